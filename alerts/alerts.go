@@ -1,6 +1,7 @@
 package alerts
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -55,6 +56,11 @@ type (
 		log    *zap.Logger
 		events EventReporter
 
+		ctx    context.Context
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+		sinks  []*sinkWorker
+
 		mu sync.Mutex
 		// alerts is a map of alert IDs to their current alert.
 		alerts map[types.Hash256]Alert
@@ -83,21 +89,25 @@ func (s Severity) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
-func (s *Severity) UnmarshalJSON(b []byte) error {
-	status := strings.Trim(string(b), `"`)
-	switch status {
+func (s *Severity) UnmarshalJSON(b []byte) (err error) {
+	*s, err = ParseSeverity(strings.Trim(string(b), `"`))
+	return
+}
+
+// ParseSeverity parses a severity from its string representation.
+func ParseSeverity(str string) (Severity, error) {
+	switch str {
 	case severityInfoStr:
-		*s = SeverityInfo
+		return SeverityInfo, nil
 	case severityWarningStr:
-		*s = SeverityWarning
+		return SeverityWarning, nil
 	case severityErrorStr:
-		*s = SeverityError
+		return SeverityError, nil
 	case severityCriticalStr:
-		*s = SeverityCritical
+		return SeverityCritical, nil
 	default:
-		return fmt.Errorf("unrecognized severity: %v", status)
+		return 0, fmt.Errorf("unrecognized severity: %v", str)
 	}
-	return nil
 }
 
 // Register registers a new alert with the manager
@@ -113,8 +123,18 @@ func (m *Manager) Register(a Alert) {
 	}
 
 	m.mu.Lock()
+	prev, exists := m.alerts[a.ID]
 	m.alerts[a.ID] = a
 	m.mu.Unlock()
+
+	// alerts are often re-registered to update their data. Sinks are only
+	// notified of new alerts or alerts whose severity or message changed.
+	if exists && prev.Severity == a.Severity && prev.Message == a.Message {
+		return
+	}
+	for _, sw := range m.sinks {
+		sw.enqueue(a)
+	}
 }
 
 // Dismiss removes the alerts with the given IDs.
@@ -141,12 +161,36 @@ func (m *Manager) Active() []Alert {
 	return alerts
 }
 
+// Close stops delivering alerts to the manager's sinks. Alerts that have not
+// been delivered yet are dropped.
+func (m *Manager) Close() error {
+	m.cancel()
+	m.wg.Wait()
+	return nil
+}
+
 // NewManager initializes a new alerts manager.
-func NewManager(er EventReporter, log *zap.Logger) *Manager {
-	return &Manager{
+func NewManager(er EventReporter, log *zap.Logger, opts ...Option) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
 		log:    log,
 		events: er,
 
+		ctx:    ctx,
+		cancel: cancel,
+
 		alerts: make(map[types.Hash256]Alert),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	for _, sw := range m.sinks {
+		m.wg.Add(1)
+		go func(sw *sinkWorker) {
+			defer m.wg.Done()
+			sw.run(m.ctx)
+		}(sw)
+	}
+	return m
 }
@@ -0,0 +1,191 @@
+package alerts_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+type (
+	nopEventReporter struct{}
+
+	mockSink struct {
+		delivered chan alerts.Alert
+	}
+)
+
+func (nopEventReporter) BroadcastEvent(string, string, any) error { return nil }
+
+func (ms *mockSink) Deliver(_ context.Context, a alerts.Alert) error {
+	ms.delivered <- a
+	return nil
+}
+
+func randomAlert(severity alerts.Severity) alerts.Alert {
+	return alerts.Alert{
+		ID:        frand.Entropy256(),
+		Severity:  severity,
+		Message:   "test alert",
+		Timestamp: time.Now(),
+	}
+}
+
+func TestAlertSink(t *testing.T) {
+	sink := &mockSink{delivered: make(chan alerts.Alert, 10)}
+	m := alerts.NewManager(nopEventReporter{}, zaptest.NewLogger(t), alerts.WithSink(sink, alerts.SeverityCritical))
+	defer m.Close()
+
+	info := randomAlert(alerts.SeverityInfo)
+	critical := randomAlert(alerts.SeverityCritical)
+	m.Register(info)
+	m.Register(critical)
+
+	// both alerts should be active regardless of delivery
+	if active := m.Active(); len(active) != 2 {
+		t.Fatalf("expected 2 active alerts, got %v", len(active))
+	}
+
+	select {
+	case a := <-sink.delivered:
+		if a.ID != critical.ID {
+			t.Fatalf("expected critical alert %v to be delivered, got %v", critical.ID, a.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("critical alert was not delivered")
+	}
+
+	// alerts are delivered in order, so the info alert would have been
+	// delivered first if it passed the filter
+	select {
+	case a := <-sink.delivered:
+		t.Fatalf("unexpected alert delivered: %v", a.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan alerts.Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alerts.Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- a
+	}))
+	defer srv.Close()
+
+	m := alerts.NewManager(nopEventReporter{}, zaptest.NewLogger(t), alerts.WithSink(alerts.NewWebhookSink(srv.URL), alerts.SeverityWarning))
+	defer m.Close()
+
+	expected := randomAlert(alerts.SeverityError)
+	expected.Data = map[string]any{"volume": "/data"}
+	m.Register(expected)
+
+	select {
+	case a := <-received:
+		switch {
+		case a.ID != expected.ID:
+			t.Fatalf("expected ID %v, got %v", expected.ID, a.ID)
+		case a.Severity != expected.Severity:
+			t.Fatalf("expected severity %v, got %v", expected.Severity, a.Severity)
+		case a.Data["volume"] != "/data":
+			t.Fatalf("expected volume data, got %v", a.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert was not delivered")
+	}
+}
+
+func TestAlertSinkUpdates(t *testing.T) {
+	sink := &mockSink{delivered: make(chan alerts.Alert, 10)}
+	m := alerts.NewManager(nopEventReporter{}, zaptest.NewLogger(t), alerts.WithSink(sink, alerts.SeverityInfo))
+	defer m.Close()
+
+	expectDelivered := func(expected bool) {
+		t.Helper()
+		select {
+		case a := <-sink.delivered:
+			if !expected {
+				t.Fatalf("unexpected alert delivered: %v", a.ID)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if expected {
+				t.Fatal("alert was not delivered")
+			}
+		}
+	}
+
+	a := randomAlert(alerts.SeverityWarning)
+	m.Register(a)
+	expectDelivered(true)
+
+	// re-registering the alert with new data should not deliver it again
+	a.Data = map[string]any{"count": 2}
+	m.Register(a)
+	expectDelivered(false)
+
+	// a severity change should be delivered
+	a.Severity = alerts.SeverityError
+	m.Register(a)
+	expectDelivered(true)
+
+	// a message change should be delivered
+	a.Message = "updated"
+	m.Register(a)
+	expectDelivered(true)
+
+	// a dismissed alert is delivered again when it is re-registered
+	m.Dismiss(a.ID)
+	m.Register(a)
+	expectDelivered(true)
+}
+
+func TestEmailSinkTimeout(t *testing.T) {
+	// accept connections but never respond
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	sink, err := alerts.NewEmailSink(alerts.EmailConfig{
+		Address: l.Addr().String(),
+		From:    "host@example.com",
+		To:      []string{"operator@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- sink.Deliver(ctx, randomAlert(alerts.SeverityCritical)) }()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("expected delivery to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery did not time out")
+	}
+}
@@ -0,0 +1,20 @@
+package alerts
+
+// An Option is a functional option that can be used to configure an alerts
+// manager.
+type Option func(*Manager)
+
+// WithSink registers a sink that receives alerts with a severity of at least
+// minSeverity as they are registered. Alerts are delivered asynchronously and
+// failed deliveries are retried, so a slow or unavailable sink never blocks
+// alert creation.
+func WithSink(sink AlertSink, minSeverity Severity) Option {
+	return func(m *Manager) {
+		m.sinks = append(m.sinks, &sinkWorker{
+			sink:        sink,
+			minSeverity: minSeverity,
+			log:         m.log.Named("sink"),
+			queue:       make(chan Alert, sinkQueueSize),
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package alerts
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// sinkQueueSize is the number of alerts buffered for each sink. Alerts
+	// registered while the queue is full are dropped.
+	sinkQueueSize = 100
+	// sinkMaxAttempts is the number of times delivery of an alert is
+	// attempted before it is dropped.
+	sinkMaxAttempts = 5
+	// sinkRetryInterval is the delay before the first retry. The delay
+	// doubles after each failed attempt.
+	sinkRetryInterval = 5 * time.Second
+	// sinkDeliveryTimeout is the maximum time a single delivery attempt may
+	// take.
+	sinkDeliveryTimeout = 30 * time.Second
+)
+
+type (
+	// An AlertSink delivers alerts to an external destination, such as an
+	// email address or a chat service.
+	AlertSink interface {
+		Deliver(ctx context.Context, a Alert) error
+	}
+
+	// A sinkWorker delivers queued alerts to a single sink.
+	sinkWorker struct {
+		sink        AlertSink
+		minSeverity Severity
+		log         *zap.Logger
+		queue       chan Alert
+	}
+)
+
+// enqueue queues an alert for delivery if it meets the sink's minimum
+// severity. It never blocks.
+func (sw *sinkWorker) enqueue(a Alert) {
+	if a.Severity < sw.minSeverity {
+		return
+	}
+
+	select {
+	case sw.queue <- a:
+	default:
+		sw.log.Warn("alert queue full, dropping alert", zap.Stringer("id", a.ID), zap.Stringer("severity", a.Severity))
+	}
+}
+
+// deliver attempts to deliver an alert to the sink, retrying with an
+// exponential backoff.
+func (sw *sinkWorker) deliver(ctx context.Context, a Alert) {
+	log := sw.log.With(zap.Stringer("id", a.ID), zap.Stringer("severity", a.Severity))
+	delay := sinkRetryInterval
+	for attempt := 1; ; attempt++ {
+		deliverCtx, cancel := context.WithTimeout(ctx, sinkDeliveryTimeout)
+		err := sw.sink.Deliver(deliverCtx, a)
+		cancel()
+		if err == nil {
+			return
+		} else if attempt >= sinkMaxAttempts {
+			log.Error("failed to deliver alert", zap.Int("attempts", attempt), zap.Error(err))
+			return
+		}
+		log.Debug("failed to deliver alert, retrying", zap.Int("attempt", attempt), zap.Duration("delay", delay), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// run delivers queued alerts until the context is cancelled.
+func (sw *sinkWorker) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a := <-sw.queue:
+			sw.deliver(ctx, a)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+)
+
+type (
+	// A WebhookSink posts alerts as JSON to a URL.
+	WebhookSink struct {
+		url    string
+		client *http.Client
+		// format converts an alert into the request body
+		format func(Alert) any
+	}
+
+	// EmailConfig configures the SMTP server used to send alert emails.
+	EmailConfig struct {
+		// Address is the host:port of the SMTP server.
+		Address string
+		// Username and Password are used for PLAIN authentication. If
+		// Username is empty, no authentication is used.
+		Username string
+		Password string
+		From     string
+		To       []string
+	}
+
+	// An EmailSink sends alerts as plain text emails.
+	EmailSink struct {
+		cfg EmailConfig
+	}
+)
+
+// Deliver implements AlertSink.
+func (ws *WebhookSink) Deliver(ctx context.Context, a Alert) error {
+	buf, err := json.Marshal(ws.format(a))
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ws.url, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendMail sends msg using the SMTP server in the sink's config. Unlike
+// smtp.SendMail, the connection is closed when ctx is cancelled or its
+// deadline passes.
+func (es *EmailSink) sendMail(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(es.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("failed to parse SMTP address: %w", err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", es.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("failed to set deadline: %w", err)
+		}
+	}
+	// unblock any pending reads or writes if the context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if es.cfg.Username != "" {
+		auth := smtp.PlainAuth("", es.cfg.Username, es.cfg.Password, host)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err := c.Mail(es.cfg.From); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range es.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to add recipient %q: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	} else if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	} else if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return c.Quit()
+}
+
+// Deliver implements AlertSink.
+func (es *EmailSink) Deliver(ctx context.Context, a Alert) error {
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", es.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(es.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [hostd %s] %s\r\n", a.Severity, a.Message)
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(alertText(a))
+	return es.sendMail(ctx, []byte(msg.String()))
+}
+
+// alertText returns a human-readable description of an alert.
+func alertText(a Alert) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] %s\n", a.Severity, a.Message)
+	fmt.Fprintf(&sb, "Time: %s\n", a.Timestamp.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(&sb, "ID: %s\n", a.ID)
+
+	keys := make([]string, 0, len(a.Data))
+	for k := range a.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s: %v\n", k, a.Data[k])
+	}
+	return sb.String()
+}
+
+// NewWebhookSink returns a sink that posts each alert as JSON to url.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: http.DefaultClient,
+		format: func(a Alert) any { return a },
+	}
+}
+
+// NewSlackSink returns a sink that posts each alert to a Slack-compatible
+// incoming webhook URL.
+func NewSlackSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: http.DefaultClient,
+		format: func(a Alert) any {
+			return map[string]string{"text": alertText(a)}
+		},
+	}
+}
+
+// NewEmailSink returns a sink that emails each alert using the SMTP server
+// in cfg.
+func NewEmailSink(cfg EmailConfig) (*EmailSink, error) {
+	switch {
+	case cfg.Address == "":
+		return nil, errors.New("SMTP address is required")
+	case cfg.From == "":
+		return nil, errors.New("sender address is required")
+	case len(cfg.To) == 0:
+		return nil, errors.New("at least one recipient is required")
+	}
+	return &EmailSink{cfg: cfg}, nil
+}
@@ -32,10 +32,20 @@ type (
 		Address() types.Address
 		ScanHeight() uint64
 		Balance() (spendable, confirmed, unconfirmed types.Currency, err error)
+		FeeReserve() types.Currency
+		WithdrawableBalance() (types.Currency, error)
+		UTXOCount() (count, target int, err error)
 		UnconfirmedTransactions() ([]wallet.Transaction, error)
 		FundTransaction(txn *types.Transaction, amount types.Currency) (toSign []types.Hash256, release func(), err error)
 		SignTransaction(cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) error
 		Transactions(limit, offset int) ([]wallet.Transaction, error)
+		TransactionCount() (uint64, error)
+		TransactionRetention() uint64
+		PrunedTransactions() (wallet.PrunedTransactions, error)
+		Reservations() []wallet.Reservation
+		ReleaseReservation(id uint64) error
+		// Reindex rebuilds the wallet by replaying every consensus change.
+		Reindex(ctx context.Context, progress func(height uint64)) error
 	}
 
 	// Settings updates and retrieves the host's settings
@@ -43,6 +53,9 @@ type (
 		Announce() error
 
 		UpdateSettings(s settings.Settings) error
+		// PreviewSettings validates the proposed settings and returns the
+		// settings that would be advertised without applying them.
+		PreviewSettings(proposed settings.Settings) (settings.SettingsPreview, error)
 		Settings() settings.Settings
 		LastAnnouncement() (settings.Announcement, error)
 
@@ -61,6 +74,9 @@ type (
 		PeriodMetrics(start time.Time, periods int, interval metrics.Interval) (period []metrics.Metrics, err error)
 		// Metrics returns aggregated metrics for the host as of the timestamp.
 		Metrics(time.Time) (m metrics.Metrics, err error)
+		// ContractLatency returns latency metrics for the contracts formed
+		// and renewed by the host.
+		ContractLatency() metrics.ContractLatencies
 	}
 
 	// A VolumeManager manages the host's storage volumes
@@ -68,13 +84,24 @@ type (
 		Usage() (usedSectors uint64, totalSectors uint64, err error)
 		Volumes() ([]storage.VolumeMeta, error)
 		Volume(id int64) (storage.VolumeMeta, error)
+		CanRemoveVolume(id int64) (storage.VolumeRemovalCheck, error)
+		// VolumeThroughput returns the number of bytes read from and
+		// written to a volume over the last window.
+		VolumeThroughput(id int64, window time.Duration) (storage.VolumeThroughput, error)
+		// MigrationStats returns the state of the sector migrations running
+		// across all volumes
+		MigrationStats() storage.MigrationStats
+		// SectorCacheStats returns the state of the sector cache
+		SectorCacheStats() storage.SectorCacheStats
 		AddVolume(ctx context.Context, localPath string, maxSectors uint64, result chan<- error) (storage.Volume, error)
 		RemoveVolume(ctx context.Context, id int64, force bool, result chan<- error) error
 		ResizeVolume(ctx context.Context, id int64, maxSectors uint64, result chan<- error) error
 		SetReadOnly(id int64, readOnly bool) error
+		SetReplicaGroup(id int64, group string) error
 		RemoveSector(root types.Hash256) error
-		ResizeCache(size uint32)
 		Read(types.Hash256) (*[rhp2.SectorSize]byte, error)
+		// VerifySector reads a sector from disk and checks its integrity
+		VerifySector(types.Hash256) error
 
 		// SectorReferences returns the references to a sector
 		SectorReferences(root types.Hash256) (storage.SectorReference, error)
@@ -89,6 +116,48 @@ type (
 		// disk. The result of each sector checked is sent on the returned
 		// channel. Read errors are logged.
 		CheckIntegrity(ctx context.Context, contractID types.FileContractID) (<-chan contracts.IntegrityResult, uint64, error)
+		// BuildSectorProof returns a Merkle proof of a sector's inclusion in
+		// the contract.
+		BuildSectorProof(id types.FileContractID, sectorIndex uint64) (contracts.SectorProof, error)
+		// ActionHistory returns the recorded lifecycle actions for a
+		// contract.
+		ActionHistory(id types.FileContractID) ([]contracts.ActionResult, error)
+		// PendingActions returns the lifecycle broadcasts the host has
+		// scheduled for its contracts.
+		PendingActions() ([]contracts.PendingAction, error)
+		// ProofBreaker returns the state of the proof failure circuit
+		// breaker.
+		ProofBreaker() contracts.ProofBreakerState
+		// ResetProofBreaker resets the proof failure circuit breaker so the
+		// host resumes accepting contracts.
+		ResetProofBreaker()
+		// AuditProvability builds and verifies the storage proof for every
+		// active contract and reports the contracts that would fail.
+		AuditProvability(context.Context) (contracts.ProvabilityReport, error)
+		// Reindex replays consensus changes to rebuild the confirmation
+		// state of the host's contracts.
+		Reindex(ctx context.Context, from modules.ConsensusChangeID, progress func(height uint64)) error
+		// FeeExpenditures returns the miner fees paid by the host in
+		// [start, end), totaled by category.
+		FeeExpenditures(start, end time.Time) ([]contracts.FeeTotal, error)
+		// ObligationsSummary returns a summary of the storage and collateral
+		// the host is obligated to by its unresolved contracts.
+		ObligationsSummary() (contracts.ObligationsSummary, error)
+		// PinContract sets whether a contract is excluded from pruning.
+		PinContract(id types.FileContractID, pinned bool) error
+		// QuarantineContract rejects renter RPCs against a contract while
+		// still submitting its storage proof.
+		QuarantineContract(id types.FileContractID, reason string) error
+		// UnquarantineContract removes a contract from quarantine.
+		UnquarantineContract(id types.FileContractID) error
+		// SetContractTags replaces the tags of a contract.
+		SetContractTags(id types.FileContractID, tags []string) error
+		// ContractsForSector returns the IDs of the contracts that would be
+		// affected if the sector were lost.
+		ContractsForSector(root types.Hash256) ([]types.FileContractID, error)
+		// Reconcile compares the contracts' sector roots with the stored
+		// sectors, optionally freeing leaked sectors.
+		Reconcile(ctx context.Context, repair bool) (contracts.ReconcileReport, error)
 	}
 
 	// An AccountManager manages ephemeral accounts
@@ -97,6 +166,12 @@ type (
 		AccountFunding(accountID rhp3.Account) ([]accounts.FundingSource, error)
 	}
 
+	// Backups creates consistent copies of the host's database while the
+	// host is running.
+	Backups interface {
+		Backup(ctx context.Context, destPath string, progress func(remaining, total int)) error
+	}
+
 	// Alerts retrieves and dismisses notifications
 	Alerts interface {
 		Active() []alerts.Alert
@@ -121,6 +196,9 @@ type (
 	TPool interface {
 		RecommendedFee() (fee types.Currency)
 		AcceptTransactionSet(txns []types.Transaction) error
+		// BroadcastMetrics returns the number of broadcast attempts,
+		// successes, and rejections since startup.
+		BroadcastMetrics() metrics.Broadcasts
 	}
 
 	// WebHooks manages webhooks
@@ -140,6 +218,18 @@ type (
 		Active() []rhp.Session
 	}
 
+	// RHP2Settings returns the settings advertised to renters for a snapshot
+	// of the host's configuration.
+	RHP2Settings interface {
+		SettingsFrom(settings.Settings) (rhp2.HostSettings, error)
+	}
+
+	// RHP3PriceTables returns the price table advertised to renters for a
+	// snapshot of the host's configuration.
+	RHP3PriceTables interface {
+		PriceTableFrom(settings.Settings) (rhp3.HostPriceTable, error)
+	}
+
 	// An api provides an HTTP API for the host
 	api struct {
 		hostKey types.PublicKey
@@ -159,13 +249,18 @@ type (
 		metrics   MetricManager
 		settings  Settings
 		sessions  RHPSessionReporter
+		rhp2      RHP2Settings
+		rhp3      RHP3PriceTables
 
 		explorerDisabled bool
 		explorer         *explorer.Explorer
 		pinned           PinnedSettings
 
+		operations *operations
 		volumeJobs volumeJobs
 		checks     integrityCheckJobs
+		backups    backupJobs
+		reindex    reindexJob
 	}
 )
 
@@ -191,13 +286,25 @@ func NewServer(name string, hostKey types.PublicKey, opts ...ServerOption) http.
 	for _, opt := range opts {
 		opt(a)
 	}
+	a.operations = newOperations()
 	a.checks = integrityCheckJobs{
-		contracts: a.contracts,
-		checks:    make(map[types.FileContractID]IntegrityCheckResult),
+		contracts:  a.contracts,
+		operations: a.operations,
+		checks:     make(map[types.FileContractID]IntegrityCheckResult),
+	}
+	a.backups.operations = a.operations
+	a.backups.log = a.log.Named("backup")
+	a.reindex = reindexJob{
+		chain:      a.chain,
+		contracts:  a.contracts,
+		wallet:     a.wallet,
+		operations: a.operations,
+		log:        a.log.Named("reindex"),
 	}
 	a.volumeJobs = volumeJobs{
-		volumes: a.volumes,
-		jobs:    make(map[int64]context.CancelFunc),
+		volumes:    a.volumes,
+		operations: a.operations,
+		jobs:       make(map[int64]uint64),
 	}
 
 	return jape.Mux(map[string]jape.Handler{
@@ -215,46 +322,77 @@ func NewServer(name string, hostKey types.PublicKey, opts ...ServerOption) http.
 		// settings endpoints
 		"GET /settings":             a.handleGETSettings,
 		"PATCH /settings":           a.handlePATCHSettings,
+		"POST /settings/preview":    a.handlePOSTSettingsPreview,
 		"POST /settings/announce":   a.handlePOSTAnnounce,
 		"PUT /settings/ddns/update": a.handlePUTDDNSUpdate,
-		"GET /settings/pinned":      a.requiresExplorer(a.handleGETPinnedSettings),
-		"PUT /settings/pinned":      a.requiresExplorer(a.handlePUTPinnedSettings),
+		// config endpoints
+		"GET /config/export":   a.handleGETConfigExport,
+		"POST /config/import":  a.handlePOSTConfigImport,
+		"GET /settings/pinned": a.requiresExplorer(a.handleGETPinnedSettings),
+		"PUT /settings/pinned": a.requiresExplorer(a.handlePUTPinnedSettings),
 		// metrics endpoints
-		"GET /metrics":         a.handleGETMetrics,
-		"GET /metrics/:period": a.handleGETPeriodMetrics,
+		"GET /metrics":           a.handleGETMetrics,
+		"GET /metrics/:period":   a.handleGETPeriodMetrics,
+		"GET /latency/contracts": a.handleGETContractLatency,
 		// contract endpoints
 		"POST /contracts":                 a.handlePostContracts,
+		"POST /contracts/reconcile":       a.handlePOSTContractsReconcile,
+		"POST /contracts/provability":     a.handlePOSTContractsProvability,
 		"GET /contracts/:id":              a.handleGETContract,
 		"GET /contracts/:id/integrity":    a.handleGETContractCheck,
 		"PUT /contracts/:id/integrity":    a.handlePUTContractCheck,
 		"DELETE /contracts/:id/integrity": a.handleDeleteContractCheck,
+		"GET /contracts/:id/proof/:index": a.handleGETContractSectorProof,
+		"GET /contracts/:id/actions":      a.handleGETContractActions,
+		"PUT /contracts/:id/pin":          a.handlePUTContractPin,
+		"PUT /contracts/:id/quarantine":   a.handlePUTContractQuarantine,
+		"PUT /contracts/:id/tags":         a.handlePUTContractTags,
+		"GET /obligations":                a.handleGETObligations,
+		"GET /fees":                       a.handleGETFees,
+		"GET /proofs/breaker":             a.handleGETProofBreaker,
+		"DELETE /proofs/breaker":          a.handleDELETEProofBreaker,
+		"GET /actions/pending":            a.handleGETPendingActions,
 		// account endpoints
 		"GET /accounts":                  a.handleGETAccounts,
 		"GET /accounts/:account/funding": a.handleGETAccountFunding,
 		// sector endpoints
-		"DELETE /sectors/:root":     a.handleDeleteSector,
-		"GET /sectors/:root/verify": a.handleGETVerifySector,
+		"DELETE /sectors/:root":        a.handleDeleteSector,
+		"GET /sectors/:root/verify":    a.handleGETVerifySector,
+		"GET /sectors/:root/contracts": a.handleGETSectorContracts,
 		// volume endpoints
-		"GET /volumes":               a.handleGETVolumes,
-		"POST /volumes":              a.handlePOSTVolume,
-		"GET /volumes/:id":           a.handleGETVolume,
-		"PUT /volumes/:id":           a.handlePUTVolume,
-		"DELETE /volumes/:id":        a.handleDeleteVolume,
-		"DELETE /volumes/:id/cancel": a.handleDELETEVolumeCancelOp,
-		"PUT /volumes/:id/resize":    a.handlePUTVolumeResize,
+		"GET /volumes":                a.handleGETVolumes,
+		"POST /volumes":               a.handlePOSTVolume,
+		"GET /volumes/:id":            a.handleGETVolume,
+		"PUT /volumes/:id":            a.handlePUTVolume,
+		"DELETE /volumes/:id":         a.handleDeleteVolume,
+		"DELETE /volumes/:id/cancel":  a.handleDELETEVolumeCancelOp,
+		"GET /volumes/:id/removal":    a.handleGETVolumeRemoval,
+		"GET /volumes/:id/throughput": a.handleGETVolumeThroughput,
+		"PUT /volumes/:id/resize":     a.handlePUTVolumeResize,
+		"GET /migrations":             a.handleGETMigrations,
+		"GET /storage/cache":          a.handleGETSectorCache,
 		// session endpoints
 		"GET /sessions":           a.handleGETSessions,
 		"GET /sessions/subscribe": a.handleGETSessionsSubscribe,
+		// operation endpoints
+		"GET /operations":        a.handleGETOperations,
+		"DELETE /operations/:id": a.handleDELETEOperation,
 		// tpool endpoints
-		"GET /tpool/fee": a.handleGETTPoolFee,
+		"GET /tpool/fee":     a.handleGETTPoolFee,
+		"GET /tpool/metrics": a.handleGETTPoolMetrics,
 		// wallet endpoints
-		"GET /wallet":              a.handleGETWallet,
-		"GET /wallet/transactions": a.handleGETWalletTransactions,
-		"GET /wallet/pending":      a.handleGETWalletPending,
-		"POST /wallet/send":        a.handlePOSTWalletSend,
+		"GET /wallet":                     a.handleGETWallet,
+		"GET /wallet/transactions":        a.handleGETWalletTransactions,
+		"GET /wallet/pending":             a.handleGETWalletPending,
+		"POST /wallet/send":               a.handlePOSTWalletSend,
+		"GET /wallet/reservations":        a.handleGETWalletReservations,
+		"DELETE /wallet/reservations/:id": a.handleDELETEWalletReservation,
 		// system endpoints
-		"GET /system/dir": a.handleGETSystemDir,
-		"PUT /system/dir": a.handlePUTSystemDir,
+		"GET /system/dir":      a.handleGETSystemDir,
+		"PUT /system/dir":      a.handlePUTSystemDir,
+		"GET /system/backup":   a.requiresBackups(a.handleGETSystemBackup),
+		"POST /system/backup":  a.requiresBackups(a.handlePOSTSystemBackup),
+		"POST /system/reindex": a.handlePOSTSystemReindex,
 		// webhook endpoints
 		"GET /webhooks":           a.handleGETWebhooks,
 		"POST /webhooks":          a.handlePOSTWebhooks,
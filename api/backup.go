@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.sia.tech/jape"
+	"go.uber.org/zap"
+)
+
+type (
+	// BackupResult tracks the result of a database backup.
+	BackupResult struct {
+		Path  string    `json:"path"`
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+		Error string    `json:"error,omitempty"`
+	}
+
+	// backupJobs tracks the most recent database backup.
+	backupJobs struct {
+		store      Backups
+		operations *operations
+		log        *zap.Logger
+
+		mu   sync.Mutex // protects last
+		last *BackupResult
+	}
+)
+
+// Last returns the result of the most recent backup.
+func (bj *backupJobs) Last() (BackupResult, bool) {
+	bj.mu.Lock()
+	defer bj.mu.Unlock()
+	if bj.last == nil {
+		return BackupResult{}, false
+	}
+	return *bj.last, true
+}
+
+// Start starts a backup of the host's database to destPath. If a backup is
+// already running, an error is returned.
+func (bj *backupJobs) Start(destPath string) error {
+	bj.mu.Lock()
+	defer bj.mu.Unlock()
+
+	if bj.last != nil && bj.last.End.IsZero() {
+		return fmt.Errorf("backup to %q already running", bj.last.Path)
+	}
+
+	ctx, opID := bj.operations.Start(OperationBackup, destPath)
+	bj.last = &BackupResult{
+		Path:  destPath,
+		Start: time.Now(),
+	}
+
+	go func() {
+		defer bj.operations.Done(opID)
+
+		err := bj.store.Backup(ctx, destPath, func(remaining, total int) {
+			bj.operations.SetProgress(opID, uint64(total-remaining), uint64(total))
+		})
+		if err != nil {
+			bj.log.Error("backup failed", zap.String("path", destPath), zap.Error(err))
+		}
+
+		bj.mu.Lock()
+		defer bj.mu.Unlock()
+		bj.last.End = time.Now()
+		if err != nil {
+			bj.last.Error = err.Error()
+		}
+	}()
+	return nil
+}
+
+func (a *api) requiresBackups(h jape.Handler) jape.Handler {
+	return func(c jape.Context) {
+		if a.backups.store == nil {
+			c.Error(errors.New("database backups are not supported"), http.StatusNotFound)
+			return
+		}
+		h(c)
+	}
+}
+
+func (a *api) handleGETSystemBackup(c jape.Context) {
+	result, ok := a.backups.Last()
+	if !ok {
+		c.Error(errors.New("no backup found"), http.StatusNotFound)
+		return
+	}
+	c.Encode(result)
+}
+
+func (a *api) handlePOSTSystemBackup(c jape.Context) {
+	var req BackupRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	} else if req.Path == "" || !filepath.IsAbs(req.Path) {
+		c.Error(errors.New("backup path must be absolute"), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.backups.Start(req.Path); err != nil {
+		c.Error(err, http.StatusConflict)
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/host/settings/pin"
+	"go.sia.tech/jape"
+)
+
+// ConfigBundleVersion is the version of the configuration bundle format.
+const ConfigBundleVersion = 1
+
+// ErrBundleVersion is returned when importing a configuration bundle with an
+// unsupported version.
+var ErrBundleVersion = errors.New("unsupported config bundle version")
+
+type (
+	// A VolumeDefinition describes a volume without its data.
+	VolumeDefinition struct {
+		LocalPath    string `json:"localPath"`
+		MaxSectors   uint64 `json:"maxSectors"`
+		ReadOnly     bool   `json:"readOnly"`
+		ReplicaGroup string `json:"replicaGroup"`
+	}
+
+	// A ConfigBundle is a portable export of the host's configuration. It
+	// contains the host's settings, pricing, and volume definitions, but no
+	// secrets or contract data. The DDNS provider options are omitted
+	// since they contain credentials.
+	ConfigBundle struct {
+		Version   int               `json:"version"`
+		Timestamp time.Time         `json:"timestamp"`
+		Settings  settings.Settings `json:"settings"`
+		// Pinned is nil if explorer data is disabled.
+		Pinned  *pin.PinnedSettings `json:"pinned,omitempty"`
+		Volumes []VolumeDefinition  `json:"volumes"`
+	}
+
+	// ImportBundleResponse is the response body for the [POST]
+	// /config/import endpoint.
+	ImportBundleResponse struct {
+		// Added are the volumes that were added. Volumes are initialized in
+		// the background.
+		Added []VolumeMeta `json:"added"`
+		// Skipped are the paths of the volumes that already exist.
+		Skipped []string `json:"skipped"`
+	}
+)
+
+// exportBundle exports the host's configuration.
+func (a *api) exportBundle(c jape.Context) (ConfigBundle, error) {
+	bundle := ConfigBundle{
+		Version:   ConfigBundleVersion,
+		Timestamp: time.Now(),
+		Settings:  a.settings.Settings(),
+	}
+	// DDNS options contain the provider's credentials
+	bundle.Settings.DDNS.Options = nil
+
+	if !a.explorerDisabled && a.pinned != nil {
+		pinned := a.pinned.Pinned(c.Request.Context())
+		bundle.Pinned = &pinned
+	}
+
+	volumes, err := a.volumes.Volumes()
+	if err != nil {
+		return ConfigBundle{}, fmt.Errorf("failed to get volumes: %w", err)
+	}
+	for _, vol := range volumes {
+		bundle.Volumes = append(bundle.Volumes, VolumeDefinition{
+			LocalPath:    vol.LocalPath,
+			MaxSectors:   vol.TotalSectors,
+			ReadOnly:     vol.ReadOnly,
+			ReplicaGroup: vol.ReplicaGroup,
+		})
+	}
+	return bundle, nil
+}
+
+// importBundle restores the host's configuration from a bundle. The host's
+// current DDNS settings are kept and volumes that already exist are skipped.
+func (a *api) importBundle(c jape.Context, bundle ConfigBundle) (resp ImportBundleResponse, err error) {
+	s := bundle.Settings
+	current := a.settings.Settings()
+	s.DDNS = current.DDNS
+	s.Revision = current.Revision
+	if err := a.settings.UpdateSettings(s); err != nil {
+		return ImportBundleResponse{}, fmt.Errorf("failed to update settings: %w", err)
+	}
+
+	if bundle.Pinned != nil && !a.explorerDisabled && a.pinned != nil {
+		if err := a.pinned.Update(c.Request.Context(), *bundle.Pinned); err != nil {
+			return ImportBundleResponse{}, fmt.Errorf("failed to update pinned settings: %w", err)
+		}
+	}
+
+	volumes, err := a.volumes.Volumes()
+	if err != nil {
+		return ImportBundleResponse{}, fmt.Errorf("failed to get volumes: %w", err)
+	}
+	existing := make(map[string]bool)
+	for _, vol := range volumes {
+		existing[vol.LocalPath] = true
+	}
+
+	for _, def := range bundle.Volumes {
+		if existing[def.LocalPath] {
+			resp.Skipped = append(resp.Skipped, def.LocalPath)
+			continue
+		}
+
+		vol, err := a.volumeJobs.AddVolume(def.LocalPath, def.MaxSectors)
+		if err != nil {
+			return resp, fmt.Errorf("failed to add volume %q: %w", def.LocalPath, err)
+		} else if def.ReadOnly {
+			if err := a.volumes.SetReadOnly(vol.ID, true); err != nil {
+				return resp, fmt.Errorf("failed to set volume %q read-only: %w", def.LocalPath, err)
+			}
+		}
+		if def.ReplicaGroup != "" {
+			if err := a.volumes.SetReplicaGroup(vol.ID, def.ReplicaGroup); err != nil {
+				return resp, fmt.Errorf("failed to set volume %q replica group: %w", def.LocalPath, err)
+			}
+		}
+
+		meta, err := a.volumes.Volume(vol.ID)
+		if err != nil {
+			return resp, fmt.Errorf("failed to get volume %q: %w", def.LocalPath, err)
+		}
+		resp.Added = append(resp.Added, toJSONVolume(meta))
+	}
+	return resp, nil
+}
+
+func (a *api) handleGETConfigExport(c jape.Context) {
+	bundle, err := a.exportBundle(c)
+	if !a.checkServerError(c, "failed to export config", err) {
+		return
+	}
+	c.Encode(bundle)
+}
+
+func (a *api) handlePOSTConfigImport(c jape.Context) {
+	var bundle ConfigBundle
+	if err := c.Decode(&bundle); err != nil {
+		return
+	} else if bundle.Version != ConfigBundleVersion {
+		c.Error(fmt.Errorf("%w: got %d, expected %d", ErrBundleVersion, bundle.Version, ConfigBundleVersion), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := a.importBundle(c, bundle)
+	if errors.Is(err, settings.ErrIngressPriceTooLow) {
+		c.Error(err, http.StatusBadRequest)
+		return
+	} else if !a.checkServerError(c, "failed to import config", err) {
+		return
+	}
+	c.Encode(resp)
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/host/storage"
+)
+
+type (
+	stubSettings struct {
+		mu sync.Mutex
+		s  settings.Settings
+	}
+
+	stubVolumes struct {
+		VolumeManager
+		mu      sync.Mutex
+		volumes []storage.VolumeMeta
+	}
+)
+
+func (ss *stubSettings) Announce() error       { return nil }
+func (ss *stubSettings) UpdateDDNS(bool) error { return nil }
+func (ss *stubSettings) LastAnnouncement() (settings.Announcement, error) {
+	return settings.Announcement{}, nil
+}
+
+func (ss *stubSettings) Settings() settings.Settings {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.s
+}
+
+func (ss *stubSettings) PreviewSettings(s settings.Settings) (settings.SettingsPreview, error) {
+	return settings.SettingsPreview{Settings: s, Advertised: s}, nil
+}
+
+func (ss *stubSettings) UpdateSettings(s settings.Settings) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.s = s
+	return nil
+}
+
+func (sv *stubVolumes) Volumes() ([]storage.VolumeMeta, error) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	return append([]storage.VolumeMeta(nil), sv.volumes...), nil
+}
+
+func (sv *stubVolumes) Volume(id int64) (storage.VolumeMeta, error) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	for _, vol := range sv.volumes {
+		if vol.ID == id {
+			return vol, nil
+		}
+	}
+	return storage.VolumeMeta{}, storage.ErrVolumeNotFound
+}
+
+func (sv *stubVolumes) AddVolume(_ context.Context, localPath string, maxSectors uint64, result chan<- error) (storage.Volume, error) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	vol := storage.Volume{
+		ID:           int64(len(sv.volumes) + 1),
+		LocalPath:    localPath,
+		TotalSectors: maxSectors,
+		Available:    true,
+	}
+	sv.volumes = append(sv.volumes, storage.VolumeMeta{Volume: vol})
+	result <- nil
+	return vol, nil
+}
+
+func (sv *stubVolumes) update(id int64, fn func(*storage.Volume)) error {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	for i := range sv.volumes {
+		if sv.volumes[i].ID == id {
+			fn(&sv.volumes[i].Volume)
+			return nil
+		}
+	}
+	return storage.ErrVolumeNotFound
+}
+
+func (sv *stubVolumes) SetReadOnly(id int64, readOnly bool) error {
+	return sv.update(id, func(v *storage.Volume) { v.ReadOnly = readOnly })
+}
+
+func (sv *stubVolumes) SetReplicaGroup(id int64, group string) error {
+	return sv.update(id, func(v *storage.Volume) { v.ReplicaGroup = group })
+}
+
+func (sv *stubVolumes) ResizeCache(uint32) {}
+
+func startTestServer(t *testing.T, s Settings, vm VolumeManager) *Client {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: NewServer("test", types.PublicKey{}, ServerWithSettings(s), ServerWithVolumeManager(vm))}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return NewClient("http://"+l.Addr().String(), "")
+}
+
+func TestConfigBundleRoundTrip(t *testing.T) {
+	s := settings.DefaultSettings
+	s.AcceptingContracts = true
+	s.NetAddress = "foo.bar:9982"
+	s.StoragePrice = types.Siacoins(1).Div64(1e9)
+	s.MaxCollateral = types.Siacoins(2000)
+	s.DDNS = settings.DNSSettings{Provider: "duckdns", IPv4: true, Options: []byte(`{"token":"secret"}`)}
+
+	source := &stubSettings{s: s}
+	sourceVolumes := &stubVolumes{}
+	client := startTestServer(t, source, sourceVolumes)
+
+	// add a few volumes to the source host
+	for _, def := range []VolumeDefinition{
+		{LocalPath: "/data/a.dat", MaxSectors: 100},
+		{LocalPath: "/data/b.dat", MaxSectors: 200, ReadOnly: true},
+		{LocalPath: "/data/c.dat", MaxSectors: 300, ReplicaGroup: "mirror"},
+	} {
+		vol, err := sourceVolumes.AddVolume(context.Background(), def.LocalPath, def.MaxSectors, make(chan error, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sourceVolumes.SetReadOnly(vol.ID, def.ReadOnly)
+		sourceVolumes.SetReplicaGroup(vol.ID, def.ReplicaGroup)
+	}
+
+	bundle, err := client.ExportConfig()
+	if err != nil {
+		t.Fatal(err)
+	} else if bundle.Version != ConfigBundleVersion {
+		t.Fatalf("expected version %d, got %d", ConfigBundleVersion, bundle.Version)
+	} else if strings.Contains(string(bundle.Settings.DDNS.Options), "secret") {
+		t.Fatal("expected DDNS credentials to be excluded")
+	}
+
+	// import the bundle on a fresh host with one of the volumes already
+	// present
+	target := &stubSettings{s: settings.DefaultSettings}
+	targetVolumes := &stubVolumes{}
+	if _, err := targetVolumes.AddVolume(context.Background(), "/data/a.dat", 100, make(chan error, 1)); err != nil {
+		t.Fatal(err)
+	}
+	targetClient := startTestServer(t, target, targetVolumes)
+
+	resp, err := targetClient.ImportConfig(bundle)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(resp.Added) != 2 {
+		t.Fatalf("expected 2 added volumes, got %v", len(resp.Added))
+	} else if !reflect.DeepEqual(resp.Skipped, []string{"/data/a.dat"}) {
+		t.Fatalf("expected a.dat to be skipped, got %v", resp.Skipped)
+	}
+
+	// the settings should be restored, except for the DDNS settings
+	expected := s
+	expected.DDNS = settings.DefaultSettings.DDNS
+	if restored := target.Settings(); !reflect.DeepEqual(restored, expected) {
+		t.Fatalf("expected settings %+v, got %+v", expected, restored)
+	}
+
+	// the volume definitions should be restored
+	restored, err := targetClient.ExportConfig()
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(restored.Volumes, bundle.Volumes) {
+		t.Fatalf("expected volumes %+v, got %+v", bundle.Volumes, restored.Volumes)
+	}
+
+	// unsupported versions should be rejected
+	bundle.Version = ConfigBundleVersion + 1
+	if _, err := targetClient.ImportConfig(bundle); err == nil || !strings.Contains(err.Error(), ErrBundleVersion.Error()) {
+		t.Fatalf("expected %v, got %v", ErrBundleVersion, err)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"go.sia.tech/hostd/wallet"
 	"go.sia.tech/hostd/webhooks"
 	"go.sia.tech/jape"
+	"go.sia.tech/siad/modules"
 )
 
 // A Client is a client for the hostd API.
@@ -77,6 +78,18 @@ func (c *Client) UpdateSettings(updated ...Setting) (settings settings.Settings,
 	return
 }
 
+// PreviewSettings returns the effect of updating the host's settings without
+// applying the update. Prices later changed by pinning or automatic storage
+// pricing are not previewed.
+func (c *Client) PreviewSettings(updated ...Setting) (resp SettingsPreviewResponse, err error) {
+	values := make(map[string]any)
+	for _, s := range updated {
+		s(values)
+	}
+	err = c.c.POST("/settings/preview", values, &resp)
+	return
+}
+
 // TestDDNS tests the dynamic DNS settings of the host.
 func (c *Client) TestDDNS() error {
 	return c.c.PUT("/settings/ddns/update", nil)
@@ -114,6 +127,106 @@ func (c *Client) Contract(id types.FileContractID) (contract contracts.Contract,
 	return
 }
 
+// SectorProof returns a Merkle proof that the sector at the specified index is
+// included in the contract.
+func (c *Client) SectorProof(id types.FileContractID, sectorIndex uint64) (proof contracts.SectorProof, err error) {
+	err = c.c.GET(fmt.Sprintf("/contracts/%v/proof/%d", id, sectorIndex), &proof)
+	return
+}
+
+// ExportConfig exports the host's configuration as a portable bundle.
+func (c *Client) ExportConfig() (bundle ConfigBundle, err error) {
+	err = c.c.GET("/config/export", &bundle)
+	return
+}
+
+// ImportConfig restores the host's configuration from a bundle.
+func (c *Client) ImportConfig(bundle ConfigBundle) (resp ImportBundleResponse, err error) {
+	err = c.c.POST("/config/import", bundle, &resp)
+	return
+}
+
+// PinContract sets whether the contract with the specified ID is excluded
+// from pruning.
+func (c *Client) PinContract(id types.FileContractID, pinned bool) error {
+	return c.c.PUT(fmt.Sprintf("/contracts/%v/pin", id), PinContractRequest{Pinned: pinned})
+}
+
+// SetContractTags replaces the tags of the contract with the specified ID.
+func (c *Client) SetContractTags(id types.FileContractID, tags []string) error {
+	return c.c.PUT(fmt.Sprintf("/contracts/%v/tags", id), ContractTagsRequest{Tags: tags})
+}
+
+// QuarantineContract quarantines the contract with the specified ID. Renter
+// RPCs against the contract are rejected but its storage proof is still
+// submitted.
+func (c *Client) QuarantineContract(id types.FileContractID, reason string) error {
+	return c.c.PUT(fmt.Sprintf("/contracts/%v/quarantine", id), QuarantineContractRequest{Quarantined: true, Reason: reason})
+}
+
+// UnquarantineContract removes the contract with the specified ID from
+// quarantine.
+func (c *Client) UnquarantineContract(id types.FileContractID) error {
+	return c.c.PUT(fmt.Sprintf("/contracts/%v/quarantine", id), QuarantineContractRequest{})
+}
+
+// ReconcileContracts compares the contracts' sector roots with the host's
+// stored sectors. If repair is true, leaked sectors are freed.
+func (c *Client) ReconcileContracts(repair bool) (report contracts.ReconcileReport, err error) {
+	err = c.c.POST("/contracts/reconcile", ReconcileContractsRequest{Repair: repair}, &report)
+	return
+}
+
+// ContractActions returns the lifecycle actions recorded for a contract.
+func (c *Client) ContractActions(id types.FileContractID) (actions []contracts.ActionResult, err error) {
+	err = c.c.GET(fmt.Sprintf("/contracts/%v/actions", id), &actions)
+	return
+}
+
+// PendingActions returns the formation, final revision, and storage proof
+// broadcasts the host has scheduled for its contracts.
+func (c *Client) PendingActions() (actions []contracts.PendingAction, err error) {
+	err = c.c.GET("/actions/pending", &actions)
+	return
+}
+
+// FeeExpenditures returns the miner fees paid by the host in [start, end),
+// totaled by category.
+func (c *Client) FeeExpenditures(start, end time.Time) (fees []contracts.FeeTotal, err error) {
+	v := url.Values{
+		"start": []string{start.Format(time.RFC3339)},
+		"end":   []string{end.Format(time.RFC3339)},
+	}
+	err = c.c.GET("/fees?"+v.Encode(), &fees)
+	return
+}
+
+// ProofBreaker returns the state of the proof failure circuit breaker.
+func (c *Client) ProofBreaker() (state contracts.ProofBreakerState, err error) {
+	err = c.c.GET("/proofs/breaker", &state)
+	return
+}
+
+// ResetProofBreaker resets the proof failure circuit breaker so the host
+// resumes accepting contracts.
+func (c *Client) ResetProofBreaker() error {
+	return c.c.DELETE("/proofs/breaker")
+}
+
+// AuditProvability builds and verifies the storage proof for every active
+// contract and reports the contracts the host cannot currently prove.
+func (c *Client) AuditProvability() (report contracts.ProvabilityReport, err error) {
+	err = c.c.POST("/contracts/provability", nil, &report)
+	return
+}
+
+// Obligations returns a summary of the storage and collateral the host is
+// obligated to by its unresolved contracts.
+func (c *Client) Obligations() (summary contracts.ObligationsSummary, err error) {
+	err = c.c.GET("/obligations", &summary)
+	return
+}
+
 // StartIntegrityCheck scans the volume with the specified ID for consistency errors.
 func (c *Client) StartIntegrityCheck(id types.FileContractID) error {
 	return c.c.PUT(fmt.Sprintf("/contracts/%v/integrity", id), nil)
@@ -138,6 +251,12 @@ func (c *Client) DeleteSector(root types.Hash256) error {
 	return c.c.DELETE(fmt.Sprintf("/sectors/%s", root))
 }
 
+// SectorContracts returns the IDs of the contracts that reference a sector.
+func (c *Client) SectorContracts(root types.Hash256) (ids []types.FileContractID, err error) {
+	err = c.c.GET(fmt.Sprintf("/sectors/%s/contracts", root), &ids)
+	return
+}
+
 // Volumes returns the volumes of the host.
 func (c *Client) Volumes() (volumes []VolumeMeta, err error) {
 	err = c.c.GET("/volumes", &volumes)
@@ -150,6 +269,33 @@ func (c *Client) Volume(id int) (volume VolumeMeta, err error) {
 	return
 }
 
+// VolumeRemoval returns whether a volume can be removed without losing data
+// and what is preventing it.
+func (c *Client) VolumeRemoval(id int64) (check storage.VolumeRemovalCheck, err error) {
+	err = c.c.GET(fmt.Sprintf("/volumes/%d/removal", id), &check)
+	return
+}
+
+// VolumeThroughput returns the number of bytes read from and written to a
+// volume over the last window.
+func (c *Client) VolumeThroughput(id int64, window time.Duration) (throughput storage.VolumeThroughput, err error) {
+	err = c.c.GET(fmt.Sprintf("/volumes/%d/throughput?window=%s", id, window), &throughput)
+	return
+}
+
+// MigrationStats returns the state of the sector migrations running across
+// all volumes.
+func (c *Client) MigrationStats() (stats storage.MigrationStats, err error) {
+	err = c.c.GET("/migrations", &stats)
+	return
+}
+
+// SectorCacheStats returns the state of the sector cache.
+func (c *Client) SectorCacheStats() (stats storage.SectorCacheStats, err error) {
+	err = c.c.GET("/storage/cache", &stats)
+	return
+}
+
 // AddVolume adds a new volume to the host
 func (c *Client) AddVolume(localPath string, sectors uint64) (vol storage.Volume, err error) {
 	req := AddVolumeRequest{
@@ -178,6 +324,36 @@ func (c *Client) ResizeVolume(id int, sectors uint64) error {
 	return c.c.PUT(fmt.Sprintf("/volumes/%v/resize", id), req)
 }
 
+// Operations returns the host's in-progress long operations.
+func (c *Client) Operations() (ops []Operation, err error) {
+	err = c.c.GET("/operations", &ops)
+	return
+}
+
+// CancelOperation cancels the in-progress operation with the specified ID.
+func (c *Client) CancelOperation(id uint64) error {
+	return c.c.DELETE(fmt.Sprintf("/operations/%d", id))
+}
+
+// Backup starts a backup of the host's database to the specified path.
+// Progress can be tracked with [Client.Operations].
+func (c *Client) Backup(path string) error {
+	return c.c.POST("/system/backup", BackupRequest{Path: path}, nil)
+}
+
+// Reindex starts rebuilding the host's wallet and contract state by replaying
+// consensus changes. Contracts are replayed starting after from. The progress
+// of the reindex is reported by Operations.
+func (c *Client) Reindex(from modules.ConsensusChangeID) error {
+	return c.c.POST("/system/reindex", ReindexRequest{From: from}, nil)
+}
+
+// LastBackup returns the result of the most recent database backup.
+func (c *Client) LastBackup() (result BackupResult, err error) {
+	err = c.c.GET("/system/backup", &result)
+	return
+}
+
 // Wallet returns the state of the host's wallet.
 func (c *Client) Wallet() (resp WalletResponse, err error) {
 	err = c.c.GET("/wallet", &resp)
@@ -208,6 +384,32 @@ func (c *Client) SendSiacoins(address types.Address, amount types.Currency, subt
 	return
 }
 
+// WalletReservations returns the outputs locked by outstanding calls to fund
+// transactions.
+func (c *Client) WalletReservations() (reservations []wallet.Reservation, err error) {
+	err = c.c.GET("/wallet/reservations", &reservations)
+	return
+}
+
+// ReleaseWalletReservation forcibly unlocks the outputs of a reservation. The
+// outputs may be double spent if the reserving transaction is broadcast.
+func (c *Client) ReleaseWalletReservation(id uint64) error {
+	return c.c.DELETE(fmt.Sprintf("/wallet/reservations/%d", id))
+}
+
+// ContractLatency returns the time the host spent in each phase of contract
+// formation and renewal.
+func (c *Client) ContractLatency() (latency metrics.ContractLatencies, err error) {
+	err = c.c.GET("/latency/contracts", &latency)
+	return
+}
+
+// TPoolMetrics returns the host's transaction pool broadcast metrics.
+func (c *Client) TPoolMetrics() (metrics metrics.Broadcasts, err error) {
+	err = c.c.GET("/tpool/metrics", &metrics)
+	return
+}
+
 // LocalDir returns the contents of the specified directory on the host.
 func (c *Client) LocalDir(path string) (resp SystemDirResponse, err error) {
 	v := url.Values{
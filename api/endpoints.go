@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"time"
 
+	rhp2 "go.sia.tech/core/rhp/v2"
 	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/build"
@@ -20,6 +21,7 @@ import (
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/internal/disk"
 	"go.sia.tech/hostd/internal/prometheus"
+	"go.sia.tech/hostd/wallet"
 	"go.sia.tech/hostd/webhooks"
 	"go.sia.tech/jape"
 	"go.sia.tech/siad/modules"
@@ -166,47 +168,87 @@ func (a *api) handleGETSettings(c jape.Context) {
 	a.writeResponse(c, hs)
 }
 
-func (a *api) handlePATCHSettings(c jape.Context) {
+// decodeSettingsPatch decodes a partial settings update from the request
+// body and applies it to the host's current settings.
+func (a *api) decodeSettingsPatch(c jape.Context) (settings.Settings, bool) {
 	buf, err := json.Marshal(a.settings.Settings())
 	if !a.checkServerError(c, "failed to marshal existing settings", err) {
-		return
+		return settings.Settings{}, false
 	}
 	var current map[string]any
 	err = json.Unmarshal(buf, &current)
 	if !a.checkServerError(c, "failed to unmarshal existing settings", err) {
-		return
+		return settings.Settings{}, false
 	}
 
 	var req map[string]any
 	if err := c.Decode(&req); err != nil {
-		return
+		return settings.Settings{}, false
 	}
 
 	err = patchSettings(current, req)
 	if !a.checkServerError(c, "failed to patch settings", err) {
-		return
+		return settings.Settings{}, false
 	}
 
 	buf, err = json.Marshal(current)
 	if !a.checkServerError(c, "failed to marshal patched settings", err) {
+		return settings.Settings{}, false
+	}
+
+	var updated settings.Settings
+	if err := json.Unmarshal(buf, &updated); err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return settings.Settings{}, false
+	}
+	return updated, true
+}
+
+func (a *api) handlePATCHSettings(c jape.Context) {
+	updated, ok := a.decodeSettingsPatch(c)
+	if !ok {
 		return
 	}
 
-	var settings settings.Settings
-	if err := json.Unmarshal(buf, &settings); err != nil {
+	err := a.settings.UpdateSettings(updated)
+	if errors.Is(err, settings.ErrIngressPriceTooLow) {
 		c.Error(err, http.StatusBadRequest)
 		return
+	} else if !a.checkServerError(c, "failed to update settings", err) {
+		return
 	}
 
-	err = a.settings.UpdateSettings(settings)
-	if !a.checkServerError(c, "failed to update settings", err) {
+	c.Encode(a.settings.Settings())
+}
+
+func (a *api) handlePOSTSettingsPreview(c jape.Context) {
+	proposed, ok := a.decodeSettingsPatch(c)
+	if !ok {
 		return
 	}
 
-	// Resize the cache based on the updated settings
-	a.volumes.ResizeCache(settings.SectorCacheSize)
+	preview, err := a.settings.PreviewSettings(proposed)
+	if err != nil {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
 
-	c.Encode(a.settings.Settings())
+	resp := SettingsPreviewResponse{SettingsPreview: preview}
+	if a.rhp2 != nil {
+		hs, err := a.rhp2.SettingsFrom(preview.Advertised)
+		if !a.checkServerError(c, "failed to compute host settings", err) {
+			return
+		}
+		resp.HostSettings = &hs
+	}
+	if a.rhp3 != nil {
+		pt, err := a.rhp3.PriceTableFrom(preview.Advertised)
+		if !a.checkServerError(c, "failed to compute price table", err) {
+			return
+		}
+		resp.PriceTable = &pt
+	}
+	c.Encode(resp)
 }
 
 func (a *api) handleGETPinnedSettings(c jape.Context) {
@@ -219,7 +261,12 @@ func (a *api) handlePUTPinnedSettings(c jape.Context) {
 		return
 	}
 
-	a.checkServerError(c, "failed to update pinned settings", a.pinned.Update(c.Request.Context(), req))
+	err := a.pinned.Update(c.Request.Context(), req)
+	if errors.Is(err, pin.ErrStoragePriceManaged) {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+	a.checkServerError(c, "failed to update pinned settings", err)
 }
 
 func (a *api) handlePUTDDNSUpdate(c jape.Context) {
@@ -303,22 +350,30 @@ func (a *api) handleGETPeriodMetrics(c jape.Context) {
 	c.Encode(period)
 }
 
+func (a *api) handleGETContractLatency(c jape.Context) {
+	a.writeResponse(c, a.metrics.ContractLatency())
+}
+
 func (a *api) handlePostContracts(c jape.Context) {
 	var filter contracts.ContractFilter
 	if err := c.Decode(&filter); err != nil {
 		return
 	}
 
-	if filter.Limit <= 0 || filter.Limit > 500 {
-		filter.Limit = 500
+	if filter.Limit <= 0 || filter.Limit > contracts.MaxContractsLimit {
+		filter.Limit = contracts.MaxContractsLimit
 	}
 
-	contracts, count, err := a.contracts.Contracts(filter)
-	if !a.checkServerError(c, "failed to get contracts", err) {
+	results, count, err := a.contracts.Contracts(filter)
+	if errors.Is(err, contracts.ErrNotFound) {
+		// the cursor does not exist
+		c.Error(err, http.StatusBadRequest)
+		return
+	} else if !a.checkServerError(c, "failed to get contracts", err) {
 		return
 	}
 	c.Encode(ContractsResponse{
-		Contracts: contracts,
+		Contracts: results,
 		Count:     count,
 	})
 }
@@ -338,6 +393,177 @@ func (a *api) handleGETContract(c jape.Context) {
 	c.Encode(contract)
 }
 
+func (a *api) handlePUTContractPin(c jape.Context) {
+	var id types.FileContractID
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+	var req PinContractRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	}
+	err := a.contracts.PinContract(id, req.Pinned)
+	if errors.Is(err, contracts.ErrNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to pin contract", err)
+}
+
+func (a *api) handlePUTContractQuarantine(c jape.Context) {
+	var id types.FileContractID
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+	var req QuarantineContractRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	}
+
+	var err error
+	if req.Quarantined {
+		err = a.contracts.QuarantineContract(id, req.Reason)
+	} else {
+		err = a.contracts.UnquarantineContract(id)
+	}
+	if errors.Is(err, contracts.ErrNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to quarantine contract", err)
+}
+
+func (a *api) handlePUTContractTags(c jape.Context) {
+	var id types.FileContractID
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+	var req ContractTagsRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	}
+	err := a.contracts.SetContractTags(id, req.Tags)
+	if errors.Is(err, contracts.ErrNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	} else if errors.Is(err, contracts.ErrInvalidTag) {
+		c.Error(err, http.StatusBadRequest)
+		return
+	}
+	a.checkServerError(c, "failed to set contract tags", err)
+}
+
+func (a *api) handlePOSTContractsReconcile(c jape.Context) {
+	var req ReconcileContractsRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	}
+	report, err := a.contracts.Reconcile(c.Request.Context(), req.Repair)
+	if !a.checkServerError(c, "failed to reconcile contracts", err) {
+		return
+	}
+	a.writeResponse(c, report)
+}
+
+func (a *api) handlePOSTContractsProvability(c jape.Context) {
+	report, err := a.contracts.AuditProvability(c.Request.Context())
+	if !a.checkServerError(c, "failed to audit contract provability", err) {
+		return
+	}
+	c.Encode(report)
+}
+
+func (a *api) handleGETContractSectorProof(c jape.Context) {
+	var id types.FileContractID
+	var index uint64
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	} else if err := c.DecodeParam("index", &index); err != nil {
+		return
+	}
+
+	contract, err := a.contracts.Contract(id)
+	if errors.Is(err, contracts.ErrNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	} else if !a.checkServerError(c, "failed to get contract", err) {
+		return
+	} else if sectors := contract.Revision.Filesize / rhp2.SectorSize; index >= sectors {
+		c.Error(fmt.Errorf("%w: index %v, contract has %v sectors", contracts.ErrSectorIndexOutOfRange, index, sectors), http.StatusBadRequest)
+		return
+	}
+
+	proof, err := a.contracts.BuildSectorProof(id, index)
+	if errors.Is(err, contracts.ErrNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	} else if errors.Is(err, contracts.ErrSectorIndexOutOfRange) {
+		// the contract was revised after the index was checked
+		c.Error(err, http.StatusBadRequest)
+		return
+	} else if !a.checkServerError(c, "failed to build sector proof", err) {
+		return
+	}
+	c.Encode(proof)
+}
+
+func (a *api) handleGETContractActions(c jape.Context) {
+	var id types.FileContractID
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+	actions, err := a.contracts.ActionHistory(id)
+	if !a.checkServerError(c, "failed to get contract actions", err) {
+		return
+	}
+	c.Encode(actions)
+}
+
+func (a *api) handleGETPendingActions(c jape.Context) {
+	actions, err := a.contracts.PendingActions()
+	if !a.checkServerError(c, "failed to get pending actions", err) {
+		return
+	}
+	c.Encode(actions)
+}
+
+func (a *api) handleGETFees(c jape.Context) {
+	var start, end time.Time
+	if err := c.DecodeForm("start", &start); err != nil {
+		return
+	} else if err := c.DecodeForm("end", &end); err != nil {
+		return
+	} else if end.IsZero() {
+		end = time.Now()
+	}
+	if start.After(end) {
+		c.Error(errors.New("start time cannot be after end time"), http.StatusBadRequest)
+		return
+	}
+
+	fees, err := a.contracts.FeeExpenditures(start, end)
+	if !a.checkServerError(c, "failed to get fee expenditures", err) {
+		return
+	}
+	c.Encode(fees)
+}
+
+func (a *api) handleGETProofBreaker(c jape.Context) {
+	c.Encode(a.contracts.ProofBreaker())
+}
+
+func (a *api) handleDELETEProofBreaker(c jape.Context) {
+	a.contracts.ResetProofBreaker()
+}
+
+func (a *api) handleGETObligations(c jape.Context) {
+	summary, err := a.contracts.ObligationsSummary()
+	if !a.checkServerError(c, "failed to get obligations summary", err) {
+		return
+	}
+	c.Encode(summary)
+}
+
 func (a *api) handleGETVolume(c jape.Context) {
 	var id int64
 	if err := c.DecodeParam("id", &id); err != nil {
@@ -357,6 +583,65 @@ func (a *api) handleGETVolume(c jape.Context) {
 	c.Encode(toJSONVolume(volume))
 }
 
+func (a *api) handleGETVolumeThroughput(c jape.Context) {
+	var id int64
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	} else if id < 0 {
+		c.Error(errors.New("invalid volume id"), http.StatusBadRequest)
+		return
+	}
+
+	window := "1h"
+	if err := c.DecodeForm("window", &window); err != nil {
+		return
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		c.Error(fmt.Errorf("invalid window: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	throughput, err := a.volumes.VolumeThroughput(id, d)
+	if errors.Is(err, storage.ErrVolumeNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	} else if errors.Is(err, storage.ErrInvalidThroughputWindow) {
+		c.Error(err, http.StatusBadRequest)
+		return
+	} else if !a.checkServerError(c, "failed to get volume throughput", err) {
+		return
+	}
+	c.Encode(throughput)
+}
+
+func (a *api) handleGETVolumeRemoval(c jape.Context) {
+	var id int64
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	} else if id < 0 {
+		c.Error(errors.New("invalid volume id"), http.StatusBadRequest)
+		return
+	}
+
+	check, err := a.volumes.CanRemoveVolume(id)
+	if errors.Is(err, storage.ErrVolumeNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	} else if !a.checkServerError(c, "failed to check volume removal", err) {
+		return
+	}
+	a.writeResponse(c, check)
+}
+
+func (a *api) handleGETMigrations(c jape.Context) {
+	a.writeResponse(c, MigrationResp(a.volumes.MigrationStats()))
+}
+
+func (a *api) handleGETSectorCache(c jape.Context) {
+	a.writeResponse(c, SectorCacheResp(a.volumes.SectorCacheStats()))
+}
+
 func (a *api) handlePUTVolume(c jape.Context) {
 	var id int64
 	if err := c.DecodeParam("id", &id); err != nil {
@@ -372,6 +657,9 @@ func (a *api) handlePUTVolume(c jape.Context) {
 	}
 
 	err := a.volumes.SetReadOnly(id, req.ReadOnly)
+	if err == nil && req.ReplicaGroup != nil {
+		err = a.volumes.SetReplicaGroup(id, *req.ReplicaGroup)
+	}
 	if errors.Is(err, storage.ErrVolumeNotFound) {
 		c.Error(err, http.StatusNotFound)
 		return
@@ -388,17 +676,53 @@ func (a *api) handleDeleteSector(c jape.Context) {
 	a.checkServerError(c, "failed to remove sector", err)
 }
 
+func (a *api) handleGETSectorContracts(c jape.Context) {
+	var root types.Hash256
+	if err := c.DecodeParam("root", &root); err != nil {
+		return
+	}
+	ids, err := a.contracts.ContractsForSector(root)
+	if !a.checkServerError(c, "failed to get sector contracts", err) {
+		return
+	}
+	a.writeResponse(c, ids)
+}
+
 func (a *api) handleGETWallet(c jape.Context) {
 	spendable, confirmed, unconfirmed, err := a.wallet.Balance()
 	if !a.checkServerError(c, "failed to get wallet", err) {
 		return
 	}
+	count, err := a.wallet.TransactionCount()
+	if !a.checkServerError(c, "failed to get wallet transaction count", err) {
+		return
+	}
+	pruned, err := a.wallet.PrunedTransactions()
+	if !a.checkServerError(c, "failed to get pruned wallet transactions", err) {
+		return
+	}
+	withdrawable, err := a.wallet.WithdrawableBalance()
+	if !a.checkServerError(c, "failed to get withdrawable balance", err) {
+		return
+	}
+	utxos, minUTXOs, err := a.wallet.UTXOCount()
+	if !a.checkServerError(c, "failed to get wallet output count", err) {
+		return
+	}
 	a.writeResponse(c, WalletResponse{
-		ScanHeight:  a.wallet.ScanHeight(),
-		Address:     a.wallet.Address(),
-		Spendable:   spendable,
-		Confirmed:   confirmed,
-		Unconfirmed: unconfirmed,
+		ScanHeight:   a.wallet.ScanHeight(),
+		Address:      a.wallet.Address(),
+		Spendable:    spendable,
+		Confirmed:    confirmed,
+		Unconfirmed:  unconfirmed,
+		FeeReserve:   a.wallet.FeeReserve(),
+		Withdrawable: withdrawable,
+		UTXOs:        utxos,
+		MinUTXOs:     minUTXOs,
+
+		TransactionCount:     count,
+		TransactionRetention: a.wallet.TransactionRetention(),
+		PrunedTransactions:   pruned,
 	})
 }
 
@@ -421,6 +745,23 @@ func (a *api) handleGETWalletPending(c jape.Context) {
 	a.writeResponse(c, WalletPendingResp(pending))
 }
 
+func (a *api) handleGETWalletReservations(c jape.Context) {
+	a.writeResponse(c, a.wallet.Reservations())
+}
+
+func (a *api) handleDELETEWalletReservation(c jape.Context) {
+	var id uint64
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+	err := a.wallet.ReleaseReservation(id)
+	if errors.Is(err, wallet.ErrReservationNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to release reservation", err)
+}
+
 func (a *api) handlePOSTWalletSend(c jape.Context) {
 	var req WalletSendSiacoinsRequest
 	if err := c.Decode(&req); err != nil {
@@ -560,6 +901,10 @@ func (a *api) handleGETTPoolFee(c jape.Context) {
 	a.writeResponse(c, TPoolResp(a.tpool.RecommendedFee()))
 }
 
+func (a *api) handleGETTPoolMetrics(c jape.Context) {
+	a.writeResponse(c, a.tpool.BroadcastMetrics())
+}
+
 func (a *api) handleGETAccounts(c jape.Context) {
 	limit, offset := parseLimitParams(c, 100, 500)
 	accounts, err := a.accounts.Accounts(limit, offset)
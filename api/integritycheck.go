@@ -1,7 +1,6 @@
 package api
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"sync"
@@ -25,7 +24,8 @@ type (
 
 	// integrityChecks tracks the result of all integrity checks.
 	integrityCheckJobs struct {
-		contracts ContractManager
+		contracts  ContractManager
+		operations *operations
 
 		mu     sync.Mutex // protects checks
 		checks map[types.FileContractID]IntegrityCheckResult
@@ -63,8 +63,10 @@ func (ic *integrityCheckJobs) CheckContract(contractID types.FileContractID) (ui
 		return 0, fmt.Errorf("integrity check already running for contract %v", contractID)
 	}
 
-	results, roots, err := ic.contracts.CheckIntegrity(context.Background(), contractID)
+	ctx, opID := ic.operations.Start(OperationIntegrityCheck, contractID.String())
+	results, roots, err := ic.contracts.CheckIntegrity(ctx, contractID)
 	if err != nil {
+		ic.operations.Done(opID)
 		return 0, fmt.Errorf("failed to check contract integrity: %w", err)
 	}
 
@@ -75,6 +77,8 @@ func (ic *integrityCheckJobs) CheckContract(contractID types.FileContractID) (ui
 	ic.checks[contractID] = check
 
 	go func() {
+		defer ic.operations.Done(opID)
+
 		for result := range results {
 			ic.mu.Lock()
 			check := ic.checks[contractID]
@@ -84,6 +88,7 @@ func (ic *integrityCheckJobs) CheckContract(contractID types.FileContractID) (ui
 			}
 			ic.checks[contractID] = check
 			ic.mu.Unlock()
+			ic.operations.SetProgress(opID, check.CheckedSectors, check.TotalSectors)
 		}
 		ic.mu.Lock()
 		check := ic.checks[contractID]
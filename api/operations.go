@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.sia.tech/jape"
+)
+
+// operation types
+const (
+	OperationAddVolume      = "addVolume"
+	OperationRemoveVolume   = "removeVolume"
+	OperationResizeVolume   = "resizeVolume"
+	OperationIntegrityCheck = "integrityCheck"
+	OperationBackup         = "backup"
+	OperationReindex        = "reindex"
+)
+
+// ErrOperationNotFound is returned when an operation does not exist or has
+// already completed.
+var ErrOperationNotFound = errors.New("operation not found")
+
+type (
+	// An Operation is a long-running operation started through the API.
+	Operation struct {
+		ID     uint64 `json:"id"`
+		Type   string `json:"type"`
+		Target string `json:"target"`
+
+		Progress uint64    `json:"progress"`
+		Total    uint64    `json:"total"`
+		Start    time.Time `json:"start"`
+	}
+
+	trackedOperation struct {
+		Operation
+		cancel context.CancelFunc
+	}
+
+	// operations tracks the host's in-progress long operations so they can be
+	// listed and cancelled from a single place.
+	operations struct {
+		mu     sync.Mutex // protects the fields below
+		nextID uint64
+		ops    map[uint64]*trackedOperation
+	}
+)
+
+// Start registers a new operation. The returned context is cancelled when
+// the operation is cancelled or marked done.
+func (o *operations) Start(opType, target string) (context.Context, uint64) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.nextID++
+	id := o.nextID
+	o.ops[id] = &trackedOperation{
+		Operation: Operation{
+			ID:     id,
+			Type:   opType,
+			Target: target,
+			Start:  time.Now(),
+		},
+		cancel: cancel,
+	}
+	return ctx, id
+}
+
+// SetTarget updates the target of an operation. It is used when the target
+// is not known until after the operation has started.
+func (o *operations) SetTarget(id uint64, target string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if op, ok := o.ops[id]; ok {
+		op.Target = target
+	}
+}
+
+// SetProgress updates the progress of an operation.
+func (o *operations) SetProgress(id uint64, progress, total uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if op, ok := o.ops[id]; ok {
+		op.Progress, op.Total = progress, total
+	}
+}
+
+// Done removes a completed operation and releases its context.
+func (o *operations) Done(id uint64) {
+	o.mu.Lock()
+	op, ok := o.ops[id]
+	delete(o.ops, id)
+	o.mu.Unlock()
+	if ok {
+		op.cancel()
+	}
+}
+
+// Operations returns all in-progress operations ordered by start time.
+func (o *operations) Operations() []Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ops := make([]Operation, 0, len(o.ops))
+	for _, op := range o.ops {
+		ops = append(ops, op.Operation)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].ID < ops[j].ID
+	})
+	return ops
+}
+
+// CancelOperation cancels the context of an in-progress operation and removes
+// it from the registry.
+func (o *operations) CancelOperation(id uint64) error {
+	o.mu.Lock()
+	op, ok := o.ops[id]
+	delete(o.ops, id)
+	o.mu.Unlock()
+	if !ok {
+		return ErrOperationNotFound
+	}
+	op.cancel()
+	return nil
+}
+
+func newOperations() *operations {
+	return &operations{
+		ops: make(map[uint64]*trackedOperation),
+	}
+}
+
+func (a *api) handleGETOperations(c jape.Context) {
+	c.Encode(a.operations.Operations())
+}
+
+func (a *api) handleDELETEOperation(c jape.Context) {
+	var id uint64
+	if err := c.DecodeParam("id", &id); err != nil {
+		return
+	}
+
+	err := a.operations.CancelOperation(id)
+	if errors.Is(err, ErrOperationNotFound) {
+		c.Error(err, http.StatusNotFound)
+		return
+	}
+	a.checkServerError(c, "failed to cancel operation", err)
+}
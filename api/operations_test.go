@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCancelOperation(t *testing.T) {
+	ops := newOperations()
+
+	ctx, id := ops.Start(OperationResizeVolume, "1")
+	stopped := make(chan struct{})
+	go func() {
+		// mock long operation that runs until cancelled
+		defer close(stopped)
+		for i := uint64(1); ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+				ops.SetProgress(id, i, 100)
+			}
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	active := ops.Operations()
+	if len(active) != 1 {
+		t.Fatalf("expected 1 operation, got %d", len(active))
+	} else if active[0].ID != id {
+		t.Fatalf("expected operation %d, got %d", id, active[0].ID)
+	} else if active[0].Type != OperationResizeVolume || active[0].Target != "1" {
+		t.Fatalf("unexpected operation %+v", active[0])
+	} else if active[0].Progress == 0 || active[0].Total != 100 {
+		t.Fatalf("expected progress to be reported, got %d/%d", active[0].Progress, active[0].Total)
+	} else if active[0].Start.IsZero() {
+		t.Fatal("expected start time to be set")
+	}
+
+	if err := ops.CancelOperation(id); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("operation was not cancelled")
+	}
+
+	if n := len(ops.Operations()); n != 0 {
+		t.Fatalf("expected no operations, got %d", n)
+	} else if err := ops.CancelOperation(id); !errors.Is(err, ErrOperationNotFound) {
+		t.Fatalf("expected ErrOperationNotFound, got %v", err)
+	}
+
+	// completed operations are removed from the registry
+	_, id = ops.Start(OperationIntegrityCheck, "foo")
+	ops.Done(id)
+	if n := len(ops.Operations()); n != 0 {
+		t.Fatalf("expected no operations, got %d", n)
+	}
+}
@@ -93,6 +93,22 @@ func ServerWithSettings(s Settings) ServerOption {
 	}
 }
 
+// ServerWithRHP2Settings sets the RHP2 settings provider used to preview
+// settings changes.
+func ServerWithRHP2Settings(s RHP2Settings) ServerOption {
+	return func(a *api) {
+		a.rhp2 = s
+	}
+}
+
+// ServerWithRHP3PriceTables sets the RHP3 price table provider used to
+// preview settings changes.
+func ServerWithRHP3PriceTables(pt RHP3PriceTables) ServerOption {
+	return func(a *api) {
+		a.rhp3 = pt
+	}
+}
+
 // ServerWithRHPSessionReporter sets the RHP session reporter for the API server.
 func ServerWithRHPSessionReporter(rsr RHPSessionReporter) ServerOption {
 	return func(a *api) {
@@ -107,6 +123,13 @@ func ServerWithWallet(w Wallet) ServerOption {
 	}
 }
 
+// ServerWithBackups sets the database backup provider for the API server.
+func ServerWithBackups(b Backups) ServerOption {
+	return func(a *api) {
+		a.backups.store = b
+	}
+}
+
 // ServerWithLogger sets the logger for the API server.
 func ServerWithLogger(log *zap.Logger) ServerOption {
 	return func(a *api) {
@@ -110,6 +110,10 @@ func (hs HostSettings) PrometheusMetric() []prometheus.Metric {
 			Name:  "hostd_settings_max_account_balance",
 			Value: hs.MaxAccountBalance.Siacoins(),
 		},
+		{
+			Name:  "hostd_settings_min_account_deposit",
+			Value: hs.MinAccountDeposit.Siacoins(),
+		},
 		{
 			Name:  "hostd_settings_ingress_limit",
 			Value: float64(hs.IngressLimit),
@@ -260,6 +264,26 @@ func (m Metrics) PrometheusMetric() []prometheus.Metric {
 			Name:  "hostd_metrics_storage_sector_cache_misses",
 			Value: float64(m.Storage.SectorCacheMisses),
 		},
+		{
+			Name:  "hostd_metrics_storage_sector_prefetches",
+			Value: float64(m.Storage.SectorPrefetches),
+		},
+		{
+			Name:  "hostd_metrics_storage_sector_prefetch_hits",
+			Value: float64(m.Storage.SectorPrefetchHits),
+		},
+		{
+			Name:  "hostd_metrics_storage_sector_read_aheads",
+			Value: float64(m.Storage.SectorReadAheads),
+		},
+		{
+			Name:  "hostd_metrics_storage_sector_read_ahead_hits",
+			Value: float64(m.Storage.SectorReadAheadHits),
+		},
+		{
+			Name:  "hostd_metrics_registry_rejections",
+			Value: float64(m.Registry.Rejections),
+		},
 		{
 			Name:  "hostd_metrics_data_rhp_ingress",
 			Value: float64(m.Data.RHP.Ingress),
@@ -319,6 +343,63 @@ func (t TPoolResp) PrometheusMetric() (metrics []prometheus.Metric) {
 	}
 }
 
+// PrometheusMetric returns Prometheus samples for the host's sector
+// migrations.
+func (m MigrationResp) PrometheusMetric() []prometheus.Metric {
+	return []prometheus.Metric{
+		{
+			Name:  "hostd_migrations_limit",
+			Value: float64(m.Limit),
+		},
+		{
+			Name:  "hostd_migrations_active",
+			Value: float64(m.Active),
+		},
+		{
+			Name:  "hostd_migrations_queued",
+			Value: float64(m.Queued),
+		},
+		{
+			Name:  "hostd_migrations_migrated",
+			Value: float64(m.Migrated),
+		},
+		{
+			Name:  "hostd_migrations_failed",
+			Value: float64(m.Failed),
+		},
+		{
+			Name:  "hostd_migrations_throughput",
+			Value: float64(m.Throughput),
+		},
+	}
+}
+
+// PrometheusMetric returns Prometheus samples for the host's sector cache.
+func (s SectorCacheResp) PrometheusMetric() []prometheus.Metric {
+	return []prometheus.Metric{
+		{
+			Name:  "hostd_sector_cache_sectors",
+			Value: float64(s.Sectors),
+		},
+		{
+			Name:  "hostd_sector_cache_capacity",
+			Value: float64(s.Capacity),
+		},
+		{
+			Name:  "hostd_sector_cache_memory_bytes",
+			Value: float64(s.MemoryBytes),
+		},
+		{
+			Name:  "hostd_sector_cache_max_memory_bytes",
+			Value: float64(s.MaxMemoryBytes),
+		},
+		{
+			Name:  "hostd_sector_cache_pressure_evictions",
+			Value: float64(s.PressureEvictions),
+		},
+	}
+}
+
 // PrometheusMetric returns Prometheus samples for the hosts volumes.
 func (v VolumeResp) PrometheusMetric() (metrics []prometheus.Metric) {
 	for _, volume := range v {
@@ -376,6 +457,16 @@ func (v VolumeResp) PrometheusMetric() (metrics []prometheus.Metric) {
 			Labels: labels,
 			Value:  float64(volume.SuccessfulWrites),
 		})
+		metrics = append(metrics, prometheus.Metric{
+			Name:   "hostd_volume_bytes_read",
+			Labels: labels,
+			Value:  float64(volume.BytesRead),
+		})
+		metrics = append(metrics, prometheus.Metric{
+			Name:   "hostd_volume_bytes_written",
+			Labels: labels,
+			Value:  float64(volume.BytesWritten),
+		})
 		metrics = append(metrics, prometheus.Metric{
 			Name:   "hostd_volume_status",
 			Labels: labels,
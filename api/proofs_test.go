@@ -0,0 +1,82 @@
+package api
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/contracts"
+)
+
+type stubContracts struct {
+	ContractManager
+	contract contracts.Contract
+}
+
+func (sc *stubContracts) Contract(id types.FileContractID) (contracts.Contract, error) {
+	if id != sc.contract.Revision.ParentID {
+		return contracts.Contract{}, contracts.ErrNotFound
+	}
+	return sc.contract, nil
+}
+
+func (sc *stubContracts) BuildSectorProof(id types.FileContractID, index uint64) (contracts.SectorProof, error) {
+	return contracts.SectorProof{
+		ContractID:  id,
+		NumSectors:  sc.contract.Revision.Filesize / rhp2.SectorSize,
+		SectorIndex: index,
+	}, nil
+}
+
+func TestSectorProofIndex(t *testing.T) {
+	var contract contracts.Contract
+	contract.Revision.ParentID = types.FileContractID{1}
+	contract.Revision.Filesize = 3 * rhp2.SectorSize
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: NewServer("test", types.PublicKey{}, ServerWithContractManager(&stubContracts{contract: contract}))}
+	go srv.Serve(l)
+	defer srv.Close()
+	client := NewClient("http://"+l.Addr().String(), "")
+
+	if proof, err := client.SectorProof(contract.Revision.ParentID, 2); err != nil {
+		t.Fatal(err)
+	} else if proof.SectorIndex != 2 {
+		t.Fatalf("expected sector index 2, got %v", proof.SectorIndex)
+	}
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get("http://" + l.Addr().String() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	// an index past the last sector is a bad request
+	status, body := get("/contracts/" + contract.Revision.ParentID.String() + "/proof/3")
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected status %v, got %v", http.StatusBadRequest, status)
+	} else if !strings.Contains(body, contracts.ErrSectorIndexOutOfRange.Error()) || !strings.Contains(body, "contract has 3 sectors") {
+		t.Fatalf("expected out of range error, got %q", body)
+	}
+
+	// an unknown contract is not found
+	status, _ = get("/contracts/" + types.FileContractID{2}.String() + "/proof/0")
+	if status != http.StatusNotFound {
+		t.Fatalf("expected status %v, got %v", http.StatusNotFound, status)
+	}
+}
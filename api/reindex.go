@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"go.sia.tech/jape"
+	"go.sia.tech/siad/modules"
+	"go.uber.org/zap"
+)
+
+// ErrReindexRunning is returned when a reindex is started while another is
+// still running.
+var ErrReindexRunning = errors.New("reindex already running")
+
+// reindexJob tracks the host's wallet and contract reindex.
+type reindexJob struct {
+	chain      ChainManager
+	contracts  ContractManager
+	wallet     Wallet
+	operations *operations
+	log        *zap.Logger
+
+	mu      sync.Mutex // protects running
+	running bool
+}
+
+// Start replays consensus changes to rebuild the wallet, then the contracts
+// starting after from. If a reindex is already running, ErrReindexRunning is
+// returned.
+func (rj *reindexJob) Start(from modules.ConsensusChangeID) error {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	if rj.running {
+		return ErrReindexRunning
+	}
+	rj.running = true
+
+	// the wallet and contracts each report progress up to the current height
+	height := rj.chain.TipState().Index.Height
+	ctx, opID := rj.operations.Start(OperationReindex, from.String())
+	rj.operations.SetProgress(opID, 0, 2*height)
+
+	go func() {
+		defer func() {
+			rj.operations.Done(opID)
+			rj.mu.Lock()
+			rj.running = false
+			rj.mu.Unlock()
+		}()
+
+		err := rj.wallet.Reindex(ctx, func(n uint64) {
+			rj.operations.SetProgress(opID, min(n, height), 2*height)
+		})
+		if err != nil {
+			rj.log.Error("wallet reindex failed", zap.Error(err))
+			return
+		}
+		err = rj.contracts.Reindex(ctx, from, func(n uint64) {
+			rj.operations.SetProgress(opID, height+min(n, height), 2*height)
+		})
+		if err != nil {
+			rj.log.Error("contract reindex failed", zap.Error(err))
+			return
+		}
+	}()
+	return nil
+}
+
+func (a *api) handlePOSTSystemReindex(c jape.Context) {
+	var req ReindexRequest
+	if err := c.Decode(&req); err != nil {
+		return
+	}
+
+	if err := a.reindex.Start(req.From); err != nil {
+		c.Error(err, http.StatusConflict)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"reflect"
 	"time"
 
+	rhp2 "go.sia.tech/core/rhp/v2"
+	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/alerts"
 	"go.sia.tech/hostd/host/contracts"
@@ -15,6 +17,7 @@ import (
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/rhp"
 	"go.sia.tech/hostd/wallet"
+	"go.sia.tech/siad/modules"
 )
 
 // JSON keys for host setting fields
@@ -28,6 +31,7 @@ const (
 	settingCollateral          = "collateral"
 	settingMaxCollateral       = "maxCollateral"
 	settingMaxAccountBalance   = "maxAccountBalance"
+	settingMinAccountDeposit   = "minAccountDeposit"
 	settingStoragePrice        = "storagePrice"
 	settingEgressPrice         = "egressPrice"
 	settingIngressPrice        = "ingressPrice"
@@ -105,9 +109,37 @@ type (
 		Errors JSONErrors `json:"errors"`
 	}
 
+	// PinContractRequest is the request body for the [PUT] /contracts/:id/pin
+	// endpoint.
+	PinContractRequest struct {
+		Pinned bool `json:"pinned"`
+	}
+
+	// QuarantineContractRequest is the request body for the [PUT]
+	// /contracts/:id/quarantine endpoint.
+	QuarantineContractRequest struct {
+		Quarantined bool   `json:"quarantined"`
+		Reason      string `json:"reason"`
+	}
+
+	// ContractTagsRequest is the request body for the [PUT]
+	// /contracts/:id/tags endpoint.
+	ContractTagsRequest struct {
+		Tags []string `json:"tags"`
+	}
+
 	// UpdateVolumeRequest is the request body for the [PUT] /volume/:id endpoint.
+	// ReconcileContractsRequest is the request body for the [POST]
+	// /contracts/reconcile endpoint.
+	ReconcileContractsRequest struct {
+		Repair bool `json:"repair"`
+	}
+
 	UpdateVolumeRequest struct {
 		ReadOnly bool `json:"readOnly"`
+		// ReplicaGroup sets the volume's replica group if not nil. An empty
+		// group disables replication.
+		ReplicaGroup *string `json:"replicaGroup,omitempty"`
 	}
 
 	// ResizeVolumeRequest is the request body for the [PUT] /volume/:id/resize endpoint.
@@ -128,6 +160,23 @@ type (
 		Spendable   types.Currency `json:"spendable"`
 		Confirmed   types.Currency `json:"confirmed"`
 		Unconfirmed types.Currency `json:"unconfirmed"`
+		// FeeReserve is the balance kept in the wallet to pay for
+		// transaction fees. Withdrawable is the spendable balance above it.
+		FeeReserve   types.Currency `json:"feeReserve"`
+		Withdrawable types.Currency `json:"withdrawable"`
+		// UTXOs is the number of spendable outputs. MinUTXOs is the
+		// minimum number of outputs the wallet maintains by splitting its
+		// balance.
+		UTXOs    int `json:"utxos"`
+		MinUTXOs int `json:"minUTXOs"`
+
+		// TransactionCount is the number of transactions in the wallet's
+		// history.
+		TransactionCount uint64 `json:"transactionCount"`
+		// TransactionRetention is the number of blocks transactions are
+		// kept in the wallet's history. 0 keeps all transactions.
+		TransactionRetention uint64                    `json:"transactionRetention"`
+		PrunedTransactions   wallet.PrunedTransactions `json:"prunedTransactions"`
 	}
 
 	// WalletSendSiacoinsRequest is the request body for the [POST] /wallet/send endpoint.
@@ -160,6 +209,32 @@ type (
 		Path string `json:"path"`
 	}
 
+	// SettingsPreviewResponse is the response body for the [POST]
+	// /settings/preview endpoint.
+	SettingsPreviewResponse struct {
+		settings.SettingsPreview
+		// HostSettings and PriceTable are the RHP2 settings and RHP3 price
+		// table that would be advertised to renters. They are omitted if
+		// the host's RHP handlers are not available to the API.
+		HostSettings *rhp2.HostSettings   `json:"hostSettings,omitempty"`
+		PriceTable   *rhp3.HostPriceTable `json:"priceTable,omitempty"`
+	}
+
+	// A BackupRequest is the request body for the [POST] /system/backup
+	// endpoint.
+	BackupRequest struct {
+		Path string `json:"path"`
+	}
+
+	// A ReindexRequest is the request body for the [POST] /system/reindex
+	// endpoint.
+	ReindexRequest struct {
+		// From is the consensus change to replay contracts from. The zero
+		// value replays the entire chain. The wallet is always replayed from
+		// the beginning.
+		From modules.ConsensusChangeID `json:"from"`
+	}
+
 	// VerifySectorResponse is the response body for the [GET] /sectors/:root/verify endpoint.
 	VerifySectorResponse struct {
 		storage.SectorReference
@@ -178,6 +253,13 @@ type (
 	// VolumeResp is the response body for the [GET] /volumes endpoint
 	VolumeResp []VolumeMeta
 
+	// MigrationResp is the response body for the [GET] /migrations endpoint
+	MigrationResp storage.MigrationStats
+
+	// SectorCacheResp is the response body for the [GET] /storage/cache
+	// endpoint
+	SectorCacheResp storage.SectorCacheStats
+
 	// AlertResp is the response body for the [GET] /alerts endpoint
 	AlertResp []alerts.Alert
 
@@ -291,6 +373,13 @@ func SetMaxAccountBalance(value types.Currency) Setting {
 	}
 }
 
+// SetMinAccountDeposit sets the MinAccountDeposit
+func SetMinAccountDeposit(value types.Currency) Setting {
+	return func(v map[string]any) {
+		v[settingMinAccountDeposit] = value
+	}
+}
+
 // SetMinStoragePrice sets the MinStoragePrice in bytes/block
 func SetMinStoragePrice(price types.Currency) Setting {
 	return func(v map[string]any) {
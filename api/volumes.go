@@ -5,9 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 
-	rhp2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/jape"
@@ -15,38 +15,53 @@ import (
 
 type (
 	volumeJobs struct {
-		volumes VolumeManager
+		volumes    VolumeManager
+		operations *operations
 
 		mu   sync.Mutex // protects jobs
-		jobs map[int64]context.CancelFunc
+		jobs map[int64]uint64
 	}
 )
 
+// track removes the volume's job from the registry once the operation
+// completes or is cancelled.
+func (vj *volumeJobs) track(ctx context.Context, volumeID int64, opID uint64, complete <-chan error) {
+	select {
+	case <-ctx.Done():
+	case <-complete:
+	}
+
+	vj.operations.Done(opID)
+	vj.mu.Lock()
+	defer vj.mu.Unlock()
+	if vj.jobs[volumeID] == opID {
+		delete(vj.jobs, volumeID)
+	}
+}
+
+// withProgress returns a copy of ctx that reports the volume operation's
+// progress to the operations registry.
+func (vj *volumeJobs) withProgress(ctx context.Context, opID uint64) context.Context {
+	return storage.WithProgress(ctx, func(progress, total uint64) {
+		vj.operations.SetProgress(opID, progress, total)
+	})
+}
+
 func (vj *volumeJobs) AddVolume(path string, maxSectors uint64) (storage.Volume, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, opID := vj.operations.Start(OperationAddVolume, path)
+	ctx = vj.withProgress(ctx, opID)
 	complete := make(chan error, 1)
 	volume, err := vj.volumes.AddVolume(ctx, path, maxSectors, complete)
 	if err != nil {
-		cancel()
+		vj.operations.Done(opID)
 		return storage.Volume{}, err
 	}
 
 	vj.mu.Lock()
 	defer vj.mu.Unlock()
-	vj.jobs[volume.ID] = cancel
-
-	go func() {
-		defer cancel()
-
-		select {
-		case <-ctx.Done():
-		case <-complete:
-		}
-
-		vj.mu.Lock()
-		defer vj.mu.Unlock()
-		delete(vj.jobs, volume.ID)
-	}()
+	vj.jobs[volume.ID] = opID
+	vj.operations.SetTarget(opID, strconv.FormatInt(volume.ID, 10))
+	go vj.track(ctx, volume.ID, opID, complete)
 	return volume, nil
 }
 
@@ -57,27 +72,17 @@ func (vj *volumeJobs) RemoveVolume(id int64, force bool) error {
 		return errors.New("volume is busy")
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, opID := vj.operations.Start(OperationRemoveVolume, strconv.FormatInt(id, 10))
+	ctx = vj.withProgress(ctx, opID)
 	complete := make(chan error, 1)
 	err := vj.volumes.RemoveVolume(ctx, id, force, complete)
 	if err != nil {
-		cancel()
+		vj.operations.Done(opID)
 		return err
 	}
 
-	vj.jobs[id] = cancel
-	go func() {
-		defer cancel()
-
-		select {
-		case <-ctx.Done():
-		case <-complete:
-		}
-
-		vj.mu.Lock()
-		defer vj.mu.Unlock()
-		delete(vj.jobs, id)
-	}()
+	vj.jobs[id] = opID
+	go vj.track(ctx, id, opID, complete)
 	return nil
 }
 
@@ -88,40 +93,29 @@ func (vj *volumeJobs) ResizeVolume(id int64, newSize uint64) error {
 		return errors.New("volume is busy")
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, opID := vj.operations.Start(OperationResizeVolume, strconv.FormatInt(id, 10))
+	ctx = vj.withProgress(ctx, opID)
 	complete := make(chan error, 1)
 	err := vj.volumes.ResizeVolume(ctx, id, newSize, complete)
 	if err != nil {
-		cancel()
+		vj.operations.Done(opID)
 		return err
 	}
 
-	vj.jobs[id] = cancel
-	go func() {
-		defer cancel()
-
-		select {
-		case <-ctx.Done():
-		case <-complete:
-		}
-
-		vj.mu.Lock()
-		defer vj.mu.Unlock()
-		delete(vj.jobs, id)
-	}()
+	vj.jobs[id] = opID
+	go vj.track(ctx, id, opID, complete)
 	return nil
 }
 
 func (vj *volumeJobs) Cancel(id int64) error {
 	vj.mu.Lock()
 	defer vj.mu.Unlock()
-	cancel, exists := vj.jobs[id]
+	opID, exists := vj.jobs[id]
 	if !exists {
 		return fmt.Errorf("no job for volume %d", id)
 	}
-	cancel()
 	delete(vj.jobs, id)
-	return nil
+	return vj.operations.CancelOperation(opID)
 }
 
 func (a *api) handleGETVolumes(c jape.Context) {
@@ -224,11 +218,8 @@ func (a *api) handleGETVerifySector(jc jape.Context) {
 	}
 
 	// try to read the sector data and verify the root
-	data, err := a.volumes.Read(root)
-	if err != nil {
+	if err := a.volumes.VerifySector(root); err != nil {
 		resp.Error = err.Error()
-	} else if calc := rhp2.SectorRoot(data); calc != root {
-		resp.Error = fmt.Sprintf("sector is corrupt: expected root %q, got %q", root, calc)
 	}
 	jc.Encode(resp)
 }
@@ -3,8 +3,9 @@
 package main
 
 const (
-	apiPasswordEnvVariable = "HOSTD_API_PASSWORD"
-	walletSeedEnvVariable  = "HOSTD_SEED"
+	apiPasswordEnvVariable      = "HOSTD_API_PASSWORD"
+	walletSeedEnvVariable       = "HOSTD_SEED"
+	volumePassphraseEnvVariable = "HOSTD_VOLUME_PASSPHRASE"
 	// logPathEnvVariable overrides the path of the log file.
 	// Deprecated: use logFileEnvVar instead.
 	logPathEnvVariable = "HOSTD_LOG_PATH"
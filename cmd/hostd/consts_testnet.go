@@ -3,8 +3,9 @@
 package main
 
 const (
-	apiPasswordEnvVariable = "HOSTD_ZEN_API_PASSWORD"
-	walletSeedEnvVariable  = "HOSTD_ZEN_SEED"
+	apiPasswordEnvVariable      = "HOSTD_ZEN_API_PASSWORD"
+	walletSeedEnvVariable       = "HOSTD_ZEN_SEED"
+	volumePassphraseEnvVariable = "HOSTD_ZEN_VOLUME_PASSPHRASE"
 	// logPathEnvVariable overrides the path of the log file.
 	// Deprecated: use logFileEnvVar instead.
 	logPathEnvVariable = "HOSTD_ZEN_LOG_PATH"
@@ -47,6 +47,11 @@ var (
 		Consensus: config.Consensus{
 			GatewayAddress: defaultGatewayAddr,
 			Bootstrap:      true,
+			SyncTolerance:  6,
+		},
+		Contracts: config.Contracts{
+			ProofFailureThreshold: 3,
+			ProofFailureWindow:    24 * time.Hour,
 		},
 		RHP2: config.RHP2{
 			Address: defaultRHP2Addr,
@@ -55,6 +60,9 @@ var (
 			TCPAddress:       defaultRHP3TCPAddr,
 			WebSocketAddress: defaultRHP3WSAddr,
 		},
+		Storage: config.Storage{
+			EncryptionPassphrase: os.Getenv(volumePassphraseEnvVariable),
+		},
 		Log: config.Log{
 			Path:  os.Getenv(logPathEnvVariable), // deprecated. included for compatibility.
 			Level: "info",
@@ -68,6 +76,10 @@ var (
 				Format:     "human",
 				EnableANSI: runtime.GOOS != "windows",
 			},
+			RHPSampling: config.LogSampling{
+				Interval: time.Minute,
+				First:    10,
+			},
 		},
 	}
 
@@ -386,9 +398,12 @@ func main() {
 		api.ServerWithAccountManager(node.accounts),
 		api.ServerWithVolumeManager(node.storage),
 		api.ServerWithRHPSessionReporter(node.sessions),
+		api.ServerWithRHP2Settings(node.rhp2),
+		api.ServerWithRHP3PriceTables(node.rhp3),
 		api.ServerWithMetricManager(node.metrics),
 		api.ServerWithSettings(node.settings),
 		api.ServerWithWallet(node.w),
+		api.ServerWithBackups(node.store),
 		api.ServerWithLogger(log.Named("api")),
 	}
 
@@ -422,7 +437,7 @@ func main() {
 		}
 	}()
 
-	log.Info("hostd started", zap.String("hostKey", hostKey.PublicKey().String()), zap.String("api", apiListener.Addr().String()), zap.String("p2p", string(node.g.Address())), zap.String("rhp2", node.rhp2.LocalAddr()), zap.String("rhp3", node.rhp3.LocalAddr()))
+	log.Info("hostd started", zap.String("hostKey", hostKey.PublicKey().String()), zap.String("api", apiListener.Addr().String()), zap.String("p2p", string(node.g.Address())), zap.Strings("rhp2", node.rhp2.LocalAddr()), zap.Strings("rhp3", node.rhp3.LocalAddr()))
 	if runtime.GOARCH == "amd64" && !cpu.X86.HasAVX2 {
 		log.Warn("hostd is running on a system without AVX2 support, performance may be degraded")
 	}
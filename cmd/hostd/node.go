@@ -2,19 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/config"
 	"go.sia.tech/hostd/host/accounts"
 	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/metrics"
 	"go.sia.tech/hostd/host/registry"
 	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/host/settings/autoprice"
 	"go.sia.tech/hostd/host/settings/pin"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/internal/chain"
@@ -30,6 +34,7 @@ import (
 	"go.sia.tech/siad/modules/gateway"
 	"go.sia.tech/siad/modules/transactionpool"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type node struct {
@@ -42,6 +47,7 @@ type node struct {
 	store *sqlite.Store
 
 	metrics   *metrics.MetricManager
+	uptime    *metrics.UptimeTracker
 	settings  *settings.ConfigManager
 	pinned    *pin.Manager
 	accounts  *accounts.AccountManager
@@ -55,7 +61,13 @@ type node struct {
 	rhp3     *rhp3.SessionHandler
 }
 
+// UptimeStats returns the host's availability over the window ending now.
+func (n *node) UptimeStats(window time.Duration) (metrics.UptimeStats, error) {
+	return n.uptime.Stats(window)
+}
+
 func (n *node) Close() {
+	n.uptime.Close()
 	n.rhp3.Close()
 	n.rhp2.Close()
 	n.data.Close()
@@ -67,11 +79,96 @@ func (n *node) Close() {
 	n.cm.Close()
 	n.g.Close()
 	n.wh.Close()
+	n.a.Close()
 	n.store.Close()
 }
 
-func startRHP2(l net.Listener, hostKey types.PrivateKey, rhp3Addr string, cs rhp2.ChainManager, tp rhp2.TransactionPool, w rhp2.Wallet, cm rhp2.ContractManager, sr rhp2.SettingsReporter, sm rhp2.StorageManager, monitor rhp.DataMonitor, sessions *rhp.SessionReporter, log *zap.Logger) (*rhp2.SessionHandler, error) {
-	rhp2, err := rhp2.NewSessionHandler(l, hostKey, rhp3Addr, cs, tp, w, cm, sr, sm, monitor, sessions, log)
+// newAutoPriceManager creates a manager that adjusts the storage price toward
+// the configured target utilization.
+func newAutoPriceManager(apc config.AutoPrice, sr *settings.ConfigManager, sm *storage.VolumeManager, am *alerts.Manager, log *zap.Logger) (*autoprice.Manager, error) {
+	opts := []autoprice.Option{
+		autoprice.WithSettings(sr),
+		autoprice.WithStorage(sm),
+		autoprice.WithAlerts(am),
+		autoprice.WithLogger(log),
+		autoprice.WithFrequency(apc.Interval),
+	}
+	if apc.TargetUtilization != 0 {
+		opts = append(opts, autoprice.WithTarget(apc.TargetUtilization))
+	}
+	if apc.Tolerance != 0 {
+		opts = append(opts, autoprice.WithTolerance(apc.Tolerance))
+	}
+	if apc.Step != 0 {
+		opts = append(opts, autoprice.WithStep(apc.Step))
+	}
+
+	// convert the bounds from SC/TB/month to H/byte/block
+	minPrice, maxPrice := types.ZeroCurrency, types.MaxCurrency
+	if apc.MinPrice != "" {
+		v, err := types.ParseCurrency(apc.MinPrice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse min price: %w", err)
+		}
+		minPrice = v.Div64(4320).Div64(1e12)
+	}
+	if apc.MaxPrice != "" {
+		v, err := types.ParseCurrency(apc.MaxPrice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse max price: %w", err)
+		}
+		maxPrice = v.Div64(4320).Div64(1e12)
+	}
+	opts = append(opts, autoprice.WithBounds(minPrice, maxPrice))
+	return autoprice.NewManager(opts...)
+}
+
+// alertSinkOptions returns the options to deliver alerts to the configured
+// sinks.
+func alertSinkOptions(sinks []config.AlertSink) ([]alerts.Option, error) {
+	var opts []alerts.Option
+	for i, sc := range sinks {
+		minSeverity := alerts.SeverityWarning
+		if sc.MinSeverity != "" {
+			var err error
+			minSeverity, err = alerts.ParseSeverity(sc.MinSeverity)
+			if err != nil {
+				return nil, fmt.Errorf("alert sink %d: %w", i, err)
+			}
+		}
+
+		var sink alerts.AlertSink
+		switch sc.Type {
+		case "webhook", "slack":
+			if sc.URL == "" {
+				return nil, fmt.Errorf("alert sink %d: url is required", i)
+			} else if sc.Type == "slack" {
+				sink = alerts.NewSlackSink(sc.URL)
+			} else {
+				sink = alerts.NewWebhookSink(sc.URL)
+			}
+		case "email":
+			es, err := alerts.NewEmailSink(alerts.EmailConfig{
+				Address:  sc.SMTPAddress,
+				Username: sc.SMTPUsername,
+				Password: sc.SMTPPassword,
+				From:     sc.From,
+				To:       sc.To,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("alert sink %d: %w", i, err)
+			}
+			sink = es
+		default:
+			return nil, fmt.Errorf("alert sink %d: unrecognized type %q", i, sc.Type)
+		}
+		opts = append(opts, alerts.WithSink(sink, minSeverity))
+	}
+	return opts, nil
+}
+
+func startRHP2(l []net.Listener, hostKey types.PrivateKey, rhp3Addr string, cs rhp2.ChainManager, tp rhp2.TransactionPool, w rhp2.Wallet, cm rhp2.ContractManager, sr rhp2.SettingsReporter, sm rhp2.StorageManager, monitor rhp.DataMonitor, sessions *rhp.SessionReporter, log *zap.Logger, opts ...rhp2.SessionHandlerOption) (*rhp2.SessionHandler, error) {
+	rhp2, err := rhp2.NewSessionHandler(l, hostKey, rhp3Addr, cs, tp, w, cm, sr, sm, monitor, sessions, log, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +176,8 @@ func startRHP2(l net.Listener, hostKey types.PrivateKey, rhp3Addr string, cs rhp
 	return rhp2, nil
 }
 
-func startRHP3(l net.Listener, hostKey types.PrivateKey, cs rhp3.ChainManager, tp rhp3.TransactionPool, w rhp3.Wallet, am rhp3.AccountManager, cm rhp3.ContractManager, rm rhp3.RegistryManager, sr rhp3.SettingsReporter, sm rhp3.StorageManager, monitor rhp.DataMonitor, sessions *rhp.SessionReporter, log *zap.Logger) (*rhp3.SessionHandler, error) {
-	rhp3, err := rhp3.NewSessionHandler(l, hostKey, cs, tp, w, am, cm, rm, sm, sr, monitor, sessions, log)
+func startRHP3(l []net.Listener, hostKey types.PrivateKey, cs rhp3.ChainManager, tp rhp3.TransactionPool, w rhp3.Wallet, am rhp3.AccountManager, cm rhp3.ContractManager, rm rhp3.RegistryManager, sr rhp3.SettingsReporter, sm rhp3.StorageManager, monitor rhp.DataMonitor, sessions *rhp.SessionReporter, log *zap.Logger, opts ...rhp3.SessionHandlerOption) (*rhp3.SessionHandler, error) {
+	rhp3, err := rhp3.NewSessionHandler(l, hostKey, cs, tp, w, am, cm, rm, sm, sr, monitor, sessions, log, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +185,23 @@ func startRHP3(l net.Listener, hostKey types.PrivateKey, cs rhp3.ChainManager, t
 	return rhp3, nil
 }
 
+// listenAll listens on the primary address and each additional address. If
+// any address fails to bind, the listeners that were opened are closed.
+func listenAll(primary string, additional []string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, 1+len(additional))
+	for _, addr := range append([]string{primary}, additional...) {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %q: %w", addr, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
 func newNode(ctx context.Context, walletKey types.PrivateKey, ex *explorer.Explorer, logger *zap.Logger) (*node, types.PrivateKey, error) {
 	gatewayDir := filepath.Join(cfg.Directory, "gateway")
 	if err := os.MkdirAll(gatewayDir, 0700); err != nil {
@@ -130,7 +244,6 @@ func newNode(ctx context.Context, walletKey types.PrivateKey, ex *explorer.Explo
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create tpool: %w", err)
 	}
-	tp := chain.NewTPool(stp)
 
 	db, err := sqlite.OpenDatabase(filepath.Join(cfg.Directory, "hostd.db"), logger.Named("sqlite"))
 	if err != nil {
@@ -140,31 +253,51 @@ func newNode(ctx context.Context, walletKey types.PrivateKey, ex *explorer.Explo
 	// load the host identity
 	hostKey := db.HostKey()
 
-	cm, err := chain.NewManager(cs)
+	cm, err := chain.NewManager(cs, chain.WithSyncTolerance(cfg.Consensus.SyncTolerance))
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create chain manager: %w", err)
 	}
 
-	w, err := wallet.NewSingleAddressWallet(walletKey, cm, tp, db, logger.Named("wallet"))
-	if err != nil {
-		return nil, types.PrivateKey{}, fmt.Errorf("failed to create wallet: %w", err)
-	}
-
 	webhookReporter, err := webhooks.NewManager(db, logger.Named("webhooks"))
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create webhook reporter: %w", err)
 	}
 
-	rhp2Listener, err := net.Listen("tcp", cfg.RHP2.Address)
+	rhp2Listeners, err := listenAll(cfg.RHP2.Address, cfg.RHP2.AdditionalAddresses)
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to listen on rhp2 addr: %w", err)
 	}
 
-	rhp3Listener, err := net.Listen("tcp", cfg.RHP3.TCPAddress)
+	rhp3Listeners, err := listenAll(cfg.RHP3.TCPAddress, cfg.RHP3.AdditionalTCPAddresses)
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to listen on rhp3 addr: %w", err)
 	}
 
+	// connections forwarded by the Tor daemon are received on dedicated
+	// listeners
+	var torRHP2, torRHP3 []net.Listener
+	if cfg.Tor.RHP2Address != "" {
+		l, err := net.Listen("tcp", cfg.Tor.RHP2Address)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to listen on tor rhp2 addr: %w", err)
+		}
+		torRHP2 = append(torRHP2, l)
+		rhp2Listeners = append(rhp2Listeners, l)
+	}
+	if cfg.Tor.RHP3Address != "" {
+		l, err := net.Listen("tcp", cfg.Tor.RHP3Address)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to listen on tor rhp3 addr: %w", err)
+		}
+		torRHP3 = append(torRHP3, l)
+		rhp3Listeners = append(rhp3Listeners, l)
+	}
+	torPolicy := settings.NetworkPolicy{
+		RejectContracts: cfg.Tor.RejectContracts,
+		PriceMultiplier: cfg.Tor.PriceMultiplier,
+		NetAddress:      cfg.Tor.NetAddress,
+	}
+
 	_, rhp2Port, err := net.SplitHostPort(cfg.RHP2.Address)
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to parse rhp2 addr: %w", err)
@@ -172,25 +305,96 @@ func newNode(ctx context.Context, walletKey types.PrivateKey, ex *explorer.Explo
 	discoveredAddr := net.JoinHostPort(g.Address().Host(), rhp2Port)
 	logger.Debug("discovered address", zap.String("addr", discoveredAddr))
 
-	am := alerts.NewManager(webhookReporter, logger.Named("alerts"))
-	sr, err := settings.NewConfigManager(settings.WithHostKey(hostKey),
+	alertOpts, err := alertSinkOptions(cfg.Alerts.Sinks)
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to configure alert sinks: %w", err)
+	}
+	am := alerts.NewManager(webhookReporter, logger.Named("alerts"), alertOpts...)
+	tp := chain.NewTPool(stp, chain.WithBroadcastAlerts(am))
+
+	walletOpts := []wallet.Option{
+		wallet.WithAlerts(am),
+		wallet.WithTransactionRetention(cfg.Wallet.TransactionRetention),
+		wallet.WithMinUTXOs(cfg.Wallet.MinUTXOs),
+	}
+	if cfg.Wallet.FeeReserve != "" {
+		reserve, err := types.ParseCurrency(cfg.Wallet.FeeReserve)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to parse fee reserve: %w", err)
+		}
+		walletOpts = append(walletOpts, wallet.WithFeeReserve(reserve))
+	}
+	if cfg.Wallet.WithdrawAddress != "" {
+		var addr types.Address
+		if err := addr.UnmarshalText([]byte(cfg.Wallet.WithdrawAddress)); err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to parse withdraw address: %w", err)
+		} else if cfg.Wallet.WithdrawInterval <= 0 {
+			return nil, types.PrivateKey{}, errors.New("withdraw interval must be positive")
+		}
+		walletOpts = append(walletOpts, wallet.WithScheduledWithdrawal(addr, cfg.Wallet.WithdrawInterval))
+	}
+	w, err := wallet.NewSingleAddressWallet(walletKey, cm, tp, db, logger.Named("wallet"), walletOpts...)
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to create wallet: %w", err)
+	}
+
+	var announcementFee types.Currency
+	if cfg.Announcement.Fee != "" {
+		announcementFee, err = types.ParseCurrency(cfg.Announcement.Fee)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to parse announcement fee: %w", err)
+		}
+	}
+
+	var maxRefreshFee types.Currency
+	if cfg.Announcement.MaxRefreshFee != "" {
+		maxRefreshFee, err = types.ParseCurrency(cfg.Announcement.MaxRefreshFee)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to parse max refresh fee: %w", err)
+		}
+	}
+
+	settingsOpts := []settings.Option{
+		settings.WithHostKey(hostKey),
 		settings.WithStore(db),
 		settings.WithChainManager(cm),
 		settings.WithTransactionPool(tp),
 		settings.WithWallet(w),
 		settings.WithAlertManager(am),
-		settings.WithLog(logger.Named("settings")))
+		settings.WithAnnouncementFee(announcementFee),
+		settings.WithMaxRefreshFee(maxRefreshFee),
+		settings.WithLog(logger.Named("settings")),
+	}
+	if cfg.Announcement.RefreshInterval > 0 {
+		settingsOpts = append(settingsOpts, settings.WithAnnounceInterval(cfg.Announcement.RefreshInterval))
+	}
+	if cfg.Tor.NetAddress != "" {
+		settingsOpts = append(settingsOpts, settings.WithNetworkAnnouncements(cfg.Tor.NetAddress))
+	}
+	sr, err := settings.NewConfigManager(settingsOpts...)
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create settings manager: %w", err)
 	}
+	// pause contract formation while the database cannot be written
+	db.OnDiskFull(sr.SetDatabaseFull)
+	if db.DiskFull() {
+		sr.SetDatabaseFull(true)
+	}
 
 	var pm *pin.Manager
 	if !cfg.Explorer.Disable {
-		pm, err = pin.NewManager(
+		pinOpts := []pin.Option{
 			pin.WithStore(db),
 			pin.WithSettings(sr),
 			pin.WithExchangeRateRetriever(ex),
-			pin.WithLogger(logger.Named("pin")))
+			pin.WithLogger(logger.Named("pin")),
+		}
+		// automatic storage pricing and a pinned storage price would
+		// overwrite each other's prices
+		if cfg.AutoPrice.Interval > 0 {
+			pinOpts = append(pinOpts, pin.WithAutoStoragePrice())
+		}
+		pm, err = pin.NewManager(pinOpts...)
 		if err != nil {
 			return nil, types.PrivateKey{}, fmt.Errorf("failed to create pin manager: %w", err)
 		}
@@ -199,30 +403,170 @@ func newNode(ctx context.Context, walletKey types.PrivateKey, ex *explorer.Explo
 
 	accountManager := accounts.NewManager(db, sr)
 
-	sm, err := storage.NewVolumeManager(db, am, cm, logger.Named("volumes"), sr.Settings().SectorCacheSize)
+	writeSLO := storage.LatencySLO(cfg.Storage.WriteLatencySLO)
+	if writeSLO.Threshold > 0 && (writeSLO.Percentile <= 0 || writeSLO.Percentile > 1) {
+		return nil, types.PrivateKey{}, errors.New("write latency SLO percentile must be between 0 and 1")
+	}
+	sm, err := storage.NewVolumeManager(db, am, cm, logger.Named("volumes"), sr.Settings().SectorCacheSize, storage.WithMaxOpenVolumes(cfg.Storage.MaxOpenVolumes), storage.WithSectorChecksums(cfg.Storage.SectorChecksums), storage.WithPrefetchDepth(cfg.Storage.PrefetchDepth), storage.WithReadAhead(cfg.Storage.ReadAheadSectors), storage.WithMaxConcurrentMigrations(cfg.Storage.MaxConcurrentMigrations), storage.WithEncryptionPassphrase(cfg.Storage.EncryptionPassphrase), storage.WithMaxVolumes(cfg.Storage.MaxVolumes), storage.WithWriteLatencySLO(writeSLO), storage.WithWriteTimeout(cfg.Storage.WriteTimeout), storage.WithSelfAudit(storage.SelfAuditConfig(cfg.Storage.SelfAudit)), storage.WithExpiredSectors(storage.ExpiredSectorConfig(cfg.Storage.ExpiredSectors)), storage.WithAutoGrow(storage.AutoGrowConfig(cfg.Storage.AutoGrow)), storage.WithCacheMemory(storage.CacheMemoryConfig(cfg.Storage.SectorCache)), storage.WithRelink(storage.RelinkConfig(cfg.Storage.Relink)), storage.WithPacking(storage.PackingConfig(cfg.Storage.Packing)))
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create storage manager: %w", err)
 	}
+	sr.Subscribe(func(_, s settings.Settings, _ settings.ChangedFields) {
+		sm.ResizeCache(s.SectorCacheSize)
+	}, "sectorCacheSize")
+	// scale the advertised collateral with the host's storage utilization
+	go sr.RunDynamicCollateral(ctx, sm)
 
-	contractManager, err := contracts.NewManager(db, am, sm, cm, tp, w, logger.Named("contracts"))
+	if cfg.AutoPrice.Interval > 0 {
+		apm, err := newAutoPriceManager(cfg.AutoPrice, sr, sm, am, logger.Named("autoprice"))
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("failed to create storage price manager: %w", err)
+		}
+		go apm.Run(ctx)
+	}
+
+	var verifyLevel storage.VerifyLevel
+	if err := verifyLevel.UnmarshalText([]byte(cfg.Storage.StartupVerification)); err != nil {
+		return nil, types.PrivateKey{}, err
+	}
+	var abortSeverity alerts.Severity
+	if cfg.Storage.StartupAbortSeverity != "" {
+		abortSeverity, err = alerts.ParseSeverity(cfg.Storage.StartupAbortSeverity)
+		if err != nil {
+			return nil, types.PrivateKey{}, fmt.Errorf("invalid startup abort severity: %w", err)
+		}
+	}
+	issues, err := sm.VerifyVolumes(ctx, verifyLevel)
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to verify volumes: %w", err)
+	} else if cfg.Storage.StartupAbortSeverity != "" {
+		for _, issue := range issues {
+			if issue.Severity >= abortSeverity {
+				return nil, types.PrivateKey{}, fmt.Errorf("volume %d failed verification: %s", issue.VolumeID, issue.Message)
+			}
+		}
+	}
+
+	var proofStrategy contracts.ProofStrategy
+	switch cfg.Contracts.ProofStrategy {
+	case "", "immediate":
+		proofStrategy = contracts.ProofStrategyImmediate
+	case "spread":
+		proofStrategy = contracts.ProofStrategySpread
+	default:
+		return nil, types.PrivateKey{}, fmt.Errorf("unknown proof strategy %q", cfg.Contracts.ProofStrategy)
+	}
+
+	var doubleSpendPolicy contracts.DoubleSpendPolicy
+	switch cfg.Contracts.DoubleSpendPolicy {
+	case "", "reject":
+		doubleSpendPolicy = contracts.DoubleSpendReject
+	case "alert":
+		doubleSpendPolicy = contracts.DoubleSpendAlert
+	default:
+		return nil, types.PrivateKey{}, fmt.Errorf("unknown double spend policy %q", cfg.Contracts.DoubleSpendPolicy)
+	}
+
+	if cfg.Contracts.ProofFailureThreshold > 0 && cfg.Contracts.ProofFailureWindow <= 0 {
+		return nil, types.PrivateKey{}, errors.New("proof failure window must be positive")
+	} else if cfg.Contracts.ProofBreakerThreshold > 0 && cfg.Contracts.ProofBreakerWindow <= 0 {
+		return nil, types.PrivateKey{}, errors.New("proof breaker window must be positive")
+	} else if cfg.Contracts.MaxProofFeeRatio < 0 {
+		return nil, types.PrivateKey{}, errors.New("max proof fee ratio must not be negative")
+	}
+
+	contractManager, err := contracts.NewManager(db, am, sm, cm, tp, w, logger.Named("contracts"), contracts.WithProofStrategy(proofStrategy), contracts.WithProofFailureAlert(cfg.Contracts.ProofFailureThreshold, cfg.Contracts.ProofFailureWindow), contracts.WithCollateralRelease(cfg.Contracts.ReleaseCollateral), contracts.WithMaxProofFeeRatio(cfg.Contracts.MaxProofFeeRatio), contracts.WithDoubleSpendPolicy(doubleSpendPolicy), contracts.WithProofBreaker(cfg.Contracts.ProofBreakerThreshold, cfg.Contracts.ProofBreakerWindow), contracts.WithPruneRetention(cfg.Contracts.PruneRetention))
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to create contract manager: %w", err)
 	}
-	registryManager := registry.NewManager(hostKey, db, logger.Named("registry"))
+	// pause contract formation while proofs are failing
+	contractManager.OnProofBreaker(sr.SetProofBreakerTripped)
+	// alert the contracts affected by volumes that fail to open, degrade, or
+	// lose sectors when force removed
+	err = sm.OnVolumeUnavailable(func(volumeID int64, localPath string) {
+		if err := contractManager.AlertContractsOnVolume(volumeID, localPath); err != nil {
+			logger.Error("failed to alert contracts on unavailable volume", zap.Int64("volumeID", volumeID), zap.Error(err))
+		}
+	})
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to subscribe to unavailable volumes: %w", err)
+	}
+	registryManager := registry.NewManager(hostKey, db, logger.Named("registry"), registry.WithMaxValueSize(cfg.RHP3.MaxRegistryValueSize))
 
 	sessions := rhp.NewSessionReporter()
 
 	dm := rhp.NewDataRecorder(db, logger.Named("data"))
-	rhp2, err := startRHP2(rhp2Listener, hostKey, rhp3Listener.Addr().String(), cm, tp, w, contractManager, sr, sm, dm, sessions, logger.Named("rhp2"))
+
+	rhpLogger := logger
+	if cfg.Log.RHPSampling.Enabled {
+		if cfg.Log.RHPSampling.Interval <= 0 {
+			return nil, types.PrivateKey{}, errors.New("rhp log sampling interval must be positive")
+		}
+		rhpLogger = logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return rhp.NewSampledCore(core, cfg.Log.RHPSampling.Interval, cfg.Log.RHPSampling.First)
+		}))
+	}
+
+	mm, err := metrics.NewManager(db, logger.Named("metrics"))
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to create metrics manager: %w", err)
+	}
+
+	rhp2Opts := []rhp2.SessionHandlerOption{rhp2.WithContractLatencyRecorder(mm)}
+	if cfg.RHP2.HandshakeTimeout > 0 {
+		rhp2Opts = append(rhp2Opts, rhp2.WithHandshakeTimeout(cfg.RHP2.HandshakeTimeout))
+	}
+	if cfg.RHP2.MinStorageHeadroom > 0 {
+		rhp2Opts = append(rhp2Opts, rhp2.WithMinStorageHeadroom(cfg.RHP2.MinStorageHeadroom))
+	}
+	if cfg.Contracts.MinRenterSuccessfulContracts > 0 || cfg.Contracts.EstablishedRenterContracts > 0 || cfg.Contracts.NewRenterPriceMultiplier > 0 {
+		rhp2Opts = append(rhp2Opts, rhp2.WithRenterPolicy(settings.RenterPolicy{
+			MinSuccessfulContracts:   cfg.Contracts.MinRenterSuccessfulContracts,
+			EstablishedContracts:     cfg.Contracts.EstablishedRenterContracts,
+			NewRenterPriceMultiplier: cfg.Contracts.NewRenterPriceMultiplier,
+		}))
+	}
+	if len(torRHP2) > 0 {
+		rhp2Opts = append(rhp2Opts, rhp2.WithNetworkTagger(rhp.ListenerTagger(rhp.NetworkTor, torRHP2...)), rhp2.WithNetworkPolicy(rhp.NetworkTor, torPolicy))
+	}
+	rhp2, err := startRHP2(rhp2Listeners, hostKey, rhp3Listeners[0].Addr().String(), cm, tp, w, contractManager, sr, sm, dm, sessions, rhpLogger.Named("rhp2"), rhp2Opts...)
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to start rhp2: %w", err)
 	}
 
-	rhp3, err := startRHP3(rhp3Listener, hostKey, cm, tp, w, accountManager, contractManager, registryManager, sr, sm, dm, sessions, logger.Named("rhp3"))
+	rhp3Opts := []rhp3.SessionHandlerOption{rhp3.WithContractLatencyRecorder(mm)}
+	if cfg.RHP3.HandshakeTimeout > 0 {
+		rhp3Opts = append(rhp3Opts, rhp3.WithHandshakeTimeout(cfg.RHP3.HandshakeTimeout))
+	}
+	if cfg.RHP2.MinStorageHeadroom > 0 {
+		rhp3Opts = append(rhp3Opts, rhp3.WithMinStorageHeadroom(cfg.RHP2.MinStorageHeadroom))
+	}
+	if cfg.RHP3.MaxProgramBuffer > 0 {
+		rhp3Opts = append(rhp3Opts, rhp3.WithMaxProgramBuffer(cfg.RHP3.MaxProgramBuffer))
+	}
+	switch mode := rhp3.ProgramFailureMode(cfg.RHP3.ProgramFailureMode); mode {
+	case "":
+	case rhp3.ProgramFailureRollback, rhp3.ProgramFailureCommitCompleted:
+		rhp3Opts = append(rhp3Opts, rhp3.WithProgramFailureMode(mode))
+	default:
+		return nil, types.PrivateKey{}, fmt.Errorf("unknown program failure mode %q", mode)
+	}
+	if len(torRHP3) > 0 {
+		rhp3Opts = append(rhp3Opts, rhp3.WithNetworkTagger(rhp.ListenerTagger(rhp.NetworkTor, torRHP3...)), rhp3.WithNetworkPolicy(rhp.NetworkTor, torPolicy))
+	}
+	rhp3, err := startRHP3(rhp3Listeners, hostKey, cm, tp, w, accountManager, contractManager, registryManager, sr, sm, dm, sessions, rhpLogger.Named("rhp3"), rhp3Opts...)
 	if err != nil {
 		return nil, types.PrivateKey{}, fmt.Errorf("failed to start rhp3: %w", err)
 	}
 
+	// the tracker records heartbeats while the process is running. It is
+	// started after the RHP listeners and closed before them, but does not
+	// detect a listener failing in between.
+	uptime, err := metrics.NewUptimeTracker(db, logger.Named("uptime"), metrics.DefaultHeartbeatInterval)
+	if err != nil {
+		return nil, types.PrivateKey{}, fmt.Errorf("failed to start uptime tracker: %w", err)
+	}
+
 	return &node{
 		g:     g,
 		a:     am,
@@ -232,7 +576,8 @@ func newNode(ctx context.Context, walletKey types.PrivateKey, ex *explorer.Explo
 		w:     w,
 		store: db,
 
-		metrics:   metrics.NewManager(db),
+		metrics:   mm,
+		uptime:    uptime,
 		settings:  sr,
 		pinned:    pm,
 		accounts:  accountManager,
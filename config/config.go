@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type (
 	// HTTP contains the configuration for the HTTP server.
 	HTTP struct {
@@ -12,11 +14,26 @@ type (
 		GatewayAddress string   `yaml:"gatewayAddress,omitempty"`
 		Bootstrap      bool     `yaml:"bootstrap,omitempty"`
 		Peers          []string `yaml:"peers,omitempty"`
+		// SyncTolerance is the number of blocks the host's tip can be
+		// behind the network before it stops forming and renewing
+		// contracts.
+		SyncTolerance uint64 `yaml:"syncTolerance,omitempty"`
 	}
 
 	// RHP2 contains the configuration for the RHP2 server.
 	RHP2 struct {
 		Address string `yaml:"address,omitempty"`
+		// AdditionalAddresses are extra addresses to accept RHP2
+		// connections on, such as an IPv6 address on a dual-stack host.
+		AdditionalAddresses []string `yaml:"additionalAddresses,omitempty"`
+		// HandshakeTimeout is the time a peer has to complete the RHP2
+		// handshake before the connection is closed. Zero uses the
+		// default.
+		HandshakeTimeout time.Duration `yaml:"handshakeTimeout,omitempty"`
+		// MinStorageHeadroom is the number of free 4 MiB sectors the host
+		// must have to form new contracts or renew contracts over RHP2 or
+		// RHP3. Zero disables the check.
+		MinStorageHeadroom uint64 `yaml:"minStorageHeadroom,omitempty"`
 	}
 
 	// ExplorerData contains the configuration for using an external explorer.
@@ -31,6 +48,317 @@ type (
 		WebSocketAddress string `yaml:"websocket,omitempty"`
 		CertPath         string `yaml:"certPath,omitempty"`
 		KeyPath          string `yaml:"keyPath,omitempty"`
+		// AdditionalTCPAddresses are extra addresses to accept TCP RHP3
+		// connections on.
+		AdditionalTCPAddresses []string `yaml:"additionalTCP,omitempty"`
+		// HandshakeTimeout is the time a peer has to complete the RHP3
+		// handshake before the connection is closed. Zero uses the
+		// default.
+		HandshakeTimeout time.Duration `yaml:"handshakeTimeout,omitempty"`
+		// MaxProgramBuffer is the maximum number of bytes of instruction
+		// output a program can hold in memory. It must hold at least a
+		// sector and its proof. Zero uses the default.
+		MaxProgramBuffer uint64 `yaml:"maxProgramBuffer,omitempty"`
+		// MaxRegistryValueSize is the maximum size in bytes of a registry
+		// entry's data. Zero uses the protocol maximum of 113 bytes.
+		MaxRegistryValueSize int `yaml:"maxRegistryValueSize,omitempty"`
+		// ProgramFailureMode determines how programs that fail before all
+		// of their instructions are executed are handled, either
+		// "rollback" or "commitCompleted". Defaults to "rollback".
+		ProgramFailureMode string `yaml:"programFailureMode,omitempty"`
+	}
+
+	// Tor contains the configuration for renters connecting through a Tor
+	// hidden service. The Tor daemon forwards hidden service connections to
+	// the listener addresses, so connections received on them are tagged as
+	// Tor connections.
+	Tor struct {
+		RHP2Address string `yaml:"rhp2,omitempty"`
+		RHP3Address string `yaml:"rhp3,omitempty"`
+		// NetAddress is the onion address advertised to renters connecting
+		// through Tor. It is also included in the host's announcements.
+		NetAddress string `yaml:"netAddress,omitempty"`
+		// RejectContracts rejects contract formation and renewal through
+		// Tor.
+		RejectContracts bool `yaml:"rejectContracts,omitempty"`
+		// PriceMultiplier is applied to the host's prices for renters
+		// connecting through Tor, e.g. 1.5 for a 50% premium.
+		PriceMultiplier float64 `yaml:"priceMultiplier,omitempty"`
+	}
+
+	// Wallet contains the configuration for the wallet.
+	Wallet struct {
+		// TransactionRetention is the number of blocks transactions are
+		// kept in the wallet's history. Older transactions are pruned
+		// unless they created an unspent output. 0 keeps all transactions.
+		TransactionRetention uint64 `yaml:"transactionRetention,omitempty"`
+		// FeeReserve is the balance kept in the wallet to pay for
+		// transaction fees, e.g. "10 KS". Funds above the reserve are
+		// withdrawable.
+		FeeReserve string `yaml:"feeReserve,omitempty"`
+		// WithdrawAddress is the address funds above the fee reserve are
+		// periodically withdrawn to. Withdrawals are disabled if empty.
+		WithdrawAddress string `yaml:"withdrawAddress,omitempty"`
+		// WithdrawInterval is the interval between scheduled withdrawals.
+		WithdrawInterval time.Duration `yaml:"withdrawInterval,omitempty"`
+		// MinUTXOs is the minimum number of spendable outputs the wallet
+		// maintains so concurrent storage proofs and contracts can be
+		// funded. The balance is split when the count drops below it. Zero
+		// disables splitting.
+		MinUTXOs int `yaml:"minUTXOs,omitempty"`
+	}
+
+	// Contracts contains the configuration for the contract manager.
+	Contracts struct {
+		// ProofStrategy determines when storage proofs are submitted within
+		// the proof window. Valid values are "immediate" and "spread".
+		ProofStrategy string `yaml:"proofStrategy,omitempty"`
+		// ProofFailureThreshold is the number of contracts that must fail
+		// without a storage proof within ProofFailureWindow before a summary
+		// alert is registered. 0 disables the summary alert.
+		ProofFailureThreshold int           `yaml:"proofFailureThreshold,omitempty"`
+		ProofFailureWindow    time.Duration `yaml:"proofFailureWindow,omitempty"`
+		// ProofBreakerThreshold is the number of contracts that must fail
+		// without a storage proof within ProofBreakerWindow before the host
+		// stops accepting contracts until the breaker is reset. 0 disables
+		// the breaker.
+		ProofBreakerThreshold int           `yaml:"proofBreakerThreshold,omitempty"`
+		ProofBreakerWindow    time.Duration `yaml:"proofBreakerWindow,omitempty"`
+		// ReleaseCollateral releases a contract's collateral from the
+		// collateral metrics when the contract is cleared by a renewal
+		// instead of when its proof window closes.
+		ReleaseCollateral bool `yaml:"releaseCollateral,omitempty"`
+		// MaxProofFeeRatio is the maximum ratio of the storage proof fee to
+		// the payout recovered by submitting the proof. Proofs that cost
+		// more are skipped. 0 always submits proofs.
+		MaxProofFeeRatio float64 `yaml:"maxProofFeeRatio,omitempty"`
+		// PruneRetention is the number of blocks after a contract's proof
+		// window ends before its data is pruned and replaced with a
+		// tombstone. Pinned contracts are kept. 0 disables automatic
+		// pruning.
+		PruneRetention uint64 `yaml:"pruneRetention,omitempty"`
+		// DoubleSpendPolicy determines how a pending contract is handled
+		// when the renter double spends its formation inputs. Valid values
+		// are "reject" and "alert".
+		DoubleSpendPolicy string `yaml:"doubleSpendPolicy,omitempty"`
+		// MinRenterSuccessfulContracts is the number of successful
+		// contracts a renter must have completed with the host before new
+		// contracts are formed. 0 accepts new renters.
+		MinRenterSuccessfulContracts uint64 `yaml:"minRenterSuccessfulContracts,omitempty"`
+		// EstablishedRenterContracts is the number of successful contracts
+		// after which a renter is considered established.
+		EstablishedRenterContracts uint64 `yaml:"establishedRenterContracts,omitempty"`
+		// NewRenterPriceMultiplier is applied to the contract price charged
+		// to renters that are not established, e.g. 1.5 for a 50% premium.
+		NewRenterPriceMultiplier float64 `yaml:"newRenterPriceMultiplier,omitempty"`
+	}
+
+	// Announcement contains the configuration for host announcements.
+	Announcement struct {
+		// Fee is a fixed miner fee to pay for announcement transactions,
+		// e.g. "10 mS". If empty, the recommended fee is used.
+		Fee string `yaml:"fee,omitempty"`
+		// RefreshInterval is the number of blocks after which the host
+		// re-announces its unchanged address. If zero, the host re-announces
+		// every 180 days.
+		RefreshInterval uint64 `yaml:"refreshInterval,omitempty"`
+		// MaxRefreshFee is the maximum miner fee to pay for a refresh,
+		// e.g. "10 mS". Refreshes are deferred while the fee is higher. If
+		// empty, refreshes are not limited.
+		MaxRefreshFee string `yaml:"maxRefreshFee,omitempty"`
+	}
+
+	// Storage contains the configuration for the volume manager.
+	Storage struct {
+		// MaxOpenVolumes limits the number of volume files that are open at
+		// the same time. Zero means every volume is kept open.
+		MaxOpenVolumes int `yaml:"maxOpenVolumes,omitempty"`
+		// SectorChecksums stores a checksum of each sector when it is
+		// written so reads can be verified without recomputing the Merkle
+		// root.
+		SectorChecksums bool `yaml:"sectorChecksums,omitempty"`
+		// PrefetchDepth is the number of sectors read into the cache ahead
+		// of a sequential download. Zero disables prefetching.
+		PrefetchDepth int `yaml:"prefetchDepth,omitempty"`
+		// ReadAheadSectors is the number of contiguous sectors read from a
+		// volume with a single call during sequential reads. Values less
+		// than 2 disable read-ahead.
+		ReadAheadSectors int `yaml:"readAheadSectors,omitempty"`
+		// MaxConcurrentMigrations limits the number of sectors migrated at
+		// the same time by all volume operations. Zero means migrations are
+		// not limited.
+		MaxConcurrentMigrations int `yaml:"maxConcurrentMigrations,omitempty"`
+		// EncryptionPassphrase enables encryption at rest for new volumes.
+		// Encrypted volumes cannot be opened without it. Defaults to the
+		// HOSTD_VOLUME_PASSPHRASE environment variable.
+		EncryptionPassphrase string `yaml:"encryptionPassphrase,omitempty"`
+		// MaxVolumes is the maximum number of volumes that can be added to
+		// the host. Zero uses the default of 1000.
+		MaxVolumes int `yaml:"maxVolumes,omitempty"`
+		// StartupVerification is the level of volume verification run
+		// before the host starts accepting connections: "none", "quick",
+		// or "full". Defaults to "none".
+		StartupVerification string `yaml:"startupVerification,omitempty"`
+		// StartupAbortSeverity is the minimum severity of a verification
+		// issue that aborts startup: "warning", "error", or "critical".
+		// Issues are only alerted if empty.
+		StartupAbortSeverity string `yaml:"startupAbortSeverity,omitempty"`
+		// WriteLatencySLO is the write latency objective for each volume.
+		WriteLatencySLO LatencySLO `yaml:"writeLatencySLO,omitempty"`
+		// WriteTimeout is the maximum time a sector write may take before
+		// it is abandoned and the volume is marked as degraded. Zero
+		// disables the timeout.
+		WriteTimeout time.Duration `yaml:"writeTimeout,omitempty"`
+		// SelfAudit periodically reads a sample of stored sectors to catch
+		// read problems before renters do.
+		SelfAudit SelfAudit `yaml:"selfAudit,omitempty"`
+		// ExpiredSectors configures the handling of sectors referenced
+		// only by expired contracts that have not been pruned yet.
+		ExpiredSectors ExpiredSectors `yaml:"expiredSectors,omitempty"`
+		// AutoGrow grows volumes that are running out of space when the
+		// underlying disk has room.
+		AutoGrow AutoGrow `yaml:"autoGrow,omitempty"`
+		// SectorCache limits the memory used by the sector cache.
+		SectorCache SectorCache `yaml:"sectorCache,omitempty"`
+		// Relink searches for volume files that were moved from their
+		// recorded path when the host starts.
+		Relink Relink `yaml:"relink,omitempty"`
+		// Packing packs the payloads of small sectors into shared sectors.
+		Packing Packing `yaml:"packing,omitempty"`
+	}
+
+	// LatencySLO configures a latency objective. An alert is registered when
+	// the given percentile of operations within the rolling window take
+	// longer than the threshold. A zero threshold disables the objective.
+	LatencySLO struct {
+		// Percentile is between 0 and 1, e.g. 0.99 for p99.
+		Percentile float64       `yaml:"percentile,omitempty"`
+		Threshold  time.Duration `yaml:"threshold,omitempty"`
+		// Window is the rolling window operations are evaluated over.
+		// Defaults to 10 minutes.
+		Window time.Duration `yaml:"window,omitempty"`
+	}
+
+	// SelfAudit configures the periodic self-audit of stored sectors.
+	SelfAudit struct {
+		// Interval is the time between audits. Zero disables the audit.
+		Interval time.Duration `yaml:"interval,omitempty"`
+		// SampleSize is the number of sectors read by each audit. Defaults
+		// to 10.
+		SampleSize int `yaml:"sampleSize,omitempty"`
+		// LatencyThreshold is the read latency above which a sector is
+		// reported as slow. Zero disables the latency check.
+		LatencyThreshold time.Duration `yaml:"latencyThreshold,omitempty"`
+	}
+
+	// AutoGrow configures the automatic growth of volumes.
+	AutoGrow struct {
+		// Interval is the time between checks. Zero disables automatic
+		// growth.
+		Interval time.Duration `yaml:"interval,omitempty"`
+		// Threshold is the number of free sectors below which a volume is
+		// grown.
+		Threshold uint64 `yaml:"threshold,omitempty"`
+		// Increment is the number of sectors added each time a volume is
+		// grown.
+		Increment uint64 `yaml:"increment,omitempty"`
+		// MaxSectors is the size volumes will not be grown beyond. Zero
+		// does not limit the size.
+		MaxSectors uint64 `yaml:"maxSectors,omitempty"`
+		// ReservedSpace is the number of bytes that must remain free on the
+		// disk after a volume is grown.
+		ReservedSpace uint64 `yaml:"reservedSpace,omitempty"`
+	}
+
+	// Relink configures the search for moved volume files.
+	Relink struct {
+		// SearchDirs are the directories searched for missing volume
+		// files. Subdirectories are not searched. Empty disables the
+		// search.
+		SearchDirs []string `yaml:"searchDirs,omitempty"`
+		// Auto updates a volume's path if exactly one file matches.
+		// Otherwise matches are only reported with an alert.
+		Auto bool `yaml:"auto,omitempty"`
+		// SampleSize is the number of stored sectors compared against
+		// each candidate file. Defaults to 16.
+		SampleSize int `yaml:"sampleSize,omitempty"`
+	}
+
+	// Packing configures packing small sector payloads into shared sectors.
+	Packing struct {
+		// MaxPayloadSize is the largest payload, in bytes, packed into a
+		// shared sector. Zero disables packing.
+		MaxPayloadSize uint64 `yaml:"maxPayloadSize,omitempty"`
+	}
+
+	// SectorCache limits the memory used by the sector cache.
+	SectorCache struct {
+		// MaxBytes is the maximum memory used by cached sectors. Zero only
+		// limits the cache by the sector cache size setting.
+		MaxBytes uint64 `yaml:"maxBytes,omitempty"`
+		// HighWaterMark is the heap size, in bytes, above which half of the
+		// cached sectors are evicted. Zero disables the check.
+		HighWaterMark uint64 `yaml:"highWaterMark,omitempty"`
+		// CheckInterval is the time between heap checks. Defaults to 10
+		// seconds.
+		CheckInterval time.Duration `yaml:"checkInterval,omitempty"`
+	}
+
+	// ExpiredSectors configures the handling of sectors referenced only by
+	// expired contracts that have not been pruned yet.
+	ExpiredSectors struct {
+		// GracePeriod is the number of blocks after a contract's proof
+		// window ends before its sectors are considered reclaimable.
+		GracePeriod uint64 `yaml:"gracePeriod,omitempty"`
+		// ReclaimOnMigration removes reclaimable sectors instead of
+		// migrating them when a volume is removed or shrunk.
+		ReclaimOnMigration bool `yaml:"reclaimOnMigration,omitempty"`
+	}
+
+	// AutoPrice configures the automatic adjustment of the storage price
+	// toward a target storage utilization. The storage price cannot be
+	// pinned while automatic adjustment is enabled.
+	AutoPrice struct {
+		// Interval is the time between adjustments. Zero disables automatic
+		// adjustment.
+		Interval time.Duration `yaml:"interval,omitempty"`
+		// TargetUtilization is the fraction of storage, between 0 and 1,
+		// the host steers toward. Defaults to 0.8.
+		TargetUtilization float64 `yaml:"targetUtilization,omitempty"`
+		// Tolerance is how far the utilization may be from the target
+		// before the price is changed. Defaults to 0.05.
+		Tolerance float64 `yaml:"tolerance,omitempty"`
+		// Step is the fraction the price is changed by in each adjustment.
+		// Defaults to 0.05.
+		Step float64 `yaml:"step,omitempty"`
+		// MinPrice and MaxPrice bound the storage price in SC per TB per
+		// month.
+		MinPrice string `yaml:"minPrice,omitempty"`
+		MaxPrice string `yaml:"maxPrice,omitempty"`
+	}
+
+	// AlertSink configures an external destination alerts are delivered to.
+	AlertSink struct {
+		// Type is one of "webhook", "slack", or "email".
+		Type string `yaml:"type,omitempty"`
+		// MinSeverity is the lowest severity delivered to the sink. One of
+		// "info", "warning", "error", or "critical". Defaults to "warning".
+		MinSeverity string `yaml:"minSeverity,omitempty"`
+		// URL is the endpoint of webhook and slack sinks.
+		URL string `yaml:"url,omitempty"`
+
+		// SMTPAddress is the host:port of the SMTP server used by email
+		// sinks.
+		SMTPAddress  string   `yaml:"smtpAddress,omitempty"`
+		SMTPUsername string   `yaml:"smtpUsername,omitempty"`
+		SMTPPassword string   `yaml:"smtpPassword,omitempty"`
+		From         string   `yaml:"from,omitempty"`
+		To           []string `yaml:"to,omitempty"`
+	}
+
+	// Alerts configures the delivery of alerts.
+	Alerts struct {
+		Sinks []AlertSink `yaml:"sinks,omitempty"`
 	}
 
 	// LogFile configures the file output of the logger.
@@ -50,6 +378,15 @@ type (
 		EnableANSI bool   `yaml:"enableANSI,omitempty"` //nolint:tagliatelle
 	}
 
+	// LogSampling configures sampling of repeated RHP warnings and errors.
+	// The first entries of each kind from the same peer are written during
+	// each interval and the rest are collapsed into a summary.
+	LogSampling struct {
+		Enabled  bool          `yaml:"enabled,omitempty"`
+		Interval time.Duration `yaml:"interval,omitempty"`
+		First    int           `yaml:"first,omitempty"`
+	}
+
 	// Log contains the configuration for the logger.
 	Log struct {
 		// Path is the directory to store the hostd.log file.
@@ -58,6 +395,8 @@ type (
 		Level  string  `yaml:"level,omitempty"` // global log level
 		StdOut StdOut  `yaml:"stdout,omitempty"`
 		File   LogFile `yaml:"file,omitempty"`
+		// RHPSampling limits repeated RHP error logs.
+		RHPSampling LogSampling `yaml:"rhpSampling,omitempty"`
 	}
 
 	// Config contains the configuration for the host.
@@ -67,11 +406,18 @@ type (
 		RecoveryPhrase string `yaml:"recoveryPhrase,omitempty"`
 		AutoOpenWebUI  bool   `yaml:"autoOpenWebUI,omitempty"`
 
-		HTTP      HTTP         `yaml:"http,omitempty"`
-		Consensus Consensus    `yaml:"consensus,omitempty"`
-		Explorer  ExplorerData `yaml:"explorer,omitempty"`
-		RHP2      RHP2         `yaml:"rhp2,omitempty"`
-		RHP3      RHP3         `yaml:"rhp3,omitempty"`
-		Log       Log          `yaml:"log,omitempty"`
+		HTTP         HTTP         `yaml:"http,omitempty"`
+		Consensus    Consensus    `yaml:"consensus,omitempty"`
+		Explorer     ExplorerData `yaml:"explorer,omitempty"`
+		RHP2         RHP2         `yaml:"rhp2,omitempty"`
+		RHP3         RHP3         `yaml:"rhp3,omitempty"`
+		Tor          Tor          `yaml:"tor,omitempty"`
+		Wallet       Wallet       `yaml:"wallet,omitempty"`
+		Contracts    Contracts    `yaml:"contracts,omitempty"`
+		Storage      Storage      `yaml:"storage,omitempty"`
+		Announcement Announcement `yaml:"announcement,omitempty"`
+		AutoPrice    AutoPrice    `yaml:"autoPrice,omitempty"`
+		Alerts       Alerts       `yaml:"alerts,omitempty"`
+		Log          Log          `yaml:"log,omitempty"`
 	}
 )
@@ -19,6 +19,9 @@ var (
 	// ErrBalanceExceeded is returned when an account's balance exceeds the
 	// maximum balance.
 	ErrBalanceExceeded = errors.New("ephemeral account maximum balance exceeded") // note: text is required for compatibility with siad
+	// ErrDepositTooSmall is returned when a deposit is less than the host's
+	// minimum account deposit.
+	ErrDepositTooSmall = errors.New("ephemeral account deposit is less than the minimum deposit")
 )
 
 type (
@@ -124,8 +127,13 @@ func (am *AccountManager) Credit(req FundAccountWithContract, refund bool) (type
 		return types.ZeroCurrency, fmt.Errorf("failed to get account balance: %w", err)
 	}
 
+	// refunds are credited by the host and are not subject to the deposit
+	// limits
+	settings := am.settings.Settings()
 	creditBalance := balance.Add(req.Amount)
-	if !refund && creditBalance.Cmp(am.settings.Settings().MaxAccountBalance) > 0 {
+	if !refund && req.Amount.Cmp(settings.MinAccountDeposit) < 0 {
+		return types.ZeroCurrency, ErrDepositTooSmall
+	} else if !refund && creditBalance.Cmp(settings.MaxAccountBalance) > 0 {
 		return types.ZeroCurrency, ErrBalanceExceeded
 	}
 
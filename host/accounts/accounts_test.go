@@ -23,11 +23,13 @@ import (
 )
 
 type ephemeralSettings struct {
+	minDeposit types.Currency
 	maxBalance types.Currency
 }
 
 func (s ephemeralSettings) Settings() settings.Settings {
 	return settings.Settings{
+		MinAccountDeposit: s.minDeposit,
 		MaxAccountBalance: s.maxBalance,
 	}
 }
@@ -105,7 +107,7 @@ func TestCredit(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	am := accounts.NewManager(db, ephemeralSettings{maxBalance: types.NewCurrency64(100)})
+	am := accounts.NewManager(db, ephemeralSettings{minDeposit: types.NewCurrency64(10), maxBalance: types.NewCurrency64(100)})
 	accountID := frand.Entropy256()
 
 	// attempt to credit the account
@@ -149,6 +151,22 @@ func TestCredit(t *testing.T) {
 		t.Fatalf("expected 1 active account, got %v", m.Accounts.Active)
 	}
 
+	// attempt to credit the account with less than the minimum deposit
+	req = accounts.FundAccountWithContract{
+		Account:    accountID,
+		Amount:     types.NewCurrency64(5),
+		Cost:       types.NewCurrency64(1),
+		Revision:   rev,
+		Expiration: time.Now().Add(time.Minute),
+	}
+	if _, err := am.Credit(req, false); err != accounts.ErrDepositTooSmall {
+		t.Fatalf("expected ErrDepositTooSmall, got %v", err)
+	} else if balance, err := am.Balance(accountID); err != nil {
+		t.Fatal(err)
+	} else if !balance.Equals(expectedFunding) {
+		t.Fatalf("expected balance %v, got %v", expectedFunding, balance)
+	}
+
 	// attempt to credit the account over the max balance
 	amount = types.NewCurrency64(100)
 	req = accounts.FundAccountWithContract{
@@ -1,7 +1,9 @@
 package contracts
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync/atomic"
 	"time"
@@ -12,6 +14,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// broadcastInterval is the number of blocks between broadcasts of a lifecycle
+// action's transactions.
+const broadcastInterval = 3
+
 // An action determines what lifecycle event should be performed on a contract.
 const (
 	ActionBroadcastFormation     = "formation"
@@ -21,6 +27,153 @@ const (
 	ActionExpire                 = "expire"
 )
 
+// Action statuses describe the outcome of a lifecycle action.
+const (
+	// ActionStatusBroadcast indicates that one or more transactions were
+	// broadcast.
+	ActionStatusBroadcast = "broadcast"
+	// ActionStatusSkipped indicates that the action was not needed at the
+	// current height.
+	ActionStatusSkipped = "skipped"
+	// ActionStatusCompleted indicates that the action updated the contract
+	// without broadcasting a transaction.
+	ActionStatusCompleted = "completed"
+	// ActionStatusFailed indicates that the action returned an error.
+	ActionStatusFailed = "failed"
+)
+
+// An ActionResult is the outcome of a lifecycle action performed on a
+// contract.
+type ActionResult struct {
+	ContractID types.FileContractID `json:"contractID"`
+	Height     uint64               `json:"height"`
+	Action     string               `json:"action"`
+	Status     string               `json:"status"`
+
+	TransactionIDs []types.TransactionID `json:"transactionIDs,omitempty"`
+	Fee            types.Currency        `json:"fee"`
+	Error          string                `json:"error,omitempty"`
+	// Cause categorizes why a resolution action failed. It is only set for
+	// failed resolution actions.
+	Cause     ProofFailureCause `json:"cause,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Proof strategies determine when, within a contract's proof window, the host
+// will submit its storage proof.
+const (
+	// ProofStrategyImmediate submits storage proofs as soon as the proof
+	// window opens.
+	ProofStrategyImmediate ProofStrategy = iota
+	// ProofStrategySpread spreads storage proof submissions across the first
+	// half of the proof window to avoid competing for block space when many
+	// contracts share the same window. The second half of the window is
+	// reserved for retries.
+	ProofStrategySpread
+)
+
+// A ProofStrategy determines when a storage proof is submitted.
+type ProofStrategy uint8
+
+// SubmissionHeight returns the height at which the host should first attempt
+// to submit a storage proof for the contract. The height is deterministic for
+// a given contract so that it remains stable across restarts.
+func (ps ProofStrategy) SubmissionHeight(id types.FileContractID, windowStart, windowEnd uint64) uint64 {
+	switch ps {
+	case ProofStrategySpread:
+		if windowEnd <= windowStart {
+			return windowStart
+		}
+		span := (windowEnd - windowStart) / 2
+		if span == 0 {
+			return windowStart
+		}
+		return windowStart + binary.LittleEndian.Uint64(id[:8])%span
+	default:
+		return windowStart
+	}
+}
+
+// A broadcastSchedule is the range of heights [start, end) in which a
+// lifecycle action's transactions are broadcast. Broadcasts are spaced
+// broadcastInterval blocks apart, counted from the anchor height.
+type broadcastSchedule struct {
+	start, end, anchor uint64
+}
+
+// scheduled returns true if the action is broadcast at height.
+func (bs broadcastSchedule) scheduled(height uint64) bool {
+	if height < bs.start || height >= bs.end {
+		return false
+	} else if height >= bs.anchor {
+		return (height-bs.anchor)%broadcastInterval == 0
+	}
+	return (bs.anchor-height)%broadcastInterval == 0
+}
+
+// next returns the first height after current at which the action is
+// broadcast. false is returned if there is no such height.
+func (bs broadcastSchedule) next(current uint64) (uint64, bool) {
+	height := max(current+1, bs.start)
+	if height >= bs.anchor {
+		if d := (height - bs.anchor) % broadcastInterval; d != 0 {
+			height += broadcastInterval - d
+		}
+	} else {
+		height += (bs.anchor - height) % broadcastInterval
+	}
+	return height, height < bs.end
+}
+
+// broadcastSchedule returns the schedule of a broadcast action for the
+// contract. The ranges match the heights at which the store returns the
+// action for the contract; resolutions are further delayed until the
+// proof strategy's submission height.
+func (cm *ContractManager) broadcastSchedule(c Contract, action string) broadcastSchedule {
+	windowStart, windowEnd := c.Revision.WindowStart, c.Revision.WindowEnd
+	switch action {
+	case ActionBroadcastFormation:
+		// formations are rebroadcast until the rebroadcast buffer ends
+		return broadcastSchedule{start: c.NegotiationHeight, end: c.NegotiationHeight + RebroadcastBuffer + 1, anchor: c.NegotiationHeight}
+	case ActionBroadcastFinalRevision:
+		// final revisions are broadcast before the proof window opens
+		var start uint64
+		if windowStart > RevisionSubmissionBuffer {
+			start = windowStart - RevisionSubmissionBuffer
+		}
+		return broadcastSchedule{start: start, end: windowStart + 1, anchor: windowStart}
+	case ActionBroadcastResolution:
+		// storage proofs are broadcast within the proof window
+		submissionHeight := cm.proofStrategy.SubmissionHeight(c.Revision.ParentID, windowStart, windowEnd)
+		return broadcastSchedule{start: submissionHeight, end: windowEnd, anchor: submissionHeight}
+	default:
+		panic("unknown broadcast action " + action) // developer error
+	}
+}
+
+// proofRecoverable returns the payout the host recovers by submitting the
+// contract's storage proof. false is returned if the host does not benefit
+// from submitting it.
+func proofRecoverable(c Contract) (types.Currency, bool) {
+	validPayout, missedPayout := c.Revision.ValidHostPayout(), c.Revision.MissedHostPayout()
+	if missedPayout.Cmp(validPayout) >= 0 {
+		return types.ZeroCurrency, false
+	}
+	return validPayout.Sub(missedPayout), true
+}
+
+// String implements fmt.Stringer.
+func (ps ProofStrategy) String() string {
+	switch ps {
+	case ProofStrategyImmediate:
+		return "immediate"
+	case ProofStrategySpread:
+		return "spread"
+	default:
+		return "unknown"
+	}
+}
+
 func (cm *ContractManager) buildStorageProof(id types.FileContractID, filesize uint64, index uint64, log *zap.Logger) (types.StorageProof, error) {
 	if filesize == 0 {
 		return types.StorageProof{
@@ -34,7 +187,7 @@ func (cm *ContractManager) buildStorageProof(id types.FileContractID, filesize u
 	roots, err := cm.getSectorRoots(id)
 	if err != nil {
 		return types.StorageProof{}, fmt.Errorf("failed to get sector roots: %w", err)
-	} else if uint64(len(roots)) < sectorIndex {
+	} else if uint64(len(roots)) <= sectorIndex {
 		log.Error("failed to build storage proof. invalid root index", zap.Uint64("sectorIndex", sectorIndex), zap.Uint64("segmentIndex", segmentIndex), zap.Int("rootsLength", len(roots)))
 		return types.StorageProof{}, fmt.Errorf("invalid root index")
 	}
@@ -67,12 +220,15 @@ func (cm *ContractManager) processActions() {
 				}
 				defer done()
 
-				err = cm.store.ContractAction(height, cm.handleContractAction)
+				err = cm.store.ContractAction(height, func(id types.FileContractID, height uint64, action string) {
+					cm.recordActionResult(cm.handleContractAction(id, height, action))
+				})
 				if err != nil {
 					return fmt.Errorf("failed to process contract actions: %w", err)
 				} else if err = cm.store.ExpireContractSectors(height); err != nil {
 					return fmt.Errorf("failed to expire contract sectors: %w", err)
 				}
+				cm.pruneExpiredContracts(height)
 				return nil
 			}()
 			if err != nil {
@@ -85,13 +241,77 @@ func (cm *ContractManager) processActions() {
 	}
 }
 
-// handleContractAction performs a lifecycle action on a contract.
-func (cm *ContractManager) handleContractAction(id types.FileContractID, height uint64, action string) {
+// recordActionResult persists the result of a lifecycle action so it is
+// visible in the contract's history. Skipped actions are not recorded since
+// they are expected on most blocks.
+func (cm *ContractManager) recordActionResult(result ActionResult) {
+	if result.Status == ActionStatusSkipped {
+		return
+	} else if err := cm.store.AddContractActionResult(result); errors.Is(err, ErrNotFound) {
+		// the action removed the contract, e.g. by reverting a renewal
+		return
+	} else if err != nil {
+		cm.log.Error("failed to record contract action result", zap.Stringer("contractID", result.ContractID), zap.String("action", result.Action), zap.Error(err))
+	}
+}
+
+// ActionHistory returns the recorded lifecycle actions for a contract, oldest
+// first.
+func (cm *ContractManager) ActionHistory(id types.FileContractID) ([]ActionResult, error) {
+	return cm.store.ContractActionResults(id)
+}
+
+// handleContractAction performs a lifecycle action on a contract and returns
+// the outcome of the action.
+func (cm *ContractManager) handleContractAction(id types.FileContractID, height uint64, action string) ActionResult {
 	log := cm.log.Named("lifecycle").With(zap.String("contractID", id.String()), zap.Uint64("height", height), zap.String("action", action))
+	result := ActionResult{
+		ContractID: id,
+		Height:     height,
+		Action:     action,
+		Status:     ActionStatusCompleted,
+		Timestamp:  time.Now(),
+	}
+	// helpers to set the result's status
+	skipped := func() ActionResult {
+		result.Status = ActionStatusSkipped
+		return result
+	}
+	failed := func(err error) ActionResult {
+		result.Status = ActionStatusFailed
+		result.Error = err.Error()
+		var pe *proofError
+		if errors.As(err, &pe) {
+			result.Cause = pe.cause
+		}
+		return result
+	}
+	broadcast := func(txnSet []types.Transaction, fee types.Currency) {
+		result.Status = ActionStatusBroadcast
+		result.Fee = fee
+		for _, txn := range txnSet {
+			result.TransactionIDs = append(result.TransactionIDs, txn.ID())
+		}
+		if fee.IsZero() {
+			return
+		}
+		// the fee is paid by the last transaction in the set
+		err := cm.store.AddFeeExpenditure(FeeExpenditure{
+			ContractID:    id,
+			Category:      feeCategory(action),
+			TransactionID: result.TransactionIDs[len(result.TransactionIDs)-1],
+			Amount:        fee,
+			Timestamp:     result.Timestamp,
+		})
+		if err != nil {
+			log.Error("failed to record fee expenditure", zap.Error(err))
+		}
+	}
+
 	contract, err := cm.store.Contract(id)
 	if err != nil {
 		log.Error("failed to get contract", zap.Error(err))
-		return
+		return failed(err)
 	}
 	log = log.With(zap.Uint64("revisionNumber", contract.Revision.RevisionNumber), zap.Uint64("size", contract.Revision.Filesize), zap.Stringer("merkleRoot", contract.Revision.FileMerkleRoot), zap.Uint64("scanHeight", cm.chain.TipState().Index.Height))
 	log.Debug("performing contract action", zap.Uint64("negotiationHeight", contract.NegotiationHeight), zap.Uint64("windowStart", contract.Revision.WindowStart), zap.Uint64("windowEnd", contract.Revision.WindowEnd))
@@ -119,25 +339,27 @@ func (cm *ContractManager) handleContractAction(id types.FileContractID, height
 
 	switch action {
 	case ActionBroadcastFormation:
-		if (height-contract.NegotiationHeight)%3 != 0 {
+		if !cm.broadcastSchedule(contract, action).scheduled(height) {
 			// debounce formation broadcasts to prevent spamming
 			log.Debug("skipping rebroadcast", zap.Uint64("negotiationHeight", contract.NegotiationHeight))
-			return
+			return skipped()
 		}
 		formationSet, err := cm.store.ContractFormationSet(id)
 		if err != nil {
 			log.Error("failed to get formation set", zap.Error(err))
-			return
+			return failed(err)
 		} else if err := cm.tpool.AcceptTransactionSet(formationSet); err != nil {
 			log.Error("failed to broadcast formation transaction", zap.Error(err))
-			return
+			return failed(err)
 		}
+		// the formation fee was paid when the contract was formed
+		broadcast(formationSet, types.ZeroCurrency)
 		log.Info("rebroadcast formation transaction", zap.String("transactionID", formationSet[len(formationSet)-1].ID().String()))
 	case ActionBroadcastFinalRevision:
-		if (contract.Revision.WindowStart-height)%3 != 0 {
+		if !cm.broadcastSchedule(contract, action).scheduled(height) {
 			// debounce final revision broadcasts to prevent spamming
 			log.Debug("skipping revision", zap.Uint64("windowStart", contract.Revision.WindowStart))
-			return
+			return skipped()
 		}
 		revisionTxn := types.Transaction{
 			FileContractRevisions: []types.FileContractRevision{contract.Revision},
@@ -156,39 +378,63 @@ func (cm *ContractManager) handleContractAction(id types.FileContractID, height
 			},
 		}
 
-		fee := cm.tpool.RecommendedFee().Mul64(1000)
+		fee := cm.tpool.RecommendedFee().Mul64(revisionTxnSize)
 		revisionTxn.MinerFees = append(revisionTxn.MinerFees, fee)
 		toSign, discard, err := cm.wallet.FundTransaction(&revisionTxn, fee)
 		if err != nil {
 			log.Error("failed to fund revision transaction", zap.Error(err))
-			return
+			registerContractAlert(alerts.SeverityError, "Failed to fund revision transaction", err)
+			return failed(err)
 		}
 		defer discard()
 		if err := cm.wallet.SignTransaction(cs, &revisionTxn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
 			log.Error("failed to sign revision transaction", zap.Error(err))
-			return
+			return failed(err)
 		} else if err := cm.tpool.AcceptTransactionSet([]types.Transaction{revisionTxn}); err != nil {
 			log.Error("failed to broadcast revision transaction", zap.Error(err))
-			return
+			return failed(err)
 		}
+		broadcast([]types.Transaction{revisionTxn}, fee)
 		log.Info("broadcast final revision", zap.Uint64("revisionNumber", contract.Revision.RevisionNumber), zap.String("transactionID", revisionTxn.ID().String()))
 	case ActionBroadcastResolution:
-		if (height-contract.Revision.WindowStart)%3 != 0 {
+		if schedule := cm.broadcastSchedule(contract, action); !schedule.scheduled(height) {
+			// wait for the contract's scheduled submission height and
 			// debounce resolution broadcasts to prevent spamming
-			log.Debug("skipping resolution", zap.Uint64("windowStart", contract.Revision.WindowStart))
-			return
+			log.Debug("skipping resolution, not scheduled", zap.Uint64("windowStart", contract.Revision.WindowStart), zap.Uint64("submissionHeight", schedule.anchor))
+			return skipped()
 		}
-		validPayout, missedPayout := contract.Revision.ValidHostPayout(), contract.Revision.MissedHostPayout()
-		if missedPayout.Cmp(validPayout) >= 0 {
-			log.Info("skipping storage proof, no benefit to host", zap.String("validPayout", validPayout.ExactString()), zap.String("missedPayout", missedPayout.ExactString()))
-			return
+		recoverable, ok := proofRecoverable(contract)
+		if !ok {
+			log.Info("skipping storage proof, no benefit to host", zap.String("validPayout", contract.Revision.ValidHostPayout().ExactString()), zap.String("missedPayout", contract.Revision.MissedHostPayout().ExactString()))
+			return skipped()
+		}
+
+		// skip the proof if broadcasting it costs more than it recovers
+		fee := cm.proofFee(contract.Revision.Filesize)
+		if cm.proofUneconomical(fee, recoverable) {
+			log.Info("skipping storage proof, fee exceeds recoverable payout", zap.String("fee", fee.ExactString()), zap.String("recoverable", recoverable.ExactString()), zap.Float64("maxFeeRatio", cm.maxProofFeeRatio))
+			// record the decision so the contract's failure is not reported
+			// as a lost proof
+			if contract.ProofSkipReason == "" {
+				reason := fmt.Sprintf("storage proof fee %v exceeds %v times the recoverable payout %v", fee, cm.maxProofFeeRatio, recoverable)
+				if err := cm.store.SetProofSkipReason(id, reason); err != nil {
+					log.Error("failed to set proof skip reason", zap.Error(err))
+				}
+			}
+			return skipped()
+		} else if contract.ProofSkipReason != "" {
+			// the proof is economical again, clear the previous decision
+			if err := cm.store.SetProofSkipReason(id, ""); err != nil {
+				log.Error("failed to clear proof skip reason", zap.Error(err))
+				return failed(&proofError{ProofFailureInternal, err})
+			}
 		}
 
 		// get the block before the proof window starts
 		windowStart, err := cm.chain.IndexAtHeight(contract.Revision.WindowStart - 1)
 		if err != nil {
 			log.Error("failed to get chain index at height", zap.Uint64("height", contract.Revision.WindowStart-1), zap.Error(err))
-			return
+			return failed(&proofError{ProofFailureInternal, err})
 		}
 
 		// get the proof leaf index
@@ -197,11 +443,9 @@ func (cm *ContractManager) handleContractAction(id types.FileContractID, height
 		if err != nil {
 			log.Error("failed to build storage proof", zap.Error(err))
 			registerContractAlert(alerts.SeverityError, "Failed to build storage proof", err)
-			return
+			return failed(&proofError{ProofFailureDataUnavailable, err})
 		}
 
-		// TODO: consider cost of broadcasting the proof
-		fee := cm.tpool.RecommendedFee().Mul64(1000)
 		resolutionTxnSet := []types.Transaction{
 			{
 				// intermediate funding transaction is required by siad because
@@ -219,7 +463,7 @@ func (cm *ContractManager) handleContractAction(id types.FileContractID, height
 		if err != nil {
 			log.Error("failed to fund resolution transaction", zap.Error(err))
 			registerContractAlert(alerts.SeverityError, "Failed to fund resolution transaction", err)
-			return
+			return failed(&proofError{ProofFailureInsufficientFunds, err})
 		}
 		defer discard()
 
@@ -232,21 +476,37 @@ func (cm *ContractManager) handleContractAction(id types.FileContractID, height
 		start = time.Now()
 		if err := cm.wallet.SignTransaction(cs, &resolutionTxnSet[0], intermediateToSign, types.CoveredFields{WholeTransaction: true}); err != nil { // sign the intermediate transaction
 			log.Error("failed to sign resolution intermediate transaction", zap.Error(err))
-			return
+			return failed(&proofError{ProofFailureInternal, err})
 		} else if err := cm.wallet.SignTransaction(cs, &resolutionTxnSet[1], proofToSign, types.CoveredFields{WholeTransaction: true}); err != nil { // sign the proof transaction
 			log.Error("failed to sign resolution transaction", zap.Error(err))
-			return
+			return failed(&proofError{ProofFailureInternal, err})
 		} else if err := cm.tpool.AcceptTransactionSet(resolutionTxnSet); err != nil { // broadcast the transaction set
 			buf, _ := json.Marshal(resolutionTxnSet)
 			log.Error("failed to broadcast resolution transaction set", zap.Error(err), zap.ByteString("transactionSet", buf))
 			registerContractAlert(alerts.SeverityError, "Failed to broadcast resolution transaction set", err)
-			return
+			return failed(&proofError{ProofFailureBroadcast, err})
 		}
+		broadcast(resolutionTxnSet, fee)
 		cm.alerts.Dismiss(types.Hash256(id)) // dismiss any previous failure alerts
 		log.Info("broadcast storage proof", zap.String("transactionID", resolutionTxnSet[1].ID().String()), zap.Duration("elapsed", time.Since(start)))
 	case ActionReject:
+		// a renewal that was never broadcast is reverted instead of rejected
+		// to restore the existing contract
+		if err := cm.store.RevertRenewal(id); err == nil {
+			cm.rootsCache.Remove(id)
+			cm.rootsCache.Remove(contract.RenewedFrom)
+			log.Warn("reverted renewal that was never confirmed", zap.Stringer("renewedFrom", contract.RenewedFrom))
+			return result
+		} else if errors.Is(err, ErrRenewalRevised) {
+			log.Warn("renewal was revised and cannot be reverted", zap.Stringer("renewedFrom", contract.RenewedFrom))
+		} else if !errors.Is(err, ErrNotFound) {
+			log.Error("failed to revert renewal", zap.Error(err))
+			return failed(err)
+		}
+
 		if err := cm.store.ExpireContract(id, ContractStatusRejected); err != nil {
 			log.Error("failed to set contract status", zap.Error(err))
+			return failed(err)
 		}
 		log.Info("contract rejected", zap.Uint64("negotiationHeight", contract.NegotiationHeight))
 	case ActionExpire:
@@ -257,6 +517,7 @@ func (cm *ContractManager) handleContractAction(id types.FileContractID, height
 			// gained
 			if err := cm.store.ExpireContract(id, ContractStatusRejected); err != nil {
 				log.Error("failed to set contract status", zap.Error(err))
+				return failed(err)
 			}
 		case validPayout.Cmp(missedPayout) <= 0 || contract.ResolutionHeight != 0:
 			// if the host valid payout is less than or equal to the missed
@@ -264,20 +525,59 @@ func (cm *ContractManager) handleContractAction(id types.FileContractID, height
 			// successful
 			if err := cm.store.ExpireContract(id, ContractStatusSuccessful); err != nil {
 				log.Error("failed to set contract status", zap.Error(err))
+				return failed(err)
 			}
 			payout := validPayout
 			if contract.ResolutionHeight != 0 {
 				payout = missedPayout
 			}
 			log.Info("contract successful", zap.String("payout", payout.ExactString()))
+		case validPayout.Cmp(missedPayout) > 0 && contract.ResolutionHeight == 0 && contract.ProofSkipReason != "":
+			// the host decided not to submit the proof. The lost payout was
+			// expected and does not indicate a problem with the host.
+			reason := "proof skipped: " + contract.ProofSkipReason
+			if err := cm.store.FailContract(id, reason); err != nil {
+				log.Error("failed to set contract status", zap.Error(err))
+				return failed(err)
+			}
+			cm.alerts.Register(alerts.Alert{
+				ID:       types.Hash256(id),
+				Severity: alerts.SeverityInfo,
+				Message:  "Contract failed, storage proof skipped",
+				Data: map[string]any{
+					"contractID":  id,
+					"blockHeight": height,
+					"reason":      reason,
+				},
+				Timestamp: time.Now(),
+			})
+			log.Info("contract failed, storage proof skipped", zap.String("reason", contract.ProofSkipReason), zap.String("validPayout", validPayout.ExactString()), zap.String("missedPayout", missedPayout.ExactString()))
 		case validPayout.Cmp(missedPayout) > 0 && contract.ResolutionHeight == 0:
 			// if the host valid payout is greater than the missed payout and a
 			// proof was not broadcast, the contract failed
-			if err := cm.store.ExpireContract(id, ContractStatusFailed); err != nil {
+			failure, err := cm.proofFailure(id, height)
+			if err != nil {
+				log.Error("failed to determine failure reason", zap.Error(err))
+				return failed(err)
+			} else if err := cm.store.RecordProofFailure(failure); err != nil {
 				log.Error("failed to set contract status", zap.Error(err))
+				return failed(err)
 			}
-			registerContractAlert(alerts.SeverityError, "Contract failed without storage proof", nil)
-			log.Error("contract failed, revenue lost", zap.Uint64("windowStart", contract.Revision.WindowStart), zap.Uint64("windowEnd", contract.Revision.WindowEnd), zap.String("validPayout", validPayout.ExactString()), zap.String("missedPayout", missedPayout.ExactString()))
+			cm.alerts.Register(alerts.Alert{
+				ID:       types.Hash256(id),
+				Severity: alerts.SeverityCritical,
+				Message:  "Contract failed without storage proof",
+				Data: map[string]any{
+					"contractID":  id,
+					"blockHeight": height,
+					"cause":       failure.Cause,
+					"reason":      failure.Reason,
+				},
+				Timestamp: time.Now(),
+			})
+			cm.alertProofFailures()
+			cm.checkProofBreaker(failure)
+			log.Error("contract failed, revenue lost", zap.String("cause", string(failure.Cause)), zap.String("reason", failure.Reason), zap.Uint64("windowStart", contract.Revision.WindowStart), zap.Uint64("windowEnd", contract.Revision.WindowEnd), zap.String("validPayout", validPayout.ExactString()), zap.String("missedPayout", missedPayout.ExactString()))
 		default:
 			log.Panic("unrecognized contract state", zap.Stack("stack"), zap.String("validPayout", validPayout.ExactString()), zap.String("missedPayout", missedPayout.ExactString()), zap.Uint64("resolutionHeight", contract.ResolutionHeight), zap.Bool("formationConfirmed", contract.FormationConfirmed))
 		}
@@ -285,4 +585,5 @@ func (cm *ContractManager) handleContractAction(id types.FileContractID, height
 		log.Panic("unrecognized contract action", zap.Stack("stack"))
 	}
 	log.Debug("contract action completed", zap.Duration("elapsed", time.Since(start)))
+	return result
 }
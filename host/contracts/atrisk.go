@@ -0,0 +1,107 @@
+package contracts
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+)
+
+// maxAlertContracts is the maximum number of contracts listed in a volume's
+// at-risk alert. The alert reports totals for all of the affected contracts.
+const maxAlertContracts = 25
+
+// A VolumeContract is an active or pending contract with sectors stored in a
+// volume. If the volume is lost, the contract's storage proof may fail and
+// the risked collateral is burned.
+type VolumeContract struct {
+	ContractID       types.FileContractID `json:"contractID"`
+	Status           ContractStatus       `json:"status"`
+	WindowStart      uint64               `json:"windowStart"`
+	WindowEnd        uint64               `json:"windowEnd"`
+	Sectors          uint64               `json:"sectors"`
+	LockedCollateral types.Currency       `json:"lockedCollateral"`
+	RiskedCollateral types.Currency       `json:"riskedCollateral"`
+}
+
+// ContractsOnVolume returns the active and pending contracts with sectors
+// stored in the volume, ordered by the start of their proof window.
+func (cm *ContractManager) ContractsOnVolume(volumeID int64) ([]VolumeContract, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	counts, err := cm.store.VolumeContracts(volumeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume contracts: %w", err)
+	}
+
+	results := make([]VolumeContract, 0, len(counts))
+	for id, sectors := range counts {
+		contract, err := cm.store.Contract(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get contract %v: %w", id, err)
+		}
+		results = append(results, VolumeContract{
+			ContractID:       id,
+			Status:           contract.Status,
+			WindowStart:      contract.Revision.WindowStart,
+			WindowEnd:        contract.Revision.WindowEnd,
+			Sectors:          sectors,
+			LockedCollateral: contract.LockedCollateral,
+			RiskedCollateral: contract.Usage.RiskedCollateral,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].WindowStart != results[j].WindowStart {
+			return results[i].WindowStart < results[j].WindowStart
+		}
+		return results[i].ContractID.String() < results[j].ContractID.String()
+	})
+	return results, nil
+}
+
+// AlertContractsOnVolume registers a critical alert listing the contracts
+// affected by an unavailable volume along with their proof windows and the
+// collateral at risk. Only the contracts with the earliest proof windows are
+// listed; the totals cover every affected contract. No alert is registered if
+// no contracts are affected.
+func (cm *ContractManager) AlertContractsOnVolume(volumeID int64, localPath string) error {
+	affected, err := cm.ContractsOnVolume(volumeID)
+	if err != nil {
+		return err
+	} else if len(affected) == 0 {
+		return nil
+	}
+
+	var atRisk types.Currency
+	var sectors uint64
+	for _, c := range affected {
+		atRisk = atRisk.Add(c.RiskedCollateral)
+		sectors += c.Sectors
+	}
+	listed := affected
+	if len(listed) > maxAlertContracts {
+		listed = listed[:maxAlertContracts]
+	}
+	cm.alerts.Register(alerts.Alert{
+		ID:       types.HashBytes([]byte(fmt.Sprintf("volumeContractsAtRisk-%d", volumeID))),
+		Severity: alerts.SeverityCritical,
+		Message:  "Contracts are at risk due to an unavailable volume",
+		Data: map[string]any{
+			"volume":           localPath,
+			"volumeID":         volumeID,
+			"contracts":        listed,
+			"totalContracts":   len(affected),
+			"totalSectors":     sectors,
+			"nextWindowStart":  affected[0].WindowStart,
+			"collateralAtRisk": atRisk,
+		},
+		Timestamp: time.Now(),
+	})
+	return nil
+}
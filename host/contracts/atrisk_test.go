@@ -0,0 +1,178 @@
+package contracts_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestContractsOnUnavailableVolume(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewWallet(hostKey, dir, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error, 1)
+	volumePath := filepath.Join(dir, "data.dat")
+	vol, err := s.AddVolume(context.Background(), volumePath, 10, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rev.Revision.ParentID
+
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	contract, err := c.Contract(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updater, err := c.ReviseContract(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updater.Close()
+
+	var roots []types.Hash256
+	for i := 0; i < 3; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:])
+		root := rhp2.SectorRoot(&sector)
+		release, err := s.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+
+		updater.AppendSector(root)
+		roots = append(roots, root)
+	}
+
+	risked := types.Siacoins(1)
+	contract.Revision.RevisionNumber++
+	contract.Revision.Filesize = uint64(len(roots)) * rhp2.SectorSize
+	contract.Revision.FileMerkleRoot = rhp2.MetaRoot(roots)
+	if err := updater.Commit(contract.SignedRevision, contracts.Usage{RiskedCollateral: risked}); err != nil {
+		t.Fatal(err)
+	}
+	updater.Close()
+
+	// make the volume unavailable and reload the volumes
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := os.Rename(volumePath, volumePath+".bak"); err != nil {
+		t.Fatal(err)
+	}
+
+	s2, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+
+	if meta, err := s2.Volume(vol.ID); err != nil {
+		t.Fatal(err)
+	} else if meta.Available {
+		t.Fatal("expected volume to be unavailable")
+	}
+
+	affected, err := c.ContractsOnVolume(vol.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(affected) != 1 {
+		t.Fatalf("expected 1 affected contract, got %v", len(affected))
+	}
+	switch vc := affected[0]; {
+	case vc.ContractID != id:
+		t.Fatalf("expected contract %v, got %v", id, vc.ContractID)
+	case vc.Sectors != uint64(len(roots)):
+		t.Fatalf("expected %v sectors, got %v", len(roots), vc.Sectors)
+	case vc.WindowStart != contract.Revision.WindowStart:
+		t.Fatalf("expected window start %v, got %v", contract.Revision.WindowStart, vc.WindowStart)
+	case !vc.RiskedCollateral.Equals(risked):
+		t.Fatalf("expected risked collateral %v, got %v", risked, vc.RiskedCollateral)
+	}
+
+	// subscribing should immediately report the unavailable volume
+	var notified []int64
+	err = s2.OnVolumeUnavailable(func(volumeID int64, localPath string) {
+		notified = append(notified, volumeID)
+		if err := c.AlertContractsOnVolume(volumeID, localPath); err != nil {
+			t.Error(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(notified) != 1 || notified[0] != vol.ID {
+		t.Fatalf("expected volume %v to be reported, got %v", vol.ID, notified)
+	}
+
+	var found bool
+	for _, a := range am.Active() {
+		if a.Severity != alerts.SeverityCritical || a.Data["volumeID"] != vol.ID {
+			continue
+		}
+		found = true
+		if ids := a.Data["contracts"].([]contracts.VolumeContract); len(ids) != 1 || ids[0].ContractID != id {
+			t.Fatalf("expected alert to list contract %v, got %v", id, ids)
+		} else if a.Data["totalContracts"] != 1 || a.Data["totalSectors"] != uint64(len(roots)) {
+			t.Fatalf("expected totals of 1 contract and %v sectors, got %v and %v", len(roots), a.Data["totalContracts"], a.Data["totalSectors"])
+		} else if !a.Data["collateralAtRisk"].(types.Currency).Equals(risked) {
+			t.Fatalf("expected %v collateral at risk, got %v", risked, a.Data["collateralAtRisk"])
+		}
+	}
+	if !found {
+		t.Fatal("expected a critical alert for the unavailable volume")
+	}
+}
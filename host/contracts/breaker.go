@@ -0,0 +1,181 @@
+package contracts
+
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+)
+
+// proofBreakerAlertID is the ID of the alert registered while the proof
+// failure circuit breaker is tripped.
+var proofBreakerAlertID = types.HashBytes([]byte("proofBreaker"))
+
+// A ProofBreakerState is the state of the proof failure circuit breaker.
+type ProofBreakerState struct {
+	Enabled   bool          `json:"enabled"`
+	Tripped   bool          `json:"tripped"`
+	TrippedAt time.Time     `json:"trippedAt,omitempty"`
+	Threshold int           `json:"threshold"`
+	Window    time.Duration `json:"window"`
+	// Failures is the number of proof failures within the window since the
+	// breaker was last reset.
+	Failures int `json:"failures"`
+}
+
+// recentBreakerFailures returns the number of proof failures within the
+// breaker window. The caller must hold cm.mu.
+func (cm *ContractManager) recentBreakerFailures() int {
+	cutoff := time.Now().Add(-cm.breakerWindow)
+	i := 0
+	for i < len(cm.breakerFailures) && cm.breakerFailures[i].Before(cutoff) {
+		i++
+	}
+	cm.breakerFailures = cm.breakerFailures[i:]
+	return len(cm.breakerFailures)
+}
+
+// loadProofBreaker restores the circuit breaker's state from the store. The
+// failures within the window that were recorded after the last reset are
+// counted towards the threshold again.
+func (cm *ContractManager) loadProofBreaker() error {
+	if cm.breakerThreshold <= 0 {
+		return nil
+	}
+
+	trippedAt, resetAt, err := cm.store.ProofBreaker()
+	if err != nil {
+		return fmt.Errorf("failed to get proof breaker state: %w", err)
+	}
+	since := time.Now().Add(-cm.breakerWindow)
+	if resetAt.After(since) {
+		since = resetAt
+	}
+	failures, err := cm.store.ProofFailures(since)
+	if err != nil {
+		return fmt.Errorf("failed to get proof failures: %w", err)
+	}
+
+	cm.mu.Lock()
+	cm.breakerTrippedAt, cm.breakerResetAt = trippedAt, resetAt
+	for _, failure := range failures {
+		// timestamps are stored with second precision, ignore failures
+		// that may have been recorded before the reset
+		if !failure.Timestamp.After(resetAt) {
+			continue
+		}
+		cm.breakerFailures = append(cm.breakerFailures, failure.Timestamp)
+	}
+	n := cm.recentBreakerFailures()
+	cm.mu.Unlock()
+
+	if !trippedAt.IsZero() {
+		cm.log.Warn("proof failure circuit breaker is tripped", zap.Time("trippedAt", trippedAt))
+		cm.registerBreakerAlert(n)
+	}
+	return nil
+}
+
+// registerBreakerAlert registers the alert shown while the circuit breaker is
+// tripped.
+func (cm *ContractManager) registerBreakerAlert(failures int) {
+	cm.alerts.Register(alerts.Alert{
+		ID:       proofBreakerAlertID,
+		Severity: alerts.SeverityCritical,
+		Message:  "Contract formation paused due to repeated proof failures",
+		Data: map[string]any{
+			"failures":  failures,
+			"threshold": cm.breakerThreshold,
+			"window":    cm.breakerWindow.String(),
+			"hint":      "Investigate the proof failures, then reset the breaker to resume accepting contracts",
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// checkProofBreaker records a proof failure and trips the circuit breaker if
+// the threshold is reached within the window.
+func (cm *ContractManager) checkProofBreaker(failure ProofFailure) {
+	if cm.breakerThreshold <= 0 {
+		return
+	}
+
+	cm.mu.Lock()
+	cm.breakerFailures = append(cm.breakerFailures, failure.Timestamp)
+	failures := cm.recentBreakerFailures()
+	trip := cm.breakerTrippedAt.IsZero() && failures >= cm.breakerThreshold
+	if trip {
+		cm.breakerTrippedAt = time.Now()
+	}
+	trippedAt, resetAt := cm.breakerTrippedAt, cm.breakerResetAt
+	fns := cm.breakerFns
+	cm.mu.Unlock()
+
+	if !trip {
+		return
+	}
+
+	cm.log.Error("proof failure circuit breaker tripped", zap.Int("failures", failures), zap.Duration("window", cm.breakerWindow))
+	if err := cm.store.SetProofBreaker(trippedAt, resetAt); err != nil {
+		cm.log.Error("failed to persist proof breaker state", zap.Error(err))
+	}
+	cm.registerBreakerAlert(failures)
+	for _, fn := range fns {
+		fn(true)
+	}
+}
+
+// OnProofBreaker registers a function that is called when the proof failure
+// circuit breaker is tripped or reset. If the breaker is already tripped, the
+// function is called immediately. The function should not block.
+func (cm *ContractManager) OnProofBreaker(fn func(tripped bool)) {
+	cm.mu.Lock()
+	cm.breakerFns = append(cm.breakerFns, fn)
+	tripped := !cm.breakerTrippedAt.IsZero()
+	cm.mu.Unlock()
+
+	if tripped {
+		fn(true)
+	}
+}
+
+// ProofBreaker returns the state of the proof failure circuit breaker.
+func (cm *ContractManager) ProofBreaker() ProofBreakerState {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return ProofBreakerState{
+		Enabled:   cm.breakerThreshold > 0,
+		Tripped:   !cm.breakerTrippedAt.IsZero(),
+		TrippedAt: cm.breakerTrippedAt,
+		Threshold: cm.breakerThreshold,
+		Window:    cm.breakerWindow,
+		Failures:  cm.recentBreakerFailures(),
+	}
+}
+
+// ResetProofBreaker resets the proof failure circuit breaker. Failures
+// recorded before the reset no longer count towards the threshold.
+func (cm *ContractManager) ResetProofBreaker() {
+	cm.mu.Lock()
+	tripped := !cm.breakerTrippedAt.IsZero()
+	cm.breakerTrippedAt = time.Time{}
+	cm.breakerResetAt = time.Now()
+	cm.breakerFailures = nil
+	resetAt := cm.breakerResetAt
+	fns := cm.breakerFns
+	cm.mu.Unlock()
+
+	if err := cm.store.SetProofBreaker(time.Time{}, resetAt); err != nil {
+		cm.log.Error("failed to persist proof breaker state", zap.Error(err))
+	}
+	if !tripped {
+		return
+	}
+	cm.log.Info("proof failure circuit breaker reset")
+	cm.alerts.Dismiss(proofBreakerAlertID)
+	for _, fn := range fns {
+		fn(false)
+	}
+}
@@ -0,0 +1,199 @@
+package contracts_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestProofBreaker(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	cm := node.ChainManager()
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"), contracts.WithProofBreaker(2, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tripped := make(chan bool, 10)
+	c.OnProofBreaker(func(b bool) { tripped <- b })
+
+	waitForScan := func() {
+		for cm.TipState().Index.Height != c.ScanHeight() {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	// restart checks the breaker's state as loaded by a new contract manager
+	restart := func() contracts.ProofBreakerState {
+		t.Helper()
+		c2, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("restart"), contracts.WithProofBreaker(2, time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c2.Close()
+		return c2.ProofBreaker()
+	}
+
+	breakerAlert := func() bool {
+		for _, a := range am.Active() {
+			if a.Severity == alerts.SeverityCritical && a.Message == "Contract formation paused due to repeated proof failures" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	waitForScan()
+
+	// form contracts that the host cannot prove
+	height := cm.TipState().Index.Height
+	var revisions []contracts.SignedRevision
+	for i := 0; i < 2; i++ {
+		renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+		rev, err := formContract(renterKey, hostKey, height+30, height+40, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := s.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		collateral := types.NewCurrency64(200)
+		rev.Revision.RevisionNumber++
+		rev.Revision.Filesize = rhp2.SectorSize
+		rev.Revision.FileMerkleRoot = frand.Entropy256() // corrupt the file merkle root so the blockchain rejects the proof
+		rev.Revision.MissedProofOutputs[1].Value = rev.Revision.MissedProofOutputs[1].Value.Sub(collateral)
+		rev.Revision.MissedProofOutputs[2].Value = rev.Revision.MissedProofOutputs[2].Value.Add(collateral)
+		sigHash := hashRevision(rev.Revision)
+		rev.HostSignature = hostKey.SignHash(sigHash)
+		rev.RenterSignature = renterKey.SignHash(sigHash)
+
+		updater, err := c.ReviseContract(rev.Revision.ParentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updater.AppendSector(root)
+		if err := updater.Commit(rev, contracts.Usage{RiskedCollateral: collateral}); err != nil {
+			t.Fatal(err)
+		}
+		updater.Close()
+		if err := release(); err != nil {
+			t.Fatal(err)
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if state := c.ProofBreaker(); !state.Enabled || state.Tripped {
+		t.Fatalf("expected enabled, untripped breaker, got %+v", state)
+	}
+
+	// mine until after the proof window
+	remainingBlocks := revisions[0].Revision.WindowEnd - cm.TipState().Index.Height + 1
+	if err := node.MineBlocks(types.VoidAddress, int(remainingBlocks)); err != nil {
+		t.Fatal(err)
+	}
+	waitForScan()
+	time.Sleep(time.Second) // sync time
+
+	for _, rev := range revisions {
+		contract, err := c.Contract(rev.Revision.ParentID)
+		if err != nil {
+			t.Fatal(err)
+		} else if contract.Status != contracts.ContractStatusFailed {
+			t.Fatalf("expected contract to be failed, got %q", contract.Status)
+		}
+	}
+
+	// the second failure should trip the breaker
+	select {
+	case b := <-tripped:
+		if !b {
+			t.Fatal("expected breaker to trip")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected breaker to trip")
+	}
+	if state := c.ProofBreaker(); !state.Tripped || state.TrippedAt.IsZero() {
+		t.Fatalf("expected tripped breaker, got %+v", state)
+	} else if state.Failures != 2 {
+		t.Fatalf("expected 2 failures, got %v", state.Failures)
+	} else if !breakerAlert() {
+		t.Fatal("expected breaker alert")
+	}
+
+	// the breaker should stay tripped after a restart
+	if state := restart(); !state.Tripped || state.Failures != 2 {
+		t.Fatalf("expected tripped breaker after restart, got %+v", state)
+	}
+
+	// the breaker stays tripped until it is reset
+	c.ResetProofBreaker()
+	select {
+	case b := <-tripped:
+		if b {
+			t.Fatal("expected breaker to reset")
+		}
+	default:
+		t.Fatal("expected breaker to reset")
+	}
+	if state := c.ProofBreaker(); state.Tripped || state.Failures != 0 {
+		t.Fatalf("expected reset breaker, got %+v", state)
+	} else if breakerAlert() {
+		t.Fatal("expected breaker alert to be dismissed")
+	}
+
+	// failures before the reset should not count after a restart
+	if state := restart(); state.Tripped || state.Failures != 0 {
+		t.Fatalf("expected reset breaker after restart, got %+v", state)
+	}
+}
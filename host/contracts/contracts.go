@@ -99,12 +99,39 @@ type (
 		// at. If the contract has not been resolved, the field is the zero
 		// value.
 		ResolutionHeight uint64 `json:"resolutionHeight"`
+		// ResolutionID is the ID of the block that confirmed the storage
+		// proof. If the contract has not been resolved, the field is the
+		// zero value.
+		ResolutionID types.BlockID `json:"resolutionID"`
+		// ResolutionTxnID is the ID of the transaction that confirmed the
+		// storage proof. If the contract has not been resolved, the field is
+		// the zero value.
+		ResolutionTxnID types.TransactionID `json:"resolutionTxnID"`
 		// RenewedTo is the ID of the contract that renewed this contract. If
 		// this contract was not renewed, this field is the zero value.
 		RenewedTo types.FileContractID `json:"renewedTo"`
 		// RenewedFrom is the ID of the contract that this contract renewed. If
 		// this contract is not a renewal, the field is the zero value.
 		RenewedFrom types.FileContractID `json:"renewedFrom"`
+		// FailureReason is the reason the contract failed. It is only set
+		// for failed contracts and contracts rejected because the renter
+		// double spent their formation.
+		FailureReason string `json:"failureReason,omitempty"`
+		// Pinned is true if the contract is excluded from pruning.
+		Pinned bool `json:"pinned"`
+		// Quarantined is true if renter RPCs against the contract are
+		// rejected. The host still submits the contract's storage proof.
+		Quarantined bool `json:"quarantined"`
+		// QuarantineReason is the reason the operator gave for quarantining
+		// the contract.
+		QuarantineReason string `json:"quarantineReason,omitempty"`
+		// ProofSkipReason is the reason the host decided not to submit the
+		// contract's storage proof. It is cleared if the host later
+		// attempts the proof.
+		ProofSkipReason string `json:"proofSkipReason,omitempty"`
+		// Tags are the labels the operator attached to the contract, sorted
+		// alphabetically.
+		Tags []string `json:"tags,omitempty"`
 	}
 
 	// ContractFilter defines the filter criteria for a contract query.
@@ -115,6 +142,8 @@ type (
 		RenewedFrom []types.FileContractID `json:"renewedFrom"`
 		RenewedTo   []types.FileContractID `json:"renewedTo"`
 		RenterKey   []types.PublicKey      `json:"renterKey"`
+		// Tags matches contracts with at least one of the tags.
+		Tags []string `json:"tags"`
 
 		MinNegotiationHeight uint64 `json:"minNegotiationHeight"`
 		MaxNegotiationHeight uint64 `json:"maxNegotiationHeight"`
@@ -122,9 +151,13 @@ type (
 		MinExpirationHeight uint64 `json:"minExpirationHeight"`
 		MaxExpirationHeight uint64 `json:"maxExpirationHeight"`
 
-		// pagination
-		Limit  int `json:"limit"`
-		Offset int `json:"offset"`
+		// pagination. Limit is capped at MaxContractsLimit. If After is
+		// set, only the contracts sorted after it are returned, which stays
+		// cheap at any depth; pass the last contract of the previous page.
+		// Offset is applied after After.
+		After  types.FileContractID `json:"after"`
+		Limit  int                  `json:"limit"`
+		Offset int                  `json:"offset"`
 
 		// sorting
 		SortField string `json:"sortField"`
@@ -147,6 +180,7 @@ type (
 		rootsCache *lru.TwoQueueCache[types.FileContractID, []types.Hash256] // reference to the cache in the contract manager
 		once       sync.Once
 		done       func() // done is called when the updater is closed.
+		onCommit   func(types.FileContractRevision)
 
 		contractID    types.FileContractID
 		sectorActions []SectorChange
@@ -155,6 +189,14 @@ type (
 	}
 )
 
+// MaxContractsLimit is the maximum number of contracts returned by a single
+// contract query. Larger limits are reduced to it so listing contracts
+// on a host with many contracts stays cheap.
+const MaxContractsLimit = 500
+
+// MaxTagLength is the maximum length of a contract tag in bytes.
+const MaxTagLength = 64
+
 var (
 	// ErrNotFound is returned by the contract store when a contract is not
 	// found.
@@ -162,8 +204,34 @@ var (
 	// ErrContractExists is returned by the contract store during formation when
 	// the contract already exists.
 	ErrContractExists = errors.New("contract already exists")
+	// ErrRenewalNotBroadcast is returned when a renewal's transaction set
+	// is rejected by the transaction pool. The renewal is not recorded.
+	ErrRenewalNotBroadcast = errors.New("failed to broadcast renewal transaction")
+	// ErrRenewalRevised is returned when reverting a pending renewal whose
+	// sector roots were changed after the renewal. The existing contract
+	// cannot be restored without losing the renter's changes.
+	ErrRenewalRevised = errors.New("renewal has been revised")
+	// ErrContractQuarantined is returned when a renter attempts to use a
+	// contract that has been quarantined by the host.
+	ErrContractQuarantined = errors.New("contract is quarantined")
+	// ErrInvalidTag is returned when a contract tag is empty or longer than
+	// MaxTagLength.
+	ErrInvalidTag = errors.New("invalid contract tag")
+	// ErrSectorIndexOutOfRange is returned when a sector index is not less
+	// than the number of sectors in a contract.
+	ErrSectorIndexOutOfRange = errors.New("sector index out of range")
 )
 
+// Revenue returns the total revenue earned by the host.
+func (u Usage) Revenue() types.Currency {
+	return u.RPCRevenue.
+		Add(u.StorageRevenue).
+		Add(u.EgressRevenue).
+		Add(u.IngressRevenue).
+		Add(u.RegistryRead).
+		Add(u.RegistryWrite)
+}
+
 // Add returns the sum of two usages.
 func (u Usage) Add(b Usage) (c Usage) {
 	return Usage{
@@ -340,6 +408,9 @@ func (cu *ContractUpdater) Commit(revision SignedRevision, usage Usage) error {
 	cu.sectorActions = cu.sectorActions[:0]
 	// update the roots cache
 	cu.rootsCache.Add(revision.Revision.ParentID, append([]types.Hash256(nil), cu.sectorRoots...))
+	if cu.onCommit != nil {
+		cu.onCommit(revision.Revision)
+	}
 	cu.log.Debug("contract update committed", zap.String("contractID", revision.Revision.ParentID.String()), zap.Uint64("revision", revision.Revision.RevisionNumber), zap.Duration("elapsed", time.Since(start)))
 	return nil
 }
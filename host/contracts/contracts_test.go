@@ -218,3 +218,43 @@ func TestUsageAdd(t *testing.T) {
 		}
 	}
 }
+
+func TestProofSubmissionSpread(t *testing.T) {
+	const (
+		windowStart = 1000
+		windowEnd   = 1144
+		n           = 1000
+	)
+
+	heights := make(map[uint64]int)
+	for i := 0; i < n; i++ {
+		id := frand.Entropy256()
+		if height := contracts.ProofStrategyImmediate.SubmissionHeight(id, windowStart, windowEnd); height != windowStart {
+			t.Fatalf("expected immediate submission at %v, got %v", windowStart, height)
+		}
+
+		height := contracts.ProofStrategySpread.SubmissionHeight(id, windowStart, windowEnd)
+		if height < windowStart || height >= windowStart+(windowEnd-windowStart)/2 {
+			t.Fatalf("submission height %v outside of the first half of the window [%v, %v)", height, windowStart, windowEnd)
+		} else if height != contracts.ProofStrategySpread.SubmissionHeight(id, windowStart, windowEnd) {
+			t.Fatal("expected submission height to be deterministic")
+		}
+		heights[height]++
+	}
+
+	// submissions should be distributed across the window rather than
+	// bunched at the start
+	if len(heights) < (windowEnd-windowStart)/4 {
+		t.Fatalf("expected submissions to be spread across the window, only %v distinct heights", len(heights))
+	}
+	for height, count := range heights {
+		if count > n/10 {
+			t.Fatalf("expected submissions to be distributed, %v submissions at height %v", count, height)
+		}
+	}
+
+	// a window too small to spread should submit immediately
+	if height := contracts.ProofStrategySpread.SubmissionHeight(frand.Entropy256(), windowStart, windowStart+1); height != windowStart {
+		t.Fatalf("expected submission at %v, got %v", windowStart, height)
+	}
+}
@@ -0,0 +1,146 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+)
+
+const (
+	// DoubleSpendReject rejects a pending contract as soon as its formation
+	// inputs are spent by a conflicting transaction.
+	DoubleSpendReject DoubleSpendPolicy = iota
+	// DoubleSpendAlert registers an alert, but leaves the contract pending
+	// until it is rejected by the normal contract lifecycle.
+	DoubleSpendAlert
+)
+
+// A DoubleSpendPolicy determines how the host handles a pending contract whose
+// formation inputs were spent by a conflicting transaction.
+type DoubleSpendPolicy uint8
+
+// String implements fmt.Stringer.
+func (dp DoubleSpendPolicy) String() string {
+	switch dp {
+	case DoubleSpendReject:
+		return "reject"
+	case DoubleSpendAlert:
+		return "alert"
+	default:
+		return "unknown"
+	}
+}
+
+// A doubleSpend is a pending contract whose formation inputs were spent by a
+// transaction outside of its formation set.
+type doubleSpend struct {
+	contract    Contract
+	transaction types.TransactionID
+	outputs     []types.SiacoinOutputID
+}
+
+// findDoubleSpends returns the pending contracts with a formation input spent
+// by a transaction that is not part of the contract's formation set.
+func (cm *ContractManager) findDoubleSpends(spent map[types.SiacoinOutputID]types.TransactionID) ([]doubleSpend, error) {
+	outputs := make([]types.SiacoinOutputID, 0, len(spent))
+	for id := range spent {
+		outputs = append(outputs, id)
+	}
+	spends, err := cm.store.PendingFormationSpends(outputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending contracts spending outputs: %w", err)
+	}
+
+	var conflicts []doubleSpend
+	for id := range spends {
+		// only the contracts with a spent input are loaded. A formation set
+		// spends its own inputs when it is confirmed.
+		formationSet, err := cm.store.ContractFormationSet(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get formation set for %v: %w", id, err)
+		}
+		inSet := make(map[types.TransactionID]bool, len(formationSet))
+		for _, txn := range formationSet {
+			inSet[txn.ID()] = true
+		}
+
+		var conflict *doubleSpend
+		for _, txn := range formationSet {
+			for _, sci := range txn.SiacoinInputs {
+				spender, ok := spent[sci.ParentID]
+				if !ok || inSet[spender] {
+					continue
+				} else if conflict == nil {
+					contract, err := cm.store.Contract(id)
+					if err != nil {
+						return nil, fmt.Errorf("failed to get contract %v: %w", id, err)
+					}
+					conflict = &doubleSpend{contract: contract, transaction: spender}
+				}
+				conflict.outputs = append(conflict.outputs, sci.ParentID)
+			}
+		}
+		if conflict != nil {
+			conflicts = append(conflicts, *conflict)
+		}
+	}
+	return conflicts, nil
+}
+
+// handleDoubleSpends checks whether any pending contract's formation inputs
+// were spent by a conflicting transaction. Depending on the double spend
+// policy, the contracts are rejected immediately. The host's own inputs are
+// released by the wallet when the transaction pool drops the formation set.
+func (cm *ContractManager) handleDoubleSpends(spent map[types.SiacoinOutputID]types.TransactionID) error {
+	if len(spent) == 0 {
+		return nil
+	}
+
+	conflicts, err := cm.findDoubleSpends(spent)
+	if err != nil {
+		return err
+	}
+
+	for _, ds := range conflicts {
+		id := ds.contract.Revision.ParentID
+		renterKey := ds.contract.RenterKey()
+		log := cm.log.Named("doubleSpend").With(zap.Stringer("contractID", id), zap.Stringer("renterKey", renterKey), zap.Stringer("transactionID", ds.transaction))
+		log.Warn("contract formation inputs spent by a conflicting transaction", zap.Stringers("outputs", ds.outputs), zap.Stringer("policy", cm.doubleSpendPolicy))
+
+		if cm.doubleSpendPolicy == DoubleSpendReject {
+			reason := fmt.Sprintf("formation inputs double spent by transaction %v", ds.transaction)
+			// a pending renewal is reverted instead of rejected to restore
+			// the existing contract
+			if err := cm.store.RevertRenewal(id); err == nil {
+				cm.rootsCache.Remove(id)
+				cm.rootsCache.Remove(ds.contract.RenewedFrom)
+				log.Info("reverted double spent renewal", zap.Stringer("renewedFrom", ds.contract.RenewedFrom))
+			} else if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrRenewalRevised) {
+				return fmt.Errorf("failed to revert renewal %v: %w", id, err)
+			} else if err := cm.store.RejectContract(id, reason); err != nil {
+				return fmt.Errorf("failed to reject contract %v: %w", id, err)
+			} else {
+				log.Info("rejected double spent contract")
+			}
+		}
+
+		cm.alerts.Register(alerts.Alert{
+			ID:       types.Hash256(id),
+			Severity: alerts.SeverityWarning,
+			Message:  "Renter double spent contract formation",
+			Data: map[string]any{
+				"contractID":  id,
+				"renterKey":   renterKey,
+				"transaction": ds.transaction,
+				"outputs":     ds.outputs,
+				"policy":      cm.doubleSpendPolicy.String(),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+	return nil
+}
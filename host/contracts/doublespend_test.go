@@ -0,0 +1,147 @@
+package contracts_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestDoubleSpendFormation(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	// create a formation transaction, but do not broadcast it
+	renterFunds, hostCollateral := types.Siacoins(500), types.Siacoins(1000)
+	fc := rhp2.PrepareContractFormation(renterKey.PublicKey(), hostKey.PublicKey(), renterFunds, hostCollateral, 50, rhp2.HostSettings{WindowSize: 10}, node.Address())
+	state := node.ChainManager().TipState()
+	formationTxn := types.Transaction{
+		FileContracts: []types.FileContract{fc},
+	}
+	toSign, discard, err := node.FundTransaction(&formationTxn, rhp2.ContractFormationCost(state, fc, types.ZeroCurrency).Add(hostCollateral))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer discard()
+	if err := node.SignTransaction(state, &formationTxn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	revision := types.FileContractRevision{
+		ParentID: formationTxn.FileContractID(0),
+		UnlockConditions: types.UnlockConditions{
+			PublicKeys: []types.UnlockKey{
+				renterKey.PublicKey().UnlockKey(),
+				hostKey.PublicKey().UnlockKey(),
+			},
+			SignaturesRequired: 2,
+		},
+		FileContract: fc,
+	}
+	revision.RevisionNumber = 1
+	sigHash := hashRevision(revision)
+	rev := contracts.SignedRevision{
+		Revision:        revision,
+		HostSignature:   hostKey.SignHash(sigHash),
+		RenterSignature: renterKey.SignHash(sigHash),
+	}
+	if err := c.AddContract(rev, []types.Transaction{formationTxn}, hostCollateral, contracts.Usage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// spend the formation inputs in a conflicting transaction
+	conflictTxn := types.Transaction{
+		SiacoinOutputs: append(append([]types.SiacoinOutput(nil), formationTxn.SiacoinOutputs...), types.SiacoinOutput{Address: node.Address(), Value: fc.Payout}),
+		MinerFees:      formationTxn.MinerFees,
+	}
+	for _, sci := range formationTxn.SiacoinInputs {
+		conflictTxn.SiacoinInputs = append(conflictTxn.SiacoinInputs, types.SiacoinInput{ParentID: sci.ParentID, UnlockConditions: sci.UnlockConditions})
+	}
+	if err := node.SignTransaction(state, &conflictTxn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
+		t.Fatal(err)
+	} else if err := node.TPool().AcceptTransactionSet([]types.Transaction{conflictTxn}); err != nil {
+		t.Fatal(err)
+	} else if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	// the contract should be rejected and its collateral released
+	contract, err := c.Contract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if contract.Status != contracts.ContractStatusRejected {
+		t.Fatalf("expected contract to be rejected, got %v", contract.Status)
+	} else if !strings.Contains(contract.FailureReason, conflictTxn.ID().String()) {
+		t.Fatalf("expected failure reason to reference the conflicting transaction, got %q", contract.FailureReason)
+	} else if m, err := node.Store().Metrics(time.Now()); err != nil {
+		t.Fatal(err)
+	} else if m.Contracts.Pending != 0 {
+		t.Fatalf("expected 0 pending contracts, got %v", m.Contracts.Pending)
+	} else if !m.Contracts.LockedCollateral.IsZero() {
+		t.Fatalf("expected 0 locked collateral, got %v", m.Contracts.LockedCollateral)
+	}
+
+	// an alert should identify the renter
+	var found bool
+	for _, alert := range am.Active() {
+		if alert.ID == types.Hash256(rev.Revision.ParentID) {
+			found = true
+			if alert.Data["renterKey"] != renterKey.PublicKey() {
+				t.Fatalf("expected alert to identify renter %v, got %v", renterKey.PublicKey(), alert.Data["renterKey"])
+			}
+		}
+	}
+	if !found {
+		t.Fatal("expected double spend alert")
+	}
+}
@@ -0,0 +1,189 @@
+package contracts
+
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+)
+
+// Proof failure causes categorize why a contract failed without a valid
+// storage proof.
+const (
+	// ProofFailureDataUnavailable indicates that the sector data needed to
+	// build the proof could not be read.
+	ProofFailureDataUnavailable ProofFailureCause = "data unavailable"
+	// ProofFailureInsufficientFunds indicates that the proof transaction
+	// could not be funded.
+	ProofFailureInsufficientFunds ProofFailureCause = "insufficient funds"
+	// ProofFailureBroadcast indicates that the proof transaction was
+	// rejected by the transaction pool.
+	ProofFailureBroadcast ProofFailureCause = "broadcast failed"
+	// ProofFailureInternal indicates that the proof transaction could not be
+	// built or signed.
+	ProofFailureInternal ProofFailureCause = "internal error"
+	// ProofFailureNotConfirmed indicates that a proof was broadcast, but was
+	// not confirmed before the proof window closed.
+	ProofFailureNotConfirmed ProofFailureCause = "not confirmed"
+	// ProofFailureNotSubmitted indicates that a proof was never attempted.
+	ProofFailureNotSubmitted ProofFailureCause = "not submitted"
+)
+
+const (
+	defaultProofFailureThreshold = 3
+	defaultProofFailureWindow    = 24 * time.Hour
+
+	// maxRecentProofFailures limits the number of failures included in a
+	// summary.
+	maxRecentProofFailures = 20
+)
+
+// proofFailureAlertID is the ID of the alert summarizing recent proof
+// failures.
+var proofFailureAlertID = types.HashBytes([]byte("proofFailures"))
+
+type (
+	// A ProofFailureCause categorizes why a contract failed without a valid
+	// storage proof.
+	ProofFailureCause string
+
+	// A ProofFailure records why a contract failed without a valid storage
+	// proof.
+	ProofFailure struct {
+		ContractID types.FileContractID `json:"contractID"`
+		Height     uint64               `json:"height"`
+		Cause      ProofFailureCause    `json:"cause"`
+		Reason     string               `json:"reason"`
+		Timestamp  time.Time            `json:"timestamp"`
+	}
+
+	// A ProofFailureSummary aggregates the proof failures within a window.
+	ProofFailureSummary struct {
+		Window time.Duration             `json:"window"`
+		Total  int                       `json:"total"`
+		Causes map[ProofFailureCause]int `json:"causes"`
+		// Recent contains the most recent failures, newest first.
+		Recent []ProofFailure `json:"recent"`
+	}
+
+	// proofError annotates an error that prevented a storage proof from
+	// being submitted with its cause.
+	proofError struct {
+		cause ProofFailureCause
+		err   error
+	}
+)
+
+// Error implements error
+func (pe *proofError) Error() string {
+	return string(pe.cause) + ": " + pe.err.Error()
+}
+
+// Unwrap returns the underlying error
+func (pe *proofError) Unwrap() error {
+	return pe.err
+}
+
+// proofFailure determines why a contract failed from the last recorded
+// resolution action.
+func (cm *ContractManager) proofFailure(id types.FileContractID, height uint64) (ProofFailure, error) {
+	failure := ProofFailure{
+		ContractID: id,
+		Height:     height,
+		Timestamp:  time.Now(),
+	}
+
+	history, err := cm.store.ContractActionResults(id)
+	if err != nil {
+		return ProofFailure{}, fmt.Errorf("failed to get action history: %w", err)
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		result := history[i]
+		if result.Action != ActionBroadcastResolution {
+			continue
+		}
+
+		switch result.Status {
+		case ActionStatusBroadcast:
+			failure.Cause = ProofFailureNotConfirmed
+			failure.Reason = fmt.Sprintf("%s: storage proof broadcast at height %d was not confirmed", ProofFailureNotConfirmed, result.Height)
+		default:
+			failure.Cause = result.Cause
+			if failure.Cause == "" {
+				// the action failed before the proof was attempted
+				failure.Cause = ProofFailureInternal
+			}
+			failure.Reason = result.Error
+		}
+		return failure, nil
+	}
+	failure.Cause = ProofFailureNotSubmitted
+	failure.Reason = string(ProofFailureNotSubmitted) + ": no storage proof was submitted during the proof window"
+	return failure, nil
+}
+
+// summarizeProofFailures aggregates a list of failures, oldest first.
+func summarizeProofFailures(failures []ProofFailure, window time.Duration) ProofFailureSummary {
+	summary := ProofFailureSummary{
+		Window: window,
+		Total:  len(failures),
+		Causes: make(map[ProofFailureCause]int),
+	}
+	for i := len(failures) - 1; i >= 0; i-- {
+		summary.Causes[failures[i].Cause]++
+		if len(summary.Recent) < maxRecentProofFailures {
+			summary.Recent = append(summary.Recent, failures[i])
+		}
+	}
+	return summary
+}
+
+// recentProofFailures returns a summary of the proof failures recorded within
+// the failure window.
+func (cm *ContractManager) recentProofFailures() (ProofFailureSummary, error) {
+	failures, err := cm.store.ProofFailures(time.Now().Add(-cm.proofFailureWindow))
+	if err != nil {
+		return ProofFailureSummary{}, fmt.Errorf("failed to get proof failures: %w", err)
+	}
+	return summarizeProofFailures(failures, cm.proofFailureWindow), nil
+}
+
+// alertProofFailures registers a critical alert summarizing the recent proof
+// failures if the threshold is reached.
+func (cm *ContractManager) alertProofFailures() {
+	if cm.proofFailureThreshold <= 0 {
+		return
+	}
+
+	summary, err := cm.recentProofFailures()
+	if err != nil {
+		cm.log.Error("failed to summarize proof failures", zap.Error(err))
+		return
+	} else if summary.Total < cm.proofFailureThreshold {
+		return
+	}
+	cm.alerts.Register(alerts.Alert{
+		ID:       proofFailureAlertID,
+		Severity: alerts.SeverityCritical,
+		Message:  "Multiple contracts failed without a storage proof",
+		Data: map[string]any{
+			"window": summary.Window.String(),
+			"total":  summary.Total,
+			"causes": summary.Causes,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// ProofFailures returns a summary of the contracts that failed without a
+// valid storage proof within the failure window.
+func (cm *ContractManager) ProofFailures() (ProofFailureSummary, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return ProofFailureSummary{}, err
+	}
+	defer done()
+	return cm.recentProofFailures()
+}
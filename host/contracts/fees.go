@@ -0,0 +1,57 @@
+package contracts
+
+import (
+	"time"
+
+	"go.sia.tech/core/types"
+)
+
+// Fee categories identify the lifecycle action a miner fee was paid for.
+const (
+	// FeeCategoryFormation is the fee paid to broadcast a formation
+	// transaction. Formation fees are currently paid by the renter.
+	FeeCategoryFormation = "formation"
+	// FeeCategoryRevision is the fee paid to broadcast a final revision.
+	FeeCategoryRevision = "revision"
+	// FeeCategoryProof is the fee paid to broadcast a storage proof.
+	FeeCategoryProof = "proof"
+)
+
+type (
+	// A FeeExpenditure is a miner fee paid by the host to broadcast a
+	// contract transaction.
+	FeeExpenditure struct {
+		ContractID    types.FileContractID `json:"contractID"`
+		Category      string               `json:"category"`
+		TransactionID types.TransactionID  `json:"transactionID"`
+		Amount        types.Currency       `json:"amount"`
+		Timestamp     time.Time            `json:"timestamp"`
+	}
+
+	// A FeeTotal is the sum of the fees paid for a category.
+	FeeTotal struct {
+		Category string         `json:"category"`
+		Count    int            `json:"count"`
+		Total    types.Currency `json:"total"`
+	}
+)
+
+// feeCategory returns the fee category of a lifecycle action.
+func feeCategory(action string) string {
+	switch action {
+	case ActionBroadcastFormation:
+		return FeeCategoryFormation
+	case ActionBroadcastFinalRevision:
+		return FeeCategoryRevision
+	case ActionBroadcastResolution:
+		return FeeCategoryProof
+	default:
+		return action
+	}
+}
+
+// FeeExpenditures returns the miner fees paid by the host in [start, end),
+// totaled by category.
+func (cm *ContractManager) FeeExpenditures(start, end time.Time) ([]FeeTotal, error) {
+	return cm.store.FeeExpenditures(start, end)
+}
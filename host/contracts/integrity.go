@@ -15,6 +15,10 @@ import (
 	"lukechampine.com/frand"
 )
 
+// maxReportedSectors limits the number of sector roots and contracts
+// included in a contract data alert.
+const maxReportedSectors = 20
+
 type (
 	// An IntegrityResult contains the result of an integrity check for a
 	// contract sector.
@@ -65,7 +69,7 @@ func (i *IntegrityResult) UnmarshalJSON(b []byte) error {
 func (cm *ContractManager) CheckIntegrity(ctx context.Context, contractID types.FileContractID) (<-chan IntegrityResult, uint64, error) {
 	// lock the contract to ensure it doesn't get modified before the sector
 	// roots are retrieved.
-	contract, err := cm.Lock(ctx, contractID)
+	contract, err := cm.lock(ctx, contractID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to lock contract: %w", err)
 	}
@@ -110,6 +114,7 @@ func (cm *ContractManager) CheckIntegrity(ctx context.Context, contractID types.
 		defer done()
 
 		var missing, corrupt int
+		var bad []types.Hash256
 		log := cm.log.Named("integrityCheck").With(zap.String("contractID", contractID.String()))
 		for i, root := range roots {
 			select {
@@ -122,10 +127,12 @@ func (cm *ContractManager) CheckIntegrity(ctx context.Context, contractID types.
 			if err != nil { // sector read failed
 				log.Error("missing sector", zap.String("root", root.String()), zap.Error(err))
 				missing++
+				bad = append(bad, root)
 				results <- IntegrityResult{ExpectedRoot: root, Error: err}
 			} else if calculated := rhp2.SectorRoot(sector); root != calculated { // sector data corrupt
 				log.Error("corrupt sector", zap.String("root", root.String()), zap.String("actual", calculated.String()))
 				corrupt++
+				bad = append(bad, root)
 				results <- IntegrityResult{ExpectedRoot: root, ActualRoot: calculated, Error: errors.New("sector data corrupt")}
 			} else { // sector is valid
 				results <- IntegrityResult{ExpectedRoot: root, ActualRoot: calculated}
@@ -144,8 +151,78 @@ func (cm *ContractManager) CheckIntegrity(ctx context.Context, contractID types.
 		alert.Message = "Integrity check complete"
 		if corrupt > 0 || missing > 0 {
 			alert.Severity = alerts.SeverityError
+			// report every contract that references the bad sectors
+			if affected, err := cm.affectedContracts(bad); err != nil {
+				log.Error("failed to get affected contracts", zap.Error(err))
+			} else {
+				alert.Data["affectedContracts"] = affected
+			}
 		}
 		cm.alerts.Register(alert)
 	}()
 	return results, uint64(len(roots)), nil
 }
+
+// VerifyContractData reads each of a contract's sectors from disk, bypassing
+// the sector cache, and returns the roots of any that are missing,
+// unreadable, or corrupt. The host cannot produce a
+// valid storage proof for a contract with missing data, so a critical alert is
+// registered if any sectors fail verification.
+func (cm *ContractManager) VerifyContractData(id types.FileContractID) ([]types.Hash256, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// lock the contract while retrieving the sector roots so they are
+	// consistent with the latest revision
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := cm.lock(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to lock contract: %w", err)
+	}
+	roots, err := cm.getSectorRoots(id)
+	cm.Unlock(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sector roots: %w", err)
+	}
+
+	log := cm.log.Named("verifyContractData").With(zap.Stringer("contractID", id))
+	var bad []types.Hash256
+	for _, root := range roots {
+		if err := cm.storage.VerifySector(root); err != nil {
+			log.Error("bad sector", zap.Stringer("root", root), zap.Error(err))
+			bad = append(bad, root)
+		}
+	}
+
+	alertID := types.HashBytes(append([]byte("contractData"), id[:]...))
+	if len(bad) == 0 {
+		cm.alerts.Dismiss(alertID)
+		return nil, nil
+	}
+	// only the first bad sectors and affected contracts are included so
+	// the alert stays small; the totals are reported separately
+	data := map[string]any{
+		"contractID": id,
+		"missing":    len(bad),
+		"total":      len(roots),
+		"roots":      bad[:min(len(bad), maxReportedSectors)],
+	}
+	// other contracts sharing the bad sectors are also affected
+	if affected, err := cm.affectedContracts(bad); err != nil {
+		log.Error("failed to get affected contracts", zap.Error(err))
+	} else {
+		data["affectedContracts"] = affected[:min(len(affected), maxReportedSectors)]
+		data["affectedTotal"] = len(affected)
+	}
+	cm.alerts.Register(alerts.Alert{
+		ID:        alertID,
+		Severity:  alerts.SeverityCritical,
+		Message:   "Contract data missing, storage proof will fail",
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+	return bad, nil
+}
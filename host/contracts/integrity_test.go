@@ -241,3 +241,165 @@ func TestCheckIntegrity(t *testing.T) {
 		t.Fatalf("expected %v issues, got %v", 2, issues)
 	}
 }
+
+func TestVerifyContractData(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewWallet(hostKey, dir, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contract, err := c.Contract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if contract.Status != contracts.ContractStatusPending {
+		t.Fatal("expected contract to be pending")
+	}
+
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	contract, err = c.Contract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if contract.Status != contracts.ContractStatusActive {
+		t.Fatal("expected contract to be active")
+	}
+
+	updater, err := c.ReviseContract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updater.Close()
+
+	var roots []types.Hash256
+	var releases []func() error
+	for i := 0; i < 5; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := s.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+		roots = append(roots, root)
+		updater.AppendSector(root)
+	}
+
+	contract.Revision.RevisionNumber++
+	contract.Revision.Filesize = uint64(len(roots)) * rhp2.SectorSize
+	contract.Revision.FileMerkleRoot = rhp2.MetaRoot(roots)
+
+	if err := updater.Commit(contract.SignedRevision, contracts.Usage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, release := range releases {
+		if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if bad, err := c.VerifyContractData(rev.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	} else if len(bad) != 0 {
+		t.Fatalf("expected no missing sectors, got %v", len(bad))
+	}
+
+	// delete a sector
+	if err := s.RemoveSector(roots[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	bad, err := c.VerifyContractData(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(bad) != 1 {
+		t.Fatalf("expected 1 missing sector, got %v", len(bad))
+	} else if bad[0] != roots[2] {
+		t.Fatalf("expected missing sector %v, got %v", roots[2], bad[0])
+	}
+
+	// corrupt a cached sector on disk
+	if _, err := s.Read(roots[3]); err != nil {
+		t.Fatal(err)
+	}
+	loc, release, err := node.Store().SectorLocation(roots[3])
+	if err != nil {
+		t.Fatal(err)
+	} else if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "data.dat"), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := f.WriteAt(frand.Bytes(256), int64(loc.Index*rhp2.SectorSize)); err != nil {
+		t.Fatal(err)
+	} else if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the corrupt sector should be detected even though it is cached
+	bad, err = c.VerifyContractData(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(bad) != 2 {
+		t.Fatalf("expected 2 bad sectors, got %v", len(bad))
+	} else if bad[1] != roots[3] {
+		t.Fatalf("expected corrupt sector %v, got %v", roots[3], bad[1])
+	}
+
+	var critical bool
+	for _, a := range am.Active() {
+		if a.Severity == alerts.SeverityCritical {
+			critical = true
+			break
+		}
+	}
+	if !critical {
+		t.Fatal("expected a critical alert")
+	}
+}
@@ -37,12 +37,19 @@ type (
 		index types.ChainIndex
 	}
 
+	// resolutionChange is a storage proof confirmed in a block.
+	resolutionChange struct {
+		contractChange
+		txnID types.TransactionID
+	}
+
 	// ChainManager defines the interface required by the contract manager to
 	// interact with the consensus set.
 	ChainManager interface {
 		TipState() consensus.State
 		IndexAtHeight(height uint64) (types.ChainIndex, error)
 		Subscribe(s modules.ConsensusSetSubscriber, ccID modules.ConsensusChangeID, cancel <-chan struct{}) error
+		Unsubscribe(s modules.ConsensusSetSubscriber)
 	}
 
 	// A Wallet manages Siacoins and funds transactions
@@ -63,6 +70,9 @@ type (
 	StorageManager interface {
 		// Read reads a sector from the store
 		Read(root types.Hash256) (*[rhp2.SectorSize]byte, error)
+		// VerifySector reads a sector from disk, bypassing any cache, and
+		// checks that its data matches its root.
+		VerifySector(root types.Hash256) error
 	}
 
 	// Alerts registers and dismisses global alerts.
@@ -84,12 +94,25 @@ type (
 		tg    *threadgroup.ThreadGroup
 		log   *zap.Logger
 
+		// subscribeMu is held while the manager subscribes to or replays
+		// consensus changes so that it is never subscribed twice.
+		subscribeMu sync.Mutex
+
 		alerts  Alerts
 		storage StorageManager
 		chain   ChainManager
 		tpool   TransactionPool
 		wallet  Wallet
 
+		proofStrategy         ProofStrategy
+		proofFailureThreshold int
+		proofFailureWindow    time.Duration
+		releaseCollateral     bool
+		maxProofFeeRatio      float64
+		doubleSpendPolicy     DoubleSpendPolicy
+		revisionLimitWarning  uint64
+		pruneRetention        uint64
+
 		processQueue chan uint64 // signals that the contract manager should process actions for a given block height
 
 		// caches the sector roots of contracts to avoid hitting the DB
@@ -99,6 +122,13 @@ type (
 
 		mu    sync.Mutex                       // guards the following fields
 		locks map[types.FileContractID]*locker // contracts must be locked while they are being modified
+
+		breakerThreshold int
+		breakerWindow    time.Duration
+		breakerFailures  []time.Time // proof failures since the breaker was reset, oldest first
+		breakerTrippedAt time.Time
+		breakerResetAt   time.Time
+		breakerFns       []func(tripped bool)
 	}
 )
 
@@ -119,11 +149,13 @@ func (cm *ContractManager) getSectorRoots(id types.FileContractID) ([]types.Hash
 	return append([]types.Hash256(nil), roots...), nil
 }
 
-// Lock locks a contract for modification.
-func (cm *ContractManager) Lock(ctx context.Context, id types.FileContractID) (SignedRevision, error) {
+// lock locks a contract for modification and returns the contract. Unlike
+// Lock, it does not reject quarantined contracts so the host can continue to
+// meet their obligations.
+func (cm *ContractManager) lock(ctx context.Context, id types.FileContractID) (Contract, error) {
 	ctx, cancel, err := cm.tg.AddContext(ctx)
 	if err != nil {
-		return SignedRevision{}, err
+		return Contract{}, err
 	}
 	defer cancel()
 
@@ -131,10 +163,10 @@ func (cm *ContractManager) Lock(ctx context.Context, id types.FileContractID) (S
 	contract, err := cm.store.Contract(id)
 	if err != nil {
 		cm.mu.Unlock()
-		return SignedRevision{}, fmt.Errorf("failed to get contract: %w", err)
+		return Contract{}, fmt.Errorf("failed to get contract: %w", err)
 	} else if err := isGoodForModification(contract, cm.chain.TipState().Index.Height); err != nil {
 		cm.mu.Unlock()
-		return SignedRevision{}, fmt.Errorf("contract is not good for modification: %w", err)
+		return Contract{}, fmt.Errorf("contract is not good for modification: %w", err)
 	}
 
 	// if the contract isn't already locked, create a new lock
@@ -144,7 +176,7 @@ func (cm *ContractManager) Lock(ctx context.Context, id types.FileContractID) (S
 			waiters: 0,
 		}
 		cm.mu.Unlock()
-		return contract.SignedRevision, nil
+		return contract, nil
 	}
 	cm.locks[id].waiters++
 	c := cm.locks[id].c
@@ -156,16 +188,29 @@ func (cm *ContractManager) Lock(ctx context.Context, id types.FileContractID) (S
 		defer cm.mu.Unlock()
 		contract, err := cm.store.Contract(id)
 		if err != nil {
-			return SignedRevision{}, fmt.Errorf("failed to get contract: %w", err)
+			return Contract{}, fmt.Errorf("failed to get contract: %w", err)
 		} else if err := isGoodForModification(contract, cm.chain.TipState().Index.Height); err != nil {
-			return SignedRevision{}, fmt.Errorf("contract is not good for modification: %w", err)
+			return Contract{}, fmt.Errorf("contract is not good for modification: %w", err)
 		}
-		return contract.SignedRevision, nil
+		return contract, nil
 	case <-ctx.Done():
-		return SignedRevision{}, ctx.Err()
+		return Contract{}, ctx.Err()
 	}
 }
 
+// Lock locks a contract for modification by a renter. Quarantined contracts
+// are rejected with ErrContractQuarantined.
+func (cm *ContractManager) Lock(ctx context.Context, id types.FileContractID) (SignedRevision, error) {
+	contract, err := cm.lock(ctx, id)
+	if err != nil {
+		return SignedRevision{}, err
+	} else if contract.Quarantined {
+		cm.Unlock(id)
+		return SignedRevision{}, fmt.Errorf("%w: %v", ErrContractQuarantined, id)
+	}
+	return contract.SignedRevision, nil
+}
+
 // Unlock unlocks a locked contract.
 func (cm *ContractManager) Unlock(id types.FileContractID) {
 	cm.mu.Lock()
@@ -207,8 +252,10 @@ func (cm *ContractManager) AddContract(revision SignedRevision, formationSet []t
 	return nil
 }
 
-// RenewContract renews a contract. It is expected that the existing
-// contract will be cleared.
+// RenewContract renews a contract and broadcasts the renewal transaction set.
+// It is expected that the existing contract will be cleared. The renewal is
+// recorded before it is broadcast and reverted if the broadcast fails, so
+// either the full renewal is recorded or none of it is.
 func (cm *ContractManager) RenewContract(renewal SignedRevision, existing SignedRevision, formationSet []types.Transaction, lockedCollateral types.Currency, clearingUsage, initialUsage Usage) error {
 	done, err := cm.tg.Add()
 	if err != nil {
@@ -225,10 +272,39 @@ func (cm *ContractManager) RenewContract(renewal SignedRevision, existing Signed
 		return errors.New("existing contract must be cleared")
 	}
 
+	renewalID := renewal.Revision.ParentID
 	if err := cm.store.RenewContract(renewal, existing, formationSet, lockedCollateral, clearingUsage, initialUsage, cm.chain.TipState().Index.Height); err != nil {
 		return err
 	}
-	cm.log.Debug("contract renewed", zap.Stringer("renewalID", renewal.Revision.ParentID), zap.Stringer("existingID", existing.Revision.ParentID))
+	// the sector roots were moved to the renewed contract
+	cm.rootsCache.Remove(existing.Revision.ParentID)
+	cm.alerts.Dismiss(revisionLimitAlertID(existing.Revision.ParentID))
+
+	// broadcasting is the last step. If the host crashes before the renewal
+	// is confirmed as broadcast, it is rebroadcast on startup.
+	if err := cm.tpool.AcceptTransactionSet(formationSet); err != nil {
+		if err := cm.store.RevertRenewal(renewalID); err != nil {
+			cm.log.Error("failed to revert renewal", zap.Stringer("renewalID", renewalID), zap.Error(err))
+		}
+		cm.rootsCache.Remove(renewalID)
+		return fmt.Errorf("%w: %v", ErrRenewalNotBroadcast, err)
+	} else if err := cm.store.ConfirmRenewal(renewalID); err != nil {
+		// not fatal, the renewal is confirmed on startup or when the
+		// formation is confirmed
+		cm.log.Error("failed to confirm renewal", zap.Stringer("renewalID", renewalID), zap.Error(err))
+	}
+	cm.log.Debug("contract renewed", zap.Stringer("renewalID", renewalID), zap.Stringer("existingID", existing.Revision.ParentID))
+
+	if cm.releaseCollateral {
+		// the clearing revision has identical valid and missed outputs, so
+		// the host cannot lose its collateral once the renewal is
+		// confirmed. Until then the renewal can still be reverted, which
+		// adds the collateral back. Failing to release it early is not
+		// fatal, it will be released when the contract expires.
+		if err := cm.store.ReleaseCollateral(existing.Revision.ParentID); err != nil {
+			cm.log.Error("failed to release collateral", zap.Stringer("contractID", existing.Revision.ParentID), zap.Error(err))
+		}
+	}
 	return nil
 }
 
@@ -243,6 +319,28 @@ func (cm *ContractManager) SectorRoots(id types.FileContractID) ([]types.Hash256
 	return cm.getSectorRoots(id)
 }
 
+// ContractsForSector returns the IDs of the contracts that would be affected
+// if the sector with the given root were lost.
+func (cm *ContractManager) ContractsForSector(root types.Hash256) ([]types.FileContractID, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return cm.store.SectorContracts(root)
+}
+
+// affectedContracts returns the IDs of every contract referencing any of the
+// given sectors.
+func (cm *ContractManager) affectedContracts(roots []types.Hash256) ([]types.FileContractID, error) {
+	affected, err := cm.store.SectorContracts(roots...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contracts for sectors: %w", err)
+	}
+	return affected, nil
+}
+
 // ScanHeight returns the height of the last block processed by the contract
 func (cm *ContractManager) ScanHeight() uint64 {
 	return atomic.LoadUint64(&cm.blockHeight)
@@ -285,14 +383,23 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 		blockHeight--
 	}
 
-	var appliedFormations, appliedResolutions []contractChange
+	var appliedFormations []contractChange
+	var appliedResolutions []resolutionChange
 	appliedRevisions := make(map[types.FileContractID]types.FileContractRevision)
+	// spentInputs maps the siacoin outputs spent in the applied blocks to the
+	// transaction that spent them
+	spentInputs := make(map[types.SiacoinOutputID]types.TransactionID)
 	for _, applied := range cc.AppliedBlocks {
 		index := types.ChainIndex{
 			Height: blockHeight,
 			ID:     types.BlockID(applied.ID()),
 		}
 		for _, transaction := range applied.Transactions {
+			txnID := types.TransactionID(transaction.ID())
+			for _, sci := range transaction.SiacoinInputs {
+				spentInputs[types.SiacoinOutputID(sci.ParentID)] = txnID
+			}
+
 			for i := range transaction.FileContracts {
 				contractID := types.FileContractID(transaction.FileContractID(uint64(i)))
 				appliedFormations = append(appliedFormations, contractChange{contractID, index})
@@ -307,7 +414,7 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 
 			for _, proof := range transaction.StorageProofs {
 				contractID := types.FileContractID(proof.ParentID)
-				appliedResolutions = append(appliedResolutions, contractChange{contractID, index})
+				appliedResolutions = append(appliedResolutions, resolutionChange{contractChange{contractID, index}, txnID})
 			}
 		}
 		blockHeight++
@@ -411,11 +518,11 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 				return fmt.Errorf("failed to check if contract %v is relevant: %w", applied, err)
 			} else if !relevant {
 				continue
-			} else if err := tx.ConfirmResolution(applied.id, applied.index.Height); err != nil {
+			} else if err := tx.ConfirmResolution(applied.id, applied.index, applied.txnID); err != nil {
 				return fmt.Errorf("failed to apply proof: %w", err)
 			}
 
-			log.Info("contract resolution confirmed", zap.Stringer("contractID", applied.id), zap.Stringer("block", applied.index))
+			log.Info("contract resolution confirmed", zap.Stringer("contractID", applied.id), zap.Stringer("block", applied.index), zap.Stringer("transactionID", applied.txnID))
 			cm.alerts.Dismiss(types.Hash256(applied.id)) // dismiss any lifecycle alerts for this contract
 		}
 		return nil
@@ -425,6 +532,11 @@ func (cm *ContractManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 		return
 	}
 
+	// check for renters double spending pending contract formations
+	if err := cm.handleDoubleSpends(spentInputs); err != nil {
+		log.Error("failed to handle double spends", zap.Error(err))
+	}
+
 	scanHeight := uint64(cc.BlockHeight)
 	log.Debug("consensus change applied", zap.Uint64("height", scanHeight), zap.String("changeID", cc.ID.String()))
 
@@ -462,10 +574,38 @@ func (cm *ContractManager) ReviseContract(contractID types.FileContractID) (*Con
 		sectorRoots: roots, // roots is already a deep copy
 		oldRoots:    append([]types.Hash256(nil), roots...),
 
-		done: done, // decrements the threadgroup counter after the updater is closed
+		onCommit: cm.checkRevisionLimit,
+		done:     done, // decrements the threadgroup counter after the updater is closed
 	}, nil
 }
 
+// rebroadcastPendingRenewals rebroadcasts renewals that were recorded, but
+// were not confirmed as broadcast before the host shut down. Renewals that
+// cannot be rebroadcast are left pending and reverted if their formation is
+// never confirmed.
+func (cm *ContractManager) rebroadcastPendingRenewals() error {
+	pending, err := cm.store.PendingRenewals()
+	if err != nil {
+		return fmt.Errorf("failed to get pending renewals: %w", err)
+	}
+	for _, id := range pending {
+		log := cm.log.With(zap.Stringer("renewalID", id))
+		formationSet, err := cm.store.ContractFormationSet(id)
+		if err != nil {
+			return fmt.Errorf("failed to get formation set for %v: %w", id, err)
+		} else if err := cm.tpool.AcceptTransactionSet(formationSet); err != nil {
+			// the renewal may already be confirmed or it may never have been
+			// broadcast. Either way, the lifecycle resolves it.
+			log.Warn("failed to rebroadcast pending renewal", zap.Error(err))
+			continue
+		} else if err := cm.store.ConfirmRenewal(id); err != nil {
+			return fmt.Errorf("failed to confirm renewal %v: %w", id, err)
+		}
+		log.Info("rebroadcast pending renewal")
+	}
+	return nil
+}
+
 // Close closes the contract manager.
 func (cm *ContractManager) Close() error {
 	cm.tg.Stop()
@@ -496,7 +636,7 @@ func convertToCore(siad encoding.SiaMarshaler, core types.DecoderFrom) {
 }
 
 // NewManager creates a new contract manager.
-func NewManager(store ContractStore, alerts Alerts, storage StorageManager, c ChainManager, tpool TransactionPool, wallet Wallet, log *zap.Logger) (*ContractManager, error) {
+func NewManager(store ContractStore, alerts Alerts, storage StorageManager, c ChainManager, tpool TransactionPool, wallet Wallet, log *zap.Logger, opts ...Option) (*ContractManager, error) {
 	cache, err := lru.New2Q[types.FileContractID, []types.Hash256](sectorRootCacheSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cache: %w", err)
@@ -513,9 +653,24 @@ func NewManager(store ContractStore, alerts Alerts, storage StorageManager, c Ch
 
 		rootsCache: cache,
 
+		proofFailureThreshold: defaultProofFailureThreshold,
+		proofFailureWindow:    defaultProofFailureWindow,
+		revisionLimitWarning:  defaultRevisionLimitWarning,
+
 		processQueue: make(chan uint64, 100),
 		locks:        make(map[types.FileContractID]*locker),
 	}
+	for _, opt := range opts {
+		opt(cm)
+	}
+
+	if err := cm.loadProofBreaker(); err != nil {
+		return nil, fmt.Errorf("failed to load proof breaker: %w", err)
+	}
+
+	if err := cm.rebroadcastPendingRenewals(); err != nil {
+		return nil, fmt.Errorf("failed to rebroadcast pending renewals: %w", err)
+	}
 
 	changeID, err := store.LastContractChange()
 	if err != nil {
@@ -528,17 +683,24 @@ func NewManager(store ContractStore, alerts Alerts, storage StorageManager, c Ch
 
 	// subscribe to the consensus set in a separate goroutine to prevent
 	// blocking startup
-	go func() {
-		err := cm.chain.Subscribe(cm, changeID, cm.tg.Done())
-		if errors.Is(err, chain.ErrInvalidChangeID) {
-			cm.log.Warn("rescanning blockchain due to unknown consensus change ID")
-			if err := cm.chain.Subscribe(cm, modules.ConsensusChangeBeginning, cm.tg.Done()); err != nil {
-				cm.log.Fatal("failed to reset consensus change subscription", zap.Error(err))
-			}
-		} else if err != nil && !strings.Contains(err.Error(), "ThreadGroup already stopped") {
-			cm.log.Fatal("failed to subscribe to consensus changes", zap.Error(err))
-		}
-	}()
+	go cm.subscribe(changeID)
 
 	return cm, nil
 }
+
+// subscribe subscribes the contract manager to consensus changes starting
+// from changeID.
+func (cm *ContractManager) subscribe(changeID modules.ConsensusChangeID) {
+	cm.subscribeMu.Lock()
+	defer cm.subscribeMu.Unlock()
+
+	err := cm.chain.Subscribe(cm, changeID, cm.tg.Done())
+	if errors.Is(err, chain.ErrInvalidChangeID) {
+		cm.log.Warn("rescanning blockchain due to unknown consensus change ID")
+		if err := cm.chain.Subscribe(cm, modules.ConsensusChangeBeginning, cm.tg.Done()); err != nil {
+			cm.log.Fatal("failed to reset consensus change subscription", zap.Error(err))
+		}
+	} else if err != nil && !strings.Contains(err.Error(), "ThreadGroup already stopped") {
+		cm.log.Fatal("failed to subscribe to consensus changes", zap.Error(err))
+	}
+}
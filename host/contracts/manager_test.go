@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"go.sia.tech/hostd/alerts"
 	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/chain"
 	"go.sia.tech/hostd/internal/test"
 	"go.sia.tech/hostd/persist/sqlite"
 	"go.sia.tech/hostd/webhooks"
@@ -73,6 +75,104 @@ func formContract(renterKey, hostKey types.PrivateKey, start, end uint64, renter
 	return rev, nil
 }
 
+// assertActionConfirmed checks that the contract's action history contains a
+// broadcast result for the action and that its final transaction was
+// confirmed on chain.
+func assertActionConfirmed(t *testing.T, c *contracts.ContractManager, cm *chain.Manager, id types.FileContractID, action string) {
+	t.Helper()
+
+	history, err := c.ActionHistory(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result *contracts.ActionResult
+	for i := range history {
+		if history[i].Action == action && history[i].Status == contracts.ActionStatusBroadcast {
+			result = &history[i]
+		}
+	}
+	if result == nil {
+		t.Fatalf("no broadcast %q action recorded: %+v", action, history)
+	} else if len(result.TransactionIDs) == 0 {
+		t.Fatal("expected transaction IDs to be recorded")
+	} else if result.Fee.IsZero() {
+		t.Fatal("expected fee to be recorded")
+	}
+
+	txnID := result.TransactionIDs[len(result.TransactionIDs)-1]
+	for height := result.Height; height <= cm.TipState().Index.Height; height++ {
+		block, ok := cm.BlockAtHeight(height)
+		if !ok {
+			t.Fatalf("missing block at height %v", height)
+		}
+		for _, txn := range block.Transactions {
+			if txn.ID() == txnID {
+				return
+			}
+		}
+	}
+	t.Fatalf("transaction %v was not confirmed", txnID)
+}
+
+// assertResolutionRecorded checks that the contract's recorded resolution
+// block contains its storage proof transaction.
+func assertResolutionRecorded(t *testing.T, contract contracts.Contract, cm *chain.Manager) {
+	t.Helper()
+
+	block, ok := cm.BlockAtHeight(contract.ResolutionHeight)
+	if !ok {
+		t.Fatalf("missing block at height %v", contract.ResolutionHeight)
+	} else if contract.ResolutionID != types.BlockID(block.ID()) {
+		t.Fatalf("expected resolution block %v, got %v", block.ID(), contract.ResolutionID)
+	}
+	for _, txn := range block.Transactions {
+		if types.TransactionID(txn.ID()) != contract.ResolutionTxnID {
+			continue
+		}
+		for _, proof := range txn.StorageProofs {
+			if types.FileContractID(proof.ParentID) == contract.Revision.ParentID {
+				return
+			}
+		}
+	}
+	t.Fatalf("resolution transaction %v does not contain a proof for the contract", contract.ResolutionTxnID)
+}
+
+// assertFeesRecorded checks that the fees of the contract's broadcast actions
+// are included in the host's fee expenditures.
+func assertFeesRecorded(t *testing.T, c *contracts.ContractManager, id types.FileContractID) {
+	t.Helper()
+
+	history, err := c.ActionHistory(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make(map[string]types.Currency)
+	for _, result := range history {
+		if result.Status != contracts.ActionStatusBroadcast || result.Fee.IsZero() {
+			continue
+		}
+		switch result.Action {
+		case contracts.ActionBroadcastFinalRevision:
+			expected[contracts.FeeCategoryRevision] = expected[contracts.FeeCategoryRevision].Add(result.Fee)
+		case contracts.ActionBroadcastResolution:
+			expected[contracts.FeeCategoryProof] = expected[contracts.FeeCategoryProof].Add(result.Fee)
+		}
+	}
+
+	totals, err := c.FeeExpenditures(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(totals) != len(expected) {
+		t.Fatalf("expected %v fee categories, got %+v", len(expected), totals)
+	}
+	for _, total := range totals {
+		if !total.Total.Equals(expected[total.Category]) {
+			t.Fatalf("expected %v %v fees, got %v", expected[total.Category], total.Category, total.Total)
+		}
+	}
+}
+
 func TestContractLockUnlock(t *testing.T) {
 	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
 	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
@@ -339,6 +439,10 @@ func TestContractLifecycle(t *testing.T) {
 			t.Fatal("expected revision to be confirmed")
 		}
 
+		// the recorded result should reference the confirmed revision
+		// transaction
+		assertActionConfirmed(t, c, node.ChainManager(), rev.Revision.ParentID, contracts.ActionBroadcastFinalRevision)
+
 		// mine until the proof window
 		remainingBlocks = rev.Revision.WindowStart - node.TipState().Index.Height
 		if err := node.MineBlocks(types.VoidAddress, int(remainingBlocks)); err != nil {
@@ -359,7 +463,12 @@ func TestContractLifecycle(t *testing.T) {
 			t.Fatal("expected contract to be active")
 		} else if contract.ResolutionHeight != proofHeight {
 			t.Fatalf("expected resolution height %v, got %v", proofHeight, contract.ResolutionHeight)
-		} else if m, err := node.Store().Metrics(time.Now()); err != nil {
+		}
+		assertActionConfirmed(t, c, node.ChainManager(), rev.Revision.ParentID, contracts.ActionBroadcastResolution)
+		assertResolutionRecorded(t, contract, node.ChainManager())
+		assertFeesRecorded(t, c, rev.Revision.ParentID)
+
+		if m, err := node.Store().Metrics(time.Now()); err != nil {
 			t.Fatal(err)
 		} else if m.Contracts.Active != 1 {
 			t.Fatal("expected 1 active contracts")
@@ -388,6 +497,8 @@ func TestContractLifecycle(t *testing.T) {
 			t.Fatal("expected contract to be successful")
 		} else if contract.ResolutionHeight != proofHeight {
 			t.Fatalf("expected resolution height %v, got %v", proofHeight, contract.ResolutionHeight)
+		} else if contract.ResolutionID == (types.BlockID{}) {
+			t.Fatal("expected resolution block to be recorded")
 		} else if m, err := node.Store().Metrics(time.Now()); err != nil {
 			t.Fatal(err)
 		} else if m.Contracts.Active != 0 {
@@ -984,6 +1095,8 @@ func TestContractLifecycle(t *testing.T) {
 			t.Fatalf("expected contract to be failed, got %q", contract.Status)
 		} else if contract.ResolutionHeight != 0 {
 			t.Fatalf("expected resolution height %v, got %v", 0, contract.ResolutionHeight)
+		} else if contract.ResolutionID != (types.BlockID{}) || contract.ResolutionTxnID != (types.TransactionID{}) {
+			t.Fatalf("expected no resolution to be recorded, got %v %v", contract.ResolutionID, contract.ResolutionTxnID)
 		} else if m, err := node.Store().Metrics(time.Now()); err != nil {
 			t.Fatal(err)
 		} else if m.Contracts.Active != 0 {
@@ -997,6 +1110,41 @@ func TestContractLifecycle(t *testing.T) {
 		} else if !m.Contracts.RiskedCollateral.IsZero() {
 			t.Fatalf("expected %v risked collateral, got %v", types.ZeroCurrency, m.Contracts.RiskedCollateral)
 		}
+
+		// the blockchain rejected the corrupt proof, so the failure should be
+		// attributed to the broadcast
+		if !strings.HasPrefix(contract.FailureReason, string(contracts.ProofFailureBroadcast)+": ") {
+			t.Fatalf("expected failure reason to start with %q, got %q", contracts.ProofFailureBroadcast, contract.FailureReason)
+		}
+		summary, err := c.ProofFailures()
+		if err != nil {
+			t.Fatal(err)
+		} else if summary.Total != 1 {
+			t.Fatalf("expected 1 proof failure, got %v", summary.Total)
+		} else if summary.Causes[contracts.ProofFailureBroadcast] != 1 {
+			t.Fatalf("expected 1 broadcast failure, got %v", summary.Causes)
+		} else if len(summary.Recent) != 1 || summary.Recent[0].ContractID != rev.Revision.ParentID {
+			t.Fatalf("expected recent failure for contract %v, got %v", rev.Revision.ParentID, summary.Recent)
+		}
+
+		// the failed resolution should record its cause
+		history, err := c.ActionHistory(rev.Revision.ParentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resolutions int
+		for _, result := range history {
+			if result.Action != contracts.ActionBroadcastResolution || result.Status != contracts.ActionStatusFailed {
+				continue
+			}
+			resolutions++
+			if result.Cause != contracts.ProofFailureBroadcast {
+				t.Fatalf("expected cause %q, got %q", contracts.ProofFailureBroadcast, result.Cause)
+			}
+		}
+		if resolutions == 0 {
+			t.Fatal("expected a failed resolution")
+		}
 	})
 }
 
@@ -1121,3 +1269,152 @@ func TestSectorRoots(t *testing.T) {
 		}
 	}
 }
+
+func TestReclaimExpiredSectors(t *testing.T) {
+	const gracePeriod = 2
+
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize, storage.WithExpiredSectors(storage.ExpiredSectorConfig{
+		GracePeriod:        gracePeriod,
+		ReclaimOnMigration: true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// add a single volume so its sectors cannot be migrated
+	result := make(chan error, 1)
+	volume, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	height := node.TipState().Index.Height
+	rev, err := formContract(renterKey, hostKey, height+10, height+20, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roots []types.Hash256
+	var releaseFuncs []func() error
+	for i := 0; i < 5; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := s.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releaseFuncs = append(releaseFuncs, release)
+		roots = append(roots, root)
+	}
+
+	rev.Revision.RevisionNumber++
+	rev.Revision.Filesize = rhp2.SectorSize * uint64(len(roots))
+	rev.Revision.FileMerkleRoot = rhp2.MetaRoot(roots)
+	sigHash := hashRevision(rev.Revision)
+	rev.HostSignature = hostKey.SignHash(sigHash)
+	rev.RenterSignature = renterKey.SignHash(sigHash)
+
+	updater, err := c.ReviseContract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range roots {
+		updater.AppendSector(root)
+	}
+	if err := updater.Commit(rev, contracts.Usage{}); err != nil {
+		t.Fatal(err)
+	}
+	updater.Close()
+	for _, release := range releaseFuncs {
+		if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the contract is active, so its sectors cannot be removed
+	if check, err := s.CanRemoveVolume(volume.ID); err != nil {
+		t.Fatal(err)
+	} else if check.CanRemove {
+		t.Fatal("expected volume to not be removable")
+	} else if check.ReclaimableSectors != 0 {
+		t.Fatalf("expected 0 reclaimable sectors, got %v", check.ReclaimableSectors)
+	}
+
+	// stop the contract manager so the expired sectors are not pruned
+	c.Close()
+
+	// mine until the end of the grace period
+	remaining := rev.Revision.WindowEnd + gracePeriod - node.TipState().Index.Height
+	if err := node.MineBlocks(types.VoidAddress, int(remaining)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	if n, err := s.ReclaimableSectors(volume.ID); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected 0 reclaimable sectors during the grace period, got %v", n)
+	}
+
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	if check, err := s.CanRemoveVolume(volume.ID); err != nil {
+		t.Fatal(err)
+	} else if !check.CanRemove {
+		t.Fatalf("expected volume to be removable: %v", check.Reason)
+	} else if check.ReclaimableSectors != uint64(len(roots)) {
+		t.Fatalf("expected %v reclaimable sectors, got %v", len(roots), check.ReclaimableSectors)
+	}
+
+	// the expired sectors are removed instead of migrated
+	if err := s.RemoveVolume(context.Background(), volume.ID, false, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	if volumes, err := s.Volumes(); err != nil {
+		t.Fatal(err)
+	} else if len(volumes) != 0 {
+		t.Fatalf("expected no volumes, got %v", len(volumes))
+	}
+	for _, root := range roots {
+		if _, err := s.Read(root); !errors.Is(err, storage.ErrSectorNotFound) {
+			t.Fatalf("expected sector %v to be removed, got %v", root, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package contracts
+
+import (
+	"fmt"
+
+	"go.sia.tech/core/types"
+)
+
+const (
+	blocksPerDay   = 144
+	blocksPerWeek  = 7 * blocksPerDay
+	blocksPerMonth = 30 * blocksPerDay
+)
+
+type (
+	// ProofWindowBuckets counts the unresolved contracts by how soon their
+	// proof window opens.
+	ProofWindowBuckets struct {
+		// Open is the number of contracts whose proof window has started.
+		Open int `json:"open"`
+		// Day is the number of contracts whose proof window starts within
+		// one day.
+		Day int `json:"day"`
+		// Week is the number of contracts whose proof window starts in more
+		// than one day, but within one week.
+		Week int `json:"week"`
+		// Month is the number of contracts whose proof window starts in
+		// more than one week, but within 30 days.
+		Month int `json:"month"`
+		// Later is the number of contracts whose proof window starts in
+		// more than 30 days.
+		Later int `json:"later"`
+	}
+
+	// An ObligationsSummary is a snapshot of the host's liabilities from its
+	// pending and active contracts.
+	ObligationsSummary struct {
+		Height    uint64 `json:"height"`
+		Contracts int    `json:"contracts"`
+		// StoredBytes is the total size of the data the host is obligated
+		// to store.
+		StoredBytes uint64 `json:"storedBytes"`
+		// LockedCollateral is the total collateral the host locked into the
+		// contracts.
+		LockedCollateral types.Currency `json:"lockedCollateral"`
+		// RiskedCollateral is the total collateral the host would lose if
+		// no storage proofs were submitted.
+		RiskedCollateral types.Currency     `json:"riskedCollateral"`
+		ProofWindows     ProofWindowBuckets `json:"proofWindows"`
+	}
+)
+
+// add adds a contract's obligations to the summary.
+func (s *ObligationsSummary) add(c Contract) {
+	s.Contracts++
+	s.StoredBytes += c.Revision.Filesize
+	s.LockedCollateral = s.LockedCollateral.Add(c.LockedCollateral)
+	s.RiskedCollateral = s.RiskedCollateral.Add(c.Usage.RiskedCollateral)
+
+	switch start := c.Revision.WindowStart; {
+	case start <= s.Height:
+		s.ProofWindows.Open++
+	case start-s.Height <= blocksPerDay:
+		s.ProofWindows.Day++
+	case start-s.Height <= blocksPerWeek:
+		s.ProofWindows.Week++
+	case start-s.Height <= blocksPerMonth:
+		s.ProofWindows.Month++
+	default:
+		s.ProofWindows.Later++
+	}
+}
+
+// ObligationsSummary returns a summary of the storage and collateral the host
+// is currently obligated to by its pending and active contracts.
+func (cm *ContractManager) ObligationsSummary() (ObligationsSummary, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return ObligationsSummary{}, err
+	}
+	defer done()
+
+	summary := ObligationsSummary{
+		Height: cm.chain.TipState().Index.Height,
+	}
+	filter := ContractFilter{
+		Statuses:  []ContractStatus{ContractStatusPending, ContractStatusActive},
+		SortField: ContractSortExpirationHeight,
+		Limit:     100,
+	}
+	for {
+		contracts, _, err := cm.store.Contracts(filter)
+		if err != nil {
+			return ObligationsSummary{}, fmt.Errorf("failed to get contracts: %w", err)
+		}
+		for _, c := range contracts {
+			// cleared contracts have no obligations once their
+			// collateral is released
+			if cm.releaseCollateral && c.RenewedTo != (types.FileContractID{}) {
+				continue
+			}
+			summary.add(c)
+		}
+		if len(contracts) < filter.Limit {
+			return summary, nil
+		}
+		filter.After = contracts[len(contracts)-1].Revision.ParentID
+	}
+}
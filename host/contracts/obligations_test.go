@@ -0,0 +1,130 @@
+package contracts_test
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestObligationsSummary(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewWallet(hostKey, dir, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	summary, err := c.ObligationsSummary()
+	if err != nil {
+		t.Fatal(err)
+	} else if summary.Contracts != 0 {
+		t.Fatalf("expected 0 contracts, got %v", summary.Contracts)
+	} else if !summary.LockedCollateral.IsZero() {
+		t.Fatalf("expected no locked collateral, got %v", summary.LockedCollateral)
+	}
+
+	// form contracts with proof windows in each bucket
+	height := node.ChainManager().TipState().Index.Height
+	windows := []uint64{
+		height + 50,    // day
+		height + 100,   // day
+		height + 500,   // week
+		height + 2000,  // month
+		height + 10000, // later
+	}
+	for i, start := range windows {
+		renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+		collateral := types.Siacoins(uint32(100 * (i + 1)))
+		if _, err := formContract(renterKey, hostKey, start, start+10, types.Siacoins(500), collateral, c, node, node.ChainManager(), node.TPool()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertSummary := func(summary contracts.ObligationsSummary) {
+		t.Helper()
+		expected := contracts.ProofWindowBuckets{Day: 2, Week: 1, Month: 1, Later: 1}
+		if summary.Contracts != len(windows) {
+			t.Fatalf("expected %v contracts, got %v", len(windows), summary.Contracts)
+		} else if summary.ProofWindows != expected {
+			t.Fatalf("expected proof windows %+v, got %+v", expected, summary.ProofWindows)
+		} else if !summary.LockedCollateral.Equals(types.Siacoins(1500)) {
+			t.Fatalf("expected %v locked collateral, got %v", types.Siacoins(1500), summary.LockedCollateral)
+		} else if summary.StoredBytes != 0 {
+			t.Fatalf("expected 0 stored bytes, got %v", summary.StoredBytes)
+		} else if !summary.RiskedCollateral.IsZero() {
+			t.Fatalf("expected no risked collateral, got %v", summary.RiskedCollateral)
+		}
+	}
+
+	// pending contracts are included
+	summary, err = c.ObligationsSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSummary(summary)
+
+	// confirm the contracts
+	if err := node.MineBlocks(node.Address(), 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	summary, err = c.ObligationsSummary()
+	if err != nil {
+		t.Fatal(err)
+	} else if summary.Height != height+1 {
+		t.Fatalf("expected height %v, got %v", height+1, summary.Height)
+	}
+	assertSummary(summary)
+
+	// mine until the first proof window is open
+	if err := node.MineBlocks(node.Address(), 50); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	summary, err = c.ObligationsSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := contracts.ProofWindowBuckets{Open: 1, Day: 1, Week: 1, Month: 1, Later: 1}
+	if summary.ProofWindows != expected {
+		t.Fatalf("expected proof windows %+v, got %+v", expected, summary.ProofWindows)
+	}
+}
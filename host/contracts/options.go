@@ -0,0 +1,86 @@
+package contracts
+
+import "time"
+
+// An Option is a functional option that can be used to configure a contract
+// manager.
+type Option func(*ContractManager)
+
+// WithProofStrategy sets the strategy used to schedule storage proof
+// submissions. The default is ProofStrategyImmediate.
+func WithProofStrategy(ps ProofStrategy) Option {
+	return func(cm *ContractManager) {
+		cm.proofStrategy = ps
+	}
+}
+
+// WithProofFailureAlert registers a critical alert summarizing recent failures
+// when at least threshold contracts fail without a valid storage proof within
+// window. A threshold of 0 disables the summary alert. The default is 3
+// failures within 24 hours.
+func WithProofFailureAlert(threshold int, window time.Duration) Option {
+	return func(cm *ContractManager) {
+		cm.proofFailureThreshold = threshold
+		cm.proofFailureWindow = window
+	}
+}
+
+// WithProofBreaker enables a circuit breaker that pauses contract formation
+// when at least threshold contracts fail without a valid storage proof within
+// window. The breaker stays tripped until it is reset by the operator. A
+// threshold of 0 disables the breaker, which is the default.
+func WithProofBreaker(threshold int, window time.Duration) Option {
+	return func(cm *ContractManager) {
+		cm.breakerThreshold = threshold
+		cm.breakerWindow = window
+	}
+}
+
+// WithCollateralRelease sets whether a contract's locked and risked collateral
+// is released from the collateral metrics as soon as the contract is cleared
+// by a renewal, instead of when its proof window closes. The default is
+// false.
+func WithCollateralRelease(release bool) Option {
+	return func(cm *ContractManager) {
+		cm.releaseCollateral = release
+	}
+}
+
+// WithDoubleSpendPolicy sets how a pending contract is handled when its
+// formation inputs are spent by a conflicting transaction. The default is
+// DoubleSpendReject.
+func WithDoubleSpendPolicy(dp DoubleSpendPolicy) Option {
+	return func(cm *ContractManager) {
+		cm.doubleSpendPolicy = dp
+	}
+}
+
+// WithMaxProofFeeRatio sets the maximum ratio of the storage proof fee to the
+// payout recovered by submitting the proof. Proofs with a higher estimated fee
+// are skipped. A ratio of 1 skips proofs that cost more than they recover. The
+// default is 0, which always submits proofs.
+func WithMaxProofFeeRatio(ratio float64) Option {
+	return func(cm *ContractManager) {
+		cm.maxProofFeeRatio = ratio
+	}
+}
+
+// WithRevisionLimitWarning registers an alert when a committed revision's
+// number is within remaining of types.MaxRevisionNumber. Revisions within
+// rhp.RevisionNumberReserve of the maximum are rejected, so the contract must
+// be renewed. A value of 0 disables the alert. The default is 16777216.
+func WithRevisionLimitWarning(remaining uint64) Option {
+	return func(cm *ContractManager) {
+		cm.revisionLimitWarning = remaining
+	}
+}
+
+// WithPruneRetention prunes successful, failed, and rejected contracts once
+// their proof window ended at least retention blocks ago. Contracts are
+// pruned as blocks are processed. A retention of 0 disables automatic
+// pruning, which is the default.
+func WithPruneRetention(retention uint64) Option {
+	return func(cm *ContractManager) {
+		cm.pruneRetention = retention
+	}
+}
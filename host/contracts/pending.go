@@ -0,0 +1,113 @@
+package contracts
+
+import (
+	"fmt"
+	"sort"
+
+	"go.sia.tech/core/types"
+)
+
+// revisionTxnSize is the estimated size of a final revision transaction used
+// to calculate its miner fee.
+const revisionTxnSize = 1000 // bytes
+
+// A PendingAction is a lifecycle action that the host will perform on a
+// contract at a future height.
+type PendingAction struct {
+	ContractID types.FileContractID `json:"contractID"`
+	Action     string               `json:"action"`
+	// Height is the next height the action is scheduled for. Until its
+	// transaction is confirmed, the action is repeated every few blocks
+	// while its window is open.
+	Height uint64 `json:"height"`
+	// Fee is the estimated miner fee at the current recommended fee rate.
+	Fee types.Currency `json:"fee"`
+}
+
+// pendingActions returns the broadcasts the host will perform on the
+// contract after the current height.
+func (cm *ContractManager) pendingActions(c Contract, height uint64) (actions []PendingAction) {
+	id := c.Revision.ParentID
+
+	if !c.FormationConfirmed {
+		if c.Status == ContractStatusRejected {
+			return nil
+		}
+		// the fee was paid when the contract was formed
+		if next, ok := cm.broadcastSchedule(c, ActionBroadcastFormation).next(height); ok {
+			actions = append(actions, PendingAction{ContractID: id, Action: ActionBroadcastFormation, Height: next})
+		}
+		// the remaining actions depend on the formation being confirmed
+		return
+	}
+
+	if !c.RevisionConfirmed {
+		if next, ok := cm.broadcastSchedule(c, ActionBroadcastFinalRevision).next(height); ok {
+			actions = append(actions, PendingAction{
+				ContractID: id,
+				Action:     ActionBroadcastFinalRevision,
+				Height:     next,
+				Fee:        cm.tpool.RecommendedFee().Mul64(revisionTxnSize),
+			})
+		}
+	}
+
+	// storage proofs are only broadcast if they are needed and economical
+	if c.ResolutionHeight != 0 {
+		return
+	}
+	recoverable, ok := proofRecoverable(c)
+	if !ok {
+		return
+	}
+	fee := cm.proofFee(c.Revision.Filesize)
+	if cm.proofUneconomical(fee, recoverable) {
+		return
+	}
+	if next, ok := cm.broadcastSchedule(c, ActionBroadcastResolution).next(height); ok {
+		actions = append(actions, PendingAction{
+			ContractID: id,
+			Action:     ActionBroadcastResolution,
+			Height:     next,
+			Fee:        fee,
+		})
+	}
+	return
+}
+
+// PendingActions returns the formation, final revision, and storage proof
+// broadcasts that the host has scheduled for its pending and active
+// contracts, ordered by height.
+func (cm *ContractManager) PendingActions() ([]PendingAction, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	height := cm.chain.TipState().Index.Height
+	filter := ContractFilter{
+		Statuses:  []ContractStatus{ContractStatusPending, ContractStatusActive},
+		SortField: ContractSortExpirationHeight,
+		Limit:     100,
+	}
+	actions := []PendingAction{}
+	for {
+		contracts, _, err := cm.store.Contracts(filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get contracts: %w", err)
+		}
+		for _, c := range contracts {
+			actions = append(actions, cm.pendingActions(c, height)...)
+		}
+		if len(contracts) < filter.Limit {
+			break
+		}
+		filter.After = contracts[len(contracts)-1].Revision.ParentID
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Height < actions[j].Height
+	})
+	return actions, nil
+}
@@ -0,0 +1,165 @@
+package contracts_test
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestPendingActions(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewWallet(hostKey, dir, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	if actions, err := c.PendingActions(); err != nil {
+		t.Fatal(err)
+	} else if len(actions) != 0 {
+		t.Fatalf("expected no pending actions, got %v", actions)
+	}
+
+	height := node.ChainManager().TipState().Index.Height
+	// the host's payout does not change if it fails to submit a proof, so
+	// only the final revision is broadcast
+	unrevisedKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unrevised, err := formContract(unrevisedKey, hostKey, height+100, height+110, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the host risks collateral, so the final revision and a storage proof
+	// are broadcast
+	riskedKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	risked, err := formContract(riskedKey, hostKey, height+50, height+60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// confirm the contracts
+	if err := node.MineBlocks(node.Address(), 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	collateral := types.Siacoins(100)
+	risked.Revision.RevisionNumber++
+	risked.Revision.MissedProofOutputs[1].Value = risked.Revision.MissedProofOutputs[1].Value.Sub(collateral)
+	risked.Revision.MissedProofOutputs[2].Value = risked.Revision.MissedProofOutputs[2].Value.Add(collateral)
+	sigHash := hashRevision(risked.Revision)
+	risked.HostSignature = hostKey.SignHash(sigHash)
+	risked.RenterSignature = riskedKey.SignHash(sigHash)
+	updater, err := c.ReviseContract(risked.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := updater.Commit(risked, contracts.Usage{RiskedCollateral: collateral}); err != nil {
+		t.Fatal(err)
+	}
+	updater.Close()
+
+	// the formation of a pending contract is rebroadcast
+	pendingKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	pending, err := formContract(pendingKey, hostKey, height+200, height+210, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pendingContract, err := c.Contract(pending.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type key struct {
+		id     types.FileContractID
+		action string
+	}
+	expected := map[key]uint64{
+		// formations are rebroadcast every 3 blocks after negotiation
+		{pending.Revision.ParentID, contracts.ActionBroadcastFormation}: pendingContract.NegotiationHeight + 3,
+		// revisions are broadcast when the revision submission buffer starts
+		{unrevised.Revision.ParentID, contracts.ActionBroadcastFinalRevision}: unrevised.Revision.WindowStart - contracts.RevisionSubmissionBuffer,
+		{risked.Revision.ParentID, contracts.ActionBroadcastFinalRevision}:    risked.Revision.WindowStart - contracts.RevisionSubmissionBuffer,
+		// proofs are submitted when the proof window opens
+		{risked.Revision.ParentID, contracts.ActionBroadcastResolution}: risked.Revision.WindowStart,
+	}
+
+	actions, err := c.PendingActions()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(actions) != len(expected) {
+		t.Fatalf("expected %v pending actions, got %+v", len(expected), actions)
+	}
+	for i, action := range actions {
+		k := key{action.ContractID, action.Action}
+		if height, ok := expected[k]; !ok {
+			t.Fatalf("unexpected action %+v", action)
+		} else if action.Height != height {
+			t.Fatalf("expected %q for contract %v at height %v, got %v", action.Action, action.ContractID, height, action.Height)
+		} else if i > 0 && actions[i-1].Height > action.Height {
+			t.Fatal("expected actions to be ordered by height")
+		}
+
+		switch action.Action {
+		case contracts.ActionBroadcastFormation:
+			if !action.Fee.IsZero() {
+				t.Fatalf("expected no fee for formation rebroadcast, got %v", action.Fee)
+			}
+		default:
+			if action.Fee.IsZero() {
+				t.Fatalf("expected fee for %q", action.Action)
+			}
+		}
+	}
+
+	// once the proof window opens, the revision is no longer pending
+	remaining := risked.Revision.WindowStart - node.ChainManager().TipState().Index.Height
+	if err := node.MineBlocks(node.Address(), int(remaining)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	actions, err = c.PendingActions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range actions {
+		if action.ContractID == risked.Revision.ParentID && action.Action == contracts.ActionBroadcastFinalRevision {
+			t.Fatalf("unexpected action %+v", action)
+		}
+	}
+}
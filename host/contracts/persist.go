@@ -1,6 +1,8 @@
 package contracts
 
 import (
+	"time"
+
 	"go.sia.tech/core/types"
 	"go.sia.tech/siad/modules"
 )
@@ -12,7 +14,7 @@ type (
 
 		ConfirmFormation(types.FileContractID) error
 		ConfirmRevision(types.FileContractRevision) error
-		ConfirmResolution(id types.FileContractID, height uint64) error
+		ConfirmResolution(id types.FileContractID, index types.ChainIndex, txnID types.TransactionID) error
 
 		RevertFormation(types.FileContractID) error
 		RevertRevision(types.FileContractID) error
@@ -23,6 +25,9 @@ type (
 	// contracts and determines which contracts need lifecycle actions.
 	ContractStore interface {
 		LastContractChange() (id modules.ConsensusChangeID, err error)
+		// RenterHistory returns the contract history of the renter with the
+		// given public key.
+		RenterHistory(renterKey types.PublicKey) (RenterHistory, error)
 		// Contracts returns a paginated list of contracts sorted by expiration
 		// asc.
 		Contracts(ContractFilter) ([]Contract, int, error)
@@ -31,26 +36,122 @@ type (
 		// ContractFormationSet returns the formation transaction set for the
 		// contract with the given ID.
 		ContractFormationSet(types.FileContractID) ([]types.Transaction, error)
+		// PendingFormationSpends returns the pending contracts with a
+		// formation input in outputs and the inputs of each contract that
+		// were spent.
+		PendingFormationSpends(outputs []types.SiacoinOutputID) (map[types.FileContractID][]types.SiacoinOutputID, error)
 		// ExpireContract is used to mark a contract as complete. It should only
 		// be used on active or pending contracts.
 		ExpireContract(types.FileContractID, ContractStatus) error
+		// FailContract marks an active contract as failed and records the
+		// reason it failed.
+		FailContract(id types.FileContractID, reason string) error
+		// RecordProofFailure marks an active contract as failed and records
+		// why its storage proof was not submitted.
+		RecordProofFailure(ProofFailure) error
+		// ProofFailures returns the proof failures recorded since the given
+		// time, oldest first.
+		ProofFailures(since time.Time) ([]ProofFailure, error)
+		// ProofBreaker returns the time the proof failure circuit breaker
+		// was tripped and the time it was last reset. A zero time is
+		// returned for either if it has not happened.
+		ProofBreaker() (trippedAt, resetAt time.Time, err error)
+		// SetProofBreaker sets the time the proof failure circuit breaker
+		// was tripped and the time it was last reset.
+		SetProofBreaker(trippedAt, resetAt time.Time) error
+		// RejectContract marks a pending contract as rejected and records
+		// the reason it was rejected.
+		RejectContract(id types.FileContractID, reason string) error
 		// Add stores the provided contract, should error if the contract
 		// already exists in the store.
 		AddContract(revision SignedRevision, formationSet []types.Transaction, lockedCollateral types.Currency, initialUsage Usage, negotationHeight uint64) error
-		// RenewContract renews a contract. It is expected that the existing
-		// contract will be cleared.
+		// RenewContract atomically records a renewal. It is expected that
+		// the existing contract will be cleared. The renewal must remain
+		// pending until it is confirmed or reverted.
 		RenewContract(renewal SignedRevision, existing SignedRevision, formationSet []types.Transaction, lockedCollateral types.Currency, clearingUsage, initialUsage Usage, negotationHeight uint64) error
+		// PendingRenewals returns the IDs of renewed contracts that were
+		// recorded, but have not been confirmed as broadcast.
+		PendingRenewals() ([]types.FileContractID, error)
+		// ConfirmRenewal marks a renewal as broadcast.
+		ConfirmRenewal(renewalID types.FileContractID) error
+		// RevertRenewal atomically removes a pending renewal and restores
+		// the existing contract to its state before the renewal. ErrNotFound
+		// is returned if the renewal is not pending. ErrRenewalRevised is
+		// returned if the renewal's sector roots have changed.
+		RevertRenewal(renewalID types.FileContractID) error
+		// ReleaseCollateral removes a cleared contract's locked and risked
+		// collateral from the collateral metrics before the contract
+		// expires.
+		ReleaseCollateral(types.FileContractID) error
 		// SectorRoots returns the sector roots for a contract. If limit is 0, all roots
 		// are returned.
 		SectorRoots(id types.FileContractID) ([]types.Hash256, error)
+		// SectorRootsInsertionOrder returns the sector roots for a contract
+		// ordered by when their records were created instead of by their
+		// index.
+		SectorRootsInsertionOrder(id types.FileContractID) ([]types.Hash256, error)
+		// SetSectorRootOrder reassigns the indices of a contract's sector
+		// roots to match the order of roots.
+		SetSectorRootOrder(id types.FileContractID, roots []types.Hash256) error
+		// SectorContracts returns the IDs of the contracts that reference
+		// any of the sectors with the given roots.
+		SectorContracts(roots ...types.Hash256) ([]types.FileContractID, error)
+		// MissingContractSectors returns the roots of the sectors
+		// referenced by active or pending contracts that are not stored in
+		// any volume, grouped by contract.
+		MissingContractSectors() (map[types.FileContractID][]types.Hash256, error)
+		// VolumeContracts returns the number of sectors stored in the
+		// volume for each active or pending contract that references them.
+		VolumeContracts(volumeID int64) (map[types.FileContractID]uint64, error)
+		// LeakedSectors returns the roots of stored sectors that are not
+		// referenced by any contract or temporary storage.
+		LeakedSectors() ([]types.Hash256, error)
+		// PruneLeakedSectors removes every leaked sector and frees its
+		// location.
+		PruneLeakedSectors() ([]types.Hash256, error)
 		// ContractAction calls contractFn on every contract in the store that
 		// needs a lifecycle action performed.
 		ContractAction(height uint64, contractFn func(types.FileContractID, uint64, string)) error
+		// AddContractActionResult records the outcome of a lifecycle action.
+		AddContractActionResult(ActionResult) error
+		// ContractActionResults returns the recorded lifecycle actions for a
+		// contract, oldest first.
+		ContractActionResults(types.FileContractID) ([]ActionResult, error)
+		// AddFeeExpenditure records a miner fee paid by the host. A
+		// rebroadcast replaces the fee previously recorded for the contract
+		// and category.
+		AddFeeExpenditure(FeeExpenditure) error
+		// FeeExpenditures returns the miner fees paid in [start, end),
+		// totaled by category.
+		FeeExpenditures(start, end time.Time) ([]FeeTotal, error)
+		// PruneContracts removes every successful, failed, or rejected
+		// contract whose proof window ended before height. A tombstone must
+		// be added for each contract in the same transaction that removes
+		// it.
+		PruneContracts(height uint64) (int, error)
+		// SetContractPinned sets whether a contract is pinned. Pinned
+		// contracts must not be pruned.
+		SetContractPinned(id types.FileContractID, pinned bool) error
+		// SetContractQuarantine sets whether a contract is quarantined and
+		// the reason it was quarantined.
+		SetContractQuarantine(id types.FileContractID, quarantined bool, reason string) error
+		// SetProofSkipReason records why the host decided not to submit a
+		// contract's storage proof. An empty reason clears it.
+		SetProofSkipReason(id types.FileContractID, reason string) error
+		// SetContractTags replaces the tags of a contract.
+		SetContractTags(id types.FileContractID, tags []string) error
+		// ContractTombstones returns a paginated list of the tombstones of
+		// pruned contracts, oldest first.
+		ContractTombstones(limit, offset int) ([]ContractTombstone, error)
 		// ReviseContract atomically updates a contract and its associated
 		// sector roots.
 		ReviseContract(revision SignedRevision, oldRoots []types.Hash256, usage Usage, sectorChanges []SectorChange) error
 		// UpdateContractState atomically updates the contract manager's state.
 		UpdateContractState(modules.ConsensusChangeID, uint64, func(UpdateStateTransaction) error) error
+		// ResetContractConfirmations clears the confirmation state that was
+		// recorded at or after the given height so that it can be rebuilt by
+		// replaying consensus changes.
+		ResetContractConfirmations(height uint64) error
 		// ExpireContractSectors removes sector roots for any contracts that are
 		// past their proof window.
 		ExpireContractSectors(height uint64) error
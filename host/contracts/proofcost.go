@@ -0,0 +1,68 @@
+package contracts
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+)
+
+// proofTxnSetOverhead is the estimated size, in bytes, of the resolution
+// transaction set excluding the storage proof's leaf and Merkle proof. It
+// covers the intermediate funding transaction, the inputs, and the
+// signatures.
+const proofTxnSetOverhead = 1000
+
+// proofTxnSetSize estimates the encoded size of the transaction set used to
+// submit a storage proof for a contract of filesize bytes.
+func proofTxnSetSize(filesize uint64) uint64 {
+	leaves := filesize / rhp2.LeafSize
+	if filesize%rhp2.LeafSize != 0 {
+		leaves++
+	}
+	// the Merkle proof contains at most one hash per level of the tree
+	proofHashes := uint64(bits.Len64(leaves))
+	return proofTxnSetOverhead + rhp2.LeafSize + proofHashes*32
+}
+
+// proofFee returns the estimated fee to broadcast a storage proof for a
+// contract of filesize bytes at the current recommended fee rate.
+func (cm *ContractManager) proofFee(filesize uint64) types.Currency {
+	return cm.tpool.RecommendedFee().Mul64(proofTxnSetSize(filesize))
+}
+
+// proofUneconomical returns true if the fee to broadcast a storage proof
+// exceeds the maximum fee ratio of the payout recovered by submitting it.
+// A ratio of 0 disables the check.
+func (cm *ContractManager) proofUneconomical(fee, recoverable types.Currency) bool {
+	if cm.maxProofFeeRatio <= 0 {
+		return false
+	}
+	// compare fee * denom with recoverable * num using the exact value of
+	// the ratio so small ratios and payouts don't lose precision
+	r := new(big.Rat).SetFloat64(cm.maxProofFeeRatio)
+	if r == nil {
+		return false
+	}
+	limit := new(big.Int).Mul(recoverable.Big(), r.Num())
+	scaled := new(big.Int).Mul(fee.Big(), r.Denom())
+	return scaled.Cmp(limit) > 0
+}
+
+// ProofCost returns the estimated fee to broadcast the storage proof for a
+// contract at the current recommended fee rate.
+func (cm *ContractManager) ProofCost(id types.FileContractID) (types.Currency, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return types.ZeroCurrency, err
+	}
+	defer done()
+
+	contract, err := cm.store.Contract(id)
+	if err != nil {
+		return types.ZeroCurrency, fmt.Errorf("failed to get contract: %w", err)
+	}
+	return cm.proofFee(contract.Revision.Filesize), nil
+}
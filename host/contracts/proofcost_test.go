@@ -0,0 +1,151 @@
+package contracts_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestProofCostSkip(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// skip proofs that cost more than they recover
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"), contracts.WithMaxProofFeeRatio(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: mine enough blocks to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	// transfer a tiny amount to the host's valid payout. The proof only
+	// recovers this amount.
+	amount := types.NewCurrency64(100)
+	rev.Revision.RevisionNumber++
+	rev.Revision.ValidProofOutputs[0].Value = rev.Revision.ValidProofOutputs[0].Value.Sub(amount)
+	rev.Revision.ValidProofOutputs[1].Value = rev.Revision.ValidProofOutputs[1].Value.Add(amount)
+	sigHash := hashRevision(rev.Revision)
+	rev.HostSignature = hostKey.SignHash(sigHash)
+	rev.RenterSignature = renterKey.SignHash(sigHash)
+
+	updater, err := c.ReviseContract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updater.Close()
+
+	if err := updater.Commit(rev, contracts.Usage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cost, err := c.ProofCost(rev.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	} else if cost.Cmp(amount) <= 0 {
+		t.Fatalf("expected proof cost to exceed %v, got %v", amount, cost)
+	}
+
+	// mine until after the proof window
+	remainingBlocks := rev.Revision.WindowEnd - node.TipState().Index.Height + 1
+	if err := node.MineBlocks(types.VoidAddress, int(remainingBlocks)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sync time
+
+	contract, err := c.Contract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if contract.ResolutionHeight != 0 {
+		t.Fatalf("expected no resolution, got height %v", contract.ResolutionHeight)
+	} else if contract.Status != contracts.ContractStatusFailed {
+		t.Fatalf("expected contract to be failed, got %v", contract.Status)
+	}
+
+	history, err := c.ActionHistory(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range history {
+		if result.Action == contracts.ActionBroadcastResolution {
+			t.Fatalf("expected storage proof to be skipped, got %+v", result)
+		}
+	}
+
+	// the skip reason should be persisted and the failure should not be
+	// reported as a lost proof
+	if contract.ProofSkipReason == "" {
+		t.Fatal("expected proof skip reason to be set")
+	} else if !strings.HasPrefix(contract.FailureReason, "proof skipped: ") {
+		t.Fatalf("expected failure reason to record the skip, got %q", contract.FailureReason)
+	} else if summary, err := c.ProofFailures(); err != nil {
+		t.Fatal(err)
+	} else if summary.Total != 0 {
+		t.Fatalf("expected no proof failures, got %v", summary.Total)
+	}
+
+	var found bool
+	for _, alert := range am.Active() {
+		if alert.ID != types.Hash256(rev.Revision.ParentID) {
+			continue
+		}
+		found = true
+		if alert.Severity != alerts.SeverityInfo {
+			t.Fatalf("expected info alert, got %v", alert.Severity)
+		}
+	}
+	if !found {
+		t.Fatal("expected contract alert")
+	}
+}
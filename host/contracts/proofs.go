@@ -0,0 +1,67 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+)
+
+// A SectorProof proves that a sector is included in a contract's file Merkle
+// root without requiring the sector's data.
+type SectorProof struct {
+	ContractID     types.FileContractID `json:"contractID"`
+	RevisionNumber uint64               `json:"revisionNumber"`
+	FileMerkleRoot types.Hash256        `json:"fileMerkleRoot"`
+	NumSectors     uint64               `json:"numSectors"`
+
+	SectorIndex uint64          `json:"sectorIndex"`
+	SectorRoot  types.Hash256   `json:"sectorRoot"`
+	Proof       []types.Hash256 `json:"proof"`
+}
+
+// Verify returns true if the proof is valid for the sector root and file
+// Merkle root.
+func (sp SectorProof) Verify() bool {
+	return rhp2.VerifySectorRangeProof(sp.Proof, []types.Hash256{sp.SectorRoot}, sp.SectorIndex, sp.SectorIndex+1, sp.NumSectors, sp.FileMerkleRoot)
+}
+
+// BuildSectorProof returns a Merkle proof that the sector at sectorIndex is
+// included in the contract's latest revision. The proof can be verified by an
+// external party using only the contract's file Merkle root.
+func (cm *ContractManager) BuildSectorProof(id types.FileContractID, sectorIndex uint64) (SectorProof, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return SectorProof{}, err
+	}
+	defer done()
+
+	// lock the contract so the roots are consistent with the revision
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	contract, err := cm.lock(ctx, id)
+	if err != nil {
+		return SectorProof{}, fmt.Errorf("failed to lock contract: %w", err)
+	}
+	defer cm.Unlock(id)
+
+	roots, err := cm.getSectorRoots(id)
+	if err != nil {
+		return SectorProof{}, fmt.Errorf("failed to get sector roots: %w", err)
+	} else if sectorIndex >= uint64(len(roots)) {
+		return SectorProof{}, fmt.Errorf("%w: index %v, contract has %v sectors", ErrSectorIndexOutOfRange, sectorIndex, len(roots))
+	}
+
+	return SectorProof{
+		ContractID:     id,
+		RevisionNumber: contract.Revision.RevisionNumber,
+		FileMerkleRoot: contract.Revision.FileMerkleRoot,
+		NumSectors:     uint64(len(roots)),
+
+		SectorIndex: sectorIndex,
+		SectorRoot:  roots[sectorIndex],
+		Proof:       rhp2.BuildSectorRangeProof(roots, sectorIndex, sectorIndex+1),
+	}, nil
+}
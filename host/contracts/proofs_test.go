@@ -0,0 +1,132 @@
+package contracts_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestBuildSectorProof(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewWallet(hostKey, dir, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contract, err := c.Contract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updater, err := c.ReviseContract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updater.Close()
+
+	// use a number of sectors that is not a power of two to exercise
+	// unbalanced trees
+	var roots []types.Hash256
+	for i := 0; i < 7; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := s.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+		roots = append(roots, root)
+		updater.AppendSector(root)
+	}
+
+	contract.Revision.RevisionNumber++
+	contract.Revision.Filesize = uint64(len(roots)) * rhp2.SectorSize
+	contract.Revision.FileMerkleRoot = rhp2.MetaRoot(roots)
+	if err := updater.Commit(contract.SignedRevision, contracts.Usage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, root := range roots {
+		proof, err := c.BuildSectorProof(rev.Revision.ParentID, uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		} else if proof.SectorRoot != root {
+			t.Fatalf("expected sector root %v, got %v", root, proof.SectorRoot)
+		} else if proof.FileMerkleRoot != contract.Revision.FileMerkleRoot {
+			t.Fatalf("expected file merkle root %v, got %v", contract.Revision.FileMerkleRoot, proof.FileMerkleRoot)
+		} else if proof.RevisionNumber != contract.Revision.RevisionNumber {
+			t.Fatalf("expected revision number %v, got %v", contract.Revision.RevisionNumber, proof.RevisionNumber)
+		}
+
+		// verify the proof using only the values an auditor would have
+		if !rhp2.VerifySectorRangeProof(proof.Proof, []types.Hash256{root}, uint64(i), uint64(i)+1, uint64(len(roots)), contract.Revision.FileMerkleRoot) {
+			t.Fatalf("proof for sector %v is invalid", i)
+		} else if !proof.Verify() {
+			t.Fatalf("proof for sector %v is invalid", i)
+		}
+
+		// the proof should not validate a different sector
+		proof.SectorRoot = frand.Entropy256()
+		if proof.Verify() {
+			t.Fatalf("proof for sector %v validated the wrong root", i)
+		}
+	}
+
+	if _, err := c.BuildSectorProof(rev.Revision.ParentID, uint64(len(roots))); !errors.Is(err, contracts.ErrSectorIndexOutOfRange) {
+		t.Fatalf("expected ErrSectorIndexOutOfRange, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+)
+
+// provabilityAuditDelay is the delay between contracts during a provability
+// audit to limit the load on the host's storage.
+const provabilityAuditDelay = 10 * time.Millisecond
+
+type (
+	// A ProvabilityFailure is an active contract the host cannot currently
+	// produce a valid storage proof for.
+	ProvabilityFailure struct {
+		ContractID  types.FileContractID `json:"contractID"`
+		WindowStart uint64               `json:"windowStart"`
+		LeafIndex   uint64               `json:"leafIndex"`
+		Error       string               `json:"error"`
+	}
+
+	// A ProvabilityReport is the result of a provability audit.
+	ProvabilityReport struct {
+		Checked  int                  `json:"checked"`
+		Failures []ProvabilityFailure `json:"failures"`
+		// Repaired are the contracts whose sector roots were out of order
+		// and have been reordered to match their Merkle root.
+		Repaired []types.FileContractID `json:"repaired"`
+	}
+)
+
+// verifyStorageProof checks that sp proves the leaf at leafIndex of a file
+// with the given size and Merkle root. It mirrors the consensus validation of
+// storage proofs.
+func verifyStorageProof(sp types.StorageProof, leafIndex, filesize uint64, merkleRoot types.Hash256) error {
+	leafLen := uint64(rhp2.LeafSize)
+	lastLeafIndex := filesize / rhp2.LeafSize
+	if filesize%rhp2.LeafSize == 0 {
+		lastLeafIndex--
+	} else if leafIndex == lastLeafIndex {
+		leafLen = filesize % rhp2.LeafSize
+	}
+
+	buf := make([]byte, 1+rhp2.LeafSize)
+	copy(buf[1:], sp.Leaf[:leafLen])
+	root := types.HashBytes(buf[:1+leafLen])
+
+	pair := func(left, right types.Hash256) types.Hash256 {
+		var b [1 + 32 + 32]byte
+		b[0] = 1
+		copy(b[1:], left[:])
+		copy(b[33:], right[:])
+		return types.HashBytes(b[:])
+	}
+	subtreeHeight := bits.Len64(leafIndex ^ lastLeafIndex)
+	for i, h := range sp.Proof {
+		if leafIndex&(1<<i) != 0 || i >= subtreeHeight {
+			root = pair(h, root)
+		} else {
+			root = pair(root, h)
+		}
+	}
+	if root != merkleRoot {
+		return errors.New("storage proof does not match the contract's Merkle root")
+	}
+	return nil
+}
+
+// proofLeafIndex returns the leaf index the contract's storage proof will
+// require. If the block before the proof window has not been mined yet, the
+// current tip is used in its place to select a segment.
+func (cm *ContractManager) proofLeafIndex(rev types.FileContractRevision) (uint64, error) {
+	cs := cm.chain.TipState()
+	seed := cs.Index
+	if rev.WindowStart > 0 && cs.Index.Height >= rev.WindowStart-1 {
+		index, err := cm.chain.IndexAtHeight(rev.WindowStart - 1)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get chain index at height %v: %w", rev.WindowStart-1, err)
+		}
+		seed = index
+	}
+	return cs.StorageProofLeafIndex(rev.Filesize, seed.ID, rev.ParentID), nil
+}
+
+// checkProvability builds and verifies the storage proof for a contract
+// without broadcasting it.
+func (cm *ContractManager) checkProvability(ctx context.Context, id types.FileContractID, log *zap.Logger) (ProvabilityFailure, bool, error) {
+	// lock the contract so the sector roots are consistent with the revision
+	contract, err := cm.lock(ctx, id)
+	if err != nil {
+		return ProvabilityFailure{}, false, fmt.Errorf("failed to lock contract: %w", err)
+	}
+	defer cm.Unlock(id)
+
+	rev := contract.Revision
+	failure := ProvabilityFailure{
+		ContractID:  id,
+		WindowStart: rev.WindowStart,
+	}
+	if rev.Filesize == 0 {
+		return failure, true, nil
+	}
+
+	leafIndex, err := cm.proofLeafIndex(rev)
+	if err != nil {
+		return ProvabilityFailure{}, false, err
+	}
+	failure.LeafIndex = leafIndex
+
+	sp, err := cm.buildStorageProof(id, rev.Filesize, leafIndex, log)
+	if err == nil {
+		err = verifyStorageProof(sp, leafIndex, rev.Filesize, rev.FileMerkleRoot)
+	}
+	if err != nil {
+		failure.Error = err.Error()
+		return failure, false, nil
+	}
+	return failure, true, nil
+}
+
+// AuditProvability builds, but does not broadcast, the storage proof for
+// every active contract and verifies it against the contract's Merkle root.
+// Contracts the host cannot currently prove are returned and a critical alert
+// is registered. Sector roots that are stored out of order are reordered
+// before the proof is built when the correct order can be recovered.
+// Contracts are checked one at a time with a short delay
+// between them to limit the impact on other operations.
+func (cm *ContractManager) AuditProvability(ctx context.Context) (ProvabilityReport, error) {
+	ctx, done, err := cm.tg.AddContext(ctx)
+	if err != nil {
+		return ProvabilityReport{}, err
+	}
+	defer done()
+
+	log := cm.log.Named("auditProvability")
+	report := ProvabilityReport{
+		Failures: []ProvabilityFailure{},
+		Repaired: []types.FileContractID{},
+	}
+	filter := ContractFilter{
+		Statuses:  []ContractStatus{ContractStatusActive},
+		SortField: ContractSortExpirationHeight,
+		Limit:     100,
+	}
+	for {
+		contracts, _, err := cm.store.Contracts(filter)
+		if err != nil {
+			return ProvabilityReport{}, fmt.Errorf("failed to get contracts: %w", err)
+		}
+		for _, c := range contracts {
+			select {
+			case <-ctx.Done():
+				return ProvabilityReport{}, ctx.Err()
+			case <-time.After(provabilityAuditDelay):
+			}
+
+			id := c.Revision.ParentID
+			contractLog := log.With(zap.Stringer("contractID", id))
+			if repaired, err := cm.auditRootOrder(ctx, id, contractLog); err != nil {
+				return ProvabilityReport{}, fmt.Errorf("failed to check root order of contract %v: %w", id, err)
+			} else if repaired {
+				report.Repaired = append(report.Repaired, id)
+			}
+
+			failure, ok, err := cm.checkProvability(ctx, id, contractLog)
+			if err != nil {
+				return ProvabilityReport{}, fmt.Errorf("failed to check contract %v: %w", id, err)
+			}
+			report.Checked++
+			if !ok {
+				log.Error("contract is not provable", zap.Stringer("contractID", id), zap.Uint64("leafIndex", failure.LeafIndex), zap.String("error", failure.Error))
+				report.Failures = append(report.Failures, failure)
+			}
+		}
+		if len(contracts) < filter.Limit {
+			break
+		}
+		filter.After = contracts[len(contracts)-1].Revision.ParentID
+	}
+
+	alertID := types.HashBytes([]byte("provabilityAudit"))
+	if len(report.Failures) == 0 {
+		cm.alerts.Dismiss(alertID)
+		return report, nil
+	}
+	ids := make([]types.FileContractID, 0, len(report.Failures))
+	for _, f := range report.Failures {
+		ids = append(ids, f.ContractID)
+	}
+	cm.alerts.Register(alerts.Alert{
+		ID:       alertID,
+		Severity: alerts.SeverityCritical,
+		Message:  "Storage proofs will fail for active contracts",
+		Data: map[string]any{
+			"checked":   report.Checked,
+			"failed":    len(report.Failures),
+			"contracts": ids,
+		},
+		Timestamp: time.Now(),
+	})
+	return report, nil
+}
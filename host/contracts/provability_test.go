@@ -0,0 +1,161 @@
+package contracts_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestAuditProvability(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewWallet(hostKey, dir, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	volumePath := filepath.Join(dir, "data.dat")
+	if _, err := s.AddVolume(context.Background(), volumePath, 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	var ids []types.FileContractID
+	for i := 0; i < 2; i++ {
+		rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, rev.Revision.ParentID)
+	}
+
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	// add a single sector to each contract so the corrupted sector is the
+	// one that must be proven
+	for _, id := range ids {
+		contract, err := c.Contract(id)
+		if err != nil {
+			t.Fatal(err)
+		} else if contract.Status != contracts.ContractStatusActive {
+			t.Fatal("expected contract to be active")
+		}
+
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:])
+		root := rhp2.SectorRoot(&sector)
+		release, err := s.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+
+		updater, err := c.ReviseContract(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updater.AppendSector(root)
+
+		contract.Revision.RevisionNumber++
+		contract.Revision.Filesize = rhp2.SectorSize
+		contract.Revision.FileMerkleRoot = rhp2.MetaRoot([]types.Hash256{root})
+		if err := updater.Commit(contract.SignedRevision, contracts.Usage{}); err != nil {
+			t.Fatal(err)
+		}
+		updater.Close()
+	}
+
+	report, err := c.AuditProvability(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if report.Checked != 2 {
+		t.Fatalf("expected 2 contracts to be checked, got %v", report.Checked)
+	} else if len(report.Failures) != 0 {
+		t.Fatalf("expected no failures, got %v", report.Failures)
+	}
+
+	// corrupt the first contract's sector, which is stored at the start of
+	// the volume
+	f, err := os.OpenFile(volumePath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := f.WriteAt([]byte{255}, 300); err != nil {
+		t.Fatal(err)
+	} else if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = c.AuditProvability(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if report.Checked != 2 {
+		t.Fatalf("expected 2 contracts to be checked, got %v", report.Checked)
+	} else if len(report.Failures) != 1 {
+		t.Fatalf("expected 1 failure, got %v", report.Failures)
+	} else if report.Failures[0].ContractID != ids[0] {
+		t.Fatalf("expected contract %v to fail, got %v", ids[0], report.Failures[0].ContractID)
+	}
+
+	var critical bool
+	for _, a := range am.Active() {
+		if a.Severity == alerts.SeverityCritical {
+			critical = true
+			break
+		}
+	}
+	if !critical {
+		t.Fatal("expected a critical alert")
+	}
+
+	// a cancelled audit should return an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.AuditProvability(ctx); err == nil {
+		t.Fatal("expected cancelled audit to fail")
+	}
+}
@@ -0,0 +1,35 @@
+package contracts
+
+import (
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// QuarantineContract quarantines a contract suspected of abuse. Renter RPCs
+// against a quarantined contract are rejected with ErrContractQuarantined, but
+// the contract is not resolved early and its storage proof is still
+// submitted to avoid losing collateral.
+func (cm *ContractManager) QuarantineContract(id types.FileContractID, reason string) error {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := cm.store.SetContractQuarantine(id, true, reason); err != nil {
+		return err
+	}
+	cm.log.Warn("contract quarantined", zap.Stringer("contractID", id), zap.String("reason", reason))
+	return nil
+}
+
+// UnquarantineContract removes a contract from quarantine.
+func (cm *ContractManager) UnquarantineContract(id types.FileContractID) error {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return cm.store.SetContractQuarantine(id, false, "")
+}
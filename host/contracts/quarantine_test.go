@@ -0,0 +1,164 @@
+package contracts_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestQuarantineContract(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	} else if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	// add a sector so the contract requires a storage proof
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:256])
+	root := rhp2.SectorRoot(&sector)
+	release, err := s.Write(root, &sector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	rev.Revision.RevisionNumber++
+	rev.Revision.Filesize = rhp2.SectorSize
+	rev.Revision.FileMerkleRoot = root
+	// burn some of the host's collateral if the proof is missed so the
+	// proof is worth submitting
+	collateral := types.Siacoins(1)
+	rev.Revision.MissedProofOutputs[1].Value = rev.Revision.MissedProofOutputs[1].Value.Sub(collateral)
+	rev.Revision.MissedProofOutputs[2].Value = rev.Revision.MissedProofOutputs[2].Value.Add(collateral)
+	sigHash := hashRevision(rev.Revision)
+	rev.HostSignature = hostKey.SignHash(sigHash)
+	rev.RenterSignature = renterKey.SignHash(sigHash)
+
+	updater, err := c.ReviseContract(rev.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updater.AppendSector(root)
+	if err := updater.Commit(rev, contracts.Usage{RiskedCollateral: collateral}); err != nil {
+		t.Fatal(err)
+	}
+	updater.Close()
+
+	id := rev.Revision.ParentID
+	if err := c.QuarantineContract(frand.Entropy256(), "abuse"); !errors.Is(err, contracts.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	} else if err := c.QuarantineContract(id, "abuse"); err != nil {
+		t.Fatal(err)
+	}
+
+	contract, err := c.Contract(id)
+	if err != nil {
+		t.Fatal(err)
+	} else if !contract.Quarantined || contract.QuarantineReason != "abuse" {
+		t.Fatalf("expected contract to be quarantined, got %v %q", contract.Quarantined, contract.QuarantineReason)
+	}
+
+	// renter RPCs lock the contract before using it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.Lock(ctx, id); !errors.Is(err, contracts.ErrContractQuarantined) {
+		t.Fatalf("expected ErrContractQuarantined, got %v", err)
+	}
+
+	// removing the quarantine allows the contract to be used again
+	if err := c.UnquarantineContract(id); err != nil {
+		t.Fatal(err)
+	} else if _, err := c.Lock(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	c.Unlock(id)
+	if err := c.QuarantineContract(id, "abuse"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the final revision and storage proof should still be submitted
+	remainingBlocks := rev.Revision.WindowStart - node.TipState().Index.Height - contracts.RevisionSubmissionBuffer
+	if err := node.MineBlocks(types.VoidAddress, int(remainingBlocks)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	remainingBlocks = rev.Revision.WindowStart - node.TipState().Index.Height
+	if err := node.MineBlocks(types.VoidAddress, int(remainingBlocks)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sync time
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sync time
+
+	contract, err = c.Contract(id)
+	if err != nil {
+		t.Fatal(err)
+	} else if contract.ResolutionHeight != rev.Revision.WindowStart+1 {
+		t.Fatalf("expected resolution height %v, got %v", rev.Revision.WindowStart+1, contract.ResolutionHeight)
+	} else if !contract.Quarantined {
+		t.Fatal("expected contract to remain quarantined")
+	}
+}
@@ -0,0 +1,91 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+)
+
+// A ReconcileReport contains the differences found between the contracts'
+// sector roots and the host's stored sectors.
+type ReconcileReport struct {
+	// MissingSectors are the sectors referenced by active contracts that
+	// are not stored in any volume, grouped by contract.
+	MissingSectors map[types.FileContractID][]types.Hash256 `json:"missingSectors"`
+	// LeakedSectors are the stored sectors that are not referenced by any
+	// contract or temporary storage.
+	LeakedSectors []types.Hash256 `json:"leakedSectors"`
+	// Repaired is true if the leaked sectors were freed.
+	Repaired bool `json:"repaired"`
+}
+
+var reconcileAlertID = types.HashBytes([]byte("reconcileMissingSectors"))
+
+// Reconcile compares the sector roots of every active contract with the
+// host's stored sectors. Contracts with missing data are reported and an
+// alert is registered. If repair is true, leaked sectors are removed and
+// their locations freed. Missing data cannot be repaired.
+func (cm *ContractManager) Reconcile(ctx context.Context, repair bool) (ReconcileReport, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+	defer done()
+
+	log := cm.log.Named("reconcile").With(zap.Bool("repair", repair))
+	start := time.Now()
+
+	missing, err := cm.store.MissingContractSectors()
+	if err != nil {
+		return ReconcileReport{}, fmt.Errorf("failed to get missing sectors: %w", err)
+	} else if err := ctx.Err(); err != nil {
+		return ReconcileReport{}, err
+	}
+
+	report := ReconcileReport{
+		MissingSectors: missing,
+	}
+	if repair {
+		report.LeakedSectors, err = cm.store.PruneLeakedSectors()
+		if err != nil {
+			return ReconcileReport{}, fmt.Errorf("failed to prune leaked sectors: %w", err)
+		}
+		report.Repaired = true
+	} else {
+		report.LeakedSectors, err = cm.store.LeakedSectors()
+		if err != nil {
+			return ReconcileReport{}, fmt.Errorf("failed to get leaked sectors: %w", err)
+		}
+	}
+
+	var totalMissing int
+	for _, roots := range missing {
+		totalMissing += len(roots)
+	}
+	log.Info("reconciled contract sectors", zap.Int("contracts", len(missing)), zap.Int("missing", totalMissing), zap.Int("leaked", len(report.LeakedSectors)), zap.Duration("elapsed", time.Since(start)))
+
+	if len(missing) == 0 {
+		cm.alerts.Dismiss(reconcileAlertID)
+		return report, nil
+	}
+
+	contractIDs := make([]types.FileContractID, 0, len(missing))
+	for id := range missing {
+		contractIDs = append(contractIDs, id)
+	}
+	cm.alerts.Register(alerts.Alert{
+		ID:       reconcileAlertID,
+		Severity: alerts.SeverityCritical,
+		Message:  "Contract data missing, storage proofs will fail",
+		Data: map[string]any{
+			"contracts": contractIDs,
+			"missing":   totalMissing,
+		},
+		Timestamp: time.Now(),
+	})
+	return report, nil
+}
@@ -0,0 +1,82 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.sia.tech/siad/modules"
+	"go.uber.org/zap"
+)
+
+// A reindexSubscriber forwards replayed consensus changes to the contract
+// manager and reports the height of each change.
+type reindexSubscriber struct {
+	cm       *ContractManager
+	progress func(height uint64)
+
+	reset bool
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber.
+func (rs *reindexSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	if !rs.reset {
+		// clear the confirmations recorded after the replay's starting point
+		// so that stale state is not kept. The first change starts at the
+		// height of its first applied block.
+		height := uint64(cc.BlockHeight) - uint64(len(cc.AppliedBlocks)) + 1
+		if err := rs.cm.store.ResetContractConfirmations(height); err != nil {
+			rs.cm.log.Named("reindex").Error("failed to reset contract confirmations", zap.Uint64("height", height), zap.Error(err))
+		}
+		rs.reset = true
+	}
+	rs.cm.ProcessConsensusChange(cc)
+	if rs.progress != nil {
+		rs.progress(uint64(cc.BlockHeight))
+	}
+}
+
+// Reindex replays consensus changes starting after from to rebuild the
+// confirmation state of the host's contracts. Confirmations recorded at or
+// after the first replayed block are cleared before they are replayed. Use
+// modules.ConsensusChangeBeginning to replay the entire chain. progress, if
+// not nil, is called with the height of each replayed change. If ctx is
+// cancelled, the remaining changes are processed in the background and ctx's
+// error is returned.
+func (cm *ContractManager) Reindex(ctx context.Context, from modules.ConsensusChangeID, progress func(height uint64)) error {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	log := cm.log.Named("reindex")
+	start := time.Now()
+
+	cm.subscribeMu.Lock()
+	// stop processing new changes while the contracts are rebuilt
+	cm.chain.Unsubscribe(cm)
+	rs := &reindexSubscriber{cm: cm, progress: progress}
+	err = cm.chain.Subscribe(rs, from, ctx.Done())
+	// the replay subscriber is subscribed once it has caught up
+	cm.chain.Unsubscribe(rs)
+	cm.subscribeMu.Unlock()
+
+	// resume from the last change processed by the replay
+	changeID, lastErr := cm.store.LastContractChange()
+	if lastErr != nil {
+		log.Error("failed to get last contract change, rescanning", zap.Error(lastErr))
+		changeID = modules.ConsensusChangeBeginning
+	}
+	switch {
+	case ctx.Err() != nil:
+		go cm.subscribe(changeID)
+		return ctx.Err()
+	case err != nil:
+		go cm.subscribe(changeID)
+		return fmt.Errorf("failed to replay consensus changes: %w", err)
+	}
+	cm.subscribe(changeID)
+	log.Info("contracts reindexed", zap.Duration("elapsed", time.Since(start)))
+	return nil
+}
@@ -0,0 +1,111 @@
+package contracts_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	"go.sia.tech/siad/modules"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestReindexContracts(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	} else if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	id := rev.Revision.ParentID
+	if contract, err := c.Contract(id); err != nil {
+		t.Fatal(err)
+	} else if !contract.FormationConfirmed {
+		t.Fatal("expected formation to be confirmed")
+	}
+
+	// record a resolution that is not on chain
+	changeID, err := node.Store().LastContractChange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	height := node.ChainManager().TipState().Index.Height
+	err = node.Store().UpdateContractState(changeID, height, func(tx contracts.UpdateStateTransaction) error {
+		return tx.ConfirmResolution(id, types.ChainIndex{Height: height, ID: frand.Entropy256()}, frand.Entropy256())
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if contract, err := c.Contract(id); err != nil {
+		t.Fatal(err)
+	} else if contract.ResolutionHeight != height {
+		t.Fatalf("expected resolution height %v, got %v", height, contract.ResolutionHeight)
+	}
+
+	// reindexing should clear the stale resolution and confirm the formation
+	// again
+	var lastHeight uint64
+	if err := c.Reindex(context.Background(), modules.ConsensusChangeBeginning, func(h uint64) { lastHeight = h }); err != nil {
+		t.Fatal(err)
+	} else if lastHeight != height {
+		t.Fatalf("expected replay to reach height %v, got %v", height, lastHeight)
+	}
+
+	contract, err := c.Contract(id)
+	if err != nil {
+		t.Fatal(err)
+	} else if !contract.FormationConfirmed {
+		t.Fatal("expected formation to be confirmed after reindex")
+	} else if contract.ResolutionHeight != 0 || contract.ResolutionID != (types.BlockID{}) || contract.ResolutionTxnID != (types.TransactionID{}) {
+		t.Fatalf("expected resolution to be cleared, got %v %v %v", contract.ResolutionHeight, contract.ResolutionID, contract.ResolutionTxnID)
+	}
+}
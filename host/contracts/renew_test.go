@@ -0,0 +1,230 @@
+package contracts_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/persist/sqlite"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestRenewalAtomicity(t *testing.T) {
+	const sectors = 5
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	node, err := test.NewWallet(hostKey, t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(db, am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// create a fake volume so disk space is not used
+	volumeID, err := db.AddVolume("test", false)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := db.GrowVolume(volumeID, sectors); err != nil {
+		t.Fatal(err)
+	} else if err := db.SetAvailable(volumeID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(db, am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: mine enough blocks to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	} else if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	// add sectors to the existing contract
+	var roots []types.Hash256
+	for i := 0; i < sectors; i++ {
+		err := func() error {
+			root := frand.Entropy256()
+			release, err := db.StoreSector(root, func(loc storage.SectorLocation, exists bool) error { return nil })
+			if err != nil {
+				return fmt.Errorf("failed to store sector: %w", err)
+			}
+			defer release()
+
+			// use the database method directly to avoid the sector cache
+			err = db.ReviseContract(rev, roots, contracts.Usage{}, []contracts.SectorChange{
+				{Action: contracts.SectorActionAppend, Root: root},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revise contract: %w", err)
+			}
+			roots = append(roots, root)
+			return nil
+		}()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	existingID := rev.Revision.ParentID
+	before, err := db.Metrics(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clear the existing contract
+	clearing := rev
+	clearing.Revision.RevisionNumber = types.MaxRevisionNumber
+	clearing.Revision.Filesize = 0
+	clearing.Revision.FileMerkleRoot = types.Hash256{}
+	sigHash := hashRevision(clearing.Revision)
+	clearing.HostSignature = hostKey.SignHash(sigHash)
+	clearing.RenterSignature = renterKey.SignHash(sigHash)
+
+	// create a renewal with an unfunded formation transaction that will be
+	// rejected by the transaction pool
+	fc := rev.Revision.FileContract
+	fc.WindowStart += 100
+	fc.WindowEnd += 100
+	formationSet := []types.Transaction{{FileContracts: []types.FileContract{fc}}}
+	renewal := contracts.SignedRevision{
+		Revision: types.FileContractRevision{
+			ParentID:         formationSet[0].FileContractID(0),
+			UnlockConditions: rev.Revision.UnlockConditions,
+			FileContract:     fc,
+		},
+	}
+	renewal.Revision.RevisionNumber = 1
+	sigHash = hashRevision(renewal.Revision)
+	renewal.HostSignature = hostKey.SignHash(sigHash)
+	renewal.RenterSignature = renterKey.SignHash(sigHash)
+	renewalID := renewal.Revision.ParentID
+
+	assertRestored := func(c *contracts.ContractManager) {
+		t.Helper()
+
+		if _, err := c.Contract(renewalID); !errors.Is(err, contracts.ErrNotFound) {
+			t.Fatalf("expected renewed contract to be removed, got %v", err)
+		} else if pending, err := db.PendingRenewals(); err != nil {
+			t.Fatal(err)
+		} else if len(pending) != 0 {
+			t.Fatalf("expected no pending renewals, got %v", pending)
+		}
+
+		existing, err := c.Contract(existingID)
+		if err != nil {
+			t.Fatal(err)
+		} else if existing.Revision.RevisionNumber != rev.Revision.RevisionNumber {
+			t.Fatalf("expected revision number %v, got %v", rev.Revision.RevisionNumber, existing.Revision.RevisionNumber)
+		} else if existing.RenewedTo != (types.FileContractID{}) {
+			t.Fatalf("expected existing contract to not be renewed, got %v", existing.RenewedTo)
+		} else if existing.Status != contracts.ContractStatusActive {
+			t.Fatalf("expected existing contract to be active, got %v", existing.Status)
+		}
+
+		check, err := c.SectorRoots(existingID)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(check) != len(roots) {
+			t.Fatalf("expected %v sector roots, got %v", len(roots), len(check))
+		}
+		for i := range check {
+			if check[i] != roots[i] {
+				t.Fatalf("expected sector root %v to be %v, got %v", i, roots[i], check[i])
+			}
+		}
+
+		after, err := db.Metrics(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		} else if after.Contracts != before.Contracts {
+			t.Fatalf("expected contract metrics %+v, got %+v", before.Contracts, after.Contracts)
+		} else if after.Revenue != before.Revenue {
+			t.Fatalf("expected revenue metrics %+v, got %+v", before.Revenue, after.Revenue)
+		}
+	}
+
+	// a renewal that fails to broadcast should not be recorded
+	err = c.RenewContract(renewal, clearing, formationSet, types.Siacoins(100), contracts.Usage{}, contracts.Usage{RiskedCollateral: types.Siacoins(10)})
+	if !errors.Is(err, contracts.ErrRenewalNotBroadcast) {
+		t.Fatalf("expected ErrRenewalNotBroadcast, got %v", err)
+	}
+	assertRestored(c)
+
+	// simulate a crash after the renewal was recorded, but before it was
+	// broadcast
+	err = db.RenewContract(renewal, clearing, formationSet, types.Siacoins(100), contracts.Usage{}, contracts.Usage{RiskedCollateral: types.Siacoins(10)}, node.TipState().Index.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	c, err = contracts.NewManager(db, am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the renewal cannot be rebroadcast, so it should still be pending
+	if pending, err := db.PendingRenewals(); err != nil {
+		t.Fatal(err)
+	} else if len(pending) != 1 || pending[0] != renewalID {
+		t.Fatalf("expected pending renewal %v, got %v", renewalID, pending)
+	} else if check, err := c.SectorRoots(renewalID); err != nil {
+		t.Fatal(err)
+	} else if len(check) != len(roots) {
+		t.Fatalf("expected %v sector roots, got %v", len(roots), len(check))
+	} else if existing, err := c.Contract(existingID); err != nil {
+		t.Fatal(err)
+	} else if existing.RenewedTo != renewalID {
+		t.Fatalf("expected existing contract to be renewed to %v, got %v", renewalID, existing.RenewedTo)
+	}
+
+	// mine until the renewal is outside the rebroadcast window
+	if err := node.MineBlocks(types.VoidAddress, contracts.RebroadcastBuffer+1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sync time
+
+	// the renewal should be reverted instead of rejected
+	assertRestored(c)
+}
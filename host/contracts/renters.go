@@ -0,0 +1,22 @@
+package contracts
+
+import "go.sia.tech/core/types"
+
+// RenterHistory summarizes the contracts a renter has formed with the host.
+// The history is kept when contracts are pruned.
+type RenterHistory struct {
+	// Formed is the number of contracts formed or renewed by the renter.
+	Formed uint64 `json:"formed"`
+	// Successful is the number of the renter's contracts that ended
+	// successfully.
+	Successful uint64 `json:"successful"`
+	// Failed is the number of the renter's contracts that were rejected or
+	// ended without a storage proof.
+	Failed uint64 `json:"failed"`
+}
+
+// RenterHistory returns the contract history of the renter with the given
+// public key.
+func (cm *ContractManager) RenterHistory(renterKey types.PublicKey) (RenterHistory, error) {
+	return cm.store.RenterHistory(renterKey)
+}
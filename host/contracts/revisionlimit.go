@@ -0,0 +1,46 @@
+package contracts
+
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+)
+
+// defaultRevisionLimitWarning is the default number of revision numbers
+// remaining before types.MaxRevisionNumber at which the host warns that a
+// contract must be renewed. It must be larger than rhp.RevisionNumberReserve
+// for the alert to be registered before revisions are rejected.
+const defaultRevisionLimitWarning = 1 << 24
+
+func revisionLimitAlertID(id types.FileContractID) types.Hash256 {
+	return types.HashBytes([]byte(fmt.Sprintf("revisionLimit-%v", id)))
+}
+
+// checkRevisionLimit registers an alert if a committed revision's number is
+// within the configured distance of types.MaxRevisionNumber. Renters are
+// expected to renew the contract before further revisions are rejected.
+func (cm *ContractManager) checkRevisionLimit(revision types.FileContractRevision) {
+	if cm.revisionLimitWarning == 0 || revision.RevisionNumber == types.MaxRevisionNumber {
+		return
+	}
+	remaining := types.MaxRevisionNumber - revision.RevisionNumber
+	if remaining > cm.revisionLimitWarning {
+		return
+	}
+
+	cm.log.Warn("contract nearing revision number limit", zap.Stringer("contractID", revision.ParentID), zap.Uint64("revisionNumber", revision.RevisionNumber), zap.Uint64("remaining", remaining))
+	cm.alerts.Register(alerts.Alert{
+		ID:       revisionLimitAlertID(revision.ParentID),
+		Severity: alerts.SeverityWarning,
+		Message:  "Contract is nearing its revision number limit and must be renewed",
+		Data: map[string]any{
+			"contractID":         revision.ParentID,
+			"revisionNumber":     revision.RevisionNumber,
+			"remainingRevisions": remaining,
+		},
+		Timestamp: time.Now(),
+	})
+}
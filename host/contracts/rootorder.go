@@ -0,0 +1,136 @@
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// ErrRootOrderUnrepairable is returned when a contract's stored sector roots
+// do not match its Merkle root and no known ordering of them does.
+var ErrRootOrderUnrepairable = errors.New("sector roots do not match the contract's Merkle root and cannot be reordered")
+
+// A RootOrderCheck is the result of validating the order of a contract's
+// stored sector roots against its Merkle root.
+type RootOrderCheck struct {
+	ContractID types.FileContractID `json:"contractID"`
+	Roots      int                  `json:"roots"`
+	// Valid is true if the stored roots produce the contract's Merkle root.
+	Valid bool `json:"valid"`
+	// Repairable is true if the stored roots are out of order and
+	// reordering them produces the contract's Merkle root.
+	Repairable bool `json:"repairable"`
+}
+
+// rootsMatch returns true if roots produce the revision's Merkle root.
+func rootsMatch(roots []types.Hash256, rev types.FileContractRevision) bool {
+	if len(roots) == 0 {
+		return rev.Filesize == 0
+	}
+	return rhp2.MetaRoot(roots) == rev.FileMerkleRoot
+}
+
+// checkRootOrder validates the stored order of a contract's sector roots. If
+// the order is invalid but can be repaired, the correct order is returned.
+// The roots are read from the store rather than the cache so corruption is
+// not hidden. The contract must be locked.
+func (cm *ContractManager) checkRootOrder(id types.FileContractID, rev types.FileContractRevision) (RootOrderCheck, []types.Hash256, error) {
+	roots, err := cm.store.SectorRoots(id)
+	if err != nil {
+		return RootOrderCheck{}, nil, fmt.Errorf("failed to get sector roots: %w", err)
+	}
+	check := RootOrderCheck{
+		ContractID: id,
+		Roots:      len(roots),
+		Valid:      rootsMatch(roots, rev),
+	}
+	if check.Valid {
+		return check, nil, nil
+	}
+
+	// records are created in contract order, so an index corruption can be
+	// undone by ordering the roots by record instead
+	candidate, err := cm.store.SectorRootsInsertionOrder(id)
+	if err != nil {
+		return RootOrderCheck{}, nil, fmt.Errorf("failed to get sector roots in insertion order: %w", err)
+	} else if !rootsMatch(candidate, rev) {
+		return check, nil, nil
+	}
+	check.Repairable = true
+	return check, candidate, nil
+}
+
+// setRootOrder persists the order of a contract's sector roots and removes
+// the stale roots from the cache. The contract must be locked.
+func (cm *ContractManager) setRootOrder(id types.FileContractID, roots []types.Hash256) error {
+	defer cm.rootsCache.Remove(id)
+	if err := cm.store.SetSectorRootOrder(id, roots); err != nil {
+		return fmt.Errorf("failed to reorder sector roots: %w", err)
+	}
+	return nil
+}
+
+// auditRootOrder validates the order of a contract's sector roots and
+// repairs it if possible. It returns true if the order was repaired.
+func (cm *ContractManager) auditRootOrder(ctx context.Context, id types.FileContractID, log *zap.Logger) (bool, error) {
+	contract, err := cm.lock(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock contract: %w", err)
+	}
+	defer cm.Unlock(id)
+
+	check, roots, err := cm.checkRootOrder(id, contract.Revision)
+	if err != nil {
+		return false, err
+	} else if check.Valid {
+		return false, nil
+	} else if !check.Repairable {
+		// the cached roots may predate the corruption, drop them so proofs
+		// reflect the stored state
+		cm.rootsCache.Remove(id)
+		log.Error("sector root order is invalid", zap.Int("roots", check.Roots), zap.Error(ErrRootOrderUnrepairable))
+		return false, nil
+	} else if err := cm.setRootOrder(id, roots); err != nil {
+		return false, err
+	}
+	log.Warn("repaired sector root order", zap.Int("roots", check.Roots))
+	return true, nil
+}
+
+// VerifyRootOrder checks that the stored order of a contract's sector roots
+// produces the contract's Merkle root.
+func (cm *ContractManager) VerifyRootOrder(ctx context.Context, id types.FileContractID) (RootOrderCheck, error) {
+	contract, err := cm.lock(ctx, id)
+	if err != nil {
+		return RootOrderCheck{}, fmt.Errorf("failed to lock contract: %w", err)
+	}
+	defer cm.Unlock(id)
+
+	check, _, err := cm.checkRootOrder(id, contract.Revision)
+	return check, err
+}
+
+// RepairRootOrder reorders a contract's stored sector roots to match the
+// contract's Merkle root. It is a no-op if the order is already valid.
+// ErrRootOrderUnrepairable is returned if no known ordering matches.
+func (cm *ContractManager) RepairRootOrder(ctx context.Context, id types.FileContractID) error {
+	contract, err := cm.lock(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to lock contract: %w", err)
+	}
+	defer cm.Unlock(id)
+
+	check, roots, err := cm.checkRootOrder(id, contract.Revision)
+	if err != nil {
+		return err
+	} else if check.Valid {
+		return nil
+	} else if !check.Repairable {
+		return ErrRootOrderUnrepairable
+	}
+	return cm.setRootOrder(id, roots)
+}
@@ -0,0 +1,158 @@
+package contracts_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestRootOrder(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewWallet(hostKey, dir, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: many more blocks than necessary are mined to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	rev, err := formContract(renterKey, hostKey, 50, 60, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rev.Revision.ParentID
+
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	contract, err := c.Contract(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updater, err := c.ReviseContract(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updater.Close()
+
+	var roots []types.Hash256
+	for i := 0; i < 5; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:])
+		root := rhp2.SectorRoot(&sector)
+		release, err := s.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+
+		updater.AppendSector(root)
+		roots = append(roots, root)
+	}
+
+	contract.Revision.RevisionNumber++
+	contract.Revision.Filesize = uint64(len(roots)) * rhp2.SectorSize
+	contract.Revision.FileMerkleRoot = rhp2.MetaRoot(roots)
+	if err := updater.Commit(contract.SignedRevision, contracts.Usage{}); err != nil {
+		t.Fatal(err)
+	}
+	updater.Close()
+
+	if check, err := c.VerifyRootOrder(context.Background(), id); err != nil {
+		t.Fatal(err)
+	} else if !check.Valid {
+		t.Fatal("expected root order to be valid")
+	} else if check.Roots != len(roots) {
+		t.Fatalf("expected %v roots, got %v", len(roots), check.Roots)
+	}
+
+	// deliberately reorder the stored roots
+	reordered := append([]types.Hash256(nil), roots...)
+	reordered[0], reordered[3] = reordered[3], reordered[0]
+	if err := node.Store().SetSectorRootOrder(id, reordered); err != nil {
+		t.Fatal(err)
+	}
+
+	if check, err := c.VerifyRootOrder(context.Background(), id); err != nil {
+		t.Fatal(err)
+	} else if check.Valid {
+		t.Fatal("expected reordered roots to be invalid")
+	} else if !check.Repairable {
+		t.Fatal("expected reordered roots to be repairable")
+	}
+
+	// the provability audit should repair the order
+	report, err := c.AuditProvability(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(report.Repaired) != 1 || report.Repaired[0] != id {
+		t.Fatalf("expected contract %v to be repaired, got %v", id, report.Repaired)
+	} else if len(report.Failures) != 0 {
+		t.Fatalf("expected no failures, got %v", report.Failures)
+	}
+
+	stored, err := node.Store().SectorRoots(id)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(stored) != len(roots) {
+		t.Fatalf("expected %v roots, got %v", len(roots), len(stored))
+	}
+	for i := range roots {
+		if stored[i] != roots[i] {
+			t.Fatalf("expected root %v at index %v, got %v", roots[i], i, stored[i])
+		}
+	}
+
+	if check, err := c.VerifyRootOrder(context.Background(), id); err != nil {
+		t.Fatal(err)
+	} else if !check.Valid {
+		t.Fatal("expected repaired root order to be valid")
+	}
+}
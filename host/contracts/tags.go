@@ -0,0 +1,47 @@
+package contracts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.sia.tech/core/types"
+)
+
+// normalizeTags trims whitespace from the tags, removes duplicates, and
+// sorts them.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("%w: tag is empty", ErrInvalidTag)
+		} else if len(tag) > MaxTagLength {
+			return nil, fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidTag, tag, MaxTagLength)
+		} else if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// SetContractTags replaces the tags of a contract. Tags are operator-defined
+// labels used to filter contracts. An empty list removes all of the
+// contract's tags.
+func (cm *ContractManager) SetContractTags(id types.FileContractID, tags []string) error {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	tags, err = normalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	return cm.store.SetContractTags(id, tags)
+}
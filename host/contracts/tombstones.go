@@ -0,0 +1,77 @@
+package contracts
+
+import (
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// A ContractTombstone is a compact record of a resolved contract that is kept
+// after the contract's data has been pruned.
+type ContractTombstone struct {
+	ContractID     types.FileContractID `json:"contractID"`
+	RevisionNumber uint64               `json:"revisionNumber"`
+	// Status is the final status of the contract: successful, failed, or
+	// rejected.
+	Status  ContractStatus `json:"status"`
+	Revenue types.Currency `json:"revenue"`
+	// ResolutionHeight is the height the storage proof was confirmed at. It
+	// is zero if the contract was not resolved with a storage proof.
+	ResolutionHeight uint64 `json:"resolutionHeight"`
+	// ResolutionTxnID is the ID of the last storage proof transaction
+	// broadcast by the host. It is the zero value if no proof was broadcast.
+	ResolutionTxnID types.TransactionID `json:"resolutionTxnID"`
+	PrunedAt        time.Time           `json:"prunedAt"`
+}
+
+// PruneContracts removes the data of every successful, failed, or rejected
+// contract whose proof window ended before height. Pinned contracts are
+// skipped. A tombstone is kept for each pruned contract. The number of pruned
+// contracts is returned.
+func (cm *ContractManager) PruneContracts(height uint64) (int, error) {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return cm.store.PruneContracts(height)
+}
+
+// pruneExpiredContracts prunes the resolved contracts whose proof window ended
+// at least the retention period before height. Errors are logged since
+// pruning is retried on the next block.
+func (cm *ContractManager) pruneExpiredContracts(height uint64) {
+	if cm.pruneRetention == 0 || height <= cm.pruneRetention {
+		return
+	}
+
+	log := cm.log.Named("prune").With(zap.Uint64("height", height), zap.Uint64("retention", cm.pruneRetention))
+	pruned, err := cm.store.PruneContracts(height - cm.pruneRetention)
+	if err != nil {
+		log.Error("failed to prune contracts", zap.Int("pruned", pruned), zap.Error(err))
+		return
+	} else if pruned > 0 {
+		log.Info("pruned expired contracts", zap.Int("pruned", pruned))
+	}
+}
+
+// PinContract sets whether a contract is pinned. Pinned contracts and their
+// sectors are kept when resolved contracts are pruned, e.g. to retain
+// evidence during a dispute.
+func (cm *ContractManager) PinContract(id types.FileContractID, pinned bool) error {
+	done, err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return cm.store.SetContractPinned(id, pinned)
+}
+
+// Tombstones returns a paginated list of the tombstones of pruned contracts,
+// oldest first.
+func (cm *ContractManager) Tombstones(limit, offset int) ([]ContractTombstone, error) {
+	return cm.store.ContractTombstones(limit, offset)
+}
@@ -0,0 +1,112 @@
+package contracts_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/webhooks"
+	stypes "go.sia.tech/siad/types"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestPruneRetention(t *testing.T) {
+	hostKey, renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32)), types.NewPrivateKeyFromSeed(frand.Bytes(32))
+
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	webhookReporter, err := webhooks.NewManager(node.Store(), log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	s, err := storage.NewVolumeManager(node.Store(), am, node.ChainManager(), log.Named("storage"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	result := make(chan error, 1)
+	if _, err := s.AddVolume(context.Background(), filepath.Join(dir, "data.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	const retention = 5
+	c, err := contracts.NewManager(node.Store(), am, s, node.ChainManager(), node.TPool(), node, log.Named("contracts"), contracts.WithPruneRetention(retention))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// note: mine enough blocks to ensure all forks have activated
+	if err := node.MineBlocks(node.Address(), int(stypes.MaturityDelay*4)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // sync time
+
+	height := node.TipState().Index.Height
+	pruned, err := formContract(renterKey, hostKey, height+10, height+20, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned, err := formContract(renterKey, hostKey, height+10, height+20, types.Siacoins(500), types.Siacoins(1000), c, node, node.ChainManager(), node.TPool())
+	if err != nil {
+		t.Fatal(err)
+	} else if err := c.PinContract(pinned.Revision.ParentID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// mine until the contracts expire, but not past the retention period
+	remainingBlocks := pruned.Revision.WindowEnd - node.TipState().Index.Height + 1
+	if err := node.MineBlocks(types.VoidAddress, int(remainingBlocks)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sync time
+
+	contract, err := c.Contract(pruned.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if contract.Status != contracts.ContractStatusSuccessful {
+		t.Fatalf("expected contract to be successful, got %v", contract.Status)
+	}
+
+	// mine past the retention period
+	if err := node.MineBlocks(types.VoidAddress, retention+1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sync time
+
+	if _, err := c.Contract(pruned.Revision.ParentID); !errors.Is(err, contracts.ErrNotFound) {
+		t.Fatalf("expected contract to be pruned, got %v", err)
+	} else if _, err := c.Contract(pinned.Revision.ParentID); err != nil {
+		t.Fatalf("expected pinned contract to be kept, got %v", err)
+	}
+
+	tombstones, err := c.Tombstones(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(tombstones) != 1 {
+		t.Fatalf("expected 1 tombstone, got %v", len(tombstones))
+	} else if tombstones[0].ContractID != pruned.Revision.ParentID {
+		t.Fatalf("expected tombstone for %v, got %v", pruned.Revision.ParentID, tombstones[0].ContractID)
+	} else if tombstones[0].Status != contracts.ContractStatusSuccessful {
+		t.Fatalf("expected successful tombstone, got %v", tombstones[0].Status)
+	}
+}
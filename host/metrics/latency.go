@@ -0,0 +1,303 @@
+package metrics
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Contract operations timed by a ContractTimer.
+const (
+	OperationFormation ContractOperation = "formation"
+	OperationRenewal   ContractOperation = "renewal"
+)
+
+// Phases of contract formation and renewal.
+const (
+	// PhaseValidation is the time spent validating the renter's contract
+	// and signatures.
+	PhaseValidation ContractPhase = "validation"
+	// PhaseFunding is the time spent adding and verifying the host's
+	// collateral inputs.
+	PhaseFunding ContractPhase = "funding"
+	// PhaseSigning is the time spent signing the revision and transaction.
+	PhaseSigning ContractPhase = "signing"
+	// PhaseBroadcast is the time spent recording the contract and
+	// broadcasting its transaction set.
+	PhaseBroadcast ContractPhase = "broadcast"
+)
+
+// contractLatencyBuckets are the upper bounds of the contract latency
+// histogram buckets.
+var contractLatencyBuckets = [...]time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+type (
+	// A ContractOperation is a contract flow timed by a ContractTimer.
+	ContractOperation string
+
+	// A ContractPhase is a step of a contract operation.
+	ContractPhase string
+
+	// A LatencyHistogram tracks the durations of an operation or phase.
+	LatencyHistogram struct {
+		// Count is the number of observations.
+		Count uint64 `json:"count"`
+		// TotalDuration is the sum of all observations.
+		TotalDuration time.Duration `json:"totalDuration"`
+		// Durations is a cumulative histogram of the observations.
+		// Observations that took longer than the largest bucket are only
+		// included in Count.
+		Durations []DurationBucket `json:"durations"`
+	}
+
+	// ContractLatency tracks the duration of a contract operation and each of
+	// its phases. The total includes time spent waiting on the renter, so it
+	// may be larger than the sum of the phases.
+	ContractLatency struct {
+		LatencyHistogram
+		Phases map[ContractPhase]LatencyHistogram `json:"phases"`
+	}
+
+	// ContractLatencies is a collection of latency metrics for contract
+	// formation and renewal.
+	ContractLatencies struct {
+		Formation ContractLatency `json:"formation"`
+		Renewal   ContractLatency `json:"renewal"`
+	}
+
+	// A LatencyRecord is the persisted state of a contract latency
+	// histogram.
+	LatencyRecord struct {
+		Operation ContractOperation
+		// Phase is empty for the operation's total duration.
+		Phase         ContractPhase
+		Count         uint64
+		TotalDuration time.Duration
+		// Buckets counts the observations in each bucket. It is not
+		// cumulative.
+		Buckets []uint64
+	}
+
+	// A ContractTimer times the phases of a single contract operation. Only
+	// operations that are finished are recorded. A nil ContractTimer is
+	// valid and records nothing.
+	ContractTimer struct {
+		mm *MetricManager
+		op ContractOperation
+
+		start      time.Time
+		phase      ContractPhase
+		phaseStart time.Time
+		phases     map[ContractPhase]time.Duration
+	}
+
+	// latencyHistogram is the in-memory counterpart of LatencyHistogram.
+	latencyHistogram struct {
+		count         uint64
+		totalDuration time.Duration
+		// durations counts the observations in each bucket. It is not
+		// cumulative.
+		durations [len(contractLatencyBuckets)]uint64
+	}
+
+	contractLatency struct {
+		total  latencyHistogram
+		phases map[ContractPhase]*latencyHistogram
+	}
+)
+
+func (lh *latencyHistogram) observe(elapsed time.Duration) {
+	lh.count++
+	lh.totalDuration += elapsed
+	for i, max := range contractLatencyBuckets {
+		if elapsed <= max {
+			lh.durations[i]++
+			break
+		}
+	}
+}
+
+func (lh *latencyHistogram) histogram() LatencyHistogram {
+	h := LatencyHistogram{
+		Count:         lh.count,
+		TotalDuration: lh.totalDuration,
+		Durations:     make([]DurationBucket, len(contractLatencyBuckets)),
+	}
+	var cumulative uint64
+	for i, max := range contractLatencyBuckets {
+		cumulative += lh.durations[i]
+		h.Durations[i] = DurationBucket{Max: max, Count: cumulative}
+	}
+	return h
+}
+
+func (lh *latencyHistogram) record(op ContractOperation, phase ContractPhase) LatencyRecord {
+	return LatencyRecord{
+		Operation:     op,
+		Phase:         phase,
+		Count:         lh.count,
+		TotalDuration: lh.totalDuration,
+		Buckets:       append([]uint64(nil), lh.durations[:]...),
+	}
+}
+
+// phase returns the histogram of a phase, adding it if necessary.
+func (cl *contractLatency) phase(phase ContractPhase) *latencyHistogram {
+	lh, ok := cl.phases[phase]
+	if !ok {
+		lh = new(latencyHistogram)
+		cl.phases[phase] = lh
+	}
+	return lh
+}
+
+// observe records a finished operation and returns the updated histograms.
+func (cl *contractLatency) observe(op ContractOperation, total time.Duration, phases map[ContractPhase]time.Duration) []LatencyRecord {
+	cl.total.observe(total)
+	records := []LatencyRecord{cl.total.record(op, "")}
+	for phase, elapsed := range phases {
+		lh := cl.phase(phase)
+		lh.observe(elapsed)
+		records = append(records, lh.record(op, phase))
+	}
+	return records
+}
+
+func (cl *contractLatency) latency() ContractLatency {
+	l := ContractLatency{
+		LatencyHistogram: cl.total.histogram(),
+		Phases:           make(map[ContractPhase]LatencyHistogram, len(cl.phases)),
+	}
+	for phase, lh := range cl.phases {
+		l.Phases[phase] = lh.histogram()
+	}
+	return l
+}
+
+// endPhase adds the time spent in the current phase to its total.
+func (ct *ContractTimer) endPhase(now time.Time) {
+	if ct.phase != "" {
+		ct.phases[ct.phase] += now.Sub(ct.phaseStart)
+		ct.phase = ""
+	}
+}
+
+// Phase ends the current phase and starts timing the given phase. Time spent
+// in a phase that is entered more than once is summed.
+func (ct *ContractTimer) Phase(phase ContractPhase) {
+	if ct == nil {
+		return
+	}
+	now := time.Now()
+	ct.endPhase(now)
+	ct.phase = phase
+	ct.phaseStart = now
+}
+
+// Pause ends the current phase without starting a new one, usually while
+// waiting for the renter.
+func (ct *ContractTimer) Pause() {
+	if ct == nil {
+		return
+	}
+	ct.endPhase(time.Now())
+}
+
+// Finish ends the current phase and records the operation.
+func (ct *ContractTimer) Finish() {
+	if ct == nil {
+		return
+	}
+	now := time.Now()
+	ct.endPhase(now)
+	ct.mm.observeContract(ct.op, now.Sub(ct.start), ct.phases)
+}
+
+// operationLatency returns the histograms of a contract operation, adding
+// them if necessary. latencyMu must be held.
+func (mm *MetricManager) operationLatency(op ContractOperation) *contractLatency {
+	cl, ok := mm.contractLatency[op]
+	if !ok {
+		cl = &contractLatency{phases: make(map[ContractPhase]*latencyHistogram)}
+		mm.contractLatency[op] = cl
+	}
+	return cl
+}
+
+// loadContractLatency loads the persisted contract latency histograms.
+// Histograms persisted with a different bucket layout are reset.
+func (mm *MetricManager) loadContractLatency() error {
+	records, err := mm.store.ContractLatency()
+	if err != nil {
+		return err
+	}
+
+	mm.latencyMu.Lock()
+	defer mm.latencyMu.Unlock()
+	for _, r := range records {
+		if len(r.Buckets) != len(contractLatencyBuckets) {
+			continue
+		}
+		cl := mm.operationLatency(r.Operation)
+		lh := &cl.total
+		if r.Phase != "" {
+			lh = cl.phase(r.Phase)
+		}
+		lh.count = r.Count
+		lh.totalDuration = r.TotalDuration
+		copy(lh.durations[:], r.Buckets)
+	}
+	return nil
+}
+
+// observeContract records the duration of a finished contract operation.
+// The histograms are persisted while the lock is held so concurrent
+// operations cannot persist them out of order.
+func (mm *MetricManager) observeContract(op ContractOperation, total time.Duration, phases map[ContractPhase]time.Duration) {
+	mm.latencyMu.Lock()
+	defer mm.latencyMu.Unlock()
+	records := mm.operationLatency(op).observe(op, total, phases)
+	if err := mm.store.UpdateContractLatency(records); err != nil {
+		mm.log.Error("failed to persist contract latency", zap.String("operation", string(op)), zap.Error(err))
+	}
+}
+
+// StartContractTimer returns a ContractTimer for a new contract operation.
+func (mm *MetricManager) StartContractTimer(op ContractOperation) *ContractTimer {
+	return &ContractTimer{
+		mm:     mm,
+		op:     op,
+		start:  time.Now(),
+		phases: make(map[ContractPhase]time.Duration),
+	}
+}
+
+// ContractLatency returns latency metrics for the contracts formed and
+// renewed by the host.
+func (mm *MetricManager) ContractLatency() ContractLatencies {
+	mm.latencyMu.Lock()
+	defer mm.latencyMu.Unlock()
+
+	latency := func(op ContractOperation) ContractLatency {
+		if cl, ok := mm.contractLatency[op]; ok {
+			return cl.latency()
+		}
+		empty := contractLatency{phases: make(map[ContractPhase]*latencyHistogram)}
+		return empty.latency()
+	}
+	return ContractLatencies{
+		Formation: latency(OperationFormation),
+		Renewal:   latency(OperationRenewal),
+	}
+}
@@ -2,7 +2,10 @@ package metrics
 
 import (
 	"fmt"
+	"sync"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 type (
@@ -12,11 +15,24 @@ type (
 		PeriodMetrics(start time.Time, n int, interval Interval) (period []Metrics, err error)
 		// Metrics returns aggregated metrics for the host as of the timestamp.
 		Metrics(time.Time) (m Metrics, err error)
+		// TransactionMetrics returns metrics for the store's database
+		// transactions since the store was opened.
+		TransactionMetrics() Transactions
+
+		// ContractLatency returns the persisted contract latency
+		// histograms.
+		ContractLatency() ([]LatencyRecord, error)
+		// UpdateContractLatency persists the contract latency histograms.
+		UpdateContractLatency([]LatencyRecord) error
 	}
 
 	// A MetricManager retrieves metrics from a store
 	MetricManager struct {
-		store Store // note: this is currently a thin wrapper around the store, but may be expanded in the future
+		store Store
+		log   *zap.Logger
+
+		latencyMu       sync.Mutex // guards contractLatency
+		contractLatency map[ContractOperation]*contractLatency
 	}
 )
 
@@ -34,6 +50,12 @@ func (mm *MetricManager) Metrics(timestamp time.Time) (m Metrics, err error) {
 	return mm.store.Metrics(timestamp)
 }
 
+// TransactionMetrics returns metrics for the host's database transactions
+// since startup.
+func (mm *MetricManager) TransactionMetrics() Transactions {
+	return mm.store.TransactionMetrics()
+}
+
 // Normalize returns the normalized timestamp for the given interval.
 func Normalize(timestamp time.Time, interval Interval) (time.Time, error) {
 	switch interval {
@@ -59,9 +81,16 @@ func Normalize(timestamp time.Time, interval Interval) (time.Time, error) {
 	}
 }
 
-// NewManager returns a new MetricManager
-func NewManager(store Store) *MetricManager {
-	return &MetricManager{
-		store: store,
+// NewManager returns a new MetricManager. The contract latency histograms
+// are loaded from the store.
+func NewManager(store Store, log *zap.Logger) (*MetricManager, error) {
+	mm := &MetricManager{
+		store:           store,
+		log:             log,
+		contractLatency: make(map[ContractOperation]*contractLatency),
+	}
+	if err := mm.loadContractLatency(); err != nil {
+		return nil, fmt.Errorf("failed to load contract latency: %w", err)
 	}
+	return mm, nil
 }
@@ -78,6 +78,8 @@ type (
 
 		Reads  uint64 `json:"reads"`
 		Writes uint64 `json:"writes"`
+		// Rejections is the number of writes that failed validation.
+		Rejections uint64 `json:"rejections"`
 	}
 
 	// Storage is a collection of metrics related to storage.
@@ -93,6 +95,12 @@ type (
 
 		SectorCacheHits   uint64 `json:"sectorCacheHits"`
 		SectorCacheMisses uint64 `json:"sectorCacheMisses"`
+
+		SectorPrefetches   uint64 `json:"sectorPrefetches"`
+		SectorPrefetchHits uint64 `json:"sectorPrefetchHits"`
+
+		SectorReadAheads    uint64 `json:"sectorReadAheads"`
+		SectorReadAheadHits uint64 `json:"sectorReadAheadHits"`
 	}
 
 	// RevenueMetrics is a collection of metrics related to revenue.
@@ -101,6 +109,51 @@ type (
 		Earned    Revenue `json:"earned"`
 	}
 
+	// A DurationBucket is a cumulative histogram bucket counting the
+	// observations that took at most Max.
+	DurationBucket struct {
+		Max   time.Duration `json:"max"`
+		Count uint64        `json:"count"`
+	}
+
+	// Transactions is a collection of metrics related to the host's database
+	// transactions. The metrics are not persisted and are reset when the host
+	// restarts.
+	Transactions struct {
+		// Count is the number of transactions that have completed, whether
+		// they succeeded or failed.
+		Count uint64 `json:"count"`
+		// Retries is the number of times a transaction was retried because
+		// the database was busy.
+		Retries uint64 `json:"retries"`
+		// Rollbacks is the number of transaction attempts that were rolled
+		// back instead of committed.
+		Rollbacks uint64 `json:"rollbacks"`
+
+		// TotalDuration is the sum of the durations of all completed
+		// transactions, including retries.
+		TotalDuration time.Duration `json:"totalDuration"`
+		// Durations is a cumulative histogram of transaction durations.
+		// Transactions that took longer than the largest bucket are only
+		// included in Count.
+		Durations []DurationBucket `json:"durations"`
+	}
+
+	// Broadcasts is a collection of metrics related to the host's transaction
+	// pool broadcasts. The metrics are not persisted and are reset when the
+	// host restarts.
+	Broadcasts struct {
+		// Attempts is the number of transaction sets the host has tried to
+		// broadcast.
+		Attempts uint64 `json:"attempts"`
+		// Successes is the number of transaction sets that were accepted by
+		// the transaction pool.
+		Successes uint64 `json:"successes"`
+		// Rejections is the number of transaction sets rejected by the
+		// transaction pool, keyed by reason.
+		Rejections map[string]uint64 `json:"rejections"`
+	}
+
 	// Metrics is a collection of metrics for the host.
 	Metrics struct {
 		Accounts  Accounts       `json:"accounts"`
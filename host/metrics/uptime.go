@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/hostd/internal/threadgroup"
+	"go.uber.org/zap"
+)
+
+// DefaultHeartbeatInterval is the default interval between liveness
+// heartbeats.
+const DefaultHeartbeatInterval = time.Minute
+
+type (
+	// An UptimeInterval is a period the host was online.
+	UptimeInterval struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	}
+
+	// A DowntimeEvent is a period the host was offline.
+	DowntimeEvent struct {
+		Start    time.Time     `json:"start"`
+		End      time.Time     `json:"end"`
+		Duration time.Duration `json:"duration"`
+	}
+
+	// UptimeStats contains the availability of the host over a period.
+	UptimeStats struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+		// Uptime is the percentage of the period the host was online.
+		Uptime   float64         `json:"uptime"`
+		Downtime time.Duration   `json:"downtime"`
+		Events   []DowntimeEvent `json:"events"`
+	}
+
+	// An UptimeStore persists the intervals the host was online.
+	UptimeStore interface {
+		// StartUptimeInterval records the start of a new interval and
+		// returns its ID.
+		StartUptimeInterval(time.Time) (int64, error)
+		// RecordHeartbeat extends an uptime interval to the timestamp.
+		RecordHeartbeat(id int64, timestamp time.Time) error
+		// UptimeIntervals returns the intervals that ended after the
+		// timestamp and the last interval that ended before it, ordered by
+		// start time.
+		UptimeIntervals(since time.Time) ([]UptimeInterval, error)
+	}
+
+	// An UptimeTracker records liveness heartbeats while the host is online
+	// and computes its availability.
+	UptimeTracker struct {
+		store     UptimeStore
+		log       *zap.Logger
+		tg        *threadgroup.ThreadGroup
+		heartbeat time.Duration
+
+		id int64
+	}
+)
+
+// ComputeUptime computes the host's availability between start and end from
+// the intervals it was online. Gaps between intervals shorter than the
+// tolerance are not counted as downtime. The period before the first interval
+// is not tracked and is excluded.
+func ComputeUptime(intervals []UptimeInterval, start, end time.Time, tolerance time.Duration) UptimeStats {
+	stats := UptimeStats{Start: start, End: end, Events: []DowntimeEvent{}}
+	if len(intervals) == 0 || !end.After(start) {
+		return stats
+	} else if intervals[0].Start.After(start) {
+		stats.Start = intervals[0].Start
+	}
+	total := end.Sub(stats.Start)
+	if total <= 0 {
+		return stats
+	}
+
+	addDowntime := func(from, to time.Time) {
+		if to.Sub(from) <= tolerance {
+			return
+		}
+		stats.Downtime += to.Sub(from)
+		stats.Events = append(stats.Events, DowntimeEvent{
+			Start:    from,
+			End:      to,
+			Duration: to.Sub(from),
+		})
+	}
+
+	// cursor is the end of the latest online interval
+	cursor := stats.Start
+	for _, interval := range intervals {
+		if interval.Start.After(end) {
+			break
+		} else if interval.Start.After(cursor) {
+			addDowntime(cursor, interval.Start)
+		}
+		if interval.End.After(cursor) {
+			cursor = interval.End
+		}
+	}
+	if cursor.Before(end) {
+		addDowntime(cursor, end)
+	}
+
+	stats.Uptime = 100 * float64(total-stats.Downtime) / float64(total)
+	return stats
+}
+
+// Stats returns the host's availability over the window ending now.
+func (ut *UptimeTracker) Stats(window time.Duration) (UptimeStats, error) {
+	end := time.Now()
+	start := end.Add(-window)
+	intervals, err := ut.store.UptimeIntervals(start)
+	if err != nil {
+		return UptimeStats{}, fmt.Errorf("failed to get uptime intervals: %w", err)
+	}
+	// allow for the time between heartbeats, including the time since the
+	// current interval's last heartbeat
+	return ComputeUptime(intervals, start, end, 2*ut.heartbeat), nil
+}
+
+// Close records a final heartbeat and stops the tracker.
+func (ut *UptimeTracker) Close() error {
+	ut.tg.Stop()
+	return ut.store.RecordHeartbeat(ut.id, time.Now())
+}
+
+func (ut *UptimeTracker) run() {
+	done, err := ut.tg.Add()
+	if err != nil {
+		return
+	}
+	defer done()
+
+	t := time.NewTicker(ut.heartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-ut.tg.Done():
+			return
+		case <-t.C:
+		}
+		if err := ut.store.RecordHeartbeat(ut.id, time.Now()); err != nil {
+			ut.log.Error("failed to record heartbeat", zap.Error(err))
+		}
+	}
+}
+
+// NewUptimeTracker starts a new uptime interval and records a heartbeat at
+// the given interval until the tracker is closed.
+func NewUptimeTracker(store UptimeStore, log *zap.Logger, heartbeat time.Duration) (*UptimeTracker, error) {
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeatInterval
+	}
+	id, err := store.StartUptimeInterval(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to start uptime interval: %w", err)
+	}
+	ut := &UptimeTracker{
+		store:     store,
+		log:       log,
+		tg:        threadgroup.New(),
+		heartbeat: heartbeat,
+		id:        id,
+	}
+	go ut.run()
+	return ut, nil
+}
@@ -0,0 +1,18 @@
+package registry
+
+import rhp3 "go.sia.tech/core/rhp/v3"
+
+// An Option configures a registry Manager.
+type Option func(*Manager)
+
+// WithMaxValueSize sets the maximum size of a registry entry's data. Values
+// larger than rhp3.MaxValueDataSize are capped. The default is
+// rhp3.MaxValueDataSize.
+func WithMaxValueSize(n int) Option {
+	return func(m *Manager) {
+		if n <= 0 || n > rhp3.MaxValueDataSize {
+			n = rhp3.MaxValueDataSize
+		}
+		m.maxValueSize = n
+	}
+}
@@ -17,22 +17,24 @@ type (
 		mu sync.Mutex
 		r  uint64
 		w  uint64
+		// rejected is the number of writes that failed validation
+		rejected uint64
 	}
 )
 
 // Flush persists the number of sectors read and written.
 func (rr *registryAccessRecorder) Flush() {
 	rr.mu.Lock()
-	r, w := rr.r, rr.w
-	rr.r, rr.w = 0, 0
+	r, w, rejected := rr.r, rr.w, rr.rejected
+	rr.r, rr.w, rr.rejected = 0, 0, 0
 	rr.mu.Unlock()
 
 	// no need to persist if there is no change
-	if r == 0 && w == 0 {
+	if r == 0 && w == 0 && rejected == 0 {
 		return
 	}
 
-	if err := rr.store.IncrementRegistryAccess(r, w); err != nil {
+	if err := rr.store.IncrementRegistryAccess(r, w, rejected); err != nil {
 		rr.log.Error("failed to persist sector access", zap.Error(err))
 		return
 	}
@@ -52,6 +54,13 @@ func (rr *registryAccessRecorder) AddWrite() {
 	rr.w++
 }
 
+// AddRejection increments the number of rejected writes by 1.
+func (rr *registryAccessRecorder) AddRejection() {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.rejected++
+}
+
 // Run starts the recorder, flushing data at regular intervals.
 func (rr *registryAccessRecorder) Run(stop <-chan struct{}) {
 	t := time.NewTicker(flushInterval)
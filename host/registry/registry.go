@@ -19,6 +19,16 @@ var (
 	// ErrNotEnoughSpace should be returned when the registry is full and
 	// there is no more space to store a new entry.
 	ErrNotEnoughSpace = errors.New("not enough space")
+
+	// ErrValueTooLarge is returned when a registry entry's data exceeds the
+	// host's maximum value size.
+	ErrValueTooLarge = errors.New("registry value too large")
+	// ErrInvalidSignature is returned when a registry entry is not signed by
+	// its public key.
+	ErrInvalidSignature = errors.New("invalid registry entry signature")
+	// ErrStaleRevision is returned when a registry update has a lower
+	// revision number than the current entry.
+	ErrStaleRevision = errors.New("stale registry revision")
 )
 
 type (
@@ -36,12 +46,15 @@ type (
 		// maximum number of entries the registry can hold.
 		RegistryEntries() (count uint64, total uint64, err error)
 
-		IncrementRegistryAccess(read, write uint64) error
+		// IncrementRegistryAccess increments the registry read, write, and
+		// rejected write metrics.
+		IncrementRegistryAccess(read, write, rejected uint64) error
 	}
 
 	// A Manager manages registry entries stored in a RegistryStore.
 	Manager struct {
-		hostID types.Hash256
+		hostID       types.Hash256
+		maxValueSize int
 
 		store    Store
 		tg       *threadgroup.ThreadGroup
@@ -83,7 +96,14 @@ func (r *Manager) Put(entry rhp3.RegistryEntry, expirationHeight uint64) (rhp3.R
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if err := rhp3.ValidateRegistryEntry(entry); err != nil {
+	if len(entry.Data) > r.maxValueSize {
+		r.recorder.AddRejection()
+		return rhp3.RegistryValue{}, fmt.Errorf("invalid registry entry: %w: %d > %d", ErrValueTooLarge, len(entry.Data), r.maxValueSize)
+	} else if !entry.PublicKey.VerifyHash(entry.Hash(), entry.Signature) {
+		r.recorder.AddRejection()
+		return rhp3.RegistryValue{}, fmt.Errorf("invalid registry entry: %w", ErrInvalidSignature)
+	} else if err := rhp3.ValidateRegistryEntry(entry); err != nil {
+		r.recorder.AddRejection()
 		return rhp3.RegistryValue{}, fmt.Errorf("invalid registry entry: %w", err)
 	}
 
@@ -103,7 +123,11 @@ func (r *Manager) Put(entry rhp3.RegistryEntry, expirationHeight uint64) (rhp3.R
 		RegistryValue: old,
 	}
 
-	if err := rhp3.ValidateRegistryUpdate(oldEntry, entry, r.hostID); err != nil {
+	if entry.Revision < old.Revision {
+		r.recorder.AddRejection()
+		return old, fmt.Errorf("invalid registry update: %w: %d < %d", ErrStaleRevision, entry.Revision, old.Revision)
+	} else if err := rhp3.ValidateRegistryUpdate(oldEntry, entry, r.hostID); err != nil {
+		r.recorder.AddRejection()
 		return old, fmt.Errorf("invalid registry update: %w", err)
 	} else if err = r.store.SetRegistryValue(entry, expirationHeight); err != nil {
 		return old, fmt.Errorf("failed to update registry key: %w", err)
@@ -113,15 +137,20 @@ func (r *Manager) Put(entry rhp3.RegistryEntry, expirationHeight uint64) (rhp3.R
 }
 
 // NewManager returns a new registry manager.
-func NewManager(privkey types.PrivateKey, store Store, log *zap.Logger) *Manager {
+func NewManager(privkey types.PrivateKey, store Store, log *zap.Logger, opts ...Option) *Manager {
 	m := &Manager{
-		hostID: rhp3.RegistryHostID(privkey.PublicKey()),
-		tg:     threadgroup.New(),
-		store:  store,
+		hostID:       rhp3.RegistryHostID(privkey.PublicKey()),
+		maxValueSize: rhp3.MaxValueDataSize,
+		tg:           threadgroup.New(),
+		store:        store,
 		recorder: &registryAccessRecorder{
-			log: log.Named("recorder"),
+			store: store,
+			log:   log.Named("recorder"),
 		},
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
 	done, _ := m.tg.Add()
 	go func() {
 		m.recorder.Run(m.tg.Done())
@@ -1,9 +1,11 @@
 package registry_test
 
 import (
+	"errors"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
@@ -147,3 +149,72 @@ func TestRegistryPut(t *testing.T) {
 		t.Fatalf("expected cap error")
 	}
 }
+
+func TestRegistryValidation(t *testing.T) {
+	const maxValueSize = 16
+	hostPriv := types.GeneratePrivateKey()
+	renterPriv := types.GeneratePrivateKey()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(t.TempDir(), "hostdb.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.UpdateSettings(settings.Settings{MaxRegistryEntries: 10}); err != nil {
+		t.Fatal(err)
+	}
+	reg := registry.NewManager(hostPriv, db, log.Named("registry"), registry.WithMaxValueSize(maxValueSize))
+
+	sign := func(entry rhp3.RegistryEntry) rhp3.RegistryEntry {
+		entry.Signature = renterPriv.SignHash(entry.Hash())
+		return entry
+	}
+
+	// an entry larger than the configured cap should be rejected
+	entry := randomValue(renterPriv)
+	entry.Data = frand.Bytes(maxValueSize + 1)
+	entry = sign(entry)
+	if _, err := reg.Put(entry, 10); !errors.Is(err, registry.ErrValueTooLarge) {
+		t.Fatalf("expected ErrValueTooLarge, got %v", err)
+	}
+
+	// an entry with an invalid signature should be rejected
+	entry.Data = frand.Bytes(maxValueSize)
+	if _, err := reg.Put(entry, 10); !errors.Is(err, registry.ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	// store a valid entry
+	entry.Revision = 5
+	entry = sign(entry)
+	if _, err := reg.Put(entry, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// an update with a lower revision should be rejected and return the
+	// current value
+	stale := entry
+	stale.Revision = 4
+	stale.Data = frand.Bytes(maxValueSize)
+	stale = sign(stale)
+	current, err := reg.Put(stale, 10)
+	if !errors.Is(err, registry.ErrStaleRevision) {
+		t.Fatalf("expected ErrStaleRevision, got %v", err)
+	} else if !reflect.DeepEqual(current, entry.RegistryValue) {
+		t.Fatal("expected current value to be returned")
+	}
+
+	// flush the recorded metrics
+	if err := reg.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := db.Metrics(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	} else if m.Registry.Rejections != 3 {
+		t.Fatalf("expected 3 rejections, got %v", m.Registry.Rejections)
+	} else if m.Registry.Writes != 0 {
+		t.Fatalf("expected 0 writes, got %v", m.Registry.Writes)
+	}
+}
@@ -1,7 +1,9 @@
 package settings
 
 import (
+	"bytes"
 	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"time"
 
@@ -23,12 +25,43 @@ type (
 		Index     types.ChainIndex `json:"index"`
 		PublicKey types.PublicKey  `json:"publicKey"`
 		Address   string           `json:"address"`
+		Fee       types.Currency   `json:"fee"`
 	}
 )
 
 // constant to overwrite announcement alerts instead of registering new ones
 var alertAnnouncementID = frand.Entropy256()
 
+// ErrAnnouncementUnaffordable is returned when the wallet's spendable balance
+// does not cover the announcement fee. The announcement will be retried after
+// the debounce period.
+var ErrAnnouncementUnaffordable = errors.New("wallet balance is too low to pay the announcement fee")
+
+// announcementMinerFee returns the miner fee to use for an announcement
+// transaction.
+func (m *ConfigManager) announcementMinerFee() types.Currency {
+	if !m.announcementFee.IsZero() {
+		return m.announcementFee
+	}
+	return m.tp.RecommendedFee().Mul64(announcementTxnSize)
+}
+
+// minerFees returns the sum of a transaction's miner fees.
+func minerFees(txn stypes.Transaction) (total types.Currency) {
+	for _, fee := range txn.MinerFees {
+		var buf bytes.Buffer
+		fee.MarshalSia(&buf)
+		var c types.V1Currency
+		d := types.NewBufDecoder(buf.Bytes())
+		c.DecodeFrom(d)
+		if d.Err() != nil {
+			panic(d.Err()) // should never happen
+		}
+		total = total.Add(types.Currency(c))
+	}
+	return
+}
+
 // Announce announces the host to the network
 func (m *ConfigManager) Announce() error {
 	// get the current settings
@@ -42,14 +75,31 @@ func (m *ConfigManager) Announce() error {
 		return err
 	}
 
-	// create a transaction with an announcement
-	minerFee := m.tp.RecommendedFee().Mul64(announcementTxnSize)
+	// check that the wallet can afford the fee before funding the
+	// transaction
+	minerFee := m.announcementMinerFee()
+	spendable, _, _, err := m.wallet.Balance()
+	if err != nil {
+		return fmt.Errorf("failed to get wallet balance: %w", err)
+	} else if spendable.Cmp(minerFee) < 0 {
+		return fmt.Errorf("%w: fee %v, spendable %v", ErrAnnouncementUnaffordable, minerFee, spendable)
+	}
+
+	// create a transaction with an announcement for each additional
+	// address. The net address is announced last so that it is recorded as
+	// the host's latest announcement.
 	txn := types.Transaction{
-		ArbitraryData: [][]byte{
-			createAnnouncement(m.hostKey, settings.NetAddress),
-		},
 		MinerFees: []types.Currency{minerFee},
 	}
+	for _, addr := range m.networkAddrs {
+		if addr == settings.NetAddress {
+			continue
+		} else if err := validateNetAddress(addr); err != nil {
+			return fmt.Errorf("invalid network address %q: %w", addr, err)
+		}
+		txn.ArbitraryData = append(txn.ArbitraryData, createAnnouncement(m.hostKey, addr))
+	}
+	txn.ArbitraryData = append(txn.ArbitraryData, createAnnouncement(m.hostKey, settings.NetAddress))
 
 	// fund the transaction
 	toSign, release, err := m.wallet.FundTransaction(&txn, minerFee)
@@ -67,7 +117,7 @@ func (m *ConfigManager) Announce() error {
 	if err != nil {
 		return fmt.Errorf("failed to broadcast transaction: %w", err)
 	}
-	m.log.Debug("broadcast announcement", zap.String("transactionID", txn.ID().String()), zap.String("netaddress", settings.NetAddress), zap.String("cost", minerFee.ExactString()))
+	m.log.Debug("broadcast announcement", zap.String("transactionID", txn.ID().String()), zap.String("netaddress", settings.NetAddress), zap.Strings("networkAddresses", m.networkAddrs), zap.String("cost", minerFee.ExactString()))
 	return nil
 }
 
@@ -125,6 +175,7 @@ func (cm *ConfigManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 						Height: blockHeight,
 					},
 				}
+				announcement.Fee = minerFees(txn)
 
 				if announcement.PublicKey != hostPub {
 					continue
@@ -141,6 +192,7 @@ func (cm *ConfigManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 					Data: map[string]any{
 						"address": announcement.Address,
 						"height":  blockHeight,
+						"fee":     announcement.Fee,
 					},
 					Timestamp: time.Now(),
 				})
@@ -168,12 +220,14 @@ func (cm *ConfigManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 	currentNetAddress := cm.settings.NetAddress
 	cm.scanHeight = uint64(cc.BlockHeight)
 	timestamp := time.Unix(int64(cc.AppliedBlocks[len(cc.AppliedBlocks)-1].Timestamp), 0)
-	nextAnnounceHeight := lastAnnouncement.Index.Height + autoAnnounceInterval
+	nextAnnounceHeight := lastAnnouncement.Index.Height + cm.announceInterval
 
 	log = log.With(zap.Uint64("currentHeight", cm.scanHeight), zap.Uint64("lastHeight", lastAnnouncement.Index.Height), zap.Uint64("nextHeight", nextAnnounceHeight), zap.String("currentAddress", currentNetAddress), zap.String("oldAddress", lastAnnouncement.Address))
 
-	// if the address hasn't changed, don't reannounce
-	if cm.scanHeight < nextAnnounceHeight && currentNetAddress == lastAnnouncement.Address {
+	// if the address hasn't changed, only reannounce to refresh a stale
+	// announcement
+	refresh := currentNetAddress == lastAnnouncement.Address
+	if refresh && (cm.announceInterval == 0 || cm.scanHeight < nextAnnounceHeight) {
 		log.Debug("skipping announcement for unchanged address")
 		return
 	}
@@ -188,7 +242,24 @@ func (cm *ConfigManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 
 	// in go-routine to prevent deadlock with TPool
 	go func() {
-		if err := cm.Announce(); err != nil {
+		if fee := cm.announcementMinerFee(); refresh && !cm.maxRefreshFee.IsZero() && fee.Cmp(cm.maxRefreshFee) > 0 {
+			log.Debug("deferring announcement refresh, fee too high", zap.Stringer("fee", fee), zap.Stringer("maxFee", cm.maxRefreshFee))
+			return
+		}
+
+		if err := cm.Announce(); errors.Is(err, ErrAnnouncementUnaffordable) {
+			log.Warn("deferring announcement", zap.Error(err))
+			cm.a.Register(alerts.Alert{
+				ID:       alertAnnouncementID,
+				Severity: alerts.SeverityWarning,
+				Message:  "Announcement deferred, wallet balance too low",
+				Data: map[string]any{
+					"error": err.Error(),
+				},
+				Timestamp: time.Now(),
+			})
+			return
+		} else if err != nil {
 			log.Error("failed to announce host", zap.Error(err))
 			cm.a.Register(alerts.Alert{
 				ID:       alertAnnouncementID,
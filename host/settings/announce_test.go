@@ -1,7 +1,9 @@
 package settings_test
 
 import (
+	"errors"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +13,8 @@ import (
 	"go.sia.tech/hostd/internal/test"
 	"go.sia.tech/hostd/persist/sqlite"
 	"go.sia.tech/hostd/webhooks"
+	"go.sia.tech/siad/modules"
+	stypes "go.sia.tech/siad/types"
 	"go.uber.org/zap/zaptest"
 	"lukechampine.com/frand"
 )
@@ -119,3 +123,294 @@ func TestAutoAnnounce(t *testing.T) {
 		t.Fatal("announcement not updated")
 	}
 }
+
+func TestAnnounceInsufficientFunds(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	// mine past the announcement debounce without funding the wallet
+	if err := node.MineBlocks(types.VoidAddress, 20); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	announcementFee := types.Siacoins(1)
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	manager, err := settings.NewConfigManager(settings.WithHostKey(hostKey),
+		settings.WithStore(db),
+		settings.WithChainManager(node.ChainManager()),
+		settings.WithTransactionPool(node.TPool()),
+		settings.WithWallet(node),
+		settings.WithAlertManager(am),
+		settings.WithAnnouncementFee(announcementFee),
+		settings.WithLog(log.Named("settings")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	hostSettings := settings.DefaultSettings
+	hostSettings.NetAddress = "foo.bar:1234"
+	manager.UpdateSettings(hostSettings)
+
+	if err := manager.Announce(); !errors.Is(err, settings.ErrAnnouncementUnaffordable) {
+		t.Fatalf("expected ErrAnnouncementUnaffordable, got %v", err)
+	}
+
+	// wait for the manager to subscribe, then trigger an auto-announce,
+	// which should be deferred
+	time.Sleep(time.Second)
+	if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	var deferred bool
+	for _, a := range am.Active() {
+		if a.Severity == alerts.SeverityWarning && strings.Contains(a.Message, "deferred") {
+			deferred = true
+			break
+		}
+	}
+	if !deferred {
+		t.Fatal("expected announcement deferred alert")
+	} else if lastAnnouncement, err := manager.LastAnnouncement(); err != nil {
+		t.Fatal(err)
+	} else if lastAnnouncement.Index.Height != 0 {
+		t.Fatal("expected no announcement")
+	}
+
+	// fund the wallet and announce
+	if err := node.MineBlocks(node.Address(), 1); err != nil {
+		t.Fatal(err)
+	} else if err := node.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	if err := manager.Announce(); err != nil {
+		t.Fatal(err)
+	} else if err := node.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	lastAnnouncement, err := manager.LastAnnouncement()
+	if err != nil {
+		t.Fatal(err)
+	} else if lastAnnouncement.Address != "foo.bar:1234" {
+		t.Fatalf("expected announcement address %q, got %q", "foo.bar:1234", lastAnnouncement.Address)
+	} else if !lastAnnouncement.Fee.Equals(announcementFee) {
+		t.Fatalf("expected announcement fee %v, got %v", announcementFee, lastAnnouncement.Fee)
+	}
+}
+
+func TestAnnounceRefresh(t *testing.T) {
+	const interval = 20
+
+	tests := []struct {
+		name    string
+		maxFee  types.Currency
+		refresh bool
+	}{
+		{"refresh", types.ZeroCurrency, true},
+		{"fee too high", types.Siacoins(1).Div64(2), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+			dir := t.TempDir()
+			log := zaptest.NewLogger(t)
+			node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer node.Close()
+
+			// fund the wallet
+			if err := node.MineBlocks(node.Address(), 99); err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			manager, err := settings.NewConfigManager(settings.WithHostKey(hostKey),
+				settings.WithStore(db),
+				settings.WithChainManager(node.ChainManager()),
+				settings.WithTransactionPool(node.TPool()),
+				settings.WithWallet(node),
+				settings.WithAlertManager(alerts.NewManager(webhookReporter, log.Named("alerts"))),
+				settings.WithAnnouncementFee(types.Siacoins(1)),
+				settings.WithAnnounceInterval(interval),
+				settings.WithMaxRefreshFee(tt.maxFee),
+				settings.WithLog(log.Named("settings")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer manager.Close()
+
+			s := settings.DefaultSettings
+			s.NetAddress = "foo.bar:1234"
+			if err := manager.UpdateSettings(s); err != nil {
+				t.Fatal(err)
+			}
+
+			// the initial announcement is never limited by the refresh fee
+			if err := node.MineBlocks(node.Address(), 1); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Second)
+			if err := node.MineBlocks(node.Address(), 5); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Second)
+
+			lastAnnouncement, err := manager.LastAnnouncement()
+			if err != nil {
+				t.Fatal(err)
+			} else if lastAnnouncement.Index.Height == 0 {
+				t.Fatal("expected an announcement")
+			}
+			lastHeight := lastAnnouncement.Index.Height
+
+			// mine until right before the refresh
+			remainingBlocks := lastHeight + interval - node.ChainManager().TipState().Index.Height
+			if err := node.MineBlocks(node.Address(), int(remainingBlocks-1)); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Second)
+
+			if lastAnnouncement, err := manager.LastAnnouncement(); err != nil {
+				t.Fatal(err)
+			} else if lastAnnouncement.Index.Height != lastHeight {
+				t.Fatal("announcement refreshed early")
+			}
+
+			// trigger the refresh and confirm it
+			if err := node.MineBlocks(node.Address(), 1); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Second)
+			if err := node.MineBlocks(node.Address(), 2); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Second)
+
+			lastAnnouncement, err = manager.LastAnnouncement()
+			if err != nil {
+				t.Fatal(err)
+			} else if refreshed := lastAnnouncement.Index.Height > lastHeight; refreshed != tt.refresh {
+				t.Fatalf("expected refresh %v, last announcement at %v, previous %v", tt.refresh, lastAnnouncement.Index.Height, lastHeight)
+			}
+		})
+	}
+}
+
+func TestAnnounceNetworkAddresses(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	// fund the wallet
+	if err := node.MineBlocks(node.Address(), 99); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const onionAddr = "abcdefghij.onion:9982"
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	manager, err := settings.NewConfigManager(settings.WithHostKey(hostKey),
+		settings.WithStore(db),
+		settings.WithChainManager(node.ChainManager()),
+		settings.WithTransactionPool(node.TPool()),
+		settings.WithWallet(node),
+		settings.WithAlertManager(am),
+		settings.WithNetworkAnnouncements(onionAddr),
+		settings.WithLog(log.Named("settings")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	s := settings.DefaultSettings
+	s.NetAddress = "foo.bar:1234"
+	if err := manager.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the manager to subscribe, then announce
+	time.Sleep(time.Second)
+	if err := manager.Announce(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the onion address should be announced before the net address
+	var announced []string
+	for _, txn := range node.TPool().Transactions() {
+		for _, arb := range txn.ArbitraryData {
+			addr, pub, err := modules.DecodeAnnouncement(arb)
+			if err != nil || types.PublicKey(pub.Key) != hostKey.PublicKey() {
+				continue
+			}
+			announced = append(announced, string(addr))
+		}
+	}
+	if len(announced) != 2 || announced[0] != onionAddr || announced[1] != s.NetAddress {
+		t.Fatalf("expected announcements [%v %v], got %v", onionAddr, s.NetAddress, announced)
+	}
+
+	// confirm the announcement
+	if err := node.MineBlocks(node.Address(), 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	// the net address should be recorded as the last announcement
+	lastAnnouncement, err := manager.LastAnnouncement()
+	if err != nil {
+		t.Fatal(err)
+	} else if lastAnnouncement.Address != s.NetAddress {
+		t.Fatalf("expected last announcement %v, got %v", s.NetAddress, lastAnnouncement.Address)
+	}
+}
@@ -0,0 +1,192 @@
+// Package autoprice adjusts the host's storage price to steer its storage
+// utilization toward a target.
+package autoprice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/settings"
+	"go.uber.org/zap"
+)
+
+// stepPrecision is the precision the step multiplier is applied with.
+const stepPrecision = 1e6
+
+type (
+	// A SettingsManager updates the host's settings.
+	SettingsManager interface {
+		// ModifySettings calls fn with the host's settings and applies the
+		// modified settings without interleaving other updates.
+		ModifySettings(fn func(*settings.Settings) error) error
+	}
+
+	// A StorageReporter reports the host's storage utilization.
+	StorageReporter interface {
+		Usage() (usedSectors, totalSectors uint64, err error)
+	}
+
+	// Alerts registers alerts.
+	Alerts interface {
+		Register(alerts.Alert)
+	}
+
+	// An Adjustment is a change to the host's storage price.
+	Adjustment struct {
+		Utilization float64        `json:"utilization"`
+		Previous    types.Currency `json:"previous"`
+		Current     types.Currency `json:"current"`
+	}
+
+	// A Manager periodically raises the host's storage price when its
+	// storage utilization is above the target and lowers it when the
+	// utilization is below the target. Unlike pinning, which tracks an
+	// exchange rate, the manager closes the loop on the host's own demand.
+	Manager struct {
+		log     *zap.Logger
+		sm      SettingsManager
+		storage StorageReporter
+		alerts  Alerts
+
+		frequency time.Duration
+		target    float64
+		tolerance float64
+		step      float64
+		minPrice  types.Currency
+		maxPrice  types.Currency
+	}
+)
+
+// scalePrice multiplies the price by m, saturating at types.MaxCurrency.
+func scalePrice(price types.Currency, m float64) types.Currency {
+	v, overflow := price.Mul64WithOverflow(uint64(m * stepPrecision))
+	if overflow {
+		return types.MaxCurrency
+	}
+	return v.Div64(stepPrecision)
+}
+
+// nextPrice returns the storage price for the given utilization. The price is
+// unchanged if the utilization is within tolerance of the target.
+func (m *Manager) nextPrice(price types.Currency, utilization float64) types.Currency {
+	switch {
+	case utilization > m.target+m.tolerance:
+		price = scalePrice(price, 1+m.step)
+	case utilization < m.target-m.tolerance:
+		price = scalePrice(price, 1-m.step)
+	}
+
+	if price.Cmp(m.minPrice) < 0 {
+		return m.minPrice
+	} else if price.Cmp(m.maxPrice) > 0 {
+		return m.maxPrice
+	}
+	return price
+}
+
+// Adjust checks the host's storage utilization and raises or lowers the
+// storage price by one step toward the target, within the configured
+// bounds. If the price was changed, the adjustment is returned.
+func (m *Manager) Adjust() (Adjustment, bool, error) {
+	used, total, err := m.storage.Usage()
+	if err != nil {
+		return Adjustment{}, false, fmt.Errorf("failed to get storage usage: %w", err)
+	} else if total == 0 {
+		// no storage has been added yet
+		return Adjustment{}, false, nil
+	}
+	utilization := float64(used) / float64(total)
+
+	// the price is read and updated atomically so that a concurrent
+	// settings update is not overwritten with stale values
+	adj := Adjustment{Utilization: utilization}
+	err = m.sm.ModifySettings(func(hs *settings.Settings) error {
+		adj.Previous = hs.StoragePrice
+		adj.Current = m.nextPrice(hs.StoragePrice, utilization)
+		hs.StoragePrice = adj.Current
+		return nil
+	})
+	if err != nil {
+		return Adjustment{}, false, fmt.Errorf("failed to update settings: %w", err)
+	} else if adj.Current.Equals(adj.Previous) {
+		return Adjustment{}, false, nil
+	}
+
+	m.log.Info("adjusted storage price", zap.Float64("utilization", utilization), zap.Float64("target", m.target), zap.Stringer("previous", adj.Previous), zap.Stringer("current", adj.Current))
+	if m.alerts != nil {
+		m.alerts.Register(alerts.Alert{
+			ID:       types.HashBytes([]byte("storagePriceAdjusted")),
+			Severity: alerts.SeverityInfo,
+			Message:  "Storage price adjusted for utilization",
+			Data: map[string]any{
+				"utilization":   utilization,
+				"target":        m.target,
+				"previousPrice": adj.Previous,
+				"currentPrice":  adj.Current,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+	return adj, true, nil
+}
+
+// Run adjusts the storage price at the configured frequency until ctx is
+// cancelled.
+func (m *Manager) Run(ctx context.Context) error {
+	t := time.NewTicker(m.frequency)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if _, _, err := m.Adjust(); err != nil {
+				m.log.Error("failed to adjust storage price", zap.Error(err))
+			}
+		}
+	}
+}
+
+// NewManager initializes a new storage price manager.
+func NewManager(opts ...Option) (*Manager, error) {
+	m := &Manager{
+		log: zap.NewNop(),
+
+		frequency: time.Hour,
+		target:    0.8,
+		tolerance: 0.05,
+		step:      0.05,
+		maxPrice:  types.MaxCurrency,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	switch {
+	case m.sm == nil:
+		return nil, errors.New("settings manager is required")
+	case m.storage == nil:
+		return nil, errors.New("storage reporter is required")
+	case m.log == nil:
+		return nil, errors.New("logger is required")
+	case m.frequency <= 0:
+		return nil, errors.New("frequency must be positive")
+	case m.target <= 0 || m.target > 1:
+		return nil, errors.New("target utilization must be between 0 and 1")
+	case m.tolerance < 0 || m.tolerance >= 1:
+		return nil, errors.New("tolerance must be between 0 and 1")
+	case m.step <= 0 || m.step >= 1:
+		return nil, errors.New("step must be between 0 and 1")
+	case m.maxPrice.IsZero():
+		return nil, errors.New("max price must be greater than zero")
+	case m.minPrice.Cmp(m.maxPrice) > 0:
+		return nil, fmt.Errorf("min price %v is greater than max price %v", m.minPrice, m.maxPrice)
+	}
+	return m, nil
+}
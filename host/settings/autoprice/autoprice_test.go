@@ -0,0 +1,163 @@
+package autoprice_test
+
+import (
+	"sync"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/host/settings/autoprice"
+	"go.uber.org/zap/zaptest"
+)
+
+type (
+	settingsStub struct {
+		mu       sync.Mutex
+		settings settings.Settings
+	}
+
+	storageStub struct {
+		mu          sync.Mutex
+		used, total uint64
+	}
+
+	alertsStub struct {
+		registered []alerts.Alert
+	}
+)
+
+func (s *settingsStub) Settings() settings.Settings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+func (s *settingsStub) ModifySettings(fn func(*settings.Settings) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs := s.settings
+	if err := fn(&hs); err != nil {
+		return err
+	}
+	s.settings = hs
+	return nil
+}
+
+func (s *storageStub) setUsage(used, total uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used, s.total = used, total
+}
+
+func (s *storageStub) Usage() (uint64, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used, s.total, nil
+}
+
+func (a *alertsStub) Register(alert alerts.Alert) {
+	a.registered = append(a.registered, alert)
+}
+
+func TestAutoPrice(t *testing.T) {
+	initial := types.NewCurrency64(1e9)
+	minPrice, maxPrice := types.NewCurrency64(8e8), types.NewCurrency64(12e8)
+
+	sm := &settingsStub{settings: settings.Settings{StoragePrice: initial}}
+	storage := &storageStub{}
+	am := &alertsStub{}
+	m, err := autoprice.NewManager(
+		autoprice.WithSettings(sm),
+		autoprice.WithStorage(storage),
+		autoprice.WithAlerts(am),
+		autoprice.WithLogger(zaptest.NewLogger(t)),
+		autoprice.WithTarget(0.5),
+		autoprice.WithTolerance(0.1),
+		autoprice.WithStep(0.1),
+		autoprice.WithBounds(minPrice, maxPrice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// utilization within tolerance of the target should not change the price
+	storage.setUsage(55, 100)
+	if _, ok, err := m.Adjust(); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected no adjustment")
+	} else if price := sm.Settings().StoragePrice; !price.Equals(initial) {
+		t.Fatalf("expected price %v, got %v", initial, price)
+	}
+
+	// high utilization should raise the price by one step
+	storage.setUsage(90, 100)
+	adj, ok, err := m.Adjust()
+	if err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected an adjustment")
+	} else if expected := types.NewCurrency64(11e8); !adj.Current.Equals(expected) {
+		t.Fatalf("expected price %v, got %v", expected, adj.Current)
+	} else if !adj.Previous.Equals(initial) {
+		t.Fatalf("expected previous price %v, got %v", initial, adj.Previous)
+	} else if price := sm.Settings().StoragePrice; !price.Equals(adj.Current) {
+		t.Fatalf("expected settings price %v, got %v", adj.Current, price)
+	} else if len(am.registered) != 1 {
+		t.Fatalf("expected 1 alert, got %v", len(am.registered))
+	}
+
+	// sustained high utilization should stop at the max price
+	for i := 0; i < 10; i++ {
+		if _, _, err := m.Adjust(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if price := sm.Settings().StoragePrice; !price.Equals(maxPrice) {
+		t.Fatalf("expected price to be capped at %v, got %v", maxPrice, price)
+	} else if _, ok, err := m.Adjust(); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected no adjustment at the max price")
+	}
+
+	// low utilization should lower the price by one step
+	storage.setUsage(10, 100)
+	if adj, ok, err := m.Adjust(); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected an adjustment")
+	} else if expected := types.NewCurrency64(108e7); !adj.Current.Equals(expected) {
+		t.Fatalf("expected price %v, got %v", expected, adj.Current)
+	}
+
+	// sustained low utilization should stop at the min price
+	for i := 0; i < 10; i++ {
+		if _, _, err := m.Adjust(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if price := sm.Settings().StoragePrice; !price.Equals(minPrice) {
+		t.Fatalf("expected price to be floored at %v, got %v", minPrice, price)
+	}
+}
+
+func TestAutoPriceValidation(t *testing.T) {
+	sm := &settingsStub{}
+	storage := &storageStub{}
+
+	tests := []struct {
+		name string
+		opt  autoprice.Option
+	}{
+		{"target", autoprice.WithTarget(1.5)},
+		{"tolerance", autoprice.WithTolerance(-1)},
+		{"step", autoprice.WithStep(0)},
+		{"bounds", autoprice.WithBounds(types.NewCurrency64(2), types.NewCurrency64(1))},
+	}
+	for _, test := range tests {
+		if _, err := autoprice.NewManager(autoprice.WithSettings(sm), autoprice.WithStorage(storage), test.opt); err == nil {
+			t.Fatalf("expected invalid %v to be rejected", test.name)
+		}
+	}
+}
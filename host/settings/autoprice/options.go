@@ -0,0 +1,80 @@
+package autoprice
+
+import (
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// An Option is a functional option for configuring a Manager.
+type Option func(*Manager)
+
+// WithLogger sets the logger for the manager.
+func WithLogger(log *zap.Logger) Option {
+	return func(m *Manager) {
+		m.log = log
+	}
+}
+
+// WithFrequency sets how often the manager checks the host's utilization and
+// adjusts the storage price.
+func WithFrequency(frequency time.Duration) Option {
+	return func(m *Manager) {
+		m.frequency = frequency
+	}
+}
+
+// WithSettings sets the settings manager for the manager.
+func WithSettings(s SettingsManager) Option {
+	return func(m *Manager) {
+		m.sm = s
+	}
+}
+
+// WithStorage sets the storage reporter used to get the host's utilization.
+func WithStorage(s StorageReporter) Option {
+	return func(m *Manager) {
+		m.storage = s
+	}
+}
+
+// WithAlerts sets the alerts manager adjustments are reported to.
+func WithAlerts(a Alerts) Option {
+	return func(m *Manager) {
+		m.alerts = a
+	}
+}
+
+// WithTarget sets the storage utilization, between 0 and 1, the manager
+// steers the host toward.
+func WithTarget(utilization float64) Option {
+	return func(m *Manager) {
+		m.target = utilization
+	}
+}
+
+// WithTolerance sets how far the utilization may be from the target before
+// the price is changed.
+func WithTolerance(tolerance float64) Option {
+	return func(m *Manager) {
+		m.tolerance = tolerance
+	}
+}
+
+// WithStep sets the fraction the storage price is raised or lowered by in
+// each adjustment.
+func WithStep(step float64) Option {
+	return func(m *Manager) {
+		m.step = step
+	}
+}
+
+// WithBounds sets the minimum and maximum storage price, in Hastings per
+// byte per block.
+func WithBounds(min, max types.Currency) Option {
+	return func(m *Manager) {
+		m.minPrice = min
+		m.maxPrice = max
+	}
+}
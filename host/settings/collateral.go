@@ -0,0 +1,166 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/rhp"
+	"go.uber.org/zap"
+)
+
+// collateralRefreshInterval is how often the storage utilization used to
+// scale the advertised collateral is refreshed.
+const collateralRefreshInterval = 10 * time.Minute
+
+// A StorageReporter reports the host's storage utilization.
+type StorageReporter interface {
+	Usage() (usedSectors, totalSectors uint64, err error)
+}
+
+// DynamicCollateral returns true if the advertised collateral is scaled by
+// storage utilization.
+func (s Settings) DynamicCollateral() bool {
+	return s.MinCollateralScale > 0 || s.MaxCollateralScale > 0
+}
+
+// CollateralScale returns the factor the collateral multiplier and max
+// collateral are scaled by at the given storage utilization. The scale
+// decreases linearly from MaxCollateralScale when the host is empty to
+// MinCollateralScale when it is full. If dynamic collateral is disabled, the
+// scale is 1.
+func (s Settings) CollateralScale(utilization float64) float64 {
+	if !s.DynamicCollateral() {
+		return 1
+	}
+	switch {
+	case utilization < 0:
+		utilization = 0
+	case utilization > 1:
+		utilization = 1
+	}
+	return s.MaxCollateralScale - (s.MaxCollateralScale-s.MinCollateralScale)*utilization
+}
+
+// validateDynamicCollateral checks that the collateral scale bounds are
+// valid and that the collateral stays valid at the upper bound.
+func validateDynamicCollateral(s Settings) error {
+	if !s.DynamicCollateral() {
+		return nil
+	} else if s.MinCollateralScale <= 0 {
+		return errors.New("min collateral scale must be greater than zero")
+	} else if s.MaxCollateralScale < s.MinCollateralScale {
+		return fmt.Errorf("max collateral scale %g is less than the min collateral scale %g", s.MaxCollateralScale, s.MinCollateralScale)
+	} else if _, err := rhp.CollateralPrice(s.StoragePrice, s.CollateralMultiplier*s.MaxCollateralScale); err != nil {
+		return fmt.Errorf("invalid collateral at max scale: %w", err)
+	}
+	return nil
+}
+
+// applyCollateralScale returns a copy of the settings with the collateral
+// multiplier and max collateral scaled for the given storage utilization.
+func applyCollateralScale(s Settings, utilization float64) Settings {
+	if !s.DynamicCollateral() {
+		return s
+	}
+	scale := s.CollateralScale(utilization)
+	s.CollateralMultiplier *= scale
+	s.MaxCollateral = applyMultiplier(s.MaxCollateral, scale)
+	return s
+}
+
+// SetStorageUtilization updates the storage utilization used to scale the
+// advertised collateral.
+func (m *ConfigManager) SetStorageUtilization(usedSectors, totalSectors uint64) {
+	var utilization float64
+	if totalSectors > 0 {
+		utilization = float64(usedSectors) / float64(totalSectors)
+	}
+
+	m.mu.Lock()
+	if utilization == m.utilization {
+		m.mu.Unlock()
+		return
+	}
+	m.utilization = utilization
+	m.updateSnapshot()
+	m.mu.Unlock()
+
+	m.checkUploadPrice()
+}
+
+// updateSnapshot replaces the advertised settings snapshot with the current
+// settings. mu must be held.
+func (m *ConfigManager) updateSnapshot() {
+	advertised := applyCollateralScale(m.settings, m.utilization)
+	m.snapshot.Store(&advertised)
+}
+
+// checkUploadPrice warns the operator if the advertised ingress price does not
+// cover the minimum for the longest contract the host accepts. Uploads to
+// contracts whose remaining duration requires a higher price are rejected.
+// This can happen when the advertised collateral is scaled above the
+// configured collateral. mu must not be held.
+func (m *ConfigManager) checkUploadPrice() {
+	s := m.AdvertisedSettings()
+	min := s.MinIngressPrice(s.MaxContractDuration)
+	if s.IngressPrice.Cmp(min) >= 0 {
+		if m.a != nil {
+			m.a.Dismiss(alertIngressPriceID)
+		}
+		return
+	}
+
+	m.log.Warn("advertised ingress price is below the collateral minimum", zap.Stringer("ingressPrice", s.IngressPrice), zap.Stringer("minIngressPrice", min), zap.Float64("collateralMultiplier", s.CollateralMultiplier))
+	if m.a == nil {
+		return
+	}
+	m.a.Register(alerts.Alert{
+		ID:       alertIngressPriceID,
+		Severity: alerts.SeverityWarning,
+		Message:  "Advertised ingress price is below the collateral minimum",
+		Data: map[string]any{
+			"ingressPrice":         s.IngressPrice,
+			"minIngressPrice":      min,
+			"maxContractDuration":  s.MaxContractDuration,
+			"collateralMultiplier": s.CollateralMultiplier,
+			"hint":                 "Uploads to long contracts are rejected. Raise the ingress price or lower the minimum ingress collateral ratio",
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// AdvertisedSettings returns the settings advertised to renters: the host's
+// settings with the collateral scaled by the current storage utilization. RHP
+// settings and price tables should be derived from a single call so that
+// concurrent updates cannot produce mismatched prices.
+func (m *ConfigManager) AdvertisedSettings() Settings {
+	return *m.snapshot.Load()
+}
+
+// RunDynamicCollateral periodically refreshes the storage utilization used to
+// scale the advertised collateral until ctx is cancelled.
+func (m *ConfigManager) RunDynamicCollateral(ctx context.Context, sr StorageReporter) {
+	refresh := func() {
+		used, total, err := sr.Usage()
+		if err != nil {
+			m.log.Error("failed to get storage usage", zap.Error(err))
+			return
+		}
+		m.SetStorageUtilization(used, total)
+	}
+
+	refresh()
+	t := time.NewTicker(collateralRefreshInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			refresh()
+		}
+	}
+}
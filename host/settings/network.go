@@ -0,0 +1,63 @@
+package settings
+
+import (
+	"math/big"
+
+	"go.sia.tech/core/types"
+)
+
+// A NetworkPolicy overrides the host's contract acceptance and pricing for
+// renters connecting over a specific network, such as Tor.
+type NetworkPolicy struct {
+	// RejectContracts rejects contract formation and renewal over the
+	// network.
+	RejectContracts bool `json:"rejectContracts"`
+	// PriceMultiplier is applied to the host's prices for renters connecting
+	// over the network, e.g. 1.5 for a 50% premium. The collateral is not
+	// changed. Zero is treated as 1.
+	PriceMultiplier float64 `json:"priceMultiplier"`
+	// NetAddress is the address advertised to renters connecting over the
+	// network. The host's net address is advertised if empty.
+	NetAddress string `json:"netAddress"`
+}
+
+// applyMultiplier returns c multiplied by m, rounded down. The multiplier is
+// applied exactly, so small prices are not truncated to zero. The result
+// saturates at the maximum currency value.
+func applyMultiplier(c types.Currency, m float64) types.Currency {
+	r := new(big.Rat).SetFloat64(m)
+	if r == nil {
+		return types.MaxCurrency
+	} else if r.Sign() <= 0 {
+		return types.ZeroCurrency
+	}
+	v := new(big.Int).Mul(c.Big(), r.Num())
+	v.Quo(v, r.Denom())
+	if v.BitLen() > 128 {
+		return types.MaxCurrency
+	}
+	return types.NewCurrency(v.Uint64(), new(big.Int).Rsh(v, 64).Uint64())
+}
+
+// Apply returns a copy of the settings with the policy applied.
+func (p NetworkPolicy) Apply(s Settings) Settings {
+	if p.RejectContracts {
+		s.AcceptingContracts = false
+	}
+	if p.NetAddress != "" {
+		s.NetAddress = p.NetAddress
+	}
+	if p.PriceMultiplier > 0 && p.PriceMultiplier != 1 {
+		s.ContractPrice = applyMultiplier(s.ContractPrice, p.PriceMultiplier)
+		s.BaseRPCPrice = applyMultiplier(s.BaseRPCPrice, p.PriceMultiplier)
+		s.SectorAccessPrice = applyMultiplier(s.SectorAccessPrice, p.PriceMultiplier)
+		s.StoragePrice = applyMultiplier(s.StoragePrice, p.PriceMultiplier)
+		s.IngressPrice = applyMultiplier(s.IngressPrice, p.PriceMultiplier)
+		s.EgressPrice = applyMultiplier(s.EgressPrice, p.PriceMultiplier)
+		// collateral is derived from the storage price. Scale the
+		// multiplier down so the premium does not increase the risked
+		// collateral.
+		s.CollateralMultiplier /= p.PriceMultiplier
+	}
+	return s
+}
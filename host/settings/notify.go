@@ -0,0 +1,99 @@
+package settings
+
+import (
+	"reflect"
+	"strings"
+)
+
+type (
+	// ChangedFields is the set of settings fields, identified by their JSON
+	// name, that were changed by an update.
+	ChangedFields map[string]bool
+
+	// A SettingsSubscriber is called after the host's settings are updated
+	// with the previous settings, the new settings, and the changed fields.
+	SettingsSubscriber func(old, new Settings, changed ChangedFields)
+
+	subscription struct {
+		id     uint64
+		fields []string
+		fn     SettingsSubscriber
+	}
+)
+
+// Any returns true if any of the fields changed.
+func (cf ChangedFields) Any(fields ...string) bool {
+	for _, field := range fields {
+		if cf[field] {
+			return true
+		}
+	}
+	return false
+}
+
+// settingsFieldNames maps the index of each settings field to its JSON name.
+var settingsFieldNames = func() []string {
+	t := reflect.TypeOf(Settings{})
+	names := make([]string, t.NumField())
+	for i := range names {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		names[i] = name
+	}
+	return names
+}()
+
+// diffSettings returns the fields that differ between the two settings.
+func diffSettings(old, new Settings) ChangedFields {
+	changed := make(ChangedFields)
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i, name := range settingsFieldNames {
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed[name] = true
+		}
+	}
+	return changed
+}
+
+// Subscribe registers fn to be called after each settings update that
+// changes at least one of the given fields. If no fields are given, fn is
+// called after every update that changes any field. Subscribers are called
+// synchronously, in the order updates are applied, and must not update the
+// settings. The returned function removes the subscription.
+func (m *ConfigManager) Subscribe(fn SettingsSubscriber, fields ...string) (unsubscribe func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.nextSubscriberID
+	m.nextSubscriberID++
+	m.subscribers = append(m.subscribers, subscription{id: id, fields: fields, fn: fn})
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for i, sub := range m.subscribers {
+			if sub.id == id {
+				m.subscribers = append(m.subscribers[:i:i], m.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notifySubscribers calls each subscriber interested in the changed fields.
+// updateMu must be held.
+func (m *ConfigManager) notifySubscribers(old, new Settings, changed ChangedFields) {
+	if len(changed) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	subscribers := m.subscribers
+	m.mu.Unlock()
+
+	for _, sub := range subscribers {
+		if len(sub.fields) == 0 || changed.Any(sub.fields...) {
+			sub.fn(old, new, changed)
+		}
+	}
+}
@@ -72,3 +72,43 @@ func WithCertificateFiles(certFilePath, keyFilePath string) Option {
 		c.certKeyFilePath = keyFilePath
 	}
 }
+
+// WithAnnouncementFee sets a fixed miner fee for announcement transactions. If
+// the fee is zero, the transaction pool's recommended fee is used.
+func WithAnnouncementFee(fee types.Currency) Option {
+	return func(c *ConfigManager) {
+		c.announcementFee = fee
+	}
+}
+
+// WithAnnounceInterval sets the number of blocks after which the host
+// re-announces itself, even if its address has not changed, so that it stays
+// fresh in renters' host databases. An interval of 0 disables refreshes; the
+// host still announces when its address changes. Intervals shorter than the
+// announcement debounce are raised to it.
+func WithAnnounceInterval(blocks uint64) Option {
+	return func(c *ConfigManager) {
+		c.announceInterval = blocks
+	}
+}
+
+// WithMaxRefreshFee sets the maximum miner fee to pay for a refresh
+// announcement. Refreshes are deferred while the announcement fee is higher.
+// Announcements of a changed address are not limited. If the fee is zero,
+// refreshes are not limited.
+func WithMaxRefreshFee(fee types.Currency) Option {
+	return func(c *ConfigManager) {
+		c.maxRefreshFee = fee
+	}
+}
+
+// WithNetworkAnnouncements sets additional addresses, such as the host's onion
+// address, that are included in every announcement. They are announced
+// before the host's net address so that renters keeping only the latest
+// announcement still see the net address. Changing only these addresses does
+// not trigger a new announcement.
+func WithNetworkAnnouncements(addrs ...string) Option {
+	return func(c *ConfigManager) {
+		c.networkAddrs = append(c.networkAddrs, addrs...)
+	}
+}
@@ -52,3 +52,12 @@ func WithAverageRateWindow(window time.Duration) Option {
 		m.rateWindow = window
 	}
 }
+
+// WithAutoStoragePrice indicates that the host's storage price is adjusted
+// automatically for its utilization. Pinning the storage price would fight
+// the adjustments, so it is rejected.
+func WithAutoStoragePrice() Option {
+	return func(m *Manager) {
+		m.autoStoragePrice = true
+	}
+}
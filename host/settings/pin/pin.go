@@ -72,6 +72,8 @@ type (
 
 		frequency  time.Duration
 		rateWindow time.Duration
+		// autoStoragePrice rejects pinning the storage price
+		autoStoragePrice bool
 
 		mu       sync.Mutex
 		rates    []decimal.Decimal
@@ -80,6 +82,10 @@ type (
 	}
 )
 
+// ErrStoragePriceManaged is returned when the storage price is pinned while it
+// is adjusted automatically for the host's utilization.
+var ErrStoragePriceManaged = errors.New("storage price cannot be pinned while automatic storage pricing is enabled")
+
 // IsPinned returns true if the pin is enabled and the value is greater than 0.
 func (p Pin) IsPinned() bool {
 	return p.Pinned && p.Value > 0
@@ -199,6 +205,8 @@ func (m *Manager) Update(ctx context.Context, p PinnedSettings) error {
 		return fmt.Errorf("threshold must be between 0 and 1")
 	case p.Storage.Pinned && p.Storage.Value <= 0:
 		return fmt.Errorf("storage price must be greater than 0")
+	case p.Storage.IsPinned() && m.autoStoragePrice:
+		return ErrStoragePriceManaged
 	case p.Ingress.Pinned && p.Ingress.Value <= 0:
 		return fmt.Errorf("ingress price must be greater than 0")
 	case p.Egress.Pinned && p.Egress.Value <= 0:
@@ -292,6 +300,9 @@ func NewManager(opts ...Option) (*Manager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pinned settings: %w", err)
 	}
+	if pinned.Storage.IsPinned() && m.autoStoragePrice {
+		return nil, ErrStoragePriceManaged
+	}
 	m.settings = pinned
 	return m, nil
 }
@@ -320,3 +320,70 @@ func TestAutomaticUpdate(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestAutoStoragePrice(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rr := &exchangeRateRetrieverStub{
+		value:    1,
+		currency: "usd",
+	}
+
+	node, err := test.NewNode(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	sm, err := settings.NewConfigManager(settings.WithHostKey(types.GeneratePrivateKey()), settings.WithStore(db), settings.WithChainManager(node.ChainManager()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	opts := []pin.Option{
+		pin.WithExchangeRateRetriever(rr),
+		pin.WithSettings(sm),
+		pin.WithStore(db),
+		pin.WithLogger(log.Named("pin")),
+	}
+	pm, err := pin.NewManager(append(opts, pin.WithAutoStoragePrice())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := pin.PinnedSettings{
+		Currency:  "usd",
+		Threshold: 0.1,
+		Storage:   pin.Pin{Pinned: true, Value: 1.0},
+	}
+
+	// the storage price is adjusted automatically, so it cannot be pinned
+	if err := pm.Update(context.Background(), p); !errors.Is(err, pin.ErrStoragePriceManaged) {
+		t.Fatalf("expected ErrStoragePriceManaged, got %v", err)
+	}
+
+	// other prices can still be pinned
+	p.Storage.Pinned = false
+	p.Ingress = pin.Pin{Pinned: true, Value: 1.0}
+	if err := pm.Update(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	// a previously pinned storage price conflicts with automatic pricing
+	pm, err = pin.NewManager(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Storage.Pinned = true
+	if err := pm.Update(context.Background(), p); err != nil {
+		t.Fatal(err)
+	} else if _, err := pin.NewManager(append(opts, pin.WithAutoStoragePrice())...); !errors.Is(err, pin.ErrStoragePriceManaged) {
+		t.Fatalf("expected ErrStoragePriceManaged, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package settings
+
+import "sort"
+
+// A SettingsPreview is the effect of a settings update computed without
+// applying it. It does not include later adjustments by pinning or
+// automatic storage pricing.
+type SettingsPreview struct {
+	// Settings are the proposed settings after validation.
+	Settings Settings `json:"settings"`
+	// Advertised are the settings that would be advertised to renters, with
+	// the collateral scaled by the current storage utilization.
+	Advertised Settings `json:"advertised"`
+	// Changed contains the JSON names of the fields the update would change.
+	Changed []string `json:"changed"`
+	// ContractsPaused is true if contract formation would remain paused
+	// regardless of the AcceptingContracts setting.
+	ContractsPaused bool `json:"contractsPaused"`
+}
+
+// PreviewSettings validates the proposed settings and returns the settings
+// that would be advertised if they were applied. The host's settings are not
+// changed.
+//
+// The preview only reflects adjustments made by the config manager: dynamic
+// collateral scaling and the database and proof breaker pauses. Prices set
+// later by pinning or automatic storage pricing, and the RHP2 storage headroom
+// check, are applied outside of the config manager and are not previewed.
+func (m *ConfigManager) PreviewSettings(proposed Settings) (SettingsPreview, error) {
+	if err := validateSettings(&proposed); err != nil {
+		return SettingsPreview{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := make([]string, 0)
+	for field := range diffSettings(m.settings, proposed) {
+		changed = append(changed, field)
+	}
+	sort.Strings(changed)
+
+	return SettingsPreview{
+		Settings:        proposed,
+		Advertised:      applyCollateralScale(proposed, m.utilization),
+		Changed:         changed,
+		ContractsPaused: m.databaseFull || m.proofBreakerTripped,
+	}, nil
+}
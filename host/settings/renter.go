@@ -0,0 +1,38 @@
+package settings
+
+// A RenterPolicy adjusts contract acceptance and pricing based on the number
+// of contracts a renter has successfully completed with the host.
+type RenterPolicy struct {
+	// MinSuccessfulContracts is the number of successful contracts a renter
+	// must have before the host forms new contracts with it. 0 accepts new
+	// renters.
+	MinSuccessfulContracts uint64 `json:"minSuccessfulContracts"`
+	// EstablishedContracts is the number of successful contracts after
+	// which a renter is considered established.
+	EstablishedContracts uint64 `json:"establishedContracts"`
+	// NewRenterPriceMultiplier is applied to the contract price charged to
+	// renters that are not established, e.g. 1.5 for a 50% premium. Zero is
+	// treated as 1.
+	NewRenterPriceMultiplier float64 `json:"newRenterPriceMultiplier"`
+}
+
+// Accepts returns true if the host should form contracts with a renter that
+// has the given number of successful contracts.
+func (p RenterPolicy) Accepts(successful uint64) bool {
+	return successful >= p.MinSuccessfulContracts
+}
+
+// Established returns true if a renter with the given number of successful
+// contracts is established.
+func (p RenterPolicy) Established(successful uint64) bool {
+	return successful >= p.EstablishedContracts
+}
+
+// Apply returns a copy of the settings with the policy applied for a renter
+// with the given number of successful contracts.
+func (p RenterPolicy) Apply(successful uint64, s Settings) Settings {
+	if !p.Established(successful) && p.NewRenterPriceMultiplier > 0 && p.NewRenterPriceMultiplier != 1 {
+		s.ContractPrice = applyMultiplier(s.ContractPrice, p.NewRenterPriceMultiplier)
+	}
+	return s
+}
@@ -9,16 +9,20 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.sia.tech/core/consensus"
+	rhp2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/alerts"
 	"go.sia.tech/hostd/internal/chain"
 	"go.sia.tech/hostd/internal/threadgroup"
+	"go.sia.tech/hostd/rhp"
 	"go.sia.tech/siad/modules"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
+	"lukechampine.com/frand"
 )
 
 const (
@@ -32,6 +36,16 @@ const (
 	dnsUpdateFrequency = 30 * time.Second
 )
 
+// sector allocation strategies
+const (
+	// SectorAllocationFirstFit stores new sectors in the first available
+	// location, preferring the least written locations.
+	SectorAllocationFirstFit = "firstFit"
+	// SectorAllocationSpread stores new sectors round-robin across the
+	// writable volumes with free space.
+	SectorAllocationSpread = "spread"
+)
+
 type (
 	// A Store persists the host's settings
 	Store interface {
@@ -55,18 +69,37 @@ type (
 		NetAddress          string `json:"netAddress"`
 		MaxContractDuration uint64 `json:"maxContractDuration"`
 		WindowSize          uint64 `json:"windowSize"`
+		// FormationSafetyMargin is the minimum number of blocks between the
+		// current height and the start of a new contract's proof window.
+		// Zero uses WindowSize.
+		FormationSafetyMargin uint64 `json:"formationSafetyMargin"`
 
 		// Pricing
 		ContractPrice     types.Currency `json:"contractPrice"`
 		BaseRPCPrice      types.Currency `json:"baseRPCPrice"`
 		SectorAccessPrice types.Currency `json:"sectorAccessPrice"`
+		// MinHostPayout is the minimum valid host payout the host will accept
+		// when forming a contract. Contracts paying less are rejected
+		// regardless of the host's other prices.
+		MinHostPayout types.Currency `json:"minHostPayout"`
 
 		CollateralMultiplier float64        `json:"collateralMultiplier"`
 		MaxCollateral        types.Currency `json:"maxCollateral"`
+		// MinCollateralScale and MaxCollateralScale bound the factor the
+		// advertised collateral multiplier and max collateral are scaled by.
+		// The host advertises the max scale when its storage is empty and the
+		// min scale when it is full. Zero disables dynamic collateral.
+		MinCollateralScale float64 `json:"minCollateralScale"`
+		MaxCollateralScale float64 `json:"maxCollateralScale"`
 
 		StoragePrice types.Currency `json:"storagePrice"`
 		EgressPrice  types.Currency `json:"egressPrice"`
 		IngressPrice types.Currency `json:"ingressPrice"`
+		// MinIngressCollateralRatio is the minimum ratio between the ingress
+		// price and the collateral the host risks per byte stored for the
+		// remaining duration of the contract being uploaded to. Zero
+		// disables the check.
+		MinIngressCollateralRatio float64 `json:"minIngressCollateralRatio"`
 
 		PriceTableValidity time.Duration `json:"priceTableValidity"`
 
@@ -76,6 +109,30 @@ type (
 		// RHP3 settings
 		AccountExpiry     time.Duration  `json:"accountExpiry"`
 		MaxAccountBalance types.Currency `json:"maxAccountBalance"`
+		// MinAccountDeposit is the minimum amount a renter can deposit into
+		// an ephemeral account. Smaller deposits are rejected. Zero disables
+		// the check.
+		MinAccountDeposit types.Currency `json:"minAccountDeposit"`
+		// MaxSectorsPerRPC is the maximum number of sectors a single RHP3
+		// program can read or write. Zero disables the limit.
+		MaxSectorsPerRPC uint64 `json:"maxSectorsPerRPC"`
+		// MaxReadSectorsPerProgram is the maximum number of sectors a single
+		// RHP3 program can read. Zero disables the limit.
+		MaxReadSectorsPerProgram uint64 `json:"maxReadSectorsPerProgram"`
+		// MaxAppendSectorsPerProgram is the maximum number of sectors a
+		// single RHP3 program can append. Zero disables the limit.
+		MaxAppendSectorsPerProgram uint64 `json:"maxAppendSectorsPerProgram"`
+		// MaxProgramInstructions is the maximum number of instructions in a
+		// single RHP3 program. Zero disables the limit.
+		MaxProgramInstructions uint64 `json:"maxProgramInstructions"`
+
+		// ZeroDeletedSectors overwrites the data of sectors that are no
+		// longer referenced with zeroes before their space is reused.
+		ZeroDeletedSectors bool `json:"zeroDeletedSectors"`
+		// SectorAllocation is the strategy used to choose the location of
+		// new sectors. Either SectorAllocationFirstFit or
+		// SectorAllocationSpread.
+		SectorAllocation string `json:"sectorAllocation"`
 
 		// Bandwidth limiter settings
 		IngressLimit uint64 `json:"ingressLimit"`
@@ -98,6 +155,7 @@ type (
 	// Alerts registers global alerts.
 	Alerts interface {
 		Register(alerts.Alert)
+		Dismiss(...types.Hash256)
 	}
 
 	// A ChainManager manages the current consensus state
@@ -108,6 +166,7 @@ type (
 
 	// A Wallet manages funds and signs transactions
 	Wallet interface {
+		Balance() (spendable, confirmed, unconfirmed types.Currency, err error)
 		FundTransaction(txn *types.Transaction, amount types.Currency) ([]types.Hash256, func(), error)
 		SignTransaction(cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) error
 	}
@@ -128,10 +187,32 @@ type (
 		tp     TransactionPool
 		wallet Wallet
 
+		announcementFee types.Currency // overrides the recommended announcement fee if non-zero
+		// announceInterval is the number of blocks after which the host
+		// re-announces its unchanged address. Zero disables refreshes.
+		announceInterval uint64
+		maxRefreshFee    types.Currency // defers refreshes while the fee is higher, if non-zero
+		// networkAddrs are additional addresses, such as an onion address,
+		// announced alongside the host's net address.
+		networkAddrs []string
+
+		// updateMu serializes settings updates so that the persisted and
+		// in-memory settings are always replaced in the same order.
+		updateMu sync.Mutex
+
 		mu                  sync.Mutex // guards the following fields
 		settings            Settings   // in-memory cache of the host's settings
 		scanHeight          uint64     // track the last block height that was scanned for announcements
 		lastAnnounceAttempt uint64     // debounce announcement transactions
+		databaseFull        bool       // pauses contract formation without changing the persisted settings
+		proofBreakerTripped bool       // pauses contract formation until the proof failure breaker is reset
+		utilization         float64    // fraction of storage in use, used to scale the advertised collateral
+		subscribers         []subscription
+		nextSubscriberID    uint64
+
+		// snapshot holds the advertised settings. It is replaced while mu
+		// is held, but can be loaded without it.
+		snapshot atomic.Pointer[Settings]
 
 		ingressLimit *rate.Limiter
 		egressLimit  *rate.Limiter
@@ -146,6 +227,12 @@ type (
 	}
 )
 
+// constant to overwrite the database full alert instead of registering new ones
+var alertDatabaseFullID = frand.Entropy256()
+
+// constant to overwrite the ingress price alert instead of registering new ones
+var alertIngressPriceID = frand.Entropy256()
+
 var (
 	// DefaultSettings are the default settings for the host
 	DefaultSettings = Settings{
@@ -168,16 +255,73 @@ var (
 
 		AccountExpiry:     30 * 24 * time.Hour, // 30 days
 		MaxAccountBalance: types.Siacoins(10),  // 10SC
+		MaxSectorsPerRPC:  256,                 // 1 GiB
 		WindowSize:        144,                 // 144 blocks
 
 		MaxRegistryEntries: 100000,
+
+		SectorAllocation: SectorAllocationFirstFit,
 	}
 	// ErrNoSettings must be returned by the store if the host has no settings yet
 	ErrNoSettings = errors.New("no settings found")
+	// ErrIngressPriceTooLow is returned when the ingress price does not cover
+	// the minimum multiple of the collateral risked per byte.
+	ErrIngressPriceTooLow = errors.New("ingress price too low")
 
 	specifierAnnouncement = types.NewSpecifier("HostAnnouncement")
 )
 
+// MinIngressPrice returns the minimum ingress price per byte allowed by the
+// host's collateral settings for data stored for duration blocks:
+// MinIngressCollateralRatio times the collateral the host risks for a byte
+// stored for duration blocks. If the minimum overflows, types.MaxCurrency is
+// returned.
+func (s Settings) MinIngressPrice(duration uint64) types.Currency {
+	if s.MinIngressCollateralRatio <= 0 {
+		return types.ZeroCurrency
+	}
+	collateral, err := rhp.CollateralPrice(s.StoragePrice, s.CollateralMultiplier)
+	if err != nil {
+		return types.MaxCurrency
+	}
+	risked, err := rhp.MulCurrency(collateral, duration)
+	if err != nil {
+		return types.MaxCurrency
+	}
+
+	// the ratio is applied exactly since it can be much smaller than 1
+	return applyMultiplier(risked, s.MinIngressCollateralRatio)
+}
+
+// FormationLeadTime returns the minimum number of blocks between the current
+// height and the start of a new contract's proof window.
+func (s Settings) FormationLeadTime() uint64 {
+	if s.FormationSafetyMargin == 0 {
+		return s.WindowSize
+	}
+	return s.FormationSafetyMargin
+}
+
+// CheckIngressPrice returns ErrIngressPriceTooLow if price does not cover the
+// minimum ingress price for data stored for duration blocks. Uploads are
+// checked against the remaining duration of their contract.
+func (s Settings) CheckIngressPrice(price types.Currency, duration uint64) error {
+	if min := s.MinIngressPrice(duration); price.Cmp(min) < 0 {
+		return fmt.Errorf("%w: ingress price %v is less than the minimum %v (%gx the collateral risked per byte over %d blocks)", ErrIngressPriceTooLow, price, min, s.MinIngressCollateralRatio, duration)
+	}
+	return nil
+}
+
+// validateIngressPrice checks that the ingress price covers the minimum
+// multiple of the collateral risked per byte for the longest contract the
+// host accepts.
+func validateIngressPrice(s Settings) error {
+	if s.MinIngressCollateralRatio < 0 {
+		return errors.New("min ingress collateral ratio must not be negative")
+	}
+	return s.CheckIngressPrice(s.IngressPrice, s.MaxContractDuration)
+}
+
 // setRateLimit sets the bandwidth rate limit for the host
 func (m *ConfigManager) setRateLimit(ingress, egress uint64) {
 	var ingressLimit rate.Limit
@@ -218,6 +362,73 @@ func (m *ConfigManager) LastAnnouncement() (Announcement, error) {
 
 // UpdateSettings updates the host's settings.
 func (m *ConfigManager) UpdateSettings(s Settings) error {
+	if err := validateSettings(&s); err != nil {
+		return err
+	}
+
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+	return m.applySettings(s)
+}
+
+// ModifySettings calls fn with a copy of the host's settings and applies the
+// modified settings. Updates are serialized, so no other update can be lost
+// between reading and applying the settings. If fn returns an error or does
+// not change any fields, the settings are not updated.
+func (m *ConfigManager) ModifySettings(fn func(*Settings) error) error {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+
+	m.mu.Lock()
+	s := m.settings
+	m.mu.Unlock()
+
+	if err := fn(&s); err != nil {
+		return err
+	} else if err := validateSettings(&s); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	unchanged := len(diffSettings(m.settings, s)) == 0
+	m.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	return m.applySettings(s)
+}
+
+// applySettings persists and applies validated settings. updateMu must be
+// held.
+func (m *ConfigManager) applySettings(s Settings) error {
+	// persist the settings before replacing the in-memory copy so that a
+	// failed update is never advertised to renters.
+	if err := m.store.UpdateSettings(s); err != nil {
+		return fmt.Errorf("failed to persist settings: %w", err)
+	}
+
+	m.mu.Lock()
+	old := m.settings
+	changed := diffSettings(old, s)
+	m.settings = s
+	m.updateSnapshot()
+	if changed.Any("ingressLimit", "egressLimit") {
+		m.setRateLimit(s.IngressLimit, s.EgressLimit)
+	}
+	// the DNS records are derived from the net address
+	if changed.Any("ddns", "netAddress") {
+		m.resetDDNS()
+	}
+	m.mu.Unlock()
+
+	m.checkUploadPrice()
+	m.notifySubscribers(old, s, changed)
+	return nil
+}
+
+// validateSettings checks that the settings can be applied. The DNS settings
+// are normalized in place.
+func validateSettings(s *Settings) error {
 	// validate DNS settings
 	if err := validateDNSSettings(&s.DDNS); err != nil {
 		return fmt.Errorf("failed to validate DNS settings: %w", err)
@@ -230,21 +441,97 @@ func (m *ConfigManager) UpdateSettings(s Settings) error {
 		}
 	}
 
-	m.mu.Lock()
-	m.settings = s
-	m.setRateLimit(s.IngressLimit, s.EgressLimit)
-	m.resetDDNS()
-	m.mu.Unlock()
-	return m.store.UpdateSettings(s)
+	collateral, err := rhp.CollateralPrice(s.StoragePrice, s.CollateralMultiplier)
+	if err != nil {
+		return fmt.Errorf("invalid collateral settings: %w", err)
+	} else if err := validateDynamicCollateral(*s); err != nil {
+		return fmt.Errorf("invalid dynamic collateral settings: %w", err)
+	} else if err := validateIngressPrice(*s); err != nil {
+		return err
+	}
+
+	// storing a sector for the longest contract must not overflow
+	if _, err := rhp.StorageCost(s.StoragePrice, rhp2.SectorSize, s.MaxContractDuration); err != nil {
+		return fmt.Errorf("invalid storage price: %w", err)
+	} else if _, err := rhp.StorageCost(collateral, rhp2.SectorSize, s.MaxContractDuration); err != nil {
+		return fmt.Errorf("invalid collateral settings: %w", err)
+	}
+
+	if s.MinAccountDeposit.Cmp(s.MaxAccountBalance) > 0 {
+		return fmt.Errorf("min account deposit %v exceeds the max account balance %v", s.MinAccountDeposit, s.MaxAccountBalance)
+	}
+
+	if s.FormationSafetyMargin > s.MaxContractDuration {
+		return fmt.Errorf("formation safety margin %d exceeds the max contract duration %d", s.FormationSafetyMargin, s.MaxContractDuration)
+	}
+
+	switch s.SectorAllocation {
+	case "", SectorAllocationFirstFit, SectorAllocationSpread:
+	default:
+		return fmt.Errorf("unknown sector allocation strategy %q", s.SectorAllocation)
+	}
+	return nil
 }
 
-// Settings returns the host's current settings.
+// Settings returns a snapshot of the host's current settings. Everything
+// advertised to renters should be derived from a single snapshot so that
+// concurrent updates cannot produce mismatched prices.
 func (m *ConfigManager) Settings() Settings {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	return m.settings
 }
 
+// ContractsPaused returns true if contract formation and renewal are paused
+// regardless of the AcceptingContracts setting.
+func (m *ConfigManager) ContractsPaused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.databaseFull || m.proofBreakerTripped
+}
+
+// SetProofBreakerTripped pauses contract formation while the proof failure
+// circuit breaker is tripped. The breaker is responsible for alerting the
+// operator.
+func (m *ConfigManager) SetProofBreakerTripped(tripped bool) {
+	m.mu.Lock()
+	m.proofBreakerTripped = tripped
+	m.mu.Unlock()
+
+	if tripped {
+		m.log.Error("proof failure circuit breaker tripped, pausing contract formation")
+	} else {
+		m.log.Info("proof failure circuit breaker reset, resuming contract formation")
+	}
+}
+
+// SetDatabaseFull pauses contract formation and registers a critical alert
+// while the disk holding the host's database is full. Contracts formed while
+// the database cannot be written would not be persisted and storage proofs
+// may fail to be submitted. The pause is lifted and the alert dismissed when
+// space is freed.
+func (m *ConfigManager) SetDatabaseFull(full bool) {
+	m.mu.Lock()
+	m.databaseFull = full
+	m.mu.Unlock()
+
+	if !full {
+		m.log.Info("database disk space recovered, resuming contract formation")
+		m.a.Dismiss(alertDatabaseFullID)
+		return
+	}
+	m.log.Error("database disk is full, pausing contract formation")
+	m.a.Register(alerts.Alert{
+		ID:       alertDatabaseFullID,
+		Severity: alerts.SeverityCritical,
+		Message:  "Database disk is full",
+		Data: map[string]any{
+			"error": "the disk holding the host's database is full. Contract formation is paused until space is freed.",
+		},
+		Timestamp: time.Now(),
+	})
+}
+
 // BandwidthLimiters returns the rate limiters for all traffic
 func (m *ConfigManager) BandwidthLimiters() (ingress, egress *rate.Limiter) {
 	return m.ingressLimit, m.egressLimit
@@ -289,12 +576,19 @@ func NewConfigManager(opts ...Option) (*ConfigManager, error) {
 
 		// rhp3 WebSocket TLS
 		rhp3WSTLS: &tls.Config{},
+
+		announceInterval: autoAnnounceInterval,
 	}
 
 	for _, opt := range opts {
 		opt(m)
 	}
 
+	// refreshes are rate limited by the announcement debounce
+	if m.announceInterval > 0 && m.announceInterval < announcementDebounce {
+		m.announceInterval = announcementDebounce
+	}
+
 	if len(m.hostKey) != ed25519.PrivateKeySize {
 		panic("host key invalid")
 	}
@@ -313,6 +607,11 @@ func NewConfigManager(opts ...Option) (*ConfigManager, error) {
 		return nil, fmt.Errorf("failed to load settings: %w", err)
 	}
 
+	// the snapshot must be set before the consensus subscriber can read it
+	m.settings = settings
+	m.updateSnapshot()
+	m.checkUploadPrice()
+
 	lastChange, height, err := m.store.LastSettingsConsensusChange()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load last settings consensus change: %w", err)
@@ -335,7 +634,6 @@ func NewConfigManager(opts ...Option) (*ConfigManager, error) {
 		}
 	}()
 
-	m.settings = settings
 	// update the global rate limiters from settings
 	m.setRateLimit(settings.IngressLimit, settings.EgressLimit)
 	// initialize the DDNS update timer
@@ -1,8 +1,10 @@
 package settings_test
 
 import (
+	"errors"
 	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
 
 	"go.sia.tech/core/types"
@@ -10,6 +12,7 @@ import (
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/internal/test"
 	"go.sia.tech/hostd/persist/sqlite"
+	"go.sia.tech/hostd/rhp"
 	"go.sia.tech/hostd/webhooks"
 	"go.uber.org/zap/zaptest"
 	"lukechampine.com/frand"
@@ -63,4 +66,479 @@ func TestSettings(t *testing.T) {
 	} else if !reflect.DeepEqual(manager.Settings(), updated) {
 		t.Fatal("settings not equal to updated")
 	}
+
+	// concurrent modifications should not overwrite each other
+	var wg sync.WaitGroup
+	errCh := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errCh <- manager.ModifySettings(func(s *settings.Settings) error {
+				s.WindowSize++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if manager.Settings().WindowSize != 120 {
+		t.Fatalf("expected window size 120, got %v", manager.Settings().WindowSize)
+	}
+
+	// a failed modification should not change the settings
+	errModify := errors.New("modify failed")
+	err = manager.ModifySettings(func(s *settings.Settings) error {
+		s.WindowSize = 1
+		return errModify
+	})
+	if !errors.Is(err, errModify) {
+		t.Fatalf("expected %v, got %v", errModify, err)
+	} else if manager.Settings().WindowSize != 120 {
+		t.Fatalf("expected window size 120, got %v", manager.Settings().WindowSize)
+	}
+}
+
+func TestIngressPriceMinimum(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	manager, err := settings.NewConfigManager(settings.WithHostKey(hostKey),
+		settings.WithStore(db),
+		settings.WithChainManager(node.ChainManager()),
+		settings.WithTransactionPool(node.TPool()),
+		settings.WithWallet(node),
+		settings.WithAlertManager(am),
+		settings.WithLog(log.Named("settings")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	s := manager.Settings()
+	s.StoragePrice = types.NewCurrency64(1000)
+	s.CollateralMultiplier = 2
+	s.MaxContractDuration = 10
+	s.FormationSafetyMargin = 0
+	s.MinIngressCollateralRatio = 1.5
+
+	// collateral is 2000 H per byte per block, 20000 H per byte over the max
+	// contract duration, so the minimum is 30000 H. Shorter contracts have a
+	// lower minimum.
+	if min := s.MinIngressPrice(s.MaxContractDuration); !min.Equals(types.NewCurrency64(30000)) {
+		t.Fatalf("expected min ingress price 30000 H, got %v", min)
+	} else if min := s.MinIngressPrice(5); !min.Equals(types.NewCurrency64(15000)) {
+		t.Fatalf("expected min ingress price 15000 H, got %v", min)
+	} else if err := s.CheckIngressPrice(types.NewCurrency64(15000), 5); err != nil {
+		t.Fatal(err)
+	} else if err := s.CheckIngressPrice(types.NewCurrency64(14999), 5); !errors.Is(err, settings.ErrIngressPriceTooLow) {
+		t.Fatalf("expected ErrIngressPriceTooLow, got %v", err)
+	}
+
+	s.IngressPrice = types.NewCurrency64(29999)
+	if err := manager.UpdateSettings(s); !errors.Is(err, settings.ErrIngressPriceTooLow) {
+		t.Fatalf("expected ErrIngressPriceTooLow, got %v", err)
+	} else if manager.Settings().MinIngressCollateralRatio != 0 {
+		t.Fatal("invalid settings should not be applied")
+	}
+
+	s.IngressPrice = types.NewCurrency64(30000)
+	if err := manager.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(manager.Settings(), s) {
+		t.Fatal("settings not equal to updated")
+	}
+
+	hasAlert := func() bool {
+		for _, alert := range am.Active() {
+			if alert.Message == "Advertised ingress price is below the collateral minimum" {
+				return true
+			}
+		}
+		return false
+	}
+	if hasAlert() {
+		t.Fatal("expected no ingress price alert")
+	}
+
+	// doubling the advertised collateral of an empty host doubles the
+	// minimum. The advertised price only covers contracts with half the max
+	// duration remaining and the operator is warned.
+	s.MinCollateralScale = 1
+	s.MaxCollateralScale = 2
+	if err := manager.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	} else if !hasAlert() {
+		t.Fatal("expected ingress price alert")
+	}
+	advertised := manager.AdvertisedSettings()
+	if !advertised.IngressPrice.Equals(s.IngressPrice) {
+		t.Fatalf("expected advertised ingress price %v, got %v", s.IngressPrice, advertised.IngressPrice)
+	} else if err := advertised.CheckIngressPrice(advertised.IngressPrice, 5); err != nil {
+		t.Fatal(err)
+	} else if err := advertised.CheckIngressPrice(advertised.IngressPrice, 6); !errors.Is(err, settings.ErrIngressPriceTooLow) {
+		t.Fatalf("expected ErrIngressPriceTooLow, got %v", err)
+	}
+
+	// the alert is dismissed once the minimum is covered again
+	manager.SetStorageUtilization(1, 1)
+	if err := manager.AdvertisedSettings().CheckIngressPrice(s.IngressPrice, s.MaxContractDuration); err != nil {
+		t.Fatal(err)
+	} else if hasAlert() {
+		t.Fatal("expected ingress price alert to be dismissed")
+	}
+	s.MinCollateralScale = 0
+	s.MaxCollateralScale = 0
+
+	// a ratio of zero disables the check
+	s.MinIngressCollateralRatio = 0
+	s.IngressPrice = types.ZeroCurrency
+	if err := manager.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	s.MinIngressCollateralRatio = -1
+	if err := manager.UpdateSettings(s); err == nil {
+		t.Fatal("expected negative ratio to be rejected")
+	}
+
+	// an overflowing minimum can never be satisfied. The overflowing
+	// collateral is rejected before the ingress price is checked.
+	s.StoragePrice = types.MaxCurrency
+	s.MinIngressCollateralRatio = 1
+	s.IngressPrice = types.MaxCurrency.Sub(types.NewCurrency64(1))
+	if min := s.MinIngressPrice(s.MaxContractDuration); !min.Equals(types.MaxCurrency) {
+		t.Fatalf("expected max currency, got %v", min)
+	} else if err := manager.UpdateSettings(s); !errors.Is(err, rhp.ErrPriceOverflow) {
+		t.Fatalf("expected ErrPriceOverflow, got %v", err)
+	}
+
+	// a minimum that overflows only after applying the duration is still
+	// reported as too low
+	s.StoragePrice = types.MaxCurrency.Div64(1000)
+	s.CollateralMultiplier = 1
+	s.MaxContractDuration = 2000
+	s.MinIngressCollateralRatio = 2
+	if err := manager.UpdateSettings(s); !errors.Is(err, settings.ErrIngressPriceTooLow) {
+		t.Fatalf("expected ErrIngressPriceTooLow, got %v", err)
+	}
+
+	// a storage price that only overflows when a sector is stored for the
+	// max contract duration is rejected
+	s.MinIngressCollateralRatio = 0
+	s.IngressPrice = types.ZeroCurrency
+	if err := manager.UpdateSettings(s); !errors.Is(err, rhp.ErrPriceOverflow) {
+		t.Fatalf("expected ErrPriceOverflow, got %v", err)
+	}
+}
+
+func TestSettingsSubscribe(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	manager, err := settings.NewConfigManager(settings.WithHostKey(hostKey),
+		settings.WithStore(db),
+		settings.WithChainManager(node.ChainManager()),
+		settings.WithTransactionPool(node.TPool()),
+		settings.WithWallet(node),
+		settings.WithAlertManager(am),
+		settings.WithLog(log.Named("settings")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	var all, pricing, announcement []settings.ChangedFields
+	manager.Subscribe(func(_, _ settings.Settings, changed settings.ChangedFields) {
+		all = append(all, changed)
+	})
+	manager.Subscribe(func(_, _ settings.Settings, changed settings.ChangedFields) {
+		pricing = append(pricing, changed)
+	}, "storagePrice", "egressPrice", "ingressPrice")
+	unsubscribe := manager.Subscribe(func(old, new settings.Settings, changed settings.ChangedFields) {
+		if old.NetAddress == new.NetAddress {
+			t.Error("expected net address to change")
+		}
+		announcement = append(announcement, changed)
+	}, "netAddress")
+
+	update := func(fn func(*settings.Settings)) {
+		t.Helper()
+		s := manager.Settings()
+		fn(&s)
+		if err := manager.UpdateSettings(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// change a pricing field and an unrelated field
+	update(func(s *settings.Settings) {
+		s.StoragePrice = s.StoragePrice.Mul64(2)
+		s.SectorCacheSize = 128
+	})
+	// change the net address
+	update(func(s *settings.Settings) {
+		s.NetAddress = "localhost:10082"
+	})
+	// an update without changes should not notify subscribers
+	update(func(*settings.Settings) {})
+
+	expected := []settings.ChangedFields{
+		{"storagePrice": true, "sectorCacheSize": true},
+		{"netAddress": true},
+	}
+	if !reflect.DeepEqual(all, expected) {
+		t.Fatalf("expected %v, got %v", expected, all)
+	} else if !reflect.DeepEqual(pricing, expected[:1]) {
+		t.Fatalf("expected %v, got %v", expected[:1], pricing)
+	} else if !reflect.DeepEqual(announcement, expected[1:]) {
+		t.Fatalf("expected %v, got %v", expected[1:], announcement)
+	}
+
+	// unsubscribed subscribers should not be notified
+	unsubscribe()
+	update(func(s *settings.Settings) {
+		s.NetAddress = "localhost:10083"
+	})
+	if len(announcement) != 1 {
+		t.Fatalf("expected 1 announcement notification, got %v", len(announcement))
+	} else if len(all) != 3 || !reflect.DeepEqual(all[2], settings.ChangedFields{"netAddress": true}) {
+		t.Fatalf("expected net address change, got %v", all)
+	}
+}
+
+func TestDynamicCollateral(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	manager, err := settings.NewConfigManager(settings.WithHostKey(hostKey),
+		settings.WithStore(db),
+		settings.WithChainManager(node.ChainManager()),
+		settings.WithTransactionPool(node.TPool()),
+		settings.WithWallet(node),
+		settings.WithAlertManager(am),
+		settings.WithLog(log.Named("settings")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	// invalid bounds should be rejected
+	s := settings.DefaultSettings
+	s.MinCollateralScale, s.MaxCollateralScale = 2, 1
+	if err := manager.UpdateSettings(s); err == nil {
+		t.Fatal("expected error when the min scale exceeds the max scale")
+	}
+	s.MinCollateralScale, s.MaxCollateralScale = 0, 1
+	if err := manager.UpdateSettings(s); err == nil {
+		t.Fatal("expected error when the min scale is zero")
+	}
+
+	s.MinCollateralScale, s.MaxCollateralScale = 0.5, 2
+	if err := manager.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	baseCollateral, err := rhp.CollateralPrice(s.StoragePrice, s.CollateralMultiplier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minCollateral, maxCollateral := baseCollateral.Div64(2), baseCollateral.Mul64(2)
+
+	var last types.Currency
+	for i, used := range []uint64{0, 25, 50, 75, 100} {
+		manager.SetStorageUtilization(used, 100)
+		advertised := manager.AdvertisedSettings()
+		collateral, err := rhp.CollateralPrice(advertised.StoragePrice, advertised.CollateralMultiplier)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch {
+		case collateral.Cmp(minCollateral) < 0 || collateral.Cmp(maxCollateral) > 0:
+			t.Fatalf("collateral %v at %d%% utilization is outside of [%v, %v]", collateral, used, minCollateral, maxCollateral)
+		case advertised.MaxCollateral.Cmp(s.MaxCollateral.Div64(2)) < 0 || advertised.MaxCollateral.Cmp(s.MaxCollateral.Mul64(2)) > 0:
+			t.Fatalf("max collateral %v at %d%% utilization is outside of bounds", advertised.MaxCollateral, used)
+		case i > 0 && collateral.Cmp(last) >= 0:
+			t.Fatalf("expected collateral to decrease as utilization increases, got %v after %v", collateral, last)
+		}
+		last = collateral
+	}
+
+	// the bounds should be reached when the host is empty or full
+	manager.SetStorageUtilization(0, 100)
+	if advertised := manager.AdvertisedSettings(); advertised.CollateralMultiplier != s.CollateralMultiplier*2 {
+		t.Fatalf("expected multiplier %v, got %v", s.CollateralMultiplier*2, advertised.CollateralMultiplier)
+	} else if !advertised.MaxCollateral.Equals(s.MaxCollateral.Mul64(2)) {
+		t.Fatalf("expected max collateral %v, got %v", s.MaxCollateral.Mul64(2), advertised.MaxCollateral)
+	}
+	manager.SetStorageUtilization(100, 100)
+	if advertised := manager.AdvertisedSettings(); advertised.CollateralMultiplier != s.CollateralMultiplier/2 {
+		t.Fatalf("expected multiplier %v, got %v", s.CollateralMultiplier/2, advertised.CollateralMultiplier)
+	} else if !advertised.MaxCollateral.Equals(s.MaxCollateral.Div64(2)) {
+		t.Fatalf("expected max collateral %v, got %v", s.MaxCollateral.Div64(2), advertised.MaxCollateral)
+	}
+
+	// the configured settings should not change
+	if !reflect.DeepEqual(manager.Settings(), s) {
+		t.Fatal("expected configured settings to be unchanged")
+	}
+}
+
+func TestPreviewSettings(t *testing.T) {
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	dir := t.TempDir()
+	log := zaptest.NewLogger(t)
+	node, err := test.NewWallet(hostKey, dir, log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	manager, err := settings.NewConfigManager(settings.WithHostKey(hostKey),
+		settings.WithStore(db),
+		settings.WithChainManager(node.ChainManager()),
+		settings.WithTransactionPool(node.TPool()),
+		settings.WithWallet(node),
+		settings.WithAlertManager(am),
+		settings.WithLog(log.Named("settings")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+	manager.SetStorageUtilization(25, 100)
+
+	// invalid settings should be rejected without being applied
+	invalid := settings.DefaultSettings
+	invalid.SectorAllocation = "invalid"
+	if _, err := manager.PreviewSettings(invalid); err == nil {
+		t.Fatal("expected invalid settings to be rejected")
+	}
+
+	proposed := settings.DefaultSettings
+	proposed.StoragePrice = proposed.StoragePrice.Mul64(2)
+	proposed.MinCollateralScale, proposed.MaxCollateralScale = 0.5, 2
+
+	preview, err := manager.PreviewSettings(proposed)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(manager.Settings(), settings.DefaultSettings) {
+		t.Fatal("preview should not change the host's settings")
+	} else if !reflect.DeepEqual(preview.Changed, []string{"maxCollateralScale", "minCollateralScale", "storagePrice"}) {
+		t.Fatalf("unexpected changed fields %v", preview.Changed)
+	} else if preview.ContractsPaused {
+		t.Fatal("expected contracts not to be paused")
+	} else if preview.Advertised.CollateralMultiplier == proposed.CollateralMultiplier {
+		t.Fatal("expected the advertised collateral to be scaled")
+	}
+
+	// the preview should match the effect of applying the settings
+	if err := manager.UpdateSettings(proposed); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(manager.Settings(), preview.Settings) {
+		t.Fatalf("expected settings %+v, got %+v", preview.Settings, manager.Settings())
+	} else if !reflect.DeepEqual(manager.AdvertisedSettings(), preview.Advertised) {
+		t.Fatalf("expected advertised settings %+v, got %+v", preview.Advertised, manager.AdvertisedSettings())
+	}
+
+	// a paused host is reported by the preview
+	manager.SetProofBreakerTripped(true)
+	if preview, err := manager.PreviewSettings(proposed); err != nil {
+		t.Fatal(err)
+	} else if !preview.ContractsPaused {
+		t.Fatal("expected contracts to be paused")
+	} else if len(preview.Changed) != 0 {
+		t.Fatalf("expected no changed fields, got %v", preview.Changed)
+	}
+}
+
+func TestNetworkPolicyMultiplier(t *testing.T) {
+	s := settings.DefaultSettings
+	s.StoragePrice = types.NewCurrency64(10000)
+	s.EgressPrice = types.MaxCurrency
+
+	// small multipliers should not be truncated to zero
+	applied := settings.NetworkPolicy{PriceMultiplier: 0.0001}.Apply(s)
+	if !applied.StoragePrice.Equals(types.NewCurrency64(1)) {
+		t.Fatalf("expected storage price 1, got %d", applied.StoragePrice)
+	}
+
+	// large results should saturate
+	applied = settings.NetworkPolicy{PriceMultiplier: 2}.Apply(s)
+	if !applied.StoragePrice.Equals(types.NewCurrency64(20000)) {
+		t.Fatalf("expected storage price 20000, got %d", applied.StoragePrice)
+	} else if !applied.EgressPrice.Equals(types.MaxCurrency) {
+		t.Fatalf("expected max egress price, got %d", applied.EgressPrice)
+	}
 }
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+	"lukechampine.com/frand"
+)
+
+type (
+	// An AutoGrowConfig configures the automatic growth of volumes that are
+	// running out of space. Volumes are only grown if their backend provider
+	// implements SpaceReporter and has enough free space for the increment.
+	AutoGrowConfig struct {
+		// Interval is the time between checks. A zero interval disables
+		// automatic growth.
+		Interval time.Duration
+		// Threshold is the number of free sectors below which a volume is
+		// grown.
+		Threshold uint64
+		// Increment is the number of sectors added to a volume each time it
+		// is grown.
+		Increment uint64
+		// MaxSectors is the size, in sectors, volumes will not be grown
+		// beyond. Zero does not limit the size.
+		MaxSectors uint64
+		// ReservedSpace is the number of bytes that must remain free on the
+		// underlying storage after a volume is grown.
+		ReservedSpace uint64
+	}
+
+	// A VolumeGrowth is a volume that was grown automatically.
+	VolumeGrowth struct {
+		VolumeID   int64  `json:"volumeID"`
+		OldSectors uint64 `json:"oldSectors"`
+		NewSectors uint64 `json:"newSectors"`
+	}
+)
+
+// errInsufficientSpace is returned when the underlying storage does not have
+// enough free space to grow a volume.
+var errInsufficientSpace = errors.New("not enough free space to grow volume")
+
+// autoGrowAlertID returns the ID of the alert registered when a volume cannot
+// be grown.
+func autoGrowAlertID(id int64) types.Hash256 {
+	return types.HashBytes([]byte(fmt.Sprintf("autoGrow%d", id)))
+}
+
+// autoGrowTarget returns the number of sectors a volume should be grown to.
+// false is returned if the volume does not need to or cannot be grown.
+func (cfg AutoGrowConfig) autoGrowTarget(vol Volume) (uint64, bool) {
+	if cfg.Increment == 0 || vol.ReadOnly || !vol.Available {
+		return 0, false
+	} else if vol.TotalSectors-vol.UsedSectors >= cfg.Threshold {
+		return 0, false
+	}
+	target := vol.TotalSectors + cfg.Increment
+	if cfg.MaxSectors > 0 && target > cfg.MaxSectors {
+		target = cfg.MaxSectors
+	}
+	return target, target > vol.TotalSectors
+}
+
+// autoGrowVolume grows a volume if it still needs to grow and the underlying
+// storage has enough free space. The volume is re-read after its status is
+// set, so a concurrent resize is not grown from a stale size. false is
+// returned if the volume no longer needs to grow.
+func (vm *VolumeManager) autoGrowVolume(ctx context.Context, id int64) (VolumeGrowth, bool, error) {
+	reporter, ok := vm.backends.(SpaceReporter)
+	if !ok {
+		return VolumeGrowth{}, false, errors.New("backend provider cannot report free space")
+	}
+
+	vm.mu.Lock()
+	v, ok := vm.volumes[id]
+	if !ok {
+		vm.mu.Unlock()
+		return VolumeGrowth{}, false, &VolumeError{VolumeID: id, Op: VolumeOpResize, Err: ErrVolumeNotFound}
+	} else if err := v.SetStatus(VolumeStatusResizing); err != nil {
+		vm.mu.Unlock()
+		return VolumeGrowth{}, false, &VolumeError{VolumeID: id, Op: VolumeOpResize, Err: err}
+	}
+	vm.mu.Unlock()
+	defer v.SetStatus(VolumeStatusReady)
+
+	vol, err := vm.vs.Volume(id)
+	if err != nil {
+		return VolumeGrowth{}, false, fmt.Errorf("failed to get volume: %w", err)
+	}
+	target, ok := vm.autoGrow.autoGrowTarget(vol)
+	if !ok {
+		return VolumeGrowth{}, false, nil
+	}
+
+	free, err := reporter.FreeSpace(vol.LocalPath)
+	if err != nil {
+		return VolumeGrowth{}, false, fmt.Errorf("failed to get free space: %w", err)
+	}
+	required := (target-vol.TotalSectors)*rhp2.SectorSize + vm.autoGrow.ReservedSpace
+	if free < required {
+		return VolumeGrowth{}, false, fmt.Errorf("%w: %v bytes required, %v available", errInsufficientSpace, required, free)
+	}
+
+	if err := vm.growVolume(ctx, id, v, vol.TotalSectors, target); err != nil {
+		return VolumeGrowth{}, false, err
+	}
+	return VolumeGrowth{VolumeID: id, OldSectors: vol.TotalSectors, NewSectors: target}, true, nil
+}
+
+// AutoGrow grows every volume with fewer free sectors than the configured
+// threshold by the configured increment. Volumes that cannot be grown
+// because the underlying storage is full are skipped and an alert is
+// registered. An alert is registered for each volume that is grown.
+func (vm *VolumeManager) AutoGrow(ctx context.Context) ([]VolumeGrowth, error) {
+	ctx, done, err := vm.tg.AddContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	volumes, err := vm.vs.Volumes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volumes: %w", err)
+	}
+
+	log := vm.log.Named("autoGrow")
+	grown := []VolumeGrowth{}
+	for _, vol := range volumes {
+		if _, ok := vm.autoGrow.autoGrowTarget(vol); !ok {
+			continue
+		}
+
+		log := log.With(zap.Int64("volumeID", vol.ID))
+		growth, ok, err := vm.autoGrowVolume(ctx, vol.ID)
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		} else if err != nil {
+			log.Warn("failed to grow volume", zap.Error(err))
+			vm.a.Register(alerts.Alert{
+				ID:       autoGrowAlertID(vol.ID),
+				Severity: alerts.SeverityWarning,
+				Message:  "Volume is running out of space and could not be grown",
+				Data: map[string]any{
+					"volumeID":     vol.ID,
+					"usedSectors":  vol.UsedSectors,
+					"totalSectors": vol.TotalSectors,
+					"error":        err.Error(),
+				},
+				Timestamp: time.Now(),
+			})
+			continue
+		} else if !ok {
+			// the volume was resized since it was checked and no longer
+			// needs to grow
+			continue
+		}
+
+		log.Info("grew volume", zap.Uint64("oldSectors", growth.OldSectors), zap.Uint64("newSectors", growth.NewSectors))
+		vm.a.Dismiss(autoGrowAlertID(vol.ID))
+		vm.a.Register(alerts.Alert{
+			ID:       frand.Entropy256(),
+			Severity: alerts.SeverityInfo,
+			Message:  "Volume grown automatically",
+			Data: map[string]any{
+				"volumeID":   vol.ID,
+				"oldSectors": growth.OldSectors,
+				"newSectors": growth.NewSectors,
+			},
+			Timestamp: time.Now(),
+		})
+		grown = append(grown, growth)
+	}
+	return grown, nil
+}
+
+// runAutoGrow grows volumes that are running out of space at the configured
+// interval until the volume manager is closed.
+func (vm *VolumeManager) runAutoGrow() {
+	ctx, cancel, err := vm.tg.AddContext(context.Background())
+	if err != nil {
+		return
+	}
+	defer cancel()
+
+	t := time.NewTicker(vm.autoGrow.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := vm.AutoGrow(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			vm.log.Error("automatic volume growth failed", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/hostd/internal/disk"
+)
+
+type (
+	// A VolumeBackend stores a volume's sector data. Sectors are addressed by
+	// their index within the volume. Implementations must be safe to call
+	// concurrently for different indices.
+	VolumeBackend interface {
+		// ReadSector reads the sector at index.
+		ReadSector(index uint64) (*[rhp2.SectorSize]byte, error)
+		// WriteSector writes a sector at index.
+		WriteSector(sector *[rhp2.SectorSize]byte, index uint64) error
+		// Resize grows or shrinks the backend to hold the given number of
+		// sectors.
+		Resize(sectors uint64) error
+		// Sync flushes any buffered writes to durable storage.
+		Sync() error
+		// Close closes the backend.
+		Close() error
+	}
+
+	// A BackendProvider creates, opens, and removes the backends that store
+	// volume data. The location of a volume is opaque to the volume manager;
+	// the default provider treats it as a local file path.
+	BackendProvider interface {
+		// Create creates a new, empty backend at location. An error should
+		// be returned if the location already exists.
+		Create(location string) (VolumeBackend, error)
+		// Open opens an existing backend at location.
+		Open(location string) (VolumeBackend, error)
+		// Remove deletes the backend at location. Removing a location that
+		// does not exist is not an error.
+		Remove(location string) error
+	}
+
+	// A SpaceReporter is a BackendProvider that can report the space
+	// available to grow the backend at a location. Volumes are only grown
+	// automatically if their provider implements it.
+	SpaceReporter interface {
+		BackendProvider
+		// FreeSpace returns the number of bytes available to grow the
+		// backend at location.
+		FreeSpace(location string) (uint64, error)
+	}
+
+	// A SizedBackend is a VolumeBackend that can report the number of
+	// sectors it holds. The size of backends that implement it is checked
+	// when volumes are verified.
+	SizedBackend interface {
+		VolumeBackend
+		// Sectors returns the number of sectors the backend holds.
+		Sectors() (uint64, error)
+	}
+
+	// fileBackend stores sector data in a flat file.
+	fileBackend struct {
+		path string
+		data volumeData
+	}
+
+	// fileProvider is the default BackendProvider. It stores each volume in a
+	// local file with open handles limited by a handleCache.
+	fileProvider struct {
+		files *handleCache
+	}
+)
+
+// ReadSector implements VolumeBackend
+func (fb *fileBackend) ReadSector(index uint64) (*[rhp2.SectorSize]byte, error) {
+	var sector [rhp2.SectorSize]byte
+	_, err := fb.data.ReadAt(sector[:], int64(index*rhp2.SectorSize))
+	return &sector, err
+}
+
+// ReadSectors implements MultiSectorBackend
+func (fb *fileBackend) ReadSectors(buf []byte, index uint64) (int, error) {
+	n, err := fb.data.ReadAt(buf, int64(index*rhp2.SectorSize))
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return n / rhp2.SectorSize, err
+}
+
+// WriteSector implements VolumeBackend
+func (fb *fileBackend) WriteSector(sector *[rhp2.SectorSize]byte, index uint64) error {
+	_, err := fb.data.WriteAt(sector[:], int64(index*rhp2.SectorSize))
+	return err
+}
+
+// Resize implements VolumeBackend
+func (fb *fileBackend) Resize(sectors uint64) error {
+	return fb.data.Truncate(int64(sectors * rhp2.SectorSize))
+}
+
+// Sync implements VolumeBackend
+func (fb *fileBackend) Sync() error {
+	return fb.data.Sync()
+}
+
+// Sectors implements SizedBackend
+func (fb *fileBackend) Sectors() (uint64, error) {
+	info, err := os.Stat(fb.path)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(info.Size()) / rhp2.SectorSize, nil
+}
+
+// Close implements VolumeBackend
+func (fb *fileBackend) Close() error {
+	return fb.data.Close()
+}
+
+// Create implements BackendProvider
+func (fp *fileProvider) Create(localPath string) (VolumeBackend, error) {
+	// check that the volume file does not already exist
+	if _, err := os.Stat(localPath); err == nil {
+		return nil, fmt.Errorf("volume file already exists: %s", localPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat volume file: %w", err)
+	}
+
+	f, err := os.Create(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume file: %w", err)
+	}
+	return &fileBackend{path: localPath, data: fp.files.Adopt(localPath, f)}, nil
+}
+
+// Open implements BackendProvider
+func (fp *fileProvider) Open(localPath string) (VolumeBackend, error) {
+	data, err := fp.files.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	return &fileBackend{path: localPath, data: data}, nil
+}
+
+// Remove implements BackendProvider
+func (fp *fileProvider) Remove(localPath string) error {
+	if err := os.Remove(localPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// FreeSpace implements SpaceReporter
+func (fp *fileProvider) FreeSpace(localPath string) (uint64, error) {
+	free, _, err := disk.Usage(filepath.Dir(localPath))
+	return free, err
+}
@@ -0,0 +1,1127 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/chain"
+	"go.sia.tech/hostd/persist/sqlite"
+	"go.sia.tech/hostd/webhooks"
+	"go.sia.tech/siad/modules/consensus"
+	"go.sia.tech/siad/modules/gateway"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+type (
+	memBackend struct {
+		mu      sync.Mutex
+		sectors [][rhp2.SectorSize]byte
+
+		readErr      error // readErr is returned by ReadSector if set
+		writeErr     error // writeErr is returned by WriteSector if set
+		failedWrites int
+		writeDelay   time.Duration // writeDelay is added to every write
+		// writes tracks concurrent writes across backends if set
+		writes *writeTracker
+	}
+
+	memProvider struct {
+		mu       sync.Mutex
+		backends map[string]*memBackend
+		// writes is shared by every backend created by the provider if set
+		writes *writeTracker
+		// free is the number of bytes reported by FreeSpace
+		free uint64
+	}
+
+	// writeTracker records the peak number of concurrent writes
+	writeTracker struct {
+		mu     sync.Mutex
+		active int
+		peak   int
+	}
+)
+
+func (wt *writeTracker) start() {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	wt.active++
+	if wt.active > wt.peak {
+		wt.peak = wt.active
+	}
+}
+
+func (wt *writeTracker) done() {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	wt.active--
+}
+
+func (wt *writeTracker) maxConcurrent() int {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	return wt.peak
+}
+
+func (mb *memBackend) ReadSector(index uint64) (*[rhp2.SectorSize]byte, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if mb.readErr != nil {
+		return nil, mb.readErr
+	} else if index >= uint64(len(mb.sectors)) {
+		return nil, fmt.Errorf("index %v out of range", index)
+	}
+	sector := mb.sectors[index]
+	return &sector, nil
+}
+
+func (mb *memBackend) WriteSector(sector *[rhp2.SectorSize]byte, index uint64) error {
+	if mb.writes != nil {
+		mb.writes.start()
+		defer mb.writes.done()
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	time.Sleep(mb.writeDelay)
+	if mb.writeErr != nil {
+		mb.failedWrites++
+		return mb.writeErr
+	} else if index >= uint64(len(mb.sectors)) {
+		return fmt.Errorf("index %v out of range", index)
+	}
+	mb.sectors[index] = *sector
+	return nil
+}
+
+func (mb *memBackend) Resize(sectors uint64) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	resized := make([][rhp2.SectorSize]byte, sectors)
+	copy(resized, mb.sectors)
+	mb.sectors = resized
+	return nil
+}
+
+func (mb *memBackend) len() int {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return len(mb.sectors)
+}
+
+func (mb *memBackend) failWrites(err error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.writeErr = err
+}
+
+func (mb *memBackend) delayWrites(d time.Duration) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.writeDelay = d
+}
+
+func (mb *memBackend) failReads(err error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.readErr = err
+}
+
+func (mb *memBackend) failed() int {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return mb.failedWrites
+}
+
+func (mb *memBackend) Sync() error  { return nil }
+func (mb *memBackend) Close() error { return nil }
+
+func (mp *memProvider) Create(location string) (storage.VolumeBackend, error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if _, ok := mp.backends[location]; ok {
+		return nil, fmt.Errorf("backend %q already exists", location)
+	}
+	mb := &memBackend{writes: mp.writes}
+	mp.backends[location] = mb
+	return mb, nil
+}
+
+func (mp *memProvider) Open(location string) (storage.VolumeBackend, error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mb, ok := mp.backends[location]
+	if !ok {
+		return nil, errors.New("backend not found")
+	}
+	return mb, nil
+}
+
+func (mp *memProvider) Remove(location string) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	delete(mp.backends, location)
+	return nil
+}
+
+func (mp *memProvider) FreeSpace(string) (uint64, error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	return mp.free, nil
+}
+
+func (mp *memProvider) setFreeSpace(free uint64) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.free = free
+}
+
+func (mp *memProvider) backend(location string) (*memBackend, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mb, ok := mp.backends[location]
+	return mb, ok
+}
+
+func TestBackendProvider(t *testing.T) {
+	const location = "mem://volume"
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	provider := &memProvider{backends: make(map[string]*memBackend)}
+	// disable the cache so every read hits the backend
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithBackendProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	volume, err := vm.AddVolume(context.Background(), location, 10, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	mb, ok := provider.backend(location)
+	if !ok {
+		t.Fatal("expected backend to be created")
+	} else if n := mb.len(); n != 10 {
+		t.Fatalf("expected 10 sectors, got %v", n)
+	}
+
+	// adding a second volume at the same location should fail
+	if _, err := vm.AddVolume(context.Background(), location, 10, result); err == nil {
+		t.Fatal("expected error adding duplicate volume")
+	}
+
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:256])
+	root := rhp2.SectorRoot(&sector)
+	release, err := vm.Write(root, &sector)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 1}}); err != nil {
+		t.Fatal(err)
+	} else if err := release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sector should be read back from the backend
+	if read, err := vm.Read(root); err != nil {
+		t.Fatal(err)
+	} else if *read != sector {
+		t.Fatal("sector data mismatch")
+	}
+
+	// grow the volume
+	if err := vm.ResizeVolume(context.Background(), volume.ID, 20, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	} else if n := mb.len(); n != 20 {
+		t.Fatalf("expected 20 sectors, got %v", n)
+	}
+
+	// the sector should still be readable after the resize
+	if read, err := vm.Read(root); err != nil {
+		t.Fatal(err)
+	} else if *read != sector {
+		t.Fatal("sector data mismatch")
+	}
+
+	// removing the volume should remove the backend
+	if err := vm.RemoveVolume(context.Background(), volume.ID, true, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	} else if _, ok := provider.backend(location); ok {
+		t.Fatal("expected backend to be removed")
+	}
+}
+
+func TestWriteRetry(t *testing.T) {
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	provider := &memProvider{backends: make(map[string]*memBackend)}
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithBackendProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	addVolume := func(location string) (storage.Volume, *memBackend) {
+		result := make(chan error, 1)
+		volume, err := vm.AddVolume(context.Background(), location, 10, result)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+		mb, _ := provider.backend(location)
+		return volume, mb
+	}
+
+	// the first volume is preferred when both are empty
+	first, firstBackend := addVolume("mem://first")
+	second, secondBackend := addVolume("mem://second")
+	firstBackend.failWrites(errors.New("no space left on device"))
+
+	writeSector := func() (types.Hash256, error) {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			return root, err
+		} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 1}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+		return root, nil
+	}
+
+	for i := 0; i < 5; i++ {
+		root, err := writeSector()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the sector should have been written to the second volume
+		loc, release, err := db.SectorLocation(root)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		} else if loc.Volume != second.ID {
+			t.Fatalf("expected sector in volume %v, got %v", second.ID, loc.Volume)
+		} else if read, err := vm.Read(root); err != nil {
+			t.Fatal(err)
+		} else if rhp2.SectorRoot(read) != root {
+			t.Fatal("sector data mismatch")
+		}
+	}
+
+	if firstBackend.failed() == 0 {
+		t.Fatal("expected a write to be attempted on the first volume")
+	}
+
+	// the failed writes should not use space in the first volume
+	volumes, err := vm.Volumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vol := range volumes {
+		switch vol.ID {
+		case first.ID:
+			if vol.UsedSectors != 0 {
+				t.Fatalf("expected first volume to be empty, got %v sectors", vol.UsedSectors)
+			}
+		case second.ID:
+			if vol.UsedSectors != 5 {
+				t.Fatalf("expected 5 sectors in second volume, got %v", vol.UsedSectors)
+			}
+		}
+	}
+
+	// if every volume fails, the write should fail
+	secondBackend.failWrites(errors.New("i/o error"))
+	if _, err := writeSector(); !errors.Is(err, storage.ErrNotEnoughStorage) {
+		t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+	}
+}
+
+func TestWriteLatencySLO(t *testing.T) {
+	const location = "mem://volume"
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	provider := &memProvider{backends: make(map[string]*memBackend)}
+	const window = time.Second
+	slo := storage.LatencySLO{Percentile: 0.9, Threshold: 20 * time.Millisecond, Window: window}
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithBackendProvider(provider), storage.WithWriteLatencySLO(slo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	volume, err := vm.AddVolume(context.Background(), location, 30, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	mb, ok := provider.backend(location)
+	if !ok {
+		t.Fatal("expected backend to be created")
+	}
+
+	var releaseFuncs []func() error
+	defer func() {
+		for _, release := range releaseFuncs {
+			if err := release(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}()
+	writeSectors := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			var sector [rhp2.SectorSize]byte
+			frand.Read(sector[:256])
+			release, err := vm.Write(rhp2.SectorRoot(&sector), &sector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			releaseFuncs = append(releaseFuncs, release)
+		}
+	}
+
+	sloAlert := func() (alerts.Alert, bool) {
+		for _, a := range am.Active() {
+			if a.Message == "Volume write latency exceeds SLO" {
+				return a, true
+			}
+		}
+		return alerts.Alert{}, false
+	}
+
+	// fast writes should not trip the SLO
+	writeSectors(10)
+	if a, ok := sloAlert(); ok {
+		t.Fatalf("unexpected alert: %v", a)
+	}
+
+	// slow writes more than twice the threshold should register an error
+	mb.delayWrites(3 * slo.Threshold)
+	writeSectors(10)
+	if a, ok := sloAlert(); !ok {
+		t.Fatal("expected SLO alert")
+	} else if a.Severity != alerts.SeverityError {
+		t.Fatalf("expected error severity, got %v", a.Severity)
+	}
+
+	meta, err := vm.Volume(volume.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if meta.WriteLatencyP99 < 3*slo.Threshold {
+		t.Fatalf("expected p99 latency of at least %v, got %v", 3*slo.Threshold, meta.WriteLatencyP99)
+	}
+
+	// once the slow writes leave the window, the alert should be dismissed
+	mb.delayWrites(0)
+	time.Sleep(window)
+	writeSectors(10)
+	if a, ok := sloAlert(); ok {
+		t.Fatalf("expected alert to be dismissed, got %v", a)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	const location = "mem://volume"
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	provider := &memProvider{backends: make(map[string]*memBackend)}
+	const timeout = 50 * time.Millisecond
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithBackendProvider(provider), storage.WithWriteTimeout(timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	volume, err := vm.AddVolume(context.Background(), location, 10, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	mb, ok := provider.backend(location)
+	if !ok {
+		t.Fatal("expected backend to be created")
+	}
+
+	writeSector := func() (types.Hash256, error) {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			return root, err
+		}
+		return root, release()
+	}
+
+	timeoutAlert := func() bool {
+		for _, a := range am.Active() {
+			if a.Message == "Volume write timed out" {
+				return true
+			}
+		}
+		return false
+	}
+
+	checkDegraded := func(degraded bool) {
+		t.Helper()
+		meta, err := vm.Volume(volume.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if meta.Degraded != degraded {
+			t.Fatalf("expected degraded %v, got %v", degraded, meta.Degraded)
+		} else if timeoutAlert() != degraded {
+			t.Fatalf("expected alert %v, got %v", degraded, !degraded)
+		}
+	}
+
+	// keep a sector locked for the rest of the test
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:256])
+	stored := rhp2.SectorRoot(&sector)
+	release, err := vm.Write(stored, &sector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	checkDegraded(false)
+
+	// degrading the volume should notify the unavailable subscribers
+	notified := make(chan int64, 1)
+	err = vm.OnVolumeUnavailable(func(volumeID int64, _ string) {
+		select {
+		case notified <- volumeID:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a hung write should return once the timeout is exceeded
+	mb.delayWrites(10 * timeout)
+	start := time.Now()
+	root, err := writeSector()
+	var timeoutErr *storage.TimeoutError
+	var volumeErr *storage.VolumeError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected TimeoutError, got %v", err)
+	} else if timeoutErr.Duration != timeout {
+		t.Fatalf("expected timeout %v, got %v", timeout, timeoutErr.Duration)
+	} else if !errors.As(err, &volumeErr) || volumeErr.VolumeID != volume.ID {
+		t.Fatalf("expected VolumeError for volume %v, got %v", volume.ID, err)
+	} else if elapsed := time.Since(start); elapsed >= 10*timeout {
+		t.Fatalf("expected write to be abandoned after %v, took %v", timeout, elapsed)
+	}
+	checkDegraded(true)
+
+	select {
+	case id := <-notified:
+		if id != volume.ID {
+			t.Fatalf("expected volume %v to be reported, got %v", volume.ID, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the degraded volume to be reported")
+	}
+
+	// the sector should not have been stored
+	if _, err := vm.Read(root); !errors.Is(err, storage.ErrSectorNotFound) {
+		t.Fatalf("expected ErrSectorNotFound, got %v", err)
+	}
+
+	// new sectors should not be stored in the degraded volume
+	if _, err := writeSector(); !errors.Is(err, storage.ErrNotEnoughStorage) {
+		t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+	}
+
+	// syncing should not wait for the hung write
+	start = time.Now()
+	if err := vm.Sync(); err != nil {
+		t.Fatal(err)
+	} else if elapsed := time.Since(start); elapsed >= 5*timeout {
+		t.Fatalf("expected sync to skip the degraded volume, took %v", elapsed)
+	}
+
+	// sectors in the degraded volume cannot be removed since their
+	// locations cannot be zeroed
+	if err := vm.RemoveSector(stored); !errors.Is(err, storage.ErrVolumeDegraded) {
+		t.Fatalf("expected ErrVolumeDegraded, got %v", err)
+	}
+
+	// once the abandoned write completes, the volume should recover
+	mb.delayWrites(0)
+	for i := 0; i < 100; i++ {
+		meta, err := vm.Volume(volume.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if !meta.Degraded {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	checkDegraded(false)
+	if _, err := writeSector(); err != nil {
+		t.Fatal(err)
+	} else if err := vm.RemoveSector(stored); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelfAudit(t *testing.T) {
+	const location = "mem://volume"
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	provider := &memProvider{backends: make(map[string]*memBackend)}
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 10, storage.WithBackendProvider(provider), storage.WithSelfAudit(storage.SelfAuditConfig{SampleSize: 5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	if _, err := vm.AddVolume(context.Background(), location, 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	mb, ok := provider.backend(location)
+	if !ok {
+		t.Fatal("expected backend to be created")
+	}
+
+	for i := 0; i < 5; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		release, err := vm.Write(rhp2.SectorRoot(&sector), &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+	}
+
+	auditAlert := func() (alerts.Alert, bool) {
+		for _, a := range am.Active() {
+			if a.Message == "Self-audit found sector read problems" {
+				return a, true
+			}
+		}
+		return alerts.Alert{}, false
+	}
+
+	audit, err := vm.SelfAudit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if audit.Sampled != 5 {
+		t.Fatalf("expected 5 sampled sectors, got %v", audit.Sampled)
+	} else if len(audit.Failures) != 0 {
+		t.Fatalf("expected no failures, got %v", audit.Failures)
+	} else if a, ok := auditAlert(); ok {
+		t.Fatalf("unexpected alert: %v", a)
+	}
+
+	// reads are served from the cache, but the audit should still detect the
+	// failing disk
+	mb.failReads(errors.New("simulated read failure"))
+	audit, err = vm.SelfAudit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(audit.Failures) != audit.Sampled {
+		t.Fatalf("expected %v failures, got %v", audit.Sampled, len(audit.Failures))
+	} else if a, ok := auditAlert(); !ok {
+		t.Fatal("expected self-audit alert")
+	} else if a.Severity != alerts.SeverityError {
+		t.Fatalf("expected error severity, got %v", a.Severity)
+	}
+
+	// the alert should be dismissed once the reads succeed again
+	mb.failReads(nil)
+	audit, err = vm.SelfAudit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(audit.Failures) != 0 {
+		t.Fatalf("expected no failures, got %v", audit.Failures)
+	} else if _, ok := auditAlert(); ok {
+		t.Fatal("expected self-audit alert to be dismissed")
+	}
+}
+
+func TestConcurrentMigrationLimit(t *testing.T) {
+	const sectors = 10
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	provider := &memProvider{backends: make(map[string]*memBackend), writes: new(writeTracker)}
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithBackendProvider(provider), storage.WithMaxConcurrentMigrations(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	addVolume := func(location string) storage.Volume {
+		t.Helper()
+		result := make(chan error, 1)
+		vol, err := vm.AddVolume(context.Background(), location, sectors, result)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+		return vol
+	}
+
+	// fill two volumes
+	src := []storage.Volume{addVolume("mem://a"), addVolume("mem://b")}
+	roots := make([]types.Hash256, 0, 2*sectors)
+	for i := 0; i < cap(roots); i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 1}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	for _, vol := range src {
+		if err := vm.SetReadOnly(vol.ID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// add two empty volumes with slow writes so the migrations overlap
+	for _, location := range []string{"mem://c", "mem://d"} {
+		addVolume(location)
+		mb, _ := provider.backend(location)
+		mb.delayWrites(10 * time.Millisecond)
+	}
+
+	// remove both full volumes at the same time
+	results := make([]chan error, len(src))
+	for i, vol := range src {
+		results[i] = make(chan error, 1)
+		if err := vm.RemoveVolume(context.Background(), vol.ID, false, results[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the migrations should share a single slot
+	var queued bool
+	for _, result := range results {
+	wait:
+		for {
+			select {
+			case err := <-result:
+				if err != nil {
+					t.Fatal(err)
+				}
+				break wait
+			case <-time.After(5 * time.Millisecond):
+				stats := vm.MigrationStats()
+				if stats.Active > 1 {
+					t.Fatalf("expected at most 1 active migration, got %v", stats.Active)
+				}
+				queued = queued || stats.Queued > 0
+			}
+		}
+	}
+
+	if n := provider.writes.maxConcurrent(); n != 1 {
+		t.Fatalf("expected at most 1 concurrent write, got %v", n)
+	} else if !queued {
+		t.Fatal("expected migrations to be queued")
+	}
+
+	stats := vm.MigrationStats()
+	switch {
+	case stats.Limit != 1:
+		t.Fatalf("expected limit 1, got %v", stats.Limit)
+	case stats.Migrated != uint64(len(roots)):
+		t.Fatalf("expected %v migrated sectors, got %v", len(roots), stats.Migrated)
+	case stats.Active != 0 || stats.Queued != 0:
+		t.Fatalf("expected no active or queued migrations, got %v and %v", stats.Active, stats.Queued)
+	case stats.Throughput == 0:
+		t.Fatal("expected non-zero throughput")
+	}
+
+	for _, root := range roots {
+		if sector, err := vm.Read(root); err != nil {
+			t.Fatal(err)
+		} else if rhp2.SectorRoot(sector) != root {
+			t.Fatal("sector was corrupted")
+		}
+	}
+}
+
+func TestAutoGrowVolume(t *testing.T) {
+	const location = "mem://volume"
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	provider := &memProvider{backends: make(map[string]*memBackend)}
+	cfg := storage.AutoGrowConfig{
+		Threshold:  2,
+		Increment:  10,
+		MaxSectors: 15,
+	}
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithBackendProvider(provider), storage.WithAutoGrow(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	volume, err := vm.AddVolume(context.Background(), location, 10, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	assertTotalSectors := func(expected uint64) {
+		t.Helper()
+		meta, err := vm.Volume(volume.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if meta.TotalSectors != expected {
+			t.Fatalf("expected %v total sectors, got %v", expected, meta.TotalSectors)
+		}
+	}
+
+	hasAlert := func(message string) bool {
+		for _, a := range am.Active() {
+			if a.Message == message {
+				return true
+			}
+		}
+		return false
+	}
+
+	// a volume with enough free sectors should not be grown
+	provider.setFreeSpace(100 * rhp2.SectorSize)
+	if grown, err := vm.AutoGrow(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(grown) != 0 {
+		t.Fatalf("expected no volumes to be grown, got %v", grown)
+	}
+
+	// fill the volume until it is nearly full
+	for i := 0; i < 9; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		release, err := vm.Write(rhp2.SectorRoot(&sector), &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+	}
+
+	// the volume should not be grown if the underlying storage is full
+	provider.setFreeSpace(rhp2.SectorSize)
+	if grown, err := vm.AutoGrow(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(grown) != 0 {
+		t.Fatalf("expected no volumes to be grown, got %v", grown)
+	} else if !hasAlert("Volume is running out of space and could not be grown") {
+		t.Fatal("expected an alert for the volume that could not be grown")
+	}
+	assertTotalSectors(10)
+
+	// the volume should be grown up to the cap once space is available
+	provider.setFreeSpace(100 * rhp2.SectorSize)
+	grown, err := vm.AutoGrow(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(grown) != 1 {
+		t.Fatalf("expected 1 volume to be grown, got %v", len(grown))
+	} else if grown[0].VolumeID != volume.ID || grown[0].OldSectors != 10 || grown[0].NewSectors != 15 {
+		t.Fatalf("unexpected growth %+v", grown[0])
+	} else if !hasAlert("Volume grown automatically") {
+		t.Fatal("expected an alert for the grown volume")
+	} else if hasAlert("Volume is running out of space and could not be grown") {
+		t.Fatal("expected the failure alert to be dismissed")
+	}
+	assertTotalSectors(15)
+	if mb, ok := provider.backend(location); !ok {
+		t.Fatal("missing backend")
+	} else if n := mb.len(); n != 15 {
+		t.Fatalf("expected backend to hold 15 sectors, got %v", n)
+	}
+
+	// fill the volume again; it is at the cap and should not be grown
+	for i := 0; i < 5; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		release, err := vm.Write(rhp2.SectorRoot(&sector), &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+	}
+	if grown, err := vm.AutoGrow(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(grown) != 0 {
+		t.Fatalf("expected no volumes to be grown, got %v", grown)
+	}
+	assertTotalSectors(15)
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.uber.org/zap"
+)
+
+// defaultCachePressureInterval is the time between heap checks if no
+// interval is configured.
+const defaultCachePressureInterval = 10 * time.Second
+
+// heapMetric is the runtime metric compared against the cache's high-water
+// mark.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+type (
+	// A CacheMemoryConfig limits the memory used by the sector cache.
+	CacheMemoryConfig struct {
+		// MaxBytes is the maximum amount of memory used by cached sectors.
+		// The cache holds the smaller of the configured sector cache size and
+		// MaxBytes worth of sectors. Zero does not limit the cache's memory.
+		MaxBytes uint64
+		// HighWaterMark is the heap size, in bytes, above which half of the
+		// cached sectors are evicted. The heap is checked every
+		// CheckInterval. Zero disables the check.
+		HighWaterMark uint64
+		// CheckInterval is the time between heap checks. Defaults to 10
+		// seconds.
+		CheckInterval time.Duration
+	}
+
+	// SectorCacheStats reports the state of the sector cache. The stats are
+	// not persisted and are reset when the host restarts.
+	SectorCacheStats struct {
+		// Sectors is the number of sectors currently cached.
+		Sectors int `json:"sectors"`
+		// Capacity is the maximum number of sectors the cache can hold.
+		Capacity int `json:"capacity"`
+		// MemoryBytes is the memory used by the cached sectors.
+		MemoryBytes uint64 `json:"memoryBytes"`
+		// MaxMemoryBytes is the configured memory limit. Zero means the
+		// cache is only limited by its sector count.
+		MaxMemoryBytes uint64 `json:"maxMemoryBytes"`
+		// Hits is the number of reads served from the cache.
+		Hits uint64 `json:"hits"`
+		// Misses is the number of reads not served from the cache.
+		Misses uint64 `json:"misses"`
+		// PressureEvictions is the number of sectors evicted because of
+		// memory pressure.
+		PressureEvictions uint64 `json:"pressureEvictions"`
+	}
+)
+
+// cacheCapacity returns the number of sectors the cache can hold given the
+// requested size and the configured memory limit. The caller must hold vm.mu.
+func (vm *VolumeManager) cacheCapacity() int {
+	size := uint64(vm.cacheSize)
+	if vm.cacheMemory.MaxBytes > 0 {
+		size = min(size, vm.cacheMemory.MaxBytes/rhp2.SectorSize)
+	}
+	return int(size)
+}
+
+// ReleaseCacheMemory evicts the least recently used half of the cached
+// sectors, rounded up. It should be called when the host is under memory
+// pressure. The cache's capacity is not changed, so it refills as sectors
+// are read.
+func (vm *VolumeManager) ReleaseCacheMemory() (evicted int) {
+	n := (vm.cache.Len() + 1) / 2
+	for i := 0; i < n; i++ {
+		if _, _, ok := vm.cache.RemoveOldest(); !ok {
+			break
+		}
+		evicted++
+	}
+	atomic.AddUint64(&vm.cachePressureEvictions, uint64(evicted))
+	return
+}
+
+// SectorCacheStats returns the state of the sector cache.
+func (vm *VolumeManager) SectorCacheStats() SectorCacheStats {
+	vm.mu.Lock()
+	capacity := vm.cacheCapacity()
+	vm.mu.Unlock()
+
+	sectors := vm.cache.Len()
+	return SectorCacheStats{
+		Sectors:           sectors,
+		Capacity:          capacity,
+		MemoryBytes:       uint64(sectors) * rhp2.SectorSize,
+		MaxMemoryBytes:    vm.cacheMemory.MaxBytes,
+		Hits:              atomic.LoadUint64(&vm.cacheHits),
+		Misses:            atomic.LoadUint64(&vm.cacheMisses),
+		PressureEvictions: atomic.LoadUint64(&vm.cachePressureEvictions),
+	}
+}
+
+// heapSize returns the number of bytes occupied by live and unswept heap
+// objects.
+func heapSize() uint64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// runCachePressure periodically checks the heap size and releases cache
+// memory while it is above the high-water mark.
+func (vm *VolumeManager) runCachePressure() {
+	ctx, cancel, err := vm.tg.AddContext(context.Background())
+	if err != nil {
+		return
+	}
+	defer cancel()
+
+	interval := vm.cacheMemory.CheckInterval
+	if interval <= 0 {
+		interval = defaultCachePressureInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		heap := heapSize()
+		if heap <= vm.cacheMemory.HighWaterMark {
+			continue
+		} else if evicted := vm.ReleaseCacheMemory(); evicted > 0 {
+			vm.log.Debug("released sector cache memory", zap.Uint64("heap", heap), zap.Uint64("highWaterMark", vm.cacheMemory.HighWaterMark), zap.Int("evicted", evicted))
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"hash/crc32"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// sectorChecksum returns the CRC32C checksum of a sector's data. It is much
+// cheaper to compute than the sector's Merkle root, but only detects
+// accidental corruption.
+func sectorChecksum(sector *[rhp2.SectorSize]byte) uint32 {
+	return crc32.Checksum(sector[:], castagnoli)
+}
+
+// storeChecksum stores the checksum of a sector's data. Failures are logged
+// rather than returned since the checksum is only an optimization.
+func (vm *VolumeManager) storeChecksum(root types.Hash256, sector *[rhp2.SectorSize]byte) {
+	if err := vm.vs.SetSectorChecksum(root, sectorChecksum(sector)); err != nil {
+		vm.log.Warn("failed to store sector checksum", zap.Stringer("root", root), zap.Error(err))
+	}
+}
+
+// verifySector checks the integrity of sector data read from disk. The
+// stored checksum is checked first and the Merkle root is only computed if
+// the checksum does not match. If the sector does not have a stored checksum,
+// the Merkle root is only verified when backfill is true, in which case the
+// checksum is stored for future checks.
+func (vm *VolumeManager) verifySector(root types.Hash256, sector *[rhp2.SectorSize]byte, backfill bool) error {
+	checksum, ok, err := vm.vs.SectorChecksum(root)
+	if err != nil {
+		return fmt.Errorf("failed to get sector checksum: %w", err)
+	} else if ok && sectorChecksum(sector) == checksum {
+		return nil
+	} else if !ok && !backfill {
+		return nil
+	}
+
+	// the checksum is missing or does not match, fall back to the Merkle root
+	if calculated := rhp2.SectorRoot(sector); calculated != root {
+		return fmt.Errorf("%w: got root %v", ErrSectorCorrupt, calculated)
+	}
+	// the data is valid, so the stored checksum is missing or wrong
+	vm.storeChecksum(root, sector)
+	return nil
+}
+
+// VerifySector reads a sector from disk, bypassing the cache, and checks its
+// integrity. If sector checksums are enabled, the stored checksum is used to
+// avoid recomputing the Merkle root. ErrSectorCorrupt is returned if the
+// sector's data does not match its root.
+func (vm *VolumeManager) VerifySector(root types.Hash256) error {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	loc, release, err := vm.vs.SectorLocation(root)
+	if err != nil {
+		return &SectorError{Root: root, Err: fmt.Errorf("failed to locate sector: %w", err)}
+	}
+	defer release()
+
+	vm.mu.Lock()
+	v, ok := vm.volumes[loc.Volume]
+	vm.mu.Unlock()
+	if !ok {
+		return &SectorError{Root: root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpRead, Err: ErrVolumeNotFound}}
+	}
+
+	sector, err := v.ReadSector(loc.Index)
+	if err != nil {
+		return &SectorError{Root: root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpRead, Err: err}}
+	} else if !vm.checksums {
+		if calculated := rhp2.SectorRoot(sector); calculated != root {
+			return &SectorError{Root: root, Err: fmt.Errorf("%w: got root %v", ErrSectorCorrupt, calculated)}
+		}
+		return nil
+	} else if err := vm.verifySector(root, sector, true); err != nil {
+		return &SectorError{Root: root, Err: err}
+	}
+	return nil
+}
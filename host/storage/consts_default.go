@@ -6,6 +6,7 @@ import "time"
 
 const (
 	resizeBatchSize = 64 // 256 MiB
+	zeroBatchSize   = 64 // 256 MiB
 
 	cleanupInterval = 15 * time.Minute
 )
@@ -6,4 +6,5 @@ const (
 	cleanupInterval = 0
 
 	resizeBatchSize = 4 // 16 MiB
+	zeroBatchSize   = 2 // 8 MiB
 )
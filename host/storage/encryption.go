@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+
+	"go.sia.tech/core/types"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/xts"
+	"lukechampine.com/frand"
+)
+
+// argon2id parameters used to derive volume keys from the passphrase
+const (
+	volumeKeyTime    = 1
+	volumeKeyMemory  = 64 * 1024 // 64 MiB
+	volumeKeyThreads = 4
+	// volumeKeySize is the size of an XTS-AES-256 key
+	volumeKeySize = 64
+)
+
+var (
+	// ErrEncryptionPassphraseRequired is returned when an encrypted volume is
+	// opened without an encryption passphrase.
+	ErrEncryptionPassphraseRequired = errors.New("volume is encrypted, but no passphrase was provided")
+	// ErrIncorrectPassphrase is returned when the encryption passphrase does
+	// not match the passphrase a volume was encrypted with.
+	ErrIncorrectPassphrase = errors.New("incorrect volume encryption passphrase")
+	// ErrVolumeNotEncrypted is returned when the encryption metadata of an
+	// unencrypted volume is requested.
+	ErrVolumeNotEncrypted = errors.New("volume is not encrypted")
+)
+
+// VolumeEncryption contains the metadata needed to derive a volume's
+// encryption key from the passphrase. The key itself is never stored.
+type VolumeEncryption struct {
+	Salt [32]byte
+	// KeyCheck is a hash of the derived key. It is used to detect an
+	// incorrect passphrase before any sectors are read.
+	KeyCheck types.Hash256
+}
+
+// deriveVolumeKey derives a volume's encryption key from the passphrase and
+// the volume's salt.
+func deriveVolumeKey(passphrase []byte, salt [32]byte) []byte {
+	return argon2.IDKey(passphrase, salt[:], volumeKeyTime, volumeKeyMemory, volumeKeyThreads, volumeKeySize)
+}
+
+// volumeKeyCheck returns the hash stored to verify a derived volume key.
+func volumeKeyCheck(key []byte) types.Hash256 {
+	return types.HashBytes(append([]byte("hostd volume key check"), key...))
+}
+
+// newVolumeEncryption generates the encryption metadata and cipher for a new
+// volume.
+func newVolumeEncryption(passphrase []byte) (VolumeEncryption, *xts.Cipher, error) {
+	enc := VolumeEncryption{
+		Salt: frand.Entropy256(),
+	}
+	key := deriveVolumeKey(passphrase, enc.Salt)
+	enc.KeyCheck = volumeKeyCheck(key)
+	c, err := xts.NewCipher(aes.NewCipher, key)
+	if err != nil {
+		return VolumeEncryption{}, nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return enc, c, nil
+}
+
+// volumeCipher returns the cipher used to encrypt an existing volume, or nil
+// if the volume is not encrypted.
+func (vm *VolumeManager) volumeCipher(vol Volume) (*xts.Cipher, error) {
+	if !vol.Encrypted {
+		return nil, nil
+	} else if len(vm.passphrase) == 0 {
+		return nil, ErrEncryptionPassphraseRequired
+	}
+
+	enc, err := vm.vs.VolumeEncryption(vol.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume encryption: %w", err)
+	}
+	key := deriveVolumeKey(vm.passphrase, enc.Salt)
+	if volumeKeyCheck(key) != enc.KeyCheck {
+		return nil, ErrIncorrectPassphrase
+	}
+	c, err := xts.NewCipher(aes.NewCipher, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+)
+
+// Volume operations reported by a VolumeError.
+const (
+	VolumeOpRead            = "read"
+	VolumeOpWrite           = "write"
+	VolumeOpSync            = "sync"
+	VolumeOpResize          = "resize"
+	VolumeOpRemove          = "remove"
+	VolumeOpSetReadOnly     = "set read-only"
+	VolumeOpSetReplicaGroup = "set replica group"
+	VolumeOpSwap            = "swap backing file"
+)
+
+// A VolumeError is returned when an operation on a volume fails. The
+// underlying cause can be inspected with errors.Is and errors.As.
+type VolumeError struct {
+	VolumeID int64
+	Op       string
+	Err      error
+}
+
+// Error implements error.
+func (e *VolumeError) Error() string {
+	return fmt.Sprintf("%s volume %d: %v", e.Op, e.VolumeID, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *VolumeError) Unwrap() error {
+	return e.Err
+}
+
+// A SectorError is returned when an operation on a sector fails. If the
+// failure was caused by the sector's volume, Err is a *VolumeError.
+type SectorError struct {
+	Root types.Hash256
+	Err  error
+}
+
+// Error implements error.
+func (e *SectorError) Error() string {
+	return fmt.Sprintf("sector %v: %v", e.Root, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SectorError) Unwrap() error {
+	return e.Err
+}
+
+// A VolumeLimitError is returned when a volume cannot be added because the
+// host already has the maximum number of volumes.
+type VolumeLimitError struct {
+	Volumes int
+	Limit   int
+}
+
+// Error implements error.
+func (e *VolumeLimitError) Error() string {
+	return fmt.Sprintf("host has %d volumes, the maximum is %d", e.Volumes, e.Limit)
+}
+
+// A TimeoutError is returned when a volume operation does not complete
+// within the configured timeout. The operation is abandoned, but may still
+// complete in the background.
+type TimeoutError struct {
+	Duration time.Duration
+}
+
+// Error implements error.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("operation timed out after %v", e.Duration)
+}
+
+// Timeout returns true. It implements the interface checked by net.Error
+// and os.IsTimeout.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
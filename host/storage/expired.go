@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"fmt"
+
+	"go.sia.tech/core/types"
+)
+
+// An ExpiredSectorConfig configures the handling of sectors that are only
+// referenced by contracts whose proof window has passed, but have not been
+// pruned yet.
+type ExpiredSectorConfig struct {
+	// GracePeriod is the number of blocks after a contract's proof window
+	// ends before its sectors are considered reclaimable.
+	GracePeriod uint64
+	// ReclaimOnMigration removes reclaimable sectors from a volume instead of
+	// migrating them when the volume is removed or shrunk.
+	ReclaimOnMigration bool
+}
+
+// reclaimHeight returns the height contracts must have expired before for
+// their sectors to be reclaimable.
+func (vm *VolumeManager) reclaimHeight() uint64 {
+	height := vm.cm.TipState().Index.Height
+	if height <= vm.expired.GracePeriod {
+		return 0
+	}
+	return height - vm.expired.GracePeriod
+}
+
+// reclaimExpiredSectors removes the references of expired contracts to
+// sectors stored in the volume at or after minIndex. Sectors that are no
+// longer referenced are removed and no longer need to be migrated.
+func (vm *VolumeManager) reclaimExpiredSectors(id int64, minIndex uint64) ([]types.Hash256, error) {
+	reclaimed, err := vm.vs.ReclaimExpiredSectors(id, minIndex, vm.reclaimHeight())
+	if err != nil {
+		return nil, fmt.Errorf("failed to reclaim expired sectors: %w", err)
+	}
+	for _, root := range reclaimed {
+		vm.cache.Remove(root)
+	}
+	return reclaimed, nil
+}
+
+// ReclaimableSectors returns the number of sectors in the volume that are
+// only referenced by contracts that expired more than the grace period ago.
+// They will be removed when the host next prunes expired sectors.
+func (vm *VolumeManager) ReclaimableSectors(id int64) (uint64, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return vm.vs.ReclaimableSectors(id, vm.reclaimHeight())
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type (
+	// A handleCache limits the number of volume files that are open at the
+	// same time. Files are opened on demand and the least recently used idle
+	// file is closed when the limit is reached.
+	handleCache struct {
+		max int // zero means unlimited
+		log *zap.Logger
+
+		mu   sync.Mutex // protects the fields below
+		lru  *list.List // *cachedFile, most recently used at the front
+		open int
+	}
+
+	// A cachedFile is a volume file whose underlying handle is managed by a
+	// handleCache. The handle is pinned open for the duration of each
+	// operation.
+	cachedFile struct {
+		path  string
+		cache *handleCache
+
+		// the fields below are protected by the cache's mutex
+		f      *os.File
+		refs   int
+		elem   *list.Element
+		closed bool
+		// syncErr is the error from syncing an evicted handle. Writes made
+		// through that handle may not be durable, so the error is returned
+		// by the next call to Sync.
+		syncErr error
+	}
+
+	// An evictedFile is a handle removed from the cache that still needs to
+	// be synced and closed.
+	evictedFile struct {
+		cf *cachedFile
+		f  *os.File
+	}
+)
+
+// evict closes idle handles until the number of open handles is below the
+// limit. Handles that are in use are never closed, so the limit may be
+// temporarily exceeded if every open handle is pinned. The caller must hold
+// the cache's mutex. The evicted files are returned so they can be closed
+// without holding the lock.
+func (hc *handleCache) evict() (evicted []evictedFile) {
+	for e := hc.lru.Back(); e != nil && hc.open >= hc.max; {
+		prev := e.Prev()
+		cf := e.Value.(*cachedFile)
+		if cf.refs == 0 {
+			evicted = append(evicted, evictedFile{cf, cf.f})
+			cf.f = nil
+			cf.elem = nil
+			hc.lru.Remove(e)
+			hc.open--
+		}
+		e = prev
+	}
+	return
+}
+
+// add tracks an open handle for cf. The caller must hold the cache's mutex.
+func (hc *handleCache) add(cf *cachedFile, f *os.File) {
+	cf.f = f
+	cf.elem = hc.lru.PushFront(cf)
+	hc.open++
+}
+
+// Open returns the volume data for the file at path. If the cache is
+// unlimited, the file is opened directly.
+func (hc *handleCache) Open(path string) (volumeData, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return hc.Adopt(path, f), nil
+}
+
+// Adopt wraps an already open file so that its handle is managed by the
+// cache.
+func (hc *handleCache) Adopt(path string, f *os.File) volumeData {
+	if hc.max <= 0 {
+		return f
+	}
+
+	hc.mu.Lock()
+	evicted := hc.evict()
+	cf := &cachedFile{path: path, cache: hc}
+	hc.add(cf, f)
+	hc.mu.Unlock()
+	hc.closeFiles(evicted)
+	return cf
+}
+
+// closeFiles syncs and closes evicted handles. A sync error is recorded on
+// the file so that it is returned by the file's next Sync. Close errors are
+// only logged since the handle is reopened on the next access.
+func (hc *handleCache) closeFiles(files []evictedFile) {
+	for _, ef := range files {
+		if err := ef.f.Sync(); err != nil {
+			hc.log.Error("failed to sync evicted volume handle", zap.String("path", ef.cf.path), zap.Error(err))
+			hc.mu.Lock()
+			if ef.cf.syncErr == nil {
+				ef.cf.syncErr = err
+			}
+			hc.mu.Unlock()
+		}
+		if err := ef.f.Close(); err != nil {
+			hc.log.Warn("failed to close evicted volume handle", zap.String("path", ef.cf.path), zap.Error(err))
+		}
+	}
+}
+
+// acquire pins the file's handle open, reopening it if it was evicted. The
+// returned function must be called to release the handle.
+func (cf *cachedFile) acquire() (*os.File, func(), error) {
+	hc := cf.cache
+	hc.mu.Lock()
+	if cf.closed {
+		hc.mu.Unlock()
+		return nil, nil, os.ErrClosed
+	}
+
+	var evicted []evictedFile
+	if cf.f == nil {
+		f, err := os.OpenFile(cf.path, os.O_RDWR, 0700)
+		if err != nil {
+			hc.mu.Unlock()
+			return nil, nil, err
+		}
+		evicted = hc.evict()
+		hc.add(cf, f)
+	} else {
+		hc.lru.MoveToFront(cf.elem)
+	}
+	cf.refs++
+	f := cf.f
+	hc.mu.Unlock()
+	hc.closeFiles(evicted)
+
+	return f, func() {
+		hc.mu.Lock()
+		cf.refs--
+		hc.mu.Unlock()
+	}, nil
+}
+
+// ReadAt implements io.ReaderAt
+func (cf *cachedFile) ReadAt(b []byte, off int64) (int, error) {
+	f, release, err := cf.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return f.ReadAt(b, off)
+}
+
+// WriteAt implements io.WriterAt
+func (cf *cachedFile) WriteAt(b []byte, off int64) (int, error) {
+	f, release, err := cf.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return f.WriteAt(b, off)
+}
+
+// Sync syncs the file if its handle is open. Evicted handles are synced
+// before they are closed; if that failed, the error is returned instead.
+func (cf *cachedFile) Sync() error {
+	hc := cf.cache
+	hc.mu.Lock()
+	if err := cf.syncErr; err != nil {
+		cf.syncErr = nil
+		hc.mu.Unlock()
+		return fmt.Errorf("failed to sync evicted handle: %w", err)
+	} else if cf.f == nil {
+		hc.mu.Unlock()
+		return nil
+	}
+	cf.refs++
+	f := cf.f
+	hc.mu.Unlock()
+
+	defer func() {
+		hc.mu.Lock()
+		cf.refs--
+		hc.mu.Unlock()
+	}()
+	return f.Sync()
+}
+
+// Truncate changes the size of the file
+func (cf *cachedFile) Truncate(size int64) error {
+	f, release, err := cf.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return f.Truncate(size)
+}
+
+// Close closes the file's handle and removes it from the cache
+func (cf *cachedFile) Close() error {
+	hc := cf.cache
+	hc.mu.Lock()
+	if cf.closed {
+		hc.mu.Unlock()
+		return nil
+	}
+	cf.closed = true
+	f := cf.f
+	if f != nil {
+		hc.lru.Remove(cf.elem)
+		hc.open--
+		cf.f, cf.elem = nil, nil
+	}
+	hc.mu.Unlock()
+
+	if f == nil {
+		return nil
+	}
+	return f.Close()
+}
+
+func newHandleCache(max int, log *zap.Logger) *handleCache {
+	return &handleCache{
+		max: max,
+		log: log,
+		lru: list.New(),
+	}
+}
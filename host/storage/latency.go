@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultLatencyWindow is the rolling window used to track write latency
+	// when no SLO is configured.
+	defaultLatencyWindow = 10 * time.Minute
+	// maxLatencySamples is the maximum number of write latencies tracked
+	// per volume. The oldest samples are dropped first.
+	maxLatencySamples = 1000
+	// minLatencySamples is the minimum number of writes within the window
+	// required before the SLO is evaluated.
+	minLatencySamples = 10
+)
+
+type (
+	// A LatencySLO is a service level objective for the write latency of
+	// each volume. The objective is violated when the given percentile of
+	// writes within the rolling window take longer than the threshold.
+	LatencySLO struct {
+		// Percentile is the percentile of writes, between 0 and 1, that
+		// must complete within Threshold.
+		Percentile float64
+		Threshold  time.Duration
+		// Window is the rolling window writes are evaluated over.
+		Window time.Duration
+	}
+
+	latencySample struct {
+		timestamp time.Time
+		elapsed   time.Duration
+	}
+
+	// latencyWindow tracks a volume's recent write latencies.
+	latencyWindow struct {
+		mu      sync.Mutex
+		samples []latencySample // oldest first
+		// severity is the severity of the active SLO alert. Zero if the SLO
+		// is not violated.
+		severity alerts.Severity
+	}
+)
+
+// add records a write latency and drops any samples outside of the window.
+func (lw *latencyWindow) add(timestamp time.Time, elapsed, window time.Duration) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lw.samples = append(lw.samples, latencySample{timestamp, elapsed})
+	cutoff := timestamp.Add(-window)
+	var i int
+	for i < len(lw.samples) && (lw.samples[i].timestamp.Before(cutoff) || len(lw.samples)-i > maxLatencySamples) {
+		i++
+	}
+	lw.samples = lw.samples[i:]
+}
+
+// percentile returns the latency of the given percentile of the samples
+// recorded since the start of the window and the number of samples.
+func (lw *latencyWindow) percentile(p float64, since time.Time) (time.Duration, int) {
+	lw.mu.Lock()
+	latencies := make([]time.Duration, 0, len(lw.samples))
+	for _, s := range lw.samples {
+		if !s.timestamp.Before(since) {
+			latencies = append(latencies, s.elapsed)
+		}
+	}
+	lw.mu.Unlock()
+
+	if len(latencies) == 0 {
+		return 0, 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	i := int(p*float64(len(latencies))+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(latencies) {
+		i = len(latencies) - 1
+	}
+	return latencies[i], len(latencies)
+}
+
+// setSeverity sets the severity of the SLO alert and returns true if it
+// changed.
+func (lw *latencyWindow) setSeverity(severity alerts.Severity) bool {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	changed := lw.severity != severity
+	lw.severity = severity
+	return changed
+}
+
+// latencyWindowDuration returns the rolling window used to track write
+// latency.
+func (vm *VolumeManager) latencyWindowDuration() time.Duration {
+	if vm.writeSLO.Window > 0 {
+		return vm.writeSLO.Window
+	}
+	return defaultLatencyWindow
+}
+
+// recordWriteLatency records the latency of a successful write and evaluates
+// the volume's write latency SLO. An alert is registered when the SLO is
+// violated and escalated to an error if the latency is more than twice the
+// threshold. The alert is dismissed when the latency recovers.
+func (vm *VolumeManager) recordWriteLatency(volumeID int64, vol *volume, elapsed time.Duration) {
+	now := time.Now()
+	window := vm.latencyWindowDuration()
+	vol.writeLatency.add(now, elapsed, window)
+
+	slo := vm.writeSLO
+	if slo.Threshold <= 0 || slo.Percentile <= 0 {
+		return
+	}
+
+	latency, samples := vol.writeLatency.percentile(slo.Percentile, now.Add(-window))
+	if samples < minLatencySamples {
+		return
+	}
+
+	var severity alerts.Severity
+	switch {
+	case latency > 2*slo.Threshold:
+		severity = alerts.SeverityError
+	case latency > slo.Threshold:
+		severity = alerts.SeverityWarning
+	}
+	if !vol.writeLatency.setSeverity(severity) {
+		return
+	}
+
+	alertID := vol.alertID("writeLatency")
+	log := vm.log.Named("writeLatency").With(zap.Int64("volume", volumeID), zap.Duration("latency", latency), zap.Duration("threshold", slo.Threshold), zap.Float64("percentile", slo.Percentile))
+	if severity == 0 {
+		log.Info("volume write latency recovered")
+		vm.a.Dismiss(alertID)
+		return
+	}
+
+	log.Warn("volume write latency SLO violated", zap.Stringer("severity", severity))
+	vm.a.Register(alerts.Alert{
+		ID:       alertID,
+		Severity: severity,
+		Message:  "Volume write latency exceeds SLO",
+		Data: map[string]any{
+			"volume":     vol.Location(),
+			"volumeID":   volumeID,
+			"percentile": slo.Percentile,
+			"latency":    latency.String(),
+			"threshold":  slo.Threshold.String(),
+			"window":     window.String(),
+			"samples":    samples,
+		},
+		Timestamp: now,
+	})
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+)
+
+// migrationThroughputWindow is the rolling window used to calculate the
+// migration throughput.
+const migrationThroughputWindow = time.Minute
+
+type (
+	// MigrationStats reports the sector migrations running across all
+	// volumes. The stats are not persisted and are reset when the host
+	// restarts.
+	MigrationStats struct {
+		// Limit is the maximum number of sectors migrated at the same time.
+		// Zero means migrations are not limited.
+		Limit int `json:"limit"`
+		// Active is the number of sectors currently being migrated.
+		Active int `json:"active"`
+		// Queued is the number of sectors waiting for a migration slot.
+		Queued int `json:"queued"`
+		// Migrated is the number of sectors migrated since startup.
+		Migrated uint64 `json:"migrated"`
+		// Failed is the number of sectors that failed to migrate since
+		// startup.
+		Failed uint64 `json:"failed"`
+		// Throughput is the average number of bytes migrated per second over
+		// the last minute.
+		Throughput uint64 `json:"throughput"`
+	}
+
+	// migrationLimiter bounds the number of sectors migrated at the same
+	// time by all operations, e.g. shrinking and removing volumes.
+	migrationLimiter struct {
+		sem chan struct{} // nil if migrations are not limited
+
+		mu       sync.Mutex
+		active   int
+		queued   int
+		migrated uint64
+		failed   uint64
+		// completed holds the completion times of the migrations within the
+		// throughput window, oldest first
+		completed []time.Time
+	}
+)
+
+func newMigrationLimiter(limit int) *migrationLimiter {
+	ml := new(migrationLimiter)
+	if limit > 0 {
+		ml.sem = make(chan struct{}, limit)
+	}
+	return ml
+}
+
+// acquire waits for a migration slot. The returned function must be called
+// with the result of the migration to release the slot.
+func (ml *migrationLimiter) acquire(ctx context.Context) (func(error), error) {
+	if ml.sem != nil {
+		ml.mu.Lock()
+		ml.queued++
+		ml.mu.Unlock()
+
+		select {
+		case ml.sem <- struct{}{}:
+		case <-ctx.Done():
+			ml.mu.Lock()
+			ml.queued--
+			ml.mu.Unlock()
+			return nil, ctx.Err()
+		}
+
+		ml.mu.Lock()
+		ml.queued--
+		ml.mu.Unlock()
+	}
+
+	ml.mu.Lock()
+	ml.active++
+	ml.mu.Unlock()
+
+	return func(err error) {
+		ml.mu.Lock()
+		ml.active--
+		if err != nil {
+			ml.failed++
+		} else {
+			ml.migrated++
+			ml.completed = append(ml.completed, time.Now())
+			ml.prune(time.Now())
+		}
+		ml.mu.Unlock()
+
+		if ml.sem != nil {
+			<-ml.sem
+		}
+	}, nil
+}
+
+// prune removes completion times outside of the throughput window. The lock
+// must be held.
+func (ml *migrationLimiter) prune(now time.Time) {
+	cutoff := now.Add(-migrationThroughputWindow)
+	var i int
+	for i < len(ml.completed) && ml.completed[i].Before(cutoff) {
+		i++
+	}
+	ml.completed = ml.completed[i:]
+}
+
+// stats returns the current migration stats.
+func (ml *migrationLimiter) stats() MigrationStats {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	ml.prune(time.Now())
+	return MigrationStats{
+		Limit:      cap(ml.sem),
+		Active:     ml.active,
+		Queued:     ml.queued,
+		Migrated:   ml.migrated,
+		Failed:     ml.failed,
+		Throughput: uint64(len(ml.completed)) * rhp2.SectorSize / uint64(migrationThroughputWindow/time.Second),
+	}
+}
+
+// MigrationStats returns the state of the sector migrations running across
+// all volumes.
+func (vm *VolumeManager) MigrationStats() MigrationStats {
+	return vm.migrations.stats()
+}
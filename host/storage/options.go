@@ -0,0 +1,172 @@
+package storage
+
+import "time"
+
+// An Option is a functional option that can be used to configure a volume
+// manager.
+type Option func(*VolumeManager)
+
+// WithMaxOpenVolumes limits the number of volume files that are open at the
+// same time. Idle volume files are closed, least recently used first, and
+// reopened when they are next accessed. Files that are in use by an operation
+// are never closed, so the limit may be briefly exceeded under load. The
+// default of 0 keeps every volume open.
+func WithMaxOpenVolumes(n int) Option {
+	return func(vm *VolumeManager) {
+		vm.maxOpenVolumes = n
+	}
+}
+
+// WithSectorChecksums enables storing a CRC32C checksum of each sector when
+// it is written. Sectors read from disk are checked against their checksum and
+// the Merkle root is only recomputed on a mismatch, which makes integrity
+// scans much cheaper.
+func WithSectorChecksums(enabled bool) Option {
+	return func(vm *VolumeManager) {
+		vm.checksums = enabled
+	}
+}
+
+// WithBackendProvider sets the provider used to create, open, and remove
+// volume data. The default provider stores each volume in a local file.
+func WithBackendProvider(p BackendProvider) Option {
+	return func(vm *VolumeManager) {
+		vm.backends = p
+	}
+}
+
+// WithPrefetchDepth sets the number of sectors that are read into the cache
+// ahead of a sequential download. Prefetching is disabled for sessions that
+// read randomly. The default of 0 disables prefetching.
+func WithPrefetchDepth(n int) Option {
+	return func(vm *VolumeManager) {
+		vm.prefetchDepth = n
+	}
+}
+
+// WithReadAhead sets the number of contiguous sectors read from a volume with
+// a single call when sectors are read from it sequentially. The following
+// sectors are served from a per-volume buffer, which reduces seeks on
+// spinning disks. Each volume's buffer uses n sectors of memory once a
+// sequential read is detected. Values less than 2 disable read-ahead.
+func WithReadAhead(n int) Option {
+	return func(vm *VolumeManager) {
+		vm.readAhead = n
+	}
+}
+
+// WithEncryptionPassphrase enables encryption at rest for new volumes. Each
+// volume's key is derived from the passphrase and a random per-volume salt;
+// neither the passphrase nor the keys are stored. Existing encrypted volumes
+// can only be opened with the passphrase they were created with. Volumes
+// added without a passphrase are not encrypted.
+func WithEncryptionPassphrase(passphrase string) Option {
+	return func(vm *VolumeManager) {
+		vm.passphrase = []byte(passphrase)
+	}
+}
+
+// WithWriteLatencySLO sets the write latency objective for each volume. An
+// alert is registered when the objective is violated over the rolling window
+// and dismissed when the latency recovers. A zero threshold disables the
+// objective.
+func WithWriteLatencySLO(slo LatencySLO) Option {
+	return func(vm *VolumeManager) {
+		vm.writeSLO = slo
+	}
+}
+
+// WithMaxVolumes limits the number of volumes that can be added to the host.
+// Existing volumes over the limit are still loaded, but no new volumes can be
+// added. Values less than 1 use DefaultMaxVolumes.
+func WithMaxVolumes(n int) Option {
+	return func(vm *VolumeManager) {
+		if n > 0 {
+			vm.maxVolumes = n
+		}
+	}
+}
+
+// WithSelfAudit enables the periodic self-audit. Each audit reads a random
+// sample of stored sectors from disk and alerts if any fail to read or are
+// slower than the latency threshold.
+func WithSelfAudit(cfg SelfAuditConfig) Option {
+	return func(vm *VolumeManager) {
+		vm.selfAudit = cfg
+	}
+}
+
+// WithAutoGrow enables growing volumes automatically when their free space
+// drops below a threshold. Volumes are only grown if the underlying storage
+// has room for the increment. By default volumes are never grown
+// automatically.
+func WithAutoGrow(cfg AutoGrowConfig) Option {
+	return func(vm *VolumeManager) {
+		vm.autoGrow = cfg
+	}
+}
+
+// WithMaxConcurrentMigrations limits the number of sectors migrated at the
+// same time by all operations that move data between volumes, such as
+// shrinking and removing volumes. The limit is shared so the total migration
+// I/O is bounded regardless of how many operations are running. The default
+// of 0 does not limit migrations.
+func WithMaxConcurrentMigrations(n int) Option {
+	return func(vm *VolumeManager) {
+		vm.maxMigrations = n
+	}
+}
+
+// WithExpiredSectors configures how sectors referenced only by expired
+// contracts that have not been pruned yet are handled. By default they are
+// migrated like any other sector when a volume is removed or shrunk.
+func WithExpiredSectors(cfg ExpiredSectorConfig) Option {
+	return func(vm *VolumeManager) {
+		vm.expired = cfg
+	}
+}
+
+// WithWriteTimeout sets the maximum time a sector write to a volume may take.
+// Writes that exceed the timeout are abandoned and return a *TimeoutError.
+// The volume is marked as degraded and does not receive new sectors until
+// the abandoned write completes. The default of 0 disables the timeout.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(vm *VolumeManager) {
+		vm.writeTimeout = d
+	}
+}
+
+// WithRelink enables searching for the files of volumes that are missing
+// from their recorded path when the host starts. By default missing volumes
+// are marked as unavailable.
+func WithRelink(cfg RelinkConfig) Option {
+	return func(vm *VolumeManager) {
+		vm.relink = cfg
+	}
+}
+
+// WithPacking enables packing the payloads of small sectors into shared
+// sectors with WritePacked. By default packing is disabled.
+func WithPacking(cfg PackingConfig) Option {
+	return func(vm *VolumeManager) {
+		vm.packing = cfg
+	}
+}
+
+// WithCacheMemory limits the memory used by the sector cache and enables
+// releasing cached sectors when the heap exceeds a high-water mark. By
+// default the cache is only limited by its sector count.
+func WithCacheMemory(cfg CacheMemoryConfig) Option {
+	return func(vm *VolumeManager) {
+		vm.cacheMemory = cfg
+	}
+}
+
+// WithReservationTTL sets how long a sector reservation may go unused before
+// it is released automatically. Using a reservation resets its TTL. A TTL of
+// 0 disables expiry. The default is DefaultReservationTTL.
+func WithReservationTTL(d time.Duration) Option {
+	return func(vm *VolumeManager) {
+		vm.reservationTTL = d
+	}
+}
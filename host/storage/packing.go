@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+)
+
+var (
+	// ErrPackingDisabled is returned when packed sectors are written but
+	// packing is not enabled.
+	ErrPackingDisabled = errors.New("sector packing is disabled")
+	// ErrPayloadTooLarge is returned when a sector's payload is larger than
+	// the maximum packed payload size.
+	ErrPayloadTooLarge = errors.New("sector payload is too large to pack")
+)
+
+type (
+	// A PackingConfig configures packing sub-sector payloads into shared
+	// sectors. A sector's payload is its data with trailing zeros removed,
+	// rounded up to a whole leaf. Packing small payloads together reduces
+	// the space and I/O used by sectors that are mostly empty.
+	PackingConfig struct {
+		// MaxPayloadSize is the largest payload, in bytes, that is packed
+		// into a shared sector. Zero disables packing.
+		MaxPayloadSize uint64
+	}
+
+	// A PackedSector is the location of a sector's payload within a shared
+	// physical sector. The sector is reconstructed by padding the payload
+	// with zeros.
+	PackedSector struct {
+		// Root is the Merkle root of the zero-padded sector.
+		Root types.Hash256 `json:"root"`
+		// PhysicalRoot is the Merkle root of the shared sector that holds
+		// the payload.
+		PhysicalRoot types.Hash256 `json:"physicalRoot"`
+		Offset       uint64        `json:"offset"`
+		Length       uint64        `json:"length"`
+	}
+
+	// packedBuffer is a shared sector being filled with payloads.
+	packedBuffer struct {
+		data    *[rhp2.SectorSize]byte
+		used    uint64
+		sectors []PackedSector
+	}
+)
+
+// sectorPayload returns the sector's data with trailing zeros removed,
+// rounded up to a whole leaf so payloads are leaf-aligned when packed.
+func sectorPayload(sector *[rhp2.SectorSize]byte) []byte {
+	n := uint64(len(sector))
+	for n > 0 && sector[n-1] == 0 {
+		n--
+	}
+	if rem := n % rhp2.LeafSize; rem != 0 {
+		n += rhp2.LeafSize - rem
+	}
+	return sector[:n]
+}
+
+// packSectors packs the payloads of the sectors into as few shared sectors
+// as possible. Payloads are placed in the first shared sector with enough
+// free space. The physical roots of the returned packed sectors are not set.
+func packSectors(roots []types.Hash256, payloads [][]byte) []*packedBuffer {
+	var buffers []*packedBuffer
+	for i, payload := range payloads {
+		length := uint64(len(payload))
+		var buf *packedBuffer
+		for _, b := range buffers {
+			if rhp2.SectorSize-b.used >= length {
+				buf = b
+				break
+			}
+		}
+		if buf == nil {
+			buf = &packedBuffer{data: new([rhp2.SectorSize]byte)}
+			buffers = append(buffers, buf)
+		}
+		copy(buf.data[buf.used:], payload)
+		buf.sectors = append(buf.sectors, PackedSector{
+			Root:   roots[i],
+			Offset: buf.used,
+			Length: length,
+		})
+		buf.used += length
+	}
+	return buffers
+}
+
+// unpackSector reconstructs a packed sector from its shared sector.
+func unpackSector(physical *[rhp2.SectorSize]byte, ps PackedSector) (*[rhp2.SectorSize]byte, error) {
+	if ps.Offset+ps.Length > rhp2.SectorSize || ps.Offset+ps.Length < ps.Offset {
+		return nil, fmt.Errorf("payload [%d, %d) is outside of the sector", ps.Offset, ps.Offset+ps.Length)
+	}
+	sector := new([rhp2.SectorSize]byte)
+	copy(sector[:], physical[ps.Offset:ps.Offset+ps.Length])
+	return sector, nil
+}
+
+// WritePacked packs the payloads of small sectors into shared sectors and
+// writes the shared sectors to disk. Each packed sector holds a reference
+// to its shared sector, which is not removed until every packed sector is
+// removed with RemovePacked. Writing a sector that is already packed adds
+// another reference to it. ErrPayloadTooLarge is returned if any sector's
+// payload is larger than the configured maximum; those sectors should be
+// written with Write.
+func (vm *VolumeManager) WritePacked(sectors []*[rhp2.SectorSize]byte) ([]PackedSector, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if vm.packing.MaxPayloadSize == 0 {
+		return nil, ErrPackingDisabled
+	}
+
+	packed := make([]PackedSector, len(sectors))
+	var roots []types.Hash256
+	var payloads [][]byte
+	indices := make(map[types.Hash256][]int)
+	for i, sector := range sectors {
+		root := rhp2.SectorRoot(sector)
+		payload := sectorPayload(sector)
+		if uint64(len(payload)) > vm.packing.MaxPayloadSize {
+			return nil, &SectorError{Root: root, Err: fmt.Errorf("%w: %d bytes, max %d", ErrPayloadTooLarge, len(payload), vm.packing.MaxPayloadSize)}
+		}
+
+		// sectors that are already packed only need another reference
+		if existing, err := vm.vs.PackedSector(root); err == nil {
+			if err := vm.vs.AddPackedSectorReference(root); err != nil {
+				return nil, &SectorError{Root: root, Err: fmt.Errorf("failed to add packed sector reference: %w", err)}
+			}
+			packed[i] = existing
+			continue
+		} else if !errors.Is(err, ErrSectorNotFound) {
+			return nil, &SectorError{Root: root, Err: fmt.Errorf("failed to get packed sector: %w", err)}
+		}
+
+		if _, ok := indices[root]; !ok {
+			roots = append(roots, root)
+			payloads = append(payloads, payload)
+		}
+		indices[root] = append(indices[root], i)
+	}
+
+	for _, buf := range packSectors(roots, payloads) {
+		physicalRoot := rhp2.SectorRoot(buf.data)
+		for i := range buf.sectors {
+			buf.sectors[i].PhysicalRoot = physicalRoot
+		}
+
+		release, err := vm.Write(physicalRoot, buf.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write shared sector: %w", err)
+		}
+		// the packed sectors must be stored before the shared sector is
+		// released so it is not pruned
+		err = vm.vs.StorePackedSectors(physicalRoot, buf.sectors)
+		if releaseErr := release(); releaseErr != nil && err == nil {
+			err = fmt.Errorf("failed to release shared sector: %w", releaseErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to store packed sectors: %w", err)
+		}
+
+		for _, ps := range buf.sectors {
+			// duplicate sectors in the same call share one payload, but each
+			// holds a reference
+			for j, index := range indices[ps.Root] {
+				packed[index] = ps
+				if j == 0 {
+					continue
+				} else if err := vm.vs.AddPackedSectorReference(ps.Root); err != nil {
+					return nil, &SectorError{Root: ps.Root, Err: fmt.Errorf("failed to add packed sector reference: %w", err)}
+				}
+			}
+		}
+	}
+	return packed, nil
+}
+
+// ReadPacked reads a packed sector from its shared sector.
+func (vm *VolumeManager) ReadPacked(root types.Hash256) (*[rhp2.SectorSize]byte, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	ps, err := vm.vs.PackedSector(root)
+	if err != nil {
+		return nil, &SectorError{Root: root, Err: fmt.Errorf("failed to get packed sector: %w", err)}
+	}
+	physical, err := vm.Read(ps.PhysicalRoot)
+	if err != nil {
+		return nil, &SectorError{Root: root, Err: fmt.Errorf("failed to read shared sector %v: %w", ps.PhysicalRoot, err)}
+	}
+	sector, err := unpackSector(physical, ps)
+	if err != nil {
+		return nil, &SectorError{Root: root, Err: err}
+	} else if rhp2.SectorRoot(sector) != root {
+		return nil, &SectorError{Root: root, Err: ErrSectorCorrupt}
+	}
+	return sector, nil
+}
+
+// RemovePacked removes a reference to a packed sector. The sector's payload
+// is removed when its last reference is removed, and its shared sector is
+// removed when it no longer holds any payloads.
+func (vm *VolumeManager) RemovePacked(root types.Hash256) error {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := vm.vs.RemovePackedSectorReference(root); err != nil {
+		return &SectorError{Root: root, Err: fmt.Errorf("failed to remove packed sector reference: %w", err)}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 
 	"go.sia.tech/core/types"
 )
@@ -41,6 +42,14 @@ type (
 		SetReadOnly(volumeID int64, readOnly bool) error
 		// SetAvailable sets the available flag on a volume.
 		SetAvailable(volumeID int64, available bool) error
+		// SetVolumeLocation sets the location of a volume's data.
+		SetVolumeLocation(volumeID int64, localPath string) error
+		// SetVolumeEncryption marks a volume as encrypted and stores the
+		// metadata needed to derive its key.
+		SetVolumeEncryption(volumeID int64, enc VolumeEncryption) error
+		// VolumeEncryption returns the encryption metadata of a volume. If
+		// the volume is not encrypted, ErrVolumeNotEncrypted is returned.
+		VolumeEncryption(volumeID int64) (VolumeEncryption, error)
 
 		// MigrateSectors returns a new location for each occupied sector of a
 		// volume starting at min. The sector data should be copied to the new
@@ -53,10 +62,14 @@ type (
 		// be written to disk within fn. If fn returns an error, the metadata is
 		// rolled back. If no space is available, ErrNotEnoughStorage is
 		// returned. The location is locked until release is called.
+		// Concurrent calls must never be given the same location.
 		//
 		// The sector should be referenced by either a contract or temp store
 		// before release is called to prevent Prune() from removing it.
 		StoreSector(root types.Hash256, fn func(loc SectorLocation, exists bool) error) (release func() error, err error)
+		// StoreSectorExcluding is the same as StoreSector, but new sectors are
+		// never stored in one of the excluded volumes.
+		StoreSectorExcluding(root types.Hash256, exclude []int64, fn func(loc SectorLocation, exists bool) error) (release func() error, err error)
 		// RemoveSector removes the metadata of a sector and returns its
 		// location in the volume.
 		RemoveSector(root types.Hash256) error
@@ -73,8 +86,98 @@ type (
 		ExpireTempSectors(height uint64) error
 		// IncrementSectorStats increments sector stats
 		IncrementSectorStats(reads, writes, cacheHit, cacheMiss uint64) error
+		// IncrementPrefetchStats increments the number of sectors prefetched
+		// and the number of prefetched sectors that were read
+		IncrementPrefetchStats(prefetched, hits uint64) error
+		// IncrementReadAheadStats increments the number of sectors read
+		// ahead and the number of reads served from the read-ahead buffer
+		IncrementReadAheadStats(readAhead, hits uint64) error
 		// SectorReferences returns the references to a sector
 		SectorReferences(types.Hash256) (SectorReference, error)
+
+		// StorePackedSectors records the payloads packed into a shared
+		// sector. Each packed sector starts with one reference. If a sector
+		// is already packed, a reference is added and its existing location
+		// is kept. The shared sector must not be removed while it holds
+		// referenced payloads.
+		StorePackedSectors(physicalRoot types.Hash256, sectors []PackedSector) error
+		// PackedSector returns the location of a packed sector's payload.
+		// If the sector is not packed, ErrSectorNotFound is returned.
+		PackedSector(root types.Hash256) (PackedSector, error)
+		// AddPackedSectorReference adds a reference to a packed sector.
+		AddPackedSectorReference(root types.Hash256) error
+		// RemovePackedSectorReference removes a reference to a packed
+		// sector. The payload is removed with its last reference and the
+		// shared sector is removed when it no longer holds any payloads and
+		// is not otherwise referenced.
+		RemovePackedSectorReference(root types.Hash256) error
+
+		// VolumeSectors returns up to limit sectors stored in the volume,
+		// ordered by their index within the volume.
+		VolumeSectors(volumeID int64, limit, offset int) ([]VolumeSector, error)
+		// VolumeSectorsFrom returns up to limit sectors stored in the volume
+		// at or after the start index, ordered by their index within the
+		// volume.
+		VolumeSectorsFrom(volumeID int64, start uint64, limit int) ([]VolumeSector, error)
+		// VolumeSectorCounts counts the sector slots and used sectors of a
+		// volume from its sector metadata.
+		VolumeSectorCounts(volumeID int64) (total, used uint64, err error)
+
+		// SetReplicaGroup sets the replica group of a volume. An empty group
+		// disables replication.
+		SetReplicaGroup(volumeID int64, group string) error
+		// StoreSectorReplica calls fn with an empty location in another
+		// volume of the same replica group as the sector's primary location.
+		// fn is not called if the sector is already replicated or its volume
+		// is not in a replica group.
+		StoreSectorReplica(root types.Hash256, fn func(loc SectorLocation) error) error
+		// SectorReplica returns the location of a sector's replica. If the
+		// sector does not have a replica, ErrSectorNotFound is returned.
+		SectorReplica(root types.Hash256) (SectorLocation, error)
+
+		// ReclaimableSectors returns the number of sectors in a volume that
+		// are only referenced by contracts that expired before height.
+		ReclaimableSectors(volumeID int64, height uint64) (uint64, error)
+		// ReclaimExpiredSectors removes the references of contracts that
+		// expired before height to sectors stored in the volume at or after
+		// minIndex. Sectors that are no longer referenced are removed and
+		// their roots returned.
+		ReclaimExpiredSectors(volumeID int64, minIndex, height uint64) ([]types.Hash256, error)
+
+		// ZeroPendingLocations returns up to limit locations, ordered by ID
+		// and starting after the given ID, that were freed and must be
+		// zeroed before they can be reused.
+		ZeroPendingLocations(afterID int64, limit int) ([]SectorLocation, error)
+		// ClearZeroPending marks the locations as zeroed so they can be
+		// reused.
+		ClearZeroPending(ids []int64) error
+
+		// SetSectorChecksum sets the checksum of a stored sector's data.
+		SetSectorChecksum(root types.Hash256, checksum uint32) error
+		// SectorChecksum returns the checksum of a stored sector's data. If
+		// no checksum has been stored, ok is false.
+		SectorChecksum(root types.Hash256) (checksum uint32, ok bool, err error)
+
+		// RecordSectorAccess adds the number of times each sector was read
+		// to the access counts for the period containing timestamp.
+		RecordSectorAccess(reads map[types.Hash256]uint64, timestamp time.Time) error
+		// PruneSectorAccess removes access counts for periods that started
+		// before the given time.
+		PruneSectorAccess(before time.Time) error
+		// HotSectors returns up to limit sectors with the most reads since
+		// the given time.
+		HotSectors(since time.Time, limit int) ([]SectorAccess, error)
+
+		// IncrementVolumeIO adds the number of bytes read from and written
+		// to each volume to the volume's cumulative counters and to the
+		// counts for the minute containing timestamp.
+		IncrementVolumeIO(io map[int64]VolumeIO, timestamp time.Time) error
+		// PruneVolumeIO removes per-minute volume I/O counts for periods
+		// that started before the given time.
+		PruneVolumeIO(before time.Time) error
+		// VolumeIO returns the number of bytes read from and written to a
+		// volume since the given time.
+		VolumeIO(volumeID int64, since time.Time) (VolumeIO, error)
 	}
 )
 
@@ -87,6 +190,9 @@ var (
 	ErrNotEnoughStorage = errors.New("not enough storage")
 	// ErrSectorNotFound is returned when a sector is not found.
 	ErrSectorNotFound = errors.New("sector not found")
+	// ErrSectorCorrupt is returned when a sector's data does not match its
+	// root.
+	ErrSectorCorrupt = errors.New("sector corrupt")
 	// ErrVolumeNotEmpty is returned when trying to remove or shrink a volume
 	// that has not been emptied.
 	ErrVolumeNotEmpty = errors.New("volume is not empty")
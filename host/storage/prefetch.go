@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// randomReadThreshold is the minimum number of non-sequential reads before a
+// session is considered to be reading randomly.
+const randomReadThreshold = 4
+
+type (
+	// contractReads tracks the position of a session's reads within a
+	// contract.
+	contractReads struct {
+		last uint64
+		// ahead is the index after the last sector queued for prefetching
+		ahead uint64
+	}
+
+	// A Prefetcher detects sequential reads of a contract's sectors within a
+	// single renter session and reads the following sectors into the cache
+	// before they are requested. A Prefetcher should not be shared between
+	// sessions.
+	Prefetcher struct {
+		vm *VolumeManager
+
+		mu         sync.Mutex
+		sequential int
+		random     int
+		contracts  map[types.FileContractID]*contractReads
+	}
+)
+
+// Random returns true if the session's reads are mostly non-sequential.
+// Random sessions are not prefetched.
+func (p *Prefetcher) Random() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.isRandom()
+}
+
+func (p *Prefetcher) isRandom() bool {
+	return p.random >= randomReadThreshold && p.random > p.sequential
+}
+
+// Enabled returns true if reads observed by the prefetcher may trigger
+// prefetching.
+func (p *Prefetcher) Enabled() bool {
+	return p.vm.prefetchDepth > 0 && !p.Random()
+}
+
+// Observe records a read of the sector at index in a contract. If the read
+// continues a sequential download, the roots of the following sectors are
+// looked up with root and read into the cache in the background. root should
+// return an error for indices past the end of the contract.
+func (p *Prefetcher) Observe(id types.FileContractID, index uint64, root func(uint64) (types.Hash256, error)) {
+	depth := uint64(p.vm.prefetchDepth)
+	if depth == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	reads, ok := p.contracts[id]
+	if !ok {
+		// the first read of a contract does not indicate a pattern
+		p.contracts[id] = &contractReads{last: index}
+		p.mu.Unlock()
+		return
+	} else if index == reads.last {
+		// multiple reads of the same sector, e.g. a partial read split
+		// across instructions, do not change the pattern
+		p.mu.Unlock()
+		return
+	}
+
+	sequential := index == reads.last+1
+	reads.last = index
+	if !sequential {
+		p.random++
+		reads.ahead = 0
+		p.mu.Unlock()
+		return
+	}
+	p.sequential++
+	if p.isRandom() {
+		p.mu.Unlock()
+		return
+	}
+
+	start, end := index+1, index+1+depth
+	if reads.ahead > start {
+		start = reads.ahead
+	}
+	if start >= end {
+		p.mu.Unlock()
+		return
+	}
+	reads.ahead = end
+	p.mu.Unlock()
+
+	roots := make([]types.Hash256, 0, end-start)
+	for i := start; i < end; i++ {
+		r, err := root(i)
+		if err != nil {
+			break
+		}
+		roots = append(roots, r)
+	}
+	p.vm.prefetch(roots)
+}
+
+// evictPrefetched is called when a sector is evicted from the cache.
+func (vm *VolumeManager) evictPrefetched(root types.Hash256, _ *[rhp2.SectorSize]byte) {
+	vm.prefetchMu.Lock()
+	defer vm.prefetchMu.Unlock()
+	delete(vm.prefetched, root)
+}
+
+// consumePrefetched returns true if the sector was prefetched and has not been
+// read since.
+func (vm *VolumeManager) consumePrefetched(root types.Hash256) bool {
+	vm.prefetchMu.Lock()
+	defer vm.prefetchMu.Unlock()
+	if !vm.prefetched[root] {
+		return false
+	}
+	delete(vm.prefetched, root)
+	return true
+}
+
+// prefetch reads the sectors that are not already cached into the cache in
+// the background.
+func (vm *VolumeManager) prefetch(roots []types.Hash256) {
+	if len(roots) == 0 {
+		return
+	}
+
+	done, err := vm.tg.Add()
+	if err != nil {
+		return
+	}
+
+	go func() {
+		defer done()
+
+		for _, root := range roots {
+			if vm.cache.Contains(root) {
+				continue
+			}
+
+			sector, err := vm.readSector(root)
+			if err != nil {
+				vm.log.Debug("failed to prefetch sector", zap.Stringer("root", root), zap.Error(err))
+				return
+			}
+			vm.prefetchMu.Lock()
+			vm.prefetched[root] = true
+			vm.prefetchMu.Unlock()
+			vm.cache.Add(root, sector)
+			vm.recorder.AddPrefetch()
+			atomic.AddUint64(&vm.prefetches, 1)
+		}
+	}()
+}
+
+// PrefetchStats returns the number of sectors prefetched and the number of
+// prefetched sectors that were later read from the cache.
+func (vm *VolumeManager) PrefetchStats() (prefetched, hits uint64) {
+	return atomic.LoadUint64(&vm.prefetches), atomic.LoadUint64(&vm.prefetchHits)
+}
+
+// Prefetcher returns a new Prefetcher for a renter session.
+func (vm *VolumeManager) Prefetcher() *Prefetcher {
+	return &Prefetcher{
+		vm:        vm,
+		contracts: make(map[types.FileContractID]*contractReads),
+	}
+}
@@ -0,0 +1,24 @@
+package storage
+
+import "context"
+
+type progressKey struct{}
+
+// A ProgressFunc is called with the progress of a long-running volume
+// operation.
+type ProgressFunc func(progress, total uint64)
+
+// WithProgress returns a copy of ctx that reports the progress of volume
+// operations started with it to fn. Adding a volume reports the sectors
+// initialized, resizing reports the sectors migrated and the sectors added or
+// removed, and removing reports the sectors migrated off the volume.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress calls the context's progress func, if any.
+func reportProgress(ctx context.Context, progress, total uint64) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(progress, total)
+	}
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+)
+
+type (
+	// A MultiSectorBackend is a VolumeBackend that can read several
+	// contiguous sectors with a single call. Volumes with a backend that
+	// implements it read ahead of sequential reads.
+	MultiSectorBackend interface {
+		VolumeBackend
+		// ReadSectors reads contiguous sectors starting at index into buf,
+		// which must be a multiple of the sector size. It returns the number
+		// of whole sectors read. Reading past the end of the backend is not
+		// an error.
+		ReadSectors(buf []byte, index uint64) (int, error)
+	}
+
+	// readAheadBuffer holds contiguous sectors read ahead of a sequential
+	// read. The buffered data is stored as it is on disk and decrypted when
+	// it is served.
+	readAheadBuffer struct {
+		mu sync.Mutex
+		// last is the index of the most recent sector read from the volume
+		last    uint64
+		hasLast bool
+
+		start uint64 // index of the first buffered sector
+		n     int    // number of buffered sectors
+		buf   []byte
+	}
+)
+
+// take copies the buffered sector at index into sector. false is returned if
+// the sector is not buffered.
+func (rb *readAheadBuffer) take(sector *[rhp2.SectorSize]byte, index uint64) bool {
+	if rb.n == 0 || index < rb.start || index >= rb.start+uint64(rb.n) {
+		return false
+	}
+	offset := (index - rb.start) * rhp2.SectorSize
+	copy(sector[:], rb.buf[offset:offset+rhp2.SectorSize])
+	return true
+}
+
+// invalidate discards the buffer if it contains the sector at index.
+func (rb *readAheadBuffer) invalidate(index uint64) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.n > 0 && index >= rb.start && index < rb.start+uint64(rb.n) {
+		rb.n = 0
+	}
+}
+
+// reset discards the buffer and the read history.
+func (rb *readAheadBuffer) reset() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.n = 0
+	rb.hasLast = false
+}
+
+// ReadSectorAhead reads the sector at index. If the previous read from the
+// volume was of the sector before index, up to depth contiguous sectors are
+// read from the backend with a single call and the following sectors are
+// served from a buffer until the pattern is broken. buffered is the number of
+// additional sectors read into the buffer and hit is true if the sector was
+// served from the buffer. Reads from concurrent downloads on the same volume
+// are interleaved and will usually not be detected as sequential.
+func (v *volume) ReadSectorAhead(index uint64, depth int) (sector *[rhp2.SectorSize]byte, buffered int, hit bool, err error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.data == nil {
+		return nil, 0, false, ErrVolumeNotAvailable
+	}
+	mb, ok := v.data.(MultiSectorBackend)
+	if !ok || depth <= 1 {
+		sector, err := v.readSector(index)
+		return sector, 0, false, err
+	}
+
+	rb := &v.readAhead
+	rb.mu.Lock()
+	sequential := rb.hasLast && index == rb.last+1
+	rb.last, rb.hasLast = index, true
+
+	sector = new([rhp2.SectorSize]byte)
+	if rb.take(sector, index) {
+		rb.mu.Unlock()
+		hit = true
+	} else if !sequential {
+		rb.n = 0
+		rb.mu.Unlock()
+		sector, err := v.readSector(index)
+		return sector, 0, false, err
+	} else {
+		// the lock is held while the buffer is filled so that concurrent
+		// writes cannot invalidate the buffer before it is populated
+		if len(rb.buf) != depth*rhp2.SectorSize {
+			rb.buf = make([]byte, depth*rhp2.SectorSize)
+		}
+		n, rerr := mb.ReadSectors(rb.buf, index)
+		if rerr == nil && n == 0 {
+			rerr = fmt.Errorf("index %v is out of range", index)
+		}
+		if rerr != nil {
+			rb.n = 0
+			rb.mu.Unlock()
+			err = fmt.Errorf("failed to read sector at index %v: %w", index, rerr)
+			go v.incrementReadStats(err)
+			return nil, 0, false, err
+		}
+		rb.start, rb.n = index, n
+		rb.take(sector, index)
+		rb.mu.Unlock()
+		buffered = n - 1
+	}
+
+	if v.cipher != nil {
+		v.cipher.Decrypt(sector[:], sector[:], index)
+	}
+	go v.incrementReadStats(nil)
+	return sector, buffered, hit, nil
+}
+
+// ReadAheadStats returns the number of sectors read ahead of sequential
+// reads and the number of reads served from the read-ahead buffer.
+func (vm *VolumeManager) ReadAheadStats() (readAhead, hits uint64) {
+	return atomic.LoadUint64(&vm.readAheads), atomic.LoadUint64(&vm.readAheadHits)
+}
@@ -4,10 +4,20 @@ import (
 	"sync"
 	"time"
 
+	"go.sia.tech/core/types"
 	"go.uber.org/zap"
 )
 
-const flushInterval = time.Minute
+const (
+	flushInterval = time.Minute
+
+	// hotSectorWindow is the rolling window used to count sector reads
+	hotSectorWindow = 24 * time.Hour
+
+	// volumeThroughputRetention is how long per-minute volume I/O counts are
+	// kept. It is also the maximum window for throughput queries.
+	volumeThroughputRetention = 24 * time.Hour
+)
 
 type (
 	sectorAccessRecorder struct {
@@ -20,6 +30,15 @@ type (
 
 		cacheHit  uint64
 		cacheMiss uint64
+
+		prefetched  uint64
+		prefetchHit uint64
+
+		readAhead    uint64
+		readAheadHit uint64
+
+		sectorReads map[types.Hash256]uint64
+		volumeIO    map[int64]VolumeIO
 	}
 )
 
@@ -28,10 +47,48 @@ func (sr *sectorAccessRecorder) Flush() {
 	sr.mu.Lock()
 	r, w := sr.r, sr.w
 	cacheHit, cacheMiss := sr.cacheHit, sr.cacheMiss
+	prefetched, prefetchHit := sr.prefetched, sr.prefetchHit
+	readAhead, readAheadHit := sr.readAhead, sr.readAheadHit
+	sectorReads := sr.sectorReads
+	volumeIO := sr.volumeIO
 	sr.r, sr.w = 0, 0
 	sr.cacheHit, sr.cacheMiss = 0, 0
+	sr.prefetched, sr.prefetchHit = 0, 0
+	sr.readAhead, sr.readAheadHit = 0, 0
+	sr.sectorReads = nil
+	sr.volumeIO = nil
 	sr.mu.Unlock()
 
+	if len(sectorReads) > 0 {
+		now := time.Now()
+		if err := sr.store.RecordSectorAccess(sectorReads, now); err != nil {
+			sr.log.Error("failed to persist sector access counts", zap.Error(err))
+		} else if err := sr.store.PruneSectorAccess(now.Add(-hotSectorWindow)); err != nil {
+			sr.log.Error("failed to prune sector access counts", zap.Error(err))
+		}
+	}
+
+	if len(volumeIO) > 0 {
+		now := time.Now()
+		if err := sr.store.IncrementVolumeIO(volumeIO, now); err != nil {
+			sr.log.Error("failed to persist volume I/O", zap.Error(err))
+		} else if err := sr.store.PruneVolumeIO(now.Add(-volumeThroughputRetention)); err != nil {
+			sr.log.Error("failed to prune volume I/O", zap.Error(err))
+		}
+	}
+
+	if prefetched > 0 || prefetchHit > 0 {
+		if err := sr.store.IncrementPrefetchStats(prefetched, prefetchHit); err != nil {
+			sr.log.Error("failed to persist prefetch stats", zap.Error(err))
+		}
+	}
+
+	if readAhead > 0 || readAheadHit > 0 {
+		if err := sr.store.IncrementReadAheadStats(readAhead, readAheadHit); err != nil {
+			sr.log.Error("failed to persist read-ahead stats", zap.Error(err))
+		}
+	}
+
 	// no need to persist if there is no change
 	if r == 0 && w == 0 {
 		return
@@ -50,6 +107,29 @@ func (sr *sectorAccessRecorder) AddRead() {
 	sr.r++
 }
 
+// AddSectorRead increments the access count of a sector by 1.
+func (sr *sectorAccessRecorder) AddSectorRead(root types.Hash256) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if sr.sectorReads == nil {
+		sr.sectorReads = make(map[types.Hash256]uint64)
+	}
+	sr.sectorReads[root]++
+}
+
+// AddVolumeIO adds the number of bytes read from and written to a volume.
+func (sr *sectorAccessRecorder) AddVolumeIO(volumeID int64, read, written uint64) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if sr.volumeIO == nil {
+		sr.volumeIO = make(map[int64]VolumeIO)
+	}
+	io := sr.volumeIO[volumeID]
+	io.BytesRead += read
+	io.BytesWritten += written
+	sr.volumeIO[volumeID] = io
+}
+
 // AddWrite increments the number of sectors written by 1.
 func (sr *sectorAccessRecorder) AddWrite() {
 	sr.mu.Lock()
@@ -69,6 +149,36 @@ func (sr *sectorAccessRecorder) AddCacheMiss() {
 	sr.cacheMiss++
 }
 
+// AddPrefetch increments the number of sectors prefetched by 1.
+func (sr *sectorAccessRecorder) AddPrefetch() {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.prefetched++
+}
+
+// AddPrefetchHit increments the number of prefetched sectors that were read
+// by 1.
+func (sr *sectorAccessRecorder) AddPrefetchHit() {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.prefetchHit++
+}
+
+// AddReadAhead increments the number of sectors read ahead by n.
+func (sr *sectorAccessRecorder) AddReadAhead(n uint64) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.readAhead += n
+}
+
+// AddReadAheadHit increments the number of reads served from the read-ahead
+// buffer by 1.
+func (sr *sectorAccessRecorder) AddReadAheadHit() {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.readAheadHit++
+}
+
 // Run starts the recorder, flushing data at regular intervals.
 func (sr *sectorAccessRecorder) Run(stop <-chan struct{}) {
 	t := time.NewTicker(flushInterval)
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/xts"
+	"lukechampine.com/frand"
+)
+
+// defaultRelinkSampleSize is the number of stored sectors checked against a
+// candidate file if no sample size is configured.
+const defaultRelinkSampleSize = 16
+
+// A RelinkConfig configures how a volume whose file is missing from its
+// recorded path is located when the host starts. A file is only considered
+// a match if it is exactly the volume's size, is not the recorded path of
+// another volume, and every sampled sector read from it matches its Merkle
+// root. Volumes without stored sectors are never matched.
+type RelinkConfig struct {
+	// SearchDirs are the directories searched for missing volume files.
+	// Subdirectories are not searched. No directories disables the search.
+	SearchDirs []string
+	// Auto updates the volume's path if exactly one file matches. Otherwise
+	// matches are only reported with an alert and the operator must move
+	// the file back.
+	Auto bool
+	// SampleSize is the number of stored sectors read from each candidate.
+	// Defaults to 16.
+	SampleSize int
+}
+
+// relinkSamples returns up to n stored sectors spread evenly across the
+// volume.
+func (vm *VolumeManager) relinkSamples(vol Volume, n int) ([]VolumeSector, error) {
+	if vol.UsedSectors <= uint64(n) {
+		return vm.vs.VolumeSectors(vol.ID, int(vol.UsedSectors), 0)
+	}
+
+	samples := make([]VolumeSector, 0, n)
+	for i := 0; i < n; i++ {
+		offset := uint64(i) * vol.UsedSectors / uint64(n)
+		sectors, err := vm.vs.VolumeSectors(vol.ID, 1, int(offset))
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sectors...)
+	}
+	return samples, nil
+}
+
+// relinkCandidates returns the files in the search directories that are
+// exactly the volume's size and are not the recorded path of another volume.
+func (vm *VolumeManager) relinkCandidates(vol Volume, known map[string]bool) []string {
+	size := int64(vol.TotalSectors * rhp2.SectorSize)
+
+	var candidates []string
+	for _, dir := range vm.relink.SearchDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			vm.log.Warn("failed to read volume search directory", zap.String("dir", dir), zap.Error(err))
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			path, err := filepath.Abs(filepath.Join(dir, entry.Name()))
+			if err != nil || known[path] {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || info.Size() != size {
+				continue
+			}
+			candidates = append(candidates, path)
+		}
+	}
+	return candidates
+}
+
+// matchesVolume reports whether every sampled sector read from the file at
+// path matches its Merkle root.
+func matchesVolume(path string, c *xts.Cipher, samples []VolumeSector) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var buf [rhp2.SectorSize]byte
+	for _, sector := range samples {
+		if _, err := f.ReadAt(buf[:], int64(sector.Index*rhp2.SectorSize)); err != nil {
+			return false, fmt.Errorf("failed to read sector %v at index %d: %w", sector.Root, sector.Index, err)
+		} else if c != nil {
+			c.Decrypt(buf[:], buf[:], sector.Index)
+		}
+		if rhp2.SectorRoot(&buf) != sector.Root {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// relinkVolume searches for the file of a volume that is missing from its
+// recorded path. If exactly one file matches and automatic relinking is
+// enabled, the volume's path is updated and the new path is returned.
+// Otherwise an alert is registered for any matches and false is returned.
+// known contains the absolute paths of every volume.
+func (vm *VolumeManager) relinkVolume(vol Volume, c *xts.Cipher, known map[string]bool) (string, bool) {
+	log := vm.log.Named("relink").With(zap.Int64("volumeID", vol.ID), zap.String("path", vol.LocalPath))
+
+	n := vm.relink.SampleSize
+	if n <= 0 {
+		n = defaultRelinkSampleSize
+	}
+	samples, err := vm.relinkSamples(vol, n)
+	if err != nil {
+		log.Error("failed to sample volume sectors", zap.Error(err))
+		return "", false
+	} else if len(samples) == 0 {
+		// an empty volume cannot be told apart from any other file of the
+		// same size
+		log.Debug("volume has no stored sectors, skipping search")
+		return "", false
+	}
+
+	var matches []string
+	for _, path := range vm.relinkCandidates(vol, known) {
+		ok, err := matchesVolume(path, c, samples)
+		if err != nil {
+			log.Debug("failed to check candidate", zap.String("candidate", path), zap.Error(err))
+			continue
+		} else if ok {
+			matches = append(matches, path)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return "", false
+	case len(matches) > 1:
+		log.Warn("multiple files match missing volume", zap.Strings("matches", matches))
+		vm.a.Register(alerts.Alert{
+			ID:       frand.Entropy256(),
+			Severity: alerts.SeverityWarning,
+			Message:  "Multiple files match missing volume",
+			Data: map[string]any{
+				"volume":  vol.LocalPath,
+				"matches": matches,
+			},
+			Timestamp: time.Now(),
+		})
+		return "", false
+	case !vm.relink.Auto:
+		log.Warn("found moved volume file", zap.String("match", matches[0]))
+		vm.a.Register(alerts.Alert{
+			ID:       frand.Entropy256(),
+			Severity: alerts.SeverityWarning,
+			Message:  "Found moved volume file",
+			Data: map[string]any{
+				"volume": vol.LocalPath,
+				"match":  matches[0],
+			},
+			Timestamp: time.Now(),
+		})
+		return "", false
+	}
+
+	if err := vm.vs.SetVolumeLocation(vol.ID, matches[0]); err != nil {
+		log.Error("failed to update volume location", zap.String("match", matches[0]), zap.Error(err))
+		return "", false
+	}
+	log.Info("relinked moved volume file", zap.String("newPath", matches[0]), zap.Int("sampled", len(samples)))
+	vm.a.Register(alerts.Alert{
+		ID:       frand.Entropy256(),
+		Severity: alerts.SeverityInfo,
+		Message:  "Relinked moved volume file",
+		Data: map[string]any{
+			"volume":  vol.LocalPath,
+			"newPath": matches[0],
+		},
+		Timestamp: time.Now(),
+	})
+	return matches[0], true
+}
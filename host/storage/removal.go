@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"fmt"
+)
+
+type (
+	// A RemovalTarget is a volume that sectors would be migrated to if
+	// another volume were removed.
+	RemovalTarget struct {
+		VolumeID    int64  `json:"volumeID"`
+		LocalPath   string `json:"localPath"`
+		FreeSectors uint64 `json:"freeSectors"`
+	}
+
+	// A VolumeRemovalCheck describes whether a volume can be removed without
+	// losing data and, if not, what is preventing it.
+	VolumeRemovalCheck struct {
+		VolumeID  int64 `json:"volumeID"`
+		CanRemove bool  `json:"canRemove"`
+		// Status is the volume's current status. Only ready or unavailable
+		// volumes can be removed.
+		Status string `json:"status"`
+		// UsedSectors is the number of sectors that must be migrated before
+		// the volume can be removed.
+		UsedSectors uint64 `json:"usedSectors"`
+		// FreeSectors is the number of free sectors in the target volumes.
+		FreeSectors uint64 `json:"freeSectors"`
+		// ReclaimableSectors is the number of used sectors that are only
+		// referenced by expired contracts. If reclaiming on migration is
+		// enabled, they are removed instead of migrated.
+		ReclaimableSectors uint64 `json:"reclaimableSectors"`
+		// Unmigratable is the number of used sectors that cannot be migrated
+		// because the target volumes do not have enough free space. Either
+		// this many sectors must be freed or this much capacity added to
+		// other volumes.
+		Unmigratable uint64          `json:"unmigratable"`
+		Targets      []RemovalTarget `json:"targets"`
+		// Reason is a description of what is preventing the removal. It is
+		// empty if the volume can be removed.
+		Reason string `json:"reason,omitempty"`
+	}
+)
+
+// CanRemoveVolume checks whether the volume's sectors can be migrated to the
+// host's other volumes so it can be removed without losing data.
+func (vm *VolumeManager) CanRemoveVolume(id int64) (VolumeRemovalCheck, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return VolumeRemovalCheck{}, err
+	}
+	defer done()
+
+	vm.mu.Lock()
+	vol, ok := vm.volumes[id]
+	vm.mu.Unlock()
+	if !ok {
+		return VolumeRemovalCheck{}, &VolumeError{VolumeID: id, Op: VolumeOpRemove, Err: ErrVolumeNotFound}
+	}
+
+	volumes, err := vm.vs.Volumes()
+	if err != nil {
+		return VolumeRemovalCheck{}, fmt.Errorf("failed to get volumes: %w", err)
+	}
+
+	check := VolumeRemovalCheck{
+		VolumeID: id,
+		Status:   vol.Status(),
+		Targets:  []RemovalTarget{},
+	}
+	for _, v := range volumes {
+		if v.ID == id {
+			check.UsedSectors = v.UsedSectors
+			continue
+		} else if v.ReadOnly || !v.Available || v.UsedSectors >= v.TotalSectors {
+			// sectors are only migrated to writable volumes with free space
+			continue
+		}
+		free := v.TotalSectors - v.UsedSectors
+		check.FreeSectors += free
+		check.Targets = append(check.Targets, RemovalTarget{
+			VolumeID:    v.ID,
+			LocalPath:   v.LocalPath,
+			FreeSectors: free,
+		})
+	}
+	check.ReclaimableSectors, err = vm.vs.ReclaimableSectors(id, vm.reclaimHeight())
+	if err != nil {
+		return VolumeRemovalCheck{}, fmt.Errorf("failed to get reclaimable sectors: %w", err)
+	}
+
+	migrate := check.UsedSectors
+	if vm.expired.ReclaimOnMigration {
+		migrate -= min(migrate, check.ReclaimableSectors)
+	}
+	if migrate > check.FreeSectors {
+		check.Unmigratable = migrate - check.FreeSectors
+	}
+
+	switch {
+	case check.Status != VolumeStatusReady && check.Status != VolumeStatusUnavailable:
+		check.Reason = fmt.Sprintf("volume is %v", check.Status)
+	case check.Unmigratable > 0:
+		check.Reason = fmt.Sprintf("%d of %d used sectors cannot be migrated: free %d sectors or add capacity for %d sectors to other volumes", check.Unmigratable, check.UsedSectors, check.Unmigratable, check.Unmigratable)
+	default:
+		check.CanRemove = true
+	}
+	return check, nil
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrReservationClosed is returned when a sector is written to a
+	// reservation that was released.
+	ErrReservationClosed = errors.New("reservation is closed")
+	// ErrReservationExhausted is returned when more sectors are written to a
+	// reservation than were reserved.
+	ErrReservationExhausted = errors.New("all reserved sectors have been written")
+	// ErrReservationExpired is returned when a sector is written to a
+	// reservation that was released because it was not used before its TTL.
+	ErrReservationExpired = errors.New("reservation expired")
+)
+
+// A Reservation holds space for sectors that will be written by a pending
+// upload. Reserved sectors are not available to other writes until they are
+// written or the reservation is released. The owner of the reservation,
+// usually a renter session, must release it when the upload ends. A
+// reservation that is not used for longer than the volume manager's
+// reservation TTL is released automatically.
+type Reservation struct {
+	vm *VolumeManager
+
+	mu        sync.Mutex
+	timer     *time.Timer
+	remaining uint64
+	closed    bool
+	expired   bool
+}
+
+// writableUsage returns the used and total sectors of the volumes that can
+// accept new sectors.
+func writableUsage(volumes []Volume) (used, total uint64) {
+	for _, vol := range volumes {
+		if vol.ReadOnly || !vol.Available {
+			continue
+		}
+		used += min(vol.UsedSectors, vol.TotalSectors)
+		total += vol.TotalSectors
+	}
+	return
+}
+
+// loadUsage loads the storage usage of the writable volumes from the store.
+// reserveMu must be held.
+func (vm *VolumeManager) loadUsage() error {
+	volumes, err := vm.vs.Volumes()
+	if err != nil {
+		return fmt.Errorf("failed to get volumes: %w", err)
+	}
+	vm.usedSectors, vm.totalSectors = writableUsage(volumes)
+	vm.usageStale = false
+	return nil
+}
+
+// invalidateUsage reloads the storage usage from the store before the next
+// reservation. It must be called when a volume's size, read-only flag, or
+// availability changes.
+func (vm *VolumeManager) invalidateUsage() {
+	vm.reserveMu.Lock()
+	defer vm.reserveMu.Unlock()
+	vm.usageStale = true
+}
+
+// addUsedSectors adds n newly stored sectors to the in-memory usage.
+func (vm *VolumeManager) addUsedSectors(n uint64) {
+	vm.reserveMu.Lock()
+	defer vm.reserveMu.Unlock()
+	vm.usedSectors += n
+}
+
+// reserve reserves space for n sectors. ErrNotEnoughStorage is returned if
+// the free space, less any outstanding reservations, is smaller than n.
+func (vm *VolumeManager) reserve(n uint64) error {
+	vm.reserveMu.Lock()
+	defer vm.reserveMu.Unlock()
+
+	if vm.usageStale {
+		if err := vm.loadUsage(); err != nil {
+			return err
+		}
+	}
+	if vm.usedSectors+vm.reserved+n > vm.totalSectors {
+		// sectors removed by the store are not subtracted from the
+		// in-memory usage, reload it before rejecting the reservation
+		if err := vm.loadUsage(); err != nil {
+			return err
+		} else if used := vm.usedSectors + vm.reserved; used+n > vm.totalSectors {
+			return fmt.Errorf("%w: %d sectors requested, %d available", ErrNotEnoughStorage, n, vm.totalSectors-min(vm.totalSectors, used))
+		}
+	}
+	vm.reserved += n
+	return nil
+}
+
+// unreserve returns n reserved sectors to the free space.
+func (vm *VolumeManager) unreserve(n uint64) {
+	vm.reserveMu.Lock()
+	defer vm.reserveMu.Unlock()
+	if n > vm.reserved {
+		panic(fmt.Errorf("unreserved %d sectors, only %d reserved", n, vm.reserved)) // should never happen
+	}
+	vm.reserved -= n
+}
+
+// lockStoredSector locks the sector if it is already stored. Writing a stored
+// sector only adds a reference to it, so no space needs to be reserved. The
+// returned function must be called to unlock the sector.
+func (vm *VolumeManager) lockStoredSector(root types.Hash256) (func(), bool, error) {
+	_, release, err := vm.vs.SectorLocation(root)
+	if errors.Is(err, ErrSectorNotFound) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, &SectorError{Root: root, Err: fmt.Errorf("failed to get sector location: %w", err)}
+	}
+	return func() {
+		if err := release(); err != nil {
+			vm.log.Warn("failed to unlock sector", zap.Stringer("root", root), zap.Error(err))
+		}
+	}, true, nil
+}
+
+// Reserved returns the number of sectors that are reserved for pending
+// uploads.
+func (vm *VolumeManager) Reserved() uint64 {
+	vm.reserveMu.Lock()
+	defer vm.reserveMu.Unlock()
+	return vm.reserved
+}
+
+// AvailableSectors returns the number of sectors that can be written to the
+// host's writable volumes, less any outstanding reservations.
+func (vm *VolumeManager) AvailableSectors() (uint64, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	volumes, err := vm.vs.Volumes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get volumes: %w", err)
+	}
+	used, total := writableUsage(volumes)
+	free := total - used
+	return free - min(free, vm.Reserved()), nil
+}
+
+// ReserveSectors reserves space for n sectors. The space is held until each
+// sector is written with the reservation's Write method, or the reservation
+// is released. If the reservation is not used for longer than the reservation
+// TTL, it is released automatically. ErrNotEnoughStorage is returned if there
+// is not enough free space.
+func (vm *VolumeManager) ReserveSectors(n uint64) (*Reservation, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := vm.reserve(n); err != nil {
+		return nil, err
+	}
+	r := &Reservation{
+		vm:        vm,
+		remaining: n,
+	}
+	if vm.reservationTTL > 0 {
+		r.timer = time.AfterFunc(vm.reservationTTL, r.expire)
+	}
+	return r, nil
+}
+
+// checkOpen returns an error if the reservation was released or expired.
+// Otherwise the reservation's TTL is reset. r.mu must be held.
+func (r *Reservation) checkOpen() error {
+	if r.expired {
+		return ErrReservationExpired
+	} else if r.closed {
+		return ErrReservationClosed
+	} else if r.timer != nil {
+		r.timer.Reset(r.vm.reservationTTL)
+	}
+	return nil
+}
+
+// expire releases the reservation after it was not used for the reservation
+// TTL.
+func (r *Reservation) expire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.vm.log.Debug("sector reservation expired", zap.Uint64("remaining", r.remaining))
+	r.expired = true
+	r.closed = true
+	r.vm.unreserve(r.remaining)
+	r.remaining = 0
+}
+
+// Reserve reserves space for n additional sectors. ErrNotEnoughStorage is
+// returned if there is not enough free space. The sectors that are already
+// reserved are not affected.
+func (r *Reservation) Reserve(n uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkOpen(); err != nil {
+		return err
+	} else if err := r.vm.reserve(n); err != nil {
+		return err
+	}
+	r.remaining += n
+	return nil
+}
+
+// Remaining returns the number of reserved sectors that have not been written.
+func (r *Reservation) Remaining() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.remaining
+}
+
+// Write writes a sector into one of the reserved sectors. The semantics of
+// release are the same as VolumeManager.Write. The reserved sector is
+// consumed even if the write fails. Sectors that are already stored do not
+// consume a reserved sector.
+func (r *Reservation) Write(root types.Hash256, data *[rhp2.SectorSize]byte) (func() error, error) {
+	r.mu.Lock()
+	if err := r.checkOpen(); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.mu.Unlock()
+
+	if unlock, ok, err := r.vm.lockStoredSector(root); err != nil {
+		return nil, err
+	} else if ok {
+		defer unlock()
+		return r.vm.write(root, data)
+	}
+
+	r.mu.Lock()
+	if err := r.checkOpen(); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	} else if r.remaining == 0 {
+		r.mu.Unlock()
+		return nil, ErrReservationExhausted
+	}
+	r.remaining--
+	r.mu.Unlock()
+
+	// the sector is counted as used once it is written, so the reserved
+	// sector can be returned either way
+	defer r.vm.unreserve(1)
+	return r.vm.write(root, data)
+}
+
+// Release releases any sectors that have not been written. It is safe to call
+// Release multiple times.
+func (r *Reservation) Release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.closed = true
+	r.vm.unreserve(r.remaining)
+	r.remaining = 0
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+	"lukechampine.com/frand"
+)
+
+// defaultSelfAuditSampleSize is the number of sectors read by each self-audit
+// if no sample size is configured.
+const defaultSelfAuditSampleSize = 10
+
+// alertSelfAuditID is the ID of the alert registered when a self-audit fails.
+var alertSelfAuditID = frand.Entropy256()
+
+type (
+	// A SelfAuditConfig configures the periodic self-audit. Each audit reads
+	// a random sample of stored sectors from disk through the same path used
+	// to serve renters and checks them against their Merkle roots.
+	SelfAuditConfig struct {
+		// Interval is the time between audits. A zero interval disables
+		// scheduled audits.
+		Interval time.Duration
+		// SampleSize is the number of sectors read by each audit.
+		SampleSize int
+		// LatencyThreshold is the read latency above which a sector is
+		// reported as slow. A zero threshold disables the latency check.
+		LatencyThreshold time.Duration
+	}
+
+	// A SelfAuditFailure is a sector that could not be read during a
+	// self-audit.
+	SelfAuditFailure struct {
+		Root  types.Hash256 `json:"root"`
+		Error string        `json:"error"`
+	}
+
+	// A SelfAuditRead is a sector read during a self-audit that took longer
+	// than the latency threshold.
+	SelfAuditRead struct {
+		Root    types.Hash256 `json:"root"`
+		Latency time.Duration `json:"latency"`
+	}
+
+	// A SelfAuditResult is the outcome of a self-audit.
+	SelfAuditResult struct {
+		Timestamp  time.Time          `json:"timestamp"`
+		Sampled    int                `json:"sampled"`
+		MaxLatency time.Duration      `json:"maxLatency"`
+		Failures   []SelfAuditFailure `json:"failures"`
+		SlowReads  []SelfAuditRead    `json:"slowReads"`
+	}
+)
+
+// sampleSectors returns the roots of up to n randomly selected stored
+// sectors. The same sector may be selected more than once.
+func (vm *VolumeManager) sampleSectors(n int) ([]types.Hash256, error) {
+	volumes, err := vm.vs.Volumes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volumes: %w", err)
+	}
+
+	var used uint64
+	for _, vol := range volumes {
+		used += vol.UsedSectors
+	}
+	if used == 0 {
+		return nil, nil
+	}
+
+	roots := make([]types.Hash256, 0, n)
+	for i := 0; i < n; i++ {
+		// select a sector uniformly across all volumes, then find its
+		// position within its volume
+		offset := frand.Uint64n(used)
+		for _, vol := range volumes {
+			if offset >= vol.UsedSectors {
+				offset -= vol.UsedSectors
+				continue
+			}
+			sectors, err := vm.vs.VolumeSectors(vol.ID, 1, int(offset))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get sectors of volume %v: %w", vol.ID, err)
+			} else if len(sectors) > 0 {
+				// the sector may have been removed since the volumes were
+				// loaded
+				roots = append(roots, sectors[0].Root)
+			}
+			break
+		}
+	}
+	return roots, nil
+}
+
+// SelfAudit reads a random sample of stored sectors with Read, the same path
+// used to serve renters, and checks them against their Merkle roots. Each
+// sampled sector is evicted from the cache first so it is read from disk. An
+// alert is registered if any sector fails to read or,
+// if a latency threshold is configured, is slow to read. The alert is
+// dismissed by the next audit that passes.
+func (vm *VolumeManager) SelfAudit(ctx context.Context) (SelfAuditResult, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return SelfAuditResult{}, err
+	}
+	defer done()
+
+	sampleSize := vm.selfAudit.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultSelfAuditSampleSize
+	}
+	roots, err := vm.sampleSectors(sampleSize)
+	if err != nil {
+		return SelfAuditResult{}, fmt.Errorf("failed to sample sectors: %w", err)
+	}
+
+	result := SelfAuditResult{
+		Timestamp: time.Now(),
+		Failures:  []SelfAuditFailure{},
+		SlowReads: []SelfAuditRead{},
+	}
+	for _, root := range roots {
+		select {
+		case <-ctx.Done():
+			return SelfAuditResult{}, ctx.Err()
+		default:
+		}
+
+		// evict the sector so the read is not served from the cache
+		vm.cache.Remove(root)
+		start := time.Now()
+		sector, err := vm.Read(root)
+		latency := time.Since(start)
+		if errors.Is(err, ErrSectorNotFound) {
+			// the sector was removed after it was sampled
+			continue
+		} else if err == nil && rhp2.SectorRoot(sector) != root {
+			err = &SectorError{Root: root, Err: ErrSectorCorrupt}
+		}
+
+		result.Sampled++
+		if latency > result.MaxLatency {
+			result.MaxLatency = latency
+		}
+		if err != nil {
+			result.Failures = append(result.Failures, SelfAuditFailure{Root: root, Error: err.Error()})
+		} else if vm.selfAudit.LatencyThreshold > 0 && latency > vm.selfAudit.LatencyThreshold {
+			result.SlowReads = append(result.SlowReads, SelfAuditRead{Root: root, Latency: latency})
+		}
+	}
+
+	log := vm.log.Named("selfAudit").With(zap.Int("sampled", result.Sampled), zap.Int("failures", len(result.Failures)), zap.Int("slow", len(result.SlowReads)))
+	var severity alerts.Severity
+	switch {
+	case len(result.Failures) > 0:
+		severity = alerts.SeverityError
+		log.Error("self-audit failed to read sectors")
+	case len(result.SlowReads) > 0:
+		severity = alerts.SeverityWarning
+		log.Warn("self-audit reads exceeded latency threshold", zap.Duration("threshold", vm.selfAudit.LatencyThreshold))
+	default:
+		log.Debug("self-audit passed", zap.Duration("maxLatency", result.MaxLatency))
+		vm.a.Dismiss(alertSelfAuditID)
+		return result, nil
+	}
+
+	vm.a.Register(alerts.Alert{
+		ID:       alertSelfAuditID,
+		Severity: severity,
+		Message:  "Self-audit found sector read problems",
+		Data: map[string]any{
+			"sampled":          result.Sampled,
+			"failures":         result.Failures,
+			"slowReads":        result.SlowReads,
+			"latencyThreshold": vm.selfAudit.LatencyThreshold.String(),
+		},
+		Timestamp: result.Timestamp,
+	})
+	return result, nil
+}
+
+// runSelfAudits audits a sample of stored sectors at the configured interval
+// until the volume manager is closed.
+func (vm *VolumeManager) runSelfAudits() {
+	ctx, cancel, err := vm.tg.AddContext(context.Background())
+	if err != nil {
+		return
+	}
+	defer cancel()
+
+	t := time.NewTicker(vm.selfAudit.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if _, err := vm.SelfAudit(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			vm.log.Error("self-audit failed", zap.Error(err))
+		}
+	}
+}
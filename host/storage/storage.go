@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,6 +18,7 @@ import (
 	"go.sia.tech/hostd/internal/threadgroup"
 	"go.sia.tech/siad/modules"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/xts"
 	"lukechampine.com/frand"
 )
 
@@ -24,6 +26,14 @@ const (
 	// MaxTempSectorBlocks is the maximum number of blocks that a temp sector
 	// can be stored for.
 	MaxTempSectorBlocks = 144 * 7 // 7 days
+
+	// DefaultMaxVolumes is the default maximum number of volumes that can be
+	// added to the host.
+	DefaultMaxVolumes = 1000
+
+	// DefaultReservationTTL is the default time a sector reservation is held
+	// without being used before it expires.
+	DefaultReservationTTL = 30 * time.Minute
 )
 
 // VolumeStatus is the status of a volume.
@@ -32,6 +42,7 @@ const (
 	VolumeStatusCreating    = "creating"
 	VolumeStatusResizing    = "resizing"
 	VolumeStatusRemoving    = "removing"
+	VolumeStatusSwapping    = "swapping"
 	VolumeStatusReady       = "ready"
 )
 
@@ -56,6 +67,14 @@ type (
 		Root   types.Hash256
 	}
 
+	// A VolumeSector is a sector stored in a volume.
+	VolumeSector struct {
+		Index uint64        `json:"index"`
+		Root  types.Hash256 `json:"root"`
+		// Contracts is the number of contracts referencing the sector.
+		Contracts uint64 `json:"contracts"`
+	}
+
 	// A TempSector is a stored sector that is not attached to a contract. It
 	// will be deleted after the expiration height unless it is appended to a
 	// contract.
@@ -64,17 +83,33 @@ type (
 		Expiration uint64
 	}
 
+	// A SectorAccess contains the number of times a sector was read within
+	// the hot sector window.
+	SectorAccess struct {
+		Root  types.Hash256 `json:"root"`
+		Reads uint64        `json:"reads"`
+	}
+
 	// A SectorReference contains the references to a sector.
 	SectorReference struct {
 		Contracts   []types.FileContractID `json:"contracts"`
 		TempStorage int                    `json:"tempStorage"`
 		Locks       int                    `json:"locks"`
+		// Packed is the number of references to packed sectors whose
+		// payloads are stored in the sector.
+		Packed int `json:"packed"`
 	}
 
 	// A VolumeManager manages storage using local volumes.
 	VolumeManager struct {
-		cacheHits   uint64 // ensure 64-bit alignment on 32-bit systems
-		cacheMisses uint64
+		cacheHits     uint64 // ensure 64-bit alignment on 32-bit systems
+		cacheMisses   uint64
+		prefetches    uint64
+		prefetchHits  uint64
+		readAheads    uint64
+		readAheadHits uint64
+
+		cachePressureEvictions uint64
 
 		a        Alerts
 		vs       VolumeStore
@@ -84,12 +119,57 @@ type (
 
 		tg *threadgroup.ThreadGroup
 
+		maxOpenVolumes int
+		files          *handleCache
+		backends       BackendProvider
+		checksums      bool
+		prefetchDepth  int
+		readAhead      int
+		passphrase     []byte
+		maxVolumes     int
+		writeSLO       LatencySLO
+		writeTimeout   time.Duration
+		selfAudit      SelfAuditConfig
+		autoGrow       AutoGrowConfig
+		expired        ExpiredSectorConfig
+		maxMigrations  int
+		migrations     *migrationLimiter
+		cacheMemory    CacheMemoryConfig
+		relink         RelinkConfig
+		packing        PackingConfig
+		reservationTTL time.Duration
+
+		// addMu serializes adding volumes so the volume limit cannot be
+		// exceeded by concurrent calls to AddVolume
+		addMu sync.Mutex
+
 		mu          sync.Mutex // protects the following fields
 		lastCleanup time.Time
 		volumes     map[int64]*volume
 		// changedVolumes tracks volumes that need to be fsynced
 		changedVolumes map[int64]bool
+		// unavailableFns are called when a volume fails to open, is
+		// degraded, or is force removed with lost sectors
+		unavailableFns []func(volumeID int64, localPath string)
 		cache          *lru.Cache[types.Hash256, *[rhp2.SectorSize]byte] // Added cache
+		// cacheSize is the requested number of cached sectors. The cache
+		// may hold fewer sectors if its memory is limited.
+		cacheSize uint32
+
+		reserveMu sync.Mutex // protects the following fields
+		// reserved is the number of sectors held for pending writes
+		reserved uint64
+		// usedSectors and totalSectors track the usage of the writable
+		// volumes so reservations do not query the store on every write.
+		// They are reloaded from the store when usageStale is set.
+		usedSectors  uint64
+		totalSectors uint64
+		usageStale   bool
+
+		prefetchMu sync.Mutex // protects prefetched
+		// prefetched tracks the cached sectors that were prefetched and
+		// have not been read yet
+		prefetched map[types.Hash256]bool
 	}
 )
 
@@ -105,6 +185,24 @@ func (vm *VolumeManager) loadVolumes() error {
 	if err != nil {
 		return fmt.Errorf("failed to load volumes: %w", err)
 	}
+
+	// notify subscribers of unavailable volumes after the lock is released
+	var unavailable []Volume
+	defer func() {
+		for _, vol := range unavailable {
+			vm.notifyUnavailable(vol.ID, vol.LocalPath)
+		}
+	}()
+
+	// track the paths of every volume so a moved file is never matched to
+	// another volume's file
+	known := make(map[string]bool, len(volumes))
+	for _, vol := range volumes {
+		if path, err := filepath.Abs(vol.LocalPath); err == nil {
+			known[path] = true
+		}
+	}
+
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
 	// load the volumes into memory
@@ -113,6 +211,7 @@ func (vm *VolumeManager) loadVolumes() error {
 		v := vm.volumes[vol.ID]
 		if v == nil {
 			v = &volume{
+				backends: vm.backends,
 				stats: VolumeStats{
 					Status: VolumeStatusUnavailable,
 				},
@@ -120,13 +219,26 @@ func (vm *VolumeManager) loadVolumes() error {
 			vm.volumes[vol.ID] = v
 		}
 
-		if err := v.OpenVolume(vol.LocalPath, false); err != nil {
+		c, err := vm.volumeCipher(vol)
+		if err == nil {
+			v.SetCipher(c)
+			err = v.OpenVolume(vol.LocalPath, false)
+			if errors.Is(err, os.ErrNotExist) && len(vm.relink.SearchDirs) > 0 {
+				if path, ok := vm.relinkVolume(vol, c, known); ok {
+					vol.LocalPath = path
+					known[path] = true
+					err = v.OpenVolume(path, false)
+				}
+			}
+		}
+		if err != nil {
 			v.appendError(fmt.Errorf("failed to open volume: %w", err))
 			vm.log.Error("unable to open volume", zap.Error(err), zap.Int64("id", vol.ID), zap.String("path", vol.LocalPath))
 			// mark the volume as unavailable
 			if err := vm.vs.SetAvailable(vol.ID, false); err != nil {
 				return fmt.Errorf("failed to mark volume '%v' as unavailable: %w", vol.LocalPath, err)
 			}
+			unavailable = append(unavailable, vol)
 
 			// register an alert
 			vm.a.Register(alerts.Alert{
@@ -150,13 +262,64 @@ func (vm *VolumeManager) loadVolumes() error {
 		}
 		vm.log.Debug("loaded volume", zap.Int64("id", vol.ID), zap.String("path", vol.LocalPath))
 	}
+	vm.invalidateUsage()
+	return nil
+}
+
+// notifyUnavailable calls the subscribed functions for an unavailable volume.
+func (vm *VolumeManager) notifyUnavailable(volumeID int64, localPath string) {
+	vm.mu.Lock()
+	fns := append([]func(int64, string){}, vm.unavailableFns...)
+	vm.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(volumeID, localPath)
+	}
+}
+
+// OnVolumeUnavailable registers fn to be called when a volume's sectors
+// cannot be relied on: the volume fails to open, a write to it exceeds the
+// write timeout and degrades it, or it is force removed with sectors that
+// failed to migrate. fn is called immediately for every volume that is
+// currently unavailable or degraded.
+func (vm *VolumeManager) OnVolumeUnavailable(fn func(volumeID int64, localPath string)) error {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	vm.mu.Lock()
+	vm.unavailableFns = append(vm.unavailableFns, fn)
+	vm.mu.Unlock()
+
+	volumes, err := vm.vs.Volumes()
+	if err != nil {
+		return fmt.Errorf("failed to get volumes: %w", err)
+	}
+	for _, vol := range volumes {
+		// volumes that are still being added are not available yet
+		vm.mu.Lock()
+		stats := vm.volumeStats(vol.ID)
+		vm.mu.Unlock()
+		if (!vol.Available && stats.Status == VolumeStatusUnavailable) || stats.Degraded {
+			fn(vol.ID, vol.LocalPath)
+		}
+	}
 	return nil
 }
 
 // migrateSector migrates a sector to a new location. The sector is read from
 // its current location and written to its new location. The volume is
-// immediately synced after the sector is written.
-func (vm *VolumeManager) migrateSector(loc SectorLocation) error {
+// immediately synced after the sector is written. Migrations by all
+// operations share the same concurrency limit.
+func (vm *VolumeManager) migrateSector(ctx context.Context, loc SectorLocation) (err error) {
+	release, err := vm.migrations.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { release(err) }()
+
 	// read the sector from the old location
 	sector, err := vm.Read(loc.Root)
 	if err != nil {
@@ -166,20 +329,22 @@ func (vm *VolumeManager) migrateSector(loc SectorLocation) error {
 	root := rhp2.SectorRoot(sector)
 	// verify the the sector is not corrupt
 	if root != loc.Root {
-		return fmt.Errorf("sector corrupt: %v != %v", loc.Root, root)
+		return &SectorError{Root: loc.Root, Err: fmt.Errorf("%w: got root %v", ErrSectorCorrupt, root)}
 	}
 
 	vm.mu.Lock()
 	vol, ok := vm.volumes[loc.Volume]
 	vm.mu.Unlock()
 	if !ok {
-		return fmt.Errorf("volume %v not found", loc.Volume)
+		return &SectorError{Root: loc.Root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: ErrVolumeNotFound}}
+	} else if vol.degraded() {
+		return &SectorError{Root: loc.Root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: ErrVolumeDegraded}}
 	}
 	// write the sector to the new location and sync the volume
 	if err := vol.WriteSector(sector, loc.Index); err != nil {
-		return fmt.Errorf("failed to write sector: %w", err)
+		return &SectorError{Root: loc.Root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: err}}
 	} else if err := vol.Sync(); err != nil {
-		return fmt.Errorf("failed to sync volume: %w", err)
+		return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpSync, Err: err}
 	}
 	return nil
 }
@@ -209,6 +374,8 @@ func (vm *VolumeManager) growVolume(ctx context.Context, id int64, volume *volum
 	// responsibility to register a completion alert
 	defer vm.a.Dismiss(alert.ID)
 
+	total := newMaxSectors - oldMaxSectors
+	reportProgress(ctx, 0, total)
 	for current := oldMaxSectors; current < newMaxSectors; current += resizeBatchSize {
 		// stop early if the context is cancelled
 		select {
@@ -230,11 +397,13 @@ func (vm *VolumeManager) growVolume(ctx context.Context, id int64, volume *volum
 		} else if err := vm.vs.GrowVolume(id, target); err != nil {
 			return fmt.Errorf("failed to expand volume metadata: %w", err)
 		}
+		vm.invalidateUsage()
 		log.Debug("expanded volume", zap.Uint64("current", current))
 
 		// update the alert
 		alert.Data["currentSectors"] = target
 		vm.a.Register(alert)
+		reportProgress(ctx, target-oldMaxSectors, total)
 		// sleep to allow other operations to run
 		time.Sleep(time.Millisecond)
 	}
@@ -267,16 +436,38 @@ func (vm *VolumeManager) shrinkVolume(ctx context.Context, id int64, volume *vol
 	// responsibility to register a completion alert
 	defer vm.a.Dismiss(a.ID)
 
+	// sectors of expired contracts outside of the target range are removed
+	// instead of migrated
+	if vm.expired.ReclaimOnMigration {
+		reclaimed, err := vm.reclaimExpiredSectors(id, newMaxSectors)
+		if err != nil {
+			return err
+		}
+		log.Info("reclaimed expired sectors", zap.Int("reclaimed", len(reclaimed)))
+	}
+
+	// progress covers the sectors migrated and the sectors removed. At most
+	// the volume's used sectors need to be migrated.
+	stat, err := vm.vs.Volume(id)
+	if err != nil {
+		return fmt.Errorf("failed to get volume: %w", err)
+	}
+	removed := oldMaxSectors - newMaxSectors
+	toMigrate := min(stat.UsedSectors, removed)
+	total := toMigrate + removed
+	reportProgress(ctx, 0, total)
+
 	// migrate any sectors outside of the target range.
 	var migrated int
 	migrated, failed, err := vm.vs.MigrateSectors(ctx, id, newMaxSectors, func(newLoc SectorLocation) error {
-		if err := vm.migrateSector(newLoc); err != nil {
+		if err := vm.migrateSector(ctx, newLoc); err != nil {
 			return err
 		}
 		migrated++
 		// update the alert
 		a.Data["migratedSectors"] = migrated
 		vm.a.Register(a)
+		reportProgress(ctx, min(uint64(migrated), toMigrate), total)
 		return nil
 	})
 	log.Info("migrated sectors", zap.Int("migrated", migrated), zap.Int("failed", failed))
@@ -305,7 +496,9 @@ func (vm *VolumeManager) shrinkVolume(ctx context.Context, id int64, volume *vol
 
 		if err := vm.vs.ShrinkVolume(id, target); err != nil {
 			return fmt.Errorf("failed to shrink volume metadata: %w", err)
-		} else if err := volume.Resize(target); err != nil {
+		}
+		vm.invalidateUsage()
+		if err := volume.Resize(target); err != nil {
 			return fmt.Errorf("failed to shrink volume data to %v sectors: %w", current, err)
 		}
 
@@ -315,6 +508,7 @@ func (vm *VolumeManager) shrinkVolume(ctx context.Context, id int64, volume *vol
 		// update the alert
 		a.Data["currentSectors"] = current
 		vm.a.Register(a)
+		reportProgress(ctx, toMigrate+oldMaxSectors-current, total)
 		// sleep to allow other operations to run
 		time.Sleep(time.Millisecond)
 	}
@@ -369,6 +563,14 @@ func (vm *VolumeManager) Usage() (usedSectors uint64, totalSectors uint64, err e
 	return vm.vs.StorageUsage()
 }
 
+// VolumeCount returns the number of volumes and the maximum number of volumes
+// that can be added.
+func (vm *VolumeManager) VolumeCount() (count, limit int) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return len(vm.volumes), vm.maxVolumes
+}
+
 // Volumes returns a list of all volumes in the storage manager.
 func (vm *VolumeManager) Volumes() ([]VolumeMeta, error) {
 	done, err := vm.tg.Add()
@@ -430,16 +632,15 @@ func (vm *VolumeManager) AddVolume(ctx context.Context, localPath string, maxSec
 	}
 	defer done()
 
-	// check that the volume file does not already exist
-	if _, err := os.Stat(localPath); err == nil {
-		return Volume{}, fmt.Errorf("volume file already exists: %s", localPath)
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return Volume{}, fmt.Errorf("failed to stat volume file: %w", err)
+	vm.addMu.Lock()
+	defer vm.addMu.Unlock()
+	if count, limit := vm.VolumeCount(); count >= limit {
+		return Volume{}, &VolumeLimitError{Volumes: count, Limit: limit}
 	}
 
-	f, err := os.Create(localPath)
+	backend, err := vm.backends.Create(localPath)
 	if err != nil {
-		return Volume{}, fmt.Errorf("failed to create volume file: %w", err)
+		return Volume{}, err
 	}
 
 	volumeID, err := vm.vs.AddVolume(localPath, false)
@@ -447,10 +648,25 @@ func (vm *VolumeManager) AddVolume(ctx context.Context, localPath string, maxSec
 		return Volume{}, fmt.Errorf("failed to add volume to store: %w", err)
 	}
 
+	// new volumes are encrypted if a passphrase is set
+	var c *xts.Cipher
+	if len(vm.passphrase) > 0 {
+		var enc VolumeEncryption
+		enc, c, err = newVolumeEncryption(vm.passphrase)
+		if err != nil {
+			return Volume{}, fmt.Errorf("failed to initialize volume encryption: %w", err)
+		} else if err := vm.vs.SetVolumeEncryption(volumeID, enc); err != nil {
+			return Volume{}, fmt.Errorf("failed to store volume encryption: %w", err)
+		}
+	}
+
 	// add the new volume to the volume map
 	vm.mu.Lock()
 	vol := &volume{
-		data: f,
+		location: localPath,
+		data:     backend,
+		backends: vm.backends,
+		cipher:   c,
 		stats: VolumeStats{
 			Status: VolumeStatusCreating,
 		},
@@ -459,6 +675,7 @@ func (vm *VolumeManager) AddVolume(ctx context.Context, localPath string, maxSec
 	vm.mu.Unlock()
 
 	vm.vs.SetAvailable(volumeID, true)
+	vm.invalidateUsage()
 
 	go func() {
 		log := vm.log.Named("initialize").With(zap.Int64("volumeID", volumeID), zap.Uint64("maxSectors", maxSectors))
@@ -506,13 +723,31 @@ func (vm *VolumeManager) SetReadOnly(id int64, readOnly bool) error {
 	vol, ok := vm.volumes[id]
 	vm.mu.Unlock()
 	if !ok {
-		return fmt.Errorf("volume %v not found", id)
+		return &VolumeError{VolumeID: id, Op: VolumeOpSetReadOnly, Err: ErrVolumeNotFound}
 	} else if vol.Status() != VolumeStatusReady {
-		return fmt.Errorf("volume is %v", vol.Status())
+		return &VolumeError{VolumeID: id, Op: VolumeOpSetReadOnly, Err: fmt.Errorf("volume is %v", vol.Status())}
 	}
 
 	if err := vm.vs.SetReadOnly(id, readOnly); err != nil {
-		return fmt.Errorf("failed to set volume %v to read-only: %w", id, err)
+		return &VolumeError{VolumeID: id, Op: VolumeOpSetReadOnly, Err: err}
+	}
+	vm.invalidateUsage()
+	return nil
+}
+
+// SetReplicaGroup sets the replica group of a volume. New sectors written to
+// the volume are replicated to another volume in the same group, and reads
+// fall back to the replica if the primary copy cannot be read. An empty group
+// disables replication for new sectors.
+func (vm *VolumeManager) SetReplicaGroup(id int64, group string) error {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if err := vm.vs.SetReplicaGroup(id, group); err != nil {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSetReplicaGroup, Err: err}
 	}
 	return nil
 }
@@ -530,12 +765,12 @@ func (vm *VolumeManager) RemoveVolume(ctx context.Context, id int64, force bool,
 	vol, ok := vm.volumes[id]
 	vm.mu.Unlock()
 	if !ok {
-		return fmt.Errorf("volume %v not found", id)
+		return &VolumeError{VolumeID: id, Op: VolumeOpRemove, Err: ErrVolumeNotFound}
 	}
 
 	oldStatus := vol.Status()
 	if err := vol.SetStatus(VolumeStatusRemoving); err != nil {
-		return fmt.Errorf("failed to set volume status: %w", err)
+		return &VolumeError{VolumeID: id, Op: VolumeOpRemove, Err: err}
 	}
 
 	stat, err := vm.vs.Volume(id)
@@ -547,6 +782,7 @@ func (vm *VolumeManager) RemoveVolume(ctx context.Context, id int64, force bool,
 	if err := vm.vs.SetReadOnly(id, true); err != nil {
 		return fmt.Errorf("failed to set volume %v to read-only: %w", id, err)
 	}
+	vm.invalidateUsage()
 
 	alert := alerts.Alert{
 		ID:       frand.Entropy256(),
@@ -577,14 +813,28 @@ func (vm *VolumeManager) RemoveVolume(ctx context.Context, id int64, force bool,
 		}
 
 		doMigration := func() error {
+			// sectors of expired contracts are removed instead of migrated
+			if vm.expired.ReclaimOnMigration {
+				reclaimed, err := vm.reclaimExpiredSectors(id, 0)
+				if err != nil {
+					log.Error("failed to reclaim expired sectors", zap.Error(err))
+					updateRemovalAlert("Failed to remove volume", alerts.SeverityError, err)
+					return err
+				}
+				log.Info("reclaimed expired sectors", zap.Int("reclaimed", len(reclaimed)))
+				alert.Data["reclaimed"] = len(reclaimed)
+			}
+
+			reportProgress(ctx, 0, stat.UsedSectors)
 			migrated, failed, err = vm.vs.MigrateSectors(ctx, id, 0, func(newLoc SectorLocation) error {
-				err := vm.migrateSector(newLoc)
+				err := vm.migrateSector(ctx, newLoc)
 				if err != nil {
 					failed++
 				} else {
 					migrated++
 				}
 				updateRemovalAlert("Removing volume", alerts.SeverityInfo, nil) // error is ignored during migration
+				reportProgress(ctx, min(uint64(migrated+failed), stat.UsedSectors), stat.UsedSectors)
 				return err
 			})
 			if err != nil {
@@ -595,6 +845,11 @@ func (vm *VolumeManager) RemoveVolume(ctx context.Context, id int64, force bool,
 			} else if !force && failed > 0 {
 				updateRemovalAlert("Failed to remove volume", alerts.SeverityError, ErrMigrationFailed)
 				return ErrMigrationFailed
+			} else if failed > 0 {
+				// the sectors that failed to migrate are lost once the
+				// volume is removed, notify subscribers while the sectors
+				// still reference the volume
+				vm.notifyUnavailable(id, stat.LocalPath)
 			}
 
 			// remove the volume from the volume store
@@ -605,13 +860,14 @@ func (vm *VolumeManager) RemoveVolume(ctx context.Context, id int64, force bool,
 				return err
 			}
 			delete(vm.volumes, id)
+			vm.invalidateUsage()
 
 			// close the volume file and remove it from disk
 			if err := vol.Close(); err != nil {
 				log.Error("failed to close volume", zap.Error(err))
 				updateRemovalAlert("Failed to close volume files", alerts.SeverityError, err)
 				return err
-			} else if err := os.Remove(stat.LocalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			} else if err := vm.backends.Remove(stat.LocalPath); err != nil {
 				log.Error("failed to remove volume file", zap.Error(err))
 				updateRemovalAlert("Failed to delete volume file", alerts.SeverityError, err)
 				return err
@@ -649,12 +905,12 @@ func (vm *VolumeManager) ResizeVolume(ctx context.Context, id int64, maxSectors
 
 	vol, ok := vm.volumes[id]
 	if !ok {
-		return fmt.Errorf("volume %v not found", id)
+		return &VolumeError{VolumeID: id, Op: VolumeOpResize, Err: ErrVolumeNotFound}
 	}
 
 	// check that the volume is not already being resized
 	if err := vol.SetStatus(VolumeStatusResizing); err != nil {
-		return fmt.Errorf("failed to set volume status: %w", err)
+		return &VolumeError{VolumeID: id, Op: VolumeOpResize, Err: err}
 	}
 
 	var resetReadOnly bool
@@ -664,6 +920,7 @@ func (vm *VolumeManager) ResizeVolume(ctx context.Context, id int64, maxSectors
 		if err := vm.vs.SetReadOnly(id, true); err != nil {
 			return fmt.Errorf("failed to set volume %v to read-only: %w", id, err)
 		}
+		vm.invalidateUsage()
 		resetReadOnly = true
 	}
 
@@ -718,6 +975,7 @@ func (vm *VolumeManager) ResizeVolume(ctx context.Context, id int64, maxSectors
 			if err := vm.vs.SetReadOnly(id, false); err != nil {
 				vm.log.Error("failed to set volume to read-write", zap.Error(err))
 			}
+			vm.invalidateUsage()
 		}
 		vol.SetStatus(VolumeStatusReady)
 		select {
@@ -739,30 +997,51 @@ func (vm *VolumeManager) RemoveSector(root types.Hash256) error {
 	// get and lock the sector's current location
 	loc, release, err := vm.vs.SectorLocation(root)
 	if err != nil {
-		return fmt.Errorf("failed to locate sector %v: %w", root, err)
+		return &SectorError{Root: root, Err: fmt.Errorf("failed to locate sector: %w", err)}
 	}
 	defer release()
 
+	// the replica is freed with the sector
+	locations := []SectorLocation{loc}
+	if replica, err := vm.vs.SectorReplica(root); err == nil {
+		locations = append(locations, replica)
+	} else if !errors.Is(err, ErrSectorNotFound) {
+		return &SectorError{Root: root, Err: fmt.Errorf("failed to locate replica: %w", err)}
+	}
+
+	// the sector's locations are zeroed after it is removed, which would
+	// block on a degraded volume
+	vm.mu.Lock()
+	for _, loc := range locations {
+		if vol, ok := vm.volumes[loc.Volume]; ok && vol.degraded() {
+			vm.mu.Unlock()
+			return &SectorError{Root: root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: ErrVolumeDegraded}}
+		}
+	}
+	vm.mu.Unlock()
+
 	// remove the sector from the volume store
 	if err := vm.vs.RemoveSector(root); err != nil {
-		return fmt.Errorf("failed to remove sector %v: %w", root, err)
+		return &SectorError{Root: root, Err: fmt.Errorf("failed to remove sector: %w", err)}
 	}
 
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
 
-	// get the volume from memory
-	vol, ok := vm.volumes[loc.Volume]
-	if !ok {
-		return fmt.Errorf("volume %v not found", loc.Volume)
-	}
-
-	// zero the sector and immediately sync the volume
 	var zeroes [rhp2.SectorSize]byte
-	if err := vol.WriteSector(&zeroes, loc.Index); err != nil {
-		return fmt.Errorf("failed to zero sector %v: %w", root, err)
-	} else if err := vol.Sync(); err != nil {
-		return fmt.Errorf("failed to sync volume %v: %w", loc.Volume, err)
+	for _, loc := range locations {
+		// get the volume from memory
+		vol, ok := vm.volumes[loc.Volume]
+		if !ok {
+			return &SectorError{Root: root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: ErrVolumeNotFound}}
+		}
+
+		// zero the sector and immediately sync the volume
+		if err := vol.WriteSector(&zeroes, loc.Index); err != nil {
+			return &SectorError{Root: root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: err}}
+		} else if err := vol.Sync(); err != nil {
+			return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpSync, Err: err}
+		}
 	}
 
 	// eject the sector from the cache
@@ -770,6 +1049,55 @@ func (vm *VolumeManager) RemoveSector(root types.Hash256) error {
 	return nil
 }
 
+// zeroFreedSectors overwrites the data of freed sectors with zeroes and marks
+// their locations as reusable. Locations in volumes that are not ready are
+// skipped until the next cleanup.
+func (vm *VolumeManager) zeroFreedSectors() error {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var zeroes [rhp2.SectorSize]byte
+	var afterID int64
+	for {
+		locations, err := vm.vs.ZeroPendingLocations(afterID, zeroBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get locations pending zeroing: %w", err)
+		} else if len(locations) == 0 {
+			return nil
+		}
+		afterID = locations[len(locations)-1].ID
+
+		var zeroed []int64
+		changed := make(map[int64]*volume)
+		for _, loc := range locations {
+			vm.mu.Lock()
+			vol, ok := vm.volumes[loc.Volume]
+			vm.mu.Unlock()
+			if !ok || vol.Status() != VolumeStatusReady || vol.degraded() {
+				continue
+			} else if err := vol.WriteSector(&zeroes, loc.Index); err != nil {
+				return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: err}
+			}
+			changed[loc.Volume] = vol
+			zeroed = append(zeroed, loc.ID)
+		}
+
+		// sync the volumes before the locations can be reused
+		for id, vol := range changed {
+			if err := vol.Sync(); err != nil {
+				return &VolumeError{VolumeID: id, Op: VolumeOpSync, Err: err}
+			}
+		}
+		if err := vm.vs.ClearZeroPending(zeroed); err != nil {
+			return fmt.Errorf("failed to clear zero pending: %w", err)
+		}
+		vm.log.Debug("zeroed freed sectors", zap.Int("zeroed", len(zeroed)), zap.Int("skipped", len(locations)-len(zeroed)))
+	}
+}
+
 // LockSector prevents the sector with the given root from being pruned. If the
 // sector does not exist, an error is returned. Release must be called when the
 // sector is no longer needed.
@@ -788,25 +1116,12 @@ func (vm *VolumeManager) CacheStats() (hits, misses uint64) {
 	return atomic.LoadUint64(&vm.cacheHits), atomic.LoadUint64(&vm.cacheMisses)
 }
 
-// Read reads the sector with the given root
-func (vm *VolumeManager) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error) {
-	done, err := vm.tg.Add()
-	if err != nil {
-		return nil, err
-	}
-	defer done()
-
-	// Check the cache first
-	if sector, ok := vm.cache.Get(root); ok {
-		vm.recorder.AddCacheHit()
-		atomic.AddUint64(&vm.cacheHits, 1)
-		return sector, nil
-	}
-
-	// Cache miss, read from disk
+// readSector reads the sector with the given root from disk, bypassing the
+// cache.
+func (vm *VolumeManager) readSector(root types.Hash256) (*[rhp2.SectorSize]byte, error) {
 	loc, release, err := vm.vs.SectorLocation(root)
 	if err != nil {
-		return nil, fmt.Errorf("failed to locate sector: %w", err)
+		return nil, &SectorError{Root: root, Err: fmt.Errorf("failed to locate sector: %w", err)}
 	}
 	defer release()
 
@@ -814,11 +1129,25 @@ func (vm *VolumeManager) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error
 	v, ok := vm.volumes[loc.Volume]
 	if !ok {
 		vm.mu.Unlock()
-		return nil, fmt.Errorf("volume %v not found", loc.Volume)
+		err := &VolumeError{VolumeID: loc.Volume, Op: VolumeOpRead, Err: ErrVolumeNotFound}
+		if sector, ok := vm.readReplica(root, err); ok {
+			return sector, nil
+		}
+		return nil, &SectorError{Root: root, Err: err}
 	}
 	vm.mu.Unlock()
-	sector, err := v.ReadSector(loc.Index)
+	sector, buffered, hit, err := v.ReadSectorAhead(loc.Index, vm.readAhead)
+	if buffered > 0 {
+		vm.recorder.AddReadAhead(uint64(buffered))
+		atomic.AddUint64(&vm.readAheads, uint64(buffered))
+	} else if hit {
+		vm.recorder.AddReadAheadHit()
+		atomic.AddUint64(&vm.readAheadHits, 1)
+	}
 	if err != nil {
+		if sector, ok := vm.readReplica(root, err); ok {
+			return sector, nil
+		}
 		stats := v.Stats()
 		vm.a.Register(alerts.Alert{
 			ID:       v.alertID("read"),
@@ -833,7 +1162,47 @@ func (vm *VolumeManager) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error
 			},
 			Timestamp: time.Now(),
 		})
-		return nil, fmt.Errorf("failed to read sector data: %w", err)
+		return nil, &SectorError{Root: root, Err: &VolumeError{VolumeID: loc.Volume, Op: VolumeOpRead, Err: err}}
+	} else if !hit {
+		// reads served from the read-ahead buffer do not touch the disk
+		vm.recorder.AddVolumeIO(loc.Volume, uint64(1+buffered)*rhp2.SectorSize, 0)
+	}
+
+	if vm.checksums {
+		if err := vm.verifySector(root, sector, false); err != nil {
+			if sector, ok := vm.readReplica(root, err); ok {
+				return sector, nil
+			}
+			return nil, &SectorError{Root: root, Err: err}
+		}
+	}
+	return sector, nil
+}
+
+// Read reads the sector with the given root
+func (vm *VolumeManager) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// Check the cache first
+	if sector, ok := vm.cache.Get(root); ok {
+		vm.recorder.AddCacheHit()
+		vm.recorder.AddSectorRead(root)
+		atomic.AddUint64(&vm.cacheHits, 1)
+		if vm.consumePrefetched(root) {
+			vm.recorder.AddPrefetchHit()
+			atomic.AddUint64(&vm.prefetchHits, 1)
+		}
+		return sector, nil
+	}
+
+	// Cache miss, read from disk
+	sector, err := vm.readSector(root)
+	if err != nil {
+		return nil, err
 	}
 
 	// Add sector to cache
@@ -841,9 +1210,24 @@ func (vm *VolumeManager) Read(root types.Hash256) (*[rhp2.SectorSize]byte, error
 	vm.recorder.AddCacheMiss()
 	atomic.AddUint64(&vm.cacheMisses, 1)
 	vm.recorder.AddRead()
+	vm.recorder.AddSectorRead(root)
 	return sector, nil
 }
 
+// HotSectors returns up to topN sectors with the most reads over the last 24
+// hours, ordered by the number of reads descending. Pending counts are
+// flushed before the report is generated.
+func (vm *VolumeManager) HotSectors(topN int) ([]SectorAccess, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	vm.recorder.Flush()
+	return vm.vs.HotSectors(time.Now().Add(-hotSectorWindow), topN)
+}
+
 // Sync syncs the data files of changed volumes.
 func (vm *VolumeManager) Sync() error {
 	done, err := vm.tg.Add()
@@ -865,9 +1249,15 @@ func (vm *VolumeManager) Sync() error {
 		vm.mu.Unlock()
 		if !ok {
 			continue
+		} else if vol.degraded() {
+			// syncing would wait for the hung write. No sectors are written
+			// to the volume while it is degraded; it is synced once the
+			// abandoned write completes.
+			vm.log.Debug("skipping sync of degraded volume", zap.Int64("volume", id))
+			continue
 		}
 		if err := vol.Sync(); err != nil {
-			return fmt.Errorf("failed to sync volume %v: %w", id, err)
+			return &VolumeError{VolumeID: id, Op: VolumeOpSync, Err: err}
 		}
 		vm.mu.Lock()
 		delete(vm.changedVolumes, id)
@@ -876,60 +1266,203 @@ func (vm *VolumeManager) Sync() error {
 	return nil
 }
 
-// Write writes a sector to a volume. release should only be called after the
-// contract roots have been committed to prevent the sector from being deleted.
-func (vm *VolumeManager) Write(root types.Hash256, data *[rhp2.SectorSize]byte) (func() error, error) {
-	done, err := vm.tg.Add()
-	if err != nil {
-		return nil, err
+// writeSector writes a sector's data to loc. Errors caused by the volume
+// itself are returned as a *VolumeError so the write can be retried in a
+// different volume.
+func (vm *VolumeManager) writeSector(root types.Hash256, data *[rhp2.SectorSize]byte, loc SectorLocation) error {
+	start := time.Now()
+
+	vm.mu.Lock()
+	vol, ok := vm.volumes[loc.Volume]
+	vm.mu.Unlock()
+	if !ok {
+		return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: ErrVolumeNotFound}
+	} else if vol.degraded() {
+		// the volume degraded after the write started
+		return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: ErrVolumeDegraded}
+	}
+
+	// write the sector to the volume
+	writeStart := time.Now()
+	var timeoutErr *TimeoutError
+	if err := vm.writeVolumeSector(loc.Volume, vol, data, loc.Index); errors.As(err, &timeoutErr) {
+		return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: err}
+	} else if err != nil {
+		stats := vol.Stats()
+		vm.a.Register(alerts.Alert{
+			ID:       vol.alertID("write"),
+			Severity: alerts.SeverityError,
+			Message:  "Failed to write sector",
+			Data: map[string]interface{}{
+				"volume":       vol.Location(),
+				"failedReads":  stats.FailedReads,
+				"failedWrites": stats.FailedWrites,
+				"sector":       root,
+				"error":        err.Error(),
+			},
+			Timestamp: time.Now(),
+		})
+		return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: err}
 	}
-	defer done()
-	release, err := vm.vs.StoreSector(root, func(loc SectorLocation, exists bool) error {
-		if exists {
-			return nil
-		}
-		start := time.Now()
+	vm.recordWriteLatency(loc.Volume, vol, time.Since(writeStart))
+	vm.recorder.AddVolumeIO(loc.Volume, 0, rhp2.SectorSize)
+	vm.log.Debug("wrote sector", zap.String("root", root.String()), zap.Int64("volume", loc.Volume), zap.Uint64("index", loc.Index), zap.Duration("elapsed", time.Since(start)))
 
+	if vm.checksums {
+		vm.storeChecksum(root, data)
+	}
+
+	// Add newly written sector to cache
+	vm.cache.Add(root, data)
+
+	// mark the volume as changed
+	vm.mu.Lock()
+	vm.changedVolumes[loc.Volume] = true
+	vm.mu.Unlock()
+	return nil
+}
+
+// replicateSector writes a copy of a newly stored sector to another volume in
+// the same replica group as its primary location. Sectors stored in volumes
+// without a replica group are not replicated.
+func (vm *VolumeManager) replicateSector(root types.Hash256, data *[rhp2.SectorSize]byte) error {
+	return vm.vs.StoreSectorReplica(root, func(loc SectorLocation) error {
 		vm.mu.Lock()
 		vol, ok := vm.volumes[loc.Volume]
 		vm.mu.Unlock()
 		if !ok {
-			return fmt.Errorf("volume %v not found", loc.Volume)
-		}
-
-		// write the sector to the volume
-		if err := vol.WriteSector(data, loc.Index); err != nil {
-			stats := vol.Stats()
-			vm.a.Register(alerts.Alert{
-				ID:       vol.alertID("write"),
-				Severity: alerts.SeverityError,
-				Message:  "Failed to write sector",
-				Data: map[string]interface{}{
-					"volume":       vol.Location(),
-					"failedReads":  stats.FailedReads,
-					"failedWrites": stats.FailedWrites,
-					"sector":       root,
-					"error":        err.Error(),
-				},
-				Timestamp: time.Now(),
-			})
-			return fmt.Errorf("failed to write sector data: %w", err)
+			return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: ErrVolumeNotFound}
+		} else if vol.degraded() {
+			return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: ErrVolumeDegraded}
+		} else if err := vm.writeVolumeSector(loc.Volume, vol, data, loc.Index); err != nil {
+			return &VolumeError{VolumeID: loc.Volume, Op: VolumeOpWrite, Err: err}
 		}
-		vm.log.Debug("wrote sector", zap.String("root", root.String()), zap.Int64("volume", loc.Volume), zap.Uint64("index", loc.Index), zap.Duration("elapsed", time.Since(start)))
 
-		// Add newly written sector to cache
-		vm.cache.Add(root, data)
-
-		// mark the volume as changed
 		vm.mu.Lock()
 		vm.changedVolumes[loc.Volume] = true
 		vm.mu.Unlock()
 		return nil
 	})
-	if err == nil {
-		vm.recorder.AddWrite()
+}
+
+// readReplica reads a sector from its replica after reading the primary copy
+// failed with primaryErr. The replica's data is verified against the sector's
+// root. ok is false if the sector does not have a readable replica.
+func (vm *VolumeManager) readReplica(root types.Hash256, primaryErr error) (_ *[rhp2.SectorSize]byte, ok bool) {
+	log := vm.log.Named("replica").With(zap.Stringer("root", root), zap.NamedError("primaryErr", primaryErr))
+
+	loc, err := vm.vs.SectorReplica(root)
+	if errors.Is(err, ErrSectorNotFound) {
+		return nil, false
+	} else if err != nil {
+		log.Warn("failed to locate replica", zap.Error(err))
+		return nil, false
+	}
+
+	vm.mu.Lock()
+	vol, ok := vm.volumes[loc.Volume]
+	vm.mu.Unlock()
+	if !ok {
+		log.Warn("replica volume not found", zap.Int64("volume", loc.Volume))
+		return nil, false
+	}
+	sector, err := vol.ReadSector(loc.Index)
+	if err != nil {
+		log.Warn("failed to read replica", zap.Int64("volume", loc.Volume), zap.Error(err))
+		return nil, false
+	} else if rhp2.SectorRoot(sector) != root {
+		log.Warn("replica is corrupt", zap.Int64("volume", loc.Volume))
+		return nil, false
+	}
+	vm.recorder.AddVolumeIO(loc.Volume, rhp2.SectorSize, 0)
+	log.Warn("read sector from replica", zap.Int64("volume", loc.Volume))
+	return sector, true
+}
+
+// Write writes a sector to a volume. If the write fails because of an error
+// in the chosen volume, the sector is written to a different volume instead.
+// Sectors reserved for pending uploads are not available to Write.
+// release should only be called after the contract roots have been committed
+// to prevent the sector from being deleted.
+func (vm *VolumeManager) Write(root types.Hash256, data *[rhp2.SectorSize]byte) (func() error, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// sectors that are already stored only gain a reference and do not
+	// need space
+	if unlock, ok, err := vm.lockStoredSector(root); err != nil {
+		return nil, err
+	} else if ok {
+		defer unlock()
+		return vm.write(root, data)
+	}
+
+	// hold a sector for the duration of the write so that concurrent
+	// reservations cannot claim it
+	if err := vm.reserve(1); err != nil {
+		return nil, err
+	}
+	defer vm.unreserve(1)
+	return vm.write(root, data)
+}
+
+// write writes a sector to a volume. The caller is responsible for reserving
+// space for the sector.
+func (vm *VolumeManager) write(root types.Hash256, data *[rhp2.SectorSize]byte) (func() error, error) {
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// degraded volumes may still be writing to sectors that were released
+	// after their writes timed out
+	failed := vm.degradedVolumes()
+	var lastErr error
+	for {
+		var written bool
+		release, err := vm.vs.StoreSectorExcluding(root, failed, func(loc SectorLocation, exists bool) error {
+			if exists {
+				return nil
+			} else if err := vm.writeSector(root, data, loc); err != nil {
+				return err
+			}
+			written = true
+			return nil
+		})
+		var volumeErr *VolumeError
+		var timeoutErr *TimeoutError
+		switch {
+		case err == nil:
+			vm.recorder.AddWrite()
+			if written {
+				vm.addUsedSectors(1)
+				// a failed replica does not fail the write, the sector is
+				// still stored in its primary location
+				if err := vm.replicateSector(root, data); err != nil {
+					vm.log.Warn("failed to replicate sector", zap.Stringer("root", root), zap.Error(err))
+				}
+			}
+			return release, nil
+		case errors.As(err, &timeoutErr):
+			// the write is abandoned instead of retried so that a hung
+			// disk cannot block the caller for more than the timeout
+			return nil, &SectorError{Root: root, Err: err}
+		case errors.Is(err, ErrNotEnoughStorage) && lastErr != nil:
+			return nil, &SectorError{Root: root, Err: fmt.Errorf("%w: failed to write sector to %v volumes: %v", ErrNotEnoughStorage, len(failed), lastErr)}
+		case errors.As(err, &volumeErr):
+			// the sector metadata was rolled back, exclude the failed volume
+			// and retry
+			vm.log.Warn("failed to write sector, retrying in another volume", zap.Stringer("root", root), zap.Int64("volume", volumeErr.VolumeID), zap.Error(volumeErr.Err))
+			failed = append(failed, volumeErr.VolumeID)
+			lastErr = err
+		default:
+			return nil, &SectorError{Root: root, Err: err}
+		}
 	}
-	return release, err
 }
 
 // AddTemporarySectors adds sectors to the temporary store. The sectors are not
@@ -948,10 +1481,15 @@ func (vm *VolumeManager) AddTemporarySectors(sectors []TempSector) error {
 	return vm.vs.AddTemporarySectors(sectors)
 }
 
-// ResizeCache resizes the cache to the given size.
+// ResizeCache resizes the cache to the given size. If the cache's memory is
+// limited, the cache holds at most the limit's worth of sectors.
 func (vm *VolumeManager) ResizeCache(size uint32) {
+	vm.mu.Lock()
+	vm.cacheSize = size
+	capacity := vm.cacheCapacity()
+	vm.mu.Unlock()
 	// Resize the underlying cache data structure
-	vm.cache.Resize(int(size))
+	vm.cache.Resize(capacity)
 }
 
 // ProcessConsensusChange is called when the consensus set changes.
@@ -969,20 +1507,14 @@ func (vm *VolumeManager) ProcessConsensusChange(cc modules.ConsensusChange) {
 		if err := vm.vs.ExpireTempSectors(uint64(cc.BlockHeight)); err != nil {
 			log.Error("failed to expire temp sectors", zap.Error(err))
 		}
+		if err := vm.zeroFreedSectors(); err != nil {
+			log.Error("failed to zero freed sectors", zap.Error(err))
+		}
 	}()
 }
 
 // NewVolumeManager creates a new VolumeManager.
-func NewVolumeManager(vs VolumeStore, a Alerts, cm ChainManager, log *zap.Logger, sectorCacheSize uint32) (*VolumeManager, error) {
-	// Initialize cache with LRU eviction and a max capacity of 64
-	cache, err := lru.New[types.Hash256, *[rhp2.SectorSize]byte](64)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize cache: %w", err)
-	}
-	// resize the cache, prevents an error in lru.New when initializing the
-	// cache to 0
-	cache.Resize(int(sectorCacheSize))
-
+func NewVolumeManager(vs VolumeStore, a Alerts, cm ChainManager, log *zap.Logger, sectorCacheSize uint32, opts ...Option) (*VolumeManager, error) {
 	vm := &VolumeManager{
 		vs:  vs,
 		a:   a,
@@ -995,14 +1527,46 @@ func NewVolumeManager(vs VolumeStore, a Alerts, cm ChainManager, log *zap.Logger
 
 		volumes:        make(map[int64]*volume),
 		changedVolumes: make(map[int64]bool),
-		cache:          cache,
+		prefetched:     make(map[types.Hash256]bool),
+		maxVolumes:     DefaultMaxVolumes,
+		reservationTTL: DefaultReservationTTL,
+		usageStale:     true,
 		tg:             threadgroup.New(),
 	}
+
+	// Initialize cache with LRU eviction and a max capacity of 64
+	cache, err := lru.NewWithEvict[types.Hash256, *[rhp2.SectorSize]byte](64, vm.evictPrefetched)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	vm.cache = cache
+
+	for _, opt := range opts {
+		opt(vm)
+	}
+	// resize the cache, prevents an error in lru.New when initializing the
+	// cache to 0
+	vm.ResizeCache(sectorCacheSize)
+	vm.files = newHandleCache(vm.maxOpenVolumes, vm.log.Named("handles"))
+	vm.migrations = newMigrationLimiter(vm.maxMigrations)
+	if vm.backends == nil {
+		vm.backends = &fileProvider{files: vm.files}
+	}
+
 	if err := vm.loadVolumes(); err != nil {
 		return nil, err
 	} else if err := vm.cm.Subscribe(vm, modules.ConsensusChangeRecent, vm.tg.Done()); err != nil {
 		return nil, fmt.Errorf("failed to subscribe to consensus set: %w", err)
 	}
 	go vm.recorder.Run(vm.tg.Done())
+	if vm.selfAudit.Interval > 0 {
+		go vm.runSelfAudits()
+	}
+	if vm.autoGrow.Interval > 0 {
+		go vm.runAutoGrow()
+	}
+	if vm.cacheMemory.HighWaterMark > 0 {
+		go vm.runCachePressure()
+	}
 	return vm, nil
 }
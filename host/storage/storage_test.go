@@ -1,22 +1,26 @@
 package storage_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	rhp2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/internal/chain"
 	"go.sia.tech/hostd/persist/sqlite"
 	"go.sia.tech/hostd/webhooks"
+	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/consensus"
 	"go.sia.tech/siad/modules/gateway"
 	"go.uber.org/zap/zaptest"
@@ -385,6 +389,300 @@ func TestRemoveVolume(t *testing.T) {
 	}
 }
 
+func TestCanRemoveVolume(t *testing.T) {
+	const volumeSectors = 10
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	volume, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata.dat"), volumeSectors, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// an empty volume can always be removed
+	check, err := vm.CanRemoveVolume(volume.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if !check.CanRemove || check.Reason != "" {
+		t.Fatalf("expected empty volume to be removable, got %+v", check)
+	}
+
+	// fill the volume
+	for i := 0; i < volumeSectors; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 1}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a full single volume cannot be removed since there is nowhere to
+	// migrate its sectors
+	check, err = vm.CanRemoveVolume(volume.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if check.CanRemove {
+		t.Fatal("expected volume to not be removable")
+	} else if check.UsedSectors != volumeSectors {
+		t.Fatalf("expected %v used sectors, got %v", volumeSectors, check.UsedSectors)
+	} else if check.Unmigratable != volumeSectors {
+		t.Fatalf("expected %v unmigratable sectors, got %v", volumeSectors, check.Unmigratable)
+	} else if check.FreeSectors != 0 || len(check.Targets) != 0 {
+		t.Fatalf("expected no targets, got %+v", check.Targets)
+	} else if check.Reason == "" {
+		t.Fatal("expected a reason")
+	}
+
+	// add a second volume with space for some of the sectors
+	volume2, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata2.dat"), 4, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	check, err = vm.CanRemoveVolume(volume.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if check.CanRemove {
+		t.Fatal("expected volume to not be removable")
+	} else if check.Unmigratable != volumeSectors-4 {
+		t.Fatalf("expected %v unmigratable sectors, got %v", volumeSectors-4, check.Unmigratable)
+	} else if len(check.Targets) != 1 || check.Targets[0].VolumeID != volume2.ID || check.Targets[0].FreeSectors != 4 {
+		t.Fatalf("expected volume %v as the only target, got %+v", volume2.ID, check.Targets)
+	}
+
+	// grow the second volume to fit the remaining sectors
+	if err := vm.ResizeVolume(context.Background(), volume2.ID, volumeSectors, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	check, err = vm.CanRemoveVolume(volume.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if !check.CanRemove || check.Unmigratable != 0 {
+		t.Fatalf("expected volume to be removable, got %+v", check)
+	}
+
+	if _, err := vm.CanRemoveVolume(volume.ID + 100); !errors.Is(err, storage.ErrVolumeNotFound) {
+		t.Fatalf("expected ErrVolumeNotFound, got %v", err)
+	}
+}
+
+func TestSwapBackingFile(t *testing.T) {
+	// sectors without checksums are verified by their Merkle root
+	for _, checksums := range []bool{true, false} {
+		t.Run(fmt.Sprintf("checksums=%v", checksums), func(t *testing.T) {
+			testSwapBackingFile(t, checksums)
+		})
+	}
+}
+
+func testSwapBackingFile(t *testing.T, checksums bool) {
+	const volumeSectors = 10
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithSectorChecksums(checksums))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	oldPath := filepath.Join(t.TempDir(), "hostdata.dat")
+	result := make(chan error, 1)
+	volume, err := vm.AddVolume(context.Background(), oldPath, volumeSectors, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	roots := make([]types.Hash256, 5)
+	for i := range roots {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		roots[i] = rhp2.SectorRoot(&sector)
+		release, err := vm.Write(roots[i], &sector)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: roots[i], Expiration: 1}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := vm.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// copyVolume copies the volume's file to a new path and calls fn to
+	// modify the copy
+	copyVolume := func(name string, fn func(f *os.File) error) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), name)
+		buf, err := os.ReadFile(oldPath)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := os.WriteFile(path, buf, 0600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := fn(f); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	unchanged := func(*os.File) error { return nil }
+
+	// the volume must be read-only
+	if err := vm.SwapBackingFile(context.Background(), volume.ID, copyVolume("writable.dat", unchanged), true); err == nil {
+		t.Fatal("expected swapping a writable volume to fail")
+	} else if err := vm.SetReadOnly(volume.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// a file with a different size should be rejected
+	truncated := copyVolume("truncated.dat", func(f *os.File) error {
+		return f.Truncate((volumeSectors - 1) * rhp2.SectorSize)
+	})
+	if err := vm.SwapBackingFile(context.Background(), volume.ID, truncated, false); !errors.Is(err, storage.ErrBackingFileMismatch) {
+		t.Fatalf("expected %v, got %v", storage.ErrBackingFileMismatch, err)
+	}
+
+	// a file with different sector data should be rejected when the sectors
+	// are verified
+	corrupt := copyVolume("corrupt.dat", func(f *os.File) error {
+		for i := range roots {
+			if _, err := f.WriteAt(frand.Bytes(256), int64(i)*rhp2.SectorSize); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := vm.SwapBackingFile(context.Background(), volume.ID, corrupt, true); !errors.Is(err, storage.ErrBackingFileMismatch) {
+		t.Fatalf("expected %v, got %v", storage.ErrBackingFileMismatch, err)
+	}
+
+	// the failed swaps should not have changed the volume
+	if meta, err := vm.Volume(volume.ID); err != nil {
+		t.Fatal(err)
+	} else if meta.LocalPath != oldPath {
+		t.Fatalf("expected path %q, got %q", oldPath, meta.LocalPath)
+	} else if meta.Status != storage.VolumeStatusReady {
+		t.Fatalf("expected volume to be ready, got %v", meta.Status)
+	}
+
+	// swap to a valid copy
+	newPath := copyVolume("valid.dat", unchanged)
+	if err := vm.SwapBackingFile(context.Background(), volume.ID, newPath, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if meta, err := vm.Volume(volume.ID); err != nil {
+		t.Fatal(err)
+	} else if meta.LocalPath != newPath {
+		t.Fatalf("expected path %q, got %q", newPath, meta.LocalPath)
+	} else if meta.Status != storage.VolumeStatusReady {
+		t.Fatalf("expected volume to be ready, got %v", meta.Status)
+	}
+
+	// remove the old file to ensure sectors are read from the new file
+	if err := os.Remove(oldPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range roots {
+		if err := vm.VerifySector(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestRemoveCorrupt(t *testing.T) {
 	const expectedSectors = 50
 	dir := t.TempDir()
@@ -1454,140 +1752,447 @@ func TestSectorCache(t *testing.T) {
 	}
 }
 
-func BenchmarkVolumeManagerWrite(b *testing.B) {
-	dir := b.TempDir()
+func TestSectorPrefetch(t *testing.T) {
+	const (
+		sectors       = 20
+		prefetchDepth = 4
+	)
+	dir := t.TempDir()
 
 	// create the database
-	log := zaptest.NewLogger(b)
+	log := zaptest.NewLogger(t)
 	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 	defer db.Close()
 
 	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 	defer g.Close()
 
 	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
 	select {
 	case err := <-errCh:
-		b.Fatal(err)
+		if err != nil {
+			t.Fatal(err)
+		}
 	default:
 	}
 	cm, err := chain.NewManager(cs)
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 	defer cm.Close()
 
 	// initialize the storage manager
 	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 
 	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
-	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectors, storage.WithPrefetchDepth(prefetchDepth))
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 	defer vm.Close()
 
 	result := make(chan error, 1)
-	volumeFilePath := filepath.Join(b.TempDir(), "hostdata.dat")
-	_, err = vm.AddVolume(context.Background(), volumeFilePath, uint64(b.N), result)
-	if err != nil {
-		b.Fatal(err)
+	volumeFilePath := filepath.Join(t.TempDir(), "hostdata.dat")
+	if _, err := vm.AddVolume(context.Background(), volumeFilePath, sectors, result); err != nil {
+		t.Fatal(err)
 	} else if err := <-result; err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 
-	sectors := make([][rhp2.SectorSize]byte, b.N)
-	roots := make([]types.Hash256, b.N)
-	for i := range sectors {
-		frand.Read(sectors[i][:256])
-		roots[i] = rhp2.SectorRoot(&sectors[i])
+	roots := make([]types.Hash256, 0, sectors)
+	for i := 0; i < cap(roots); i++ {
+		root, err := storeRandomSector(vm, uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	sectorRoot := func(i uint64) (types.Hash256, error) {
+		if i >= uint64(len(roots)) {
+			return types.Hash256{}, errors.New("index out of range")
+		}
+		return roots[i], nil
 	}
 
-	b.ResetTimer()
-	b.ReportAllocs()
-	b.SetBytes(rhp2.SectorSize)
+	// clear the cache
+	vm.ResizeCache(0)
+	vm.ResizeCache(sectors)
 
-	// fill the volume
-	for i := 0; i < b.N; i++ {
-		root, sector := roots[i], sectors[i]
-		release, err := vm.Write(root, &sector)
-		if err != nil {
-			b.Fatal(i, err)
-		} else if err := release(); err != nil {
-			b.Fatal(i, err)
+	waitForPrefetches := func(n uint64) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if prefetched, _ := vm.PrefetchStats(); prefetched >= n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
 		}
+		prefetched, _ := vm.PrefetchStats()
+		t.Fatalf("expected %v prefetched sectors, got %v", n, prefetched)
 	}
-}
-
-func BenchmarkNewVolume(b *testing.B) {
-	const sectors = 100
-	dir := b.TempDir()
+
+	// read the first two sectors sequentially, the next sectors should be
+	// prefetched
+	var id types.FileContractID
+	frand.Read(id[:])
+	p := vm.Prefetcher()
+	for i := uint64(0); i < 2; i++ {
+		if _, err := vm.Read(roots[i]); err != nil {
+			t.Fatal(err)
+		}
+		p.Observe(id, i, sectorRoot)
+	}
+	waitForPrefetches(prefetchDepth)
+
+	// the prefetched sectors should be cache hits
+	for i := uint64(2); i < 2+prefetchDepth; i++ {
+		if _, err := vm.Read(roots[i]); err != nil {
+			t.Fatal(err)
+		}
+		p.Observe(id, i, sectorRoot)
+	}
+	if _, hits := vm.PrefetchStats(); hits != prefetchDepth {
+		t.Fatalf("expected %v prefetch hits, got %v", prefetchDepth, hits)
+	} else if hits, misses := vm.CacheStats(); hits != prefetchDepth || misses != 2 {
+		t.Fatalf("expected %v cache hits and 2 misses, got %v and %v", prefetchDepth, hits, misses)
+	}
+	waitForPrefetches(2 * prefetchDepth)
+
+	// random reads in a new session should disable prefetching
+	p = vm.Prefetcher()
+	prefetched, _ := vm.PrefetchStats()
+	for _, i := range []uint64{19, 3, 15, 7, 11, 1} {
+		p.Observe(id, i, sectorRoot)
+	}
+	if !p.Random() {
+		t.Fatal("expected random access pattern")
+	} else if p.Enabled() {
+		t.Fatal("expected prefetching to be disabled")
+	}
+	p.Observe(id, 2, sectorRoot)
+	time.Sleep(100 * time.Millisecond)
+	if n, _ := vm.PrefetchStats(); n != prefetched {
+		t.Fatalf("expected no additional prefetches, got %v", n-prefetched)
+	}
+}
+
+func TestSectorReadAhead(t *testing.T) {
+	const (
+		sectors   = 20
+		readAhead = 4
+	)
+	dir := t.TempDir()
 
 	// create the database
-	log := zaptest.NewLogger(b)
+	log := zaptest.NewLogger(t)
 	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 	defer db.Close()
 
 	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 	defer g.Close()
 
 	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
 	select {
 	case err := <-errCh:
-		b.Fatal(err)
+		if err != nil {
+			t.Fatal(err)
+		}
 	default:
 	}
 	cm, err := chain.NewManager(cs)
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 	defer cm.Close()
 
 	// initialize the storage manager
 	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 
 	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
-	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectors, storage.WithReadAhead(readAhead), storage.WithEncryptionPassphrase("foo bar baz"))
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 	defer vm.Close()
 
-	b.ResetTimer()
-	b.ReportMetric(float64(sectors), "sectors")
-	b.SetBytes(sectors * rhp2.SectorSize)
-
 	result := make(chan error, 1)
-	for i := 0; i < b.N; i++ {
+	volumeFilePath := filepath.Join(t.TempDir(), "hostdata.dat")
+	if _, err := vm.AddVolume(context.Background(), volumeFilePath, sectors, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	roots := make([]types.Hash256, 0, sectors)
+	for i := 0; i < cap(roots); i++ {
+		root, err := storeRandomSector(vm, uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+
+	readSector := func(i int) {
+		t.Helper()
+		sector, err := vm.Read(roots[i])
+		if err != nil {
+			t.Fatal(err)
+		} else if rhp2.SectorRoot(sector) != roots[i] {
+			t.Fatalf("sector %v has the wrong root", i)
+		}
+	}
+
+	// clear the cache
+	vm.ResizeCache(0)
+	vm.ResizeCache(sectors)
+
+	// random reads should not read ahead
+	for _, i := range []int{19, 3, 15, 7, 11} {
+		readSector(i)
+	}
+	if n, hits := vm.ReadAheadStats(); n != 0 || hits != 0 {
+		t.Fatalf("expected no read-ahead, got %v sectors and %v hits", n, hits)
+	}
+
+	// the second sequential read should fill the buffer and the next sectors
+	// should be served from it
+	vm.ResizeCache(0)
+	vm.ResizeCache(sectors)
+	for i := 0; i < 2+readAhead-1; i++ {
+		readSector(i)
+	}
+	if n, hits := vm.ReadAheadStats(); n != readAhead-1 || hits != readAhead-1 {
+		t.Fatalf("expected %v sectors read ahead and %v hits, got %v and %v", readAhead-1, readAhead-1, n, hits)
+	}
+
+	// continuing the download should refill the buffer. Every sector after
+	// the first should either fill the buffer or be served from it; the last
+	// fill is truncated at the end of the volume.
+	for i := 2 + readAhead - 1; i < sectors; i++ {
+		readSector(i)
+	}
+	fills := (sectors - 1 + readAhead - 1) / readAhead
+	expected := uint64(sectors - 1 - fills)
+	n, hits := vm.ReadAheadStats()
+	if n != expected || hits != expected {
+		t.Fatalf("expected %v sectors read ahead and %v hits, got %v and %v", expected, expected, n, hits)
+	}
+
+	// the stats should be persisted
+	if err := vm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := db.Metrics(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	} else if m.Storage.SectorReadAheads != n || m.Storage.SectorReadAheadHits != hits {
+		t.Fatalf("expected %v sectors read ahead and %v hits, got %v and %v", n, hits, m.Storage.SectorReadAheads, m.Storage.SectorReadAheadHits)
+	}
+}
+
+func BenchmarkSequentialRead(b *testing.B) {
+	const sectors = 64
+
+	run := func(b *testing.B, prefetchDepth int) {
+		dir := b.TempDir()
+
+		// create the database
+		log := zaptest.NewLogger(b)
+		db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer db.Close()
+
+		g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer g.Close()
+
+		cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+		select {
+		case err := <-errCh:
+			b.Fatal(err)
+		default:
+		}
+		cm, err := chain.NewManager(cs)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer cm.Close()
+
+		// initialize the storage manager
+		webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+		vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectors, storage.WithPrefetchDepth(prefetchDepth))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer vm.Close()
+
+		result := make(chan error, 1)
 		volumeFilePath := filepath.Join(b.TempDir(), "hostdata.dat")
-		_, err = vm.AddVolume(context.Background(), volumeFilePath, sectors, result)
+		if _, err := vm.AddVolume(context.Background(), volumeFilePath, sectors, result); err != nil {
+			b.Fatal(err)
+		} else if err := <-result; err != nil {
+			b.Fatal(err)
+		}
+
+		roots := make([]types.Hash256, 0, sectors)
+		for i := 0; i < cap(roots); i++ {
+			root, err := storeRandomSector(vm, uint64(i))
+			if err != nil {
+				b.Fatal(err)
+			}
+			roots = append(roots, root)
+		}
+		sectorRoot := func(i uint64) (types.Hash256, error) {
+			if i >= uint64(len(roots)) {
+				return types.Hash256{}, errors.New("index out of range")
+			}
+			return roots[i], nil
+		}
+
+		var id types.FileContractID
+		b.ResetTimer()
+		b.ReportAllocs()
+		b.SetBytes(rhp2.SectorSize)
+
+		var p *storage.Prefetcher
+		for i := 0; i < b.N; i++ {
+			index := uint64(i % sectors)
+			if index == 0 {
+				// start a new download with a cold cache
+				b.StopTimer()
+				vm.ResizeCache(0)
+				vm.ResizeCache(sectors)
+				p = vm.Prefetcher()
+				b.StartTimer()
+			}
+
+			if _, err := vm.Read(roots[index]); err != nil {
+				b.Fatal(err)
+			}
+			p.Observe(id, index, sectorRoot)
+			// simulate the time spent sending the sector to the renter
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	b.Run("no prefetch", func(b *testing.B) { run(b, 0) })
+	b.Run("prefetch", func(b *testing.B) { run(b, 4) })
+}
+
+func BenchmarkSequentialMultiSectorRead(b *testing.B) {
+	const sectors = 64
+
+	run := func(b *testing.B, readAhead int) {
+		dir := b.TempDir()
+
+		// create the database
+		log := zaptest.NewLogger(b)
+		db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer db.Close()
+
+		g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
 		if err != nil {
 			b.Fatal(err)
+		}
+		defer g.Close()
+
+		cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+		select {
+		case err := <-errCh:
+			b.Fatal(err)
+		default:
+		}
+		cm, err := chain.NewManager(cs)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer cm.Close()
+
+		// initialize the storage manager
+		webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+		vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectors, storage.WithReadAhead(readAhead))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer vm.Close()
+
+		result := make(chan error, 1)
+		volumeFilePath := filepath.Join(b.TempDir(), "hostdata.dat")
+		if _, err := vm.AddVolume(context.Background(), volumeFilePath, sectors, result); err != nil {
+			b.Fatal(err)
 		} else if err := <-result; err != nil {
 			b.Fatal(err)
 		}
+
+		roots := make([]types.Hash256, 0, sectors)
+		for i := 0; i < cap(roots); i++ {
+			root, err := storeRandomSector(vm, uint64(i))
+			if err != nil {
+				b.Fatal(err)
+			}
+			roots = append(roots, root)
+		}
+
+		b.ResetTimer()
+		b.ReportAllocs()
+		b.SetBytes(rhp2.SectorSize)
+
+		for i := 0; i < b.N; i++ {
+			index := i % sectors
+			if index == 0 {
+				// start a new download with a cold cache
+				b.StopTimer()
+				vm.ResizeCache(0)
+				vm.ResizeCache(sectors)
+				b.StartTimer()
+			}
+
+			if _, err := vm.Read(roots[index]); err != nil {
+				b.Fatal(err)
+			}
+		}
 	}
+
+	b.Run("no read-ahead", func(b *testing.B) { run(b, 0) })
+	b.Run("read-ahead 4", func(b *testing.B) { run(b, 4) })
+	b.Run("read-ahead 16", func(b *testing.B) { run(b, 16) })
 }
 
-func BenchmarkVolumeManagerRead(b *testing.B) {
+func BenchmarkVolumeManagerWrite(b *testing.B) {
 	dir := b.TempDir()
 
 	// create the database
@@ -1638,33 +2243,89 @@ func BenchmarkVolumeManagerRead(b *testing.B) {
 		b.Fatal(err)
 	}
 
+	sectors := make([][rhp2.SectorSize]byte, b.N)
+	roots := make([]types.Hash256, b.N)
+	for i := range sectors {
+		frand.Read(sectors[i][:256])
+		roots[i] = rhp2.SectorRoot(&sectors[i])
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.SetBytes(rhp2.SectorSize)
+
 	// fill the volume
-	written := make([]types.Hash256, 0, b.N)
 	for i := 0; i < b.N; i++ {
-		var sector [rhp2.SectorSize]byte
-		frand.Read(sector[:256])
-		root := rhp2.SectorRoot(&sector)
+		root, sector := roots[i], sectors[i]
 		release, err := vm.Write(root, &sector)
 		if err != nil {
 			b.Fatal(i, err)
 		} else if err := release(); err != nil {
 			b.Fatal(i, err)
 		}
-		written = append(written, root)
 	}
+}
+
+func BenchmarkNewVolume(b *testing.B) {
+	const sectors = 100
+	dir := b.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(b)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		b.Fatal(err)
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer vm.Close()
 
 	b.ResetTimer()
-	b.ReportAllocs()
-	b.SetBytes(rhp2.SectorSize)
-	// read the sectors back
-	for _, root := range written {
-		if _, err := vm.Read(root); err != nil {
+	b.ReportMetric(float64(sectors), "sectors")
+	b.SetBytes(sectors * rhp2.SectorSize)
+
+	result := make(chan error, 1)
+	for i := 0; i < b.N; i++ {
+		volumeFilePath := filepath.Join(b.TempDir(), "hostdata.dat")
+		_, err = vm.AddVolume(context.Background(), volumeFilePath, sectors, result)
+		if err != nil {
+			b.Fatal(err)
+		} else if err := <-result; err != nil {
 			b.Fatal(err)
 		}
 	}
 }
 
-func BenchmarkVolumeRemove(b *testing.B) {
+func BenchmarkVolumeManagerRead(b *testing.B) {
 	dir := b.TempDir()
 
 	// create the database
@@ -1708,7 +2369,7 @@ func BenchmarkVolumeRemove(b *testing.B) {
 
 	result := make(chan error, 1)
 	volumeFilePath := filepath.Join(b.TempDir(), "hostdata.dat")
-	volume1, err := vm.AddVolume(context.Background(), volumeFilePath, uint64(b.N), result)
+	_, err = vm.AddVolume(context.Background(), volumeFilePath, uint64(b.N), result)
 	if err != nil {
 		b.Fatal(err)
 	} else if err := <-result; err != nil {
@@ -1716,6 +2377,7 @@ func BenchmarkVolumeRemove(b *testing.B) {
 	}
 
 	// fill the volume
+	written := make([]types.Hash256, 0, b.N)
 	for i := 0; i < b.N; i++ {
 		var sector [rhp2.SectorSize]byte
 		frand.Read(sector[:256])
@@ -1726,15 +2388,91 @@ func BenchmarkVolumeRemove(b *testing.B) {
 		} else if err := release(); err != nil {
 			b.Fatal(i, err)
 		}
-	}
-
-	// add a new volume
-	volume2FilePath := filepath.Join(b.TempDir(), "hostdata2.dat")
-	_, err = vm.AddVolume(context.Background(), volume2FilePath, uint64(b.N), result)
-	if err != nil {
-		b.Fatal(err)
-	} else if err := <-result; err != nil {
-		b.Fatal(err)
+		written = append(written, root)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.SetBytes(rhp2.SectorSize)
+	// read the sectors back
+	for _, root := range written {
+		if _, err := vm.Read(root); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVolumeRemove(b *testing.B) {
+	dir := b.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(b)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		b.Fatal(err)
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	volumeFilePath := filepath.Join(b.TempDir(), "hostdata.dat")
+	volume1, err := vm.AddVolume(context.Background(), volumeFilePath, uint64(b.N), result)
+	if err != nil {
+		b.Fatal(err)
+	} else if err := <-result; err != nil {
+		b.Fatal(err)
+	}
+
+	// fill the volume
+	for i := 0; i < b.N; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			b.Fatal(i, err)
+		} else if err := release(); err != nil {
+			b.Fatal(i, err)
+		}
+	}
+
+	// add a new volume
+	volume2FilePath := filepath.Join(b.TempDir(), "hostdata2.dat")
+	_, err = vm.AddVolume(context.Background(), volume2FilePath, uint64(b.N), result)
+	if err != nil {
+		b.Fatal(err)
+	} else if err := <-result; err != nil {
+		b.Fatal(err)
 	}
 
 	b.ResetTimer()
@@ -1748,3 +2486,2100 @@ func BenchmarkVolumeRemove(b *testing.B) {
 		b.Fatal(err)
 	}
 }
+
+// openVolumeFiles returns the number of open file descriptors that reference
+// files in dir. It returns -1 if the file descriptors cannot be listed.
+func openVolumeFiles(dir string) int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	var n int
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", entry.Name()))
+		if err == nil && filepath.Dir(target) == dir {
+			n++
+		}
+	}
+	return n
+}
+
+func TestVolumeHandleLimit(t *testing.T) {
+	const (
+		maxOpen          = 2
+		volumeCount      = 5
+		sectorsPerVolume = 4
+	)
+	dir := t.TempDir()
+	volumeDir := t.TempDir()
+	if openVolumeFiles(volumeDir) < 0 {
+		t.Skip("open file descriptors cannot be listed on this platform")
+	}
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithMaxOpenVolumes(maxOpen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	for i := 0; i < volumeCount; i++ {
+		result := make(chan error, 1)
+		if _, err := vm.AddVolume(context.Background(), filepath.Join(volumeDir, fmt.Sprintf("vol%d.dat", i)), sectorsPerVolume, result); err != nil {
+			t.Fatal(err)
+		} else if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkOpenFiles := func() {
+		t.Helper()
+		if n := openVolumeFiles(volumeDir); n > maxOpen {
+			t.Fatalf("expected at most %v open volume files, got %v", maxOpen, n)
+		}
+	}
+	checkOpenFiles()
+
+	// fill every volume so that sectors are spread across more volumes than
+	// can be open at once
+	sectors := make(map[types.Hash256]*[rhp2.SectorSize]byte)
+	for i := 0; i < volumeCount*sectorsPerVolume; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 1}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+		sectors[root] = &sector
+		checkOpenFiles()
+	}
+
+	// read every sector back, forcing evicted handles to be reopened
+	for i := 0; i < 2; i++ {
+		for root, expected := range sectors {
+			sector, err := vm.Read(root)
+			if err != nil {
+				t.Fatal(err)
+			} else if *sector != *expected {
+				t.Fatal("sector was corrupted")
+			}
+			checkOpenFiles()
+		}
+	}
+}
+
+func TestHotSectors(t *testing.T) {
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	if _, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata.dat"), 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// write sectors and read each one a different number of times
+	reads := []uint64{1, 5, 3}
+	roots := make([]types.Hash256, len(reads))
+	for i := range roots {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		roots[i] = rhp2.SectorRoot(&sector)
+		release, err := vm.Write(roots[i], &sector)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: roots[i], Expiration: 1}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if hot, err := vm.HotSectors(10); err != nil {
+		t.Fatal(err)
+	} else if len(hot) != 0 {
+		t.Fatalf("expected no hot sectors, got %v", len(hot))
+	}
+
+	readSectors := func() {
+		t.Helper()
+		for i, root := range roots {
+			for j := uint64(0); j < reads[i]; j++ {
+				if _, err := vm.Read(root); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	checkHotSectors := func(multiplier uint64) {
+		t.Helper()
+		hot, err := vm.HotSectors(2)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(hot) != 2 {
+			t.Fatalf("expected 2 hot sectors, got %v", len(hot))
+		} else if hot[0].Root != roots[1] || hot[0].Reads != reads[1]*multiplier {
+			t.Fatalf("expected sector %v with %v reads, got %v with %v reads", roots[1], reads[1]*multiplier, hot[0].Root, hot[0].Reads)
+		} else if hot[1].Root != roots[2] || hot[1].Reads != reads[2]*multiplier {
+			t.Fatalf("expected sector %v with %v reads, got %v with %v reads", roots[2], reads[2]*multiplier, hot[1].Root, hot[1].Reads)
+		}
+	}
+
+	readSectors()
+	checkHotSectors(1)
+
+	// counts should accumulate across flushes
+	readSectors()
+	checkHotSectors(2)
+}
+
+func TestSectorChecksums(t *testing.T) {
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	// disable the cache so every read hits the disk
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithSectorChecksums(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	volumePath := filepath.Join(t.TempDir(), "hostdata.dat")
+	result := make(chan error, 1)
+	if _, err := vm.AddVolume(context.Background(), volumePath, 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:])
+	root := rhp2.SectorRoot(&sector)
+	release, err := vm.Write(root, &sector)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 1}}); err != nil {
+		t.Fatal(err)
+	} else if err := release(); err != nil {
+		t.Fatal(err)
+	} else if err := vm.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the checksum should be stored when the sector is written
+	if _, ok, err := db.SectorChecksum(root); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected sector checksum to be stored")
+	} else if _, err := vm.Read(root); err != nil {
+		t.Fatal(err)
+	} else if err := vm.VerifySector(root); err != nil {
+		t.Fatal(err)
+	}
+
+	loc, unlock, err := db.SectorLocation(root)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(volumePath, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// flip a single byte in the sector's data
+	offset := int64(loc.Index*rhp2.SectorSize) + int64(frand.Intn(rhp2.SectorSize))
+	flipped := []byte{^sector[offset%rhp2.SectorSize]}
+	if _, err := f.WriteAt(flipped, offset); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vm.Read(root); !errors.Is(err, storage.ErrSectorCorrupt) {
+		t.Fatalf("expected ErrSectorCorrupt, got %v", err)
+	} else if err := vm.VerifySector(root); !errors.Is(err, storage.ErrSectorCorrupt) {
+		t.Fatalf("expected ErrSectorCorrupt, got %v", err)
+	}
+
+	// restore the byte
+	if _, err := f.WriteAt(sector[offset%rhp2.SectorSize:][:1], offset); err != nil {
+		t.Fatal(err)
+	}
+
+	// a stale checksum should fall back to the Merkle root and be repaired
+	checksum, _, err := db.SectorChecksum(root)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := db.SetSectorChecksum(root, checksum+1); err != nil {
+		t.Fatal(err)
+	} else if _, err := vm.Read(root); err != nil {
+		t.Fatal(err)
+	} else if repaired, _, err := db.SectorChecksum(root); err != nil {
+		t.Fatal(err)
+	} else if repaired != checksum {
+		t.Fatalf("expected checksum %v, got %v", checksum, repaired)
+	}
+}
+
+func TestSectorReservations(t *testing.T) {
+	const (
+		sectors      = 16
+		perUpload    = 4
+		uploads      = 10
+		maxSuccesses = sectors / perUpload
+	)
+	dir := t.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	volumeFilePath := filepath.Join(t.TempDir(), "hostdata.dat")
+	if _, err := vm.AddVolume(context.Background(), volumeFilePath, sectors, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// reserve space for more uploads than the volume can hold concurrently
+	var wg sync.WaitGroup
+	reservations := make(chan *storage.Reservation, uploads)
+	errs := make(chan error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := vm.ReserveSectors(perUpload)
+			if err != nil {
+				errs <- err
+				return
+			}
+			reservations <- r
+		}()
+	}
+	wg.Wait()
+	close(reservations)
+	close(errs)
+
+	if len(reservations) != maxSuccesses {
+		t.Fatalf("expected %v reservations, got %v", maxSuccesses, len(reservations))
+	}
+	for err := range errs {
+		if !errors.Is(err, storage.ErrNotEnoughStorage) {
+			t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+		}
+	}
+	if n := vm.Reserved(); n != sectors {
+		t.Fatalf("expected %v reserved sectors, got %v", sectors, n)
+	}
+
+	// unreserved writes should not be able to use reserved space
+	if _, err := storeRandomSector(vm, 0); !errors.Is(err, storage.ErrNotEnoughStorage) {
+		t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+	}
+
+	// releasing a reservation returns its space
+	var held []*storage.Reservation
+	for r := range reservations {
+		held = append(held, r)
+	}
+	held[0].Release()
+	held[0].Release() // releasing twice should be a no-op
+	held = held[1:]
+	if n := vm.Reserved(); n != sectors-perUpload {
+		t.Fatalf("expected %v reserved sectors, got %v", sectors-perUpload, n)
+	} else if _, err := storeRandomSector(vm, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// write every reserved sector concurrently
+	writeErrs := make(chan error, len(held)*perUpload)
+	for _, r := range held {
+		for i := 0; i < perUpload; i++ {
+			wg.Add(1)
+			go func(r *storage.Reservation) {
+				defer wg.Done()
+				var sector [rhp2.SectorSize]byte
+				frand.Read(sector[:256])
+				_, err := r.Write(rhp2.SectorRoot(&sector), &sector)
+				writeErrs <- err
+			}(r)
+		}
+	}
+	wg.Wait()
+	close(writeErrs)
+	for err := range writeErrs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := vm.Reserved(); n != 0 {
+		t.Fatalf("expected no reserved sectors, got %v", n)
+	} else if used, _, err := vm.Usage(); err != nil {
+		t.Fatal(err)
+	} else if used != 1+uint64(len(held)*perUpload) {
+		t.Fatalf("expected %v used sectors, got %v", 1+len(held)*perUpload, used)
+	}
+
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:256])
+	if _, err := held[0].Write(rhp2.SectorRoot(&sector), &sector); !errors.Is(err, storage.ErrReservationExhausted) {
+		t.Fatalf("expected ErrReservationExhausted, got %v", err)
+	}
+
+	// reserve the remaining space
+	free := sectors - (1 + uint64(len(held)*perUpload))
+	r, err := vm.ReserveSectors(free)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := r.Write(rhp2.SectorRoot(&sector), &sector); err != nil {
+		t.Fatal(err)
+	} else if n := r.Remaining(); n != free-1 {
+		t.Fatalf("expected %v remaining sectors, got %v", free-1, n)
+	}
+
+	// writing a stored sector does not need reserved space
+	if _, err := vm.Write(rhp2.SectorRoot(&sector), &sector); err != nil {
+		t.Fatal(err)
+	} else if _, err := r.Write(rhp2.SectorRoot(&sector), &sector); err != nil {
+		t.Fatal(err)
+	} else if n := r.Remaining(); n != free-1 {
+		t.Fatalf("expected %v remaining sectors, got %v", free-1, n)
+	} else if _, err := storeRandomSector(vm, 0); !errors.Is(err, storage.ErrNotEnoughStorage) {
+		t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+	} else if err := r.Reserve(1); !errors.Is(err, storage.ErrNotEnoughStorage) {
+		t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+	}
+
+	// released reservations cannot be extended or written to
+	r.Release()
+	if n := vm.Reserved(); n != 0 {
+		t.Fatalf("expected no reserved sectors, got %v", n)
+	} else if err := r.Reserve(1); !errors.Is(err, storage.ErrReservationClosed) {
+		t.Fatalf("expected ErrReservationClosed, got %v", err)
+	} else if _, err := r.Write(rhp2.SectorRoot(&sector), &sector); !errors.Is(err, storage.ErrReservationClosed) {
+		t.Fatalf("expected ErrReservationClosed, got %v", err)
+	}
+}
+
+func TestReservationExpiry(t *testing.T) {
+	const (
+		sectors = 8
+		ttl     = 500 * time.Millisecond
+	)
+	dir := t.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectorCacheSize, storage.WithReservationTTL(ttl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	first, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata.dat"), sectors, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// using a reservation should reset its TTL
+	r, err := vm.ReserveSectors(sectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(ttl / 2)
+	if err := r.Reserve(0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(ttl / 2)
+	if n := vm.Reserved(); n != sectors {
+		t.Fatalf("expected %v reserved sectors, got %v", sectors, n)
+	}
+
+	// an idle reservation should be released
+	time.Sleep(2 * ttl)
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:256])
+	if n := vm.Reserved(); n != 0 {
+		t.Fatalf("expected no reserved sectors, got %v", n)
+	} else if err := r.Reserve(1); !errors.Is(err, storage.ErrReservationExpired) {
+		t.Fatalf("expected ErrReservationExpired, got %v", err)
+	} else if _, err := r.Write(rhp2.SectorRoot(&sector), &sector); !errors.Is(err, storage.ErrReservationExpired) {
+		t.Fatalf("expected ErrReservationExpired, got %v", err)
+	}
+	r.Release() // releasing an expired reservation should be a no-op
+
+	// space on a read-only volume cannot be reserved
+	if r, err := vm.ReserveSectors(1); err != nil {
+		t.Fatal(err)
+	} else {
+		r.Release()
+	}
+	if err := vm.SetReadOnly(first.ID, true); err != nil {
+		t.Fatal(err)
+	} else if _, err := vm.ReserveSectors(1); !errors.Is(err, storage.ErrNotEnoughStorage) {
+		t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+	}
+
+	// a new writable volume's space can be reserved
+	if _, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata2.dat"), sectors, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	} else if r, err := vm.ReserveSectors(sectors); err != nil {
+		t.Fatal(err)
+	} else {
+		r.Release()
+	}
+}
+
+func TestVolumeEncryption(t *testing.T) {
+	const (
+		sectors    = 8
+		passphrase = "correct horse battery staple"
+	)
+	dir := t.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithEncryptionPassphrase(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	volumeFilePath := filepath.Join(t.TempDir(), "hostdata.dat")
+	vol, err := vm.AddVolume(context.Background(), volumeFilePath, sectors, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	if vol, err := vm.Volume(vol.ID); err != nil {
+		t.Fatal(err)
+	} else if !vol.Encrypted {
+		t.Fatal("expected volume to be encrypted")
+	}
+
+	sectorData := make(map[types.Hash256]*[rhp2.SectorSize]byte)
+	for i := 0; i < sectors/2; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 100}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+		sectorData[root] = &sector
+	}
+	if err := vm.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the data on disk should not contain the plaintext
+	raw, err := os.ReadFile(volumeFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for root, sector := range sectorData {
+		loc, release, err := db.SectorLocation(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		onDisk := raw[loc.Index*rhp2.SectorSize : (loc.Index+1)*rhp2.SectorSize]
+		if bytes.Equal(onDisk[:1024], sector[:1024]) {
+			t.Fatalf("sector %v stored in plaintext", root)
+		}
+	}
+
+	checkSectors := func(vm *storage.VolumeManager) {
+		t.Helper()
+		for root, sector := range sectorData {
+			read, err := vm.Read(root)
+			if err != nil {
+				t.Fatal(err)
+			} else if *read != *sector {
+				t.Fatal("sector data mismatch")
+			} else if rhp2.SectorRoot(read) != root {
+				t.Fatal("sector root mismatch")
+			}
+
+			// proofs are built over the plaintext and should validate
+			// against the sector root
+			const start, end = 10, 20
+			proof := rhp2.BuildProof(read, start, end, nil)
+			verifier := rhp2.NewRangeProofVerifier(start, end)
+			if _, err := verifier.ReadFrom(bytes.NewReader(read[start*rhp2.LeafSize : end*rhp2.LeafSize])); err != nil {
+				t.Fatal(err)
+			} else if !verifier.Verify(proof, root) {
+				t.Fatal("proof verification failed")
+			}
+		}
+	}
+	checkSectors(vm)
+
+	if err := vm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// encrypted volumes cannot be opened without the correct passphrase
+	for _, opts := range [][]storage.Option{nil, {storage.WithEncryptionPassphrase("wrong passphrase")}} {
+		vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vol, err := vm.Volume(vol.ID); err != nil {
+			t.Fatal(err)
+		} else if vol.Available {
+			t.Fatal("expected volume to be unavailable")
+		}
+		for root := range sectorData {
+			if _, err := vm.Read(root); err == nil {
+				t.Fatal("expected read to fail")
+			}
+			break
+		}
+		vm.Close()
+	}
+
+	// the correct passphrase should open the volume
+	vm, err = storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithEncryptionPassphrase(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	if vol, err := vm.Volume(vol.ID); err != nil {
+		t.Fatal(err)
+	} else if !vol.Available {
+		t.Fatal("expected volume to be available")
+	}
+	checkSectors(vm)
+}
+
+func TestStorageErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	// disable the cache so every read hits the disk
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	volumePath := filepath.Join(t.TempDir(), "hostdata.dat")
+	result := make(chan error, 1)
+	vol, err := vm.AddVolume(context.Background(), volumePath, 10, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// a missing sector should be reported with its root
+	missing := frand.Entropy256()
+	var sectorErr *storage.SectorError
+	if _, err := vm.Read(missing); !errors.As(err, &sectorErr) {
+		t.Fatalf("expected SectorError, got %v", err)
+	} else if sectorErr.Root != missing {
+		t.Fatalf("expected root %v, got %v", missing, sectorErr.Root)
+	} else if !errors.Is(err, storage.ErrSectorNotFound) {
+		t.Fatalf("expected ErrSectorNotFound, got %v", err)
+	}
+
+	// an unknown volume should be reported with its ID and operation
+	var volumeErr *storage.VolumeError
+	if err := vm.SetReadOnly(vol.ID+1, true); !errors.As(err, &volumeErr) {
+		t.Fatalf("expected VolumeError, got %v", err)
+	} else if volumeErr.VolumeID != vol.ID+1 || volumeErr.Op != storage.VolumeOpSetReadOnly {
+		t.Fatalf("unexpected volume error: %+v", volumeErr)
+	} else if !errors.Is(err, storage.ErrVolumeNotFound) {
+		t.Fatalf("expected ErrVolumeNotFound, got %v", err)
+	}
+
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:])
+	root := rhp2.SectorRoot(&sector)
+	release, err := vm.Write(root, &sector)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 1}}); err != nil {
+		t.Fatal(err)
+	} else if err := release(); err != nil {
+		t.Fatal(err)
+	} else if err := vm.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a corrupt sector should be reported with its root
+	loc, unlock, err := db.SectorLocation(root)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(volumePath, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	offset := int64(loc.Index * rhp2.SectorSize)
+	if _, err := f.WriteAt([]byte{^sector[0]}, offset); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.VerifySector(root); !errors.As(err, &sectorErr) {
+		t.Fatalf("expected SectorError, got %v", err)
+	} else if sectorErr.Root != root {
+		t.Fatalf("expected root %v, got %v", root, sectorErr.Root)
+	} else if !errors.Is(err, storage.ErrSectorCorrupt) {
+		t.Fatalf("expected ErrSectorCorrupt, got %v", err)
+	}
+
+	// a failed read should be reported with both the sector and the volume
+	if err := f.Truncate(0); err != nil {
+		t.Fatal(err)
+	} else if _, err := vm.Read(root); !errors.As(err, &sectorErr) {
+		t.Fatalf("expected SectorError, got %v", err)
+	} else if !errors.As(err, &volumeErr) {
+		t.Fatalf("expected VolumeError, got %v", err)
+	} else if volumeErr.VolumeID != vol.ID || volumeErr.Op != storage.VolumeOpRead {
+		t.Fatalf("unexpected volume error: %+v", volumeErr)
+	}
+}
+
+func TestZeroDeletedSectors(t *testing.T) {
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	volumePath := filepath.Join(t.TempDir(), "hostdata.dat")
+	result := make(chan error, 1)
+	if _, err := vm.AddVolume(context.Background(), volumePath, 10, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// writeAndPrune writes a random sector and releases it without adding a
+	// reference so it is immediately pruned
+	writeAndPrune := func() (storage.SectorLocation, []byte) {
+		t.Helper()
+
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := vm.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		loc, unlock, err := db.SectorLocation(root)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := unlock(); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+		return loc, sector[:]
+	}
+
+	readLocation := func(loc storage.SectorLocation) []byte {
+		t.Helper()
+
+		buf := make([]byte, rhp2.SectorSize)
+		f, err := os.Open(volumePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.ReadAt(buf, int64(loc.Index*rhp2.SectorSize)); err != nil {
+			t.Fatal(err)
+		}
+		return buf
+	}
+
+	// by default, only the metadata of a pruned sector is removed
+	loc, data := writeAndPrune()
+	if pending, err := db.ZeroPendingLocations(0, 100); err != nil {
+		t.Fatal(err)
+	} else if len(pending) != 0 {
+		t.Fatalf("expected no locations pending zeroing, got %v", pending)
+	} else if !bytes.Equal(readLocation(loc), data) {
+		t.Fatal("expected sector data to remain in the volume")
+	}
+
+	s := settings.DefaultSettings
+	s.ZeroDeletedSectors = true
+	if err := db.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	loc, data = writeAndPrune()
+	if pending, err := db.ZeroPendingLocations(0, 100); err != nil {
+		t.Fatal(err)
+	} else if len(pending) != 1 || pending[0].ID != loc.ID {
+		t.Fatalf("expected location %v to be pending zeroing, got %v", loc.ID, pending)
+	} else if !bytes.Equal(readLocation(loc), data) {
+		t.Fatal("expected sector data to remain in the volume until it is zeroed")
+	}
+
+	// trigger a cleanup to zero the freed sector
+	vm.ProcessConsensusChange(modules.ConsensusChange{})
+	for i := 0; i < 100; i++ {
+		pending, err := db.ZeroPendingLocations(0, 100)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(pending) == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if pending, err := db.ZeroPendingLocations(0, 100); err != nil {
+		t.Fatal(err)
+	} else if len(pending) != 0 {
+		t.Fatalf("expected no locations pending zeroing, got %v", pending)
+	} else if !bytes.Equal(readLocation(loc), make([]byte, rhp2.SectorSize)) {
+		t.Fatal("expected freed sector to be zeroed")
+	}
+}
+
+func TestMaxVolumes(t *testing.T) {
+	const maxVolumes = 3
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithMaxVolumes(maxVolumes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	addVolume := func() (storage.Volume, error) {
+		result := make(chan error, 1)
+		vol, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata.dat"), 1, result)
+		if err != nil {
+			return storage.Volume{}, err
+		}
+		return vol, <-result
+	}
+
+	// add volumes up to the limit
+	var volumes []storage.Volume
+	for i := 0; i < maxVolumes; i++ {
+		vol, err := addVolume()
+		if err != nil {
+			t.Fatal(err)
+		}
+		volumes = append(volumes, vol)
+
+		if count, limit := vm.VolumeCount(); count != i+1 {
+			t.Fatalf("expected %v volumes, got %v", i+1, count)
+		} else if limit != maxVolumes {
+			t.Fatalf("expected limit %v, got %v", maxVolumes, limit)
+		}
+	}
+
+	// adding another volume should fail
+	var limitErr *storage.VolumeLimitError
+	if _, err := addVolume(); !errors.As(err, &limitErr) {
+		t.Fatalf("expected VolumeLimitError, got %v", err)
+	} else if limitErr.Volumes != maxVolumes || limitErr.Limit != maxVolumes {
+		t.Fatalf("unexpected limit error: %+v", limitErr)
+	} else if dbVolumes, err := db.Volumes(); err != nil {
+		t.Fatal(err)
+	} else if len(dbVolumes) != maxVolumes {
+		t.Fatalf("expected %v volumes in the database, got %v", maxVolumes, len(dbVolumes))
+	}
+
+	// removing a volume should make room for another
+	result := make(chan error, 1)
+	if err := vm.RemoveVolume(context.Background(), volumes[0].ID, false, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	} else if _, err := addVolume(); err != nil {
+		t.Fatal(err)
+	} else if count, _ := vm.VolumeCount(); count != maxVolumes {
+		t.Fatalf("expected %v volumes, got %v", maxVolumes, count)
+	}
+}
+
+func TestSectorReplication(t *testing.T) {
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	provider := &memProvider{backends: make(map[string]*memBackend)}
+	// disable the cache so every read hits the backend
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithBackendProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	for _, location := range []string{"mem://first", "mem://second"} {
+		result := make(chan error, 1)
+		vol, err := vm.AddVolume(context.Background(), location, 10, result)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := <-result; err != nil {
+			t.Fatal(err)
+		} else if err := vm.SetReplicaGroup(vol.ID, "group"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:256])
+	root := rhp2.SectorRoot(&sector)
+	release, err := vm.Write(root, &sector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 100}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sector should be stored in both volumes
+	primary, unlock, err := db.SectorLocation(root)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	replica, err := db.SectorReplica(root)
+	if err != nil {
+		t.Fatal(err)
+	} else if replica.Volume == primary.Volume {
+		t.Fatal("expected replica to be stored in a different volume")
+	}
+
+	volumeBackend := func(id int64) *memBackend {
+		t.Helper()
+		vol, err := vm.Volume(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mb, ok := provider.backend(vol.LocalPath)
+		if !ok {
+			t.Fatalf("backend %q not found", vol.LocalPath)
+		}
+		return mb
+	}
+
+	volumes, err := vm.Volumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vol := range volumes {
+		if vol.UsedSectors != 1 {
+			t.Fatalf("expected volume %v to have 1 used sector, got %v", vol.ID, vol.UsedSectors)
+		} else if vol.ReplicaGroup != "group" {
+			t.Fatalf("expected volume %v to be in replica group, got %q", vol.ID, vol.ReplicaGroup)
+		}
+	}
+
+	// make the primary unreadable, the read should be served from the replica
+	volumeBackend(primary.Volume).failReads(errors.New("read failed"))
+	if buf, err := vm.Read(root); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(buf[:], sector[:]) {
+		t.Fatal("sector data mismatch")
+	}
+
+	// without a replica, the read should fail
+	volumeBackend(replica.Volume).failReads(errors.New("read failed"))
+	if _, err := vm.Read(root); err == nil {
+		t.Fatal("expected read to fail")
+	}
+	volumeBackend(primary.Volume).failReads(nil)
+	volumeBackend(replica.Volume).failReads(nil)
+
+	// removing the sector should free both copies
+	if err := vm.RemoveSector(root); err != nil {
+		t.Fatal(err)
+	} else if _, err := db.SectorReplica(root); !errors.Is(err, storage.ErrSectorNotFound) {
+		t.Fatalf("expected ErrSectorNotFound, got %v", err)
+	}
+	var zeroes [rhp2.SectorSize]byte
+	for _, loc := range []storage.SectorLocation{primary, replica} {
+		if buf, err := volumeBackend(loc.Volume).ReadSector(loc.Index); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(buf[:], zeroes[:]) {
+			t.Fatalf("expected sector in volume %v to be zeroed", loc.Volume)
+		}
+	}
+
+	volumes, err = vm.Volumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vol := range volumes {
+		if vol.UsedSectors != 0 {
+			t.Fatalf("expected volume %v to have 0 used sectors, got %v", vol.ID, vol.UsedSectors)
+		}
+	}
+}
+
+func TestVerifyVolumes(t *testing.T) {
+	const volumeSectors = 8
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	volumePath := filepath.Join(t.TempDir(), "hostdata.dat")
+	result := make(chan error, 1)
+	vol, err := vm.AddVolume(context.Background(), volumePath, volumeSectors, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// write a few sectors, keeping them locked so they are not pruned
+	for i := 0; i < 3; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:])
+		release, err := vm.Write(rhp2.SectorRoot(&sector), &sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+	}
+	if err := vm.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkIssues := func(level storage.VerifyLevel, expected int) []storage.VolumeIssue {
+		t.Helper()
+
+		issues, err := vm.VerifyVolumes(context.Background(), level)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(issues) != expected {
+			t.Fatalf("expected %d %s verification issues, got %v", expected, level, issues)
+		}
+		for _, issue := range issues {
+			if issue.VolumeID != vol.ID {
+				t.Fatalf("expected issue for volume %d, got %d", vol.ID, issue.VolumeID)
+			} else if issue.Severity != alerts.SeverityError {
+				t.Fatalf("expected error severity, got %v", issue.Severity)
+			}
+		}
+		return issues
+	}
+
+	// an untouched volume should pass both levels
+	checkIssues(storage.VerifyLevelNone, 0)
+	checkIssues(storage.VerifyLevelQuick, 0)
+	checkIssues(storage.VerifyLevelFull, 0)
+
+	// overwrite part of a stored sector
+	sectors, err := db.VolumeSectors(vol.ID, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(sectors) != 1 {
+		t.Fatalf("expected 1 sector, got %d", len(sectors))
+	}
+	f, err := os.OpenFile(volumePath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(frand.Bytes(64), int64(sectors[0].Index*rhp2.SectorSize)); err != nil {
+		t.Fatal(err)
+	} else if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the quick check does not read sector data, the full check should find
+	// the corrupt sector
+	checkIssues(storage.VerifyLevelQuick, 0)
+	checkIssues(storage.VerifyLevelFull, 1)
+
+	// truncate the volume file
+	if err := os.Truncate(volumePath, (volumeSectors-2)*rhp2.SectorSize); err != nil {
+		t.Fatal(err)
+	}
+	checkIssues(storage.VerifyLevelQuick, 1)
+
+	if _, err := vm.VerifyVolumes(context.Background(), "thorough"); err == nil {
+		t.Fatal("expected invalid verification level to be rejected")
+	}
+}
+
+func TestVolumeThroughput(t *testing.T) {
+	const sectors = 10
+	dir := t.TempDir()
+
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	// disable the cache so every read goes to disk
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	vol, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata.dat"), sectors, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	checkIO := func(read, written uint64) {
+		t.Helper()
+
+		throughput, err := vm.VolumeThroughput(vol.ID, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		} else if throughput.BytesRead != read {
+			t.Fatalf("expected %v bytes read in window, got %v", read, throughput.BytesRead)
+		} else if throughput.BytesWritten != written {
+			t.Fatalf("expected %v bytes written in window, got %v", written, throughput.BytesWritten)
+		} else if rate := float64(written) / time.Hour.Seconds(); throughput.WriteRate != rate {
+			t.Fatalf("expected write rate %v, got %v", rate, throughput.WriteRate)
+		}
+
+		// the cumulative counters are persisted when the throughput is
+		// flushed
+		meta, err := vm.Volume(vol.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if meta.BytesRead != read {
+			t.Fatalf("expected %v total bytes read, got %v", read, meta.BytesRead)
+		} else if meta.BytesWritten != written {
+			t.Fatalf("expected %v total bytes written, got %v", written, meta.BytesWritten)
+		}
+	}
+
+	checkIO(0, 0)
+
+	var roots []types.Hash256
+	for i := 0; i < sectors; i++ {
+		root, err := storeRandomSector(vm, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	checkIO(0, sectors*rhp2.SectorSize)
+
+	for _, root := range roots[:5] {
+		if _, err := vm.Read(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkIO(5*rhp2.SectorSize, sectors*rhp2.SectorSize)
+
+	if _, err := vm.VolumeThroughput(vol.ID, 48*time.Hour); !errors.Is(err, storage.ErrInvalidThroughputWindow) {
+		t.Fatalf("expected ErrInvalidThroughputWindow, got %v", err)
+	} else if _, err := vm.VolumeThroughput(vol.ID+1, time.Hour); !errors.Is(err, storage.ErrVolumeNotFound) {
+		t.Fatalf("expected ErrVolumeNotFound, got %v", err)
+	}
+}
+
+func TestSectorCacheMemoryLimit(t *testing.T) {
+	const (
+		sectors    = 10
+		maxSectors = 3
+	)
+	dir := t.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	// the cache size allows every sector to be cached, but the memory limit
+	// only allows maxSectors
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), sectors, storage.WithCacheMemory(storage.CacheMemoryConfig{
+		MaxBytes: maxSectors*rhp2.SectorSize + rhp2.SectorSize/2,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	if _, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata.dat"), sectors, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	roots := make([]types.Hash256, 0, sectors)
+	for i := 0; i < cap(roots); i++ {
+		root, err := storeRandomSector(vm, uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+
+	stats := vm.SectorCacheStats()
+	if stats.Capacity != maxSectors {
+		t.Fatalf("expected capacity %v, got %v", maxSectors, stats.Capacity)
+	} else if stats.Sectors != maxSectors {
+		t.Fatalf("expected %v cached sectors, got %v", maxSectors, stats.Sectors)
+	} else if stats.MemoryBytes != maxSectors*rhp2.SectorSize {
+		t.Fatalf("expected %v bytes cached, got %v", maxSectors*rhp2.SectorSize, stats.MemoryBytes)
+	}
+
+	// only the most recently written sectors should be cached
+	for _, root := range roots[sectors-maxSectors:] {
+		if _, err := vm.Read(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits, misses := vm.CacheStats(); hits != maxSectors || misses != 0 {
+		t.Fatalf("expected %v hits and 0 misses, got %v hits and %v misses", maxSectors, hits, misses)
+	}
+
+	// increasing the cache size should not exceed the memory limit
+	vm.ResizeCache(sectors * 2)
+	if stats := vm.SectorCacheStats(); stats.Capacity != maxSectors {
+		t.Fatalf("expected capacity %v, got %v", maxSectors, stats.Capacity)
+	}
+
+	// releasing memory should evict the least recently used half of the
+	// cache
+	if evicted := vm.ReleaseCacheMemory(); evicted != 2 {
+		t.Fatalf("expected 2 sectors evicted, got %v", evicted)
+	}
+	stats = vm.SectorCacheStats()
+	if stats.Sectors != 1 {
+		t.Fatalf("expected 1 cached sector, got %v", stats.Sectors)
+	} else if stats.MemoryBytes != rhp2.SectorSize {
+		t.Fatalf("expected %v bytes cached, got %v", rhp2.SectorSize, stats.MemoryBytes)
+	} else if stats.PressureEvictions != 2 {
+		t.Fatalf("expected 2 pressure evictions, got %v", stats.PressureEvictions)
+	}
+
+	// the most recently used sector should still be cached
+	if _, err := vm.Read(roots[sectors-1]); err != nil {
+		t.Fatal(err)
+	} else if hits, _ := vm.CacheStats(); hits != maxSectors+1 {
+		t.Fatalf("expected %v hits, got %v", maxSectors+1, hits)
+	}
+}
+
+func TestRelinkMovedVolume(t *testing.T) {
+	const (
+		totalSectors = 8
+		usedSectors  = 4
+	)
+	dir := t.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	oldPath := filepath.Join(t.TempDir(), "hostdata.dat")
+	result := make(chan error, 1)
+	volume, err := vm.AddVolume(context.Background(), oldPath, totalSectors, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	roots := make([]types.Hash256, 0, usedSectors)
+	for i := 0; i < usedSectors; i++ {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		root := rhp2.SectorRoot(&sector)
+		release, err := vm.Write(root, &sector)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := vm.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 1}}); err != nil { // must add a temp sector to prevent pruning
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+
+	if err := vm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// move the volume file into the search directory and add a file of the
+	// same size that is not the volume
+	searchDir := t.TempDir()
+	newPath := filepath.Join(searchDir, "moved.dat")
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	} else if f, err := os.Create(filepath.Join(searchDir, "decoy.dat")); err != nil {
+		t.Fatal(err)
+	} else if err := f.Truncate(totalSectors * rhp2.SectorSize); err != nil {
+		t.Fatal(err)
+	} else if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	hasAlert := func(message, key, value string) bool {
+		for _, a := range am.Active() {
+			if a.Message == message && fmt.Sprint(a.Data[key]) == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	reopen := func(auto bool) storage.VolumeMeta {
+		t.Helper()
+		vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithRelink(storage.RelinkConfig{
+			SearchDirs: []string{searchDir},
+			Auto:       auto,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer vm.Close()
+
+		vol, err := vm.Volume(volume.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vol.LocalPath == newPath {
+			for _, root := range roots {
+				if _, err := vm.Read(root); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		return vol
+	}
+
+	// without automatic relinking, the match is only reported
+	if vol := reopen(false); vol.LocalPath != oldPath {
+		t.Fatalf("expected path %q, got %q", oldPath, vol.LocalPath)
+	} else if !hasAlert("Found moved volume file", "match", newPath) {
+		t.Fatal("expected moved volume alert")
+	}
+
+	// a copy of the volume makes the match ambiguous
+	copyPath := filepath.Join(searchDir, "copy.dat")
+	src, err := os.Open(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := os.Create(copyPath)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	} else if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := src.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if vol := reopen(true); vol.LocalPath != oldPath {
+		t.Fatalf("expected path %q, got %q", oldPath, vol.LocalPath)
+	} else if !hasAlert("Multiple files match missing volume", "volume", oldPath) {
+		t.Fatal("expected ambiguous match alert")
+	}
+
+	// with a single match the volume is relinked
+	if err := os.Remove(copyPath); err != nil {
+		t.Fatal(err)
+	}
+	if vol := reopen(true); vol.LocalPath != newPath {
+		t.Fatalf("expected path %q, got %q", newPath, vol.LocalPath)
+	} else if vol.Status != storage.VolumeStatusReady {
+		t.Fatalf("expected volume to be ready, got %q", vol.Status)
+	} else if !hasAlert("Relinked moved volume file", "newPath", newPath) {
+		t.Fatal("expected relink alert")
+	}
+
+	// the new path is persisted
+	if vol := reopen(false); vol.LocalPath != newPath {
+		t.Fatalf("expected path %q, got %q", newPath, vol.LocalPath)
+	} else if vol.Status != storage.VolumeStatusReady {
+		t.Fatalf("expected volume to be ready, got %q", vol.Status)
+	}
+}
+
+func TestVolumeProgress(t *testing.T) {
+	const volumeSectors = 16
+	dir := t.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	// trackProgress returns a context that records the reported progress
+	// and a function that checks intermediate and final progress were
+	// reported
+	trackProgress := func() (context.Context, func(total uint64)) {
+		var mu sync.Mutex
+		var reports [][2]uint64
+		ctx := storage.WithProgress(context.Background(), func(progress, total uint64) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, [2]uint64{progress, total})
+		})
+		return ctx, func(total uint64) {
+			t.Helper()
+			mu.Lock()
+			defer mu.Unlock()
+			var intermediate bool
+			for _, r := range reports {
+				if r[1] != total {
+					t.Fatalf("expected total %v, got %v", total, r[1])
+				} else if r[0] > 0 && r[0] < total {
+					intermediate = true
+				}
+			}
+			if !intermediate {
+				t.Fatalf("expected intermediate progress, got %v", reports)
+			} else if last := reports[len(reports)-1]; last[0] != total {
+				t.Fatalf("expected final progress %v, got %v", total, last[0])
+			}
+		}
+	}
+
+	// initializing the volume reports the sectors added
+	result := make(chan error, 1)
+	ctx, checkProgress := trackProgress()
+	volume, err := vm.AddVolume(ctx, filepath.Join(t.TempDir(), "hostdata.dat"), volumeSectors, result)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(volumeSectors)
+
+	// fill half of the volume
+	for i := 0; i < volumeSectors/2; i++ {
+		if _, err := storeRandomSector(vm, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// add a second volume to migrate the sectors to
+	if _, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata2.dat"), volumeSectors, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// shrinking the volume reports the sectors migrated and removed
+	const shrunkSectors = 2
+	ctx, checkProgress = trackProgress()
+	if err := vm.ResizeVolume(ctx, volume.ID, shrunkSectors, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(volumeSectors/2 + volumeSectors - shrunkSectors)
+
+	// removing the volume reports the sectors migrated
+	ctx, checkProgress = trackProgress()
+	if err := vm.RemoveVolume(ctx, volume.ID, false, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(shrunkSectors)
+}
+
+func TestWritePacked(t *testing.T) {
+	const maxPayload = 64 * 1024
+	dir := t.TempDir()
+
+	// create the database
+	log := zaptest.NewLogger(t)
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	g, err := gateway.New(":0", false, filepath.Join(dir, "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	cs, errCh := consensus.New(g, false, filepath.Join(dir, "consensus"))
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+	cm, err := chain.NewManager(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+
+	// initialize the storage manager
+	webhookReporter, err := webhooks.NewManager(db, log.Named("webhooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := alerts.NewManager(webhookReporter, log.Named("alerts"))
+	vm, err := storage.NewVolumeManager(db, am, cm, log.Named("volumes"), 0, storage.WithPacking(storage.PackingConfig{MaxPayloadSize: maxPayload}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vm.Close()
+
+	result := make(chan error, 1)
+	if _, err := vm.AddVolume(context.Background(), filepath.Join(t.TempDir(), "hostdata.dat"), 8, result); err != nil {
+		t.Fatal(err)
+	} else if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	checkUsed := func(expected uint64) {
+		t.Helper()
+		if used, _, err := vm.Usage(); err != nil {
+			t.Fatal(err)
+		} else if used != expected {
+			t.Fatalf("expected %v used sectors, got %v", expected, used)
+		}
+	}
+
+	// create several small sectors, as if uploaded to different contracts
+	sizes := []int{1, 1000, 4096, 10000, 30000, maxPayload}
+	sectors := make([]*[rhp2.SectorSize]byte, len(sizes))
+	for i, size := range sizes {
+		sectors[i] = new([rhp2.SectorSize]byte)
+		frand.Read(sectors[i][:size])
+		sectors[i][size-1] = 1 // ensure the payload is not trimmed
+	}
+
+	packed, err := vm.WritePacked(sectors)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(packed) != len(sectors) {
+		t.Fatalf("expected %v packed sectors, got %v", len(sectors), len(packed))
+	}
+	checkUsed(1)
+
+	// every payload shares one physical sector without overlapping
+	physicalRoot := packed[0].PhysicalRoot
+	var end uint64
+	for i, ps := range packed {
+		if ps.Root != rhp2.SectorRoot(sectors[i]) {
+			t.Fatalf("packed sector %d has wrong root", i)
+		} else if ps.PhysicalRoot != physicalRoot {
+			t.Fatalf("packed sector %d is in a different physical sector", i)
+		} else if ps.Offset != end {
+			t.Fatalf("packed sector %d has offset %d, expected %d", i, ps.Offset, end)
+		} else if ps.Length%rhp2.LeafSize != 0 || ps.Length < uint64(sizes[i]) {
+			t.Fatalf("packed sector %d has length %d for a %d byte payload", i, ps.Length, sizes[i])
+		}
+		end += ps.Length
+	}
+
+	readAll := func() {
+		t.Helper()
+		for i, ps := range packed {
+			sector, err := vm.ReadPacked(ps.Root)
+			if err != nil {
+				t.Fatal(err)
+			} else if *sector != *sectors[i] {
+				t.Fatalf("packed sector %d does not match", i)
+			}
+		}
+	}
+	readAll()
+
+	checkPackedRefs := func(expected int) {
+		t.Helper()
+		if refs, err := db.SectorReferences(physicalRoot); err != nil {
+			t.Fatal(err)
+		} else if refs.Packed != expected {
+			t.Fatalf("expected %v packed references, got %v", expected, refs.Packed)
+		}
+	}
+	checkPackedRefs(len(sectors))
+
+	// writing a packed sector again adds a reference instead of another copy
+	if again, err := vm.WritePacked(sectors[:1]); err != nil {
+		t.Fatal(err)
+	} else if again[0] != packed[0] {
+		t.Fatalf("expected %v, got %v", packed[0], again[0])
+	}
+	checkUsed(1)
+	checkPackedRefs(len(sectors) + 1)
+
+	// sectors with large payloads are not packed
+	var large [rhp2.SectorSize]byte
+	frand.Read(large[:maxPayload+1])
+	large[maxPayload] = 1
+	if _, err := vm.WritePacked([]*[rhp2.SectorSize]byte{&large}); !errors.Is(err, storage.ErrPayloadTooLarge) {
+		t.Fatalf("expected ErrPayloadTooLarge, got %v", err)
+	}
+
+	// the shared sector is kept until every reference is removed
+	for i := range packed {
+		if err := vm.RemovePacked(packed[i].Root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkUsed(1)
+	checkPackedRefs(1)
+	if sector, err := vm.ReadPacked(packed[0].Root); err != nil {
+		t.Fatal(err)
+	} else if *sector != *sectors[0] {
+		t.Fatal("packed sector does not match")
+	} else if _, err := vm.ReadPacked(packed[1].Root); !errors.Is(err, storage.ErrSectorNotFound) {
+		t.Fatalf("expected ErrSectorNotFound, got %v", err)
+	}
+
+	if err := vm.RemovePacked(packed[0].Root); err != nil {
+		t.Fatal(err)
+	}
+	checkUsed(0)
+	if _, err := vm.ReadPacked(packed[0].Root); !errors.Is(err, storage.ErrSectorNotFound) {
+		t.Fatalf("expected ErrSectorNotFound, got %v", err)
+	} else if err := vm.RemovePacked(packed[0].Root); !errors.Is(err, storage.ErrSectorNotFound) {
+		t.Fatalf("expected ErrSectorNotFound, got %v", err)
+	}
+}
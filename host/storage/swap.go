@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.uber.org/zap"
+)
+
+// swapBatchSize is the number of sectors checked at a time when verifying a
+// replacement backing file.
+const swapBatchSize = 256
+
+// ErrBackingFileMismatch is returned when a replacement backing file does not
+// match the volume it should replace.
+var ErrBackingFileMismatch = errors.New("backing file does not match volume")
+
+// swapBackend replaces the volume's backend and location and returns the
+// previous backend.
+func (v *volume) swapBackend(location string, data VolumeBackend) VolumeBackend {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	old := v.data
+	v.location = location
+	v.data = data
+	v.readAhead.reset()
+	return old
+}
+
+// verifyBackingFile checks that every stored sector matches the data in the
+// replacement backend. Sectors are checked against their stored checksum or,
+// if they do not have one, their Merkle root.
+func (vm *VolumeManager) verifyBackingFile(ctx context.Context, id int64, v *volume, data VolumeBackend) error {
+	v.mu.RLock()
+	cipher := v.cipher
+	v.mu.RUnlock()
+
+	var start uint64
+	for {
+		sectors, err := vm.vs.VolumeSectorsFrom(id, start, swapBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get volume sectors: %w", err)
+		} else if len(sectors) == 0 {
+			return nil
+		}
+
+		for _, sector := range sectors {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			checksum, ok, err := vm.vs.SectorChecksum(sector.Root)
+			if err != nil {
+				return fmt.Errorf("failed to get checksum of sector %v: %w", sector.Root, err)
+			}
+
+			buf, err := data.ReadSector(sector.Index)
+			if err != nil {
+				return fmt.Errorf("failed to read sector %v at index %d: %w", sector.Root, sector.Index, err)
+			} else if cipher != nil {
+				cipher.Decrypt(buf[:], buf[:], sector.Index)
+			}
+			if ok && sectorChecksum(buf) != checksum {
+				return fmt.Errorf("%w: sector %v at index %d has a different checksum", ErrBackingFileMismatch, sector.Root, sector.Index)
+			} else if !ok && rhp2.SectorRoot(buf) != sector.Root {
+				return fmt.Errorf("%w: sector %v at index %d has a different root", ErrBackingFileMismatch, sector.Root, sector.Index)
+			}
+		}
+		start = sectors[len(sectors)-1].Index + 1
+	}
+}
+
+// SwapBackingFile replaces a volume's backing file with an identical copy at
+// newPath, e.g. after copying the volume to a new disk. The volume must be
+// read-only so it is not written to while the copy is made. The new file must
+// hold the same number of sectors as the volume and, if verifyChecksums is
+// true, every stored sector must match its checksum or, if it has none, its
+// Merkle root. The volume continues to serve reads from the old file until
+// the new file is verified and is only locked while the files are switched.
+// The old file is closed, but not removed.
+func (vm *VolumeManager) SwapBackingFile(ctx context.Context, id int64, newPath string, verifyChecksums bool) error {
+	ctx, cancel, err := vm.tg.AddContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	vm.mu.Lock()
+	vol, ok := vm.volumes[id]
+	vm.mu.Unlock()
+	if !ok {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: ErrVolumeNotFound}
+	}
+
+	meta, err := vm.vs.Volume(id)
+	if err != nil {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: fmt.Errorf("failed to get volume: %w", err)}
+	} else if !meta.ReadOnly {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: errors.New("volume must be read-only")}
+	} else if meta.LocalPath == newPath {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: errors.New("new path is the volume's current path")}
+	}
+
+	// prevent the volume from being resized or removed while the new file is
+	// verified
+	if err := vol.SetStatus(VolumeStatusSwapping); err != nil {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: err}
+	}
+	defer vol.SetStatus(VolumeStatusReady)
+
+	data, err := vm.backends.Open(newPath)
+	if err != nil {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: fmt.Errorf("failed to open new backing file: %w", err)}
+	}
+	// close the new file unless the swap succeeds
+	swapped := false
+	defer func() {
+		if !swapped {
+			data.Close()
+		}
+	}()
+
+	sb, ok := data.(SizedBackend)
+	if !ok {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: errors.New("backend does not report its size")}
+	} else if sectors, err := sb.Sectors(); err != nil {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: fmt.Errorf("failed to get size of new backing file: %w", err)}
+	} else if sectors != meta.TotalSectors {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: fmt.Errorf("%w: new file has %d sectors, expected %d", ErrBackingFileMismatch, sectors, meta.TotalSectors)}
+	}
+
+	if verifyChecksums {
+		if err := vm.verifyBackingFile(ctx, id, vol, data); err != nil {
+			return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: err}
+		}
+	}
+
+	if err := vm.vs.SetVolumeLocation(id, newPath); err != nil {
+		return &VolumeError{VolumeID: id, Op: VolumeOpSwap, Err: fmt.Errorf("failed to update volume location: %w", err)}
+	}
+	old := vol.swapBackend(newPath, data)
+	swapped = true
+
+	log := vm.log.Named("swap").With(zap.Int64("volumeID", id), zap.String("oldPath", meta.LocalPath), zap.String("newPath", newPath))
+	if old != nil {
+		if err := old.Close(); err != nil {
+			log.Warn("failed to close old backing file", zap.Error(err))
+		}
+	}
+	log.Info("swapped volume backing file")
+	return nil
+}
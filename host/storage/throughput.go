@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+type (
+	// VolumeIO is the number of bytes read from and written to a volume.
+	VolumeIO struct {
+		BytesRead    uint64 `json:"bytesRead"`
+		BytesWritten uint64 `json:"bytesWritten"`
+	}
+
+	// VolumeThroughput is the I/O of a volume over a recent window.
+	VolumeThroughput struct {
+		VolumeID int64         `json:"volumeID"`
+		Window   time.Duration `json:"window"`
+		VolumeIO
+		// ReadRate and WriteRate are the average number of bytes read and
+		// written per second over the window.
+		ReadRate  float64 `json:"readRate"`
+		WriteRate float64 `json:"writeRate"`
+	}
+)
+
+// ErrInvalidThroughputWindow is returned when a throughput window is not
+// positive or is longer than the retained history.
+var ErrInvalidThroughputWindow = fmt.Errorf("throughput window must be between 1m and %v", volumeThroughputRetention)
+
+// VolumeThroughput returns the number of bytes read from and written to a
+// volume over the last window along with the average rates. Counts are
+// recorded per minute, so the window is rounded to the nearest minute.
+// Pending counts are flushed before the report is generated.
+func (vm *VolumeManager) VolumeThroughput(id int64, window time.Duration) (VolumeThroughput, error) {
+	window = window.Round(time.Minute)
+	if window <= 0 || window > volumeThroughputRetention {
+		return VolumeThroughput{}, ErrInvalidThroughputWindow
+	}
+
+	done, err := vm.tg.Add()
+	if err != nil {
+		return VolumeThroughput{}, err
+	}
+	defer done()
+
+	if _, err := vm.vs.Volume(id); err != nil {
+		return VolumeThroughput{}, fmt.Errorf("failed to get volume: %w", err)
+	}
+
+	vm.recorder.Flush()
+	io, err := vm.vs.VolumeIO(id, time.Now().Add(-window))
+	if err != nil {
+		return VolumeThroughput{}, fmt.Errorf("failed to get volume I/O: %w", err)
+	}
+	return VolumeThroughput{
+		VolumeID:  id,
+		Window:    window,
+		VolumeIO:  io,
+		ReadRate:  float64(io.BytesRead) / window.Seconds(),
+		WriteRate: float64(io.BytesWritten) / window.Seconds(),
+	}, nil
+}
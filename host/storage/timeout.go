@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+)
+
+// ErrVolumeDegraded is returned when a volume cannot be written to or synced
+// because a write to it exceeded the write timeout and has not completed.
+var ErrVolumeDegraded = errors.New("volume is degraded")
+
+// degraded returns true if a write to the volume exceeded the write timeout
+// and has not completed yet.
+func (v *volume) degraded() bool {
+	return atomic.LoadInt32(&v.timedOutWrites) > 0
+}
+
+// degradedVolumes returns the IDs of the volumes with outstanding writes
+// that exceeded the write timeout.
+func (vm *VolumeManager) degradedVolumes() (ids []int64) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	for id, vol := range vm.volumes {
+		if vol.degraded() {
+			ids = append(ids, id)
+		}
+	}
+	return
+}
+
+// writeVolumeSector writes a sector to vol at index. If the write does not
+// complete within the write timeout, a *TimeoutError is returned and the
+// volume is marked as degraded until the abandoned write completes. The
+// abandoned write's location is released, so nothing is written to or
+// synced on a degraded volume: a sector written to the released location
+// could be overwritten by the abandoned write, and syncing would wait for
+// the hung write to finish.
+func (vm *VolumeManager) writeVolumeSector(volumeID int64, vol *volume, data *[rhp2.SectorSize]byte, index uint64) error {
+	if vm.writeTimeout <= 0 {
+		return vol.WriteSector(data, index)
+	}
+
+	// the volume's lock may be held by the hung write, get the alert ID and
+	// location first
+	alertID := vol.alertID("writeTimeout")
+	location := vol.Location()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- vol.WriteSector(data, index)
+	}()
+
+	timer := time.NewTimer(vm.writeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-errCh:
+		return err
+	case <-timer.C:
+	}
+
+	log := vm.log.Named("writeTimeout").With(zap.Int64("volume", volumeID), zap.String("location", location), zap.Uint64("index", index))
+	if atomic.AddInt32(&vol.timedOutWrites, 1) == 1 {
+		log.Warn("volume write timed out, marking volume as degraded", zap.Duration("timeout", vm.writeTimeout))
+		vm.a.Register(alerts.Alert{
+			ID:       alertID,
+			Severity: alerts.SeverityWarning,
+			Message:  "Volume write timed out",
+			Data: map[string]any{
+				"volume":   location,
+				"volumeID": volumeID,
+				"timeout":  vm.writeTimeout.String(),
+			},
+			Timestamp: time.Now(),
+		})
+		// the write path must not block on the subscribers
+		go vm.notifyUnavailable(volumeID, location)
+	}
+
+	go func() {
+		start := time.Now()
+		err := <-errCh
+		if atomic.AddInt32(&vol.timedOutWrites, -1) == 0 {
+			log.Info("timed out write completed, volume is no longer degraded", zap.Duration("elapsed", time.Since(start)+vm.writeTimeout), zap.Error(err))
+			vm.a.Dismiss(alertID)
+		}
+	}()
+	return &TimeoutError{Duration: vm.writeTimeout}
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/hostd/alerts"
+	"go.uber.org/zap"
+	"lukechampine.com/frand"
+)
+
+// verification levels for VerifyVolumes
+const (
+	// VerifyLevelNone skips verification.
+	VerifyLevelNone VerifyLevel = "none"
+	// VerifyLevelQuick checks that each volume is available, that its
+	// backend holds all of its sectors, and that its sector counters match
+	// its metadata.
+	VerifyLevelQuick VerifyLevel = "quick"
+	// VerifyLevelFull performs the quick checks and reads every stored
+	// sector to check its integrity.
+	VerifyLevelFull VerifyLevel = "full"
+)
+
+// verifyBatchSize is the number of sectors read from the store at a time
+// during a full verification.
+const verifyBatchSize = 256
+
+type (
+	// A VerifyLevel determines how thoroughly volumes are verified.
+	VerifyLevel string
+
+	// A VolumeIssue is a problem found while verifying a volume.
+	VolumeIssue struct {
+		VolumeID int64           `json:"volumeID"`
+		Severity alerts.Severity `json:"severity"`
+		Message  string          `json:"message"`
+	}
+)
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *VerifyLevel) UnmarshalText(buf []byte) error {
+	switch level := VerifyLevel(buf); level {
+	case "", VerifyLevelNone:
+		*l = VerifyLevelNone
+	case VerifyLevelQuick, VerifyLevelFull:
+		*l = level
+	default:
+		return fmt.Errorf("invalid verification level: %q", string(buf))
+	}
+	return nil
+}
+
+// verifyVolume checks a single volume at the given level.
+func (vm *VolumeManager) verifyVolume(ctx context.Context, vol Volume, v *volume, level VerifyLevel) (issues []VolumeIssue, err error) {
+	addIssue := func(severity alerts.Severity, format string, args ...any) {
+		issues = append(issues, VolumeIssue{
+			VolumeID: vol.ID,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if status := v.Status(); status != VolumeStatusReady {
+		addIssue(alerts.SeverityError, "volume is %v", status)
+		return issues, nil
+	}
+
+	// check that the backend holds every sector
+	if sectors, ok, err := v.Sectors(); err != nil {
+		addIssue(alerts.SeverityError, "failed to get volume size: %v", err)
+	} else if ok && sectors < vol.TotalSectors {
+		addIssue(alerts.SeverityError, "volume holds %d sectors, expected %d", sectors, vol.TotalSectors)
+	}
+
+	// check that the counters match the sector metadata
+	total, used, err := vm.vs.VolumeSectorCounts(vol.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count volume sectors: %w", err)
+	} else if total != vol.TotalSectors {
+		addIssue(alerts.SeverityWarning, "volume has %d sector slots, expected %d", total, vol.TotalSectors)
+	} else if used != vol.UsedSectors {
+		addIssue(alerts.SeverityWarning, "volume has %d used sectors, expected %d", used, vol.UsedSectors)
+	}
+
+	if level != VerifyLevelFull {
+		return issues, nil
+	}
+
+	// read every stored sector and check its integrity
+	var start uint64
+	for {
+		sectors, err := vm.vs.VolumeSectorsFrom(vol.ID, start, verifyBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get volume sectors: %w", err)
+		} else if len(sectors) == 0 {
+			return issues, nil
+		}
+		start = sectors[len(sectors)-1].Index + 1
+
+		for _, sector := range sectors {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			data, err := v.ReadSector(sector.Index)
+			if err != nil {
+				addIssue(alerts.SeverityError, "failed to read sector %v: %v", sector.Root, err)
+				continue
+			}
+
+			if vm.checksums {
+				err = vm.verifySector(sector.Root, data, true)
+			} else if calculated := rhp2.SectorRoot(data); calculated != sector.Root {
+				err = fmt.Errorf("%w: got root %v", ErrSectorCorrupt, calculated)
+			}
+			if errors.Is(err, ErrSectorCorrupt) {
+				addIssue(alerts.SeverityError, "sector %v at index %d is corrupt", sector.Root, sector.Index)
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to verify sector %v: %w", sector.Root, err)
+			}
+		}
+	}
+}
+
+// VerifyVolumes checks the integrity of every volume at the given level and
+// returns any issues found. An alert is registered if any issues are found.
+// Verification should be run before the host starts accepting RHP
+// connections.
+func (vm *VolumeManager) VerifyVolumes(ctx context.Context, level VerifyLevel) ([]VolumeIssue, error) {
+	switch level {
+	case "", VerifyLevelNone:
+		return nil, nil
+	case VerifyLevelQuick, VerifyLevelFull:
+	default:
+		return nil, fmt.Errorf("invalid verification level: %q", level)
+	}
+
+	done, err := vm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	volumes, err := vm.vs.Volumes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volumes: %w", err)
+	}
+
+	log := vm.log.Named("verify").With(zap.String("level", string(level)))
+	start := time.Now()
+	var issues []VolumeIssue
+	for _, vol := range volumes {
+		vm.mu.Lock()
+		v, ok := vm.volumes[vol.ID]
+		vm.mu.Unlock()
+		if !ok {
+			issues = append(issues, VolumeIssue{VolumeID: vol.ID, Severity: alerts.SeverityError, Message: "volume is not loaded"})
+			continue
+		}
+
+		volumeIssues, err := vm.verifyVolume(ctx, vol, v, level)
+		if err != nil {
+			return nil, &VolumeError{VolumeID: vol.ID, Op: VolumeOpRead, Err: err}
+		}
+		for _, issue := range volumeIssues {
+			log.Warn("volume verification issue", zap.Int64("volume", issue.VolumeID), zap.Stringer("severity", issue.Severity), zap.String("message", issue.Message))
+		}
+		issues = append(issues, volumeIssues...)
+	}
+	log.Info("verified volumes", zap.Int("volumes", len(volumes)), zap.Int("issues", len(issues)), zap.Duration("elapsed", time.Since(start)))
+
+	if len(issues) > 0 {
+		severity := alerts.SeverityWarning
+		for _, issue := range issues {
+			if issue.Severity > severity {
+				severity = issue.Severity
+			}
+		}
+		vm.a.Register(alerts.Alert{
+			ID:       frand.Entropy256(),
+			Severity: severity,
+			Message:  "Volume verification found issues",
+			Data: map[string]any{
+				"level":  level,
+				"issues": issues,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+	return issues, nil
+}
@@ -4,11 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"sync"
+	"time"
 
 	rhp2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
+	"golang.org/x/crypto/xts"
 )
 
 type (
@@ -23,26 +24,48 @@ type (
 		Close() error
 	}
 
-	// A volume stores and retrieves sector data from a local file
+	// A volume stores and retrieves sector data from a backend
 	volume struct {
 		// when reading or writing to the volume, a read lock should be held.
 		// When resizing or updating the volume's state, a write lock should be
 		// held.
 		mu sync.RWMutex
 
-		location string     // location is the path to the volume's file
-		data     volumeData // data is a flatfile that stores the volume's sector data
-		stats    VolumeStats
+		location string          // location is the path to the volume's data
+		data     VolumeBackend   // data stores the volume's sector data
+		backends BackendProvider // backends opens the volume's data
+		// cipher encrypts the volume's sector data. It is nil if the volume
+		// is not encrypted.
+		cipher *xts.Cipher
+
+		// statsMu protects stats. It is separate from mu so a hung write
+		// does not block reading the volume's stats.
+		statsMu sync.Mutex
+		stats   VolumeStats
+		// writeLatency tracks the latency of recent writes
+		writeLatency latencyWindow
+		// readAhead buffers sectors read ahead of sequential reads
+		readAhead readAheadBuffer
+		// timedOutWrites is the number of writes that exceeded the write
+		// timeout and have not completed. It is accessed atomically since
+		// a hung write holds the volume's lock.
+		timedOutWrites int32
 	}
 
 	// VolumeStats contains statistics about a volume
 	VolumeStats struct {
-		FailedReads      uint64  `json:"failedReads"`
-		FailedWrites     uint64  `json:"failedWrites"`
-		SuccessfulReads  uint64  `json:"successfulReads"`
-		SuccessfulWrites uint64  `json:"successfulWrites"`
-		Status           string  `json:"status"`
-		Errors           []error `json:"errors"`
+		FailedReads      uint64 `json:"failedReads"`
+		FailedWrites     uint64 `json:"failedWrites"`
+		SuccessfulReads  uint64 `json:"successfulReads"`
+		SuccessfulWrites uint64 `json:"successfulWrites"`
+		// WriteLatencyP99 is the 99th percentile latency of recent writes.
+		WriteLatencyP99 time.Duration `json:"writeLatencyP99"`
+		// Degraded is true while a write that exceeded the write timeout
+		// has not completed. New sectors are not stored in degraded
+		// volumes.
+		Degraded bool    `json:"degraded"`
+		Status   string  `json:"status"`
+		Errors   []error `json:"errors"`
 	}
 
 	// A Volume stores and retrieves sector data
@@ -53,6 +76,17 @@ type (
 		TotalSectors uint64 `json:"totalSectors"`
 		ReadOnly     bool   `json:"readOnly"`
 		Available    bool   `json:"available"`
+		// Encrypted is true if the volume's sector data is encrypted at
+		// rest.
+		Encrypted bool `json:"encrypted"`
+		// ReplicaGroup is the volume's replica group. Sectors written to the
+		// volume are replicated to another volume in the same group. Empty
+		// if replication is disabled.
+		ReplicaGroup string `json:"replicaGroup"`
+		// BytesRead and BytesWritten are the cumulative number of bytes
+		// read from and written to the volume's disk.
+		BytesRead    uint64 `json:"bytesRead"`
+		BytesWritten uint64 `json:"bytesWritten"`
 	}
 
 	// VolumeMeta contains the metadata of a volume.
@@ -66,8 +100,8 @@ type (
 var ErrVolumeNotAvailable = errors.New("volume not available")
 
 func (v *volume) incrementReadStats(err error) {
-	v.mu.Lock()
-	defer v.mu.Unlock()
+	v.statsMu.Lock()
+	defer v.statsMu.Unlock()
 	if err != nil {
 		v.stats.FailedReads++
 		v.appendError(err)
@@ -77,8 +111,8 @@ func (v *volume) incrementReadStats(err error) {
 }
 
 func (v *volume) incrementWriteStats(err error) {
-	v.mu.Lock()
-	defer v.mu.Unlock()
+	v.statsMu.Lock()
+	defer v.statsMu.Unlock()
 	if err != nil {
 		v.stats.FailedWrites++
 		v.appendError(err)
@@ -87,6 +121,8 @@ func (v *volume) incrementWriteStats(err error) {
 	}
 }
 
+// appendError adds an error to the volume's stats. The caller must hold
+// statsMu.
 func (v *volume) appendError(err error) {
 	v.stats.Errors = append(v.stats.Errors, err)
 	if len(v.stats.Errors) > 100 {
@@ -115,21 +151,31 @@ func (v *volume) OpenVolume(localPath string, reload bool) error {
 	if v.data != nil && !reload {
 		return nil
 	}
-	f, err := os.OpenFile(localPath, os.O_RDWR, 0700)
+	data, err := v.backends.Open(localPath)
 	if err != nil {
 		return err
 	}
 	v.location = localPath
-	v.data = f
+	v.data = data
+	v.readAhead.reset()
 	return nil
 }
 
-// SetStatus sets the status of the volume. If the new status is resizing, the
-// volume must be ready. If the new status is removing, the volume must be ready
-// or unavailable.
-func (v *volume) SetStatus(status string) error {
+// SetCipher sets the cipher used to encrypt the volume's sector data. A nil
+// cipher disables encryption.
+func (v *volume) SetCipher(c *xts.Cipher) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
+	v.cipher = c
+	v.readAhead.reset()
+}
+
+// SetStatus sets the status of the volume. If the new status is resizing or
+// swapping, the volume must be ready. If the new status is removing, the volume must be ready
+// or unavailable.
+func (v *volume) SetStatus(status string) error {
+	v.statsMu.Lock()
+	defer v.statsMu.Unlock()
 
 	if v.stats.Status == status {
 		return nil
@@ -140,7 +186,7 @@ func (v *volume) SetStatus(status string) error {
 		if v.stats.Status != VolumeStatusReady && v.stats.Status != VolumeStatusUnavailable {
 			return fmt.Errorf("volume is %v", v.stats.Status)
 		}
-	case VolumeStatusResizing:
+	case VolumeStatusResizing, VolumeStatusSwapping:
 		if v.stats.Status != VolumeStatusReady {
 			return fmt.Errorf("volume is %v", v.stats.Status)
 		}
@@ -153,8 +199,8 @@ func (v *volume) SetStatus(status string) error {
 }
 
 func (v *volume) Status() string {
-	v.mu.RLock()
-	defer v.mu.RUnlock()
+	v.statsMu.Lock()
+	defer v.statsMu.Unlock()
 	return v.stats.Status
 }
 
@@ -166,15 +212,22 @@ func (v *volume) ReadSector(index uint64) (*[rhp2.SectorSize]byte, error) {
 	if v.data == nil {
 		return nil, ErrVolumeNotAvailable
 	}
+	return v.readSector(index)
+}
 
-	var sector [rhp2.SectorSize]byte
-	_, err := v.data.ReadAt(sector[:], int64(index*rhp2.SectorSize))
-
+// readSector reads the sector at index from the backend. A read lock must be
+// held.
+func (v *volume) readSector(index uint64) (*[rhp2.SectorSize]byte, error) {
+	sector, err := v.data.ReadSector(index)
 	if err != nil {
 		err = fmt.Errorf("failed to read sector at index %v: %w", index, err)
+	} else if v.cipher != nil {
+		// the sector's index is used as the tweak so that identical
+		// sectors encrypt differently
+		v.cipher.Decrypt(sector[:], sector[:], index)
 	}
 	go v.incrementReadStats(err)
-	return &sector, err
+	return sector, err
 }
 
 // WriteSector writes a sector to the volume at index
@@ -185,7 +238,16 @@ func (v *volume) WriteSector(data *[rhp2.SectorSize]byte, index uint64) error {
 	if v.data == nil {
 		panic("volume not open") // developer error
 	}
-	_, err := v.data.WriteAt(data[:], int64(index*rhp2.SectorSize))
+	if v.cipher != nil {
+		// data may be shared with the cache, encrypt a copy
+		var encrypted [rhp2.SectorSize]byte
+		v.cipher.Encrypt(encrypted[:], data[:], index)
+		data = &encrypted
+	}
+	err := v.data.WriteSector(data, index)
+	// the buffer is invalidated after the write so a concurrent read-ahead
+	// cannot buffer the old data
+	v.readAhead.invalidate(index)
 	if err != nil {
 		err = fmt.Errorf("failed to write sector to index %v: %w", index, err)
 	}
@@ -203,7 +265,9 @@ func (v *volume) Sync() error {
 	}
 	err := v.data.Sync()
 	if err != nil {
+		v.statsMu.Lock()
 		v.appendError(fmt.Errorf("failed to sync volume: %w", err))
+		v.statsMu.Unlock()
 	}
 	return err
 }
@@ -216,13 +280,34 @@ func (v *volume) Resize(newSectors uint64) error {
 	if v.data == nil {
 		return ErrVolumeNotAvailable
 	}
-	return v.data.Truncate(int64(newSectors * rhp2.SectorSize))
+	v.readAhead.reset()
+	return v.data.Resize(newSectors)
 }
 
-func (v *volume) Stats() VolumeStats {
+// Sectors returns the number of sectors held by the volume's backend. ok is
+// false if the backend does not report its size.
+func (v *volume) Sectors() (sectors uint64, ok bool, err error) {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
-	return v.stats
+
+	if v.data == nil {
+		return 0, false, ErrVolumeNotAvailable
+	}
+	sb, ok := v.data.(SizedBackend)
+	if !ok {
+		return 0, false, nil
+	}
+	sectors, err = sb.Sectors()
+	return sectors, true, err
+}
+
+func (v *volume) Stats() VolumeStats {
+	v.statsMu.Lock()
+	stats := v.stats
+	v.statsMu.Unlock()
+	stats.WriteLatencyP99, _ = v.writeLatency.percentile(0.99, time.Time{})
+	stats.Degraded = v.degraded()
+	return stats
 }
 
 // Close closes the volume
@@ -237,6 +322,9 @@ func (v *volume) Close() error {
 		return fmt.Errorf("failed to close volume: %w", err)
 	}
 	v.data = nil
+	v.readAhead.reset()
+	v.statsMu.Lock()
 	v.stats.Status = VolumeStatusUnavailable
+	v.statsMu.Unlock()
 	return nil
 }
@@ -0,0 +1,153 @@
+package chain
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/transactionpool"
+	stypes "go.sia.tech/siad/types"
+)
+
+// reasons a transaction set can be rejected by the transaction pool
+const (
+	RejectionLowFee   = "lowFee"
+	RejectionConflict = "conflict"
+	RejectionInvalid  = "invalid"
+	RejectionOther    = "other"
+)
+
+const (
+	// broadcastWindow is the number of recent broadcasts used to calculate
+	// the rejection rate.
+	broadcastWindow = 20
+	// broadcastAlertMinSamples is the minimum number of recent broadcasts
+	// required before an alert is registered.
+	broadcastAlertMinSamples = 10
+	// broadcastAlertThreshold is the rejection rate that triggers an alert.
+	broadcastAlertThreshold = 0.5
+)
+
+var broadcastAlertID = types.HashBytes([]byte("tpoolRejections"))
+
+type (
+	// Alerts registers and dismisses global alerts.
+	Alerts interface {
+		Register(alerts.Alert)
+		Dismiss(...types.Hash256)
+	}
+
+	// broadcastMonitor tracks the outcome of the host's transaction pool
+	// broadcasts.
+	broadcastMonitor struct {
+		alerts Alerts
+
+		mu         sync.Mutex
+		attempts   uint64
+		successes  uint64
+		rejections map[string]uint64
+		// recent is a ring buffer of the most recent broadcasts. True
+		// indicates the broadcast was rejected.
+		recent  []bool
+		next    int
+		lastErr string
+	}
+)
+
+// rejectionReason classifies an error returned by the transaction pool.
+func rejectionReason(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "more miner fees"),
+		strings.Contains(msg, stypes.ErrZeroMinerFee.Error()):
+		return RejectionLowFee
+	case strings.Contains(msg, stypes.ErrDoubleSpend.Error()),
+		strings.Contains(msg, "nonexisting siacoin output"),
+		strings.Contains(msg, "outdated revision number"),
+		strings.Contains(msg, "submitted after deadline"):
+		return RejectionConflict
+	case strings.Contains(msg, transactionpool.ErrTxnSetNotAccepted.Error()),
+		strings.Contains(msg, modules.ErrInvalidArbPrefix.Error()),
+		strings.Contains(msg, modules.ErrLargeTransaction.Error()),
+		strings.Contains(msg, modules.ErrLargeTransactionSet.Error()):
+		return RejectionInvalid
+	default:
+		return RejectionOther
+	}
+}
+
+// record records the result of a broadcast and updates the rejection alert.
+func (bm *broadcastMonitor) record(err error) {
+	// a duplicate set is already in the pool
+	rejected := err != nil && !strings.Contains(err.Error(), modules.ErrDuplicateTransactionSet.Error())
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	bm.attempts++
+	if rejected {
+		bm.rejections[rejectionReason(err)]++
+		bm.lastErr = err.Error()
+	} else {
+		bm.successes++
+	}
+
+	if len(bm.recent) < broadcastWindow {
+		bm.recent = append(bm.recent, rejected)
+	} else {
+		bm.recent[bm.next] = rejected
+		bm.next = (bm.next + 1) % broadcastWindow
+	}
+
+	if bm.alerts == nil || len(bm.recent) < broadcastAlertMinSamples {
+		return
+	}
+
+	var n int
+	for _, r := range bm.recent {
+		if r {
+			n++
+		}
+	}
+	rate := float64(n) / float64(len(bm.recent))
+	if rate < broadcastAlertThreshold {
+		bm.alerts.Dismiss(broadcastAlertID)
+		return
+	}
+
+	rejections := make(map[string]uint64, len(bm.rejections))
+	for reason, count := range bm.rejections {
+		rejections[reason] = count
+	}
+	bm.alerts.Register(alerts.Alert{
+		ID:       broadcastAlertID,
+		Severity: alerts.SeverityWarning,
+		Message:  "Transaction pool is rejecting broadcasts",
+		Data: map[string]any{
+			"rejectionRate": rate,
+			"rejections":    rejections,
+			"lastError":     bm.lastErr,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// metrics returns a snapshot of the broadcast counters.
+func (bm *broadcastMonitor) metrics() metrics.Broadcasts {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	m := metrics.Broadcasts{
+		Attempts:   bm.attempts,
+		Successes:  bm.successes,
+		Rejections: make(map[string]uint64, len(bm.rejections)),
+	}
+	for reason, count := range bm.rejections {
+		m.Rejections[reason] = count
+	}
+	return m
+}
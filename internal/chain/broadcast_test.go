@@ -0,0 +1,90 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/siad/modules"
+	stypes "go.sia.tech/siad/types"
+)
+
+type mockTPool struct {
+	modules.TransactionPool
+	err error
+}
+
+func (tp *mockTPool) AcceptTransactionSet([]stypes.Transaction) error {
+	return tp.err
+}
+
+type mockAlerts struct {
+	active map[types.Hash256]alerts.Alert
+}
+
+func (ma *mockAlerts) Register(a alerts.Alert) {
+	ma.active[a.ID] = a
+}
+
+func (ma *mockAlerts) Dismiss(ids ...types.Hash256) {
+	for _, id := range ids {
+		delete(ma.active, id)
+	}
+}
+
+func TestBroadcastMetrics(t *testing.T) {
+	stp := &mockTPool{}
+	am := &mockAlerts{active: make(map[types.Hash256]alerts.Alert)}
+	tp := NewTPool(stp, WithBroadcastAlerts(am))
+
+	broadcast := func(err error, n int) {
+		t.Helper()
+		stp.err = err
+		for i := 0; i < n; i++ {
+			if got := tp.AcceptTransactionSet([]types.Transaction{{}}); !errors.Is(got, err) {
+				t.Fatalf("expected error %v, got %v", err, got)
+			}
+		}
+	}
+
+	// successful broadcasts and duplicates should not be rejections
+	broadcast(nil, 8)
+	broadcast(modules.ErrDuplicateTransactionSet, 2)
+	// a few rejections should not trigger an alert
+	broadcast(errors.New("transaction set needs more miner fees to be accepted"), 3)
+	broadcast(stypes.ErrDoubleSpend, 1)
+	broadcast(errors.New("something went wrong"), 1)
+
+	m := tp.BroadcastMetrics()
+	switch {
+	case m.Attempts != 15:
+		t.Fatalf("expected 15 attempts, got %d", m.Attempts)
+	case m.Successes != 10:
+		t.Fatalf("expected 10 successes, got %d", m.Successes)
+	case m.Rejections[RejectionLowFee] != 3:
+		t.Fatalf("expected 3 low fee rejections, got %d", m.Rejections[RejectionLowFee])
+	case m.Rejections[RejectionConflict] != 1:
+		t.Fatalf("expected 1 conflict rejection, got %d", m.Rejections[RejectionConflict])
+	case m.Rejections[RejectionOther] != 1:
+		t.Fatalf("expected 1 other rejection, got %d", m.Rejections[RejectionOther])
+	case len(am.active) != 0:
+		t.Fatal("expected no alerts")
+	}
+
+	// a spike in rejections should register an alert
+	broadcast(errors.New("transaction set needs more miner fees to be accepted"), 10)
+	if _, ok := am.active[broadcastAlertID]; !ok {
+		t.Fatal("expected rejection alert")
+	} else if m := tp.BroadcastMetrics(); m.Rejections[RejectionLowFee] != 13 {
+		t.Fatalf("expected 13 low fee rejections, got %d", m.Rejections[RejectionLowFee])
+	}
+
+	// the alert should be dismissed once broadcasts succeed again
+	broadcast(nil, broadcastWindow)
+	if len(am.active) != 0 {
+		t.Fatal("expected rejection alert to be dismissed")
+	} else if m := tp.BroadcastMetrics(); m.Attempts != 45 || m.Successes != 30 {
+		t.Fatalf("expected 45 attempts and 30 successes, got %d and %d", m.Attempts, m.Successes)
+	}
+}
@@ -16,7 +16,13 @@ import (
 	stypes "go.sia.tech/siad/types"
 )
 
-const maxSyncTime = time.Hour
+const (
+	// blockInterval is the target time between blocks.
+	blockInterval = 10 * time.Minute
+	// defaultSyncTolerance is the default number of blocks the tip can be
+	// behind the expected network tip while still being considered synced.
+	defaultSyncTolerance = 6
+)
 
 var (
 	// ErrBlockNotFound is returned when a block is not found.
@@ -51,12 +57,27 @@ type Manager struct {
 	cs      modules.ConsensusSet
 	network *consensus.Network
 
+	// syncTolerance is the maximum time between the tip's timestamp and the
+	// current time for the chain to be considered synced.
+	syncTolerance time.Duration
+
 	close  chan struct{}
 	mu     sync.Mutex
 	tip    consensus.State
 	synced bool
 }
 
+// An Option configures a Manager.
+type Option func(*Manager)
+
+// WithSyncTolerance sets the number of blocks the chain can lag behind the
+// network tip while still being considered synced.
+func WithSyncTolerance(blocks uint64) Option {
+	return func(m *Manager) {
+		m.syncTolerance = time.Duration(blocks) * blockInterval
+	}
+}
+
 // ProcessConsensusChange implements the modules.ConsensusSetSubscriber interface.
 func (m *Manager) ProcessConsensusChange(cc modules.ConsensusChange) {
 	m.mu.Lock()
@@ -68,7 +89,7 @@ func (m *Manager) ProcessConsensusChange(cc modules.ConsensusChange) {
 			Height: uint64(cc.BlockHeight),
 		},
 	}
-	m.synced = synced(cc.AppliedBlocks[len(cc.AppliedBlocks)-1].Timestamp)
+	m.synced = m.isSynced(cc.AppliedBlocks[len(cc.AppliedBlocks)-1].Timestamp)
 }
 
 // Network returns the network name.
@@ -146,12 +167,19 @@ func (m *Manager) Subscribe(s modules.ConsensusSetSubscriber, ccID modules.Conse
 	return nil
 }
 
-func synced(timestamp stypes.Timestamp) bool {
-	return time.Since(time.Unix(int64(timestamp), 0)) <= maxSyncTime
+// Unsubscribe removes a subscriber from the consensus set.
+func (m *Manager) Unsubscribe(s modules.ConsensusSetSubscriber) {
+	m.cs.Unsubscribe(s)
+}
+
+// isSynced returns true if a tip with the given timestamp is within the
+// manager's sync tolerance.
+func (m *Manager) isSynced(timestamp stypes.Timestamp) bool {
+	return time.Since(time.Unix(int64(timestamp), 0)) <= m.syncTolerance
 }
 
 // NewManager creates a new chain manager.
-func NewManager(cs modules.ConsensusSet) (*Manager, error) {
+func NewManager(cs modules.ConsensusSet, opts ...Option) (*Manager, error) {
 	height := cs.Height()
 	block, ok := cs.BlockAtHeight(height)
 	if !ok {
@@ -168,9 +196,13 @@ func NewManager(cs modules.ConsensusSet) (*Manager, error) {
 				Height: uint64(height),
 			},
 		},
-		synced: synced(block.Timestamp),
-		close:  make(chan struct{}),
+		syncTolerance: defaultSyncTolerance * blockInterval,
+		close:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	m.synced = m.isSynced(block.Timestamp)
 
 	if err := cs.ConsensusSetSubscribe(m, modules.ConsensusChangeRecent, m.close); err != nil {
 		return nil, fmt.Errorf("failed to subscribe to consensus set: %w", err)
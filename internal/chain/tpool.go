@@ -2,13 +2,26 @@ package chain
 
 import (
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/metrics"
 	"go.sia.tech/siad/modules"
 	stypes "go.sia.tech/siad/types"
 )
 
 // TransactionPool wraps the siad transaction pool with a more convenient API.
 type TransactionPool struct {
-	tp modules.TransactionPool
+	tp         modules.TransactionPool
+	broadcasts *broadcastMonitor
+}
+
+// A TPoolOption configures a TransactionPool.
+type TPoolOption func(*TransactionPool)
+
+// WithBroadcastAlerts registers an alert when the transaction pool rejects a
+// large share of the host's recent broadcasts.
+func WithBroadcastAlerts(a Alerts) TPoolOption {
+	return func(tp *TransactionPool) {
+		tp.broadcasts.alerts = a
+	}
 }
 
 // RecommendedFee returns the recommended fee per byte.
@@ -35,7 +48,15 @@ func (tp *TransactionPool) AcceptTransactionSet(txns []types.Transaction) error
 	for i := range stxns {
 		convertToSiad(&txns[i], &stxns[i])
 	}
-	return tp.tp.AcceptTransactionSet(stxns)
+	err := tp.tp.AcceptTransactionSet(stxns)
+	tp.broadcasts.record(err)
+	return err
+}
+
+// BroadcastMetrics returns the number of broadcast attempts, successes, and
+// rejections since startup.
+func (tp *TransactionPool) BroadcastMetrics() metrics.Broadcasts {
+	return tp.broadcasts.metrics()
 }
 
 // UnconfirmedParents returns the unconfirmed parents of a transaction.
@@ -71,6 +92,15 @@ func (tp *TransactionPool) Close() error {
 }
 
 // NewTPool wraps a siad transaction pool with a more convenient API.
-func NewTPool(tp modules.TransactionPool) *TransactionPool {
-	return &TransactionPool{tp}
+func NewTPool(tp modules.TransactionPool, opts ...TPoolOption) *TransactionPool {
+	t := &TransactionPool{
+		tp: tp,
+		broadcasts: &broadcastMonitor{
+			rejections: make(map[string]uint64),
+		},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
 }
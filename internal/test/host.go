@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	crhp2 "go.sia.tech/core/rhp/v2"
@@ -17,6 +18,7 @@ import (
 	"go.sia.tech/hostd/host/registry"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/hostd/internal/chain"
 	"go.sia.tech/hostd/persist/sqlite"
 	"go.sia.tech/hostd/rhp"
 	rhp2 "go.sia.tech/hostd/rhp/v2"
@@ -33,11 +35,46 @@ type stubDataMonitor struct{}
 func (stubDataMonitor) ReadBytes(n int)  {}
 func (stubDataMonitor) WriteBytes(n int) {}
 
+// hostChain wraps the node's chain manager so tests can simulate a host that
+// has fallen behind the network.
+type hostChain struct {
+	*chain.Manager
+	unsynced atomic.Bool
+}
+
+// Synced returns false if the host has been marked unsynced.
+func (c *hostChain) Synced() bool {
+	return !c.unsynced.Load() && c.Manager.Synced()
+}
+
+type hostOptions struct {
+	rhp2 []rhp2.SessionHandlerOption
+	rhp3 []rhp3.SessionHandlerOption
+}
+
+// A HostOption configures a test host.
+type HostOption func(*hostOptions)
+
+// WithRHP2Options sets the options passed to the host's RHP2 session handler.
+func WithRHP2Options(opts ...rhp2.SessionHandlerOption) HostOption {
+	return func(ho *hostOptions) {
+		ho.rhp2 = append(ho.rhp2, opts...)
+	}
+}
+
+// WithRHP3Options sets the options passed to the host's RHP3 session handler.
+func WithRHP3Options(opts ...rhp3.SessionHandlerOption) HostOption {
+	return func(ho *hostOptions) {
+		ho.rhp3 = append(ho.rhp3, opts...)
+	}
+}
+
 // A Host is an ephemeral host that can be used for testing.
 type Host struct {
 	*Node
 
 	privKey   types.PrivateKey
+	chain     *hostChain
 	store     *sqlite.Store
 	log       *zap.Logger
 	wallet    *wallet.SingleAddressWallet
@@ -92,16 +129,33 @@ func (h *Host) Close() error {
 	return nil
 }
 
-// RHP2Addr returns the address of the rhp2 listener
+// SetSynced overrides the sync state reported to the host's RHP handlers. An
+// unsynced host rejects contract formations and renewals.
+func (h *Host) SetSynced(synced bool) {
+	h.chain.unsynced.Store(!synced)
+}
+
+// RHP2Addr returns the address of the primary rhp2 listener
 func (h *Host) RHP2Addr() string {
-	return h.rhp2.LocalAddr()
+	return h.rhp2.LocalAddr()[0]
 }
 
-// RHP3Addr returns the address of the rhp3 listener
+// RHP3Addr returns the address of the primary rhp3 listener
 func (h *Host) RHP3Addr() string {
+	return h.rhp3.LocalAddr()[0]
+}
+
+// RHP3Addrs returns the addresses of all rhp3 listeners
+func (h *Host) RHP3Addrs() []string {
 	return h.rhp3.LocalAddr()
 }
 
+// RejectedHandshakes returns the number of RHP2 and RHP3 connections that
+// were closed for not completing the handshake in time.
+func (h *Host) RejectedHandshakes() (v2, v3 uint64) {
+	return h.rhp2.RejectedHandshakes(), h.rhp3.RejectedHandshakes()
+}
+
 // RHP3WSAddr returns the address of the rhp3 WebSocket listener
 func (h *Host) RHP3WSAddr() string {
 	return h.rhp3WS.Addr().String()
@@ -166,9 +220,25 @@ func (h *Host) Store() *sqlite.Store {
 	return h.store
 }
 
+// listenLocal binds n listeners on random localhost ports
+func listenLocal(n int) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
 // NewHost initializes a new test host
-func NewHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Logger) (*Host, error) {
-	host, err := NewEmptyHost(privKey, dir, node, log)
+func NewHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Logger, opts ...HostOption) (*Host, error) {
+	host, err := NewEmptyHost(privKey, dir, node, log, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -188,7 +258,12 @@ func NewHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Logger)
 }
 
 // NewEmptyHost initializes a new test host
-func NewEmptyHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Logger) (*Host, error) {
+func NewEmptyHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Logger, opts ...HostOption) (*Host, error) {
+	var ho hostOptions
+	for _, opt := range opts {
+		opt(&ho)
+	}
+
 	db, err := sqlite.OpenDatabase(filepath.Join(dir, "hostd.db"), log.Named("sqlite"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sql store: %w", err)
@@ -215,14 +290,16 @@ func NewEmptyHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Log
 		return nil, fmt.Errorf("failed to create contract manager: %w", err)
 	}
 
-	rhp2Listener, err := net.Listen("tcp", "localhost:0")
+	// bind two listeners per protocol so that tests exercise hosts
+	// listening on multiple addresses
+	rhp2Listeners, err := listenLocal(2)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create rhp2 listener: %w", err)
+		return nil, fmt.Errorf("failed to create rhp2 listeners: %w", err)
 	}
 
-	rhp3Listener, err := net.Listen("tcp", "localhost:0")
+	rhp3Listeners, err := listenLocal(2)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create rhp2 listener: %w", err)
+		return nil, fmt.Errorf("failed to create rhp3 listeners: %w", err)
 	}
 
 	settings, err := settings.NewConfigManager(settings.WithHostKey(privKey),
@@ -240,14 +317,15 @@ func NewEmptyHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Log
 	accounts := accounts.NewManager(db, settings)
 
 	sessions := rhp.NewSessionReporter()
+	hc := &hostChain{Manager: node.cm}
 
-	rhp2, err := rhp2.NewSessionHandler(rhp2Listener, privKey, rhp3Listener.Addr().String(), node.cm, node.tp, wallet, contracts, settings, storage, stubDataMonitor{}, sessions, log.Named("rhp2"))
+	rhp2, err := rhp2.NewSessionHandler(rhp2Listeners, privKey, rhp3Listeners[0].Addr().String(), hc, node.tp, wallet, contracts, settings, storage, stubDataMonitor{}, sessions, log.Named("rhp2"), ho.rhp2...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create rhp2 session handler: %w", err)
 	}
 	go rhp2.Serve()
 
-	rhp3, err := rhp3.NewSessionHandler(rhp3Listener, privKey, node.cm, node.tp, wallet, accounts, contracts, registry, storage, settings, stubDataMonitor{}, sessions, log.Named("rhp3"))
+	rhp3, err := rhp3.NewSessionHandler(rhp3Listeners, privKey, hc, node.tp, wallet, accounts, contracts, registry, storage, settings, stubDataMonitor{}, sessions, log.Named("rhp3"), ho.rhp3...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create rhp3 session handler: %w", err)
 	}
@@ -272,6 +350,7 @@ func NewEmptyHost(privKey types.PrivateKey, dir string, node *Node, log *zap.Log
 	return &Host{
 		Node:      node,
 		privKey:   privKey,
+		chain:     hc,
 		store:     db,
 		log:       log,
 		wallet:    wallet,
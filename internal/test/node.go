@@ -113,7 +113,7 @@ func NewNode(dir string) (*Node, error) {
 
 // NewTestingPair creates a new renter and host pair, connects them to each
 // other, and funds both wallets.
-func NewTestingPair(dir string, log *zap.Logger) (*Renter, *Host, error) {
+func NewTestingPair(dir string, log *zap.Logger, opts ...HostOption) (*Renter, *Host, error) {
 	hostKey, renterKey := types.GeneratePrivateKey(), types.GeneratePrivateKey()
 
 	node, err := NewNode(dir)
@@ -128,7 +128,7 @@ func NewTestingPair(dir string, log *zap.Logger) (*Renter, *Host, error) {
 	}
 
 	// initialize the host
-	host, err := NewHost(hostKey, filepath.Join(dir, "host"), node, log.Named("host"))
+	host, err := NewHost(hostKey, filepath.Join(dir, "host"), node, log.Named("host"), opts...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create host: %w", err)
 	}
@@ -86,6 +86,16 @@ func (r *Renter) Settings(ctx context.Context, hostAddr string, hostKey types.Pu
 
 // FormContract forms a contract with the host
 func (r *Renter) FormContract(ctx context.Context, hostAddr string, hostKey types.PublicKey, renterPayout, hostCollateral types.Currency, duration uint64) (crhp2.ContractRevision, error) {
+	return r.formContract(ctx, hostAddr, hostKey, renterPayout, hostCollateral, duration, nil)
+}
+
+// FormContractWithPrice forms a contract with the host, paying contractPrice
+// instead of the host's advertised contract price.
+func (r *Renter) FormContractWithPrice(ctx context.Context, hostAddr string, hostKey types.PublicKey, renterPayout, hostCollateral, contractPrice types.Currency, duration uint64) (crhp2.ContractRevision, error) {
+	return r.formContract(ctx, hostAddr, hostKey, renterPayout, hostCollateral, duration, &contractPrice)
+}
+
+func (r *Renter) formContract(ctx context.Context, hostAddr string, hostKey types.PublicKey, renterPayout, hostCollateral types.Currency, duration uint64, contractPrice *types.Currency) (crhp2.ContractRevision, error) {
 	t, err := dialTransport(ctx, hostAddr, hostKey)
 	if err != nil {
 		return crhp2.ContractRevision{}, fmt.Errorf("failed to dial transport: %w", err)
@@ -95,6 +105,9 @@ func (r *Renter) FormContract(ctx context.Context, hostAddr string, hostKey type
 	if err != nil {
 		return crhp2.ContractRevision{}, fmt.Errorf("failed to get host settings: %w", err)
 	}
+	if contractPrice != nil {
+		settings.ContractPrice = *contractPrice
+	}
 	cs := r.TipState()
 	contract := crhp2.PrepareContractFormation(r.privKey.PublicKey(), hostKey, renterPayout, hostCollateral, cs.Index.Height+duration, settings, r.WalletAddress())
 	formationCost := crhp2.ContractFormationCost(cs, contract, settings.ContractPrice)
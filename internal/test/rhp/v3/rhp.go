@@ -245,6 +245,66 @@ func (s *Session) AppendSector(sector *[rhp2.SectorSize]byte, revision *rhp2.Con
 		return types.ZeroCurrency, fmt.Errorf("unexpected filesize: %v != %v", resp.NewSize, revision.Revision.Filesize+rhp2.SectorSize)
 	}
 	//TODO: validate proof
+	if err := finalizeProgram(stream, revision, renterKey, resp); err != nil {
+		return types.ZeroCurrency, err
+	}
+	return resp.TotalCost, nil
+}
+
+// ExecuteProgram executes a program and returns the output of each executed
+// instruction. If the program fails, the error of the failed instruction is
+// returned with the outputs. If revision is not nil, the program is finalized
+// with the output of the last executed instruction. A failed program is only
+// finalized if finalizeFailed is true.
+func (s *Session) ExecuteProgram(program []rhp3.Instruction, data []byte, revision *rhp2.ContractRevision, renterKey types.PrivateKey, payment PaymentMethod, budget types.Currency, finalizeFailed bool) ([]rhp3.RPCExecuteProgramResponse, error) {
+	stream := s.t.DialStream()
+	defer stream.Close()
+
+	req := rhp3.RPCExecuteProgramRequest{
+		Program:     program,
+		ProgramData: data,
+	}
+	if revision != nil {
+		req.FileContractID = revision.ID()
+	}
+
+	if err := stream.WriteRequest(rhp3.RPCExecuteProgramID, &s.pt.UID); err != nil {
+		return nil, fmt.Errorf("failed to write request: %w", err)
+	} else if err := s.processPayment(stream, payment, s.pt.InitBaseCost.Add(budget)); err != nil {
+		return nil, fmt.Errorf("failed to pay: %w", err)
+	} else if err := stream.WriteResponse(&req); err != nil {
+		return nil, fmt.Errorf("failed to write response: %w", err)
+	}
+	var cancelToken types.Specifier // unused
+	if err := stream.ReadResponse(&cancelToken, 4096); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var outputs []rhp3.RPCExecuteProgramResponse
+	var programErr error
+	for range program {
+		var resp rhp3.RPCExecuteProgramResponse
+		if err := stream.ReadResponse(&resp, 4096+rhp2.SectorSize); err != nil {
+			return outputs, fmt.Errorf("failed to read response: %w", err)
+		}
+		outputs = append(outputs, resp)
+		if resp.Error != nil {
+			programErr = fmt.Errorf("failed to execute program: %w", resp.Error)
+			break
+		}
+	}
+
+	if revision == nil || (programErr != nil && !finalizeFailed) {
+		return outputs, programErr
+	} else if err := finalizeProgram(stream, revision, renterKey, outputs[len(outputs)-1]); err != nil {
+		return outputs, err
+	}
+	return outputs, programErr
+}
+
+// finalizeProgram revises the contract with the output of the last executed
+// instruction of a program and updates revision with the host's signature.
+func finalizeProgram(stream *rhp3.Stream, revision *rhp2.ContractRevision, renterKey types.PrivateKey, resp rhp3.RPCExecuteProgramResponse) error {
 	// revise the contract
 	revised := revision.Revision
 	revised.RevisionNumber++
@@ -282,11 +342,11 @@ func (s *Session) AppendSector(sector *[rhp2.SectorSize]byte, revision *rhp2.Con
 		MissedProofValues: missedProofValues,
 	}
 	if err := stream.WriteResponse(&finalizeReq); err != nil {
-		return types.ZeroCurrency, fmt.Errorf("failed to write response: %w", err)
+		return fmt.Errorf("failed to write response: %w", err)
 	}
 	var finalizeResp rhp3.RPCFinalizeProgramResponse
 	if err := stream.ReadResponse(&finalizeResp, 4096); err != nil {
-		return types.ZeroCurrency, fmt.Errorf("failed to read response: %w", err)
+		return fmt.Errorf("failed to read response: %w", err)
 	}
 	revision.Revision = revised
 	revision.Signatures = [2]types.TransactionSignature{
@@ -307,7 +367,7 @@ func (s *Session) AppendSector(sector *[rhp2.SectorSize]byte, revision *rhp2.Con
 			Signature: finalizeResp.Signature[:],
 		},
 	}
-	return resp.TotalCost, nil
+	return nil
 }
 
 // ReadSector downloads a sector from the host.
@@ -355,6 +415,50 @@ func (s *Session) ReadSector(root types.Hash256, offset, length uint64, payment
 	return resp.Output, resp.TotalCost, nil
 }
 
+// ReadSectors downloads multiple full sectors from the host in a single
+// program.
+func (s *Session) ReadSectors(roots []types.Hash256, payment PaymentMethod, budget types.Currency) ([][]byte, error) {
+	stream := s.t.DialStream()
+	defer stream.Close()
+
+	var req rhp3.RPCExecuteProgramRequest
+	for _, root := range roots {
+		offset := uint64(len(req.ProgramData))
+		req.ProgramData = binary.LittleEndian.AppendUint64(req.ProgramData, rhp2.SectorSize)
+		req.ProgramData = binary.LittleEndian.AppendUint64(req.ProgramData, 0)
+		req.ProgramData = append(req.ProgramData, root[:]...)
+		req.Program = append(req.Program, &rhp3.InstrReadSector{
+			LengthOffset:     offset,
+			OffsetOffset:     offset + 8,
+			MerkleRootOffset: offset + 16,
+		})
+	}
+
+	if err := stream.WriteRequest(rhp3.RPCExecuteProgramID, &s.pt.UID); err != nil {
+		return nil, fmt.Errorf("failed to write request: %w", err)
+	} else if err := s.processPayment(stream, payment, s.pt.InitBaseCost.Add(budget)); err != nil {
+		return nil, fmt.Errorf("failed to pay: %w", err)
+	} else if err := stream.WriteResponse(&req); err != nil {
+		return nil, fmt.Errorf("failed to write response: %w", err)
+	}
+	var cancelToken types.Specifier // unused
+	if err := stream.ReadResponse(&cancelToken, 4096); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	sectors := make([][]byte, 0, len(roots))
+	for range roots {
+		var resp rhp3.RPCExecuteProgramResponse
+		if err := stream.ReadResponse(&resp, 4096+rhp2.SectorSize); err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		} else if resp.Error != nil {
+			return nil, fmt.Errorf("failed to read sector: %w", resp.Error)
+		}
+		sectors = append(sectors, resp.Output)
+	}
+	return sectors, nil
+}
+
 // ReadOffset reads a sector from a contract at a given offset.
 func (s *Session) ReadOffset(offset, length uint64, contractID types.FileContractID, payment PaymentMethod, budget types.Currency) ([]byte, types.Currency, error) {
 	stream := s.t.DialStream()
@@ -54,7 +54,7 @@ func (w *Wallet) SendSiacoins(outputs []types.SiacoinOutput) (txn types.Transact
 }
 
 // NewWallet initializes a new test wallet.
-func NewWallet(privKey types.PrivateKey, dir string, log *zap.Logger) (*Wallet, error) {
+func NewWallet(privKey types.PrivateKey, dir string, log *zap.Logger, opts ...wallet.Option) (*Wallet, error) {
 	node, err := NewNode(dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create node: %w", err)
@@ -63,7 +63,7 @@ func NewWallet(privKey types.PrivateKey, dir string, log *zap.Logger) (*Wallet,
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sql store: %w", err)
 	}
-	wallet, err := wallet.NewSingleAddressWallet(privKey, node.cm, node.tp, db, log.Named("wallet"))
+	wallet, err := wallet.NewSingleAddressWallet(privKey, node.cm, node.tp, db, log.Named("wallet"), opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create wallet: %w", err)
 	}
@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// backupDB copies the main database of src to dest in a single step. Copying
+// in smaller steps restarts the backup every time another connection writes
+// to the database, so a busy host might never finish. The database uses WAL
+// mode, so writers are not blocked while the copy's read transaction is open.
+func backupDB(ctx context.Context, dest, src *sqlite3.SQLiteConn, progress func(remaining, total int)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	backup, err := dest.Backup("main", src, "main")
+	if err != nil {
+		return fmt.Errorf("failed to start backup: %w", err)
+	}
+
+	if _, err := backup.Step(-1); err != nil {
+		backup.Finish()
+		return fmt.Errorf("backup step failed: %w", err)
+	}
+	if progress != nil {
+		progress(backup.Remaining(), backup.PageCount())
+	}
+	if err := backup.Finish(); err != nil {
+		return fmt.Errorf("failed to finish backup: %w", err)
+	}
+	return nil
+}
+
+// Backup creates a consistent copy of the database at destPath while the
+// host is running using SQLite's online backup API. destPath must not
+// already exist. If progress is not nil, it is called once the database has
+// been copied with the number of pages remaining and the total number of
+// pages. The partial copy is removed if the backup fails or ctx is cancelled
+// before the copy starts.
+//
+// Only the database is copied. Volume files must be snapshotted separately
+// and should be copied after the database so that every sector the backup
+// references is present in the volumes.
+func (s *Store) Backup(ctx context.Context, destPath string, progress func(remaining, total int)) (err error) {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup destination %q already exists", destPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat backup destination: %w", err)
+	}
+
+	dest, err := sql.Open("sqlite3", sqliteFilepath(destPath))
+	if err != nil {
+		return fmt.Errorf("failed to open backup destination: %w", err)
+	}
+	defer func() {
+		if cerr := dest.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close backup destination: %w", cerr)
+		}
+		if err != nil {
+			// remove the partial backup
+			for _, suffix := range []string{"", "-wal", "-shm"} {
+				os.Remove(destPath + suffix)
+			}
+		}
+	}()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to backup destination: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer srcConn.Close()
+
+	log := s.log.Named("backup").With(zap.String("path", destPath))
+	log.Info("starting database backup")
+	err = destConn.Raw(func(destDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			destSQLite, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected destination connection type %T", destDriverConn)
+			}
+			srcSQLite, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected source connection type %T", srcDriverConn)
+			}
+			return backupDB(ctx, destSQLite, srcSQLite, progress)
+		})
+	})
+	if err != nil {
+		log.Error("database backup failed", zap.Error(err))
+		return err
+	}
+	log.Info("database backup complete")
+	return nil
+}
@@ -12,4 +12,14 @@ const (
 
 	// the number of records to limit long-running sector queries to
 	sqlSectorBatchSize = 256 // 1 GiB
+
+	// the number of contracts to prune in a single transaction
+	contractPruneBatchSize = 100
+	// the number of wallet transactions to consider for pruning in a single
+	// transaction
+	walletPruneBatchSize = 1000
+
+	// the interval between write attempts while the database's disk is
+	// full
+	diskFullProbeInterval = 30 * time.Second
 )
@@ -12,4 +12,14 @@ const (
 
 	// the number of records to limit long-running sector queries to
 	sqlSectorBatchSize = 5 // 20 MiB
+
+	// the number of contracts to prune in a single transaction
+	contractPruneBatchSize = 2
+	// the number of wallet transactions to consider for pruning in a single
+	// transaction
+	walletPruneBatchSize = 2
+
+	// the interval between write attempts while the database's disk is
+	// full
+	diskFullProbeInterval = 100 * time.Millisecond
 )
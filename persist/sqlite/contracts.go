@@ -3,8 +3,10 @@ package sqlite
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -48,6 +50,10 @@ func (u *updateContractsTxn) ConfirmFormation(id types.FileContractID) error {
 	if err != nil {
 		return fmt.Errorf("failed to confirm formation: %w", err)
 	}
+	// a confirmed renewal was broadcast and can no longer be reverted
+	if _, err := u.tx.Exec(`DELETE FROM contract_pending_renewals WHERE renewed_contract_id=$1`, dbID); err != nil {
+		return fmt.Errorf("failed to confirm renewal: %w", err)
+	}
 
 	// get the contract's status
 	contract, err := getContract(u.tx, dbID)
@@ -63,9 +69,13 @@ func (u *updateContractsTxn) ConfirmFormation(id types.FileContractID) error {
 	if err := setContractStatus(u.tx, id, contracts.ContractStatusActive); err != nil {
 		return fmt.Errorf("failed to set contract status to active: %w", err)
 	}
+	released, err := collateralReleased(u.tx, dbID)
+	if err != nil {
+		return fmt.Errorf("failed to check if collateral was released: %w", err)
+	}
 	// rejected contracts have already had their collateral and revenue removed,
 	// need to re-add it if the contract is now confirmed
-	if contract.Status == contracts.ContractStatusRejected {
+	if contract.Status == contracts.ContractStatusRejected && !released {
 		if err := incrementCurrencyStat(u.tx, metricLockedCollateral, contract.LockedCollateral, false, time.Now()); err != nil {
 			return fmt.Errorf("failed to increment locked collateral stat: %w", err)
 		} else if err := incrementCurrencyStat(u.tx, metricRiskedCollateral, contract.Usage.RiskedCollateral, false, time.Now()); err != nil {
@@ -86,11 +96,12 @@ func (u *updateContractsTxn) ConfirmRevision(revision types.FileContractRevision
 	return nil
 }
 
-// ConfirmResolution sets the resolution height.
-func (u *updateContractsTxn) ConfirmResolution(id types.FileContractID, height uint64) error {
-	const query = `UPDATE contracts SET resolution_height=$1 WHERE contract_id=$2 RETURNING id;`
+// ConfirmResolution records the block and transaction that confirmed the
+// contract's resolution.
+func (u *updateContractsTxn) ConfirmResolution(id types.FileContractID, index types.ChainIndex, txnID types.TransactionID) error {
+	const query = `UPDATE contracts SET resolution_height=$1, resolution_block_id=$2, resolution_txn_id=$3 WHERE contract_id=$4 RETURNING id;`
 	var dbID int64
-	if err := u.tx.QueryRow(query, height, sqlHash256(id)).Scan(&dbID); err != nil {
+	if err := u.tx.QueryRow(query, index.Height, sqlHash256(index.ID), sqlHash256(txnID), sqlHash256(id)).Scan(&dbID); err != nil {
 		return fmt.Errorf("failed to confirm resolution: %w", err)
 	}
 	return nil
@@ -110,9 +121,9 @@ func (u *updateContractsTxn) RevertRevision(id types.FileContractID) error {
 	return u.tx.QueryRow(query, sqlUint64(0), sqlHash256(id)).Scan(&dbID) // TODO: revert to the previous revision number
 }
 
-// RevertResolution sets the resolution height to null
+// RevertResolution clears the contract's confirmed resolution.
 func (u *updateContractsTxn) RevertResolution(id types.FileContractID) error {
-	const query = `UPDATE contracts SET resolution_height=NULL WHERE contract_id=$1 RETURNING id;`
+	const query = `UPDATE contracts SET resolution_height=NULL, resolution_block_id=NULL, resolution_txn_id=NULL WHERE contract_id=$1 RETURNING id;`
 	var dbID int64
 	if err := u.tx.QueryRow(query, sqlHash256(id)).Scan(&dbID); err != nil {
 		return fmt.Errorf("failed to revert resolution: %w", err)
@@ -135,8 +146,9 @@ func deleteExpiredContractSectors(tx txn, height uint64) (sectorIDs []int64, err
 	const query = `DELETE FROM contract_sector_roots
 WHERE id IN (SELECT csr.id FROM contract_sector_roots csr
 INNER JOIN contracts c ON (csr.contract_id=c.id)
--- past proof window or not confirmed and past the rebroadcast height
-WHERE c.window_end < $1 OR c.contract_status=$2 LIMIT $3)
+-- past proof window or not confirmed and past the rebroadcast height, pinned
+-- contracts keep their sectors
+WHERE (c.window_end < $1 OR c.contract_status=$2) AND c.pinned=false LIMIT $3)
 RETURNING sector_id;`
 	rows, err := tx.Query(query, height, contracts.ContractStatusRejected, sqlSectorBatchSize)
 	if err != nil {
@@ -172,10 +184,84 @@ func (s *Store) batchExpireContractSectors(height uint64) (expired int, removed
 	return
 }
 
-// Contracts returns a paginated list of contracts.
-func (s *Store) Contracts(filter contracts.ContractFilter) (contracts []contracts.Contract, count int, err error) {
-	if filter.Limit <= 0 || filter.Limit > 100 {
-		filter.Limit = 100
+func deleteExpiredVolumeContractSectors(tx txn, volumeID int64, minIndex, height uint64) (sectorIDs []int64, err error) {
+	const query = `DELETE FROM contract_sector_roots
+WHERE id IN (SELECT csr.id FROM contract_sector_roots csr
+INNER JOIN contracts c ON (csr.contract_id=c.id)
+INNER JOIN volume_sectors vs ON (csr.sector_id=vs.sector_id)
+-- same expiration rules as deleteExpiredContractSectors
+WHERE vs.volume_id=$1 AND vs.volume_index >= $2 AND (c.window_end < $3 OR c.contract_status=$4) AND c.pinned=false LIMIT $5)
+RETURNING sector_id;`
+	rows, err := tx.Query(query, volumeID, minIndex, height, contracts.ContractStatusRejected, sqlSectorBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		sectorIDs = append(sectorIDs, id)
+	}
+	return sectorIDs, rows.Err()
+}
+
+// ReclaimableSectors returns the number of sectors in a volume that are only
+// referenced by contracts that expired before height.
+func (s *Store) ReclaimableSectors(volumeID int64, height uint64) (count uint64, err error) {
+	const query = `SELECT COUNT(*) FROM volume_sectors vs
+WHERE vs.volume_id=$1 AND vs.sector_id IS NOT NULL
+AND EXISTS (SELECT 1 FROM contract_sector_roots csr WHERE csr.sector_id=vs.sector_id)
+AND NOT EXISTS (SELECT 1 FROM contract_sector_roots csr
+	INNER JOIN contracts c ON (csr.contract_id=c.id)
+	WHERE csr.sector_id=vs.sector_id AND ((c.window_end >= $2 AND c.contract_status<>$3) OR c.pinned=true))
+AND NOT EXISTS (SELECT 1 FROM temp_storage_sector_roots tsr WHERE tsr.sector_id=vs.sector_id);`
+	err = s.queryRow(query, volumeID, height, contracts.ContractStatusRejected).Scan(&count)
+	return
+}
+
+// ReclaimExpiredSectors removes the references of contracts that expired
+// before height to sectors stored in the volume at or after minIndex. Sectors
+// that are no longer referenced are removed and their roots returned.
+func (s *Store) ReclaimExpiredSectors(volumeID int64, minIndex, height uint64) (reclaimed []types.Hash256, err error) {
+	log := s.log.Named("ReclaimExpiredSectors").With(zap.Int64("volume", volumeID), zap.Uint64("minIndex", minIndex), zap.Uint64("height", height))
+	for i := 0; ; i++ {
+		var expired int
+		var removed []types.Hash256
+		err := s.transaction(func(tx txn) (err error) {
+			sectorIDs, err := deleteExpiredVolumeContractSectors(tx, volumeID, minIndex, height)
+			if err != nil {
+				return fmt.Errorf("failed to delete contract sectors: %w", err)
+			}
+			expired = len(sectorIDs)
+
+			if err := incrementNumericStat(tx, metricContractSectors, -len(sectorIDs), time.Now()); err != nil {
+				return fmt.Errorf("failed to decrement contract sectors: %w", err)
+			}
+
+			removed, err = pruneSectors(tx, sectorIDs)
+			if err != nil {
+				return fmt.Errorf("failed to prune sectors: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		} else if expired == 0 {
+			return reclaimed, nil
+		}
+		reclaimed = append(reclaimed, removed...)
+		log.Debug("reclaimed sectors", zap.Int("expired", expired), zap.Int("batch", i))
+		jitterSleep(time.Millisecond)
+	}
+}
+
+// Contracts returns a paginated list of contracts matching the filter and the
+// total number of matching contracts.
+func (s *Store) Contracts(filter contracts.ContractFilter) (results []contracts.Contract, count int, err error) {
+	if filter.Limit <= 0 || filter.Limit > contracts.MaxContractsLimit {
+		filter.Limit = contracts.MaxContractsLimit
 	}
 
 	whereClause, whereParams, err := buildContractFilter(filter)
@@ -183,13 +269,41 @@ func (s *Store) Contracts(filter contracts.ContractFilter) (contracts []contract
 		return nil, 0, fmt.Errorf("failed to build where clause: %w", err)
 	}
 
+	// the cursor only limits the page, the count includes every matching
+	// contract
+	pageClause, pageParams := whereClause, whereParams
+	if filter.After != (types.FileContractID{}) {
+		// seek past the cursor's sort value and row ID instead of skipping
+		// rows with OFFSET
+		var value, id int64
+		col := sortColumn(filter)
+		err := s.queryRow(`SELECT `+col+`, id FROM contracts WHERE contract_id=$1`, sqlHash256(filter.After)).Scan(&value, &id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, fmt.Errorf("cursor %v: %w", filter.After, contracts.ErrNotFound)
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("failed to get cursor: %w", err)
+		}
+		op := ">"
+		if filter.SortDesc {
+			op = "<"
+		}
+		cursor := `(c.` + col + `, c.id) ` + op + ` (?, ?)`
+		if pageClause == "" {
+			pageClause = "WHERE " + cursor
+		} else {
+			pageClause += " AND " + cursor
+		}
+		pageParams = append(append([]any(nil), whereParams...), value, id)
+	}
+
 	contractQuery := fmt.Sprintf(`SELECT c.contract_id, rt.contract_id AS renewed_to, rf.contract_id AS renewed_from, c.contract_status, c.negotiation_height, c.formation_confirmed, 
-	c.revision_number=c.confirmed_revision_number AS revision_confirmed, c.resolution_height, c.locked_collateral, c.rpc_revenue,
-	c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.account_funding, c.risked_collateral, c.raw_revision, c.host_sig, c.renter_sig 
+	c.revision_number=c.confirmed_revision_number AS revision_confirmed, c.resolution_height, c.resolution_block_id, c.resolution_txn_id, c.locked_collateral, c.rpc_revenue,
+	c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.account_funding, c.risked_collateral, c.raw_revision, c.host_sig, c.renter_sig, c.failure_reason, c.pinned, c.quarantine_reason, c.proof_skip_reason,
+	(SELECT json_group_array(tag) FROM contract_tags WHERE contract_id=c.id) AS tags
 FROM contracts c
 INNER JOIN contract_renters r ON (c.renter_id=r.id)
 LEFT JOIN contracts rt ON (c.renewed_to=rt.id)
-LEFT JOIN contracts rf ON (c.renewed_from=rf.id) %s %s LIMIT ? OFFSET ?`, whereClause, buildOrderBy(filter))
+LEFT JOIN contracts rf ON (c.renewed_from=rf.id) %s %s LIMIT ? OFFSET ?`, pageClause, buildOrderBy(filter))
 
 	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM contracts c
 INNER JOIN contract_renters r ON (c.renter_id=r.id)
@@ -200,7 +314,7 @@ LEFT JOIN contracts rf ON (c.renewed_from=rf.id) %s`, whereClause)
 		return nil, 0, fmt.Errorf("failed to query contract count: %w", err)
 	}
 
-	rows, err := s.query(contractQuery, append(whereParams, filter.Limit, filter.Offset)...)
+	rows, err := s.query(contractQuery, append(pageParams, filter.Limit, filter.Offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to query contracts: %w", err)
 	}
@@ -211,7 +325,7 @@ LEFT JOIN contracts rf ON (c.renewed_from=rf.id) %s`, whereClause)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan contract: %w", err)
 		}
-		contracts = append(contracts, contract)
+		results = append(results, contract)
 	}
 	return
 }
@@ -252,6 +366,19 @@ func (s *Store) RenewContract(renewal contracts.SignedRevision, clearing contrac
 			return fmt.Errorf("failed to insert renewed contract: %w", err)
 		}
 
+		// record the existing contract's state so the renewal can be
+		// reverted if it is never broadcast
+		const pendingQuery = `INSERT INTO contract_pending_renewals (renewed_contract_id, cleared_contract_id, previous_revision_number, previous_raw_revision, previous_host_sig, previous_renter_sig,
+previous_rpc_revenue, previous_storage_revenue, previous_ingress_revenue, previous_egress_revenue, previous_account_funding, previous_risked_collateral, date_created)
+SELECT $1, id, revision_number, raw_revision, host_sig, renter_sig, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_funding, risked_collateral, $2 FROM contracts WHERE contract_id=$3;`
+		if res, err := tx.Exec(pendingQuery, renewedDBID, sqlTime(time.Now()), sqlHash256(clearing.Revision.ParentID)); err != nil {
+			return fmt.Errorf("failed to record pending renewal: %w", err)
+		} else if n, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		} else if n != 1 {
+			return fmt.Errorf("failed to record pending renewal: %w", contracts.ErrNotFound)
+		}
+
 		clearedDBID, err := clearContract(tx, clearing, renewedDBID, clearingUsage)
 		if err != nil {
 			return fmt.Errorf("faile to clear contract: %w", err)
@@ -271,6 +398,131 @@ func (s *Store) RenewContract(renewal contracts.SignedRevision, clearing contrac
 	})
 }
 
+// PendingRenewals returns the IDs of renewed contracts that were recorded, but
+// have not been confirmed as broadcast.
+func (s *Store) PendingRenewals() (ids []types.FileContractID, err error) {
+	rows, err := s.query(`SELECT c.contract_id FROM contract_pending_renewals p INNER JOIN contracts c ON p.renewed_contract_id=c.id ORDER BY p.id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending renewals: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id types.FileContractID
+		if err := rows.Scan((*sqlHash256)(&id)); err != nil {
+			return nil, fmt.Errorf("failed to scan contract id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ConfirmRenewal marks a renewal as broadcast. The renewal can no longer be
+// reverted.
+func (s *Store) ConfirmRenewal(renewalID types.FileContractID) error {
+	_, err := s.exec(`DELETE FROM contract_pending_renewals WHERE renewed_contract_id=(SELECT id FROM contracts WHERE contract_id=$1)`, sqlHash256(renewalID))
+	return err
+}
+
+// RevertRenewal atomically undoes a renewal that was never broadcast. The
+// renewed contract is removed and the existing contract is restored to its
+// state before the renewal, including its sector roots.
+// contracts.ErrNotFound is returned if the renewal is not pending.
+// contracts.ErrRenewalRevised is returned if the renewal's filesize or Merkle
+// root no longer match the existing contract's revision before the renewal.
+func (s *Store) RevertRenewal(renewalID types.FileContractID) error {
+	return s.transaction(func(tx txn) error {
+		var renewedDBID, clearedDBID int64
+		var status contracts.ContractStatus
+		var lockedCollateral types.Currency
+		var usage contracts.Usage
+		const query = `SELECT c.id, p.cleared_contract_id, c.contract_status, c.locked_collateral, c.rpc_revenue, c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.registry_read, c.registry_write, c.account_funding, c.risked_collateral
+FROM contract_pending_renewals p INNER JOIN contracts c ON p.renewed_contract_id=c.id WHERE c.contract_id=$1`
+		err := tx.QueryRow(query, sqlHash256(renewalID)).Scan(&renewedDBID, &clearedDBID, &status,
+			(*sqlCurrency)(&lockedCollateral),
+			(*sqlCurrency)(&usage.RPCRevenue),
+			(*sqlCurrency)(&usage.StorageRevenue),
+			(*sqlCurrency)(&usage.IngressRevenue),
+			(*sqlCurrency)(&usage.EgressRevenue),
+			(*sqlCurrency)(&usage.RegistryRead),
+			(*sqlCurrency)(&usage.RegistryWrite),
+			(*sqlCurrency)(&usage.AccountFunding),
+			(*sqlCurrency)(&usage.RiskedCollateral))
+		if errors.Is(err, sql.ErrNoRows) {
+			return contracts.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get pending renewal: %w", err)
+		} else if status != contracts.ContractStatusPending {
+			return fmt.Errorf("cannot revert renewal of %v contract", status)
+		}
+
+		// the sector roots can only be moved back if the renter has not
+		// changed them since the renewal
+		var renewedBuf, previousBuf []byte
+		var renewed, previous types.FileContractRevision
+		err = tx.QueryRow(`SELECT c.raw_revision, p.previous_raw_revision FROM contract_pending_renewals p INNER JOIN contracts c ON p.renewed_contract_id=c.id WHERE c.id=$1`, renewedDBID).Scan(&renewedBuf, &previousBuf)
+		if err != nil {
+			return fmt.Errorf("failed to get renewal revisions: %w", err)
+		} else if err := decodeRevision(renewedBuf, &renewed); err != nil {
+			return fmt.Errorf("failed to decode renewed revision: %w", err)
+		} else if err := decodeRevision(previousBuf, &previous); err != nil {
+			return fmt.Errorf("failed to decode previous revision: %w", err)
+		} else if renewed.Filesize != previous.Filesize || renewed.FileMerkleRoot != previous.FileMerkleRoot {
+			return contracts.ErrRenewalRevised
+		}
+
+		// the existing contract's collateral is at risk again if it was
+		// released when the contract was cleared. The released amounts are
+		// read before the contract is restored.
+		var released bool
+		var clearedLocked, clearedRisked types.Currency
+		err = tx.QueryRow(`SELECT collateral_released, locked_collateral, risked_collateral FROM contracts WHERE id=$1`, clearedDBID).Scan(&released, (*sqlCurrency)(&clearedLocked), (*sqlCurrency)(&clearedRisked))
+		if err != nil {
+			return fmt.Errorf("failed to get existing contract collateral: %w", err)
+		} else if released {
+			if err := incrementCurrencyStat(tx, metricLockedCollateral, clearedLocked, false, time.Now()); err != nil {
+				return fmt.Errorf("failed to track locked collateral: %w", err)
+			} else if err := incrementCurrencyStat(tx, metricRiskedCollateral, clearedRisked, false, time.Now()); err != nil {
+				return fmt.Errorf("failed to track risked collateral: %w", err)
+			}
+		}
+
+		// restore the existing contract
+		const restoreQuery = `UPDATE contracts SET (renewed_to, revision_number, raw_revision, host_sig, renter_sig, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, account_funding, risked_collateral, collateral_released) =
+(NULL, p.previous_revision_number, p.previous_raw_revision, p.previous_host_sig, p.previous_renter_sig, p.previous_rpc_revenue, p.previous_storage_revenue, p.previous_ingress_revenue, p.previous_egress_revenue, p.previous_account_funding, p.previous_risked_collateral, false)
+FROM contract_pending_renewals p WHERE p.renewed_contract_id=$1 AND contracts.id=p.cleared_contract_id`
+		if _, err := tx.Exec(restoreQuery, renewedDBID); err != nil {
+			return fmt.Errorf("failed to restore existing contract: %w", err)
+		} else if _, err := tx.Exec(`UPDATE contract_sector_roots SET contract_id=$1 WHERE contract_id=$2`, clearedDBID, renewedDBID); err != nil {
+			return fmt.Errorf("failed to restore sector roots: %w", err)
+		}
+
+		// remove the renewed contract
+		if _, err := tx.Exec(`DELETE FROM contract_pending_renewals WHERE renewed_contract_id=$1`, renewedDBID); err != nil {
+			return fmt.Errorf("failed to delete pending renewal: %w", err)
+		} else if _, err := tx.Exec(`DELETE FROM contract_action_results WHERE contract_id=$1`, renewedDBID); err != nil {
+			return fmt.Errorf("failed to delete action results: %w", err)
+		} else if _, err := tx.Exec(`DELETE FROM contract_account_funding WHERE contract_id=$1`, renewedDBID); err != nil {
+			return fmt.Errorf("failed to delete account funding: %w", err)
+		} else if _, err := tx.Exec(`DELETE FROM contract_formation_inputs WHERE contract_id=$1`, renewedDBID); err != nil {
+			return fmt.Errorf("failed to delete formation inputs: %w", err)
+		} else if _, err := tx.Exec(`DELETE FROM contracts WHERE id=$1`, renewedDBID); err != nil {
+			return fmt.Errorf("failed to delete renewed contract: %w", err)
+		}
+
+		// undo the renewed contract's metrics
+		if err := incrementNumericStat(tx, metricPendingContracts, -1, time.Now()); err != nil {
+			return fmt.Errorf("failed to track pending contracts: %w", err)
+		} else if err := incrementCurrencyStat(tx, metricLockedCollateral, lockedCollateral, true, time.Now()); err != nil {
+			return fmt.Errorf("failed to track locked collateral: %w", err)
+		} else if err := incrementCurrencyStat(tx, metricRiskedCollateral, usage.RiskedCollateral, true, time.Now()); err != nil {
+			return fmt.Errorf("failed to track risked collateral: %w", err)
+		} else if err := incrementPotentialRevenueMetrics(tx, usage, true); err != nil {
+			return fmt.Errorf("failed to track potential revenue: %w", err)
+		}
+		return nil
+	})
+}
+
 // ReviseContract atomically updates a contract's revision and sectors
 func (s *Store) ReviseContract(revision contracts.SignedRevision, roots []types.Hash256, usage contracts.Usage, sectorChanges []contracts.SectorChange) error {
 	return s.transaction(func(tx txn) error {
@@ -345,6 +597,111 @@ func (s *Store) ReviseContract(revision contracts.SignedRevision, roots []types.
 	})
 }
 
+// SectorContracts returns the IDs of the contracts that reference any of the
+// sectors with the given roots. References are transferred when a contract is
+// renewed and removed when it is pruned, so only contracts that would be
+// affected by the loss of the sectors are returned.
+func (s *Store) SectorContracts(roots ...types.Hash256) (ids []types.FileContractID, err error) {
+	// the roots are queried in batches to stay under SQLite's variable limit
+	contractIDs := make(map[int64]types.FileContractID)
+	for i := 0; i < len(roots); i += sqlSectorBatchSize {
+		batch := roots[i:min(i+sqlSectorBatchSize, len(roots))]
+		args := make([]any, 0, len(batch))
+		for _, root := range batch {
+			args = append(args, sqlHash256(root))
+		}
+
+		query := `SELECT DISTINCT c.id, c.contract_id FROM contract_sector_roots csr
+INNER JOIN stored_sectors ss ON (csr.sector_id=ss.id)
+INNER JOIN contracts c ON (csr.contract_id=c.id)
+WHERE ss.sector_root IN (` + queryPlaceHolders(len(batch)) + `)`
+		err := func() error {
+			rows, err := s.query(query, args...)
+			if err != nil {
+				return fmt.Errorf("failed to query sector contracts: %w", err)
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var dbID int64
+				var id types.FileContractID
+				if err := rows.Scan(&dbID, (*sqlHash256)(&id)); err != nil {
+					return fmt.Errorf("failed to scan contract id: %w", err)
+				}
+				contractIDs[dbID] = id
+			}
+			return rows.Err()
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// order the contracts by when they were added
+	dbIDs := make([]int64, 0, len(contractIDs))
+	for dbID := range contractIDs {
+		dbIDs = append(dbIDs, dbID)
+	}
+	sort.Slice(dbIDs, func(i, j int) bool { return dbIDs[i] < dbIDs[j] })
+	for _, dbID := range dbIDs {
+		ids = append(ids, contractIDs[dbID])
+	}
+	return ids, nil
+}
+
+// MissingContractSectors returns the roots of the sectors referenced by
+// active or pending contracts that are not stored in any volume, grouped by
+// contract.
+func (s *Store) MissingContractSectors() (map[types.FileContractID][]types.Hash256, error) {
+	const query = `SELECT c.contract_id, ss.sector_root FROM contract_sector_roots csr
+INNER JOIN contracts c ON (csr.contract_id=c.id)
+INNER JOIN stored_sectors ss ON (csr.sector_id=ss.id)
+LEFT JOIN volume_sectors vs ON (vs.sector_id=ss.id)
+WHERE vs.id IS NULL AND c.contract_status IN ($1, $2)
+ORDER BY c.id ASC, csr.root_index ASC`
+	rows, err := s.query(query, contracts.ContractStatusPending, contracts.ContractStatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query missing sectors: %w", err)
+	}
+	defer rows.Close()
+
+	missing := make(map[types.FileContractID][]types.Hash256)
+	for rows.Next() {
+		var id types.FileContractID
+		var root types.Hash256
+		if err := rows.Scan((*sqlHash256)(&id), (*sqlHash256)(&root)); err != nil {
+			return nil, fmt.Errorf("failed to scan sector: %w", err)
+		}
+		missing[id] = append(missing[id], root)
+	}
+	return missing, rows.Err()
+}
+
+// VolumeContracts returns the number of sectors stored in the volume for each
+// active or pending contract that references them.
+func (s *Store) VolumeContracts(volumeID int64) (map[types.FileContractID]uint64, error) {
+	const query = `SELECT c.contract_id, COUNT(*) FROM contract_sector_roots csr
+INNER JOIN contracts c ON (csr.contract_id=c.id)
+INNER JOIN volume_sectors vs ON (vs.sector_id=csr.sector_id)
+WHERE vs.volume_id=$1 AND c.contract_status IN ($2, $3)
+GROUP BY c.id`
+	rows, err := s.query(query, volumeID, contracts.ContractStatusPending, contracts.ContractStatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query volume contracts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[types.FileContractID]uint64)
+	for rows.Next() {
+		var id types.FileContractID
+		var n uint64
+		if err := rows.Scan((*sqlHash256)(&id), &n); err != nil {
+			return nil, fmt.Errorf("failed to scan contract: %w", err)
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
+
 // SectorRoots returns the sector roots for a contract. The contract must be
 // locked before calling.
 func (s *Store) SectorRoots(contractID types.FileContractID) (roots []types.Hash256, err error) {
@@ -397,6 +754,94 @@ LIMIT 5000`
 	}
 }
 
+// SectorRootsInsertionOrder returns the sector roots for a contract ordered by
+// when their records were created instead of by their index. Appends create
+// records in index order and swaps and updates modify records in place, so
+// the two orders only differ if the indices were corrupted.
+func (s *Store) SectorRootsInsertionOrder(contractID types.FileContractID) (roots []types.Hash256, err error) {
+	const query = `SELECT ss.sector_root FROM contract_sector_roots csr
+INNER JOIN stored_sectors ss ON (csr.sector_id=ss.id)
+WHERE csr.contract_id=(SELECT id FROM contracts WHERE contract_id=$1)
+ORDER BY csr.id ASC`
+	rows, err := s.query(query, sqlHash256(contractID))
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var root types.Hash256
+		if err := rows.Scan((*sqlHash256)(&root)); err != nil {
+			return nil, fmt.Errorf("failed to scan sector root: %w", err)
+		}
+		roots = append(roots, root)
+	}
+	return roots, rows.Err()
+}
+
+// SetSectorRootOrder reassigns the indices of a contract's sector roots so
+// that they are returned in the order of roots. roots must contain exactly
+// the roots currently stored for the contract.
+func (s *Store) SetSectorRootOrder(contractID types.FileContractID, roots []types.Hash256) error {
+	return s.transaction(func(tx txn) error {
+		var dbID int64
+		if err := tx.QueryRow(`SELECT id FROM contracts WHERE contract_id=$1`, sqlHash256(contractID)).Scan(&dbID); errors.Is(err, sql.ErrNoRows) {
+			return contracts.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get contract id: %w", err)
+		}
+
+		rows, err := tx.Query(`SELECT csr.id, csr.sector_id, ss.sector_root FROM contract_sector_roots csr
+INNER JOIN stored_sectors ss ON (csr.sector_id=ss.id)
+WHERE csr.contract_id=$1
+ORDER BY csr.root_index ASC`, dbID)
+		if err != nil {
+			return fmt.Errorf("failed to query sector roots: %w", err)
+		}
+		// a root may be stored more than once in a contract
+		records := make(map[types.Hash256][]int64)
+		var n int
+		for rows.Next() {
+			ref, err := scanContractSectorRootRef(rows)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan sector ref: %w", err)
+			}
+			records[ref.root] = append(records[ref.root], ref.dbID)
+			n++
+		}
+		if err := rows.Close(); err != nil {
+			return fmt.Errorf("failed to close rows: %w", err)
+		} else if n != len(roots) {
+			return fmt.Errorf("expected %v roots, got %v", n, len(roots))
+		}
+
+		// negate the existing indices so the new indices do not conflict
+		// with the unique constraint while they are reassigned
+		if _, err := tx.Exec(`UPDATE contract_sector_roots SET root_index=-1-root_index WHERE contract_id=$1`, dbID); err != nil {
+			return fmt.Errorf("failed to clear root indices: %w", err)
+		}
+
+		stmt, err := tx.Prepare(`UPDATE contract_sector_roots SET root_index=$1 WHERE id=$2`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare update statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for i, root := range roots {
+			ids := records[root]
+			if len(ids) == 0 {
+				return fmt.Errorf("root %v at index %v is not stored in the contract", root, i)
+			}
+			records[root] = ids[1:]
+			if _, err := stmt.Exec(i, ids[0]); err != nil {
+				return fmt.Errorf("failed to update root index %v: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
 // ContractAction calls contractFn on every contract in the store that
 // needs a lifecycle action performed.
 func (s *Store) ContractAction(height uint64, contractFn func(types.FileContractID, uint64, string)) error {
@@ -439,6 +884,62 @@ func (s *Store) ContractAction(height uint64, contractFn func(types.FileContract
 	return nil
 }
 
+// AddContractActionResult records the outcome of a contract lifecycle
+// action.
+func (s *Store) AddContractActionResult(result contracts.ActionResult) error {
+	const query = `INSERT INTO contract_action_results (contract_id, block_height, action, action_status, transaction_ids, fee, error_message, proof_failure_cause, date_created)
+SELECT id, $1, $2, $3, $4, $5, $6, $7, $8 FROM contracts WHERE contract_id=$9;`
+
+	var errMsg, cause *string
+	if result.Error != "" {
+		errMsg = &result.Error
+	}
+	if result.Cause != "" {
+		c := string(result.Cause)
+		cause = &c
+	}
+	res, err := s.exec(query, result.Height, result.Action, result.Status, encodeTxnIDs(result.TransactionIDs), sqlCurrency(result.Fee), errMsg, cause, sqlTime(result.Timestamp), sqlHash256(result.ContractID))
+	if err != nil {
+		return fmt.Errorf("failed to insert action result: %w", err)
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	} else if n != 1 {
+		return contracts.ErrNotFound
+	}
+	return nil
+}
+
+// ContractActionResults returns the recorded lifecycle actions for a
+// contract, oldest first.
+func (s *Store) ContractActionResults(id types.FileContractID) (results []contracts.ActionResult, err error) {
+	const query = `SELECT car.block_height, car.action, car.action_status, car.transaction_ids, car.fee, car.error_message, car.proof_failure_cause, car.date_created
+FROM contract_action_results car
+INNER JOIN contracts c ON car.contract_id=c.id
+WHERE c.contract_id=$1
+ORDER BY car.id ASC;`
+
+	rows, err := s.query(query, sqlHash256(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query action results: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		result := contracts.ActionResult{ContractID: id}
+		var txnIDs []byte
+		var errMsg, cause sql.NullString
+		if err := rows.Scan(&result.Height, &result.Action, &result.Status, &txnIDs, (*sqlCurrency)(&result.Fee), &errMsg, &cause, (*sqlTime)(&result.Timestamp)); err != nil {
+			return nil, fmt.Errorf("failed to scan action result: %w", err)
+		} else if result.TransactionIDs, err = decodeTxnIDs(txnIDs); err != nil {
+			return nil, fmt.Errorf("failed to decode transaction IDs: %w", err)
+		}
+		result.Error = errMsg.String
+		result.Cause = contracts.ProofFailureCause(cause.String)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
 // ContractFormationSet returns the set of transactions that were created during
 // contract formation.
 func (s *Store) ContractFormationSet(id types.FileContractID) ([]types.Transaction, error) {
@@ -454,51 +955,331 @@ func (s *Store) ContractFormationSet(id types.FileContractID) ([]types.Transacti
 	return txnSet, nil
 }
 
+// PendingFormationSpends returns the pending contracts with a formation input
+// in outputs and the inputs of each contract that were spent.
+func (s *Store) PendingFormationSpends(outputs []types.SiacoinOutputID) (spends map[types.FileContractID][]types.SiacoinOutputID, err error) {
+	const query = `SELECT c.contract_id FROM contract_formation_inputs cfi
+INNER JOIN contracts c ON (c.id=cfi.contract_id)
+WHERE cfi.output_id=$1 AND c.contract_status=$2`
+
+	spends = make(map[types.FileContractID][]types.SiacoinOutputID)
+	err = s.transaction(func(tx txn) error {
+		stmt, err := tx.Prepare(query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, output := range outputs {
+			if err := func() error {
+				rows, err := stmt.Query(sqlHash256(output), contracts.ContractStatusPending)
+				if err != nil {
+					return fmt.Errorf("failed to query contracts: %w", err)
+				}
+				defer rows.Close()
+				for rows.Next() {
+					var id types.FileContractID
+					if err := rows.Scan((*sqlHash256)(&id)); err != nil {
+						return fmt.Errorf("failed to scan contract id: %w", err)
+					}
+					spends[id] = append(spends[id], output)
+				}
+				return rows.Err()
+			}(); err != nil {
+				return fmt.Errorf("failed to get contracts spending %v: %w", output, err)
+			}
+		}
+		return nil
+	})
+	return
+}
+
 // ExpireContract expires a contract and updates its status. Should only be used
 // if the contract is active or pending.
 func (s *Store) ExpireContract(id types.FileContractID, status contracts.ContractStatus) error {
 	return s.transaction(func(tx txn) error {
-		var contractID int64
-		err := tx.QueryRow(`SELECT id FROM contracts WHERE contract_id=$1;`, sqlHash256(id)).Scan(&contractID)
+		return expireContract(tx, id, status)
+	})
+}
+
+// SetContractPinned sets whether a contract is pinned. Pinned contracts are not
+// pruned.
+func (s *Store) SetContractPinned(id types.FileContractID, pinned bool) error {
+	return s.transaction(func(tx txn) error {
+		var dbID int64
+		err := tx.QueryRow(`UPDATE contracts SET pinned=$1 WHERE contract_id=$2 RETURNING id;`, pinned, sqlHash256(id)).Scan(&dbID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return contracts.ErrNotFound
+		}
+		return err
+	})
+}
+
+// SetContractTags replaces the tags of a contract.
+func (s *Store) SetContractTags(id types.FileContractID, tags []string) error {
+	return s.transaction(func(tx txn) error {
+		var dbID int64
+		err := tx.QueryRow(`SELECT id FROM contracts WHERE contract_id=$1;`, sqlHash256(id)).Scan(&dbID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return contracts.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get contract: %w", err)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM contract_tags WHERE contract_id=$1;`, dbID); err != nil {
+			return fmt.Errorf("failed to delete tags: %w", err)
+		}
+		stmt, err := tx.Prepare(`INSERT INTO contract_tags (contract_id, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING;`)
 		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+		for _, tag := range tags {
+			if _, err := stmt.Exec(dbID, tag); err != nil {
+				return fmt.Errorf("failed to add tag %q: %w", tag, err)
+			}
+		}
+		return nil
+	})
+}
+
+// SetContractQuarantine sets whether a contract is quarantined and the reason
+// it was quarantined.
+func (s *Store) SetContractQuarantine(id types.FileContractID, quarantined bool, reason string) error {
+	var reasonValue sql.NullString
+	if quarantined {
+		reasonValue = sql.NullString{String: reason, Valid: true}
+	}
+	return s.transaction(func(tx txn) error {
+		var dbID int64
+		err := tx.QueryRow(`UPDATE contracts SET quarantine_reason=$1 WHERE contract_id=$2 RETURNING id;`, reasonValue, sqlHash256(id)).Scan(&dbID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return contracts.ErrNotFound
+		}
+		return err
+	})
+}
+
+// SetProofSkipReason records why the host decided not to submit a contract's
+// storage proof. An empty reason clears it.
+func (s *Store) SetProofSkipReason(id types.FileContractID, reason string) error {
+	var reasonValue sql.NullString
+	if reason != "" {
+		reasonValue = sql.NullString{String: reason, Valid: true}
+	}
+	return s.transaction(func(tx txn) error {
+		var dbID int64
+		err := tx.QueryRow(`UPDATE contracts SET proof_skip_reason=$1 WHERE contract_id=$2 RETURNING id;`, reasonValue, sqlHash256(id)).Scan(&dbID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return contracts.ErrNotFound
+		}
+		return err
+	})
+}
+
+// ReleaseCollateral removes a cleared contract's locked and risked
+// collateral from the collateral metrics before the contract expires. It is a
+// no-op if the contract is not pending or active, or if its collateral was
+// already released.
+func (s *Store) ReleaseCollateral(id types.FileContractID) error {
+	return s.transaction(func(tx txn) error {
+		var dbID int64
+		err := tx.QueryRow(`SELECT id FROM contracts WHERE contract_id=$1;`, sqlHash256(id)).Scan(&dbID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return contracts.ErrNotFound
+		} else if err != nil {
 			return fmt.Errorf("failed to get contract id: %w", err)
 		}
-		// get the contract and check if the status is already set
-		contract, err := getContract(tx, contractID)
+
+		contract, err := getContract(tx, dbID)
 		if err != nil {
 			return fmt.Errorf("failed to get contract: %w", err)
-		} else if contract.Status == status {
+		} else if contract.Status != contracts.ContractStatusActive && contract.Status != contracts.ContractStatusPending {
+			return nil
+		} else if contract.Revision.RevisionNumber != types.MaxRevisionNumber {
+			return errors.New("contract has not been cleared")
+		}
+
+		released, err := collateralReleased(tx, dbID)
+		if err != nil {
+			return fmt.Errorf("failed to check if collateral was released: %w", err)
+		} else if released {
 			return nil
 		}
 
-		if contract.Status == contracts.ContractStatusActive || contract.Status == contracts.ContractStatusPending {
-			// successful, failed and rejected contracts should have already had
-			// their collateral removed from the metrics
+		if err := incrementCurrencyStat(tx, metricLockedCollateral, contract.LockedCollateral, true, time.Now()); err != nil {
+			return fmt.Errorf("failed to decrement locked collateral stat: %w", err)
+		} else if err := incrementCurrencyStat(tx, metricRiskedCollateral, contract.Usage.RiskedCollateral, true, time.Now()); err != nil {
+			return fmt.Errorf("failed to decrement risked collateral stat: %w", err)
+		}
+		_, err = tx.Exec(`UPDATE contracts SET collateral_released=true WHERE id=$1;`, dbID)
+		if err != nil {
+			return fmt.Errorf("failed to set collateral released: %w", err)
+		}
+		return nil
+	})
+}
+
+// FailContract marks a contract as failed and records the reason it failed.
+func (s *Store) FailContract(id types.FileContractID, reason string) error {
+	return s.transaction(func(tx txn) error {
+		if err := expireContract(tx, id, contracts.ContractStatusFailed); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE contracts SET failure_reason=$1 WHERE contract_id=$2;`, reason, sqlHash256(id))
+		if err != nil {
+			return fmt.Errorf("failed to set failure reason: %w", err)
+		}
+		return nil
+	})
+}
+
+// RecordProofFailure marks a contract as failed and records why its storage
+// proof was not submitted.
+func (s *Store) RecordProofFailure(failure contracts.ProofFailure) error {
+	return s.transaction(func(tx txn) error {
+		if err := expireContract(tx, failure.ContractID, contracts.ContractStatusFailed); err != nil {
+			return err
+		}
+		var dbID int64
+		err := tx.QueryRow(`UPDATE contracts SET failure_reason=$1 WHERE contract_id=$2 RETURNING id;`, failure.Reason, sqlHash256(failure.ContractID)).Scan(&dbID)
+		if err != nil {
+			return fmt.Errorf("failed to set failure reason: %w", err)
+		}
+		_, err = tx.Exec(`INSERT INTO contract_proof_failures (contract_id, block_height, cause, reason, date_created) VALUES ($1, $2, $3, $4, $5);`, dbID, failure.Height, string(failure.Cause), failure.Reason, sqlTime(failure.Timestamp))
+		if err != nil {
+			return fmt.Errorf("failed to add proof failure: %w", err)
+		}
+		return nil
+	})
+}
+
+// ProofFailures returns the proof failures recorded since the given time,
+// oldest first.
+func (s *Store) ProofFailures(since time.Time) (failures []contracts.ProofFailure, err error) {
+	const query = `SELECT c.contract_id, pf.block_height, pf.cause, pf.reason, pf.date_created
+FROM contract_proof_failures pf
+INNER JOIN contracts c ON pf.contract_id=c.id
+WHERE pf.date_created >= $1
+ORDER BY pf.date_created ASC, pf.id ASC;`
+
+	rows, err := s.query(query, sqlTime(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query proof failures: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var failure contracts.ProofFailure
+		var cause string
+		if err := rows.Scan((*sqlHash256)(&failure.ContractID), &failure.Height, &cause, &failure.Reason, (*sqlTime)(&failure.Timestamp)); err != nil {
+			return nil, fmt.Errorf("failed to scan proof failure: %w", err)
+		}
+		failure.Cause = contracts.ProofFailureCause(cause)
+		failures = append(failures, failure)
+	}
+	return failures, rows.Err()
+}
+
+// ProofBreaker returns the time the proof failure circuit breaker was tripped
+// and the time it was last reset. A zero time is returned for either if it
+// has not happened.
+func (s *Store) ProofBreaker() (trippedAt, resetAt time.Time, err error) {
+	err = s.queryRow(`SELECT proof_breaker_tripped_at, proof_breaker_reset_at FROM global_settings`).
+		Scan(nullable((*sqlTime)(&trippedAt)), nullable((*sqlTime)(&resetAt)))
+	return
+}
+
+// SetProofBreaker sets the time the proof failure circuit breaker was
+// tripped and the time it was last reset. A zero time is stored as NULL.
+func (s *Store) SetProofBreaker(trippedAt, resetAt time.Time) error {
+	nullTime := func(t time.Time) any {
+		if t.IsZero() {
+			return nil
+		}
+		return sqlTime(t)
+	}
+	_, err := s.exec(`UPDATE global_settings SET proof_breaker_tripped_at=$1, proof_breaker_reset_at=$2`, nullTime(trippedAt), nullTime(resetAt))
+	return err
+}
+
+// RejectContract marks a contract as rejected and records the reason it was
+// rejected.
+func (s *Store) RejectContract(id types.FileContractID, reason string) error {
+	return s.transaction(func(tx txn) error {
+		if err := expireContract(tx, id, contracts.ContractStatusRejected); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE contracts SET failure_reason=$1 WHERE contract_id=$2;`, reason, sqlHash256(id))
+		if err != nil {
+			return fmt.Errorf("failed to set failure reason: %w", err)
+		}
+		return nil
+	})
+}
+
+// expireContract sets the final status of a contract and updates the
+// contract metrics.
+func expireContract(tx txn, id types.FileContractID, status contracts.ContractStatus) error {
+	var contractID int64
+	err := tx.QueryRow(`SELECT id FROM contracts WHERE contract_id=$1;`, sqlHash256(id)).Scan(&contractID)
+	if err != nil {
+		return fmt.Errorf("failed to get contract id: %w", err)
+	}
+	// get the contract and check if the status is already set
+	contract, err := getContract(tx, contractID)
+	if err != nil {
+		return fmt.Errorf("failed to get contract: %w", err)
+	} else if contract.Status == status {
+		return nil
+	}
+
+	if contract.Status == contracts.ContractStatusActive || contract.Status == contracts.ContractStatusPending {
+		released, err := collateralReleased(tx, contractID)
+		if err != nil {
+			return fmt.Errorf("failed to check if collateral was released: %w", err)
+		}
+		// successful, failed and rejected contracts should have already had
+		// their collateral removed from the metrics. Cleared contracts may
+		// have released their collateral early.
+		if !released {
 			if err := incrementCurrencyStat(tx, metricLockedCollateral, contract.LockedCollateral, true, time.Now()); err != nil {
 				return fmt.Errorf("failed to increment locked collateral stat: %w", err)
 			} else if err := incrementCurrencyStat(tx, metricRiskedCollateral, contract.Usage.RiskedCollateral, true, time.Now()); err != nil {
 				return fmt.Errorf("failed to increment risked collateral stat: %w", err)
-			} else if err := incrementPotentialRevenueMetrics(tx, contract.Usage, true); err != nil {
-				return fmt.Errorf("failed to decrement potential revenue: %w", err)
 			}
 		}
-
-		// if the contract is successful and the final revision is confirmed,
-		// increment the earned revenue metrics
-		//
-		// note: if the final revision is not confirmed, the earned revenue
-		// may be incorrect.
-		if status == contracts.ContractStatusSuccessful && contract.RevisionConfirmed {
-			if err := incrementEarnedRevenueMetrics(tx, contract.Usage, false); err != nil {
-				return fmt.Errorf("failed to increment earned revenue: %w", err)
-			}
+		if err := incrementPotentialRevenueMetrics(tx, contract.Usage, true); err != nil {
+			return fmt.Errorf("failed to decrement potential revenue: %w", err)
 		}
-		// update the contract status
-		if err := setContractStatus(tx, id, status); err != nil {
-			return fmt.Errorf("failed to set contract status: %w", err)
+	}
+
+	// if the contract is successful and the final revision is confirmed,
+	// increment the earned revenue metrics
+	//
+	// note: if the final revision is not confirmed, the earned revenue
+	// may be incorrect.
+	if status == contracts.ContractStatusSuccessful && contract.RevisionConfirmed {
+		if err := incrementEarnedRevenueMetrics(tx, contract.Usage, false); err != nil {
+			return fmt.Errorf("failed to increment earned revenue: %w", err)
 		}
-		return nil
-	})
+	}
+	// update the contract status
+	if err := setContractStatus(tx, id, status); err != nil {
+		return fmt.Errorf("failed to set contract status: %w", err)
+	}
+	return nil
+}
+
+// RenterHistory returns the contract history of the renter with the given
+// public key. A renter that has not formed any contracts with the host has an
+// empty history.
+func (s *Store) RenterHistory(renterKey types.PublicKey) (history contracts.RenterHistory, err error) {
+	err = s.queryRow(`SELECT formed_contracts, successful_contracts, failed_contracts FROM contract_renters WHERE public_key=$1;`, sqlHash256(renterKey)).Scan(&history.Formed, &history.Successful, &history.Failed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contracts.RenterHistory{}, nil
+	}
+	return
 }
 
 // LastContractChange gets the last consensus change processed by the
@@ -526,6 +1307,22 @@ func (s *Store) UpdateContractState(ccID modules.ConsensusChangeID, height uint6
 	})
 }
 
+// ResetContractConfirmations clears the confirmation state that was recorded
+// at or after height. Resolutions confirmed at or after height are cleared.
+// The formation and revisions of contracts negotiated at or after height
+// cannot have been confirmed before it, so their confirmations are cleared as
+// well.
+func (s *Store) ResetContractConfirmations(height uint64) error {
+	return s.transaction(func(tx txn) error {
+		if _, err := tx.Exec(`UPDATE contracts SET resolution_height=NULL, resolution_block_id=NULL, resolution_txn_id=NULL WHERE resolution_height >= $1`, height); err != nil {
+			return fmt.Errorf("failed to reset resolutions: %w", err)
+		} else if _, err := tx.Exec(`UPDATE contracts SET formation_confirmed=false, confirmed_revision_number=$1 WHERE negotiation_height >= $2`, sqlUint64(0), height); err != nil {
+			return fmt.Errorf("failed to reset formations: %w", err)
+		}
+		return nil
+	})
+}
+
 // ExpireContractSectors expires all sectors that are no longer covered by an
 // active contract.
 func (s *Store) ExpireContractSectors(height uint64) error {
@@ -543,10 +1340,203 @@ func (s *Store) ExpireContractSectors(height uint64) error {
 	}
 }
 
+// PruneContracts removes every successful, failed, or rejected contract whose
+// proof window ended before height, skipping pinned contracts. A tombstone is
+// added for each contract in the same transaction that removes it.
+func (s *Store) PruneContracts(height uint64) (pruned int, err error) {
+	log := s.log.Named("PruneContracts").With(zap.Uint64("height", height))
+	// prune in batches to avoid holding a lock on the database for too long
+	for {
+		var n int
+		err := s.transaction(func(tx txn) (err error) {
+			n, err = pruneContracts(tx, height)
+			return
+		})
+		if err != nil {
+			return pruned, fmt.Errorf("failed to prune contracts: %w", err)
+		}
+		pruned += n
+		if n < contractPruneBatchSize {
+			log.Debug("pruned contracts", zap.Int("pruned", pruned))
+			return pruned, nil
+		}
+		jitterSleep(time.Millisecond) // allow other transactions to run
+	}
+}
+
+// ContractTombstones returns a paginated list of the tombstones of pruned
+// contracts, oldest first.
+func (s *Store) ContractTombstones(limit, offset int) (tombstones []contracts.ContractTombstone, err error) {
+	const query = `SELECT contract_id, revision_number, contract_status, revenue, resolution_height, resolution_txn_id, date_pruned
+FROM contract_tombstones ORDER BY id ASC LIMIT $1 OFFSET $2;`
+
+	rows, err := s.query(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tombstones: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ts contracts.ContractTombstone
+		var resolutionHeight sql.NullInt64
+		err := rows.Scan((*sqlHash256)(&ts.ContractID),
+			(*sqlUint64)(&ts.RevisionNumber),
+			&ts.Status,
+			(*sqlCurrency)(&ts.Revenue),
+			&resolutionHeight,
+			nullable((*sqlHash256)(&ts.ResolutionTxnID)),
+			(*sqlTime)(&ts.PrunedAt))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tombstone: %w", err)
+		}
+		ts.ResolutionHeight = uint64(resolutionHeight.Int64)
+		tombstones = append(tombstones, ts)
+	}
+	return tombstones, rows.Err()
+}
+
+// resolutionTxnID returns the ID of the last storage proof transaction
+// broadcast for a contract.
+func resolutionTxnID(tx txn, contractID int64) (id types.TransactionID, ok bool, err error) {
+	const query = `SELECT transaction_ids FROM contract_action_results
+WHERE contract_id=$1 AND action=$2 AND action_status=$3
+ORDER BY id DESC LIMIT 1;`
+
+	var buf []byte
+	err = tx.QueryRow(query, contractID, contracts.ActionBroadcastResolution, contracts.ActionStatusBroadcast).Scan(&buf)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.TransactionID{}, false, nil
+	} else if err != nil {
+		return types.TransactionID{}, false, err
+	}
+	ids, err := decodeTxnIDs(buf)
+	if err != nil {
+		return types.TransactionID{}, false, fmt.Errorf("failed to decode transaction IDs: %w", err)
+	} else if len(ids) == 0 {
+		return types.TransactionID{}, false, nil
+	}
+	// the proof is the last transaction in the set
+	return ids[len(ids)-1], true, nil
+}
+
+// pruneContracts removes a batch of resolved contracts and their remaining
+// data, adding a tombstone for each.
+func pruneContracts(tx txn, height uint64) (int, error) {
+	const query = `SELECT id, contract_id, revision_number, contract_status, resolution_height, resolution_txn_id, rpc_revenue, storage_revenue, ingress_revenue, egress_revenue, registry_read, registry_write
+FROM contracts WHERE contract_status IN ($1, $2, $3) AND window_end < $4 AND pinned=false LIMIT $5;`
+
+	type prunable struct {
+		dbID int64
+		ts   contracts.ContractTombstone
+	}
+
+	rows, err := tx.Query(query, contracts.ContractStatusSuccessful, contracts.ContractStatusFailed, contracts.ContractStatusRejected, height, contractPruneBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query contracts: %w", err)
+	}
+	var batch []prunable
+	for rows.Next() {
+		var p prunable
+		var usage contracts.Usage
+		var resolutionHeight sql.NullInt64
+		err := rows.Scan(&p.dbID,
+			(*sqlHash256)(&p.ts.ContractID),
+			(*sqlUint64)(&p.ts.RevisionNumber),
+			&p.ts.Status,
+			&resolutionHeight,
+			nullable((*sqlHash256)(&p.ts.ResolutionTxnID)),
+			(*sqlCurrency)(&usage.RPCRevenue),
+			(*sqlCurrency)(&usage.StorageRevenue),
+			(*sqlCurrency)(&usage.IngressRevenue),
+			(*sqlCurrency)(&usage.EgressRevenue),
+			(*sqlCurrency)(&usage.RegistryRead),
+			(*sqlCurrency)(&usage.RegistryWrite))
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan contract: %w", err)
+		}
+		p.ts.ResolutionHeight = uint64(resolutionHeight.Int64)
+		p.ts.Revenue = usage.Revenue()
+		batch = append(batch, p)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, p := range batch {
+		var resolutionHeight, resolutionTxn any
+		if p.ts.ResolutionHeight != 0 {
+			resolutionHeight = p.ts.ResolutionHeight
+		}
+		if p.ts.ResolutionTxnID != (types.TransactionID{}) {
+			// prefer the transaction that confirmed the resolution
+			resolutionTxn = sqlHash256(p.ts.ResolutionTxnID)
+		} else if txnID, ok, err := resolutionTxnID(tx, p.dbID); err != nil {
+			return 0, fmt.Errorf("failed to get resolution transaction for contract %v: %w", p.ts.ContractID, err)
+		} else if ok {
+			resolutionTxn = sqlHash256(txnID)
+		}
+
+		const tombstoneQuery = `INSERT INTO contract_tombstones (contract_id, revision_number, contract_status, revenue, resolution_height, resolution_txn_id, date_pruned) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+		_, err = tx.Exec(tombstoneQuery, sqlHash256(p.ts.ContractID), sqlUint64(p.ts.RevisionNumber), p.ts.Status, sqlCurrency(p.ts.Revenue), resolutionHeight, resolutionTxn, sqlTime(now))
+		if err != nil {
+			return 0, fmt.Errorf("failed to add tombstone for contract %v: %w", p.ts.ContractID, err)
+		} else if err := deleteContract(tx, p.dbID); err != nil {
+			return 0, fmt.Errorf("failed to delete contract %v: %w", p.ts.ContractID, err)
+		}
+	}
+	return len(batch), nil
+}
+
+// deleteContract removes a contract and all data that references it.
+func deleteContract(tx txn, contractID int64) error {
+	rows, err := tx.Query(`DELETE FROM contract_sector_roots WHERE contract_id=$1 RETURNING sector_id;`, contractID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sector roots: %w", err)
+	}
+	var sectorIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan sector id: %w", err)
+		}
+		sectorIDs = append(sectorIDs, id)
+	}
+	rows.Close()
+
+	if len(sectorIDs) > 0 {
+		if err := incrementNumericStat(tx, metricContractSectors, -len(sectorIDs), time.Now()); err != nil {
+			return fmt.Errorf("failed to decrement contract sectors: %w", err)
+		} else if _, err := pruneSectors(tx, sectorIDs); err != nil {
+			return fmt.Errorf("failed to prune sectors: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM contract_action_results WHERE contract_id=$1;`, contractID); err != nil {
+		return fmt.Errorf("failed to delete action results: %w", err)
+	} else if _, err := tx.Exec(`DELETE FROM contract_pending_renewals WHERE renewed_contract_id=$1 OR cleared_contract_id=$1;`, contractID); err != nil {
+		return fmt.Errorf("failed to delete pending renewals: %w", err)
+	} else if _, err := tx.Exec(`DELETE FROM contract_proof_failures WHERE contract_id=$1;`, contractID); err != nil {
+		return fmt.Errorf("failed to delete proof failures: %w", err)
+	} else if _, err := tx.Exec(`DELETE FROM contract_account_funding WHERE contract_id=$1;`, contractID); err != nil {
+		return fmt.Errorf("failed to delete account funding: %w", err)
+	} else if _, err := tx.Exec(`DELETE FROM contract_formation_inputs WHERE contract_id=$1;`, contractID); err != nil {
+		return fmt.Errorf("failed to delete formation inputs: %w", err)
+	} else if _, err := tx.Exec(`DELETE FROM contracts WHERE id=$1;`, contractID); err != nil {
+		return fmt.Errorf("failed to delete contract: %w", err)
+	}
+	return nil
+}
+
 func getContract(tx txn, contractID int64) (contracts.Contract, error) {
 	const query = `SELECT c.contract_id, rt.contract_id AS renewed_to, rf.contract_id AS renewed_from, c.contract_status, c.negotiation_height, c.formation_confirmed, 
-	c.revision_number=c.confirmed_revision_number AS revision_confirmed, c.resolution_height, c.locked_collateral, c.rpc_revenue,
-	c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.account_funding, c.risked_collateral, c.raw_revision, c.host_sig, c.renter_sig 
+	c.revision_number=c.confirmed_revision_number AS revision_confirmed, c.resolution_height, c.resolution_block_id, c.resolution_txn_id, c.locked_collateral, c.rpc_revenue,
+	c.storage_revenue, c.ingress_revenue, c.egress_revenue, c.account_funding, c.risked_collateral, c.raw_revision, c.host_sig, c.renter_sig, c.failure_reason, c.pinned, c.quarantine_reason, c.proof_skip_reason,
+	(SELECT json_group_array(tag) FROM contract_tags WHERE contract_id=c.id) AS tags
 	FROM contracts c
 	LEFT JOIN contracts rt ON (c.renewed_to = rt.id)
 	LEFT JOIN contracts rf ON (c.renewed_from = rf.id)
@@ -763,6 +1753,13 @@ func clearContract(tx txn, revision contracts.SignedRevision, renewedDBID int64,
 	return
 }
 
+// collateralReleased returns true if the contract's collateral was removed
+// from the metrics when it was cleared.
+func collateralReleased(tx txn, dbID int64) (released bool, err error) {
+	err = tx.QueryRow(`SELECT collateral_released FROM contracts WHERE id=$1;`, dbID).Scan(&released)
+	return
+}
+
 // reviseContract revises a contract and returns its ID
 func reviseContract(tx txn, revision contracts.SignedRevision) (dbID int64, err error) {
 	err = tx.QueryRow(`UPDATE contracts SET (revision_number, window_start, window_end, raw_revision, host_sig, renter_sig) = ($1, $2, $3, $4, $5, $6) WHERE contract_id=$7 RETURNING id;`,
@@ -971,6 +1968,10 @@ raw_revision, host_sig, renter_sig, confirmed_revision_number, formation_confirm
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert contract: %w", err)
 	}
+	// add the contract to the renter's history
+	if _, err := tx.Exec(`UPDATE contract_renters SET formed_contracts=formed_contracts+1 WHERE id=$1;`, renterID); err != nil {
+		return 0, fmt.Errorf("failed to update renter history: %w", err)
+	}
 	// increment the contract count metric
 	if err := incrementNumericStat(tx, metricPendingContracts, 1, time.Now()); err != nil {
 		return 0, fmt.Errorf("failed to track pending contracts: %w", err)
@@ -985,9 +1986,31 @@ raw_revision, host_sig, renter_sig, confirmed_revision_number, formation_confirm
 	if err := incrementPotentialRevenueMetrics(tx, initialUsage, false); err != nil {
 		return 0, fmt.Errorf("failed to increment potential revenue: %w", err)
 	}
+	if err := insertFormationInputs(tx, dbID, formationSet); err != nil {
+		return 0, fmt.Errorf("failed to index formation inputs: %w", err)
+	}
 	return
 }
 
+// insertFormationInputs indexes the siacoin outputs spent by a contract's
+// formation set.
+func insertFormationInputs(tx txn, contractID int64, formationSet []types.Transaction) error {
+	stmt, err := tx.Prepare(`INSERT INTO contract_formation_inputs (output_id, contract_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, txn := range formationSet {
+		for _, sci := range txn.SiacoinInputs {
+			if _, err := stmt.Exec(sqlHash256(sci.ParentID), contractID); err != nil {
+				return fmt.Errorf("failed to insert input %v: %w", sci.ParentID, err)
+			}
+		}
+	}
+	return nil
+}
+
 func encodeRevision(fcr types.FileContractRevision) []byte {
 	var buf bytes.Buffer
 	e := types.NewEncoder(&buf)
@@ -1022,6 +2045,32 @@ func decodeTxnSet(b []byte, txns *[]types.Transaction) error {
 	return d.Err()
 }
 
+func encodeTxnIDs(ids []types.TransactionID) []byte {
+	if len(ids) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	e := types.NewEncoder(&buf)
+	e.WritePrefix(len(ids))
+	for i := range ids {
+		ids[i].EncodeTo(e)
+	}
+	e.Flush()
+	return buf.Bytes()
+}
+
+func decodeTxnIDs(b []byte) ([]types.TransactionID, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	d := types.NewBufDecoder(b)
+	ids := make([]types.TransactionID, d.ReadPrefix())
+	for i := range ids {
+		ids[i].DecodeFrom(d)
+	}
+	return ids, d.Err()
+}
+
 func buildContractFilter(filter contracts.ContractFilter) (string, []any, error) {
 	var whereClause []string
 	var queryParams []any
@@ -1059,9 +2108,14 @@ func buildContractFilter(filter contracts.ContractFilter) (string, []any, error)
 		}
 	}
 
+	if len(filter.Tags) != 0 {
+		whereClause = append(whereClause, `c.id IN (SELECT contract_id FROM contract_tags WHERE tag IN (`+queryPlaceHolders(len(filter.Tags))+`))`)
+		queryParams = append(queryParams, queryArgs(filter.Tags)...)
+	}
+
 	if filter.MinNegotiationHeight > 0 && filter.MaxNegotiationHeight > 0 {
-		if filter.MinNegotiationHeight < filter.MaxNegotiationHeight {
-			return "", nil, errors.New("min negotiation height must be less than max negotiation height")
+		if filter.MinNegotiationHeight > filter.MaxNegotiationHeight {
+			return "", nil, errors.New("min negotiation height must not be greater than max negotiation height")
 		}
 		whereClause = append(whereClause, `c.negotiation_height BETWEEN ? AND ?`)
 		queryParams = append(queryParams, filter.MinNegotiationHeight, filter.MaxNegotiationHeight)
@@ -1074,8 +2128,8 @@ func buildContractFilter(filter contracts.ContractFilter) (string, []any, error)
 	}
 
 	if filter.MinExpirationHeight > 0 && filter.MaxExpirationHeight > 0 {
-		if filter.MinExpirationHeight < filter.MaxExpirationHeight {
-			return "", nil, errors.New("min expiration height must be less than max expiration height")
+		if filter.MinExpirationHeight > filter.MaxExpirationHeight {
+			return "", nil, errors.New("min expiration height must not be greater than max expiration height")
 		}
 		whereClause = append(whereClause, `c.window_start BETWEEN ? AND ?`)
 		queryParams = append(queryParams, filter.MinExpirationHeight, filter.MaxExpirationHeight)
@@ -1092,25 +2146,34 @@ func buildContractFilter(filter contracts.ContractFilter) (string, []any, error)
 	return "WHERE " + strings.Join(whereClause, " AND "), queryParams, nil
 }
 
-func buildOrderBy(filter contracts.ContractFilter) string {
-	dir := "ASC"
-	if filter.SortDesc {
-		dir = "DESC"
-	}
+// sortColumn returns the contracts column the filter sorts by.
+func sortColumn(filter contracts.ContractFilter) string {
 	switch filter.SortField {
 	case contracts.ContractSortStatus:
-		return `ORDER BY c.contract_status ` + dir
+		return "contract_status"
 	case contracts.ContractSortNegotiationHeight:
-		return `ORDER BY c.negotiation_height ` + dir
+		return "negotiation_height"
 	default:
-		return `ORDER BY c.window_start ` + dir
+		return "window_start"
 	}
 }
 
+func buildOrderBy(filter contracts.ContractFilter) string {
+	dir := "ASC"
+	if filter.SortDesc {
+		dir = "DESC"
+	}
+	// the row ID breaks ties so pages do not overlap when many contracts
+	// share the same sort value
+	return `ORDER BY c.` + sortColumn(filter) + ` ` + dir + `, c.id ` + dir
+}
+
 func scanContract(row scanner) (c contracts.Contract, err error) {
 	var revisionBuf []byte
 	var contractID types.FileContractID
 	var resolutionHeight sql.NullInt64
+	var failureReason, quarantineReason, proofSkipReason sql.NullString
+	var tags string
 	err = row.Scan((*sqlHash256)(&contractID),
 		nullable((*sqlHash256)(&c.RenewedTo)),
 		nullable((*sqlHash256)(&c.RenewedFrom)),
@@ -1119,6 +2182,8 @@ func scanContract(row scanner) (c contracts.Contract, err error) {
 		&c.FormationConfirmed,
 		&c.RevisionConfirmed,
 		&resolutionHeight,
+		nullable((*sqlHash256)(&c.ResolutionID)),
+		nullable((*sqlHash256)(&c.ResolutionTxnID)),
 		(*sqlCurrency)(&c.LockedCollateral),
 		(*sqlCurrency)(&c.Usage.RPCRevenue),
 		(*sqlCurrency)(&c.Usage.StorageRevenue),
@@ -1129,9 +2194,16 @@ func scanContract(row scanner) (c contracts.Contract, err error) {
 		&revisionBuf,
 		(*sqlHash512)(&c.HostSignature),
 		(*sqlHash512)(&c.RenterSignature),
+		&failureReason,
+		&c.Pinned,
+		&quarantineReason,
+		&proofSkipReason,
+		&tags,
 	)
 	if err != nil {
 		return contracts.Contract{}, fmt.Errorf("failed to scan contract: %w", err)
+	} else if err := json.Unmarshal([]byte(tags), &c.Tags); err != nil {
+		return contracts.Contract{}, fmt.Errorf("failed to decode tags: %w", err)
 	} else if err := decodeRevision(revisionBuf, &c.Revision); err != nil {
 		return contracts.Contract{}, fmt.Errorf("failed to decode revision: %w", err)
 	} else if c.Revision.ParentID != contractID {
@@ -1139,6 +2211,14 @@ func scanContract(row scanner) (c contracts.Contract, err error) {
 	} else if resolutionHeight.Valid {
 		c.ResolutionHeight = uint64(resolutionHeight.Int64)
 	}
+	c.FailureReason = failureReason.String
+	c.Quarantined = quarantineReason.Valid
+	c.QuarantineReason = quarantineReason.String
+	c.ProofSkipReason = proofSkipReason.String
+	if len(c.Tags) == 0 {
+		c.Tags = nil
+	}
+	sort.Strings(c.Tags)
 	return
 }
 
@@ -1191,11 +2271,47 @@ func setContractStatus(tx txn, id types.FileContractID, status contracts.Contrac
 		return fmt.Errorf("failed to query contract status: %w", err)
 	}
 
-	var dbID int64
-	if err := tx.QueryRow(`UPDATE contracts SET contract_status=$1 WHERE contract_id=$2 RETURNING id;`, status, sqlHash256(id)).Scan(&dbID); err != nil {
+	var dbID, renterID int64
+	if err := tx.QueryRow(`UPDATE contracts SET contract_status=$1 WHERE contract_id=$2 RETURNING id, renter_id;`, status, sqlHash256(id)).Scan(&dbID, &renterID); err != nil {
 		return fmt.Errorf("failed to update contract status: %w", err)
 	} else if err := updateContractMetrics(tx, current, status); err != nil {
 		return fmt.Errorf("failed to update contract metrics: %w", err)
+	} else if err := updateRenterHistory(tx, renterID, current, status); err != nil {
+		return fmt.Errorf("failed to update renter history: %w", err)
+	}
+	return nil
+}
+
+// renterHistoryColumn returns the contract_renters column that counts
+// contracts with the given status. An empty string is returned if the status
+// is not counted.
+func renterHistoryColumn(status contracts.ContractStatus) string {
+	switch status {
+	case contracts.ContractStatusSuccessful:
+		return "successful_contracts"
+	case contracts.ContractStatusFailed, contracts.ContractStatusRejected:
+		return "failed_contracts"
+	default:
+		return ""
+	}
+}
+
+// updateRenterHistory moves a contract between the renter's history counters
+// when its status changes.
+func updateRenterHistory(tx txn, renterID int64, current, next contracts.ContractStatus) error {
+	from, to := renterHistoryColumn(current), renterHistoryColumn(next)
+	if from == to {
+		return nil
+	}
+	if from != "" {
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE contract_renters SET %[1]s=%[1]s-1 WHERE id=$1;`, from), renterID); err != nil {
+			return fmt.Errorf("failed to decrement %s: %w", from, err)
+		}
+	}
+	if to != "" {
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE contract_renters SET %[1]s=%[1]s+1 WHERE id=$1;`, to), renterID); err != nil {
+			return fmt.Errorf("failed to increment %s: %w", to, err)
+		}
 	}
 	return nil
 }
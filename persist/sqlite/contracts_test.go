@@ -3,13 +3,16 @@ package sqlite
 import (
 	"errors"
 	"fmt"
+	"math"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/storage"
+	"go.sia.tech/siad/modules"
 	"go.uber.org/zap/zaptest"
 	"lukechampine.com/frand"
 )
@@ -399,3 +402,1338 @@ func TestContracts(t *testing.T) {
 		t.Fatal("expected no contracts")
 	}
 }
+
+func TestContractsPagination(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	renterKeys := []types.PrivateKey{
+		types.NewPrivateKeyFromSeed(frand.Bytes(32)),
+		types.NewPrivateKeyFromSeed(frand.Bytes(32)),
+	}
+
+	type seeded struct {
+		id          types.FileContractID
+		renter      types.PublicKey
+		windowStart uint64
+		active      bool
+		tags        []string
+	}
+
+	const n = 1000
+	var all []seeded
+	for i := 0; i < n; i++ {
+		renterKey := renterKeys[i%len(renterKeys)]
+		uc := types.UnlockConditions{
+			PublicKeys: []types.UnlockKey{
+				renterKey.PublicKey().UnlockKey(),
+				hostKey.PublicKey().UnlockKey(),
+			},
+			SignaturesRequired: 2,
+		}
+		// use a small range of window starts so many contracts share the
+		// same sort value
+		sc := seeded{
+			id:          frand.Entropy256(),
+			renter:      renterKey.PublicKey(),
+			windowStart: 100 + uint64(i%20),
+			active:      i%3 == 0,
+		}
+		if i%5 == 0 {
+			sc.tags = append(sc.tags, "archive")
+		}
+		if i%7 == 0 {
+			sc.tags = append(sc.tags, "priority")
+		}
+
+		contract := contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         sc.id,
+				UnlockConditions: uc,
+				FileContract: types.FileContract{
+					UnlockHash:     types.Hash256(uc.UnlockHash()),
+					RevisionNumber: 1,
+					WindowStart:    sc.windowStart,
+					WindowEnd:      sc.windowStart + 10,
+				},
+			},
+		}
+		if err := db.AddContract(contract, nil, types.ZeroCurrency, contracts.Usage{}, 0); err != nil {
+			t.Fatal(err)
+		} else if sc.active {
+			if _, err := db.exec(`UPDATE contracts SET contract_status=$1 WHERE contract_id=$2`, contracts.ContractStatusActive, sqlHash256(sc.id)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(sc.tags) != 0 {
+			if err := db.SetContractTags(sc.id, sc.tags); err != nil {
+				t.Fatal(err)
+			}
+		}
+		all = append(all, sc)
+	}
+
+	// the limit is capped
+	page, count, err := db.Contracts(contracts.ContractFilter{Limit: n})
+	if err != nil {
+		t.Fatal(err)
+	} else if count != n {
+		t.Fatalf("expected %v contracts, got %v", n, count)
+	} else if len(page) != contracts.MaxContractsLimit {
+		t.Fatalf("expected %v contracts, got %v", contracts.MaxContractsLimit, len(page))
+	}
+
+	filter := contracts.ContractFilter{
+		Statuses:            []contracts.ContractStatus{contracts.ContractStatusActive},
+		RenterKey:           []types.PublicKey{renterKeys[0].PublicKey()},
+		Tags:                []string{"archive", "priority"},
+		MinExpirationHeight: 104,
+		MaxExpirationHeight: 115,
+	}
+	expected := make(map[types.FileContractID]seeded)
+	for _, sc := range all {
+		if sc.active && sc.renter == renterKeys[0].PublicKey() && len(sc.tags) != 0 && sc.windowStart >= 104 && sc.windowStart <= 115 {
+			expected[sc.id] = sc
+		}
+	}
+	if len(expected) == 0 {
+		t.Fatal("no contracts match the filter")
+	}
+
+	// page through the filtered contracts
+	const pageSize = 7
+	seen := make(map[types.FileContractID]bool)
+	var lastWindowStart uint64
+	for offset := 0; ; offset += pageSize {
+		filter.Limit, filter.Offset = pageSize, offset
+		page, count, err := db.Contracts(filter)
+		if err != nil {
+			t.Fatal(err)
+		} else if count != len(expected) {
+			t.Fatalf("expected count %v, got %v", len(expected), count)
+		} else if len(page) == 0 {
+			break
+		} else if len(page) > pageSize {
+			t.Fatalf("expected at most %v contracts, got %v", pageSize, len(page))
+		}
+
+		for _, c := range page {
+			sc, ok := expected[c.Revision.ParentID]
+			if !ok {
+				t.Fatalf("unexpected contract %v", c.Revision.ParentID)
+			} else if seen[c.Revision.ParentID] {
+				t.Fatalf("contract %v returned twice", c.Revision.ParentID)
+			} else if c.Revision.WindowStart < lastWindowStart {
+				t.Fatalf("contracts not sorted by window start: %v < %v", c.Revision.WindowStart, lastWindowStart)
+			} else if fmt.Sprint(c.Tags) != fmt.Sprint(sc.tags) {
+				t.Fatalf("expected tags %v, got %v", sc.tags, c.Tags)
+			}
+			seen[c.Revision.ParentID] = true
+			lastWindowStart = c.Revision.WindowStart
+		}
+	}
+	if len(seen) != len(expected) {
+		t.Fatalf("expected %v contracts, got %v", len(expected), len(seen))
+	}
+
+	// page through the filtered contracts in descending order using the last
+	// contract of each page as the cursor
+	seen = make(map[types.FileContractID]bool)
+	lastWindowStart = math.MaxUint64
+	filter.SortDesc, filter.Offset = true, 0
+	for {
+		filter.Limit = pageSize
+		page, count, err := db.Contracts(filter)
+		if err != nil {
+			t.Fatal(err)
+		} else if count != len(expected) {
+			t.Fatalf("expected count %v, got %v", len(expected), count)
+		} else if len(page) == 0 {
+			break
+		}
+
+		for _, c := range page {
+			if _, ok := expected[c.Revision.ParentID]; !ok {
+				t.Fatalf("unexpected contract %v", c.Revision.ParentID)
+			} else if seen[c.Revision.ParentID] {
+				t.Fatalf("contract %v returned twice", c.Revision.ParentID)
+			} else if c.Revision.WindowStart > lastWindowStart {
+				t.Fatalf("contracts not sorted by descending window start: %v > %v", c.Revision.WindowStart, lastWindowStart)
+			}
+			seen[c.Revision.ParentID] = true
+			lastWindowStart = c.Revision.WindowStart
+		}
+		filter.After = page[len(page)-1].Revision.ParentID
+	}
+	if len(seen) != len(expected) {
+		t.Fatalf("expected %v contracts, got %v", len(expected), len(seen))
+	}
+	filter.SortDesc, filter.After = false, types.FileContractID{}
+
+	// an unknown cursor is rejected
+	if _, _, err := db.Contracts(contracts.ContractFilter{After: frand.Entropy256()}); !errors.Is(err, contracts.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// removing a contract's tags excludes it from the tag filter
+	var untagged types.FileContractID
+	for id := range expected {
+		untagged = id
+		break
+	}
+	if err := db.SetContractTags(untagged, nil); err != nil {
+		t.Fatal(err)
+	}
+	filter.Limit, filter.Offset = contracts.MaxContractsLimit, 0
+	if _, count, err := db.Contracts(filter); err != nil {
+		t.Fatal(err)
+	} else if count != len(expected)-1 {
+		t.Fatalf("expected count %v, got %v", len(expected)-1, count)
+	}
+
+	if err := db.SetContractTags(frand.Entropy256(), []string{"archive"}); !errors.Is(err, contracts.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	} else if _, _, err := db.Contracts(contracts.ContractFilter{MinExpirationHeight: 110, MaxExpirationHeight: 100}); err == nil {
+		t.Fatal("expected error for invalid expiration range")
+	}
+}
+
+func TestPruneContracts(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	volumeID, err := db.AddVolume("test.dat", false)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := db.SetAvailable(volumeID, true); err != nil {
+		t.Fatal(err)
+	} else if err = db.GrowVolume(volumeID, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+
+	addContract := func(windowEnd uint64, usage contracts.Usage) contracts.SignedRevision {
+		t.Helper()
+		contract := contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:     types.Hash256(unlockConditions.UnlockHash()),
+					RevisionNumber: frand.Uint64n(100),
+					WindowStart:    windowEnd - 10,
+					WindowEnd:      windowEnd,
+				},
+			},
+		}
+		if err := db.AddContract(contract, []types.Transaction{}, types.ZeroCurrency, usage, 0); err != nil {
+			t.Fatal(err)
+		}
+		return contract
+	}
+
+	usage := contracts.Usage{
+		RPCRevenue:     types.Siacoins(1),
+		StorageRevenue: types.Siacoins(2),
+		EgressRevenue:  types.Siacoins(3),
+	}
+	successful := addContract(100, usage)
+	failed := addContract(100, contracts.Usage{})
+	active := addContract(500, usage)
+
+	// add a sector to the successful contract
+	root := frand.Entropy256()
+	release, err := db.StoreSector(root, func(storage.SectorLocation, bool) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	} else if err := db.ReviseContract(successful, nil, contracts.Usage{}, []contracts.SectorChange{{Action: contracts.SectorActionAppend, Root: root}}); err != nil {
+		t.Fatal(err)
+	} else if err := release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// record the proof and resolve the contracts
+	proofTxnID := types.TransactionID(frand.Entropy256())
+	err = db.AddContractActionResult(contracts.ActionResult{
+		ContractID:     successful.Revision.ParentID,
+		Height:         95,
+		Action:         contracts.ActionBroadcastResolution,
+		Status:         contracts.ActionStatusBroadcast,
+		TransactionIDs: []types.TransactionID{frand.Entropy256(), proofTxnID},
+		Timestamp:      time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofIndex := types.ChainIndex{Height: 96, ID: frand.Entropy256()}
+	err = db.UpdateContractState(modules.ConsensusChangeID{}, 96, func(tx contracts.UpdateStateTransaction) error {
+		return tx.ConfirmResolution(successful.Revision.ParentID, proofIndex, proofTxnID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if c, err := db.Contract(successful.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	} else if c.ResolutionHeight != proofIndex.Height || c.ResolutionID != proofIndex.ID || c.ResolutionTxnID != proofTxnID {
+		t.Fatalf("unexpected resolution %v %v %v", c.ResolutionHeight, c.ResolutionID, c.ResolutionTxnID)
+	} else if err := db.ExpireContract(successful.Revision.ParentID, contracts.ContractStatusSuccessful); err != nil {
+		t.Fatal(err)
+	} else if err := db.ExpireContract(failed.Revision.ParentID, contracts.ContractStatusFailed); err != nil {
+		t.Fatal(err)
+	}
+
+	// contracts in their proof window should not be pruned
+	if n, err := db.PruneContracts(100); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected 0 pruned contracts, got %v", n)
+	}
+
+	if n, err := db.PruneContracts(101); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("expected 2 pruned contracts, got %v", n)
+	}
+
+	for _, id := range []types.FileContractID{successful.Revision.ParentID, failed.Revision.ParentID} {
+		if _, err := db.Contract(id); !errors.Is(err, contracts.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if _, err := db.Contract(active.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	} else if _, _, err := db.SectorLocation(root); !errors.Is(err, storage.ErrSectorNotFound) {
+		t.Fatalf("expected sector to be pruned, got %v", err)
+	}
+
+	tombstones, err := db.ContractTombstones(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(tombstones) != 2 {
+		t.Fatalf("expected 2 tombstones, got %v", len(tombstones))
+	}
+
+	byID := make(map[types.FileContractID]contracts.ContractTombstone)
+	for _, ts := range tombstones {
+		byID[ts.ContractID] = ts
+	}
+
+	ts := byID[successful.Revision.ParentID]
+	switch {
+	case ts.Status != contracts.ContractStatusSuccessful:
+		t.Fatalf("expected status successful, got %v", ts.Status)
+	case ts.RevisionNumber != successful.Revision.RevisionNumber:
+		t.Fatalf("expected revision %v, got %v", successful.Revision.RevisionNumber, ts.RevisionNumber)
+	case !ts.Revenue.Equals(usage.Revenue()):
+		t.Fatalf("expected revenue %v, got %v", usage.Revenue(), ts.Revenue)
+	case ts.ResolutionHeight != 96:
+		t.Fatalf("expected resolution height 96, got %v", ts.ResolutionHeight)
+	case ts.ResolutionTxnID != proofTxnID:
+		t.Fatalf("expected resolution txn %v, got %v", proofTxnID, ts.ResolutionTxnID)
+	case ts.PrunedAt.IsZero():
+		t.Fatal("expected pruned time to be set")
+	}
+
+	ts = byID[failed.Revision.ParentID]
+	switch {
+	case ts.Status != contracts.ContractStatusFailed:
+		t.Fatalf("expected status failed, got %v", ts.Status)
+	case !ts.Revenue.IsZero():
+		t.Fatalf("expected no revenue, got %v", ts.Revenue)
+	case ts.ResolutionHeight != 0:
+		t.Fatalf("expected no resolution height, got %v", ts.ResolutionHeight)
+	case ts.ResolutionTxnID != (types.TransactionID{}):
+		t.Fatalf("expected no resolution txn, got %v", ts.ResolutionTxnID)
+	}
+}
+
+func TestReleaseCollateral(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	newRevision := func() contracts.SignedRevision {
+		return contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:     types.Hash256(unlockConditions.UnlockHash()),
+					RevisionNumber: 1,
+					WindowStart:    100,
+					WindowEnd:      200,
+				},
+			},
+		}
+	}
+
+	checkCollateral := func(locked, risked types.Currency) {
+		t.Helper()
+		m, err := db.Metrics(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		} else if !m.Contracts.LockedCollateral.Equals(locked) {
+			t.Fatalf("expected %v locked collateral, got %v", locked, m.Contracts.LockedCollateral)
+		} else if !m.Contracts.RiskedCollateral.Equals(risked) {
+			t.Fatalf("expected %v risked collateral, got %v", risked, m.Contracts.RiskedCollateral)
+		}
+	}
+
+	existing := newRevision()
+	if err := db.AddContract(existing, nil, types.Siacoins(1000), contracts.Usage{RiskedCollateral: types.Siacoins(100)}, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.Siacoins(1000), types.Siacoins(100))
+
+	// uncleared contracts cannot release their collateral
+	if err := db.ReleaseCollateral(existing.Revision.ParentID); err == nil {
+		t.Fatal("expected error releasing collateral of an uncleared contract")
+	}
+	checkCollateral(types.Siacoins(1000), types.Siacoins(100))
+
+	// renew the contract
+	clearing := existing
+	clearing.Revision.RevisionNumber = types.MaxRevisionNumber
+	renewal := newRevision()
+	if err := db.RenewContract(renewal, clearing, nil, types.Siacoins(500), contracts.Usage{}, contracts.Usage{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.Siacoins(1500), types.Siacoins(100))
+
+	// release the cleared contract's collateral
+	if err := db.ReleaseCollateral(existing.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.Siacoins(500), types.ZeroCurrency)
+
+	// releasing again should not change the metrics
+	if err := db.ReleaseCollateral(existing.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.Siacoins(500), types.ZeroCurrency)
+
+	// expiring the cleared contract should not remove its collateral again
+	if err := db.ExpireContract(existing.Revision.ParentID, contracts.ContractStatusSuccessful); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.Siacoins(500), types.ZeroCurrency)
+
+	if err := db.ExpireContract(renewal.Revision.ParentID, contracts.ContractStatusSuccessful); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.ZeroCurrency, types.ZeroCurrency)
+}
+
+func TestPrunePinnedContract(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	volumeID, err := db.AddVolume("test.dat", false)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := db.SetAvailable(volumeID, true); err != nil {
+		t.Fatal(err)
+	} else if err = db.GrowVolume(volumeID, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+
+	// add two resolved contracts, each with a sector
+	addContract := func() (contracts.SignedRevision, types.Hash256) {
+		t.Helper()
+		contract := contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:     types.Hash256(unlockConditions.UnlockHash()),
+					RevisionNumber: 1,
+					WindowStart:    90,
+					WindowEnd:      100,
+				},
+			},
+		}
+		if err := db.AddContract(contract, []types.Transaction{}, types.ZeroCurrency, contracts.Usage{}, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		root := frand.Entropy256()
+		release, err := db.StoreSector(root, func(storage.SectorLocation, bool) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		} else if err := db.ReviseContract(contract, nil, contracts.Usage{}, []contracts.SectorChange{{Action: contracts.SectorActionAppend, Root: root}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		} else if err := db.ExpireContract(contract.Revision.ParentID, contracts.ContractStatusSuccessful); err != nil {
+			t.Fatal(err)
+		}
+		return contract, root
+	}
+	pinned, pinnedRoot := addContract()
+	unpinned, unpinnedRoot := addContract()
+
+	if err := db.SetContractPinned(pinned.Revision.ParentID, true); err != nil {
+		t.Fatal(err)
+	} else if err := db.SetContractPinned(frand.Entropy256(), true); !errors.Is(err, contracts.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// the pinned status should be included in listings
+	c, err := db.Contract(pinned.Revision.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	} else if !c.Pinned {
+		t.Fatal("expected contract to be pinned")
+	}
+	listed, _, err := db.Contracts(contracts.ContractFilter{ContractIDs: []types.FileContractID{pinned.Revision.ParentID, unpinned.Revision.ParentID}})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(listed) != 2 {
+		t.Fatalf("expected 2 contracts, got %v", len(listed))
+	}
+	for _, c := range listed {
+		if c.Pinned != (c.Revision.ParentID == pinned.Revision.ParentID) {
+			t.Fatalf("contract %v: unexpected pinned status %v", c.Revision.ParentID, c.Pinned)
+		}
+	}
+
+	// expiring sectors should skip the pinned contract
+	if err := db.ExpireContractSectors(101); err != nil {
+		t.Fatal(err)
+	} else if _, _, err := db.SectorLocation(unpinnedRoot); !errors.Is(err, storage.ErrSectorNotFound) {
+		t.Fatalf("expected unpinned sector to be removed, got %v", err)
+	} else if _, release, err := db.SectorLocation(pinnedRoot); err != nil {
+		t.Fatalf("expected pinned sector to be kept, got %v", err)
+	} else if err := release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// pruning should skip the pinned contract
+	if n, err := db.PruneContracts(101); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("expected 1 pruned contract, got %v", n)
+	} else if _, err := db.Contract(unpinned.Revision.ParentID); !errors.Is(err, contracts.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	} else if roots, err := db.SectorRoots(pinned.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	} else if len(roots) != 1 || roots[0] != pinnedRoot {
+		t.Fatalf("expected pinned contract to keep its sector roots, got %v", roots)
+	}
+
+	// unpinned contracts can be pruned
+	if err := db.SetContractPinned(pinned.Revision.ParentID, false); err != nil {
+		t.Fatal(err)
+	} else if n, err := db.PruneContracts(101); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("expected 1 pruned contract, got %v", n)
+	} else if _, err := db.Contract(pinned.Revision.ParentID); !errors.Is(err, contracts.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPruneRenewedContracts(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	newRevision := func() contracts.SignedRevision {
+		return contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:  types.Hash256(unlockConditions.UnlockHash()),
+					WindowStart: 90,
+					WindowEnd:   100,
+				},
+			},
+		}
+	}
+
+	existing := newRevision()
+	if err := db.AddContract(existing, nil, types.ZeroCurrency, contracts.Usage{}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// renew the contract, but never confirm the renewal
+	clearing := existing
+	clearing.Revision.RevisionNumber = types.MaxRevisionNumber
+	renewal := newRevision()
+	if err := db.RenewContract(renewal, clearing, nil, types.ZeroCurrency, contracts.Usage{}, contracts.Usage{}, 0); err != nil {
+		t.Fatal(err)
+	} else if pending, err := db.PendingRenewals(); err != nil {
+		t.Fatal(err)
+	} else if len(pending) != 1 {
+		t.Fatalf("expected 1 pending renewal, got %v", len(pending))
+	}
+
+	for _, id := range []types.FileContractID{existing.Revision.ParentID, renewal.Revision.ParentID} {
+		if err := db.ExpireContract(id, contracts.ContractStatusFailed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// both contracts should be pruned along with the pending renewal
+	if n, err := db.PruneContracts(101); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("expected 2 pruned contracts, got %v", n)
+	} else if pending, err := db.PendingRenewals(); err != nil {
+		t.Fatal(err)
+	} else if len(pending) != 0 {
+		t.Fatalf("expected no pending renewals, got %v", len(pending))
+	}
+	for _, id := range []types.FileContractID{existing.Revision.ParentID, renewal.Revision.ParentID} {
+		if _, err := db.Contract(id); !errors.Is(err, contracts.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+}
+
+func TestSectorContracts(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := addTestVolume(db, "test", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	newRevision := func(windowEnd uint64) contracts.SignedRevision {
+		return contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:  types.Hash256(unlockConditions.UnlockHash()),
+					WindowStart: windowEnd - 10,
+					WindowEnd:   windowEnd,
+				},
+			},
+		}
+	}
+
+	first, second := newRevision(100), newRevision(200)
+	for _, c := range []contracts.SignedRevision{first, second} {
+		if err := db.AddContract(c, nil, types.ZeroCurrency, contracts.Usage{}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendSector := func(c contracts.SignedRevision, root types.Hash256) {
+		t.Helper()
+		release, err := db.StoreSector(root, func(storage.SectorLocation, bool) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+		roots, err := db.SectorRoots(c.Revision.ParentID)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := db.ReviseContract(c, roots, contracts.Usage{}, []contracts.SectorChange{{Action: contracts.SectorActionAppend, Root: root}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkContracts := func(root types.Hash256, expected ...types.FileContractID) {
+		t.Helper()
+		ids, err := db.SectorContracts(root)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(ids) != len(expected) {
+			t.Fatalf("expected %v contracts, got %v", expected, ids)
+		}
+		for i := range ids {
+			if ids[i] != expected[i] {
+				t.Fatalf("expected %v contracts, got %v", expected, ids)
+			}
+		}
+	}
+
+	// share a sector between both contracts
+	shared, unique := frand.Entropy256(), frand.Entropy256()
+	appendSector(first, shared)
+	appendSector(second, shared)
+	appendSector(first, unique)
+
+	checkContracts(shared, first.Revision.ParentID, second.Revision.ParentID)
+	checkContracts(unique, first.Revision.ParentID)
+	checkContracts(frand.Entropy256())
+
+	// roots spanning multiple batches should return each contract once
+	roots := []types.Hash256{unique}
+	for i := 0; i < 2*sqlSectorBatchSize; i++ {
+		roots = append(roots, frand.Entropy256())
+	}
+	roots = append(roots, shared)
+	if ids, err := db.SectorContracts(roots...); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []types.FileContractID{first.Revision.ParentID, second.Revision.ParentID}) {
+		t.Fatalf("expected contracts %v, got %v", []types.FileContractID{first.Revision.ParentID, second.Revision.ParentID}, ids)
+	}
+
+	// renewing the first contract should transfer its references
+	clearing := first
+	clearing.Revision.RevisionNumber = types.MaxRevisionNumber
+	renewal := newRevision(300)
+	if err := db.RenewContract(renewal, clearing, nil, types.ZeroCurrency, contracts.Usage{}, contracts.Usage{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkContracts(shared, second.Revision.ParentID, renewal.Revision.ParentID)
+	checkContracts(unique, renewal.Revision.ParentID)
+
+	// pruning the second contract should remove its references
+	if err := db.ExpireContract(second.Revision.ParentID, contracts.ContractStatusFailed); err != nil {
+		t.Fatal(err)
+	} else if _, err := db.PruneContracts(201); err != nil {
+		t.Fatal(err)
+	}
+	checkContracts(shared, renewal.Revision.ParentID)
+}
+
+func TestReconcileSectors(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	volume, err := addTestVolume(db, "test", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	contract := contracts.SignedRevision{
+		Revision: types.FileContractRevision{
+			ParentID:         frand.Entropy256(),
+			UnlockConditions: unlockConditions,
+			FileContract: types.FileContract{
+				UnlockHash:  types.Hash256(unlockConditions.UnlockHash()),
+				WindowStart: 90,
+				WindowEnd:   100,
+			},
+		},
+	}
+	if err := db.AddContract(contract, nil, types.ZeroCurrency, contracts.Usage{}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	storeSector := func(root types.Hash256) {
+		t.Helper()
+		release, err := db.StoreSector(root, func(storage.SectorLocation, bool) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { release() })
+	}
+
+	// add a few sectors to the contract
+	roots := make([]types.Hash256, 4)
+	var changes []contracts.SectorChange
+	for i := range roots {
+		roots[i] = frand.Entropy256()
+		storeSector(roots[i])
+		changes = append(changes, contracts.SectorChange{Action: contracts.SectorActionAppend, Root: roots[i]})
+	}
+	if err := db.ReviseContract(contract, nil, contracts.Usage{}, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	// add a temporary sector
+	tempRoot := frand.Entropy256()
+	storeSector(tempRoot)
+	if err := db.AddTemporarySectors([]storage.TempSector{{Root: tempRoot, Expiration: 100}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// sectors are locked until they are released
+	if leaked, err := db.LeakedSectors(); err != nil {
+		t.Fatal(err)
+	} else if len(leaked) != 0 {
+		t.Fatalf("expected no leaked sectors, got %v", leaked)
+	}
+
+	// simulate a botched migration that dropped the contract's reference
+	// to a sector
+	leakedRoot := roots[3]
+	if _, err := db.db.Exec(`DELETE FROM contract_sector_roots WHERE sector_id=(SELECT id FROM stored_sectors WHERE sector_root=$1)`, sqlHash256(leakedRoot)); err != nil {
+		t.Fatal(err)
+	}
+	// lose the data of another sector
+	missingRoot := roots[1]
+	if err := db.RemoveSector(missingRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sector locks would hide the leak
+	if _, err := db.db.Exec(`DELETE FROM locked_sectors`); err != nil {
+		t.Fatal(err)
+	}
+
+	missing, err := db.MissingContractSectors()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(missing) != 1 {
+		t.Fatalf("expected 1 contract with missing sectors, got %v", len(missing))
+	} else if err := rootsEqual(missing[contract.Revision.ParentID], []types.Hash256{missingRoot}); err != nil {
+		t.Fatal(err)
+	}
+
+	leaked, err := db.LeakedSectors()
+	if err != nil {
+		t.Fatal(err)
+	} else if err := rootsEqual(leaked, []types.Hash256{leakedRoot}); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := db.Volume(volume.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := db.PruneLeakedSectors()
+	if err != nil {
+		t.Fatal(err)
+	} else if err := rootsEqual(pruned, []types.Hash256{leakedRoot}); err != nil {
+		t.Fatal(err)
+	}
+
+	if leaked, err := db.LeakedSectors(); err != nil {
+		t.Fatal(err)
+	} else if len(leaked) != 0 {
+		t.Fatalf("expected no leaked sectors, got %v", leaked)
+	} else if ok, err := db.HasSector(leakedRoot); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected leaked sector to be removed")
+	} else if ok, err := db.HasSector(tempRoot); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected temp sector to be kept")
+	}
+
+	after, err := db.Volume(volume.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if after.UsedSectors != before.UsedSectors-1 {
+		t.Fatalf("expected %v used sectors, got %v", before.UsedSectors-1, after.UsedSectors)
+	}
+
+	// missing data is only reported for active contracts
+	if err := db.ExpireContract(contract.Revision.ParentID, contracts.ContractStatusFailed); err != nil {
+		t.Fatal(err)
+	} else if missing, err := db.MissingContractSectors(); err != nil {
+		t.Fatal(err)
+	} else if len(missing) != 0 {
+		t.Fatalf("expected no missing sectors, got %v", missing)
+	}
+}
+
+func TestFeeExpenditures(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := time.Now().Truncate(time.Second)
+	fees := []contracts.FeeExpenditure{
+		{Category: contracts.FeeCategoryRevision, Amount: types.Siacoins(1), Timestamp: start},
+		{Category: contracts.FeeCategoryRevision, Amount: types.Siacoins(2), Timestamp: start.Add(time.Minute)},
+		{Category: contracts.FeeCategoryProof, Amount: types.Siacoins(3), Timestamp: start.Add(time.Minute)},
+		{Category: contracts.FeeCategoryProof, Amount: types.Siacoins(4), Timestamp: start.Add(2 * time.Minute)},
+		{Category: contracts.FeeCategoryFormation, Amount: types.Siacoins(5), Timestamp: start.Add(2 * time.Minute)},
+		// outside of the queried range
+		{Category: contracts.FeeCategoryProof, Amount: types.Siacoins(100), Timestamp: start.Add(time.Hour)},
+	}
+	for _, fee := range fees {
+		fee.ContractID = frand.Entropy256()
+		fee.TransactionID = frand.Entropy256()
+		if err := db.AddFeeExpenditure(fee); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	totals, err := db.FeeExpenditures(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []contracts.FeeTotal{
+		{Category: contracts.FeeCategoryFormation, Count: 1, Total: types.Siacoins(5)},
+		{Category: contracts.FeeCategoryProof, Count: 2, Total: types.Siacoins(7)},
+		{Category: contracts.FeeCategoryRevision, Count: 2, Total: types.Siacoins(3)},
+	}
+	if len(totals) != len(expected) {
+		t.Fatalf("expected %v categories, got %v", len(expected), len(totals))
+	}
+	for i := range expected {
+		if totals[i].Category != expected[i].Category || totals[i].Count != expected[i].Count || !totals[i].Total.Equals(expected[i].Total) {
+			t.Fatalf("expected %+v, got %+v", expected[i], totals[i])
+		}
+	}
+
+	// only the first revision fee is before the second minute
+	totals, err = db.FeeExpenditures(start, start.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(totals) != 1 || totals[0].Category != contracts.FeeCategoryRevision || totals[0].Count != 1 || !totals[0].Total.Equals(types.Siacoins(1)) {
+		t.Fatalf("unexpected totals %+v", totals)
+	}
+
+	// rebroadcasting a proof should replace its fee instead of adding to it
+	proofID := types.FileContractID(frand.Entropy256())
+	for i := 1; i <= 3; i++ {
+		err := db.AddFeeExpenditure(contracts.FeeExpenditure{
+			ContractID:    proofID,
+			Category:      contracts.FeeCategoryProof,
+			TransactionID: frand.Entropy256(),
+			Amount:        types.Siacoins(uint32(10 * i)),
+			Timestamp:     start.Add(2 * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	totals, err = db.FeeExpenditures(start.Add(2*time.Hour), start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(totals) != 1 || totals[0].Category != contracts.FeeCategoryProof || totals[0].Count != 1 || !totals[0].Total.Equals(types.Siacoins(30)) {
+		t.Fatalf("unexpected totals %+v", totals)
+	}
+}
+
+func TestRevertReleasedCollateral(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	newRevision := func() contracts.SignedRevision {
+		return contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:     types.Hash256(unlockConditions.UnlockHash()),
+					RevisionNumber: 1,
+					WindowStart:    100,
+					WindowEnd:      200,
+				},
+			},
+		}
+	}
+
+	checkCollateral := func(locked, risked types.Currency) {
+		t.Helper()
+		m, err := db.Metrics(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		} else if !m.Contracts.LockedCollateral.Equals(locked) {
+			t.Fatalf("expected %v locked collateral, got %v", locked, m.Contracts.LockedCollateral)
+		} else if !m.Contracts.RiskedCollateral.Equals(risked) {
+			t.Fatalf("expected %v risked collateral, got %v", risked, m.Contracts.RiskedCollateral)
+		}
+	}
+
+	existing := newRevision()
+	if err := db.AddContract(existing, nil, types.Siacoins(1000), contracts.Usage{RiskedCollateral: types.Siacoins(100)}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// renew the contract and release the cleared contract's collateral
+	clearing := existing
+	clearing.Revision.RevisionNumber = types.MaxRevisionNumber
+	renewal := newRevision()
+	if err := db.RenewContract(renewal, clearing, nil, types.Siacoins(500), contracts.Usage{}, contracts.Usage{}, 0); err != nil {
+		t.Fatal(err)
+	} else if err := db.ReleaseCollateral(existing.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.Siacoins(500), types.ZeroCurrency)
+
+	// reverting the renewal should put the existing contract's collateral
+	// back at risk
+	if err := db.RevertRenewal(renewal.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.Siacoins(1000), types.Siacoins(100))
+
+	// the restored contract is no longer cleared
+	if err := db.ReleaseCollateral(existing.Revision.ParentID); err == nil {
+		t.Fatal("expected error releasing collateral of an uncleared contract")
+	}
+
+	// expiring the restored contract should remove its collateral
+	if err := db.ExpireContract(existing.Revision.ParentID, contracts.ContractStatusSuccessful); err != nil {
+		t.Fatal(err)
+	}
+	checkCollateral(types.ZeroCurrency, types.ZeroCurrency)
+}
+
+func TestRevertRevisedRenewal(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := addTestVolume(db, "test", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	newRevision := func() contracts.SignedRevision {
+		return contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:     types.Hash256(unlockConditions.UnlockHash()),
+					RevisionNumber: 1,
+					WindowStart:    100,
+					WindowEnd:      200,
+				},
+			},
+		}
+	}
+
+	existing := newRevision()
+	if err := db.AddContract(existing, nil, types.ZeroCurrency, contracts.Usage{}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	clearing := existing
+	clearing.Revision.RevisionNumber = types.MaxRevisionNumber
+	renewal := newRevision()
+	if err := db.RenewContract(renewal, clearing, nil, types.ZeroCurrency, contracts.Usage{}, contracts.Usage{}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// append a sector to the renewed contract before it is confirmed
+	root := frand.Entropy256()
+	release, err := db.StoreSector(root, func(storage.SectorLocation, bool) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	revised := renewal
+	revised.Revision.RevisionNumber++
+	revised.Revision.Filesize = 1 << 22
+	revised.Revision.FileMerkleRoot = root
+	if err := db.ReviseContract(revised, nil, contracts.Usage{}, []contracts.SectorChange{{Action: contracts.SectorActionAppend, Root: root}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the revised renewal cannot be reverted without losing the sector
+	if err := db.RevertRenewal(renewal.Revision.ParentID); !errors.Is(err, contracts.ErrRenewalRevised) {
+		t.Fatalf("expected ErrRenewalRevised, got %v", err)
+	} else if roots, err := db.SectorRoots(renewal.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	} else if len(roots) != 1 || roots[0] != root {
+		t.Fatalf("expected renewal to keep its sector roots, got %v", roots)
+	} else if c, err := db.Contract(existing.Revision.ParentID); err != nil {
+		t.Fatal(err)
+	} else if c.Revision.RevisionNumber != types.MaxRevisionNumber {
+		t.Fatalf("expected existing contract to remain cleared, got revision %v", c.Revision.RevisionNumber)
+	}
+}
+
+func TestProofFailures(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	addContract := func() types.FileContractID {
+		t.Helper()
+		contract := contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:     types.Hash256(unlockConditions.UnlockHash()),
+					RevisionNumber: 1,
+					WindowStart:    100,
+					WindowEnd:      200,
+				},
+			},
+		}
+		if err := db.AddContract(contract, nil, types.ZeroCurrency, contracts.Usage{}, 0); err != nil {
+			t.Fatal(err)
+		}
+		return contract.Revision.ParentID
+	}
+
+	start := time.Now().Truncate(time.Second)
+
+	// a failure outside of the queried window
+	old := contracts.ProofFailure{
+		ContractID: addContract(),
+		Height:     200,
+		Cause:      contracts.ProofFailureNotSubmitted,
+		Reason:     "not submitted: old",
+		Timestamp:  start.Add(-time.Hour),
+	}
+	if err := db.RecordProofFailure(old); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cause contracts.ProofFailureCause
+		// recorded is true if the cause is recorded on a failed resolution
+		// action
+		recorded bool
+	}{
+		{contracts.ProofFailureDataUnavailable, true},
+		{contracts.ProofFailureInsufficientFunds, true},
+		{contracts.ProofFailureBroadcast, true},
+		{contracts.ProofFailureInternal, true},
+		{contracts.ProofFailureNotConfirmed, false},
+		{contracts.ProofFailureNotSubmitted, false},
+	}
+	for i, test := range tests {
+		t.Run(string(test.cause), func(t *testing.T) {
+			id := addContract()
+			reason := string(test.cause) + ": test"
+			if test.recorded {
+				result := contracts.ActionResult{
+					ContractID: id,
+					Height:     150,
+					Action:     contracts.ActionBroadcastResolution,
+					Status:     contracts.ActionStatusFailed,
+					Error:      reason,
+					Cause:      test.cause,
+					Timestamp:  start,
+				}
+				if err := db.AddContractActionResult(result); err != nil {
+					t.Fatal(err)
+				} else if history, err := db.ContractActionResults(id); err != nil {
+					t.Fatal(err)
+				} else if len(history) != 1 || history[0].Cause != test.cause {
+					t.Fatalf("expected cause %q, got %+v", test.cause, history)
+				}
+			}
+
+			failure := contracts.ProofFailure{
+				ContractID: id,
+				Height:     200,
+				Cause:      test.cause,
+				Reason:     reason,
+				Timestamp:  start.Add(time.Duration(i) * time.Second),
+			}
+			if err := db.RecordProofFailure(failure); err != nil {
+				t.Fatal(err)
+			}
+
+			contract, err := db.Contract(id)
+			if err != nil {
+				t.Fatal(err)
+			} else if contract.Status != contracts.ContractStatusFailed {
+				t.Fatalf("expected contract to be failed, got %v", contract.Status)
+			} else if contract.FailureReason != reason {
+				t.Fatalf("expected failure reason %q, got %q", reason, contract.FailureReason)
+			}
+
+			failures, err := db.ProofFailures(start)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(failures) != i+1 {
+				t.Fatalf("expected %v failures, got %v", i+1, len(failures))
+			} else if last := failures[len(failures)-1]; last != failure {
+				t.Fatalf("expected failure %+v, got %+v", failure, last)
+			}
+		})
+	}
+
+	failures, err := db.ProofFailures(start.Add(-2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(failures) != len(tests)+1 {
+		t.Fatalf("expected %v failures, got %v", len(tests)+1, len(failures))
+	} else if failures[0] != old {
+		t.Fatalf("expected oldest failure %+v, got %+v", old, failures[0])
+	}
+}
+
+func TestPendingFormationSpends(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	unlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	addContract := func(inputs ...types.SiacoinOutputID) types.FileContractID {
+		t.Helper()
+		contract := contracts.SignedRevision{
+			Revision: types.FileContractRevision{
+				ParentID:         frand.Entropy256(),
+				UnlockConditions: unlockConditions,
+				FileContract: types.FileContract{
+					UnlockHash:     types.Hash256(unlockConditions.UnlockHash()),
+					RevisionNumber: 1,
+					WindowStart:    100,
+					WindowEnd:      200,
+				},
+			},
+		}
+		var txn types.Transaction
+		for _, id := range inputs {
+			txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{ParentID: id})
+		}
+		if err := db.AddContract(contract, []types.Transaction{txn}, types.ZeroCurrency, contracts.Usage{}, 0); err != nil {
+			t.Fatal(err)
+		}
+		return contract.Revision.ParentID
+	}
+
+	a, b, c := types.SiacoinOutputID(frand.Entropy256()), types.SiacoinOutputID(frand.Entropy256()), types.SiacoinOutputID(frand.Entropy256())
+	first := addContract(a, b)
+	second := addContract(c)
+	// a renter double spending its own formations
+	third := addContract(a)
+
+	spends, err := db.PendingFormationSpends([]types.SiacoinOutputID{a, c, frand.Entropy256()})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(spends) != 3 {
+		t.Fatalf("expected 3 contracts, got %v", len(spends))
+	} else if len(spends[first]) != 1 || spends[first][0] != a {
+		t.Fatalf("expected first contract to spend %v, got %v", a, spends[first])
+	} else if len(spends[second]) != 1 || spends[second][0] != c {
+		t.Fatalf("expected second contract to spend %v, got %v", c, spends[second])
+	} else if len(spends[third]) != 1 || spends[third][0] != a {
+		t.Fatalf("expected third contract to spend %v, got %v", a, spends[third])
+	}
+
+	// contracts that are no longer pending are ignored
+	if err := db.RejectContract(first, "test"); err != nil {
+		t.Fatal(err)
+	}
+	spends, err = db.PendingFormationSpends([]types.SiacoinOutputID{a, b})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(spends) != 1 || len(spends[third]) != 1 {
+		t.Fatalf("expected only the third contract, got %v", spends)
+	}
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+)
+
+// ErrDiskFull is returned when a transaction fails because the disk holding
+// the database is full.
+var ErrDiskFull = errors.New("database disk is full")
+
+// isDiskFullErr returns true if err was caused by the disk holding the
+// database running out of space.
+func isDiskFullErr(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrFull {
+		return true
+	}
+	return strings.Contains(err.Error(), "database or disk is full")
+}
+
+// setDiskFull updates the disk full state of the store and notifies the
+// subscribers if it changed. A probe is started to detect when space has been
+// freed.
+func (s *Store) setDiskFull(full bool) {
+	s.diskMu.Lock()
+	if s.diskFull == full {
+		s.diskMu.Unlock()
+		return
+	}
+	s.diskFull = full
+	fns := append([]func(full bool){}, s.diskFullFns...)
+	s.diskMu.Unlock()
+
+	if full {
+		go s.probeDiskSpace()
+	} else {
+		s.log.Info("database disk space recovered")
+	}
+	for _, fn := range fns {
+		fn(full)
+	}
+}
+
+// probeDiskSpace periodically attempts a small write until it succeeds or the
+// store is closed. A successful write clears the disk full state.
+func (s *Store) probeDiskSpace() {
+	t := time.NewTicker(diskFullProbeInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-t.C:
+		}
+
+		if !s.DiskFull() {
+			return
+		}
+		err := s.transaction(func(tx txn) error {
+			_, err := tx.Exec(`UPDATE global_settings SET db_version=db_version`)
+			return err
+		})
+		if err != nil && !errors.Is(err, ErrDiskFull) {
+			s.log.Debug("disk space probe failed", zap.Error(err))
+		}
+	}
+}
+
+// DiskFull returns true if the last write to the database failed because its
+// disk is full.
+func (s *Store) DiskFull() bool {
+	s.diskMu.Lock()
+	defer s.diskMu.Unlock()
+	return s.diskFull
+}
+
+// OnDiskFull registers a function that is called when the disk holding the
+// database fills up or space is freed. The function should not block.
+func (s *Store) OnDiskFull(fn func(full bool)) {
+	s.diskMu.Lock()
+	defer s.diskMu.Unlock()
+	s.diskFullFns = append(s.diskFullFns, fn)
+}
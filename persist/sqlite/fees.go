@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/contracts"
+)
+
+// AddFeeExpenditure records a miner fee paid by the host. Only one of a
+// contract's broadcasts for a category can be confirmed, so a rebroadcast
+// replaces the previously recorded fee.
+func (s *Store) AddFeeExpenditure(fee contracts.FeeExpenditure) error {
+	const query = `INSERT INTO contract_fee_expenditures (contract_id, category, transaction_id, amount, date_created) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (contract_id, category) DO UPDATE SET transaction_id=EXCLUDED.transaction_id, amount=EXCLUDED.amount, date_created=EXCLUDED.date_created;`
+	if _, err := s.exec(query, sqlHash256(fee.ContractID), fee.Category, sqlHash256(fee.TransactionID), sqlCurrency(fee.Amount), sqlTime(fee.Timestamp)); err != nil {
+		return fmt.Errorf("failed to insert fee expenditure: %w", err)
+	}
+	return nil
+}
+
+// FeeExpenditures returns the miner fees paid in [start, end), totaled by
+// category.
+func (s *Store) FeeExpenditures(start, end time.Time) ([]contracts.FeeTotal, error) {
+	const query = `SELECT category, amount FROM contract_fee_expenditures WHERE date_created >= $1 AND date_created < $2;`
+	rows, err := s.query(query, sqlTime(start), sqlTime(end))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fee expenditures: %w", err)
+	}
+	defer rows.Close()
+
+	// currencies are stored as blobs and must be summed in Go
+	totals := make(map[string]*contracts.FeeTotal)
+	for rows.Next() {
+		var category string
+		var amount types.Currency
+		if err := rows.Scan(&category, (*sqlCurrency)(&amount)); err != nil {
+			return nil, fmt.Errorf("failed to scan fee expenditure: %w", err)
+		}
+		total, ok := totals[category]
+		if !ok {
+			total = &contracts.FeeTotal{Category: category}
+			totals[category] = total
+		}
+		total.Count++
+		total.Total = total.Total.Add(amount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]contracts.FeeTotal, 0, len(totals))
+	for _, total := range totals {
+		results = append(results, *total)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Category < results[j].Category
+	})
+	return results, nil
+}
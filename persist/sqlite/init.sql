@@ -28,7 +28,8 @@ CREATE INDEX wallet_transactions_block_height_id ON wallet_transactions(block_he
 CREATE TABLE stored_sectors (
 	id INTEGER PRIMARY KEY,
 	sector_root BLOB UNIQUE NOT NULL,
-	last_access_timestamp INTEGER NOT NULL
+	last_access_timestamp INTEGER NOT NULL,
+	checksum INTEGER
 );
 CREATE INDEX stored_sectors_sector_root ON stored_sectors(sector_root);
 CREATE INDEX stored_sectors_last_access ON stored_sectors(last_access_timestamp);
@@ -39,23 +40,46 @@ CREATE TABLE locked_sectors ( -- should be cleared at startup. currently persist
 );
 CREATE INDEX locked_sectors_sector_id ON locked_sectors(sector_id);
 
+CREATE TABLE sector_access_counts ( -- hourly sector read counts used to find frequently accessed sectors
+	sector_id INTEGER NOT NULL REFERENCES stored_sectors (id) ON DELETE CASCADE,
+	period_start INTEGER NOT NULL,
+	reads INTEGER NOT NULL,
+	PRIMARY KEY (sector_id, period_start)
+);
+CREATE INDEX sector_access_counts_period_start ON sector_access_counts(period_start);
+
 CREATE TABLE storage_volumes (
 	id INTEGER PRIMARY KEY,
 	disk_path TEXT UNIQUE NOT NULL,
 	used_sectors INTEGER NOT NULL,
 	total_sectors INTEGER NOT NULL,
 	read_only BOOLEAN NOT NULL,
-	available BOOLEAN NOT NULL DEFAULT false
+	available BOOLEAN NOT NULL DEFAULT false,
+	encryption_salt BLOB, -- NULL if the volume is not encrypted
+	encryption_key_check BLOB,
+	replica_group TEXT NOT NULL DEFAULT '', -- sectors are replicated to another volume in the same group. empty disables replication
+	bytes_read INTEGER NOT NULL DEFAULT 0,
+	bytes_written INTEGER NOT NULL DEFAULT 0
 );
 CREATE INDEX storage_volumes_id_available_read_only ON storage_volumes(id, available, read_only);
 CREATE INDEX storage_volumes_read_only_available_used_sectors ON storage_volumes(available, read_only, used_sectors);
 
+CREATE TABLE volume_io_counts ( -- per-minute volume I/O used to report recent throughput
+	volume_id INTEGER NOT NULL REFERENCES storage_volumes (id) ON DELETE CASCADE,
+	period_start INTEGER NOT NULL,
+	bytes_read INTEGER NOT NULL,
+	bytes_written INTEGER NOT NULL,
+	PRIMARY KEY (volume_id, period_start)
+);
+CREATE INDEX volume_io_counts_period_start ON volume_io_counts(period_start);
+
 CREATE TABLE volume_sectors (
 	id INTEGER PRIMARY KEY,
 	volume_id INTEGER NOT NULL REFERENCES storage_volumes (id), -- all sectors will need to be migrated first when deleting a volume
 	volume_index INTEGER NOT NULL,
 	sector_id INTEGER UNIQUE REFERENCES stored_sectors (id),
 	sector_writes INTEGER NOT NULL DEFAULT 0,
+	zero_pending BOOLEAN NOT NULL DEFAULT false, -- the sector was deleted and must be zeroed before the location is reused
 	UNIQUE (volume_id, volume_index)
 );
 CREATE INDEX volume_sectors_sector_writes_volume_id_sector_id_volume_index_compound ON volume_sectors(sector_writes ASC, volume_id, sector_id, volume_index) WHERE sector_id IS NULL;
@@ -63,6 +87,13 @@ CREATE INDEX volume_sectors_volume_id_sector_id ON volume_sectors(volume_id, sec
 CREATE INDEX volume_sectors_volume_id ON volume_sectors(volume_id);
 CREATE INDEX volume_sectors_volume_index ON volume_sectors(volume_index ASC);
 CREATE INDEX volume_sectors_sector_id ON volume_sectors(sector_id);
+CREATE INDEX volume_sectors_zero_pending ON volume_sectors(zero_pending) WHERE zero_pending=true;
+
+CREATE TABLE sector_replicas ( -- secondary copies of sectors stored in another volume of the same replica group
+	id INTEGER PRIMARY KEY,
+	sector_id INTEGER UNIQUE NOT NULL REFERENCES stored_sectors(id),
+	volume_sector_id INTEGER UNIQUE NOT NULL REFERENCES volume_sectors(id)
+);
 
 CREATE TABLE locked_volume_sectors ( -- should be cleared at startup. currently persisted for simplicity, but may be moved to memory
 	id INTEGER PRIMARY KEY,
@@ -70,9 +101,19 @@ CREATE TABLE locked_volume_sectors ( -- should be cleared at startup. currently
 );
 CREATE INDEX locked_volume_sectors_sector_id ON locked_volume_sectors(volume_sector_id);
 
+CREATE TABLE volume_migrations ( -- tracks the progress of in-progress sector migrations so they can be resumed
+	volume_id INTEGER PRIMARY KEY REFERENCES storage_volumes (id) ON DELETE CASCADE,
+	min_index INTEGER NOT NULL,
+	last_index INTEGER NOT NULL, -- every occupied sector at or below this index has been migrated
+	updated_at INTEGER NOT NULL
+);
+
 CREATE TABLE contract_renters (
 	id INTEGER PRIMARY KEY,
-	public_key BLOB UNIQUE NOT NULL
+	public_key BLOB UNIQUE NOT NULL,
+	formed_contracts INTEGER NOT NULL DEFAULT 0,
+	successful_contracts INTEGER NOT NULL DEFAULT 0,
+	failed_contracts INTEGER NOT NULL DEFAULT 0
 );
 
 CREATE TABLE contracts (
@@ -98,10 +139,17 @@ CREATE TABLE contracts (
 	raw_revision BLOB NOT NULL, -- binary serialized contract revision
 	formation_confirmed BOOLEAN NOT NULL, -- true if the contract has been confirmed on the blockchain
 	resolution_height INTEGER, -- null if the storage proof/resolution has not been confirmed on the blockchain, otherwise the height of the block containing the storage proof/resolution
+	resolution_block_id BLOB, -- null unless the resolution has been confirmed, the ID of the block containing the resolution
+	resolution_txn_id BLOB, -- null unless the resolution has been confirmed, the ID of the transaction containing the resolution
 	negotiation_height INTEGER NOT NULL, -- determines if the formation txn should be rebroadcast or if the contract should be deleted
 	window_start INTEGER NOT NULL,
 	window_end INTEGER NOT NULL,
-	contract_status INTEGER NOT NULL
+	contract_status INTEGER NOT NULL,
+	failure_reason TEXT, -- null unless the contract failed
+	collateral_released BOOLEAN NOT NULL DEFAULT false, -- true if the collateral was removed from the metrics when the contract was cleared
+	pinned BOOLEAN NOT NULL DEFAULT false, -- pinned contracts are not pruned
+	quarantine_reason TEXT, -- null unless the contract is quarantined
+	proof_skip_reason TEXT -- null unless the host decided not to submit the storage proof
 );
 CREATE INDEX contracts_contract_id ON contracts(contract_id);
 CREATE INDEX contracts_renter_id ON contracts(renter_id);
@@ -111,11 +159,20 @@ CREATE INDEX contracts_negotiation_height ON contracts(negotiation_height);
 CREATE INDEX contracts_window_start ON contracts(window_start);
 CREATE INDEX contracts_window_end ON contracts(window_end);
 CREATE INDEX contracts_contract_status ON contracts(contract_status);
+CREATE INDEX contracts_contract_status_window_start ON contracts(contract_status, window_start);
+CREATE INDEX contracts_renter_id_window_start ON contracts(renter_id, window_start);
 CREATE INDEX contracts_formation_confirmed_resolution_height_window_start ON contracts(formation_confirmed, resolution_height, window_start);
 CREATE INDEX contracts_formation_confirmed_resolution_height_window_end ON contracts(formation_confirmed, resolution_height, window_end);
 CREATE INDEX contracts_formation_confirmed_window_start ON contracts(formation_confirmed, window_start);
 CREATE INDEX contracts_formation_confirmed_negotiation_height ON contracts(formation_confirmed, negotiation_height);
 
+CREATE TABLE contract_tags (
+	contract_id INTEGER NOT NULL REFERENCES contracts(id) ON DELETE CASCADE,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (contract_id, tag)
+);
+CREATE INDEX contract_tags_tag_contract_id ON contract_tags(tag, contract_id);
+
 CREATE TABLE contract_sector_roots (
 	id INTEGER PRIMARY KEY,
 	contract_id INTEGER NOT NULl REFERENCES contracts(id),
@@ -126,6 +183,34 @@ CREATE TABLE contract_sector_roots (
 CREATE INDEX contract_sector_roots_sector_id ON contract_sector_roots(sector_id);
 CREATE INDEX contract_sector_roots_contract_id_root_index ON contract_sector_roots(contract_id, root_index);
 
+CREATE TABLE contract_pending_renewals ( -- renewals that were recorded, but may not have been broadcast
+	id INTEGER PRIMARY KEY,
+	renewed_contract_id INTEGER UNIQUE NOT NULL REFERENCES contracts(id),
+	cleared_contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	-- the state of the cleared contract before the renewal
+	previous_revision_number BLOB NOT NULL,
+	previous_raw_revision BLOB NOT NULL,
+	previous_host_sig BLOB NOT NULL,
+	previous_renter_sig BLOB NOT NULL,
+	previous_rpc_revenue BLOB NOT NULL,
+	previous_storage_revenue BLOB NOT NULL,
+	previous_ingress_revenue BLOB NOT NULL,
+	previous_egress_revenue BLOB NOT NULL,
+	previous_account_funding BLOB NOT NULL,
+	previous_risked_collateral BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);
+
+CREATE TABLE packed_sectors (
+	id INTEGER PRIMARY KEY,
+	sector_root BLOB UNIQUE NOT NULL, -- root of the zero-padded sector
+	physical_sector_id INTEGER NOT NULL REFERENCES stored_sectors(id), -- the shared sector holding the payload
+	payload_offset INTEGER NOT NULL,
+	payload_length INTEGER NOT NULL,
+	refs INTEGER NOT NULL
+);
+CREATE INDEX packed_sectors_physical_sector_id ON packed_sectors(physical_sector_id);
+
 CREATE TABLE temp_storage_sector_roots (
 	id INTEGER PRIMARY KEY,
 	sector_id INTEGER NOT NULL REFERENCES stored_sectors(id),
@@ -152,6 +237,60 @@ CREATE TABLE accounts (
 );
 CREATE INDEX accounts_expiration_timestamp ON accounts(expiration_timestamp);
 
+CREATE TABLE contract_action_results (
+	id INTEGER PRIMARY KEY,
+	contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	block_height INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	action_status TEXT NOT NULL,
+	transaction_ids BLOB,
+	fee BLOB NOT NULL,
+	error_message TEXT,
+	proof_failure_cause TEXT, -- null unless a resolution action failed
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX contract_action_results_contract_id ON contract_action_results(contract_id);
+
+CREATE TABLE contract_formation_inputs ( -- siacoin outputs spent by each contract's formation set
+	output_id BLOB NOT NULL,
+	contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	PRIMARY KEY (output_id, contract_id)
+);
+CREATE INDEX contract_formation_inputs_contract_id ON contract_formation_inputs(contract_id);
+
+CREATE TABLE contract_proof_failures (
+	id INTEGER PRIMARY KEY,
+	contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	block_height INTEGER NOT NULL,
+	cause TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX contract_proof_failures_contract_id ON contract_proof_failures(contract_id);
+CREATE INDEX contract_proof_failures_date_created ON contract_proof_failures(date_created);
+
+CREATE TABLE contract_fee_expenditures (
+	id INTEGER PRIMARY KEY,
+	contract_id BLOB NOT NULL,
+	category TEXT NOT NULL,
+	transaction_id BLOB NOT NULL,
+	amount BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX contract_fee_expenditures_date_created ON contract_fee_expenditures(date_created);
+CREATE UNIQUE INDEX contract_fee_expenditures_contract_id_category ON contract_fee_expenditures(contract_id, category);
+
+CREATE TABLE contract_tombstones (
+	id INTEGER PRIMARY KEY,
+	contract_id BLOB UNIQUE NOT NULL,
+	revision_number BLOB NOT NULL,
+	contract_status INTEGER NOT NULL,
+	revenue BLOB NOT NULL,
+	resolution_height INTEGER,
+	resolution_txn_id BLOB,
+	date_pruned INTEGER NOT NULL
+);
+
 CREATE TABLE contract_account_funding (
 	id INTEGER PRIMARY KEY,
 	contract_id INTEGER NOT NULL REFERENCES contracts(id),
@@ -168,6 +307,15 @@ CREATE TABLE host_stats (
 );
 CREATE INDEX host_stats_stat_date_created ON host_stats(stat, date_created DESC);
 
+CREATE TABLE contract_latency (
+	operation TEXT NOT NULL,
+	phase TEXT NOT NULL, -- empty for the operation's total duration
+	observations INTEGER NOT NULL,
+	total_duration INTEGER NOT NULL, -- nanoseconds
+	buckets TEXT NOT NULL, -- JSON array of the observations in each histogram bucket
+	PRIMARY KEY (operation, phase)
+);
+
 CREATE TABLE host_settings (
 	id INTEGER PRIMARY KEY NOT NULL DEFAULT 0 CHECK (id = 0), -- enforce a single row
 	settings_revision INTEGER NOT NULL,
@@ -193,7 +341,19 @@ CREATE TABLE host_settings (
 	ddns_update_v6 BOOLEAN NOT NULL,
 	ddns_opts BLOB,
 	registry_limit INTEGER NOT NULL,
-	sector_cache_size INTEGER NOT NULL DEFAULT 0
+	sector_cache_size INTEGER NOT NULL DEFAULT 0,
+	min_host_payout BLOB NOT NULL DEFAULT X'00000000000000000000000000000000',
+	min_ingress_collateral_ratio REAL NOT NULL DEFAULT 0,
+	max_sectors_per_rpc INTEGER NOT NULL DEFAULT 256,
+	zero_deleted_sectors BOOLEAN NOT NULL DEFAULT false,
+	sector_allocation TEXT NOT NULL DEFAULT 'firstFit',
+	formation_safety_margin INTEGER NOT NULL DEFAULT 0,
+	min_account_deposit BLOB NOT NULL DEFAULT X'00000000000000000000000000000000',
+	max_read_sectors_per_program INTEGER NOT NULL DEFAULT 0,
+	max_append_sectors_per_program INTEGER NOT NULL DEFAULT 0,
+	max_program_instructions INTEGER NOT NULL DEFAULT 0,
+	min_collateral_scale REAL NOT NULL DEFAULT 0,
+	max_collateral_scale REAL NOT NULL DEFAULT 0
 );
 
 CREATE TABLE host_pinned_settings (
@@ -210,6 +370,13 @@ CREATE TABLE host_pinned_settings (
 	max_collateral REAL NOT NULL
 );
 
+CREATE TABLE host_uptime (
+	id INTEGER PRIMARY KEY,
+	start_timestamp INTEGER NOT NULL,
+	last_heartbeat INTEGER NOT NULL
+);
+CREATE INDEX host_uptime_last_heartbeat ON host_uptime(last_heartbeat);
+
 CREATE TABLE webhooks (
 	id INTEGER PRIMARY KEY,
 	callback_url TEXT UNIQUE NOT NULL,
@@ -231,7 +398,13 @@ CREATE TABLE global_settings (
 	wallet_height INTEGER, -- height of the wallet as of the last processed change
 	contracts_height INTEGER, -- height of the contract manager as of the last processed change
 	settings_height INTEGER, -- height of the settings manager as of the last processed change
-	last_announce_address TEXT -- address of the last host announcement
+	last_announce_address TEXT, -- address of the last host announcement
+	last_announce_fee BLOB, -- miner fee paid by the last host announcement
+	wallet_pruned_count INTEGER NOT NULL DEFAULT 0, -- number of wallet transactions removed by pruning
+	wallet_pruned_inflow BLOB, -- total inflow of pruned wallet transactions
+	wallet_pruned_outflow BLOB, -- total outflow of pruned wallet transactions
+	proof_breaker_tripped_at INTEGER, -- time the proof failure circuit breaker was tripped, NULL if it is not tripped
+	proof_breaker_reset_at INTEGER -- time the proof failure circuit breaker was last reset
 );
 
 -- initialize the global settings table
@@ -2,9 +2,11 @@ package sqlite
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"go.sia.tech/core/types"
@@ -26,21 +28,26 @@ const (
 	metricAccountBalance = "accountBalance"
 
 	// storage
-	metricTotalSectors    = "totalSectors"
-	metricPhysicalSectors = "physicalSectors"
-	metricLostSectors     = "lostSectors"
-	metricContractSectors = "contractSectors"
-	metricTempSectors     = "tempSectors"
-	metricSectorReads     = "sectorReads"
-	metricSectorWrites    = "sectorWrites"
-	metricSectorCacheHit  = "sectorCacheHit"
-	metricSectorCacheMiss = "sectorCacheMiss"
+	metricTotalSectors       = "totalSectors"
+	metricPhysicalSectors    = "physicalSectors"
+	metricLostSectors        = "lostSectors"
+	metricContractSectors    = "contractSectors"
+	metricTempSectors        = "tempSectors"
+	metricSectorReads        = "sectorReads"
+	metricSectorWrites       = "sectorWrites"
+	metricSectorCacheHit     = "sectorCacheHit"
+	metricSectorCacheMiss    = "sectorCacheMiss"
+	metricSectorPrefetch     = "sectorPrefetch"
+	metricSectorPrefetchHit  = "sectorPrefetchHit"
+	metricSectorReadAhead    = "sectorReadAhead"
+	metricSectorReadAheadHit = "sectorReadAheadHit"
 
 	// registry
 	metricMaxRegistryEntries = "maxRegistryEntries"
 	metricRegistryEntries    = "registryEntries"
 	metricRegistryReads      = "registryReads"
 	metricRegistryWrites     = "registryWrites"
+	metricRegistryRejections = "registryRejections"
 
 	// bandwidth
 	metricDataRHPIngress = "dataIngress"
@@ -239,6 +246,67 @@ JOIN (
 	return
 }
 
+// TransactionMetrics returns metrics for the store's database transactions
+// since the store was opened.
+func (s *Store) TransactionMetrics() metrics.Transactions {
+	m := metrics.Transactions{
+		Count:         atomic.LoadUint64(&s.txnMetrics.count),
+		Retries:       atomic.LoadUint64(&s.txnMetrics.retries),
+		Rollbacks:     atomic.LoadUint64(&s.txnMetrics.rollbacks),
+		TotalDuration: time.Duration(atomic.LoadInt64(&s.txnMetrics.totalDuration)),
+		Durations:     make([]metrics.DurationBucket, len(txnDurationBuckets)),
+	}
+	var cumulative uint64
+	for i, max := range txnDurationBuckets {
+		cumulative += atomic.LoadUint64(&s.txnMetrics.durations[i])
+		m.Durations[i] = metrics.DurationBucket{Max: max, Count: cumulative}
+	}
+	return m
+}
+
+// ContractLatency returns the persisted contract latency histograms.
+func (s *Store) ContractLatency() (records []metrics.LatencyRecord, err error) {
+	rows, err := s.query(`SELECT operation, phase, observations, total_duration, buckets FROM contract_latency`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contract latency: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r metrics.LatencyRecord
+		var buckets string
+		if err := rows.Scan(&r.Operation, &r.Phase, &r.Count, &r.TotalDuration, &buckets); err != nil {
+			return nil, fmt.Errorf("failed to scan contract latency: %w", err)
+		} else if err := json.Unmarshal([]byte(buckets), &r.Buckets); err != nil {
+			return nil, fmt.Errorf("failed to decode contract latency buckets: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// UpdateContractLatency persists the contract latency histograms.
+func (s *Store) UpdateContractLatency(records []metrics.LatencyRecord) error {
+	return s.transaction(func(tx txn) error {
+		stmt, err := tx.Prepare(`INSERT INTO contract_latency (operation, phase, observations, total_duration, buckets) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (operation, phase) DO UPDATE SET observations=EXCLUDED.observations, total_duration=EXCLUDED.total_duration, buckets=EXCLUDED.buckets`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, r := range records {
+			buckets, err := json.Marshal(r.Buckets)
+			if err != nil {
+				return fmt.Errorf("failed to encode contract latency buckets: %w", err)
+			} else if _, err := stmt.Exec(r.Operation, r.Phase, r.Count, r.TotalDuration, string(buckets)); err != nil {
+				return fmt.Errorf("failed to update %v %v latency: %w", r.Operation, r.Phase, err)
+			}
+		}
+		return nil
+	})
+}
+
 // IncrementRHPDataUsage increments the RHP3 ingress and egress metrics.
 func (s *Store) IncrementRHPDataUsage(ingress, egress uint64) error {
 	return s.transaction(func(tx txn) error {
@@ -285,8 +353,43 @@ func (s *Store) IncrementSectorStats(reads, writes, cacheHit, cacheMiss uint64)
 	})
 }
 
-// IncrementRegistryAccess increments the registry read and write metrics.
-func (s *Store) IncrementRegistryAccess(read, write uint64) error {
+// IncrementPrefetchStats increments the sector prefetch metrics.
+func (s *Store) IncrementPrefetchStats(prefetched, hits uint64) error {
+	return s.transaction(func(tx txn) error {
+		if prefetched > 0 {
+			if err := incrementNumericStat(tx, metricSectorPrefetch, int(prefetched), time.Now()); err != nil {
+				return fmt.Errorf("failed to track prefetches: %w", err)
+			}
+		}
+		if hits > 0 {
+			if err := incrementNumericStat(tx, metricSectorPrefetchHit, int(hits), time.Now()); err != nil {
+				return fmt.Errorf("failed to track prefetch hits: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// IncrementReadAheadStats increments the sector read-ahead metrics.
+func (s *Store) IncrementReadAheadStats(readAhead, hits uint64) error {
+	return s.transaction(func(tx txn) error {
+		if readAhead > 0 {
+			if err := incrementNumericStat(tx, metricSectorReadAhead, int(readAhead), time.Now()); err != nil {
+				return fmt.Errorf("failed to track read-ahead sectors: %w", err)
+			}
+		}
+		if hits > 0 {
+			if err := incrementNumericStat(tx, metricSectorReadAheadHit, int(hits), time.Now()); err != nil {
+				return fmt.Errorf("failed to track read-ahead hits: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// IncrementRegistryAccess increments the registry read, write, and rejected
+// write metrics.
+func (s *Store) IncrementRegistryAccess(read, write, rejected uint64) error {
 	return s.transaction(func(tx txn) error {
 		if read > 0 {
 			if err := incrementNumericStat(tx, metricRegistryReads, int(read), time.Now()); err != nil {
@@ -298,6 +401,11 @@ func (s *Store) IncrementRegistryAccess(read, write uint64) error {
 				return fmt.Errorf("failed to track writes: %w", err)
 			}
 		}
+		if rejected > 0 {
+			if err := incrementNumericStat(tx, metricRegistryRejections, int(rejected), time.Now()); err != nil {
+				return fmt.Errorf("failed to track rejections: %w", err)
+			}
+		}
 		return nil
 	})
 }
@@ -377,6 +485,14 @@ func mustParseMetricValue(stat string, buf []byte, m *metrics.Metrics) {
 		m.Storage.SectorCacheHits = mustScanUint64(buf)
 	case metricSectorCacheMiss:
 		m.Storage.SectorCacheMisses = mustScanUint64(buf)
+	case metricSectorPrefetch:
+		m.Storage.SectorPrefetches = mustScanUint64(buf)
+	case metricSectorPrefetchHit:
+		m.Storage.SectorPrefetchHits = mustScanUint64(buf)
+	case metricSectorReadAhead:
+		m.Storage.SectorReadAheads = mustScanUint64(buf)
+	case metricSectorReadAheadHit:
+		m.Storage.SectorReadAheadHits = mustScanUint64(buf)
 	// registry
 	case metricRegistryEntries:
 		m.Registry.Entries = mustScanUint64(buf)
@@ -386,6 +502,8 @@ func mustParseMetricValue(stat string, buf []byte, m *metrics.Metrics) {
 		m.Registry.Reads = mustScanUint64(buf)
 	case metricRegistryWrites:
 		m.Registry.Writes = mustScanUint64(buf)
+	case metricRegistryRejections:
+		m.Registry.Rejections = mustScanUint64(buf)
 	// bandwidth
 	case metricDataRHPIngress:
 		m.Data.RHP.Ingress = mustScanUint64(buf)
@@ -10,6 +10,434 @@ import (
 	"go.uber.org/zap"
 )
 
+// migrateVersion63 adds the contract_latency table to persist the contract
+// formation and renewal latency histograms.
+func migrateVersion63(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE contract_latency (
+	operation TEXT NOT NULL,
+	phase TEXT NOT NULL,
+	observations INTEGER NOT NULL,
+	total_duration INTEGER NOT NULL,
+	buckets TEXT NOT NULL,
+	PRIMARY KEY (operation, phase)
+);`)
+	return err
+}
+
+// migrateVersion62 adds the contract_formation_inputs table to look up
+// pending contracts by the outputs their formation sets spend.
+func migrateVersion62(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE contract_formation_inputs (
+	output_id BLOB NOT NULL,
+	contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	PRIMARY KEY (output_id, contract_id)
+);
+CREATE INDEX contract_formation_inputs_contract_id ON contract_formation_inputs(contract_id);`)
+	if err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	// only pending contracts are checked for double spends
+	rows, err := tx.Query(`SELECT id, formation_txn_set FROM contracts WHERE contract_status=$1`, contracts.ContractStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to query pending contracts: %w", err)
+	}
+	defer rows.Close()
+
+	formationSets := make(map[int64][]types.Transaction)
+	for rows.Next() {
+		var id int64
+		var buf []byte
+		var txnSet []types.Transaction
+		if err := rows.Scan(&id, &buf); err != nil {
+			return fmt.Errorf("failed to scan contract: %w", err)
+		} else if err := decodeTxnSet(buf, &txnSet); err != nil {
+			return fmt.Errorf("failed to decode formation set of contract %d: %w", id, err)
+		}
+		formationSets[id] = txnSet
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query pending contracts: %w", err)
+	}
+	rows.Close()
+
+	for id, txnSet := range formationSets {
+		if err := insertFormationInputs(tx, id, txnSet); err != nil {
+			return fmt.Errorf("failed to index formation inputs of contract %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// migrateVersion61 adds the proof failure circuit breaker's state to the
+// global_settings table.
+func migrateVersion61(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE global_settings ADD COLUMN proof_breaker_tripped_at INTEGER;
+ALTER TABLE global_settings ADD COLUMN proof_breaker_reset_at INTEGER;`)
+	return err
+}
+
+// migrateVersion60 removes fee expenditures recorded for rebroadcasts of a
+// contract action, keeping the latest, and makes each contract's fee unique
+// per category.
+func migrateVersion60(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`DELETE FROM contract_fee_expenditures WHERE id NOT IN (SELECT MAX(id) FROM contract_fee_expenditures GROUP BY contract_id, category);
+CREATE UNIQUE INDEX contract_fee_expenditures_contract_id_category ON contract_fee_expenditures(contract_id, category);`)
+	return err
+}
+
+// migrateVersion59 adds the packed_sectors table to track the payloads of
+// small sectors packed into shared sectors.
+func migrateVersion59(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE packed_sectors (
+	id INTEGER PRIMARY KEY,
+	sector_root BLOB UNIQUE NOT NULL, -- root of the zero-padded sector
+	physical_sector_id INTEGER NOT NULL REFERENCES stored_sectors(id), -- the shared sector holding the payload
+	payload_offset INTEGER NOT NULL,
+	payload_length INTEGER NOT NULL,
+	refs INTEGER NOT NULL
+);
+CREATE INDEX packed_sectors_physical_sector_id ON packed_sectors(physical_sector_id);`)
+	return err
+}
+
+// migrateVersion58 adds the contract_proof_failures table.
+func migrateVersion58(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE contract_proof_failures (
+	id INTEGER PRIMARY KEY,
+	contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	block_height INTEGER NOT NULL,
+	cause TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX contract_proof_failures_contract_id ON contract_proof_failures(contract_id);
+CREATE INDEX contract_proof_failures_date_created ON contract_proof_failures(date_created);`)
+	return err
+}
+
+// migrateVersion57 adds the proof_skip_reason column to the contracts table.
+func migrateVersion57(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE contracts ADD COLUMN proof_skip_reason TEXT;`)
+	return err
+}
+
+// migrateVersion56 adds the contract_tags table and indices to efficiently
+// page through filtered contracts.
+func migrateVersion56(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE contract_tags (
+	contract_id INTEGER NOT NULL REFERENCES contracts(id) ON DELETE CASCADE,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (contract_id, tag)
+);
+CREATE INDEX contract_tags_tag_contract_id ON contract_tags(tag, contract_id);
+CREATE INDEX contracts_contract_status_window_start ON contracts(contract_status, window_start);
+CREATE INDEX contracts_renter_id_window_start ON contracts(renter_id, window_start);`)
+	return err
+}
+
+// migrateVersion55 adds the resolution block and transaction ID columns to the
+// contracts table.
+func migrateVersion55(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE contracts ADD COLUMN resolution_block_id BLOB;
+ALTER TABLE contracts ADD COLUMN resolution_txn_id BLOB;`)
+	return err
+}
+
+// migrateVersion54 adds the contract history columns to the contract_renters
+// table and populates them from the existing contracts.
+func migrateVersion54(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE contract_renters ADD COLUMN formed_contracts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE contract_renters ADD COLUMN successful_contracts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE contract_renters ADD COLUMN failed_contracts INTEGER NOT NULL DEFAULT 0;`)
+	if err != nil {
+		return fmt.Errorf("failed to add columns: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE contract_renters SET formed_contracts=(SELECT COUNT(*) FROM contracts c WHERE c.renter_id=contract_renters.id),
+successful_contracts=(SELECT COUNT(*) FROM contracts c WHERE c.renter_id=contract_renters.id AND c.contract_status=$1),
+failed_contracts=(SELECT COUNT(*) FROM contracts c WHERE c.renter_id=contract_renters.id AND c.contract_status IN ($2, $3));`,
+		contracts.ContractStatusSuccessful, contracts.ContractStatusFailed, contracts.ContractStatusRejected)
+	if err != nil {
+		return fmt.Errorf("failed to populate renter history: %w", err)
+	}
+	return nil
+}
+
+// migrateVersion53 adds cumulative byte counters to the storage_volumes table
+// and the volume_io_counts table to track recent volume throughput.
+func migrateVersion53(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE storage_volumes ADD COLUMN bytes_read INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE storage_volumes ADD COLUMN bytes_written INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE volume_io_counts (
+	volume_id INTEGER NOT NULL REFERENCES storage_volumes (id) ON DELETE CASCADE,
+	period_start INTEGER NOT NULL,
+	bytes_read INTEGER NOT NULL,
+	bytes_written INTEGER NOT NULL,
+	PRIMARY KEY (volume_id, period_start)
+);
+CREATE INDEX volume_io_counts_period_start ON volume_io_counts(period_start);`)
+	return err
+}
+
+// migrateVersion52 adds the collateral scale columns to the host_settings
+// table.
+func migrateVersion52(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN min_collateral_scale REAL NOT NULL DEFAULT 0;
+ALTER TABLE host_settings ADD COLUMN max_collateral_scale REAL NOT NULL DEFAULT 0;`)
+	return err
+}
+
+// migrateVersion51 adds the quarantine_reason column to the contracts table.
+func migrateVersion51(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE contracts ADD COLUMN quarantine_reason TEXT;`)
+	return err
+}
+
+// migrateVersion50 adds the program limit columns to the host_settings
+// table.
+func migrateVersion50(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN max_read_sectors_per_program INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE host_settings ADD COLUMN max_append_sectors_per_program INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE host_settings ADD COLUMN max_program_instructions INTEGER NOT NULL DEFAULT 0;`)
+	return err
+}
+
+// migrateVersion49 adds the contract_fee_expenditures table to record the
+// miner fees paid by the host.
+func migrateVersion49(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE contract_fee_expenditures (
+	id INTEGER PRIMARY KEY,
+	contract_id BLOB NOT NULL,
+	category TEXT NOT NULL,
+	transaction_id BLOB NOT NULL,
+	amount BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX contract_fee_expenditures_date_created ON contract_fee_expenditures(date_created);`)
+	return err
+}
+
+// migrateVersion48 adds the min_account_deposit column to the host_settings
+// table.
+func migrateVersion48(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN min_account_deposit BLOB NOT NULL DEFAULT X'00000000000000000000000000000000';`)
+	return err
+}
+
+// migrateVersion47 adds the host_uptime table to track the intervals the
+// host was online.
+func migrateVersion47(tx txn, _ *zap.Logger) error {
+	const query = `CREATE TABLE host_uptime (
+	id INTEGER PRIMARY KEY,
+	start_timestamp INTEGER NOT NULL,
+	last_heartbeat INTEGER NOT NULL
+);
+CREATE INDEX host_uptime_last_heartbeat ON host_uptime(last_heartbeat);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion46 adds the formation_safety_margin column to the
+// host_settings table.
+func migrateVersion46(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN formation_safety_margin INTEGER NOT NULL DEFAULT 0;`)
+	return err
+}
+
+// migrateVersion45 adds the sector_allocation column to the host_settings
+// table.
+func migrateVersion45(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN sector_allocation TEXT NOT NULL DEFAULT 'firstFit';`)
+	return err
+}
+
+// migrateVersion44 adds the replica_group column to the storage_volumes table
+// and the sector_replicas table.
+func migrateVersion44(tx txn, _ *zap.Logger) error {
+	const query = `CREATE TABLE sector_replicas (
+	id INTEGER PRIMARY KEY,
+	sector_id INTEGER UNIQUE NOT NULL REFERENCES stored_sectors(id),
+	volume_sector_id INTEGER UNIQUE NOT NULL REFERENCES volume_sectors(id)
+);`
+	if _, err := tx.Exec(`ALTER TABLE storage_volumes ADD COLUMN replica_group TEXT NOT NULL DEFAULT '';`); err != nil {
+		return fmt.Errorf("failed to add replica_group column: %w", err)
+	} else if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to create sector_replicas table: %w", err)
+	}
+	return nil
+}
+
+// migrateVersion43 adds the zero_deleted_sectors column to the host_settings
+// table and the zero_pending column to the volume_sectors table.
+func migrateVersion43(tx txn, _ *zap.Logger) error {
+	if _, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN zero_deleted_sectors BOOLEAN NOT NULL DEFAULT false;`); err != nil {
+		return fmt.Errorf("failed to add zero_deleted_sectors column: %w", err)
+	} else if _, err := tx.Exec(`ALTER TABLE volume_sectors ADD COLUMN zero_pending BOOLEAN NOT NULL DEFAULT false;`); err != nil {
+		return fmt.Errorf("failed to add zero_pending column: %w", err)
+	} else if _, err := tx.Exec(`CREATE INDEX volume_sectors_zero_pending ON volume_sectors(zero_pending) WHERE zero_pending=true;`); err != nil {
+		return fmt.Errorf("failed to create zero_pending index: %w", err)
+	}
+	return nil
+}
+
+// migrateVersion42 adds the contract_pending_renewals table.
+func migrateVersion42(tx txn, _ *zap.Logger) error {
+	const query = `CREATE TABLE contract_pending_renewals ( -- renewals that were recorded, but may not have been broadcast
+	id INTEGER PRIMARY KEY,
+	renewed_contract_id INTEGER UNIQUE NOT NULL REFERENCES contracts(id),
+	cleared_contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	-- the state of the cleared contract before the renewal
+	previous_revision_number BLOB NOT NULL,
+	previous_raw_revision BLOB NOT NULL,
+	previous_host_sig BLOB NOT NULL,
+	previous_renter_sig BLOB NOT NULL,
+	previous_rpc_revenue BLOB NOT NULL,
+	previous_storage_revenue BLOB NOT NULL,
+	previous_ingress_revenue BLOB NOT NULL,
+	previous_egress_revenue BLOB NOT NULL,
+	previous_account_funding BLOB NOT NULL,
+	previous_risked_collateral BLOB NOT NULL,
+	date_created INTEGER NOT NULL
+);`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVersion41 adds the wallet_pruned_count, wallet_pruned_inflow, and
+// wallet_pruned_outflow columns to the global_settings table.
+func migrateVersion41(tx txn, _ *zap.Logger) error {
+	if _, err := tx.Exec(`ALTER TABLE global_settings ADD COLUMN wallet_pruned_count INTEGER NOT NULL DEFAULT 0;`); err != nil {
+		return fmt.Errorf("failed to add wallet_pruned_count column: %w", err)
+	} else if _, err := tx.Exec(`ALTER TABLE global_settings ADD COLUMN wallet_pruned_inflow BLOB;`); err != nil {
+		return fmt.Errorf("failed to add wallet_pruned_inflow column: %w", err)
+	}
+	_, err := tx.Exec(`ALTER TABLE global_settings ADD COLUMN wallet_pruned_outflow BLOB;`)
+	return err
+}
+
+// migrateVersion40 adds the encryption_salt and encryption_key_check columns
+// to the storage_volumes table.
+func migrateVersion40(tx txn, _ *zap.Logger) error {
+	if _, err := tx.Exec(`ALTER TABLE storage_volumes ADD COLUMN encryption_salt BLOB;`); err != nil {
+		return fmt.Errorf("failed to add encryption_salt column: %w", err)
+	}
+	_, err := tx.Exec(`ALTER TABLE storage_volumes ADD COLUMN encryption_key_check BLOB;`)
+	return err
+}
+
+// migrateVersion39 adds the pinned column to the contracts table.
+func migrateVersion39(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE contracts ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT false;`)
+	return err
+}
+
+// migrateVersion38 adds the collateral_released column to the contracts
+// table.
+func migrateVersion38(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE contracts ADD COLUMN collateral_released BOOLEAN NOT NULL DEFAULT false;`)
+	return err
+}
+
+// migrateVersion37 adds the failure_reason column to the contracts table.
+func migrateVersion37(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE contracts ADD COLUMN failure_reason TEXT;`)
+	return err
+}
+
+// migrateVersion36 adds the contract_tombstones table to keep a record of
+// contracts that have been pruned.
+func migrateVersion36(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE contract_tombstones (
+	id INTEGER PRIMARY KEY,
+	contract_id BLOB UNIQUE NOT NULL,
+	revision_number BLOB NOT NULL,
+	contract_status INTEGER NOT NULL,
+	revenue BLOB NOT NULL,
+	resolution_height INTEGER,
+	resolution_txn_id BLOB,
+	date_pruned INTEGER NOT NULL
+);`)
+	return err
+}
+
+// migrateVersion35 adds the contract_action_results table to record the
+// outcome of contract lifecycle actions.
+func migrateVersion35(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE contract_action_results (
+	id INTEGER PRIMARY KEY,
+	contract_id INTEGER NOT NULL REFERENCES contracts(id),
+	block_height INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	action_status TEXT NOT NULL,
+	transaction_ids BLOB,
+	fee BLOB NOT NULL,
+	error_message TEXT,
+	proof_failure_cause TEXT,
+	date_created INTEGER NOT NULL
+);
+CREATE INDEX contract_action_results_contract_id ON contract_action_results(contract_id);`)
+	return err
+}
+
+// migrateVersion34 adds the max_sectors_per_rpc column to the host_settings
+// table. Existing hosts are given the default limit.
+func migrateVersion34(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN max_sectors_per_rpc INTEGER NOT NULL DEFAULT 256;`)
+	return err
+}
+
+// migrateVersion33 adds the checksum column to the stored_sectors table.
+func migrateVersion33(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE stored_sectors ADD COLUMN checksum INTEGER;`)
+	return err
+}
+
+// migrateVersion32 adds the min_ingress_collateral_ratio column to the
+// host_settings table.
+func migrateVersion32(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN min_ingress_collateral_ratio REAL NOT NULL DEFAULT 0;`)
+	return err
+}
+
+// migrateVersion31 adds the sector_access_counts table to track sector reads
+// for hot sector analysis.
+func migrateVersion31(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE sector_access_counts (
+	sector_id INTEGER NOT NULL REFERENCES stored_sectors (id) ON DELETE CASCADE,
+	period_start INTEGER NOT NULL,
+	reads INTEGER NOT NULL,
+	PRIMARY KEY (sector_id, period_start)
+);
+CREATE INDEX sector_access_counts_period_start ON sector_access_counts(period_start);`)
+	return err
+}
+
+// migrateVersion30 adds the last_announce_fee column to the global_settings
+// table.
+func migrateVersion30(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE global_settings ADD COLUMN last_announce_fee BLOB;`)
+	return err
+}
+
+// migrateVersion29 adds the volume_migrations table to track the progress of
+// sector migrations.
+func migrateVersion29(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`CREATE TABLE volume_migrations (
+	volume_id INTEGER PRIMARY KEY REFERENCES storage_volumes (id) ON DELETE CASCADE,
+	min_index INTEGER NOT NULL,
+	last_index INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);`)
+	return err
+}
+
+// migrateVersion28 adds the min_host_payout column to the host_settings table.
+func migrateVersion28(tx txn, _ *zap.Logger) error {
+	_, err := tx.Exec(`ALTER TABLE host_settings ADD COLUMN min_host_payout BLOB NOT NULL DEFAULT X'00000000000000000000000000000000';`)
+	return err
+}
+
 // migrateVersion27 adds the sector_writes column to the volume_sectors table to
 // more evenly distribute sector writes across disks.
 func migrateVersion27(tx txn, _ *zap.Logger) error {
@@ -764,4 +1192,40 @@ var migrations = []func(tx txn, log *zap.Logger) error{
 	migrateVersion25,
 	migrateVersion26,
 	migrateVersion27,
+	migrateVersion28,
+	migrateVersion29,
+	migrateVersion30,
+	migrateVersion31,
+	migrateVersion32,
+	migrateVersion33,
+	migrateVersion34,
+	migrateVersion35,
+	migrateVersion36,
+	migrateVersion37,
+	migrateVersion38,
+	migrateVersion39,
+	migrateVersion40,
+	migrateVersion41,
+	migrateVersion42,
+	migrateVersion43,
+	migrateVersion44,
+	migrateVersion45,
+	migrateVersion46,
+	migrateVersion47,
+	migrateVersion48,
+	migrateVersion49,
+	migrateVersion50,
+	migrateVersion51,
+	migrateVersion52,
+	migrateVersion53,
+	migrateVersion54,
+	migrateVersion55,
+	migrateVersion56,
+	migrateVersion57,
+	migrateVersion58,
+	migrateVersion59,
+	migrateVersion60,
+	migrateVersion61,
+	migrateVersion62,
+	migrateVersion63,
 }
@@ -70,6 +70,11 @@ func (s *Store) RemoveSector(root types.Hash256) (err error) {
 			return fmt.Errorf("failed to remove sector: %w", err)
 		}
 
+		// free the sector's replica
+		if err := removeSectorReplica(tx, sectorID, false); err != nil {
+			return fmt.Errorf("failed to remove sector replica: %w", err)
+		}
+
 		// decrement volume usage and metrics
 		if err = incrementVolumeUsage(tx, volumeID, -1); err != nil {
 			return fmt.Errorf("failed to update volume usage: %w", err)
@@ -154,6 +159,77 @@ func (s *Store) ExpireTempSectors(height uint64) error {
 	}
 }
 
+// leakedSectorsQuery selects the stored sectors that are not referenced by a
+// contract, temporary storage, a packed sector, or a lock.
+const leakedSectorsQuery = `SELECT ss.id, ss.sector_root FROM stored_sectors ss
+WHERE NOT EXISTS (SELECT 1 FROM contract_sector_roots csr WHERE csr.sector_id=ss.id)
+AND NOT EXISTS (SELECT 1 FROM temp_storage_sector_roots tsr WHERE tsr.sector_id=ss.id)
+AND NOT EXISTS (SELECT 1 FROM packed_sectors ps WHERE ps.physical_sector_id=ss.id)
+AND NOT EXISTS (SELECT 1 FROM locked_sectors ls WHERE ls.sector_id=ss.id)`
+
+// LeakedSectors returns the roots of stored sectors that are not referenced
+// by any contract or temporary storage.
+func (s *Store) LeakedSectors() (roots []types.Hash256, err error) {
+	rows, err := s.query(leakedSectorsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaked sectors: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var root types.Hash256
+		if err := rows.Scan(&id, (*sqlHash256)(&root)); err != nil {
+			return nil, fmt.Errorf("failed to scan sector: %w", err)
+		}
+		roots = append(roots, root)
+	}
+	return roots, rows.Err()
+}
+
+func (s *Store) batchPruneLeakedSectors() (pruned []types.Hash256, err error) {
+	err = s.transaction(func(tx txn) error {
+		rows, err := tx.Query(leakedSectorsQuery+` LIMIT $1`, sqlSectorBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to query leaked sectors: %w", err)
+		}
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			var root types.Hash256
+			if err := rows.Scan(&id, (*sqlHash256)(&root)); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan sector: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Close(); err != nil {
+			return fmt.Errorf("failed to close rows: %w", err)
+		}
+
+		pruned, err = pruneSectors(tx, ids)
+		return err
+	})
+	return
+}
+
+// PruneLeakedSectors removes the metadata of every leaked sector and frees
+// its location.
+func (s *Store) PruneLeakedSectors() (pruned []types.Hash256, err error) {
+	log := s.log.Named("PruneLeakedSectors")
+	// prune in batches to avoid holding a lock on the table for too long
+	for i := 0; ; i++ {
+		batch, err := s.batchPruneLeakedSectors()
+		if err != nil {
+			return nil, fmt.Errorf("failed to prune leaked sectors: %w", err)
+		} else if len(batch) == 0 {
+			return pruned, nil
+		}
+		log.Debug("pruned leaked sectors", zap.Stringers("removed", batch), zap.Int("batch", i))
+		pruned = append(pruned, batch...)
+		jitterSleep(time.Millisecond) // allow other transactions to run
+	}
+}
+
 // HasSector returns true if the sector is stored on the host.
 func (s *Store) HasSector(root types.Hash256) (bool, error) {
 	var dbID int64
@@ -166,6 +242,87 @@ func (s *Store) HasSector(root types.Hash256) (bool, error) {
 	return true, nil
 }
 
+// SetSectorChecksum sets the checksum of a stored sector's data.
+func (s *Store) SetSectorChecksum(root types.Hash256, checksum uint32) error {
+	res, err := s.exec(`UPDATE stored_sectors SET checksum=$1 WHERE sector_root=$2`, checksum, sqlHash256(root))
+	if err != nil {
+		return err
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		return storage.ErrSectorNotFound
+	}
+	return nil
+}
+
+// SectorChecksum returns the checksum of a stored sector's data. If no
+// checksum has been stored, ok is false.
+func (s *Store) SectorChecksum(root types.Hash256) (checksum uint32, ok bool, err error) {
+	var value sql.NullInt64
+	err = s.queryRow(`SELECT checksum FROM stored_sectors WHERE sector_root=$1`, sqlHash256(root)).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, storage.ErrSectorNotFound
+	} else if err != nil {
+		return 0, false, err
+	}
+	return uint32(value.Int64), value.Valid, nil
+}
+
+// RecordSectorAccess adds the number of times each sector was read to the
+// access counts for the period containing timestamp. Counts for sectors that
+// are no longer stored are ignored.
+func (s *Store) RecordSectorAccess(reads map[types.Hash256]uint64, timestamp time.Time) error {
+	periodStart := sqlTime(timestamp.Truncate(time.Hour))
+	return s.transaction(func(tx txn) error {
+		stmt, err := tx.Prepare(`INSERT INTO sector_access_counts (sector_id, period_start, reads) SELECT id, $1, $2 FROM stored_sectors WHERE sector_root=$3
+ON CONFLICT (sector_id, period_start) DO UPDATE SET reads=reads+EXCLUDED.reads`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for root, n := range reads {
+			if _, err := stmt.Exec(periodStart, n, sqlHash256(root)); err != nil {
+				return fmt.Errorf("failed to record access for sector %v: %w", root, err)
+			}
+		}
+		return nil
+	})
+}
+
+// PruneSectorAccess removes access counts for periods that started before
+// the given time.
+func (s *Store) PruneSectorAccess(before time.Time) error {
+	_, err := s.exec(`DELETE FROM sector_access_counts WHERE period_start < $1`, sqlTime(before))
+	return err
+}
+
+// HotSectors returns the sectors with the most reads since the given time,
+// ordered by the number of reads descending.
+func (s *Store) HotSectors(since time.Time, limit int) (sectors []storage.SectorAccess, err error) {
+	const query = `SELECT ss.sector_root, SUM(sac.reads) AS total_reads
+FROM sector_access_counts sac
+INNER JOIN stored_sectors ss ON (ss.id=sac.sector_id)
+WHERE sac.period_start >= $1
+GROUP BY sac.sector_id
+ORDER BY total_reads DESC
+LIMIT $2`
+	rows, err := s.query(query, sqlTime(since.Truncate(time.Hour)), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sector storage.SectorAccess
+		if err := rows.Scan((*sqlHash256)(&sector.Root), &sector.Reads); err != nil {
+			return nil, fmt.Errorf("failed to scan sector access: %w", err)
+		}
+		sectors = append(sectors, sector)
+	}
+	return sectors, rows.Err()
+}
+
 // SectorReferences returns the references, if any of a sector root
 func (s *Store) SectorReferences(root types.Hash256) (refs storage.SectorReference, err error) {
 	err = s.transaction(func(tx txn) error {
@@ -191,11 +348,88 @@ func (s *Store) SectorReferences(root types.Hash256) (refs storage.SectorReferen
 		if err != nil {
 			return fmt.Errorf("failed to get locks: %w", err)
 		}
+
+		// check if the sector holds packed payloads
+		err = tx.QueryRow(`SELECT COALESCE(SUM(refs), 0) FROM packed_sectors WHERE physical_sector_id=$1;`, dbID).Scan(&refs.Packed)
+		if err != nil {
+			return fmt.Errorf("failed to get packed references: %w", err)
+		}
 		return nil
 	})
 	return
 }
 
+// StorePackedSectors records the payloads packed into a shared sector.
+func (s *Store) StorePackedSectors(physicalRoot types.Hash256, sectors []storage.PackedSector) error {
+	return s.transaction(func(tx txn) error {
+		physicalID, err := sectorDBID(tx, physicalRoot)
+		if err != nil {
+			return fmt.Errorf("failed to get shared sector: %w", err)
+		}
+
+		stmt, err := tx.Prepare(`INSERT INTO packed_sectors (sector_root, physical_sector_id, payload_offset, payload_length, refs) VALUES ($1, $2, $3, $4, 1)
+ON CONFLICT (sector_root) DO UPDATE SET refs=refs+1;`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, ps := range sectors {
+			if _, err := stmt.Exec(sqlHash256(ps.Root), physicalID, ps.Offset, ps.Length); err != nil {
+				return fmt.Errorf("failed to store packed sector %v: %w", ps.Root, err)
+			}
+		}
+		return nil
+	})
+}
+
+// PackedSector returns the location of a packed sector's payload.
+func (s *Store) PackedSector(root types.Hash256) (ps storage.PackedSector, err error) {
+	err = s.queryRow(`SELECT ps.sector_root, ss.sector_root, ps.payload_offset, ps.payload_length FROM packed_sectors ps
+INNER JOIN stored_sectors ss ON (ps.physical_sector_id=ss.id)
+WHERE ps.sector_root=$1`, sqlHash256(root)).Scan((*sqlHash256)(&ps.Root), (*sqlHash256)(&ps.PhysicalRoot), &ps.Offset, &ps.Length)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = storage.ErrSectorNotFound
+	}
+	return
+}
+
+// AddPackedSectorReference adds a reference to a packed sector.
+func (s *Store) AddPackedSectorReference(root types.Hash256) error {
+	return s.transaction(func(tx txn) error {
+		var id int64
+		err := tx.QueryRow(`UPDATE packed_sectors SET refs=refs+1 WHERE sector_root=$1 RETURNING id;`, sqlHash256(root)).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrSectorNotFound
+		}
+		return err
+	})
+}
+
+// RemovePackedSectorReference removes a reference to a packed sector. The
+// payload is removed with its last reference and the shared sector is pruned
+// if it is no longer referenced.
+func (s *Store) RemovePackedSectorReference(root types.Hash256) error {
+	return s.transaction(func(tx txn) error {
+		var id, physicalID, refs int64
+		err := tx.QueryRow(`UPDATE packed_sectors SET refs=refs-1 WHERE sector_root=$1 RETURNING id, physical_sector_id, refs;`, sqlHash256(root)).Scan(&id, &physicalID, &refs)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrSectorNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to remove reference: %w", err)
+		} else if refs > 0 {
+			return nil
+		}
+
+		if _, err := tx.Exec(`DELETE FROM packed_sectors WHERE id=$1;`, id); err != nil {
+			return fmt.Errorf("failed to delete packed sector: %w", err)
+		} else if _, err := pruneSectors(tx, []int64{physicalID}); err != nil {
+			return fmt.Errorf("failed to prune shared sector: %w", err)
+		}
+		return nil
+	})
+}
+
 func contractSectorRefs(tx txn, sectorID int64) (contractIDs []types.FileContractID, err error) {
 	rows, err := tx.Query(`SELECT DISTINCT contract_id FROM contract_sector_roots WHERE sector_id=$1;`, sectorID)
 	if err != nil {
@@ -236,7 +470,23 @@ func incrementVolumeUsage(tx txn, volumeID int64, delta int) error {
 	return nil
 }
 
+// zeroDeletedSectors returns true if the host's settings require the data of
+// deleted sectors to be zeroed.
+func zeroDeletedSectors(tx txn) (zero bool, err error) {
+	err = tx.QueryRow(`SELECT zero_deleted_sectors FROM host_settings`).Scan(&zero)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return
+}
+
 func pruneSectors(tx txn, ids []int64) (pruned []types.Hash256, err error) {
+	// if enabled, freed locations are not reused until they are zeroed
+	zeroPending, err := zeroDeletedSectors(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zero deleted sectors setting: %w", err)
+	}
+
 	hasContractRefStmt, err := tx.Prepare(`SELECT id FROM contract_sector_roots WHERE sector_id=$1 LIMIT 1`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare contract reference query: %w", err)
@@ -255,7 +505,13 @@ func pruneSectors(tx txn, ids []int64) (pruned []types.Hash256, err error) {
 	}
 	defer hasLockStmt.Close()
 
-	clearVolumeStmt, err := tx.Prepare(`UPDATE volume_sectors SET sector_id=NULL WHERE sector_id=$1 RETURNING volume_id`)
+	hasPackedRefStmt, err := tx.Prepare(`SELECT id FROM packed_sectors WHERE physical_sector_id=$1 LIMIT 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare packed reference query: %w", err)
+	}
+	defer hasPackedRefStmt.Close()
+
+	clearVolumeStmt, err := tx.Prepare(`UPDATE volume_sectors SET (sector_id, zero_pending)=(NULL, $1) WHERE sector_id=$2 RETURNING volume_id`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare volume reference query: %w", err)
 	}
@@ -293,14 +549,27 @@ func pruneSectors(tx txn, ids []int64) (pruned []types.Hash256, err error) {
 			continue // sector is locked
 		}
 
+		var packedDBID int64
+		err = hasPackedRefStmt.QueryRow(id).Scan(&packedDBID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to check packed references: %w", err)
+		} else if err == nil {
+			continue // sector holds packed payloads
+		}
+
 		var volumeDBID int64
-		err = clearVolumeStmt.QueryRow(id).Scan(&volumeDBID)
+		err = clearVolumeStmt.QueryRow(zeroPending, id).Scan(&volumeDBID)
 		if err != nil && !errors.Is(err, sql.ErrNoRows) { // ignore rows not found
 			return nil, fmt.Errorf("failed to clear volume references: %w", err)
 		} else if err == nil {
 			volumeDelta[volumeDBID]-- // sector was removed from a volume
 		}
 
+		// free the sector's replica
+		if err := removeSectorReplica(tx, id, zeroPending); err != nil {
+			return nil, fmt.Errorf("failed to remove sector replica: %w", err)
+		}
+
 		var root types.Hash256
 		err = deleteSectorStmt.QueryRow(id).Scan((*sqlHash256)(&root))
 		if err != nil && !errors.Is(err, sql.ErrNoRows) { // ignore rows not found
@@ -50,7 +50,7 @@ func (s *Store) Settings() (config settings.Settings, err error) {
 	contract_price, base_rpc_price, sector_access_price, collateral_multiplier, 
 	max_collateral, storage_price, egress_price, ingress_price, 
 	max_account_balance, max_account_age, price_table_validity, max_contract_duration, window_size, 
-	ingress_limit, egress_limit, registry_limit, ddns_provider, ddns_update_v4, ddns_update_v6, ddns_opts, sector_cache_size
+	ingress_limit, egress_limit, registry_limit, ddns_provider, ddns_update_v4, ddns_update_v6, ddns_opts, sector_cache_size, min_host_payout, min_ingress_collateral_ratio, max_sectors_per_rpc, zero_deleted_sectors, sector_allocation, formation_safety_margin, min_account_deposit, max_read_sectors_per_program, max_append_sectors_per_program, max_program_instructions, min_collateral_scale, max_collateral_scale
 FROM host_settings;`
 	err = s.queryRow(query).Scan(&config.Revision, &config.AcceptingContracts,
 		&config.NetAddress, (*sqlCurrency)(&config.ContractPrice),
@@ -60,7 +60,9 @@ FROM host_settings;`
 		(*sqlCurrency)(&config.IngressPrice), (*sqlCurrency)(&config.MaxAccountBalance),
 		&config.AccountExpiry, &config.PriceTableValidity, &config.MaxContractDuration, &config.WindowSize,
 		&config.IngressLimit, &config.EgressLimit, &config.MaxRegistryEntries,
-		&config.DDNS.Provider, &config.DDNS.IPv4, &config.DDNS.IPv6, &dyndnsBuf, &config.SectorCacheSize)
+		&config.DDNS.Provider, &config.DDNS.IPv4, &config.DDNS.IPv6, &dyndnsBuf, &config.SectorCacheSize,
+		(*sqlCurrency)(&config.MinHostPayout), &config.MinIngressCollateralRatio, &config.MaxSectorsPerRPC, &config.ZeroDeletedSectors, &config.SectorAllocation, &config.FormationSafetyMargin, (*sqlCurrency)(&config.MinAccountDeposit),
+		&config.MaxReadSectorsPerProgram, &config.MaxAppendSectorsPerProgram, &config.MaxProgramInstructions, &config.MinCollateralScale, &config.MaxCollateralScale)
 	if errors.Is(err, sql.ErrNoRows) {
 		return settings.Settings{}, settings.ErrNoSettings
 	}
@@ -80,21 +82,21 @@ func (s *Store) UpdateSettings(settings settings.Settings) error {
 		sector_access_price, collateral_multiplier, max_collateral, storage_price, 
 		egress_price, ingress_price, max_account_balance, 
 		max_account_age, price_table_validity, max_contract_duration, window_size, ingress_limit, 
-		egress_limit, registry_limit, ddns_provider, ddns_update_v4, ddns_update_v6, ddns_opts, sector_cache_size) 
-		VALUES (0, 0, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23) 
+		egress_limit, registry_limit, ddns_provider, ddns_update_v4, ddns_update_v6, ddns_opts, sector_cache_size, min_host_payout, min_ingress_collateral_ratio, max_sectors_per_rpc, zero_deleted_sectors, sector_allocation, formation_safety_margin, min_account_deposit, max_read_sectors_per_program, max_append_sectors_per_program, max_program_instructions, min_collateral_scale, max_collateral_scale) 
+		VALUES (0, 0, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35) 
 ON CONFLICT (id) DO UPDATE SET (settings_revision, 
 	accepting_contracts, net_address, contract_price, base_rpc_price, 
 	sector_access_price, collateral_multiplier, max_collateral, storage_price, 
 	egress_price, ingress_price, max_account_balance, 
 	max_account_age, price_table_validity, max_contract_duration, window_size, ingress_limit, 
-	egress_limit, registry_limit, ddns_provider, ddns_update_v4, ddns_update_v6, ddns_opts, sector_cache_size) = (
+	egress_limit, registry_limit, ddns_provider, ddns_update_v4, ddns_update_v6, ddns_opts, sector_cache_size, min_host_payout, min_ingress_collateral_ratio, max_sectors_per_rpc, zero_deleted_sectors, sector_allocation, formation_safety_margin, min_account_deposit, max_read_sectors_per_program, max_append_sectors_per_program, max_program_instructions, min_collateral_scale, max_collateral_scale) = (
 	settings_revision + 1, EXCLUDED.accepting_contracts, EXCLUDED.net_address,
 	EXCLUDED.contract_price, EXCLUDED.base_rpc_price, EXCLUDED.sector_access_price,
 	EXCLUDED.collateral_multiplier, EXCLUDED.max_collateral, EXCLUDED.storage_price,
 	EXCLUDED.egress_price, EXCLUDED.ingress_price, EXCLUDED.max_account_balance,
 	EXCLUDED.max_account_age, EXCLUDED.price_table_validity, EXCLUDED.max_contract_duration, EXCLUDED.window_size, 
 	EXCLUDED.ingress_limit, EXCLUDED.egress_limit, EXCLUDED.registry_limit, EXCLUDED.ddns_provider, 
-	EXCLUDED.ddns_update_v4, EXCLUDED.ddns_update_v6, EXCLUDED.ddns_opts, EXCLUDED.sector_cache_size);`
+	EXCLUDED.ddns_update_v4, EXCLUDED.ddns_update_v6, EXCLUDED.ddns_opts, EXCLUDED.sector_cache_size, EXCLUDED.min_host_payout, EXCLUDED.min_ingress_collateral_ratio, EXCLUDED.max_sectors_per_rpc, EXCLUDED.zero_deleted_sectors, EXCLUDED.sector_allocation, EXCLUDED.formation_safety_margin, EXCLUDED.min_account_deposit, EXCLUDED.max_read_sectors_per_program, EXCLUDED.max_append_sectors_per_program, EXCLUDED.max_program_instructions, EXCLUDED.min_collateral_scale, EXCLUDED.max_collateral_scale);`
 	var dnsOptsBuf []byte
 	if settings.DDNS.Provider != "" {
 		var err error
@@ -113,7 +115,9 @@ ON CONFLICT (id) DO UPDATE SET (settings_revision,
 			sqlCurrency(settings.IngressPrice), sqlCurrency(settings.MaxAccountBalance),
 			settings.AccountExpiry, settings.PriceTableValidity, settings.MaxContractDuration, settings.WindowSize,
 			settings.IngressLimit, settings.EgressLimit, settings.MaxRegistryEntries,
-			settings.DDNS.Provider, settings.DDNS.IPv4, settings.DDNS.IPv6, dnsOptsBuf, settings.SectorCacheSize)
+			settings.DDNS.Provider, settings.DDNS.IPv4, settings.DDNS.IPv6, dnsOptsBuf, settings.SectorCacheSize,
+			sqlCurrency(settings.MinHostPayout), settings.MinIngressCollateralRatio, settings.MaxSectorsPerRPC, settings.ZeroDeletedSectors, settings.SectorAllocation, settings.FormationSafetyMargin, sqlCurrency(settings.MinAccountDeposit),
+			settings.MaxReadSectorsPerProgram, settings.MaxAppendSectorsPerProgram, settings.MaxProgramInstructions, settings.MinCollateralScale, settings.MaxCollateralScale)
 		if err != nil {
 			return fmt.Errorf("failed to update settings: %w", err)
 		}
@@ -156,8 +160,8 @@ func (s *Store) HostKey() (pk types.PrivateKey) {
 func (s *Store) LastAnnouncement() (ann settings.Announcement, err error) {
 	var height sql.NullInt64
 	var address sql.NullString
-	err = s.queryRow(`SELECT last_announce_id, last_announce_height, last_announce_address, last_announce_key FROM global_settings`).
-		Scan(nullable((*sqlHash256)(&ann.Index.ID)), &height, &address, nullable((*sqlHash256)(&ann.PublicKey)))
+	err = s.queryRow(`SELECT last_announce_id, last_announce_height, last_announce_address, last_announce_key, last_announce_fee FROM global_settings`).
+		Scan(nullable((*sqlHash256)(&ann.Index.ID)), &height, &address, nullable((*sqlHash256)(&ann.PublicKey)), nullable((*sqlCurrency)(&ann.Fee)))
 	if errors.Is(err, sql.ErrNoRows) {
 		return settings.Announcement{}, nil
 	}
@@ -174,15 +178,15 @@ func (s *Store) LastAnnouncement() (ann settings.Announcement, err error) {
 // UpdateLastAnnouncement updates the last announcement.
 func (s *Store) UpdateLastAnnouncement(ann settings.Announcement) error {
 	const query = `UPDATE global_settings SET 
-last_announce_id=$1, last_announce_height=$2, last_announce_address=$3, last_announce_key=$4;`
-	_, err := s.exec(query, sqlHash256(ann.Index.ID), ann.Index.Height, ann.Address, sqlHash256(ann.PublicKey))
+last_announce_id=$1, last_announce_height=$2, last_announce_address=$3, last_announce_key=$4, last_announce_fee=$5;`
+	_, err := s.exec(query, sqlHash256(ann.Index.ID), ann.Index.Height, ann.Address, sqlHash256(ann.PublicKey), sqlCurrency(ann.Fee))
 	return err
 }
 
 // RevertLastAnnouncement reverts the last announcement.
 func (s *Store) RevertLastAnnouncement() error {
 	const query = `UPDATE global_settings SET
-last_announce_id=NULL, last_announce_height=NULL, last_announce_address=NULL, last_announce_key=NULL;`
+last_announce_id=NULL, last_announce_height=NULL, last_announce_address=NULL, last_announce_key=NULL, last_announce_fee=NULL;`
 	_, err := s.exec(query)
 	return err
 }
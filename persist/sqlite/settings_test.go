@@ -19,23 +19,32 @@ import (
 
 func randomSettings() settings.Settings {
 	return settings.Settings{
-		AcceptingContracts:   frand.Intn(1) == 1,
-		NetAddress:           hex.EncodeToString(frand.Bytes(64)),
-		MaxContractDuration:  uint64(frand.Intn(math.MaxInt)),
-		ContractPrice:        types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		BaseRPCPrice:         types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		SectorAccessPrice:    types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		CollateralMultiplier: frand.Float64(),
-		MaxCollateral:        types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		StoragePrice:         types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		EgressPrice:          types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		IngressPrice:         types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
-		IngressLimit:         uint64(frand.Intn(math.MaxInt)),
-		EgressLimit:          uint64(frand.Intn(math.MaxInt)),
-		MaxRegistryEntries:   uint64(frand.Intn(math.MaxInt)),
-		AccountExpiry:        time.Duration(frand.Intn(math.MaxInt)),
-		PriceTableValidity:   time.Duration(frand.Intn(math.MaxInt)),
-		MaxAccountBalance:    types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		AcceptingContracts:         frand.Intn(1) == 1,
+		NetAddress:                 hex.EncodeToString(frand.Bytes(64)),
+		MaxContractDuration:        uint64(frand.Intn(math.MaxInt)),
+		ContractPrice:              types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		BaseRPCPrice:               types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		SectorAccessPrice:          types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		CollateralMultiplier:       frand.Float64(),
+		MaxCollateral:              types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		StoragePrice:               types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		EgressPrice:                types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		IngressPrice:               types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		IngressLimit:               uint64(frand.Intn(math.MaxInt)),
+		EgressLimit:                uint64(frand.Intn(math.MaxInt)),
+		MaxRegistryEntries:         uint64(frand.Intn(math.MaxInt)),
+		AccountExpiry:              time.Duration(frand.Intn(math.MaxInt)),
+		PriceTableValidity:         time.Duration(frand.Intn(math.MaxInt)),
+		MaxAccountBalance:          types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		MinHostPayout:              types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		MinAccountDeposit:          types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
+		MinIngressCollateralRatio:  frand.Float64(),
+		MaxSectorsPerRPC:           uint64(frand.Intn(math.MaxInt)),
+		MaxReadSectorsPerProgram:   uint64(frand.Intn(math.MaxInt)),
+		MaxAppendSectorsPerProgram: uint64(frand.Intn(math.MaxInt)),
+		MaxProgramInstructions:     uint64(frand.Intn(math.MaxInt)),
+		MinCollateralScale:         frand.Float64(),
+		MaxCollateralScale:         frand.Float64(),
 	}
 }
 
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mattn/go-sqlite3"
@@ -13,14 +15,63 @@ import (
 	"lukechampine.com/frand"
 )
 
+// txnDurationBuckets are the upper bounds of the transaction duration
+// histogram buckets.
+var txnDurationBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
 type (
+	// txnMetrics tracks the store's transactions. All fields are updated
+	// atomically.
+	txnMetrics struct {
+		count         uint64
+		retries       uint64
+		rollbacks     uint64
+		totalDuration int64
+		// durations counts the transactions in each bucket. It is not
+		// cumulative.
+		durations [len(txnDurationBuckets)]uint64
+	}
+
 	// A Store is a persistent store that uses a SQL database as its backend.
 	Store struct {
 		db  *sql.DB
 		log *zap.Logger
+
+		txnMetrics txnMetrics
+		// lastAllocatedVolume is the volume that most recently received a
+		// new sector. It is used by the spread allocation strategy.
+		lastAllocatedVolume int64
+
+		diskMu      sync.Mutex // guards the following fields
+		diskFull    bool
+		diskFullFns []func(full bool)
+		closed      chan struct{}
 	}
 )
 
+// observe records a completed transaction
+func (tm *txnMetrics) observe(elapsed time.Duration, attempts int) {
+	atomic.AddUint64(&tm.count, 1)
+	atomic.AddUint64(&tm.retries, uint64(attempts-1))
+	atomic.AddInt64(&tm.totalDuration, int64(elapsed))
+	for i, max := range txnDurationBuckets {
+		if elapsed <= max {
+			atomic.AddUint64(&tm.durations[i], 1)
+			break
+		}
+	}
+}
+
 // exec executes a query without returning any rows. The args are for
 // any placeholder parameters in the query.
 func (s *Store) exec(query string, args ...any) (sql.Result, error) {
@@ -86,17 +137,28 @@ func (s *Store) transaction(fn func(txn) error) error {
 	log := s.log.Named("transaction").With(zap.String("id", txnID))
 	start := time.Now()
 	attempt := 1
+	defer func() {
+		s.txnMetrics.observe(time.Since(start), attempt)
+	}()
 	for ; attempt < maxRetryAttempts; attempt++ {
 		attemptStart := time.Now()
 		log := log.With(zap.Int("attempt", attempt))
 		err = doTransaction(s.db, log, fn)
 		if err == nil {
-			// no error, break out of the loop
+			// a successful write means there is free space on the disk again
+			s.setDiskFull(false)
 			return nil
 		}
+		atomic.AddUint64(&s.txnMetrics.rollbacks, 1)
 
-		// return immediately if the error is not a busy error
-		if !strings.Contains(err.Error(), "database is locked") {
+		if isDiskFullErr(err) {
+			// retrying immediately will not free any space. The probe
+			// started by setDiskFull retries periodically instead.
+			log.Error("database disk is full", zap.Error(err))
+			s.setDiskFull(true)
+			return fmt.Errorf("transaction failed (attempt %d): %w: %w", attempt, ErrDiskFull, err)
+		} else if !strings.Contains(err.Error(), "database is locked") {
+			// return immediately if the error is not a busy error
 			break
 		}
 		// exponential backoff
@@ -112,6 +174,13 @@ func (s *Store) transaction(fn func(txn) error) error {
 
 // Close closes the underlying database.
 func (s *Store) Close() error {
+	s.diskMu.Lock()
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	s.diskMu.Unlock()
 	return s.db.Close()
 }
 
@@ -203,6 +272,8 @@ func OpenDatabase(fp string, log *zap.Logger) (*Store, error) {
 	store := &Store{
 		db:  db,
 		log: log,
+
+		closed: make(chan struct{}),
 	}
 	if err := store.init(); err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
@@ -1,13 +1,18 @@
 package sqlite
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/mattn/go-sqlite3"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/storage"
 	"go.uber.org/zap/zaptest"
 	"lukechampine.com/frand"
@@ -198,3 +203,240 @@ func TestClearLockedSectors(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestTransactionMetrics(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	initial := db.TransactionMetrics()
+	if len(initial.Durations) != len(txnDurationBuckets) {
+		t.Fatalf("expected %v duration buckets, got %v", len(txnDurationBuckets), len(initial.Durations))
+	}
+
+	// successful transactions should only increment the count
+	for i := 0; i < 5; i++ {
+		if err := db.transaction(func(tx txn) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := db.TransactionMetrics()
+	if m.Count != initial.Count+5 {
+		t.Fatalf("expected %v transactions, got %v", initial.Count+5, m.Count)
+	} else if m.Rollbacks != initial.Rollbacks {
+		t.Fatalf("expected %v rollbacks, got %v", initial.Rollbacks, m.Rollbacks)
+	} else if m.Retries != initial.Retries {
+		t.Fatalf("expected %v retries, got %v", initial.Retries, m.Retries)
+	} else if m.TotalDuration < initial.TotalDuration {
+		t.Fatalf("expected total duration to increase, got %v", m.TotalDuration)
+	}
+
+	// the buckets should be cumulative
+	last := m.Durations[len(m.Durations)-1]
+	if last.Count > m.Count {
+		t.Fatalf("expected at most %v transactions in the last bucket, got %v", m.Count, last.Count)
+	}
+	for i := 1; i < len(m.Durations); i++ {
+		if m.Durations[i].Count < m.Durations[i-1].Count {
+			t.Fatalf("expected bucket %v to be cumulative", i)
+		}
+	}
+
+	// failed transactions should increment the rollback count
+	if err := db.transaction(func(tx txn) error { return errors.New("foo") }); err == nil {
+		t.Fatal("expected error")
+	}
+
+	m2 := db.TransactionMetrics()
+	if m2.Count != m.Count+1 {
+		t.Fatalf("expected %v transactions, got %v", m.Count+1, m2.Count)
+	} else if m2.Rollbacks != m.Rollbacks+1 {
+		t.Fatalf("expected %v rollbacks, got %v", m.Rollbacks+1, m2.Rollbacks)
+	} else if m2.Retries != m.Retries {
+		t.Fatalf("expected %v retries, got %v", m.Retries, m2.Retries)
+	}
+}
+
+func TestTransactionDiskFull(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	changes := make(chan bool, 2)
+	db.OnDiskFull(func(full bool) { changes <- full })
+
+	// simulate the disk filling up during a write
+	var attempts int
+	err = db.transaction(func(tx txn) error {
+		attempts++
+		return sqlite3.Error{Code: sqlite3.ErrFull}
+	})
+	if !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("expected ErrDiskFull, got %v", err)
+	} else if attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %v", attempts)
+	} else if !db.DiskFull() {
+		t.Fatal("expected disk to be full")
+	}
+
+	select {
+	case full := <-changes:
+		if !full {
+			t.Fatal("expected disk full notification")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for disk full notification")
+	}
+
+	// the probe should detect that writes succeed again
+	select {
+	case full := <-changes:
+		if full {
+			t.Fatal("expected recovery notification")
+		}
+	case <-time.After(10 * diskFullProbeInterval):
+		t.Fatal("timed out waiting for recovery notification")
+	}
+	if db.DiskFull() {
+		t.Fatal("expected disk to have space")
+	}
+}
+
+// tableRowCounts returns the number of rows in every table of the database.
+func tableRowCounts(t *testing.T, s *Store) map[string]int {
+	t.Helper()
+
+	rows, err := s.db.Query(`SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for _, table := range tables {
+		var n int
+		if err := s.db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %q`, table)).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		counts[table] = n
+	}
+	return counts
+}
+
+func TestBackup(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	db, err := OpenDatabase(filepath.Join(dir, "hostd.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	addFee := func() {
+		err := db.AddFeeExpenditure(contracts.FeeExpenditure{
+			ContractID:    frand.Entropy256(),
+			Category:      contracts.FeeCategoryProof,
+			TransactionID: frand.Entropy256(),
+			Amount:        types.Siacoins(1),
+			Timestamp:     time.Now(),
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+	for i := 0; i < 5000; i++ {
+		addFee()
+	}
+
+	// back up the database while it is being written to
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			addFee()
+		}
+	}()
+
+	var steps, lastRemaining int
+	err = db.Backup(context.Background(), filepath.Join(dir, "live.db"), func(remaining, total int) {
+		steps++
+		lastRemaining = remaining
+	})
+	cancel()
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	} else if steps == 0 {
+		t.Fatal("expected progress to be reported")
+	} else if lastRemaining != 0 {
+		t.Fatalf("expected 0 pages remaining, got %v", lastRemaining)
+	}
+
+	live, err := OpenDatabase(filepath.Join(dir, "live.db"), log.Named("live"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	var integrity string
+	if err := live.db.QueryRow(`PRAGMA integrity_check`).Scan(&integrity); err != nil {
+		t.Fatal(err)
+	} else if integrity != "ok" {
+		t.Fatalf("expected backup to pass the integrity check, got %q", integrity)
+	}
+	liveCounts := tableRowCounts(t, live)
+	if liveCounts["contract_fee_expenditures"] < 5000 {
+		t.Fatalf("expected at least 5000 fee expenditures, got %v", liveCounts["contract_fee_expenditures"])
+	}
+
+	// a backup of an idle database should match exactly
+	if err := db.Backup(context.Background(), filepath.Join(dir, "idle.db"), nil); err != nil {
+		t.Fatal(err)
+	}
+	idle, err := OpenDatabase(filepath.Join(dir, "idle.db"), log.Named("idle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	expected, actual := tableRowCounts(t, db), tableRowCounts(t, idle)
+	if len(expected) != len(actual) {
+		t.Fatalf("expected %v tables, got %v", len(expected), len(actual))
+	}
+	for table, n := range expected {
+		if actual[table] != n {
+			t.Fatalf("expected %v rows in %v, got %v", n, table, actual[table])
+		}
+	}
+
+	// the destination must not already exist
+	if err := db.Backup(context.Background(), filepath.Join(dir, "idle.db"), nil); err == nil {
+		t.Fatal("expected backup to an existing path to fail")
+	}
+
+	// a cancelled backup should not leave a partial copy
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := db.Backup(ctx, filepath.Join(dir, "cancelled.db"), nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	} else if _, err := os.Stat(filepath.Join(dir, "cancelled.db")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected partial backup to be removed")
+	}
+}
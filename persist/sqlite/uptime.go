@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"go.sia.tech/hostd/host/metrics"
+)
+
+// StartUptimeInterval records the start of a new interval the host is online
+// and returns its ID.
+func (s *Store) StartUptimeInterval(timestamp time.Time) (id int64, err error) {
+	err = s.transaction(func(tx txn) error {
+		return tx.QueryRow(`INSERT INTO host_uptime (start_timestamp, last_heartbeat) VALUES ($1, $1) RETURNING id`, sqlTime(timestamp)).Scan(&id)
+	})
+	return
+}
+
+// RecordHeartbeat extends an uptime interval to the timestamp.
+func (s *Store) RecordHeartbeat(id int64, timestamp time.Time) error {
+	return s.transaction(func(tx txn) error {
+		res, err := tx.Exec(`UPDATE host_uptime SET last_heartbeat=$1 WHERE id=$2`, sqlTime(timestamp), id)
+		if err != nil {
+			return err
+		} else if n, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		} else if n != 1 {
+			return fmt.Errorf("uptime interval %d not found", id)
+		}
+		return nil
+	})
+}
+
+// UptimeIntervals returns the intervals the host was online that ended after
+// the timestamp, ordered by start time. The last interval that ended before
+// the timestamp is also returned so that downtime at the start of the period
+// can be distinguished from the host not being tracked yet.
+func (s *Store) UptimeIntervals(since time.Time) (intervals []metrics.UptimeInterval, err error) {
+	const query = `SELECT start_timestamp, last_heartbeat FROM host_uptime
+WHERE last_heartbeat >= $1 OR id=(SELECT id FROM host_uptime WHERE last_heartbeat < $1 ORDER BY last_heartbeat DESC LIMIT 1)
+ORDER BY start_timestamp ASC`
+	rows, err := s.query(query, sqlTime(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query uptime intervals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var interval metrics.UptimeInterval
+		if err := rows.Scan((*sqlTime)(&interval.Start), (*sqlTime)(&interval.End)); err != nil {
+			return nil, fmt.Errorf("failed to scan uptime interval: %w", err)
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals, rows.Err()
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/hostd/host/metrics"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestUptimeStats(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now().Truncate(time.Second)
+	seed := func(start, end time.Time) {
+		t.Helper()
+		id, err := db.StartUptimeInterval(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// record heartbeats every minute until the end of the interval
+		for ts := start.Add(time.Minute); ts.Before(end); ts = ts.Add(time.Minute) {
+			if err := db.RecordHeartbeat(id, ts); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := db.RecordHeartbeat(id, end); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// online for 2 hours, offline for 2 hours, online for 5 hours with a
+	// restart shorter than the heartbeat tolerance, then offline for the
+	// last hour
+	seed(now.Add(-10*time.Hour), now.Add(-8*time.Hour))
+	seed(now.Add(-6*time.Hour), now.Add(-3*time.Hour))
+	seed(now.Add(-3*time.Hour+30*time.Second), now.Add(-time.Hour))
+
+	stats := func(window time.Duration) metrics.UptimeStats {
+		t.Helper()
+		start := now.Add(-window)
+		intervals, err := db.UptimeIntervals(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return metrics.ComputeUptime(intervals, start, now, 2*time.Minute)
+	}
+
+	tests := []struct {
+		window   time.Duration
+		start    time.Time
+		uptime   float64
+		downtime time.Duration
+		events   int
+	}{
+		{9 * time.Hour, now.Add(-9 * time.Hour), 100 * 6.0 / 9, 3 * time.Hour, 2},
+		// the period before the first interval is not tracked
+		{24 * time.Hour, now.Add(-10 * time.Hour), 70, 3 * time.Hour, 2},
+		// the host has been offline for the entire window
+		{30 * time.Minute, now.Add(-30 * time.Minute), 0, 30 * time.Minute, 1},
+	}
+	for _, test := range tests {
+		s := stats(test.window)
+		if !s.Start.Equal(test.start) {
+			t.Fatalf("window %v: expected start %v, got %v", test.window, test.start, s.Start)
+		} else if math.Abs(s.Uptime-test.uptime) > 1e-9 {
+			t.Fatalf("window %v: expected uptime %v, got %v", test.window, test.uptime, s.Uptime)
+		} else if s.Downtime != test.downtime {
+			t.Fatalf("window %v: expected downtime %v, got %v", test.window, test.downtime, s.Downtime)
+		} else if len(s.Events) != test.events {
+			t.Fatalf("window %v: expected %v downtime events, got %v", test.window, test.events, len(s.Events))
+		}
+	}
+
+	// the downtime events should match the gaps
+	s := stats(9 * time.Hour)
+	if !s.Events[0].Start.Equal(now.Add(-8*time.Hour)) || !s.Events[0].End.Equal(now.Add(-6*time.Hour)) {
+		t.Fatalf("unexpected first downtime event %+v", s.Events[0])
+	} else if !s.Events[1].Start.Equal(now.Add(-time.Hour)) || !s.Events[1].End.Equal(now) {
+		t.Fatalf("unexpected second downtime event %+v", s.Events[1])
+	}
+}
@@ -6,14 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.uber.org/zap"
 )
 
-func (s *Store) migrateSector(volumeID int64, minIndex uint64, marker int64, migrateFn storage.MigrateFunc, log *zap.Logger) (int64, bool, error) {
+func (s *Store) migrateSector(volumeID int64, minIndex uint64, marker int64, recordProgress bool, migrateFn storage.MigrateFunc, log *zap.Logger) (int64, bool, error) {
 	start := time.Now()
 
 	var locationLocks []int64
@@ -110,6 +112,12 @@ func (s *Store) migrateSector(volumeID int64, minIndex uint64, marker int64, mig
 		if err := incrementVolumeUsage(tx, newVolumeID, 1); err != nil {
 			return fmt.Errorf("failed to update new volume metadata: %w", err)
 		}
+
+		if recordProgress {
+			if err := setMigrationProgress(tx, volumeID, minIndex, oldLoc.Index); err != nil {
+				return fmt.Errorf("failed to update migration progress: %w", err)
+			}
+		}
 		return nil
 	})
 	if err != nil {
@@ -214,7 +222,7 @@ func (s *Store) StorageUsage() (usedSectors, totalSectors uint64, err error) {
 
 // Volumes returns a list of all volumes.
 func (s *Store) Volumes() ([]storage.Volume, error) {
-	const query = `SELECT v.id, v.disk_path, v.read_only, v.available, v.total_sectors, v.used_sectors
+	const query = `SELECT v.id, v.disk_path, v.read_only, v.available, v.total_sectors, v.used_sectors, v.encryption_salt IS NOT NULL, v.replica_group, v.bytes_read, v.bytes_written
 FROM storage_volumes v
 ORDER BY v.id ASC`
 	rows, err := s.query(query)
@@ -236,7 +244,7 @@ ORDER BY v.id ASC`
 
 // Volume returns a volume by its ID.
 func (s *Store) Volume(id int64) (storage.Volume, error) {
-	const query = `SELECT v.id, v.disk_path, v.read_only, v.available, v.total_sectors, v.used_sectors
+	const query = `SELECT v.id, v.disk_path, v.read_only, v.available, v.total_sectors, v.used_sectors, v.encryption_salt IS NOT NULL, v.replica_group, v.bytes_read, v.bytes_written
 FROM storage_volumes v
 WHERE v.id=$1`
 	row := s.queryRow(query, id)
@@ -249,6 +257,125 @@ WHERE v.id=$1`
 	return vol, nil
 }
 
+// IncrementVolumeIO adds the number of bytes read from and written to each
+// volume to the volume's cumulative counters and to the counts for the minute
+// containing timestamp. Counts for volumes that have been removed are ignored.
+func (s *Store) IncrementVolumeIO(io map[int64]storage.VolumeIO, timestamp time.Time) error {
+	periodStart := sqlTime(timestamp.Truncate(time.Minute))
+	return s.transaction(func(tx txn) error {
+		updateStmt, err := tx.Prepare(`UPDATE storage_volumes SET bytes_read=bytes_read+$1, bytes_written=bytes_written+$2 WHERE id=$3`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare update statement: %w", err)
+		}
+		defer updateStmt.Close()
+
+		insertStmt, err := tx.Prepare(`INSERT INTO volume_io_counts (volume_id, period_start, bytes_read, bytes_written) SELECT id, $1, $2, $3 FROM storage_volumes WHERE id=$4
+ON CONFLICT (volume_id, period_start) DO UPDATE SET bytes_read=bytes_read+EXCLUDED.bytes_read, bytes_written=bytes_written+EXCLUDED.bytes_written`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare insert statement: %w", err)
+		}
+		defer insertStmt.Close()
+
+		for id, vio := range io {
+			if _, err := updateStmt.Exec(vio.BytesRead, vio.BytesWritten, id); err != nil {
+				return fmt.Errorf("failed to update volume %v: %w", id, err)
+			} else if _, err := insertStmt.Exec(periodStart, vio.BytesRead, vio.BytesWritten, id); err != nil {
+				return fmt.Errorf("failed to record I/O for volume %v: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// PruneVolumeIO removes per-minute volume I/O counts for periods that started
+// before the given time.
+func (s *Store) PruneVolumeIO(before time.Time) error {
+	_, err := s.exec(`DELETE FROM volume_io_counts WHERE period_start < $1`, sqlTime(before))
+	return err
+}
+
+// VolumeIO returns the number of bytes read from and written to a volume
+// since the given time.
+func (s *Store) VolumeIO(volumeID int64, since time.Time) (io storage.VolumeIO, err error) {
+	const query = `SELECT COALESCE(SUM(bytes_read), 0), COALESCE(SUM(bytes_written), 0) FROM volume_io_counts WHERE volume_id=$1 AND period_start >= $2`
+	err = s.queryRow(query, volumeID, sqlTime(since.Truncate(time.Minute))).Scan(&io.BytesRead, &io.BytesWritten)
+	return
+}
+
+// VolumeSectors returns up to limit sectors stored in the volume, ordered by
+// their index within the volume.
+func (s *Store) VolumeSectors(volumeID int64, limit, offset int) (sectors []storage.VolumeSector, err error) {
+	// the unique (volume_id, volume_index) constraint indexes the query
+	const query = `SELECT vs.volume_index, ss.sector_root, (SELECT COUNT(DISTINCT csr.contract_id) FROM contract_sector_roots csr WHERE csr.sector_id=vs.sector_id)
+FROM volume_sectors vs
+INNER JOIN stored_sectors ss ON (ss.id=vs.sector_id)
+WHERE vs.volume_id=$1
+ORDER BY vs.volume_index ASC
+LIMIT $2 OFFSET $3;`
+
+	err = s.transaction(func(tx txn) error {
+		sectors, err = queryVolumeSectors(tx, volumeID, query, volumeID, limit, offset)
+		return err
+	})
+	return
+}
+
+// VolumeSectorsFrom returns up to limit sectors stored in the volume at or
+// after the start index, ordered by their index within the volume. Unlike
+// VolumeSectors, the cost of a page does not grow with its position in the
+// volume.
+func (s *Store) VolumeSectorsFrom(volumeID int64, start uint64, limit int) (sectors []storage.VolumeSector, err error) {
+	// the unique (volume_id, volume_index) constraint indexes the query
+	const query = `SELECT vs.volume_index, ss.sector_root, (SELECT COUNT(DISTINCT csr.contract_id) FROM contract_sector_roots csr WHERE csr.sector_id=vs.sector_id)
+FROM volume_sectors vs
+INNER JOIN stored_sectors ss ON (ss.id=vs.sector_id)
+WHERE vs.volume_id=$1 AND vs.volume_index >= $2
+ORDER BY vs.volume_index ASC
+LIMIT $3;`
+
+	err = s.transaction(func(tx txn) error {
+		sectors, err = queryVolumeSectors(tx, volumeID, query, volumeID, start, limit)
+		return err
+	})
+	return
+}
+
+// queryVolumeSectors checks that the volume exists and scans the sectors
+// returned by the query.
+func queryVolumeSectors(tx txn, volumeID int64, query string, args ...any) (sectors []storage.VolumeSector, err error) {
+	var exists bool
+	if err := tx.QueryRow(`SELECT true FROM storage_volumes WHERE id=$1`, volumeID).Scan(&exists); errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrVolumeNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to check volume: %w", err)
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sectors: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sector storage.VolumeSector
+		if err := rows.Scan(&sector.Index, (*sqlHash256)(&sector.Root), &sector.Contracts); err != nil {
+			return nil, fmt.Errorf("failed to scan sector: %w", err)
+		}
+		sectors = append(sectors, sector)
+	}
+	return sectors, rows.Err()
+}
+
+// VolumeSectorCounts counts the sector slots and used sectors of a volume from
+// its sector metadata. Replicas stored in the volume are counted as used.
+func (s *Store) VolumeSectorCounts(volumeID int64) (total, used uint64, err error) {
+	const query = `SELECT COUNT(vs.id), COUNT(vs.sector_id) + COUNT(sr.id)
+FROM volume_sectors vs
+LEFT JOIN sector_replicas sr ON (sr.volume_sector_id=vs.id)
+WHERE vs.volume_id=$1`
+	err = s.queryRow(query, volumeID).Scan(&total, &used)
+	return
+}
+
 // StoreSector calls fn with an empty location in a writable volume. If
 // the sector root already exists, fn is called with the existing
 // location and exists is true. Unless exists is true, The sector must
@@ -256,9 +383,19 @@ WHERE v.id=$1`
 // rolled back. If no space is available, ErrNotEnoughStorage is
 // returned. The location is locked until release is called.
 //
+// Concurrent calls are safe: the location is selected and claimed in a
+// single transaction, so no two sectors are given the same location.
+//
 // The sector should be referenced by either a contract or temp store
 // before release is called to prevent it from being pruned
 func (s *Store) StoreSector(root types.Hash256, fn func(loc storage.SectorLocation, exists bool) error) (func() error, error) {
+	return s.StoreSectorExcluding(root, nil, fn)
+}
+
+// StoreSectorExcluding is the same as StoreSector, but new sectors will not
+// be stored in any of the excluded volumes. If there is no space available
+// outside of the excluded volumes, ErrNotEnoughStorage is returned.
+func (s *Store) StoreSectorExcluding(root types.Hash256, exclude []int64, fn func(loc storage.SectorLocation, exists bool) error) (func() error, error) {
 	var sectorLockID int64
 	var locationLocks []int64
 	var location storage.SectorLocation
@@ -281,7 +418,16 @@ func (s *Store) StoreSector(root types.Hash256, fn func(loc storage.SectorLocati
 		location, err = sectorLocation(tx, sectorID, root)
 		exists = err == nil
 		if errors.Is(err, storage.ErrSectorNotFound) {
-			location, err = emptyLocation(tx)
+			var strategy string
+			strategy, err = sectorAllocation(tx)
+			if err != nil {
+				return fmt.Errorf("failed to get sector allocation strategy: %w", err)
+			}
+			if strategy == settings.SectorAllocationSpread {
+				location, err = spreadEmptyLocation(tx, exclude, atomic.LoadInt64(&s.lastAllocatedVolume))
+			} else {
+				location, err = emptyLocation(tx, exclude)
+			}
 			if err != nil {
 				return fmt.Errorf("failed to get empty location: %w", err)
 			}
@@ -299,13 +445,16 @@ func (s *Store) StoreSector(root types.Hash256, fn func(loc storage.SectorLocati
 		if exists {
 			return nil
 		}
-		res, err := tx.Exec(`UPDATE volume_sectors SET sector_id=$1 WHERE id=$2`, sectorID, location.ID)
+		// SQLite serializes write transactions, so the location cannot be
+		// claimed between selecting and claiming it. The sector_id check
+		// ensures an occupied location is never overwritten regardless.
+		res, err := tx.Exec(`UPDATE volume_sectors SET sector_id=$1 WHERE id=$2 AND sector_id IS NULL`, sectorID, location.ID)
 		if err != nil {
 			return fmt.Errorf("failed to commit sector location: %w", err)
 		} else if rows, err := res.RowsAffected(); err != nil {
 			return fmt.Errorf("failed to check rows affected: %w", err)
 		} else if rows == 0 {
-			return storage.ErrSectorNotFound
+			return fmt.Errorf("sector location %v:%v is no longer available", location.Volume, location.Index)
 		}
 
 		// increment the volume usage
@@ -316,6 +465,8 @@ func (s *Store) StoreSector(root types.Hash256, fn func(loc storage.SectorLocati
 	})
 	if err != nil {
 		return nil, err
+	} else if !exists {
+		atomic.StoreInt64(&s.lastAllocatedVolume, location.Volume)
 	}
 	log = log.With(zap.Int64("volume", location.Volume), zap.Uint64("index", location.Index))
 	log.Debug("stored sector")
@@ -332,6 +483,12 @@ func (s *Store) StoreSector(root types.Hash256, fn func(loc storage.SectorLocati
 
 	// call fn with the location
 	if err := fn(location, exists); err != nil {
+		if !exists {
+			// roll back the new location so the sector can be stored again
+			if err := s.transaction(func(tx txn) error { return clearSectorLocation(tx, location) }); err != nil {
+				log.Error("failed to roll back sector location", zap.Error(err))
+			}
+		}
 		unlock()
 		return nil, fmt.Errorf("failed to store sector: %w", err)
 	}
@@ -352,18 +509,50 @@ func (s *Store) MigrateSectors(ctx context.Context, volumeID int64, startIndex u
 	// marker is used to skip sectors that tried to migrate but failed.
 	// when removing a volume, marker is -1 to also migrate the first sector
 	marker := int64(startIndex) - 1
+
+	// resume a previously interrupted migration of the same range
+	lastIndex, err := migrationProgress(&dbTxn{s}, volumeID, startIndex)
+	if err != nil {
+		err = fmt.Errorf("failed to get migration progress: %w", err)
+		return
+	} else if lastIndex > marker {
+		// sectors may have been written to the migrated range after the
+		// migration was interrupted. Resuming would skip them, so the
+		// progress is discarded and the range is migrated again.
+		var stale bool
+		err = s.queryRow(`SELECT EXISTS (SELECT 1 FROM volume_sectors WHERE volume_id=$1 AND volume_index BETWEEN $2 AND $3 AND sector_id IS NOT NULL)`, volumeID, startIndex, lastIndex).Scan(&stale)
+		if err != nil {
+			err = fmt.Errorf("failed to check migration progress: %w", err)
+			return
+		} else if stale {
+			log.Info("discarding stale migration progress", zap.Int64("lastIndex", lastIndex))
+			if _, err = s.exec(`DELETE FROM volume_migrations WHERE volume_id=$1`, volumeID); err != nil {
+				err = fmt.Errorf("failed to clear migration progress: %w", err)
+				return
+			}
+		} else {
+			log.Info("resuming migration", zap.Int64("lastIndex", lastIndex))
+			marker = lastIndex
+		}
+	}
+
 	for i := 0; ; i++ {
 		if ctx.Err() != nil {
 			err = ctx.Err()
 			return
 		}
 
+		// progress is only recorded while every sector has been migrated
+		// successfully so that failed sectors are retried on resume.
 		var successful bool
-		marker, successful, err = s.migrateSector(volumeID, startIndex, marker, migrateFn, log)
+		marker, successful, err = s.migrateSector(volumeID, startIndex, marker, failed == 0, migrateFn, log)
 		if err != nil {
 			err = fmt.Errorf("failed to migrate sector: %w", err)
 			return
 		} else if marker == math.MaxInt64 {
+			if _, err = s.exec(`DELETE FROM volume_migrations WHERE volume_id=$1`, volumeID); err != nil {
+				err = fmt.Errorf("failed to clear migration progress: %w", err)
+			}
 			return
 		}
 
@@ -391,6 +580,15 @@ func (s *Store) AddVolume(localPath string, readOnly bool) (volumeID int64, err
 // true, the volume is removed regardless of whether it is empty.
 func (s *Store) RemoveVolume(id int64, force bool) error {
 	log := s.log.Named("RemoveVolume").With(zap.Int64("volume", id), zap.Bool("force", force))
+	// replicas stored in the volume are dropped, the primary copies are not
+	// affected
+	err := s.transaction(func(tx txn) error {
+		return dropVolumeReplicas(tx, id, 0)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drop volume replicas: %w", err)
+	}
+
 	// remove the volume sectors in batches to avoid holding a transaction lock
 	// for too long
 	for i := 0; ; i++ {
@@ -465,7 +663,10 @@ func (s *Store) ShrinkVolume(id int64, maxSectors uint64) error {
 		} else if maxSectors > totalSectors {
 			panic(fmt.Errorf("maxSectors must be less than totalSectors: %v < %v", maxSectors, totalSectors))
 		}
-		// delete the empty sectors
+		// drop any replicas in the shrink range and delete the empty sectors
+		if err := dropVolumeReplicas(tx, id, maxSectors); err != nil {
+			return fmt.Errorf("failed to drop volume replicas: %w", err)
+		}
 		_, err = tx.Exec(`DELETE FROM volume_sectors WHERE volume_id=$1 AND volume_index >= $2;`, id, maxSectors)
 		if err != nil {
 			return fmt.Errorf("failed to shrink volume: %w", err)
@@ -495,6 +696,261 @@ func (s *Store) SetAvailable(volumeID int64, available bool) error {
 	return err
 }
 
+// SetVolumeLocation sets the location of a volume's data.
+func (s *Store) SetVolumeLocation(volumeID int64, localPath string) error {
+	const query = `UPDATE storage_volumes SET disk_path=$1 WHERE id=$2;`
+	res, err := s.exec(query, localPath, volumeID)
+	if err != nil {
+		return err
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	} else if n != 1 {
+		return storage.ErrVolumeNotFound
+	}
+	return nil
+}
+
+// SetVolumeEncryption marks a volume as encrypted and stores the metadata
+// needed to derive its key.
+func (s *Store) SetVolumeEncryption(volumeID int64, enc storage.VolumeEncryption) error {
+	const query = `UPDATE storage_volumes SET encryption_salt=$1, encryption_key_check=$2 WHERE id=$3;`
+	res, err := s.exec(query, sqlHash256(enc.Salt), sqlHash256(enc.KeyCheck), volumeID)
+	if err != nil {
+		return err
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	} else if n != 1 {
+		return storage.ErrVolumeNotFound
+	}
+	return nil
+}
+
+// VolumeEncryption returns the encryption metadata of a volume. If the volume
+// is not encrypted, storage.ErrVolumeNotEncrypted is returned.
+func (s *Store) VolumeEncryption(volumeID int64) (enc storage.VolumeEncryption, err error) {
+	const query = `SELECT encryption_salt, encryption_key_check FROM storage_volumes WHERE id=$1;`
+	var salt, check []byte
+	err = s.queryRow(query, volumeID).Scan(&salt, &check)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.VolumeEncryption{}, storage.ErrVolumeNotFound
+	} else if err != nil {
+		return storage.VolumeEncryption{}, fmt.Errorf("query failed: %w", err)
+	} else if salt == nil {
+		return storage.VolumeEncryption{}, storage.ErrVolumeNotEncrypted
+	} else if len(salt) != len(enc.Salt) || len(check) != len(enc.KeyCheck) {
+		return storage.VolumeEncryption{}, fmt.Errorf("invalid encryption metadata for volume %d", volumeID)
+	}
+	copy(enc.Salt[:], salt)
+	copy(enc.KeyCheck[:], check)
+	return enc, nil
+}
+
+// SetReplicaGroup sets the replica group of a volume. Sectors written to a
+// volume in a replica group are replicated to another volume in the same
+// group. An empty group disables replication.
+func (s *Store) SetReplicaGroup(volumeID int64, group string) error {
+	res, err := s.exec(`UPDATE storage_volumes SET replica_group=$1 WHERE id=$2;`, group, volumeID)
+	if err != nil {
+		return fmt.Errorf("failed to update volume: %w", err)
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n == 0 {
+		return storage.ErrVolumeNotFound
+	}
+	return nil
+}
+
+// StoreSectorReplica calls fn with an empty location in another volume of the
+// same replica group as the sector's primary location. The replica must be
+// written to disk within fn. If fn returns an error, the replica's metadata is
+// rolled back. fn is not called if the sector is already replicated or its
+// volume is not in a replica group. If no space is available,
+// ErrNotEnoughStorage is returned.
+func (s *Store) StoreSectorReplica(root types.Hash256, fn func(loc storage.SectorLocation) error) error {
+	var replicaID int64
+	var locationLocks []int64
+	var location storage.SectorLocation
+
+	err := s.transaction(func(tx txn) error {
+		sectorID, err := sectorDBID(tx, root)
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrSectorNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get sector id: %w", err)
+		}
+
+		primary, err := sectorLocation(tx, sectorID, root)
+		if err != nil {
+			return fmt.Errorf("failed to get sector location: %w", err)
+		}
+
+		var group string
+		var replicated bool
+		const query = `SELECT sv.replica_group, EXISTS (SELECT 1 FROM sector_replicas WHERE sector_id=$1) FROM storage_volumes sv WHERE sv.id=$2`
+		if err := tx.QueryRow(query, sectorID, primary.Volume).Scan(&group, &replicated); err != nil {
+			return fmt.Errorf("failed to get replica group: %w", err)
+		} else if group == "" || replicated {
+			return nil
+		}
+
+		location, err = emptyReplicaLocation(tx, group, primary.Volume)
+		if err != nil {
+			return fmt.Errorf("failed to get empty location: %w", err)
+		}
+		location.Root = root
+
+		locationLocks, err = lockLocations(tx, []storage.SectorLocation{location})
+		if err != nil {
+			return fmt.Errorf("failed to lock replica location: %w", err)
+		}
+
+		err = tx.QueryRow(`INSERT INTO sector_replicas (sector_id, volume_sector_id) VALUES ($1, $2) RETURNING id`, sectorID, location.ID).Scan(&replicaID)
+		if err != nil {
+			return fmt.Errorf("failed to add replica: %w", err)
+		} else if err := incrementReplicaUsage(tx, location.Volume, 1); err != nil {
+			return fmt.Errorf("failed to update volume metadata: %w", err)
+		}
+		return nil
+	})
+	if err != nil || replicaID == 0 {
+		return err
+	}
+	defer func() {
+		if err := s.transaction(func(tx txn) error { return unlockLocations(tx, locationLocks) }); err != nil {
+			s.log.Error("failed to unlock replica location", zap.Stringer("root", root), zap.Error(err))
+		}
+	}()
+
+	if err := fn(location); err != nil {
+		// roll back the replica so the location can be reused
+		rollbackErr := s.transaction(func(tx txn) error {
+			if _, err := tx.Exec(`DELETE FROM sector_replicas WHERE id=$1`, replicaID); err != nil {
+				return err
+			}
+			return incrementReplicaUsage(tx, location.Volume, -1)
+		})
+		if rollbackErr != nil {
+			s.log.Error("failed to roll back sector replica", zap.Stringer("root", root), zap.Error(rollbackErr))
+		}
+		return fmt.Errorf("failed to store replica: %w", err)
+	}
+	return nil
+}
+
+// SectorReplica returns the location of a sector's replica. If the sector
+// does not have a replica, ErrSectorNotFound is returned.
+func (s *Store) SectorReplica(root types.Hash256) (loc storage.SectorLocation, err error) {
+	const query = `SELECT vs.id, vs.volume_id, vs.volume_index
+FROM sector_replicas sr
+INNER JOIN stored_sectors ss ON (ss.id=sr.sector_id)
+INNER JOIN volume_sectors vs ON (vs.id=sr.volume_sector_id)
+WHERE ss.sector_root=$1`
+	err = s.queryRow(query, sqlHash256(root)).Scan(&loc.ID, &loc.Volume, &loc.Index)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.SectorLocation{}, storage.ErrSectorNotFound
+	}
+	loc.Root = root
+	return
+}
+
+// ZeroPendingLocations returns up to limit locations, ordered by ID and
+// starting after the given ID, that were freed and must be zeroed before they
+// can be reused.
+func (s *Store) ZeroPendingLocations(afterID int64, limit int) (locations []storage.SectorLocation, err error) {
+	const query = `SELECT id, volume_id, volume_index FROM volume_sectors WHERE zero_pending=true AND id > $1 ORDER BY id ASC LIMIT $2;`
+	rows, err := s.query(query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var loc storage.SectorLocation
+		if err := rows.Scan(&loc.ID, &loc.Volume, &loc.Index); err != nil {
+			return nil, fmt.Errorf("failed to scan location: %w", err)
+		}
+		locations = append(locations, loc)
+	}
+	return locations, rows.Err()
+}
+
+// ClearZeroPending marks the locations as zeroed so they can be reused.
+func (s *Store) ClearZeroPending(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `UPDATE volume_sectors SET zero_pending=false WHERE id IN (` + queryPlaceHolders(len(ids)) + `);`
+	_, err := s.exec(query, queryArgs(ids)...)
+	return err
+}
+
+// emptyReplicaLocation returns an empty location in a volume of the replica
+// group other than the excluded volume.
+func emptyReplicaLocation(tx txn, group string, exclude int64) (loc storage.SectorLocation, err error) {
+	const query = `SELECT vs.id, vs.volume_id, vs.volume_index
+	FROM volume_sectors vs INDEXED BY volume_sectors_sector_writes_volume_id_sector_id_volume_index_compound
+	LEFT JOIN locked_volume_sectors lvs ON (lvs.volume_sector_id=vs.id)
+	LEFT JOIN sector_replicas sr ON (sr.volume_sector_id=vs.id)
+	INNER JOIN storage_volumes sv ON (sv.id=vs.volume_id)
+	WHERE vs.sector_id IS NULL AND vs.zero_pending=false AND lvs.volume_sector_id IS NULL AND sr.volume_sector_id IS NULL AND sv.available=true AND sv.read_only=false AND sv.replica_group=$1 AND vs.volume_id <> $2
+	ORDER BY vs.sector_writes ASC
+	LIMIT 1;`
+	err = tx.QueryRow(query, group, exclude).Scan(&loc.ID, &loc.Volume, &loc.Index)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = storage.ErrNotEnoughStorage
+		return
+	} else if err != nil {
+		return
+	}
+	_, err = tx.Exec(`UPDATE volume_sectors SET sector_writes=sector_writes+1 WHERE id=$1`, loc.ID)
+	return
+}
+
+// incrementReplicaUsage updates the used sectors of a volume when a replica is
+// added or removed. Unlike incrementVolumeUsage, replicas are not counted as
+// physical sectors.
+func incrementReplicaUsage(tx txn, volumeID int64, delta int) error {
+	var used int64
+	err := tx.QueryRow(`UPDATE storage_volumes SET used_sectors=used_sectors+$1 WHERE id=$2 RETURNING used_sectors;`, delta, volumeID).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("failed to update volume: %w", err)
+	} else if used < 0 {
+		panic("volume usage is negative") // developer error
+	}
+	return nil
+}
+
+// removeSectorReplica removes the replica of a sector, if any. If zeroPending
+// is true, the replica's location is not reused until it is zeroed.
+func removeSectorReplica(tx txn, sectorID int64, zeroPending bool) error {
+	var volumeSectorID int64
+	err := tx.QueryRow(`DELETE FROM sector_replicas WHERE sector_id=$1 RETURNING volume_sector_id`, sectorID).Scan(&volumeSectorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to remove replica: %w", err)
+	}
+	var volumeID int64
+	err = tx.QueryRow(`UPDATE volume_sectors SET zero_pending=$1 WHERE id=$2 RETURNING volume_id`, zeroPending, volumeSectorID).Scan(&volumeID)
+	if err != nil {
+		return fmt.Errorf("failed to update replica location: %w", err)
+	}
+	return incrementReplicaUsage(tx, volumeID, -1)
+}
+
+// dropVolumeReplicas removes the replicas stored in a volume at or above
+// minIndex. The primary copies of the sectors are not affected.
+func dropVolumeReplicas(tx txn, volumeID int64, minIndex uint64) error {
+	res, err := tx.Exec(`DELETE FROM sector_replicas WHERE volume_sector_id IN (SELECT id FROM volume_sectors WHERE volume_id=$1 AND volume_index >= $2)`, volumeID, minIndex)
+	if err != nil {
+		return fmt.Errorf("failed to remove replicas: %w", err)
+	} else if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if n > 0 {
+		return incrementReplicaUsage(tx, volumeID, -int(n))
+	}
+	return nil
+}
+
 // sectorDBID returns the ID of a sector root in the stored_sectors table.
 func sectorDBID(tx txn, root types.Hash256) (id int64, err error) {
 	err = tx.QueryRow(`SELECT id FROM stored_sectors WHERE sector_root=$1`, sqlHash256(root)).Scan(&id)
@@ -569,17 +1025,89 @@ WHERE v.sector_id=$1`
 	return
 }
 
-// emptyLocation returns an empty location in a writable volume. If there is no
-// space available, ErrNotEnoughStorage is returned.
-func emptyLocation(tx txn) (loc storage.SectorLocation, err error) {
-	const query = `SELECT vs.id, vs.volume_id, vs.volume_index 
+// clearSectorLocation removes a sector from a location that was assigned by
+// StoreSector and decrements the volume's usage.
+func clearSectorLocation(tx txn, loc storage.SectorLocation) error {
+	res, err := tx.Exec(`UPDATE volume_sectors SET sector_id=NULL WHERE id=$1 AND sector_id IS NOT NULL`, loc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to clear sector location: %w", err)
+	} else if rows, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if rows == 0 {
+		return nil
+	}
+	return incrementVolumeUsage(tx, loc.Volume, -1)
+}
+
+// emptyLocation returns an empty location in a writable volume that is not
+// excluded. If there is no space available, ErrNotEnoughStorage is returned.
+func emptyLocation(tx txn, exclude []int64) (loc storage.SectorLocation, err error) {
+	var excludeClause string
+	if len(exclude) > 0 {
+		excludeClause = ` AND vs.volume_id NOT IN (` + queryPlaceHolders(len(exclude)) + `)`
+	}
+	query := `SELECT vs.id, vs.volume_id, vs.volume_index 
 	FROM volume_sectors vs INDEXED BY volume_sectors_sector_writes_volume_id_sector_id_volume_index_compound
 	LEFT JOIN locked_volume_sectors lvs ON (lvs.volume_sector_id=vs.id)
+	LEFT JOIN sector_replicas sr ON (sr.volume_sector_id=vs.id)
 	INNER JOIN storage_volumes sv ON (sv.id=vs.volume_id)
-	WHERE vs.sector_id IS NULL AND lvs.volume_sector_id IS NULL AND sv.available=true AND sv.read_only=false
+	WHERE vs.sector_id IS NULL AND vs.zero_pending=false AND lvs.volume_sector_id IS NULL AND sr.volume_sector_id IS NULL AND sv.available=true AND sv.read_only=false` + excludeClause + `
 	ORDER BY vs.sector_writes ASC
 	LIMIT 1;`
-	err = tx.QueryRow(query).Scan(&loc.ID, &loc.Volume, &loc.Index)
+	err = tx.QueryRow(query, queryArgs(exclude)...).Scan(&loc.ID, &loc.Volume, &loc.Index)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = storage.ErrNotEnoughStorage
+		return
+	} else if err != nil {
+		return
+	}
+	_, err = tx.Exec(`UPDATE volume_sectors SET sector_writes=sector_writes+1 WHERE id=$1`, loc.ID)
+	return
+}
+
+// sectorAllocation returns the host's sector allocation strategy.
+func sectorAllocation(tx txn) (strategy string, err error) {
+	err = tx.QueryRow(`SELECT sector_allocation FROM host_settings`).Scan(&strategy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings.SectorAllocationFirstFit, nil
+	}
+	return
+}
+
+// spreadEmptyLocation returns an empty location in the next writable volume
+// with free space after the last volume a sector was stored in, wrapping
+// around to the first volume. If there is no space available,
+// ErrNotEnoughStorage is returned.
+func spreadEmptyLocation(tx txn, exclude []int64, lastVolume int64) (loc storage.SectorLocation, err error) {
+	var excludeClause string
+	if len(exclude) > 0 {
+		excludeClause = ` AND sv.id NOT IN (` + queryPlaceHolders(len(exclude)) + `)`
+	}
+	query := `SELECT sv.id FROM storage_volumes sv
+	WHERE sv.available=true AND sv.read_only=false` + excludeClause + ` AND EXISTS (
+		SELECT 1 FROM volume_sectors vs
+		LEFT JOIN locked_volume_sectors lvs ON (lvs.volume_sector_id=vs.id)
+		LEFT JOIN sector_replicas sr ON (sr.volume_sector_id=vs.id)
+		WHERE vs.volume_id=sv.id AND vs.sector_id IS NULL AND vs.zero_pending=false AND lvs.volume_sector_id IS NULL AND sr.volume_sector_id IS NULL
+	)
+	ORDER BY sv.id <= ? ASC, sv.id ASC
+	LIMIT 1;`
+	var volumeID int64
+	err = tx.QueryRow(query, append(queryArgs(exclude), lastVolume)...).Scan(&volumeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.SectorLocation{}, storage.ErrNotEnoughStorage
+	} else if err != nil {
+		return storage.SectorLocation{}, fmt.Errorf("failed to get volume: %w", err)
+	}
+
+	const locationQuery = `SELECT vs.id, vs.volume_id, vs.volume_index
+	FROM volume_sectors vs
+	LEFT JOIN locked_volume_sectors lvs ON (lvs.volume_sector_id=vs.id)
+	LEFT JOIN sector_replicas sr ON (sr.volume_sector_id=vs.id)
+	WHERE vs.volume_id=$1 AND vs.sector_id IS NULL AND vs.zero_pending=false AND lvs.volume_sector_id IS NULL AND sr.volume_sector_id IS NULL
+	ORDER BY vs.sector_writes ASC
+	LIMIT 1;`
+	err = tx.QueryRow(locationQuery, volumeID).Scan(&loc.ID, &loc.Volume, &loc.Index)
 	if errors.Is(err, sql.ErrNoRows) {
 		err = storage.ErrNotEnoughStorage
 		return
@@ -596,8 +1124,9 @@ func emptyLocationForMigration(tx txn, volumeID int64) (loc storage.SectorLocati
 	const query = `SELECT vs.id, vs.volume_id, vs.volume_index 
 	FROM volume_sectors vs INDEXED BY volume_sectors_sector_writes_volume_id_sector_id_volume_index_compound
 	LEFT JOIN locked_volume_sectors lvs ON (lvs.volume_sector_id=vs.id)
+	LEFT JOIN sector_replicas sr ON (sr.volume_sector_id=vs.id)
 	INNER JOIN storage_volumes sv ON (sv.id=vs.volume_id)
-	WHERE vs.sector_id IS NULL AND lvs.volume_sector_id IS NULL AND sv.available=true AND sv.read_only=false AND vs.volume_id <> $1
+	WHERE vs.sector_id IS NULL AND vs.zero_pending=false AND lvs.volume_sector_id IS NULL AND sr.volume_sector_id IS NULL AND sv.available=true AND sv.read_only=false AND vs.volume_id <> $1
 	ORDER BY vs.sector_writes ASC
 	LIMIT 1;`
 	err = tx.QueryRow(query, volumeID).Scan(&loc.ID, &loc.Volume, &loc.Index)
@@ -625,13 +1154,33 @@ func sectorForMigration(tx txn, volumeID int64, marker int64) (loc storage.Secto
 	return
 }
 
+// migrationProgress returns the index of the last sector migrated by an
+// interrupted migration of the volume starting at minIndex. If there is no
+// progress for the range, -1 is returned.
+func migrationProgress(tx txn, volumeID int64, minIndex uint64) (lastIndex int64, err error) {
+	err = tx.QueryRow(`SELECT last_index FROM volume_migrations WHERE volume_id=$1 AND min_index=$2`, volumeID, minIndex).Scan(&lastIndex)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, nil
+	}
+	return
+}
+
+// setMigrationProgress records that every occupied sector of the volume in the
+// range [minIndex, lastIndex] has been migrated.
+func setMigrationProgress(tx txn, volumeID int64, minIndex, lastIndex uint64) error {
+	const query = `INSERT INTO volume_migrations (volume_id, min_index, last_index, updated_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (volume_id) DO UPDATE SET min_index=EXCLUDED.min_index, last_index=EXCLUDED.last_index, updated_at=EXCLUDED.updated_at`
+	_, err := tx.Exec(query, volumeID, minIndex, lastIndex, sqlTime(time.Now()))
+	return err
+}
+
 // locationWithinVolume returns an empty location within the same volume as
 // the given volumeID. If there is no space in the volume, ErrNotEnoughStorage
 // is returned.
 func locationWithinVolume(tx txn, volumeID int64, maxIndex uint64) (loc storage.SectorLocation, err error) {
 	const query = `SELECT vs.id, vs.volume_id, vs.volume_index
 	FROM volume_sectors vs
-	WHERE vs.sector_id IS NULL AND vs.id NOT IN (SELECT volume_sector_id FROM locked_volume_sectors) 
+	WHERE vs.sector_id IS NULL AND vs.zero_pending=false AND vs.id NOT IN (SELECT volume_sector_id FROM locked_volume_sectors) AND vs.id NOT IN (SELECT volume_sector_id FROM sector_replicas)
 	AND vs.volume_id=$1 AND vs.volume_index<$2
 	LIMIT 1;`
 
@@ -643,6 +1192,6 @@ func locationWithinVolume(tx txn, volumeID int64, maxIndex uint64) (loc storage.
 }
 
 func scanVolume(s scanner) (volume storage.Volume, err error) {
-	err = s.Scan(&volume.ID, &volume.LocalPath, &volume.ReadOnly, &volume.Available, &volume.TotalSectors, &volume.UsedSectors)
+	err = s.Scan(&volume.ID, &volume.LocalPath, &volume.ReadOnly, &volume.Available, &volume.TotalSectors, &volume.UsedSectors, &volume.Encrypted, &volume.ReplicaGroup, &volume.BytesRead, &volume.BytesWritten)
 	return
 }
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.uber.org/zap/zaptest"
 	"lukechampine.com/frand"
@@ -64,6 +66,249 @@ func TestVolumeSetReadOnly(t *testing.T) {
 	}
 }
 
+func TestStoreSectorExcluding(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	first, err := addTestVolume(db, "first", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := addTestVolume(db, "second", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed store should roll back the sector's location
+	root := frand.Entropy256()
+	_, err = db.StoreSectorExcluding(root, []int64{second.ID}, func(loc storage.SectorLocation, exists bool) error {
+		if loc.Volume != first.ID {
+			t.Fatalf("expected volume %v, got %v", first.ID, loc.Volume)
+		}
+		return errors.New("write failed")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	} else if vol, err := db.Volume(first.ID); err != nil {
+		t.Fatal(err)
+	} else if vol.UsedSectors != 0 {
+		t.Fatalf("expected 0 used sectors, got %v", vol.UsedSectors)
+	}
+
+	// retry the sector, excluding the failed volume
+	release, err := db.StoreSectorExcluding(root, []int64{first.ID}, func(loc storage.SectorLocation, exists bool) error {
+		if exists {
+			t.Fatal("expected sector to not exist")
+		} else if loc.Volume != second.ID {
+			t.Fatalf("expected volume %v, got %v", second.ID, loc.Volume)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if err := release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// excluding every volume should fail
+	_, err = db.StoreSectorExcluding(frand.Entropy256(), []int64{first.ID, second.ID}, func(storage.SectorLocation, bool) error { return nil })
+	if !errors.Is(err, storage.ErrNotEnoughStorage) {
+		t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+	}
+}
+
+func TestStoreSectorSpread(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s := settings.DefaultSettings
+	s.SectorAllocation = settings.SectorAllocationSpread
+	if err := db.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	var volumes []storage.Volume
+	for i, size := range []uint64{4, 4, 2} {
+		vol, err := addTestVolume(db, fmt.Sprintf("vol%d", i), size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		volumes = append(volumes, vol)
+	}
+
+	// the sectors are not referenced, so keep them locked to prevent them
+	// from being pruned
+	var releases []func() error
+	defer func() {
+		for _, release := range releases {
+			release()
+		}
+	}()
+	storeSector := func() int64 {
+		t.Helper()
+
+		var volumeID int64
+		release, err := db.StoreSector(frand.Entropy256(), func(loc storage.SectorLocation, exists bool) error {
+			volumeID = loc.Volume
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+		return volumeID
+	}
+
+	// sequential writes should be distributed round-robin across the volumes
+	for i := 0; i < 6; i++ {
+		if volumeID := storeSector(); volumeID != volumes[i%3].ID {
+			t.Fatalf("write %d: expected volume %d, got %d", i, volumes[i%3].ID, volumeID)
+		}
+	}
+
+	// the third volume is full and should be skipped
+	for i := 0; i < 4; i++ {
+		if volumeID := storeSector(); volumeID != volumes[i%2].ID {
+			t.Fatalf("write %d: expected volume %d, got %d", i, volumes[i%2].ID, volumeID)
+		}
+	}
+
+	// all volumes are full
+	if _, err := db.StoreSector(frand.Entropy256(), func(storage.SectorLocation, bool) error { return nil }); !errors.Is(err, storage.ErrNotEnoughStorage) {
+		t.Fatalf("expected ErrNotEnoughStorage, got %v", err)
+	}
+
+	for _, vol := range volumes {
+		if vol, err := db.Volume(vol.ID); err != nil {
+			t.Fatal(err)
+		} else if vol.UsedSectors != vol.TotalSectors {
+			t.Fatalf("expected volume %d to be full, got %d/%d sectors", vol.ID, vol.UsedSectors, vol.TotalSectors)
+		}
+	}
+}
+
+func TestStoreSectorConcurrent(t *testing.T) {
+	const (
+		volumeSectors = 64
+		stored        = 48
+		writers       = 64
+	)
+
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	volume, err := addTestVolume(db, "test", volumeSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sectors are not referenced, so keep them locked to prevent them
+	// from being pruned
+	var releases []func() error
+	defer func() {
+		for _, release := range releases {
+			release()
+		}
+	}()
+
+	locations := make(map[uint64]types.Hash256)
+	for i := 0; i < stored; i++ {
+		root := frand.Entropy256()
+		release, err := db.StoreSector(root, func(loc storage.SectorLocation, exists bool) error {
+			locations[loc.Index] = root
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+
+	// store more sectors than the volume has room for at the same time
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var full int
+	errCh := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			root := frand.Entropy256()
+			release, err := db.StoreSector(root, func(loc storage.SectorLocation, exists bool) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if exists {
+					return fmt.Errorf("sector %v already exists", root)
+				} else if loc.Volume != volume.ID {
+					return fmt.Errorf("expected volume %v, got %v", volume.ID, loc.Volume)
+				} else if existing, ok := locations[loc.Index]; ok {
+					return fmt.Errorf("sector %v was given index %v, which is used by %v", root, loc.Index, existing)
+				}
+				locations[loc.Index] = root
+				return nil
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, storage.ErrNotEnoughStorage) {
+				full++
+				return
+			} else if err != nil {
+				errCh <- err
+				return
+			}
+			releases = append(releases, release)
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatal(err)
+	}
+
+	// every location should be used exactly once and the remaining writes
+	// should have been rejected
+	if len(locations) != volumeSectors {
+		t.Fatalf("expected %v locations, got %v", volumeSectors, len(locations))
+	} else if full != writers-(volumeSectors-stored) {
+		t.Fatalf("expected %v writes to fail, got %v", writers-(volumeSectors-stored), full)
+	}
+	for i := uint64(0); i < volumeSectors; i++ {
+		if _, ok := locations[i]; !ok {
+			t.Fatalf("expected index %v to be used", i)
+		}
+	}
+
+	if vol, err := db.Volume(volume.ID); err != nil {
+		t.Fatal(err)
+	} else if vol.UsedSectors != volumeSectors {
+		t.Fatalf("expected %v used sectors, got %v", volumeSectors, vol.UsedSectors)
+	}
+
+	// each stored sector should be found at the location it was given
+	for index, root := range locations {
+		loc, release, err := db.SectorLocation(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		if loc.Volume != volume.ID || loc.Index != index {
+			t.Fatalf("expected sector %v at %v:%v, got %v:%v", root, volume.ID, index, loc.Volume, loc.Index)
+		}
+	}
+}
+
 func TestAddSector(t *testing.T) {
 	log := zaptest.NewLogger(t)
 	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
@@ -586,6 +831,217 @@ func TestMigrateSectors(t *testing.T) {
 	}
 }
 
+func TestMigrateSectorsResume(t *testing.T) {
+	const sectors = 32
+	const crashAfter = 10
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	volume1, err := addTestVolume(db, "test", sectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := make([]types.Hash256, sectors)
+	for i := range roots {
+		roots[i] = frand.Entropy256()
+		release, err := db.StoreSector(roots[i], func(loc storage.SectorLocation, exists bool) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		} else if err := db.AddTemporarySectors([]storage.TempSector{{Root: roots[i], Expiration: 100}}); err != nil {
+			t.Fatal(err)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	volume2, err := addTestVolume(db, "test2", sectors)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := db.SetReadOnly(volume1.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := func() int64 {
+		t.Helper()
+		lastIndex, err := migrationProgress(&dbTxn{db}, volume1.ID, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lastIndex
+	}
+
+	// simulate a crash part way through the migration
+	moved := make(map[types.Hash256]bool)
+	func() {
+		defer func() {
+			if r := recover(); r != "crash" {
+				t.Fatalf("expected crash, got %v", r)
+			}
+		}()
+		db.MigrateSectors(context.Background(), volume1.ID, 0, func(loc storage.SectorLocation) error {
+			if len(moved) == crashAfter {
+				panic("crash")
+			}
+			moved[loc.Root] = true
+			return nil
+		})
+	}()
+
+	if lastIndex := progress(); lastIndex != crashAfter-1 {
+		t.Fatalf("expected last index %v, got %v", crashAfter-1, lastIndex)
+	}
+
+	// resume the migration
+	migrated, failed, err := db.MigrateSectors(context.Background(), volume1.ID, 0, func(loc storage.SectorLocation) error {
+		if moved[loc.Root] {
+			t.Fatalf("sector %v migrated twice", loc.Root)
+		}
+		moved[loc.Root] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if migrated != sectors-crashAfter {
+		t.Fatalf("expected %v migrated sectors, got %v", sectors-crashAfter, migrated)
+	} else if failed != 0 {
+		t.Fatalf("expected 0 failed sectors, got %v", failed)
+	} else if len(moved) != sectors {
+		t.Fatalf("expected %v sectors to be moved, got %v", sectors, len(moved))
+	} else if lastIndex := progress(); lastIndex != -1 {
+		t.Fatalf("expected migration progress to be cleared, got %v", lastIndex)
+	}
+
+	// check that no sectors were lost
+	for _, root := range roots {
+		loc, release, err := db.SectorLocation(root)
+		if err != nil {
+			t.Fatal(err)
+		} else if loc.Volume != volume2.ID {
+			t.Fatalf("expected sector %v in volume %v, got %v", root, volume2.ID, loc.Volume)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if v1, err := db.Volume(volume1.ID); err != nil {
+		t.Fatal(err)
+	} else if v1.UsedSectors != 0 {
+		t.Fatalf("expected volume 1 to be empty, got %v", v1.UsedSectors)
+	} else if v2, err := db.Volume(volume2.ID); err != nil {
+		t.Fatal(err)
+	} else if v2.UsedSectors != sectors {
+		t.Fatalf("expected volume 2 to have %v sectors, got %v", sectors, v2.UsedSectors)
+	}
+}
+
+func TestMigrateSectorsStaleProgress(t *testing.T) {
+	const sectors = 32
+	const cancelAfter = 10
+	const newSectors = 5
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	volume1, err := addTestVolume(db, "test", sectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storeSectors := func(n int) (roots []types.Hash256) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			root := frand.Entropy256()
+			release, err := db.StoreSector(root, func(loc storage.SectorLocation, exists bool) error { return nil })
+			if err != nil {
+				t.Fatal(err)
+			} else if err := db.AddTemporarySectors([]storage.TempSector{{Root: root, Expiration: 100}}); err != nil {
+				t.Fatal(err)
+			} else if err := release(); err != nil {
+				t.Fatal(err)
+			}
+			roots = append(roots, root)
+		}
+		return
+	}
+	roots := storeSectors(sectors)
+
+	volume2, err := addTestVolume(db, "test2", sectors*2)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := db.SetReadOnly(volume1.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// cancel the migration part way through
+	ctx, cancel := context.WithCancel(context.Background())
+	var moved int
+	_, _, err = db.MigrateSectors(ctx, volume1.ID, 0, func(loc storage.SectorLocation) error {
+		moved++
+		if moved == cancelAfter {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// write new sectors into the migrated range of the first volume
+	if err := db.SetReadOnly(volume1.ID, false); err != nil {
+		t.Fatal(err)
+	} else if err := db.SetReadOnly(volume2.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range storeSectors(newSectors) {
+		loc, release, err := db.SectorLocation(root)
+		if err != nil {
+			t.Fatal(err)
+		} else if loc.Volume != volume1.ID || loc.Index >= cancelAfter {
+			t.Fatalf("expected sector in the migrated range of volume %v, got %v:%v", volume1.ID, loc.Volume, loc.Index)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	if err := db.SetReadOnly(volume1.ID, true); err != nil {
+		t.Fatal(err)
+	} else if err := db.SetReadOnly(volume2.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the new sectors must be migrated by the next run
+	_, failed, err := db.MigrateSectors(context.Background(), volume1.ID, 0, func(loc storage.SectorLocation) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	} else if failed != 0 {
+		t.Fatalf("expected 0 failed sectors, got %v", failed)
+	}
+
+	for _, root := range roots {
+		loc, release, err := db.SectorLocation(root)
+		if err != nil {
+			t.Fatal(err)
+		} else if loc.Volume != volume2.ID {
+			t.Fatalf("expected sector %v in volume %v, got %v", root, volume2.ID, loc.Volume)
+		} else if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v1, err := db.Volume(volume1.ID); err != nil {
+		t.Fatal(err)
+	} else if v1.UsedSectors != 0 {
+		t.Fatalf("expected volume 1 to be empty, got %v", v1.UsedSectors)
+	}
+}
+
 func TestPrune(t *testing.T) {
 	const sectors = 100
 
@@ -921,3 +1377,129 @@ func BenchmarkStoreSector(b *testing.B) {
 		}
 	}
 }
+
+func TestVolumeSectors(t *testing.T) {
+	const sectors = 25
+
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "test.db"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	volume, err := addTestVolume(db, "test", sectors*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := make([]types.Hash256, 0, sectors)
+	for i := 0; i < sectors; i++ {
+		root := frand.Entropy256()
+		release, err := db.StoreSector(root, func(loc storage.SectorLocation, exists bool) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+		roots = append(roots, root)
+	}
+
+	renterKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	hostKey := types.NewPrivateKeyFromSeed(frand.Bytes(32))
+	contractUnlockConditions := types.UnlockConditions{
+		PublicKeys: []types.UnlockKey{
+			renterKey.PublicKey().UnlockKey(),
+			hostKey.PublicKey().UnlockKey(),
+		},
+		SignaturesRequired: 2,
+	}
+	c := contracts.SignedRevision{
+		Revision: types.FileContractRevision{
+			UnlockConditions: contractUnlockConditions,
+			ParentID:         types.FileContractID(frand.Entropy256()),
+			FileContract: types.FileContract{
+				UnlockHash:  types.Hash256(contractUnlockConditions.UnlockHash()),
+				WindowStart: 90,
+				WindowEnd:   100,
+			},
+		},
+	}
+	if err := db.AddContract(c, []types.Transaction{}, types.MaxCurrency, contracts.Usage{}, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// reference the first 10 sectors from the contract
+	var changes []contracts.SectorChange
+	for _, root := range roots[:10] {
+		changes = append(changes, contracts.SectorChange{
+			Root:   root,
+			Action: contracts.SectorActionAppend,
+		})
+	}
+	if err := db.ReviseContract(c, []types.Hash256{}, contracts.Usage{}, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	// page through the volume's sectors
+	const pageSize = 10
+	var listed []storage.VolumeSector
+	for offset := 0; ; offset += pageSize {
+		page, err := db.VolumeSectors(volume.ID, pageSize, offset)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(page) == 0 {
+			break
+		} else if len(page) > pageSize {
+			t.Fatalf("expected at most %v sectors, got %v", pageSize, len(page))
+		}
+		listed = append(listed, page...)
+	}
+
+	if len(listed) != sectors {
+		t.Fatalf("expected %v sectors, got %v", sectors, len(listed))
+	}
+	for i, sector := range listed {
+		if sector.Index != uint64(i) {
+			t.Fatalf("expected index %v, got %v", i, sector.Index)
+		} else if sector.Root != roots[i] {
+			t.Fatalf("expected root %v at index %v, got %v", roots[i], i, sector.Root)
+		}
+
+		expectedContracts := uint64(0)
+		if i < 10 {
+			expectedContracts = 1
+		}
+		if sector.Contracts != expectedContracts {
+			t.Fatalf("expected %v contracts for sector %v, got %v", expectedContracts, i, sector.Contracts)
+		}
+	}
+
+	// page through the volume's sectors by index
+	var keyset []storage.VolumeSector
+	for start := uint64(0); ; {
+		page, err := db.VolumeSectorsFrom(volume.ID, start, pageSize)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(page) == 0 {
+			break
+		} else if len(page) > pageSize {
+			t.Fatalf("expected at most %v sectors, got %v", pageSize, len(page))
+		}
+		keyset = append(keyset, page...)
+		start = page[len(page)-1].Index + 1
+	}
+	if len(keyset) != len(listed) {
+		t.Fatalf("expected %v sectors, got %v", len(listed), len(keyset))
+	}
+	for i := range keyset {
+		if keyset[i] != listed[i] {
+			t.Fatalf("expected sector %v, got %v", listed[i], keyset[i])
+		}
+	}
+
+	if _, err := db.VolumeSectors(volume.ID+1, pageSize, 0); !errors.Is(err, storage.ErrVolumeNotFound) {
+		t.Fatalf("expected ErrVolumeNotFound, got %v", err)
+	} else if _, err := db.VolumeSectorsFrom(volume.ID+1, 0, pageSize); !errors.Is(err, storage.ErrVolumeNotFound) {
+		t.Fatalf("expected ErrVolumeNotFound, got %v", err)
+	}
+}
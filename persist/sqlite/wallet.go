@@ -10,6 +10,7 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/wallet"
 	"go.sia.tech/siad/modules"
+	"go.uber.org/zap"
 )
 
 func encodeTransaction(txn wallet.Transaction) []byte {
@@ -151,6 +152,38 @@ func (s *Store) TransactionCount() (count uint64, err error) {
 	return
 }
 
+// PruneTransactions removes transactions confirmed before height from the
+// wallet's history and adds them to the pruned totals. Transactions that
+// created an output that is still unspent are kept so the origin of the
+// wallet's balance remains visible.
+func (s *Store) PruneTransactions(height uint64) (pruned int, err error) {
+	log := s.log.Named("PruneTransactions").With(zap.Uint64("height", height))
+	// prune in batches to avoid holding a lock on the database for too long
+	var lastID int64
+	for {
+		var n, scanned int
+		err := s.transaction(func(tx txn) (err error) {
+			n, scanned, lastID, err = pruneWalletTransactions(tx, height, lastID)
+			return
+		})
+		if err != nil {
+			return pruned, fmt.Errorf("failed to prune transactions: %w", err)
+		}
+		pruned += n
+		if scanned < walletPruneBatchSize {
+			log.Debug("pruned transactions", zap.Int("pruned", pruned))
+			return pruned, nil
+		}
+		jitterSleep(time.Millisecond) // allow other transactions to run
+	}
+}
+
+// PrunedTransactions returns the totals of all pruned wallet transactions.
+func (s *Store) PrunedTransactions() (pt wallet.PrunedTransactions, err error) {
+	err = s.queryRow(`SELECT wallet_pruned_count, wallet_pruned_inflow, wallet_pruned_outflow FROM global_settings`).Scan(&pt.Count, nullable((*sqlCurrency)(&pt.Inflow)), nullable((*sqlCurrency)(&pt.Outflow)))
+	return
+}
+
 // UpdateWallet begins an update transaction on the wallet store.
 func (s *Store) UpdateWallet(ccID modules.ConsensusChangeID, height uint64, fn func(wallet.UpdateTransaction) error) error {
 	return s.transaction(func(tx txn) error {
@@ -191,9 +224,104 @@ func (s *Store) ResetWallet(seedHash types.Hash256) error {
 			return fmt.Errorf("failed to delete wallet transactions: %w", err)
 		} else if _, err := tx.Exec(`DELETE FROM host_stats WHERE stat=$1`, metricWalletBalance); err != nil {
 			return fmt.Errorf("failed to delete wallet metrics: %w", err)
-		} else if _, err := tx.Exec(`UPDATE global_settings SET wallet_last_processed_change=NULL, wallet_height=NULL,  wallet_hash=?`, sqlHash256(seedHash)); err != nil {
+		} else if _, err := tx.Exec(`UPDATE global_settings SET wallet_last_processed_change=NULL, wallet_height=NULL,  wallet_hash=?, wallet_pruned_count=0, wallet_pruned_inflow=NULL, wallet_pruned_outflow=NULL`, sqlHash256(seedHash)); err != nil {
 			return fmt.Errorf("failed to reset wallet settings: %w", err)
 		}
 		return nil
 	})
 }
+
+// walletTransactionOutputs returns the IDs of the siacoin outputs created by
+// a wallet transaction.
+func walletTransactionOutputs(txn wallet.Transaction) []types.SiacoinOutputID {
+	if txn.Source != wallet.TxnSourceTransaction {
+		// payout transactions use the ID of the delayed output
+		return []types.SiacoinOutputID{types.SiacoinOutputID(txn.ID)}
+	}
+	ids := make([]types.SiacoinOutputID, len(txn.Transaction.SiacoinOutputs))
+	for i := range txn.Transaction.SiacoinOutputs {
+		ids[i] = txn.Transaction.SiacoinOutputID(i)
+	}
+	return ids
+}
+
+// pruneWalletTransactions removes up to walletPruneBatchSize transactions
+// confirmed before height with an ID greater than afterID. The number of
+// removed and scanned transactions and the last scanned ID are returned.
+func pruneWalletTransactions(tx txn, height uint64, afterID int64) (pruned, scanned int, lastID int64, err error) {
+	rows, err := tx.Query(`SELECT id, raw_transaction FROM wallet_transactions WHERE block_height < $1 AND id > $2 ORDER BY id ASC LIMIT $3`, height, afterID, walletPruneBatchSize)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	type prunable struct {
+		dbID int64
+		txn  wallet.Transaction
+	}
+	var batch []prunable
+	lastID = afterID
+	for rows.Next() {
+		var p prunable
+		var buf []byte
+		if err := rows.Scan(&p.dbID, &buf); err != nil {
+			rows.Close()
+			return 0, 0, 0, fmt.Errorf("failed to scan transaction: %w", err)
+		} else if err := decodeTransaction(buf, &p.txn); err != nil {
+			rows.Close()
+			return 0, 0, 0, fmt.Errorf("failed to decode transaction: %w", err)
+		}
+		batch = append(batch, p)
+		lastID = p.dbID
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, 0, 0, err
+	}
+	rows.Close()
+
+	utxoStmt, err := tx.Prepare(`SELECT EXISTS(SELECT 1 FROM wallet_utxos WHERE id=$1)`)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to prepare utxo statement: %w", err)
+	}
+	defer utxoStmt.Close()
+
+	deleteStmt, err := tx.Prepare(`DELETE FROM wallet_transactions WHERE id=$1`)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to prepare delete statement: %w", err)
+	}
+	defer deleteStmt.Close()
+
+	var totals wallet.PrunedTransactions
+	err = tx.QueryRow(`SELECT wallet_pruned_count, wallet_pruned_inflow, wallet_pruned_outflow FROM global_settings`).Scan(&totals.Count, nullable((*sqlCurrency)(&totals.Inflow)), nullable((*sqlCurrency)(&totals.Outflow)))
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get pruned totals: %w", err)
+	}
+
+outer:
+	for _, p := range batch {
+		// keep transactions that created an unspent output
+		for _, id := range walletTransactionOutputs(p.txn) {
+			var unspent bool
+			if err := utxoStmt.QueryRow(sqlHash256(id)).Scan(&unspent); err != nil {
+				return 0, 0, 0, fmt.Errorf("failed to check output %v: %w", id, err)
+			} else if unspent {
+				continue outer
+			}
+		}
+
+		if _, err := deleteStmt.Exec(p.dbID); err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to delete transaction %v: %w", p.txn.ID, err)
+		}
+		totals.Count++
+		totals.Inflow = totals.Inflow.Add(p.txn.Inflow)
+		totals.Outflow = totals.Outflow.Add(p.txn.Outflow)
+		pruned++
+	}
+
+	if pruned > 0 {
+		_, err = tx.Exec(`UPDATE global_settings SET wallet_pruned_count=$1, wallet_pruned_inflow=$2, wallet_pruned_outflow=$3`, totals.Count, sqlCurrency(totals.Inflow), sqlCurrency(totals.Outflow))
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to update pruned totals: %w", err)
+		}
+	}
+	return pruned, len(batch), lastID, nil
+}
@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/wallet"
+	"go.sia.tech/siad/modules"
+	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
+)
+
+func TestPruneTransactions(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "hostd.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	addr := types.Address(frand.Entropy256())
+	randomTxn := func(height uint64) wallet.Transaction {
+		txn := types.Transaction{
+			SiacoinOutputs: []types.SiacoinOutput{{Address: addr, Value: types.Siacoins(1)}},
+			ArbitraryData:  [][]byte{frand.Bytes(16)}, // ensure each transaction has a unique ID
+		}
+		return wallet.Transaction{
+			ID:          txn.ID(),
+			Index:       types.ChainIndex{ID: frand.Entropy256(), Height: height},
+			Transaction: txn,
+			Inflow:      types.Siacoins(1),
+			Outflow:     types.NewCurrency64(height),
+			Source:      wallet.TxnSourceTransaction,
+			Timestamp:   time.Now(),
+		}
+	}
+
+	// seed a transaction at each height from 1 to 10
+	var txns []wallet.Transaction
+	for height := uint64(1); height <= 10; height++ {
+		txns = append(txns, randomTxn(height))
+	}
+	// an old miner payout and an old transaction that both created an
+	// unspent output
+	payout := wallet.Transaction{
+		ID:          types.TransactionID(frand.Entropy256()),
+		Index:       types.ChainIndex{ID: frand.Entropy256(), Height: 2},
+		Transaction: types.Transaction{SiacoinOutputs: []types.SiacoinOutput{{Address: addr, Value: types.Siacoins(10)}}},
+		Inflow:      types.Siacoins(10),
+		Source:      wallet.TxnSourceMinerPayout,
+		Timestamp:   time.Now(),
+	}
+	unspent := randomTxn(3)
+
+	err = db.UpdateWallet(modules.ConsensusChangeID(frand.Entropy256()), 10, func(tx wallet.UpdateTransaction) error {
+		for _, txn := range append(txns, payout, unspent) {
+			if err := tx.AddTransaction(txn); err != nil {
+				return err
+			}
+		}
+		if err := tx.AddSiacoinElement(wallet.SiacoinElement{ID: types.SiacoinOutputID(payout.ID), SiacoinOutput: payout.Transaction.SiacoinOutputs[0]}); err != nil {
+			return err
+		}
+		return tx.AddSiacoinElement(wallet.SiacoinElement{ID: unspent.Transaction.SiacoinOutputID(0), SiacoinOutput: unspent.Transaction.SiacoinOutputs[0]})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := db.TransactionCount(); err != nil {
+		t.Fatal(err)
+	} else if count != 12 {
+		t.Fatalf("expected 12 transactions, got %v", count)
+	}
+
+	// prune the transactions before height 6
+	if pruned, err := db.PruneTransactions(6); err != nil {
+		t.Fatal(err)
+	} else if pruned != 5 {
+		t.Fatalf("expected 5 pruned transactions, got %v", pruned)
+	}
+
+	remaining, err := db.Transactions(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(remaining) != 7 {
+		t.Fatalf("expected 7 transactions, got %v", len(remaining))
+	}
+	kept := make(map[types.TransactionID]bool)
+	for _, txn := range remaining {
+		kept[txn.ID] = true
+		if txn.Index.Height < 6 && txn.ID != payout.ID && txn.ID != unspent.ID {
+			t.Fatalf("transaction at height %v should have been pruned", txn.Index.Height)
+		}
+	}
+	if !kept[payout.ID] || !kept[unspent.ID] {
+		t.Fatal("transactions with unspent outputs should be kept")
+	}
+
+	// the pruned totals should include every removed transaction
+	expected := wallet.PrunedTransactions{Count: 5, Inflow: types.Siacoins(5), Outflow: types.NewCurrency64(1 + 2 + 3 + 4 + 5)}
+	if pt, err := db.PrunedTransactions(); err != nil {
+		t.Fatal(err)
+	} else if pt != expected {
+		t.Fatalf("expected pruned totals %+v, got %+v", expected, pt)
+	}
+
+	// the wallet's outputs should not be affected
+	if utxos, err := db.UnspentSiacoinElements(); err != nil {
+		t.Fatal(err)
+	} else if len(utxos) != 2 {
+		t.Fatalf("expected 2 unspent outputs, got %v", len(utxos))
+	}
+
+	// pruning again should only add the newly pruned transactions
+	if pruned, err := db.PruneTransactions(8); err != nil {
+		t.Fatal(err)
+	} else if pruned != 2 {
+		t.Fatalf("expected 2 pruned transactions, got %v", pruned)
+	} else if pt, err := db.PrunedTransactions(); err != nil {
+		t.Fatal(err)
+	} else if pt.Count != 7 || !pt.Inflow.Equals(types.Siacoins(7)) {
+		t.Fatalf("unexpected pruned totals %+v", pt)
+	} else if count, err := db.TransactionCount(); err != nil {
+		t.Fatal(err)
+	} else if count != 5 {
+		t.Fatalf("expected 5 transactions, got %v", count)
+	}
+}
@@ -2,8 +2,10 @@ package rhp
 
 import (
 	"context"
+	"errors"
 	"net"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/time/rate"
 )
@@ -25,6 +27,10 @@ type (
 	}
 )
 
+// ErrHandshakeTimeout is returned when a peer does not complete the protocol
+// handshake before the handshake timeout.
+var ErrHandshakeTimeout = errors.New("handshake timed out")
+
 // Usage returns the amount of data read and written by the connection.
 func (c *Conn) Usage() (read, written uint64) {
 	read = atomic.LoadUint64(&c.r)
@@ -66,3 +72,16 @@ func NewConn(c net.Conn, m DataMonitor, rl, wl *rate.Limiter) *Conn {
 		wl:      wl,
 	}
 }
+
+// GuardHandshake closes conn if the protocol handshake does not complete
+// within timeout, so peers that open a connection and stall cannot tie up
+// the host's resources. The returned function must be called once the
+// handshake returns; it reports false if conn was closed by the timeout. A
+// timeout of 0 disables the guard.
+func GuardHandshake(conn net.Conn, timeout time.Duration) (done func() bool) {
+	if timeout <= 0 {
+		return func() bool { return true }
+	}
+	t := time.AfterFunc(timeout, func() { conn.Close() })
+	return t.Stop
+}
@@ -8,6 +8,69 @@ import (
 	"go.sia.tech/core/types"
 )
 
+// RevisionNumberReserve is the number of revision numbers below
+// types.MaxRevisionNumber that are reserved. Standard revisions may not use a
+// reserved revision number so that a long-lived contract can always be
+// cleared or renewed instead of colliding with the clearing revision's
+// sentinel.
+const RevisionNumberReserve = 1 << 20
+
+var (
+	// ErrStaleRevision is returned when a renter submits a revision that is
+	// not newer than the host's latest revision of the contract.
+	ErrStaleRevision = errors.New("stale revision")
+	// ErrRevisionNumberExhausted is returned when a revision uses one of the
+	// reserved revision numbers. The contract can no longer be revised and
+	// must be renewed.
+	ErrRevisionNumberExhausted = errors.New("contract revision numbers exhausted, renew the contract to continue")
+	// ErrNotSynced is returned when the host is asked to form or renew a
+	// contract while its chain is behind the network.
+	ErrNotSynced = errors.New("host is not synced")
+)
+
+// A StaleRevisionError is returned when a revision's number is not greater
+// than the host's latest revision number. Renters should resync the contract
+// and revise from Expected.
+//
+// There is no grace window for stale revisions. The renter's signature covers
+// the revision's outputs, which were computed from an older revision, so the
+// host cannot rebase the payment onto its latest revision. Accepting a
+// duplicate of the latest revision would apply its payment or sector changes
+// a second time.
+type StaleRevisionError struct {
+	// Current is the revision number of the host's latest revision.
+	Current uint64
+	// Received is the revision number submitted by the renter.
+	Received uint64
+	// Duplicate is true if the submitted revision is identical to the host's
+	// latest revision, usually because a previous submission was retried.
+	Duplicate bool
+}
+
+// Expected returns the lowest revision number the host will accept.
+func (e *StaleRevisionError) Expected() uint64 {
+	return e.Current + 1
+}
+
+// Error implements the error interface.
+func (e *StaleRevisionError) Error() string {
+	if e.Duplicate {
+		return fmt.Sprintf("%v: revision %d has already been applied, expected revision number %d or greater", ErrStaleRevision, e.Received, e.Expected())
+	}
+	return fmt.Sprintf("%v: revision number %d must be at least %d", ErrStaleRevision, e.Received, e.Expected())
+}
+
+// Unwrap returns ErrStaleRevision so the error can be checked with errors.Is.
+func (e *StaleRevisionError) Unwrap() error {
+	return ErrStaleRevision
+}
+
+// RevisionNumberExhausted returns true if n is one of the reserved revision
+// numbers that cannot be used by a standard revision.
+func RevisionNumberExhausted(n uint64) bool {
+	return n >= types.MaxRevisionNumber-RevisionNumberReserve
+}
+
 func contractUnlockConditions(hostKey, renterKey types.UnlockKey) types.UnlockConditions {
 	return types.UnlockConditions{
 		PublicKeys:         []types.UnlockKey{renterKey, hostKey},
@@ -19,48 +82,58 @@ func contractUnlockConditions(hostKey, renterKey types.UnlockKey) types.UnlockCo
 // revision. Only the revision number and proof output values are allowed to
 // change
 func validateStdRevision(current, revision types.FileContractRevision) error {
+	// check the revision number first so that stale revisions are reported
+	// with the expected revision number instead of a mismatched output.
+	if revision.RevisionNumber <= current.RevisionNumber {
+		return &StaleRevisionError{
+			Current:   current.RevisionNumber,
+			Received:  revision.RevisionNumber,
+			Duplicate: HashRevision(revision) == HashRevision(current),
+		}
+	} else if RevisionNumberExhausted(revision.RevisionNumber) {
+		return &ValidationError{Code: CodeRevisionNumberExhausted, Err: ErrRevisionNumberExhausted}
+	}
+
 	var oldPayout, validPayout, missedPayout types.Currency
 	for _, o := range current.ValidProofOutputs {
 		oldPayout = oldPayout.Add(o.Value)
 	}
 	for i := range revision.ValidProofOutputs {
 		if revision.ValidProofOutputs[i].Address != current.ValidProofOutputs[i].Address {
-			return fmt.Errorf("valid proof output %v address should not change", i)
+			return invalidf(CodeImmutableField, "valid proof output %v address should not change", i)
 		}
 		validPayout = validPayout.Add(revision.ValidProofOutputs[i].Value)
 	}
 	for i := range revision.MissedProofOutputs {
 		if revision.MissedProofOutputs[i].Address != current.MissedProofOutputs[i].Address {
-			return fmt.Errorf("missed proof output %v address should not change", i)
+			return invalidf(CodeImmutableField, "missed proof output %v address should not change", i)
 		}
 		missedPayout = missedPayout.Add(revision.MissedProofOutputs[i].Value)
 	}
 
 	switch {
 	case !validPayout.Equals(oldPayout):
-		return errors.New("valid proof output sum must not change")
+		return invalid(CodeInvalidOutputs, "valid proof output sum must not change")
 	case !missedPayout.Equals(oldPayout):
-		return errors.New("missed proof output sum must not change")
+		return invalid(CodeInvalidOutputs, "missed proof output sum must not change")
 	case revision.UnlockHash != current.UnlockHash:
-		return errors.New("unlock hash must not change")
+		return invalid(CodeImmutableField, "unlock hash must not change")
 	case revision.UnlockConditions.UnlockHash() != current.UnlockConditions.UnlockHash():
-		return errors.New("unlock conditions must not change")
-	case revision.RevisionNumber <= current.RevisionNumber:
-		return errors.New("revision number must increase")
+		return invalid(CodeImmutableField, "unlock conditions must not change")
 	case revision.WindowStart != current.WindowStart:
-		return errors.New("window start must not change")
+		return invalid(CodeImmutableField, "window start must not change")
 	case revision.WindowEnd != current.WindowEnd:
-		return errors.New("window end must not change")
+		return invalid(CodeImmutableField, "window end must not change")
 	case len(revision.ValidProofOutputs) != len(current.ValidProofOutputs):
-		return errors.New("valid proof outputs must not change")
+		return invalid(CodeInvalidOutputs, "valid proof outputs must not change")
 	case len(revision.MissedProofOutputs) != len(current.MissedProofOutputs):
-		return errors.New("missed proof outputs must not change")
+		return invalid(CodeInvalidOutputs, "missed proof outputs must not change")
 	case revision.ValidRenterPayout().Cmp(current.ValidRenterPayout()) > 0:
-		return errors.New("renter valid proof output must not increase")
+		return invalid(CodeInvalidPayout, "renter valid proof output must not increase")
 	case revision.MissedRenterPayout().Cmp(current.MissedRenterPayout()) > 0:
-		return errors.New("renter missed proof output must not increase")
+		return invalid(CodeInvalidPayout, "renter missed proof output must not increase")
 	case !revision.ValidRenterPayout().Equals(revision.MissedRenterPayout()):
-		return errors.New("renter payouts must be equal")
+		return invalid(CodeInvalidOutputs, "renter payouts must be equal")
 	}
 	return nil
 }
@@ -96,13 +169,13 @@ func InitialRevision(formationTxn *types.Transaction, hostPubKey, renterPubKey t
 func Revise(revision types.FileContractRevision, revisionNumber uint64, validOutputs, missedOutputs []types.Currency) (types.FileContractRevision, error) {
 	switch {
 	case revision.RevisionNumber == math.MaxUint64:
-		return types.FileContractRevision{}, errors.New("contract is locked")
+		return types.FileContractRevision{}, invalid(CodeContractLocked, "contract is locked")
 	case revisionNumber <= revision.RevisionNumber:
-		return types.FileContractRevision{}, fmt.Errorf("revision number must be greater than %v", revision.RevisionNumber)
+		return types.FileContractRevision{}, invalidf(CodeStaleRevision, "revision number must be greater than %v", revision.RevisionNumber)
 	case len(validOutputs) != len(revision.ValidProofOutputs):
-		return types.FileContractRevision{}, errors.New("incorrect number of valid outputs")
+		return types.FileContractRevision{}, invalid(CodeInvalidOutputs, "incorrect number of valid outputs")
 	case len(missedOutputs) != len(revision.MissedProofOutputs):
-		return types.FileContractRevision{}, errors.New("incorrect number of missed outputs")
+		return types.FileContractRevision{}, invalid(CodeInvalidOutputs, "incorrect number of missed outputs")
 	}
 
 	revision.RevisionNumber = revisionNumber
@@ -124,9 +197,9 @@ func Revise(revision types.FileContractRevision, revisionNumber uint64, validOut
 // proof outputs to the valid proof outputs.
 func ClearingRevision(revision types.FileContractRevision, outputValues []types.Currency) (types.FileContractRevision, error) {
 	if revision.RevisionNumber == math.MaxUint64 {
-		return types.FileContractRevision{}, errors.New("contract is locked")
+		return types.FileContractRevision{}, invalid(CodeContractLocked, "contract is locked")
 	} else if len(outputValues) != len(revision.ValidProofOutputs) {
-		return types.FileContractRevision{}, errors.New("incorrect number of outputs")
+		return types.FileContractRevision{}, invalid(CodeInvalidOutputs, "incorrect number of outputs")
 	}
 
 	oldValid := revision.ValidProofOutputs
@@ -148,37 +221,37 @@ func ClearingRevision(revision types.FileContractRevision, outputValues []types.
 func ValidateClearingRevision(current, final types.FileContractRevision, finalPayment types.Currency) (types.Currency, error) {
 	switch {
 	case final.Filesize != 0:
-		return types.ZeroCurrency, errors.New("filesize must be 0")
+		return types.ZeroCurrency, invalid(CodeInvalidOutputs, "filesize must be 0")
 	case final.FileMerkleRoot != types.Hash256{}:
-		return types.ZeroCurrency, errors.New("file merkle root must be empty")
+		return types.ZeroCurrency, invalid(CodeInvalidOutputs, "file merkle root must be empty")
 	case current.WindowStart != final.WindowStart:
-		return types.ZeroCurrency, errors.New("window start must not change")
+		return types.ZeroCurrency, invalid(CodeImmutableField, "window start must not change")
 	case current.WindowEnd != final.WindowEnd:
-		return types.ZeroCurrency, errors.New("window end must not change")
+		return types.ZeroCurrency, invalid(CodeImmutableField, "window end must not change")
 	case len(final.MissedProofOutputs) != 2:
-		return types.ZeroCurrency, errors.New("wrong number of proof outputs")
+		return types.ZeroCurrency, invalid(CodeInvalidOutputs, "wrong number of proof outputs")
 	case len(final.ValidProofOutputs) != len(final.MissedProofOutputs):
-		return types.ZeroCurrency, errors.New("valid proof outputs must equal missed proof outputs")
+		return types.ZeroCurrency, invalid(CodeInvalidOutputs, "valid proof outputs must equal missed proof outputs")
 	case final.RevisionNumber != types.MaxRevisionNumber:
-		return types.ZeroCurrency, errors.New("revision number must be max value")
+		return types.ZeroCurrency, invalid(CodeInvalidRevisionNumber, "revision number must be max value")
 	case final.UnlockHash != current.UnlockHash:
-		return types.ZeroCurrency, errors.New("unlock hash must not change")
+		return types.ZeroCurrency, invalid(CodeImmutableField, "unlock hash must not change")
 	case final.UnlockConditions.UnlockHash() != current.UnlockConditions.UnlockHash():
-		return types.ZeroCurrency, errors.New("unlock conditions must not change")
+		return types.ZeroCurrency, invalid(CodeImmutableField, "unlock conditions must not change")
 	}
 
 	fromRenter, underflow := current.ValidRenterPayout().SubWithUnderflow(final.MissedRenterPayout())
 	if underflow {
-		return types.ZeroCurrency, errors.New("renter valid payout must not increase")
+		return types.ZeroCurrency, invalid(CodeInvalidPayout, "renter valid payout must not increase")
 	}
 
 	toHost, underflow := final.ValidHostPayout().SubWithUnderflow(current.ValidHostPayout())
 	if underflow {
-		return types.ZeroCurrency, errors.New("host valid payout must not decrease")
+		return types.ZeroCurrency, invalid(CodeInvalidPayout, "host valid payout must not decrease")
 	} else if !fromRenter.Equals(toHost) {
-		return types.ZeroCurrency, fmt.Errorf("expected host to receive %v, but got %v", fromRenter, toHost)
+		return types.ZeroCurrency, invalidf(CodeInvalidTransfer, "expected host to receive %v, but got %v", fromRenter, toHost)
 	} else if fromRenter.Cmp(finalPayment) < 0 {
-		return types.ZeroCurrency, fmt.Errorf("expected host payment of at least %v, but got %v", finalPayment, fromRenter)
+		return types.ZeroCurrency, invalidf(CodeInsufficientPayment, "expected host payment of at least %v, but got %v", finalPayment, fromRenter)
 	}
 
 	// validate both valid and missed outputs are equal to the current valid
@@ -187,11 +260,11 @@ func ValidateClearingRevision(current, final types.FileContractRevision, finalPa
 		current, valid, missed := current.ValidProofOutputs[i], final.ValidProofOutputs[i], final.MissedProofOutputs[i]
 		switch {
 		case valid.Address != current.Address: // address doesn't change
-			return types.ZeroCurrency, fmt.Errorf("valid proof output address %v must not change", i)
+			return types.ZeroCurrency, invalidf(CodeImmutableField, "valid proof output address %v must not change", i)
 		case valid.Address != missed.Address: // valid and missed address are the same
-			return types.ZeroCurrency, fmt.Errorf("missed proof output address %v must equal valid proof output", i)
+			return types.ZeroCurrency, invalidf(CodeInvalidOutputs, "missed proof output address %v must equal valid proof output", i)
 		case !valid.Value.Equals(missed.Value): // valid and missed value are the same
-			return types.ZeroCurrency, fmt.Errorf("missed proof output %v must equal valid proof output", i)
+			return types.ZeroCurrency, invalidf(CodeInvalidOutputs, "missed proof output %v must equal valid proof output", i)
 		}
 	}
 	return toHost, nil
@@ -208,35 +281,35 @@ func ValidateRevision(current, revision types.FileContractRevision, payment, col
 	// validate the current revision has enough funds
 	switch {
 	case current.ValidRenterPayout().Cmp(payment) < 0:
-		return types.ZeroCurrency, types.ZeroCurrency, errors.New("renter valid proof output must be greater than the payment amount")
+		return types.ZeroCurrency, types.ZeroCurrency, invalid(CodeInsufficientFunds, "renter valid proof output must be greater than the payment amount")
 	case current.MissedRenterPayout().Cmp(payment) < 0:
-		return types.ZeroCurrency, types.ZeroCurrency, errors.New("renter missed proof output must be greater than the payment amount")
+		return types.ZeroCurrency, types.ZeroCurrency, invalid(CodeInsufficientFunds, "renter missed proof output must be greater than the payment amount")
 	case current.MissedHostPayout().Cmp(collateral) < 0:
-		return types.ZeroCurrency, types.ZeroCurrency, errors.New("host missed proof output must be greater than the collateral amount")
+		return types.ZeroCurrency, types.ZeroCurrency, invalid(CodeInsufficientCollateral, "host missed proof output must be greater than the collateral amount")
 	}
 
 	fromRenter, underflow := current.ValidRenterPayout().SubWithUnderflow(revision.ValidRenterPayout())
 	if underflow {
-		return types.ZeroCurrency, types.ZeroCurrency, errors.New("renter valid payout must decrease")
+		return types.ZeroCurrency, types.ZeroCurrency, invalid(CodeInvalidPayout, "renter valid payout must decrease")
 	}
 
 	toHost, overflow := revision.ValidHostPayout().SubWithUnderflow(current.ValidHostPayout())
 	if overflow {
-		return types.ZeroCurrency, types.ZeroCurrency, errors.New("host valid payout must increase")
+		return types.ZeroCurrency, types.ZeroCurrency, invalid(CodeInvalidPayout, "host valid payout must increase")
 	}
 
 	hostBurn, underflow := current.MissedHostPayout().SubWithUnderflow(revision.MissedHostPayout())
 	if underflow {
-		return types.ZeroCurrency, types.ZeroCurrency, errors.New("host missed payout must decrease")
+		return types.ZeroCurrency, types.ZeroCurrency, invalid(CodeInvalidPayout, "host missed payout must decrease")
 	}
 
 	switch {
 	case !fromRenter.Equals(toHost):
-		return types.ZeroCurrency, types.ZeroCurrency, fmt.Errorf("expected %d to be transferred from renter to host, got %d", fromRenter, toHost)
+		return types.ZeroCurrency, types.ZeroCurrency, invalidf(CodeInvalidTransfer, "expected %d to be transferred from renter to host, got %d", fromRenter, toHost)
 	case toHost.Cmp(payment) < 0:
-		return types.ZeroCurrency, types.ZeroCurrency, fmt.Errorf("insufficient host transfer: expected at least %d, got %d", payment, toHost)
+		return types.ZeroCurrency, types.ZeroCurrency, invalidf(CodeInsufficientPayment, "insufficient host transfer: expected at least %d, got %d", payment, toHost)
 	case hostBurn.Cmp(collateral) > 0:
-		return types.ZeroCurrency, types.ZeroCurrency, fmt.Errorf("excessive collateral transfer: expected at most %d, got %d", collateral, hostBurn)
+		return types.ZeroCurrency, types.ZeroCurrency, invalidf(CodeExcessiveBurn, "excessive collateral transfer: expected at most %d, got %d", collateral, hostBurn)
 	}
 	return toHost, hostBurn, nil
 }
@@ -252,13 +325,13 @@ func ValidateProgramRevision(current, revision types.FileContractRevision, stora
 	// calculate the amount of SC that the host is expected to burn
 	hostBurn, underflow := current.MissedHostPayout().SubWithUnderflow(revision.MissedHostPayout())
 	if underflow {
-		return types.ZeroCurrency, errors.New("host missed payout must decrease")
+		return types.ZeroCurrency, invalid(CodeInvalidPayout, "host missed payout must decrease")
 	}
 
 	// validate that the host is not burning more than the expected amount
 	expectedBurn := storage.Add(collateral)
 	if hostBurn.Cmp(expectedBurn) > 0 {
-		return types.ZeroCurrency, fmt.Errorf("host expected to burn at most %d, but burned %d", expectedBurn, hostBurn)
+		return types.ZeroCurrency, invalidf(CodeExcessiveBurn, "host expected to burn at most %d, but burned %d", expectedBurn, hostBurn)
 	}
 
 	// validate that the void burn value is equal to the host burn value
@@ -266,19 +339,19 @@ func ValidateProgramRevision(current, revision types.FileContractRevision, stora
 	// good to be explicit.
 	voidBurn, underflow := revision.MissedProofOutputs[2].Value.SubWithUnderflow(current.MissedProofOutputs[2].Value)
 	if underflow {
-		return types.ZeroCurrency, errors.New("void output value must increase")
+		return types.ZeroCurrency, invalid(CodeInvalidPayout, "void output value must increase")
 	} else if !voidBurn.Equals(hostBurn) {
-		return types.ZeroCurrency, fmt.Errorf("host burn value %d should match void burn value %d", hostBurn, voidBurn)
+		return types.ZeroCurrency, invalidf(CodeInvalidTransfer, "host burn value %d should match void burn value %d", hostBurn, voidBurn)
 	}
 
 	// validate no other values have changed
 	switch {
 	case !current.ValidRenterPayout().Equals(revision.ValidRenterPayout()):
-		return types.ZeroCurrency, errors.New("renter valid proof output must not change")
+		return types.ZeroCurrency, invalid(CodeInvalidPayout, "renter valid proof output must not change")
 	case !current.ValidHostPayout().Equals(revision.ValidHostPayout()):
-		return types.ZeroCurrency, errors.New("host valid proof output must not change")
+		return types.ZeroCurrency, invalid(CodeInvalidPayout, "host valid proof output must not change")
 	case !current.MissedRenterPayout().Equals(revision.MissedRenterPayout()):
-		return types.ZeroCurrency, errors.New("renter missed proof output must not change")
+		return types.ZeroCurrency, invalid(CodeInvalidPayout, "renter missed proof output must not change")
 	}
 	return hostBurn, nil
 }
@@ -294,13 +367,118 @@ func ValidatePaymentRevision(current, revision types.FileContractRevision, payme
 	// payment from the renter payouts to the host payouts.
 	switch {
 	case revision.ValidRenterPayout().Cmp(current.ValidRenterPayout().Sub(payment)) != 0:
-		return errors.New("renter valid proof output is not reduced by the payment amount")
+		return invalid(CodeInvalidTransfer, "renter valid proof output is not reduced by the payment amount")
 	case revision.MissedRenterPayout().Cmp(current.MissedRenterPayout().Sub(payment)) != 0:
-		return errors.New("renter missed proof output is not reduced by the payment amount")
+		return invalid(CodeInvalidTransfer, "renter missed proof output is not reduced by the payment amount")
 	case revision.ValidHostPayout().Cmp(current.ValidHostPayout().Add(payment)) != 0:
-		return errors.New("host valid proof output is not increased by the payment amount")
+		return invalid(CodeInvalidTransfer, "host valid proof output is not increased by the payment amount")
 	case revision.MissedHostPayout().Cmp(current.MissedHostPayout().Add(payment)) != 0:
-		return errors.New("host missed proof output is not increased by the payment amount")
+		return invalid(CodeInvalidTransfer, "host missed proof output is not increased by the payment amount")
+	}
+	return nil
+}
+
+// ValidateFunding verifies the funding of a formation or renewal transaction.
+// The host's additions are the inputs and outputs following the renter's.
+// They must fund exactly hostFunding and return any change to the host.
+// hostInputValues must contain the value of each of the host's inputs.
+//
+// If every renter input spends an output created by one of the parent
+// transactions, the renter's inputs must fund the rest of the transaction.
+// Otherwise the renter's input values are not known to the host and their
+// balance is checked when the transaction set is broadcast.
+func ValidateFunding(parents []types.Transaction, txn types.Transaction, renterInputs, renterOutputs int, hostAddress types.Address, hostInputValues map[types.SiacoinOutputID]types.Currency, hostFunding types.Currency) error {
+	if renterInputs > len(txn.SiacoinInputs) || renterOutputs > len(txn.SiacoinOutputs) {
+		return errors.New("transaction is missing renter inputs or outputs")
+	}
+
+	// the renter must not be able to spend the host's outputs as its own
+	for _, sci := range txn.SiacoinInputs[:renterInputs] {
+		if sci.UnlockConditions.UnlockHash() == hostAddress {
+			return fmt.Errorf("renter input %v spends a host output", sci.ParentID)
+		}
+	}
+
+	var funded types.Currency
+	for _, sci := range txn.SiacoinInputs[renterInputs:] {
+		value, ok := hostInputValues[sci.ParentID]
+		if !ok {
+			return fmt.Errorf("unknown host input %v", sci.ParentID)
+		} else if sci.UnlockConditions.UnlockHash() != hostAddress {
+			return fmt.Errorf("host input %v does not spend a host output", sci.ParentID)
+		}
+		var overflow bool
+		funded, overflow = funded.AddWithOverflow(value)
+		if overflow {
+			return errors.New("host inputs overflow")
+		}
+	}
+
+	// any additional outputs must return change to the host
+	for i, sco := range txn.SiacoinOutputs[renterOutputs:] {
+		if sco.Address != hostAddress {
+			return fmt.Errorf("host output %d does not pay the host", renterOutputs+i)
+		}
+		var underflow bool
+		funded, underflow = funded.SubWithUnderflow(sco.Value)
+		if underflow {
+			return errors.New("host outputs exceed host inputs")
+		}
+	}
+
+	if !funded.Equals(hostFunding) {
+		return fmt.Errorf("host funded %d, expected %d", funded, hostFunding)
+	}
+	return validateRenterFunding(parents, txn, renterInputs, renterOutputs, hostFunding)
+}
+
+// validateRenterFunding checks that the renter's inputs fund everything in
+// the transaction not funded by the host. The check is skipped if any of the
+// renter's inputs spends an output that is not created by a parent.
+func validateRenterFunding(parents []types.Transaction, txn types.Transaction, renterInputs, renterOutputs int, hostFunding types.Currency) error {
+	parentOutputs := make(map[types.SiacoinOutputID]types.Currency)
+	for _, parent := range parents {
+		for i, sco := range parent.SiacoinOutputs {
+			parentOutputs[parent.SiacoinOutputID(i)] = sco.Value
+		}
+	}
+
+	var funded types.Currency
+	for _, sci := range txn.SiacoinInputs[:renterInputs] {
+		value, ok := parentOutputs[sci.ParentID]
+		if !ok {
+			return nil
+		}
+		var overflow bool
+		funded, overflow = funded.AddWithOverflow(value)
+		if overflow {
+			return errors.New("renter inputs overflow")
+		}
+	}
+
+	// the renter funds its own outputs, the miner fees, and the contract
+	// payouts, less the host's funding
+	spent := make([]types.Currency, 0, renterOutputs+len(txn.MinerFees)+len(txn.FileContracts))
+	for _, sco := range txn.SiacoinOutputs[:renterOutputs] {
+		spent = append(spent, sco.Value)
+	}
+	spent = append(spent, txn.MinerFees...)
+	for _, fc := range txn.FileContracts {
+		spent = append(spent, fc.Payout)
+	}
+	var required types.Currency
+	for _, v := range spent {
+		var overflow bool
+		required, overflow = required.AddWithOverflow(v)
+		if overflow {
+			return errors.New("transaction outputs overflow")
+		}
+	}
+	required, underflow := required.SubWithUnderflow(hostFunding)
+	if underflow {
+		return fmt.Errorf("host funding %d exceeds the transaction's outputs", hostFunding)
+	} else if !funded.Equals(required) {
+		return fmt.Errorf("renter funded %d, expected %d", funded, required)
 	}
 	return nil
 }
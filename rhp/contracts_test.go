@@ -0,0 +1,388 @@
+package rhp_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/rhp"
+	"lukechampine.com/frand"
+)
+
+func TestValidatePaymentRevisionStale(t *testing.T) {
+	current := types.FileContractRevision{
+		ParentID: frand.Entropy256(),
+		FileContract: types.FileContract{
+			RevisionNumber: 5,
+			WindowStart:    100,
+			WindowEnd:      200,
+			UnlockHash:     frand.Entropy256(),
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Address: frand.Entropy256(), Value: types.Siacoins(10)},
+				{Address: frand.Entropy256(), Value: types.Siacoins(20)},
+			},
+		},
+	}
+	current.MissedProofOutputs = []types.SiacoinOutput{
+		current.ValidProofOutputs[0],
+		current.ValidProofOutputs[1],
+		{Address: types.VoidAddress},
+	}
+
+	payment := types.Siacoins(1)
+	revise := func(revisionNumber uint64) types.FileContractRevision {
+		rev := current
+		rev.RevisionNumber = revisionNumber
+		rev.ValidProofOutputs = append([]types.SiacoinOutput(nil), current.ValidProofOutputs...)
+		rev.MissedProofOutputs = append([]types.SiacoinOutput(nil), current.MissedProofOutputs...)
+		rev.ValidProofOutputs[0].Value = rev.ValidProofOutputs[0].Value.Sub(payment)
+		rev.ValidProofOutputs[1].Value = rev.ValidProofOutputs[1].Value.Add(payment)
+		rev.MissedProofOutputs[0].Value = rev.MissedProofOutputs[0].Value.Sub(payment)
+		rev.MissedProofOutputs[1].Value = rev.MissedProofOutputs[1].Value.Add(payment)
+		return rev
+	}
+
+	if err := rhp.ValidatePaymentRevision(current, revise(current.RevisionNumber+1), payment); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		revision  types.FileContractRevision
+		duplicate bool
+	}{
+		{"stale", revise(current.RevisionNumber - 2), false},
+		{"same number", revise(current.RevisionNumber), false},
+		{"duplicate", current, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := rhp.ValidatePaymentRevision(current, test.revision, payment)
+			if !errors.Is(err, rhp.ErrStaleRevision) {
+				t.Fatalf("expected ErrStaleRevision, got %v", err)
+			}
+			var staleErr *rhp.StaleRevisionError
+			if !errors.As(err, &staleErr) {
+				t.Fatalf("expected StaleRevisionError, got %T", err)
+			} else if staleErr.Expected() != current.RevisionNumber+1 {
+				t.Fatalf("expected revision number %v, got %v", current.RevisionNumber+1, staleErr.Expected())
+			} else if staleErr.Received != test.revision.RevisionNumber {
+				t.Fatalf("expected received revision number %v, got %v", test.revision.RevisionNumber, staleErr.Received)
+			} else if staleErr.Duplicate != test.duplicate {
+				t.Fatalf("expected duplicate %v, got %v", test.duplicate, staleErr.Duplicate)
+			}
+		})
+	}
+}
+
+func TestValidateRevisionNumberExhausted(t *testing.T) {
+	limit := uint64(types.MaxRevisionNumber - rhp.RevisionNumberReserve)
+	current := types.FileContractRevision{
+		ParentID: frand.Entropy256(),
+		FileContract: types.FileContract{
+			RevisionNumber: limit - 2,
+			WindowStart:    100,
+			WindowEnd:      200,
+			UnlockHash:     frand.Entropy256(),
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Address: frand.Entropy256(), Value: types.Siacoins(10)},
+				{Address: frand.Entropy256(), Value: types.Siacoins(20)},
+			},
+		},
+	}
+	current.MissedProofOutputs = []types.SiacoinOutput{
+		current.ValidProofOutputs[0],
+		current.ValidProofOutputs[1],
+		{Address: types.VoidAddress},
+	}
+
+	payment := types.Siacoins(1)
+	revise := func(revisionNumber uint64) types.FileContractRevision {
+		rev := current
+		rev.RevisionNumber = revisionNumber
+		rev.ValidProofOutputs = append([]types.SiacoinOutput(nil), current.ValidProofOutputs...)
+		rev.MissedProofOutputs = append([]types.SiacoinOutput(nil), current.MissedProofOutputs...)
+		rev.ValidProofOutputs[0].Value = rev.ValidProofOutputs[0].Value.Sub(payment)
+		rev.ValidProofOutputs[1].Value = rev.ValidProofOutputs[1].Value.Add(payment)
+		rev.MissedProofOutputs[0].Value = rev.MissedProofOutputs[0].Value.Sub(payment)
+		rev.MissedProofOutputs[1].Value = rev.MissedProofOutputs[1].Value.Add(payment)
+		return rev
+	}
+
+	// the last revision number before the reserve is still accepted
+	if rhp.RevisionNumberExhausted(limit - 1) {
+		t.Fatal("expected revision number to be available")
+	} else if err := rhp.ValidatePaymentRevision(current, revise(limit-1), payment); err != nil {
+		t.Fatal(err)
+	}
+
+	// revision numbers in the reserve are rejected
+	for _, n := range []uint64{limit, limit + 1, types.MaxRevisionNumber - 1} {
+		if !rhp.RevisionNumberExhausted(n) {
+			t.Fatalf("expected revision number %v to be exhausted", n)
+		}
+		err := rhp.ValidatePaymentRevision(current, revise(n), payment)
+		if !errors.Is(err, rhp.ErrRevisionNumberExhausted) {
+			t.Fatalf("expected ErrRevisionNumberExhausted for revision %v, got %v", n, err)
+		} else if code, ok := rhp.ErrorCode(err); !ok || code != rhp.CodeRevisionNumberExhausted {
+			t.Fatalf("expected code %v, got %v", rhp.CodeRevisionNumberExhausted, code)
+		}
+	}
+
+	// stale revisions are still reported as stale
+	exhausted := current
+	exhausted.RevisionNumber = types.MaxRevisionNumber - 1
+	if err := rhp.ValidatePaymentRevision(exhausted, revise(exhausted.RevisionNumber), payment); !errors.Is(err, rhp.ErrStaleRevision) {
+		t.Fatalf("expected ErrStaleRevision, got %v", err)
+	}
+
+	// the contract can still be cleared so that it can be renewed
+	clearing, err := rhp.ClearingRevision(exhausted, []types.Currency{types.Siacoins(10), types.Siacoins(20)})
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := rhp.ValidateClearingRevision(exhausted, clearing, types.ZeroCurrency); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidationErrorCodes(t *testing.T) {
+	current := types.FileContractRevision{
+		ParentID: frand.Entropy256(),
+		FileContract: types.FileContract{
+			RevisionNumber: 5,
+			WindowStart:    100,
+			WindowEnd:      200,
+			UnlockHash:     frand.Entropy256(),
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Address: frand.Entropy256(), Value: types.Siacoins(10)},
+				{Address: frand.Entropy256(), Value: types.Siacoins(20)},
+			},
+		},
+	}
+	current.MissedProofOutputs = []types.SiacoinOutput{
+		current.ValidProofOutputs[0],
+		current.ValidProofOutputs[1],
+		{Address: types.VoidAddress},
+	}
+
+	// revise returns the next revision of the current contract modified by
+	// fn
+	revise := func(fn func(*types.FileContractRevision)) types.FileContractRevision {
+		rev := current
+		rev.RevisionNumber++
+		rev.ValidProofOutputs = append([]types.SiacoinOutput(nil), current.ValidProofOutputs...)
+		rev.MissedProofOutputs = append([]types.SiacoinOutput(nil), current.MissedProofOutputs...)
+		if fn != nil {
+			fn(&rev)
+		}
+		return rev
+	}
+	// move transfers amount between two outputs
+	move := func(outputs []types.SiacoinOutput, from, to int, amount types.Currency) {
+		outputs[from].Value = outputs[from].Value.Sub(amount)
+		outputs[to].Value = outputs[to].Value.Add(amount)
+	}
+	// pay transfers amount from the renter's outputs to the host's
+	pay := func(amount types.Currency) func(*types.FileContractRevision) {
+		return func(rev *types.FileContractRevision) {
+			move(rev.ValidProofOutputs, 0, 1, amount)
+			move(rev.MissedProofOutputs, 0, 1, amount)
+		}
+	}
+	// spend transfers amount from the renter's outputs to the host's valid
+	// output and the void output, as a revision paying for an RPC does
+	spend := func(amount types.Currency) func(*types.FileContractRevision) {
+		return func(rev *types.FileContractRevision) {
+			move(rev.ValidProofOutputs, 0, 1, amount)
+			move(rev.MissedProofOutputs, 0, 2, amount)
+		}
+	}
+
+	locked := current
+	locked.RevisionNumber = types.MaxRevisionNumber
+	clearing, err := rhp.ClearingRevision(current, []types.Currency{types.Siacoins(9), types.Siacoins(21)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notMax := clearing
+	notMax.RevisionNumber = current.RevisionNumber + 1
+
+	tests := []struct {
+		name string
+		fn   func() error
+		code types.Specifier
+	}{
+		{"stale revision", func() error {
+			return rhp.ValidatePaymentRevision(current, current, types.ZeroCurrency)
+		}, rhp.CodeStaleRevision},
+		{"revise stale", func() error {
+			_, err := rhp.Revise(current, current.RevisionNumber, []types.Currency{types.Siacoins(10), types.Siacoins(20)}, []types.Currency{types.Siacoins(10), types.Siacoins(20), types.ZeroCurrency})
+			return err
+		}, rhp.CodeStaleRevision},
+		{"contract locked", func() error {
+			_, err := rhp.Revise(locked, types.MaxRevisionNumber, nil, nil)
+			return err
+		}, rhp.CodeContractLocked},
+		{"wrong output count", func() error {
+			_, err := rhp.Revise(current, current.RevisionNumber+1, []types.Currency{types.Siacoins(30)}, nil)
+			return err
+		}, rhp.CodeInvalidOutputs},
+		{"window changed", func() error {
+			return rhp.ValidatePaymentRevision(current, revise(func(rev *types.FileContractRevision) { rev.WindowStart++ }), types.ZeroCurrency)
+		}, rhp.CodeImmutableField},
+		{"address changed", func() error {
+			return rhp.ValidatePaymentRevision(current, revise(func(rev *types.FileContractRevision) {
+				rev.ValidProofOutputs[1].Address = frand.Entropy256()
+			}), types.ZeroCurrency)
+		}, rhp.CodeImmutableField},
+		{"payout changed", func() error {
+			return rhp.ValidatePaymentRevision(current, revise(func(rev *types.FileContractRevision) {
+				rev.ValidProofOutputs[1].Value = rev.ValidProofOutputs[1].Value.Add(types.Siacoins(1))
+			}), types.ZeroCurrency)
+		}, rhp.CodeInvalidOutputs},
+		{"renter payout increased", func() error {
+			_, _, err := rhp.ValidateRevision(current, revise(func(rev *types.FileContractRevision) {
+				move(rev.ValidProofOutputs, 1, 0, types.Siacoins(1))
+				move(rev.MissedProofOutputs, 1, 0, types.Siacoins(1))
+			}), types.ZeroCurrency, types.ZeroCurrency)
+			return err
+		}, rhp.CodeInvalidPayout},
+		{"insufficient funds", func() error {
+			_, _, err := rhp.ValidateRevision(current, revise(spend(types.Siacoins(1))), types.Siacoins(100), types.ZeroCurrency)
+			return err
+		}, rhp.CodeInsufficientFunds},
+		{"insufficient collateral", func() error {
+			_, _, err := rhp.ValidateRevision(current, revise(spend(types.Siacoins(1))), types.Siacoins(1), types.Siacoins(100))
+			return err
+		}, rhp.CodeInsufficientCollateral},
+		{"insufficient payment", func() error {
+			_, _, err := rhp.ValidateRevision(current, revise(spend(types.Siacoins(1))), types.Siacoins(2), types.ZeroCurrency)
+			return err
+		}, rhp.CodeInsufficientPayment},
+		{"excessive burn", func() error {
+			_, _, err := rhp.ValidateRevision(current, revise(func(rev *types.FileContractRevision) {
+				move(rev.MissedProofOutputs, 1, 2, types.Siacoins(2))
+			}), types.ZeroCurrency, types.Siacoins(1))
+			return err
+		}, rhp.CodeExcessiveBurn},
+		{"payment mismatch", func() error {
+			return rhp.ValidatePaymentRevision(current, revise(pay(types.Siacoins(1))), types.Siacoins(2))
+		}, rhp.CodeInvalidTransfer},
+		{"clearing revision number", func() error {
+			_, err := rhp.ValidateClearingRevision(current, notMax, types.ZeroCurrency)
+			return err
+		}, rhp.CodeInvalidRevisionNumber},
+		{"clearing payment", func() error {
+			_, err := rhp.ValidateClearingRevision(current, clearing, types.Siacoins(2))
+			return err
+		}, rhp.CodeInsufficientPayment},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.fn()
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			// the code should survive wrapping by the session handlers
+			code, ok := rhp.ErrorCode(fmt.Errorf("failed to validate revision: %w", err))
+			if !ok {
+				t.Fatalf("expected validation error, got %v", err)
+			} else if code != test.code {
+				t.Fatalf("expected code %q, got %q (%v)", test.code, code, err)
+			}
+		})
+	}
+
+	if _, ok := rhp.ErrorCode(errors.New("foo")); ok {
+		t.Fatal("expected no code for non-validation error")
+	}
+}
+
+func TestValidateFunding(t *testing.T) {
+	hostKey := types.GeneratePrivateKey().PublicKey()
+	renterKey := types.GeneratePrivateKey().PublicKey()
+	hostAddr := types.StandardUnlockHash(hostKey)
+	renterAddr := types.StandardUnlockHash(renterKey)
+
+	collateral := types.Siacoins(100)
+	hostInputID := types.SiacoinOutputID(frand.Entropy256())
+	inputValues := map[types.SiacoinOutputID]types.Currency{
+		hostInputID: types.Siacoins(150),
+	}
+
+	// the renter's input is created by a parent transaction
+	parent := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{
+			{Address: renterAddr, Value: types.Siacoins(200)},
+		},
+	}
+	parents := []types.Transaction{parent}
+
+	// buildTxn returns a formation transaction with one renter input and
+	// output followed by the host's input and change output. The renter
+	// funds 200 SC: a 1 SC output, a 1 SC fee, and 198 SC of the payout.
+	buildTxn := func(change types.Currency) types.Transaction {
+		return types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{
+				{ParentID: parent.SiacoinOutputID(0), UnlockConditions: types.StandardUnlockConditions(renterKey)},
+				{ParentID: hostInputID, UnlockConditions: types.StandardUnlockConditions(hostKey)},
+			},
+			SiacoinOutputs: []types.SiacoinOutput{
+				{Address: renterAddr, Value: types.Siacoins(1)},
+				{Address: hostAddr, Value: change},
+			},
+			FileContracts: []types.FileContract{
+				{Payout: types.Siacoins(298)},
+			},
+			MinerFees: []types.Currency{types.Siacoins(1)},
+		}
+	}
+
+	// the host funds exactly its collateral
+	if err := rhp.ValidateFunding(parents, buildTxn(types.Siacoins(50)), 1, 1, hostAddr, inputValues, collateral); err != nil {
+		t.Fatal(err)
+	}
+
+	// the host over-funds the transaction
+	if err := rhp.ValidateFunding(parents, buildTxn(types.Siacoins(40)), 1, 1, hostAddr, inputValues, collateral); err == nil {
+		t.Fatal("expected over-funded transaction to be rejected")
+	}
+
+	// the host under-funds the transaction
+	if err := rhp.ValidateFunding(parents, buildTxn(types.Siacoins(60)), 1, 1, hostAddr, inputValues, collateral); err == nil {
+		t.Fatal("expected under-funded transaction to be rejected")
+	}
+
+	// the host's change is sent to the renter
+	txn := buildTxn(types.Siacoins(50))
+	txn.SiacoinOutputs[1].Address = renterAddr
+	if err := rhp.ValidateFunding(parents, txn, 1, 1, hostAddr, inputValues, collateral); err == nil {
+		t.Fatal("expected change paid to the renter to be rejected")
+	}
+
+	// the renter spends a host output as its own input
+	txn = buildTxn(types.Siacoins(50))
+	txn.SiacoinInputs[0].UnlockConditions = types.StandardUnlockConditions(hostKey)
+	if err := rhp.ValidateFunding(parents, txn, 1, 1, hostAddr, inputValues, collateral); err == nil {
+		t.Fatal("expected renter input spending a host output to be rejected")
+	}
+
+	// the host's input value is unknown
+	if err := rhp.ValidateFunding(parents, buildTxn(types.Siacoins(50)), 1, 1, hostAddr, nil, collateral); err == nil {
+		t.Fatal("expected unknown host input to be rejected")
+	}
+
+	// the renter under-funds the payout
+	txn = buildTxn(types.Siacoins(50))
+	txn.FileContracts[0].Payout = types.Siacoins(299)
+	if err := rhp.ValidateFunding(parents, txn, 1, 1, hostAddr, inputValues, collateral); err == nil {
+		t.Fatal("expected under-funded renter inputs to be rejected")
+	}
+
+	// the renter's input is not created by a parent, so its funding is
+	// checked when the transaction set is broadcast
+	if err := rhp.ValidateFunding(nil, txn, 1, 1, hostAddr, inputValues, collateral); err != nil {
+		t.Fatal(err)
+	}
+}
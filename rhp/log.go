@@ -0,0 +1,18 @@
+package rhp
+
+import (
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// SessionLogger returns a child of log annotated with the fields used to
+// correlate every entry emitted during a single RHP session.
+func SessionLogger(log *zap.Logger, sessionID UID, peerAddr string) *zap.Logger {
+	return log.With(zap.Stringer("sessionID", sessionID), zap.String("peerAddress", peerAddr))
+}
+
+// ContractLogger returns a child of log annotated with a contract ID. It
+// should be used as soon as the contract an RPC operates on is known.
+func ContractLogger(log *zap.Logger, id types.FileContractID) *zap.Logger {
+	return log.With(zap.Stringer("contractID", id))
+}
@@ -0,0 +1,38 @@
+package rhp
+
+import "net"
+
+// NetworkType identifies the network a connection was received on.
+type NetworkType string
+
+// network types
+const (
+	NetworkClearnet NetworkType = "clearnet"
+	NetworkTor      NetworkType = "tor"
+)
+
+// A NetworkTagger returns the network a connection was received on.
+type NetworkTagger func(net.Conn) NetworkType
+
+// ListenerTagger returns a NetworkTagger that tags connections accepted by
+// any of the listeners as network. All other connections are tagged as
+// clearnet. Hidden service connections are forwarded by the Tor daemon to a
+// dedicated local listener, so they are identified by the address they were
+// accepted on rather than their remote address.
+func ListenerTagger(network NetworkType, listeners ...net.Listener) NetworkTagger {
+	return func(conn net.Conn) NetworkType {
+		local, ok := conn.LocalAddr().(*net.TCPAddr)
+		if !ok {
+			return NetworkClearnet
+		}
+		for _, l := range listeners {
+			addr, ok := l.Addr().(*net.TCPAddr)
+			if !ok || addr.Port != local.Port {
+				continue
+			} else if addr.IP.IsUnspecified() || addr.IP.Equal(local.IP) {
+				return network
+			}
+		}
+		return NetworkClearnet
+	}
+}
@@ -0,0 +1,49 @@
+package rhp
+
+import (
+	"errors"
+	"fmt"
+
+	"go.sia.tech/core/types"
+)
+
+// ErrPriceOverflow is returned when a price or cost calculation exceeds the
+// maximum value of a types.Currency.
+var ErrPriceOverflow = errors.New("price calculation overflows")
+
+// MulCurrency returns c multiplied by each of the factors. ErrPriceOverflow is
+// returned instead of a wrapped value if the product overflows.
+func MulCurrency(c types.Currency, factors ...uint64) (types.Currency, error) {
+	for _, f := range factors {
+		var overflow bool
+		c, overflow = c.Mul64WithOverflow(f)
+		if overflow {
+			return types.ZeroCurrency, ErrPriceOverflow
+		}
+	}
+	return c, nil
+}
+
+// StorageCost returns the cost of storing size bytes for duration blocks.
+// price is in hastings per byte per block.
+func StorageCost(price types.Currency, size, duration uint64) (types.Currency, error) {
+	cost, err := MulCurrency(price, size, duration)
+	if err != nil {
+		return types.ZeroCurrency, fmt.Errorf("storage cost of %d bytes for %d blocks at %v/byte/block: %w", size, duration, price, err)
+	}
+	return cost, nil
+}
+
+// CollateralPrice returns the collateral in hastings per byte per block the
+// host risks for a storage price of storagePrice hastings per byte per block.
+// The multiplier is applied with a precision of 1/1000.
+func CollateralPrice(storagePrice types.Currency, multiplier float64) (types.Currency, error) {
+	if multiplier < 0 {
+		return types.ZeroCurrency, errors.New("collateral multiplier must not be negative")
+	}
+	collateral, err := MulCurrency(storagePrice, uint64(multiplier*1000))
+	if err != nil {
+		return types.ZeroCurrency, fmt.Errorf("collateral price for %v/byte/block at %gx: %w", storagePrice, multiplier, err)
+	}
+	return collateral.Div64(1000), nil
+}
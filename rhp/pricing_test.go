@@ -0,0 +1,78 @@
+package rhp_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/rhp"
+)
+
+func TestStorageCostOverflow(t *testing.T) {
+	// the largest price that can be multiplied by size * duration without
+	// overflowing
+	const size, duration = 1 << 40, 1 << 20
+	max := types.MaxCurrency.Div64(size).Div64(duration)
+
+	cost, err := rhp.StorageCost(max, size, duration)
+	if err != nil {
+		t.Fatal(err)
+	} else if expected := max.Mul64(size).Mul64(duration); !cost.Equals(expected) {
+		t.Fatalf("expected cost %v, got %v", expected, cost)
+	}
+
+	// one more hasting per byte per block overflows. The error includes the
+	// formatted price.
+	price := max.Add(types.NewCurrency64(1))
+	if _, err := rhp.StorageCost(price, size, duration); !errors.Is(err, rhp.ErrPriceOverflow) {
+		t.Fatalf("expected ErrPriceOverflow, got %v", err)
+	} else if !strings.Contains(err.Error(), price.String()+"/byte/block") {
+		t.Fatalf("expected error to include price %v, got %v", price, err)
+	}
+
+	// overflow in the second factor should also be detected
+	if _, err := rhp.StorageCost(types.MaxCurrency.Div64(2), 2, 2); !errors.Is(err, rhp.ErrPriceOverflow) {
+		t.Fatalf("expected ErrPriceOverflow, got %v", err)
+	}
+
+	// the full range of each factor is usable with a price of 1 H
+	cost, err = rhp.StorageCost(types.NewCurrency64(1), math.MaxUint64, math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	} else if expected := types.NewCurrency64(math.MaxUint64).Mul64(math.MaxUint64); !cost.Equals(expected) {
+		t.Fatalf("expected cost %v, got %v", expected, cost)
+	}
+
+	// a zero size or duration is always free
+	if cost, err := rhp.StorageCost(types.MaxCurrency, 0, math.MaxUint64); err != nil {
+		t.Fatal(err)
+	} else if !cost.IsZero() {
+		t.Fatalf("expected zero cost, got %v", cost)
+	}
+}
+
+func TestCollateralPriceOverflow(t *testing.T) {
+	collateral, err := rhp.CollateralPrice(types.NewCurrency64(1000), 2.5)
+	if err != nil {
+		t.Fatal(err)
+	} else if !collateral.Equals(types.NewCurrency64(2500)) {
+		t.Fatalf("expected 2500 H, got %v", collateral)
+	}
+
+	// the multiplier is applied with a precision of 1/1000, so the largest
+	// price for a multiplier of 1 is MaxCurrency / 1000
+	max := types.MaxCurrency.Div64(1000)
+	if collateral, err := rhp.CollateralPrice(max, 1); err != nil {
+		t.Fatal(err)
+	} else if !collateral.Equals(max) {
+		t.Fatalf("expected %v, got %v", max, collateral)
+	}
+
+	if _, err := rhp.CollateralPrice(max.Add(types.NewCurrency64(1)), 1); !errors.Is(err, rhp.ErrPriceOverflow) {
+		t.Fatalf("expected ErrPriceOverflow, got %v", err)
+	} else if _, err := rhp.CollateralPrice(types.NewCurrency64(1), -1); err == nil {
+		t.Fatal("expected negative multiplier to be rejected")
+	}
+}
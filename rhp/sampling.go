@@ -0,0 +1,237 @@
+package rhp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// sampleKey groups log entries that are considered duplicates: the same
+	// message and root error logged by the same logger for the same peer.
+	sampleKey struct {
+		peer    string
+		logger  string
+		message string
+		err     string
+	}
+
+	sampleEntry struct {
+		core       zapcore.Core
+		level      zapcore.Level
+		start      time.Time
+		count      int
+		suppressed int
+	}
+
+	// errorSampler tracks repeated entries for every core derived from the
+	// same sampledCore.
+	errorSampler struct {
+		interval time.Duration
+		first    int
+
+		mu        sync.Mutex
+		lastSweep time.Time
+		entries   map[sampleKey]*sampleEntry
+		// timer writes the summaries of suppressed entries when their
+		// interval ends, even if no further entries are logged.
+		timer *time.Timer
+	}
+
+	// A sampledCore wraps a zapcore.Core and limits the number of duplicate
+	// warning and error entries that are written in each interval.
+	sampledCore struct {
+		zapcore.Core
+
+		peer    string
+		sampler *errorSampler
+	}
+)
+
+// rootError returns the message of the innermost error in err's chain. Wrapped
+// errors usually include request-specific details, so the root error is a
+// better indicator of the error's type.
+func rootError(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err.Error()
+		}
+		err = next
+	}
+}
+
+// peerHost strips the port from a peer address so that every connection from
+// the same peer shares a key.
+func peerHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// summarize writes a summary of the entries suppressed during an interval.
+func summarize(key sampleKey, e *sampleEntry) {
+	ent := zapcore.Entry{
+		Level:      e.level,
+		Time:       time.Now(),
+		LoggerName: key.logger,
+		Message:    "suppressed repeated log entries",
+	}
+	fields := []zapcore.Field{
+		zap.String("message", key.message),
+		zap.Int("suppressed", e.suppressed),
+		zap.Duration("interval", time.Since(e.start)),
+	}
+	if key.err != "" {
+		fields = append(fields, zap.String("error", key.err))
+	}
+	e.core.Write(ent, fields)
+}
+
+// sweep writes summaries for and removes every entry whose interval has
+// ended. If force is true, every entry is flushed.
+func (s *errorSampler) sweep(now time.Time, force bool) {
+	for key, e := range s.entries {
+		if !force && now.Sub(e.start) < s.interval {
+			continue
+		}
+		if e.suppressed > 0 {
+			summarize(key, e)
+		}
+		delete(s.entries, key)
+	}
+	s.lastSweep = now
+}
+
+// flushExpired writes the summaries of every entry whose interval has ended
+// and schedules the next flush.
+func (s *errorSampler) flushExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.timer = nil
+	s.sweep(now, false)
+
+	var next time.Time
+	for _, e := range s.entries {
+		if end := e.start.Add(s.interval); e.suppressed > 0 && (next.IsZero() || end.Before(next)) {
+			next = end
+		}
+	}
+	if !next.IsZero() {
+		s.timer = time.AfterFunc(next.Sub(now), s.flushExpired)
+	}
+}
+
+// allow returns true if an entry with the given key should be written.
+func (s *errorSampler) allow(key sampleKey, level zapcore.Level, core zapcore.Core) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= s.interval {
+		s.sweep(now, false)
+	}
+
+	e, ok := s.entries[key]
+	if !ok || now.Sub(e.start) >= s.interval {
+		if ok && e.suppressed > 0 {
+			summarize(key, e)
+		}
+		e = &sampleEntry{start: now}
+		s.entries[key] = e
+	}
+	e.core = core
+	e.level = level
+	e.count++
+	if e.count <= s.first {
+		return true
+	}
+	e.suppressed++
+	if s.timer == nil {
+		s.timer = time.AfterFunc(e.start.Add(s.interval).Sub(now), s.flushExpired)
+	}
+	return false
+}
+
+// With implements zapcore.Core
+func (c *sampledCore) With(fields []zapcore.Field) zapcore.Core {
+	peer := c.peer
+	for _, f := range fields {
+		if f.Key == "peerAddress" && f.Type == zapcore.StringType {
+			peer = peerHost(f.String)
+		}
+	}
+	return &sampledCore{
+		Core:    c.Core.With(fields),
+		peer:    peer,
+		sampler: c.sampler,
+	}
+}
+
+// Check implements zapcore.Core
+func (c *sampledCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core
+func (c *sampledCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < zapcore.WarnLevel {
+		return c.Core.Write(ent, fields)
+	}
+
+	key := sampleKey{
+		peer:    c.peer,
+		logger:  ent.LoggerName,
+		message: ent.Message,
+	}
+	for _, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		} else if err, ok := f.Interface.(error); ok && err != nil {
+			key.err = rootError(err)
+			break
+		}
+	}
+	if !c.sampler.allow(key, ent.Level, c.Core) {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// Sync implements zapcore.Core. Summaries of any suppressed entries are
+// written before the underlying core is synced.
+func (c *sampledCore) Sync() error {
+	c.sampler.mu.Lock()
+	c.sampler.sweep(time.Now(), true)
+	c.sampler.mu.Unlock()
+	return c.Core.Sync()
+}
+
+// NewSampledCore wraps core to limit repetitive warning and error entries,
+// such as the same RPC error returned to the same peer during a flood of
+// requests. Only the first entries of each kind are written during an
+// interval; the rest are counted and collapsed into a summary entry that is
+// written when the interval ends. Entries below the warning level are never
+// sampled.
+func NewSampledCore(core zapcore.Core, interval time.Duration, first int) zapcore.Core {
+	return &sampledCore{
+		Core: core,
+		sampler: &errorSampler{
+			interval:  interval,
+			first:     first,
+			lastSweep: time.Now(),
+			entries:   make(map[sampleKey]*sampleEntry),
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package rhp_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.sia.tech/hostd/rhp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSampledCore(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	errInsufficientFunds := errors.New("insufficient funds")
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(rhp.NewSampledCore(core, interval, 3))
+
+	flood := rhp.SessionLogger(log, rhp.UID{1}, "1.2.3.4:1000")
+	for i := 0; i < 100; i++ {
+		// wrapped errors from the same peer with the same root are duplicates
+		flood.Warn("RPC failed", zap.Error(fmt.Errorf("request %d: %w", i, errInsufficientFunds)))
+	}
+	// a new connection from the same peer should share the same key
+	rhp.SessionLogger(log, rhp.UID{2}, "1.2.3.4:1001").Warn("RPC failed", zap.Error(errInsufficientFunds))
+
+	if n := logs.FilterMessage("RPC failed").Len(); n != 3 {
+		t.Fatalf("expected 3 entries, got %d", n)
+	}
+
+	// entries from other peers, info entries, and different errors are not
+	// sampled with the flood
+	rhp.SessionLogger(log, rhp.UID{3}, "5.6.7.8:1000").Warn("RPC failed", zap.Error(errInsufficientFunds))
+	flood.Warn("RPC failed", zap.Error(errors.New("invalid signature")))
+	for i := 0; i < 10; i++ {
+		flood.Info("RPC success")
+	}
+	if n := logs.FilterMessage("RPC failed").Len(); n != 5 {
+		t.Fatalf("expected 5 entries, got %d", n)
+	} else if n := logs.FilterMessage("RPC success").Len(); n != 10 {
+		t.Fatalf("expected 10 entries, got %d", n)
+	}
+
+	// after the interval, the suppressed entries should be summarized
+	// without waiting for another entry
+	time.Sleep(2 * interval)
+	summaries := logs.FilterMessage("suppressed repeated log entries").AllUntimed()
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 summary, got %d", len(summaries))
+	} else if fields := summaries[0].ContextMap(); fields["suppressed"] != int64(98) {
+		t.Fatalf("expected 98 suppressed entries, got %v", fields["suppressed"])
+	} else if fields["error"] != errInsufficientFunds.Error() {
+		t.Fatalf("expected error %q, got %v", errInsufficientFunds, fields["error"])
+	}
+
+	// new entries should be written once the interval has ended
+	flood.Warn("RPC failed", zap.Error(errInsufficientFunds))
+	if n := logs.FilterMessage("RPC failed").Len(); n != 6 {
+		t.Fatalf("expected 6 entries, got %d", n)
+	}
+
+	// syncing should flush any remaining summaries
+	for i := 0; i < 10; i++ {
+		flood.Warn("RPC failed", zap.Error(errInsufficientFunds))
+	}
+	if err := log.Sync(); err != nil {
+		t.Fatal(err)
+	} else if n := logs.FilterMessage("suppressed repeated log entries").Len(); n != 2 {
+		t.Fatalf("expected 2 summaries, got %d", n)
+	}
+}
@@ -31,8 +31,10 @@ func contractUnlockConditions(hostKey, renterKey types.UnlockKey) types.UnlockCo
 }
 
 // validateContractFormation verifies that the new contract is valid given the
-// host's settings.
-func validateContractFormation(fc types.FileContract, hostKey, renterKey types.UnlockKey, currentHeight uint64, settings rhp2.HostSettings) (types.Currency, error) {
+// host's settings. Contracts with a host valid payout less than minHostPayout
+// are rejected. The contract's proof window must start at least leadTime
+// blocks after the current height.
+func validateContractFormation(fc types.FileContract, hostKey, renterKey types.UnlockKey, currentHeight, leadTime uint64, settings rhp2.HostSettings, minHostPayout types.Currency) (types.Currency, error) {
 	switch {
 	case fc.Filesize != 0:
 		return types.ZeroCurrency, errors.New("initial filesize should be 0")
@@ -40,7 +42,7 @@ func validateContractFormation(fc types.FileContract, hostKey, renterKey types.U
 		return types.ZeroCurrency, errors.New("initial revision number should be 0")
 	case fc.FileMerkleRoot != types.Hash256{}:
 		return types.ZeroCurrency, errors.New("initial Merkle root should be empty")
-	case fc.WindowStart < currentHeight+settings.WindowSize:
+	case fc.WindowStart < currentHeight+leadTime:
 		return types.ZeroCurrency, errors.New("contract ends too soon to safely submit the contract transaction")
 	case fc.WindowStart > currentHeight+settings.MaxDuration:
 		return types.ZeroCurrency, errors.New("contract duration is too long")
@@ -60,6 +62,8 @@ func validateContractFormation(fc types.FileContract, hostKey, renterKey types.U
 		return types.ZeroCurrency, errors.New("void output should have value 0")
 	case fc.ValidHostPayout().Cmp(settings.ContractPrice) < 0:
 		return types.ZeroCurrency, errors.New("host valid payout is too small")
+	case fc.ValidHostPayout().Cmp(minHostPayout) < 0:
+		return types.ZeroCurrency, fmt.Errorf("host valid payout %v is less than the host's minimum payout %v", fc.ValidHostPayout(), minHostPayout)
 	case !fc.ValidHostPayout().Equals(fc.MissedHostPayout()):
 		return types.ZeroCurrency, errors.New("host valid and missed outputs must be equal")
 	case fc.ValidHostPayout().Cmp(settings.MaxCollateral) > 0:
@@ -0,0 +1,125 @@
+package rhp
+
+import (
+	"strings"
+	"testing"
+
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+)
+
+func TestValidateContractFormationMinPayout(t *testing.T) {
+	hostKey := types.GeneratePrivateKey().PublicKey()
+	renterKey := types.GeneratePrivateKey().PublicKey()
+	hostAddr := types.StandardUnlockHash(hostKey)
+
+	settings := rhp2.HostSettings{
+		Address:       hostAddr,
+		ContractPrice: types.Siacoins(1),
+		MaxCollateral: types.Siacoins(1000),
+		MaxDuration:   1000,
+		WindowSize:    10,
+	}
+
+	formContract := func(hostPayout types.Currency) types.FileContract {
+		return types.FileContract{
+			WindowStart: 100,
+			WindowEnd:   110,
+			UnlockHash:  types.Hash256(contractUnlockConditions(hostKey.UnlockKey(), renterKey.UnlockKey()).UnlockHash()),
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Address: types.StandardUnlockHash(renterKey), Value: types.Siacoins(10)},
+				{Address: hostAddr, Value: hostPayout},
+			},
+			MissedProofOutputs: []types.SiacoinOutput{
+				{Address: types.StandardUnlockHash(renterKey), Value: types.Siacoins(10)},
+				{Address: hostAddr, Value: hostPayout},
+				{Address: types.VoidAddress},
+			},
+		}
+	}
+
+	minPayout := types.Siacoins(5)
+
+	// a payout at the floor should be accepted
+	collateral, err := validateContractFormation(formContract(minPayout), hostKey.UnlockKey(), renterKey.UnlockKey(), 0, settings.WindowSize, settings, minPayout)
+	if err != nil {
+		t.Fatal(err)
+	} else if !collateral.Equals(minPayout.Sub(settings.ContractPrice)) {
+		t.Fatalf("expected collateral %v, got %v", minPayout.Sub(settings.ContractPrice), collateral)
+	}
+
+	// a payout below the floor should be rejected, even if it covers the
+	// contract price
+	_, err = validateContractFormation(formContract(minPayout.Sub(types.NewCurrency64(1))), hostKey.UnlockKey(), renterKey.UnlockKey(), 0, settings.WindowSize, settings, minPayout)
+	if err == nil {
+		t.Fatal("expected payout below the floor to be rejected")
+	} else if !strings.Contains(err.Error(), "minimum payout") {
+		t.Fatalf("expected minimum payout error, got %v", err)
+	}
+
+	// a zero floor should not reject the contract
+	if _, err := validateContractFormation(formContract(settings.ContractPrice), hostKey.UnlockKey(), renterKey.UnlockKey(), 0, settings.WindowSize, settings, types.ZeroCurrency); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateContractFormationLeadTime(t *testing.T) {
+	hostKey := types.GeneratePrivateKey().PublicKey()
+	renterKey := types.GeneratePrivateKey().PublicKey()
+	hostAddr := types.StandardUnlockHash(hostKey)
+
+	settings := rhp2.HostSettings{
+		Address:       hostAddr,
+		ContractPrice: types.Siacoins(1),
+		MaxCollateral: types.Siacoins(1000),
+		MaxDuration:   1000,
+		WindowSize:    10,
+	}
+
+	formContract := func(windowStart, windowEnd uint64) types.FileContract {
+		return types.FileContract{
+			WindowStart: windowStart,
+			WindowEnd:   windowEnd,
+			UnlockHash:  types.Hash256(contractUnlockConditions(hostKey.UnlockKey(), renterKey.UnlockKey()).UnlockHash()),
+			ValidProofOutputs: []types.SiacoinOutput{
+				{Address: types.StandardUnlockHash(renterKey), Value: types.Siacoins(10)},
+				{Address: hostAddr, Value: settings.ContractPrice},
+			},
+			MissedProofOutputs: []types.SiacoinOutput{
+				{Address: types.StandardUnlockHash(renterKey), Value: types.Siacoins(10)},
+				{Address: hostAddr, Value: settings.ContractPrice},
+				{Address: types.VoidAddress},
+			},
+		}
+	}
+
+	const currentHeight = 100
+	validate := func(fc types.FileContract, leadTime uint64) error {
+		_, err := validateContractFormation(fc, hostKey.UnlockKey(), renterKey.UnlockKey(), currentHeight, leadTime, settings, types.ZeroCurrency)
+		return err
+	}
+
+	// with a lead time equal to the window size, a contract starting one
+	// window after the current height is accepted
+	if err := validate(formContract(currentHeight+10, currentHeight+20), settings.WindowSize); err != nil {
+		t.Fatal(err)
+	}
+
+	// a larger lead time should reject the same contract without changing
+	// the required proof window length
+	if err := validate(formContract(currentHeight+10, currentHeight+20), 50); err == nil || !strings.Contains(err.Error(), "ends too soon") {
+		t.Fatalf("expected contract to end too soon, got %v", err)
+	} else if err := validate(formContract(currentHeight+50, currentHeight+60), 50); err != nil {
+		t.Fatal(err)
+	} else if err := validate(formContract(currentHeight+50, currentHeight+59), 50); err == nil || !strings.Contains(err.Error(), "proof window is too small") {
+		t.Fatalf("expected proof window to be too small, got %v", err)
+	}
+
+	// a smaller lead time should accept a contract starting before one
+	// window has passed, but still require a full proof window
+	if err := validate(formContract(currentHeight+5, currentHeight+15), 5); err != nil {
+		t.Fatal(err)
+	} else if err := validate(formContract(currentHeight+5, currentHeight+14), 5); err == nil || !strings.Contains(err.Error(), "proof window is too small") {
+		t.Fatalf("expected proof window to be too small, got %v", err)
+	}
+}
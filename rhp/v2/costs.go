@@ -0,0 +1,60 @@
+package rhp
+
+import (
+	rhp2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/rhp"
+)
+
+// rpcWriteCost returns the cost of the write actions. It matches
+// HostSettings.RPCWriteCost, but returns rhp.ErrPriceOverflow instead of
+// panicking or wrapping when a cost overflows.
+func rpcWriteCost(settings rhp2.HostSettings, actions []rhp2.RPCWriteAction, oldSectors, remainingDuration uint64, proof bool) (rhp2.RPCCost, error) {
+	// validate the actions using the core implementation. The prices are
+	// zeroed so its unchecked arithmetic cannot overflow.
+	if _, err := (rhp2.HostSettings{}).RPCWriteCost(actions, oldSectors, remainingDuration, proof); err != nil {
+		return rhp2.RPCCost{}, err
+	}
+
+	newSectors, appended := oldSectors, uint64(0)
+	for _, action := range actions {
+		switch action.Type {
+		case rhp2.RPCWriteActionAppend:
+			newSectors++
+			appended++
+		case rhp2.RPCWriteActionTrim:
+			newSectors -= action.A
+		}
+	}
+
+	cost := rhp2.RPCCost{
+		Base: settings.BaseRPCPrice,
+	}
+	var err error
+	if cost.Ingress, err = rhp.MulCurrency(settings.UploadBandwidthPrice, appended, rhp2.SectorSize); err != nil {
+		return rhp2.RPCCost{}, err
+	}
+	if newSectors > oldSectors {
+		additional := newSectors - oldSectors
+		if cost.Storage, err = rhp.MulCurrency(settings.StoragePrice, rhp2.SectorSize, additional, remainingDuration); err != nil {
+			return rhp2.RPCCost{}, err
+		} else if cost.Collateral, err = rhp.MulCurrency(settings.Collateral, rhp2.SectorSize, additional, remainingDuration); err != nil {
+			return rhp2.RPCCost{}, err
+		}
+	}
+	if proof {
+		proofSize := rhp2.DiffProofSize(actions, oldSectors)
+		if cost.Egress, err = rhp.MulCurrency(settings.DownloadBandwidthPrice, proofSize, 32); err != nil {
+			return rhp2.RPCCost{}, err
+		}
+	}
+	// RPCCost.Total does not check for overflow
+	total := cost.Base
+	for _, c := range []types.Currency{cost.Storage, cost.Ingress, cost.Egress} {
+		var overflow bool
+		if total, overflow = total.AddWithOverflow(c); overflow {
+			return rhp2.RPCCost{}, rhp.ErrPriceOverflow
+		}
+	}
+	return cost, nil
+}
@@ -0,0 +1,66 @@
+package rhp
+
+import (
+	"time"
+
+	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/rhp"
+)
+
+// A SessionHandlerOption configures a SessionHandler.
+type SessionHandlerOption func(*SessionHandler)
+
+// WithHandshakeTimeout sets the time a peer has to complete the protocol
+// handshake before its connection is closed. A timeout of 0 disables the
+// limit. The default is 30 seconds.
+func WithHandshakeTimeout(d time.Duration) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.handshakeTimeout = d
+	}
+}
+
+// WithNetworkTagger sets the function used to identify the network each
+// connection was received on. By default, all connections are clearnet.
+func WithNetworkTagger(tagger rhp.NetworkTagger) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.tagger = tagger
+	}
+}
+
+// WithNetworkPolicy overrides the host's contract acceptance and pricing for
+// renters connecting over the network.
+func WithNetworkPolicy(network rhp.NetworkType, policy settings.NetworkPolicy) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		if sh.policies == nil {
+			sh.policies = make(map[rhp.NetworkType]settings.NetworkPolicy)
+		}
+		sh.policies[network] = policy
+	}
+}
+
+// WithRenterPolicy adjusts contract acceptance and pricing for new contracts
+// based on the renter's contract history. By default, new and established
+// renters are treated the same.
+func WithRenterPolicy(policy settings.RenterPolicy) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.renterPolicy = &policy
+	}
+}
+
+// WithMinStorageHeadroom sets the number of free sectors the host must have
+// to form new contracts. While free storage is below the headroom, new
+// contracts are rejected and the host advertises that it is not accepting
+// contracts. The default of 0 disables the check.
+func WithMinStorageHeadroom(sectors uint64) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.minHeadroom = sectors
+	}
+}
+
+// WithContractLatencyRecorder records the time spent in each phase of
+// contract formation and renewal. By default, latency is not recorded.
+func WithContractLatencyRecorder(r ContractLatencyRecorder) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.latency = r
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"go.sia.tech/core/consensus"
@@ -13,7 +14,9 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/build"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/metrics"
 	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/internal/threadgroup"
 	"go.sia.tech/hostd/rhp"
 	"go.uber.org/zap"
@@ -23,6 +26,10 @@ import (
 const (
 	defaultBatchSize = 20 * (1 << 20) // 20 MiB
 
+	// defaultHandshakeTimeout is the time a peer has to complete the
+	// handshake before the connection is closed.
+	defaultHandshakeTimeout = 30 * time.Second
+
 	// Version is the current version of the RHP2 protocol.
 	Version = "1.6.0"
 )
@@ -47,11 +54,17 @@ type (
 
 		// SectorRoots returns the sector roots of the contract with the given ID.
 		SectorRoots(id types.FileContractID) ([]types.Hash256, error)
+		// RenterHistory returns the contract history of the renter with the
+		// given public key.
+		RenterHistory(renterKey types.PublicKey) (contracts.RenterHistory, error)
 	}
 
 	// A StorageManager manages the storage of sectors on disk.
 	StorageManager interface {
 		Usage() (used, total uint64, _ error)
+		// AvailableSectors returns the number of sectors that can be
+		// written to writable volumes.
+		AvailableSectors() (uint64, error)
 
 		// Write writes a sector to persistent storage. release should only be
 		// called after the contract roots have been committed to prevent the
@@ -61,17 +74,27 @@ type (
 		Read(root types.Hash256) (*[rhp2.SectorSize]byte, error)
 		// Sync syncs the data files of changed volumes.
 		Sync() error
+
+		// ReserveSectors reserves space for n sectors that will be written
+		// by a pending upload. The reservation must be released when the
+		// upload ends.
+		ReserveSectors(n uint64) (*storage.Reservation, error)
+
+		// Prefetcher returns a new prefetcher for a renter session.
+		Prefetcher() *storage.Prefetcher
 	}
 
 	// A ChainManager provides access to the current state of the blockchain.
 	ChainManager interface {
 		TipState() consensus.State
+		Synced() bool
 	}
 
 	// A Wallet manages funds and signs transactions
 	Wallet interface {
 		Address() types.Address
 		FundTransaction(txn *types.Transaction, amount types.Currency) ([]types.Hash256, func(), error)
+		OutputValues(ids []types.Hash256) (map[types.SiacoinOutputID]types.Currency, error)
 		SignTransaction(cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) error
 	}
 
@@ -85,9 +108,20 @@ type (
 	SettingsReporter interface {
 		DiscoveredRHP2Address() string
 		Settings() settings.Settings
+		// AdvertisedSettings returns the settings advertised to renters,
+		// with the host's dynamic policies, such as collateral scaling,
+		// applied.
+		AdvertisedSettings() settings.Settings
+		ContractsPaused() bool
 		BandwidthLimiters() (ingress, egress *rate.Limiter)
 	}
 
+	// A ContractLatencyRecorder records the time spent in each phase of
+	// contract formation and renewal.
+	ContractLatencyRecorder interface {
+		StartContractTimer(metrics.ContractOperation) *metrics.ContractTimer
+	}
+
 	// SessionReporter reports session metrics
 	SessionReporter interface {
 		StartSession(conn *rhp.Conn, proto string, version int) (sessionID rhp.UID, end func())
@@ -97,12 +131,29 @@ type (
 	// A SessionHandler handles the host side of the renter-host protocol and
 	// manages renter sessions
 	SessionHandler struct {
+		rejectedHandshakes uint64 // ensure 64-bit alignment on 32-bit systems
+
 		privateKey types.PrivateKey
 		rhp3Port   string
 
-		listener net.Listener
-		monitor  rhp.DataMonitor
-		tg       *threadgroup.ThreadGroup
+		handshakeTimeout time.Duration
+		// minHeadroom is the number of free sectors below which new
+		// contracts are rejected.
+		minHeadroom uint64
+
+		// tagger identifies the network of each connection. policies
+		// override the host's settings for renters connecting over a
+		// network.
+		tagger   rhp.NetworkTagger
+		policies map[rhp.NetworkType]settings.NetworkPolicy
+		// renterPolicy adjusts contract formation based on the renter's
+		// contract history. It is nil if the history is not considered.
+		renterPolicy *settings.RenterPolicy
+
+		listeners []net.Listener
+		monitor   rhp.DataMonitor
+		latency   ContractLatencyRecorder
+		tg        *threadgroup.ThreadGroup
 
 		cm     ChainManager
 		tpool  TransactionPool
@@ -146,6 +197,9 @@ func (sh *SessionHandler) rpcLoop(sess *session, log *zap.Logger) error {
 	start := time.Now()
 	rpcID, end := sh.sessions.StartRPC(sess.id, id)
 	log = log.Named(id.String()).With(zap.Stringer("rpcID", rpcID))
+	if sess.contract.Revision.ParentID != (types.FileContractID{}) {
+		log = rhp.ContractLogger(log, sess.contract.Revision.ParentID)
+	}
 	log.Debug("RPC start")
 	usage, err := rpcFn(sess, log)
 	end(usage, err)
@@ -163,8 +217,15 @@ func (sh *SessionHandler) upgrade(conn net.Conn) error {
 	ingressLimiter, egressLimiter := sh.settings.BandwidthLimiters()
 	rhpConn := rhp.NewConn(conn, sh.monitor, ingressLimiter, egressLimiter)
 
+	handshakeDone := rhp.GuardHandshake(conn, sh.handshakeTimeout)
 	t, err := rhp2.NewHostTransport(rhpConn, sh.privateKey)
-	if err != nil {
+	if !handshakeDone() {
+		atomic.AddUint64(&sh.rejectedHandshakes, 1)
+		if err == nil {
+			t.Close()
+		}
+		return rhp.ErrHandshakeTimeout
+	} else if err != nil {
 		return err
 	}
 
@@ -172,19 +233,22 @@ func (sh *SessionHandler) upgrade(conn net.Conn) error {
 	defer end()
 
 	sess := &session{
-		id:   sessionID,
-		conn: rhpConn,
-		t:    t,
+		id:         sessionID,
+		conn:       rhpConn,
+		t:          t,
+		network:    sh.tagger(conn),
+		prefetcher: sh.storage.Prefetcher(),
 	}
 	defer t.Close()
 
 	defer func() {
+		sess.releaseReservation()
 		if sess.contract.Revision.ParentID != (types.FileContractID{}) {
 			sh.contracts.Unlock(sess.contract.Revision.ParentID)
 		}
 	}()
 
-	log := sh.log.With(zap.Stringer("sessionID", sessionID), zap.Stringer("peerAddr", conn.RemoteAddr()))
+	log := rhp.SessionLogger(sh.log, sessionID, conn.RemoteAddr().String()).With(zap.String("network", string(sess.network)))
 
 	for {
 		if err := sh.rpcLoop(sess, log); err != nil {
@@ -193,15 +257,99 @@ func (sh *SessionHandler) upgrade(conn net.Conn) error {
 	}
 }
 
+// RejectedHandshakes returns the number of connections that were closed
+// because they did not complete the handshake before the timeout.
+func (sh *SessionHandler) RejectedHandshakes() uint64 {
+	return atomic.LoadUint64(&sh.rejectedHandshakes)
+}
+
 // Close closes the listener and stops accepting new connections
 func (sh *SessionHandler) Close() error {
 	sh.tg.Stop()
-	return sh.listener.Close()
+	var errs []error
+	for _, l := range sh.listeners {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
-// Settings returns the host's current settings
+// Settings returns the host's current settings. They are derived from the
+// same snapshot as the RHP3 price table.
 func (sh *SessionHandler) Settings() (rhp2.HostSettings, error) {
-	settings := sh.settings.Settings()
+	return sh.SettingsFrom(sh.settings.AdvertisedSettings())
+}
+
+// networkSettings returns a snapshot of the host's configuration with the
+// policy of the network applied.
+func (sh *SessionHandler) networkSettings(network rhp.NetworkType) settings.Settings {
+	s := sh.settings.AdvertisedSettings()
+	if policy, ok := sh.policies[network]; ok {
+		s = policy.Apply(s)
+	}
+	return s
+}
+
+// sessionSettings returns the host settings advertised to the session's
+// renter. Once the renter has locked a contract, its key is known and the
+// renter policy is applied.
+func (sh *SessionHandler) sessionSettings(s *session) (rhp2.HostSettings, error) {
+	hs := sh.networkSettings(s.network)
+	if s.contract.Revision.ParentID != (types.FileContractID{}) {
+		rs, err := sh.renterSettings(hs, s.contract.RenterKey())
+		if errors.Is(err, ErrInsufficientRenterHistory) {
+			hs.AcceptingContracts = false
+		} else if err != nil {
+			return rhp2.HostSettings{}, err
+		} else {
+			hs = rs
+		}
+	}
+	return sh.SettingsFrom(hs)
+}
+
+// renterSettings applies the renter policy to the host's settings for a
+// renter forming a contract. ErrInsufficientRenterHistory is returned if the
+// policy rejects the renter.
+func (sh *SessionHandler) renterSettings(s settings.Settings, renterKey types.PublicKey) (settings.Settings, error) {
+	if sh.renterPolicy == nil {
+		return s, nil
+	}
+	history, err := sh.contracts.RenterHistory(renterKey)
+	if err != nil {
+		return settings.Settings{}, fmt.Errorf("failed to get renter history: %w", err)
+	} else if !sh.renterPolicy.Accepts(history.Successful) {
+		return settings.Settings{}, ErrInsufficientRenterHistory
+	}
+	return sh.renterPolicy.Apply(history.Successful, s), nil
+}
+
+// startContractTimer returns a timer for a contract operation or nil if
+// latency is not recorded.
+func (sh *SessionHandler) startContractTimer(op metrics.ContractOperation) *metrics.ContractTimer {
+	if sh.latency == nil {
+		return nil
+	}
+	return sh.latency.StartContractTimer(op)
+}
+
+// hasStorageHeadroom returns true if the host has more free storage than the
+// minimum headroom required to form new contracts.
+func (sh *SessionHandler) hasStorageHeadroom() (bool, error) {
+	if sh.minHeadroom == 0 {
+		return true, nil
+	}
+	available, err := sh.storage.AvailableSectors()
+	if err != nil {
+		return false, fmt.Errorf("failed to get available sectors: %w", err)
+	}
+	return available >= sh.minHeadroom, nil
+}
+
+// SettingsFrom returns the host settings advertised for a snapshot of the
+// host's configuration.
+func (sh *SessionHandler) SettingsFrom(settings settings.Settings) (rhp2.HostSettings, error) {
 	usedSectors, totalSectors, err := sh.storage.Usage()
 	if err != nil {
 		return rhp2.HostSettings{}, fmt.Errorf("failed to get storage usage: %w", err)
@@ -216,6 +364,16 @@ func (sh *SessionHandler) Settings() (rhp2.HostSettings, error) {
 		return rhp2.HostSettings{}, errors.New("no net address found")
 	}
 
+	collateral, err := rhp.CollateralPrice(settings.StoragePrice, settings.CollateralMultiplier)
+	if err != nil {
+		return rhp2.HostSettings{}, fmt.Errorf("failed to calculate collateral: %w", err)
+	}
+
+	headroom, err := sh.hasStorageHeadroom()
+	if err != nil {
+		return rhp2.HostSettings{}, err
+	}
+
 	return rhp2.HostSettings{
 		// build info
 		Release: "hostd " + build.Version(),
@@ -236,14 +394,14 @@ func (sh *SessionHandler) Settings() (rhp2.HostSettings, error) {
 		WindowSize:           settings.WindowSize,
 
 		// contract formation
-		AcceptingContracts: settings.AcceptingContracts,
+		AcceptingContracts: settings.AcceptingContracts && headroom && !sh.settings.ContractsPaused() && sh.cm.Synced(),
 		MaxDuration:        settings.MaxContractDuration,
 		ContractPrice:      settings.ContractPrice,
 
 		// rpc prices
 		BaseRPCPrice:           settings.BaseRPCPrice,
 		SectorAccessPrice:      settings.SectorAccessPrice,
-		Collateral:             settings.StoragePrice.Mul64(uint64(settings.CollateralMultiplier * 1000)).Div64(1000),
+		Collateral:             collateral,
 		MaxCollateral:          settings.MaxCollateral,
 		StoragePrice:           settings.StoragePrice,
 		DownloadBandwidthPrice: settings.EgressPrice,
@@ -257,10 +415,29 @@ func (sh *SessionHandler) Settings() (rhp2.HostSettings, error) {
 	}, nil
 }
 
-// Serve starts listening for new connections and blocks until closed
+// Serve starts listening for new connections on all of the handler's
+// listeners and blocks until they are closed
 func (sh *SessionHandler) Serve() error {
+	errCh := make(chan error, len(sh.listeners))
+	for _, l := range sh.listeners {
+		go func(l net.Listener) {
+			errCh <- sh.serve(l)
+		}(l)
+	}
+
+	var errs []error
+	for range sh.listeners {
+		if err := <-errCh; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// serve accepts connections from a single listener until it is closed.
+func (sh *SessionHandler) serve(l net.Listener) error {
 	for {
-		conn, err := sh.listener.Accept()
+		conn, err := l.Accept()
 		if errors.Is(err, net.ErrClosed) {
 			return nil
 		} else if err != nil {
@@ -273,19 +450,26 @@ func (sh *SessionHandler) Serve() error {
 					// skip logging graceful close and EOF errors
 					return
 				}
-				sh.log.Debug("failed to upgrade connection", zap.Error(err), zap.String("remoteAddr", conn.RemoteAddr().String()))
+				sh.log.Debug("failed to upgrade connection", zap.Error(err), zap.String("peerAddress", conn.RemoteAddr().String()))
 			}
 		}()
 	}
 }
 
-// LocalAddr returns the listener's listen address
-func (sh *SessionHandler) LocalAddr() string {
-	return sh.listener.Addr().String()
+// LocalAddr returns the addresses of all the handler's listeners
+func (sh *SessionHandler) LocalAddr() []string {
+	addrs := make([]string, 0, len(sh.listeners))
+	for _, l := range sh.listeners {
+		addrs = append(addrs, l.Addr().String())
+	}
+	return addrs
 }
 
 // NewSessionHandler creates a new RHP2 SessionHandler
-func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, rhp3Addr string, cm ChainManager, tpool TransactionPool, wallet Wallet, contracts ContractManager, settings SettingsReporter, storage StorageManager, monitor rhp.DataMonitor, sessions SessionReporter, log *zap.Logger) (*SessionHandler, error) {
+func NewSessionHandler(listeners []net.Listener, hostKey types.PrivateKey, rhp3Addr string, cm ChainManager, tpool TransactionPool, wallet Wallet, contracts ContractManager, settings SettingsReporter, storage StorageManager, monitor rhp.DataMonitor, sessions SessionReporter, log *zap.Logger, opts ...SessionHandlerOption) (*SessionHandler, error) {
+	if len(listeners) == 0 {
+		return nil, errors.New("at least one listener is required")
+	}
 	_, rhp3Port, err := net.SplitHostPort(rhp3Addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse rhp3 addr: %w", err)
@@ -296,17 +480,23 @@ func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, rhp3Addr string
 		tg:         threadgroup.New(),
 		rhp3Port:   rhp3Port,
 
-		listener: l,
-		monitor:  monitor,
-		cm:       cm,
-		tpool:    tpool,
-		wallet:   wallet,
+		listeners: listeners,
+		monitor:   monitor,
+		cm:        cm,
+		tpool:     tpool,
+		wallet:    wallet,
 
 		contracts: contracts,
 		sessions:  sessions,
 		settings:  settings,
 		storage:   storage,
 		log:       log,
+
+		handshakeTimeout: defaultHandshakeTimeout,
+		tagger:           func(net.Conn) rhp.NetworkType { return rhp.NetworkClearnet },
+	}
+	for _, opt := range opts {
+		opt(sh)
 	}
 	return sh, nil
 }
@@ -12,6 +12,7 @@ import (
 	rhp2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/metrics"
 	"go.sia.tech/hostd/rhp"
 	"go.sia.tech/hostd/wallet"
 	"go.uber.org/zap"
@@ -35,10 +36,28 @@ var (
 	// ErrNotAcceptingContracts is returned when the host is not accepting
 	// contracts.
 	ErrNotAcceptingContracts = errors.New("host is not accepting contracts")
+	// ErrInsufficientRenterHistory is returned when the host's renter policy
+	// requires more successful contracts than the renter has completed.
+	ErrInsufficientRenterHistory = errors.New("renter does not have enough successful contracts with the host")
+	// ErrNewRenterPrice is returned when a renter that is not established
+	// forms a contract that does not pay the renter policy's contract price.
+	// The price cannot be advertised before the renter's key is known.
+	ErrNewRenterPrice = errors.New("renter must pay the new renter contract price")
 )
 
+// rpcError converts err into the error sent to the renter. Validation errors
+// are sent with their code as the RPC error type so renters can identify the
+// failure without parsing its description.
+func rpcError(err error) error {
+	code, ok := rhp.ErrorCode(err)
+	if !ok {
+		return err
+	}
+	return &rhp2.RPCError{Type: code, Description: err.Error()}
+}
+
 func (sh *SessionHandler) rpcSettings(s *session, log *zap.Logger) (contracts.Usage, error) {
-	settings, err := sh.Settings()
+	settings, err := sh.sessionSettings(s)
 	if err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to get host settings: %w", err)
@@ -107,6 +126,7 @@ func (sh *SessionHandler) rpcUnlock(s *session, log *zap.Logger) (contracts.Usag
 	if s.contract.Revision.ParentID == (types.FileContractID{}) {
 		return contracts.Usage{}, ErrNoContractLocked
 	}
+	s.releaseReservation()
 	sh.contracts.Unlock(s.contract.Revision.ParentID)
 	s.contract = contracts.SignedRevision{}
 	return contracts.Usage{}, nil
@@ -115,14 +135,27 @@ func (sh *SessionHandler) rpcUnlock(s *session, log *zap.Logger) (contracts.Usag
 // rpcFormContract is an RPC that forms a contract between a renter and the
 // host.
 func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contracts.Usage, error) {
-	if !sh.settings.Settings().AcceptingContracts {
+	if !sh.cm.Synced() {
+		s.t.WriteResponseErr(rhp.ErrNotSynced)
+		return contracts.Usage{}, rhp.ErrNotSynced
+	}
+	hostSettings := sh.networkSettings(s.network)
+	if !hostSettings.AcceptingContracts || sh.settings.ContractsPaused() {
 		s.t.WriteResponseErr(ErrNotAcceptingContracts)
 		return contracts.Usage{}, ErrNotAcceptingContracts
+	} else if headroom, err := sh.hasStorageHeadroom(); err != nil {
+		s.t.WriteResponseErr(ErrHostInternalError)
+		return contracts.Usage{}, err
+	} else if !headroom {
+		s.t.WriteResponseErr(ErrNotAcceptingContracts)
+		return contracts.Usage{}, fmt.Errorf("%w: not enough free storage", ErrNotAcceptingContracts)
 	}
 	var req rhp2.RPCFormContractRequest
 	if err := s.readRequest(&req, 10*minMessageSize, time.Minute); err != nil {
 		return contracts.Usage{}, err
 	}
+	timer := sh.startContractTimer(metrics.OperationFormation)
+	timer.Phase(metrics.PhaseValidation)
 	formationTxnSet := req.Transactions
 	// if the transaction set does not contain any transaction or if the
 	// transaction does not contain exactly one file contract, return an error
@@ -136,9 +169,23 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 		return contracts.Usage{}, err
 	}
 	renterPub := *(*types.PublicKey)(req.RenterKey.Key)
+	// apply the renter policy to the host's settings
+	advertisedPrice := hostSettings.ContractPrice
+	hostSettings, err := sh.renterSettings(hostSettings, renterPub)
+	if errors.Is(err, ErrInsufficientRenterHistory) {
+		s.t.WriteResponseErr(err)
+		return contracts.Usage{}, err
+	} else if err != nil {
+		s.t.WriteResponseErr(ErrHostInternalError)
+		return contracts.Usage{}, err
+	}
+	// a renter that paid the advertised price underfunds the host's
+	// collateral. The failure is reported with the renter's price so it can
+	// retry.
+	premium := !hostSettings.ContractPrice.Equals(advertisedPrice)
 	// get the host's public key, current block height, and settings
 	hostPub := sh.privateKey.PublicKey()
-	settings, err := sh.Settings()
+	settings, err := sh.SettingsFrom(hostSettings)
 	if err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to get host settings: %w", err)
@@ -149,7 +196,7 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 
 	// validate the contract formation fields. note: the v1 contract type
 	// does not contain the public keys or signatures.
-	hostCollateral, err := validateContractFormation(formationTxn.FileContracts[0], hostPub.UnlockKey(), renterPub.UnlockKey(), currentHeight, settings)
+	hostCollateral, err := validateContractFormation(formationTxn.FileContracts[0], hostPub.UnlockKey(), renterPub.UnlockKey(), currentHeight, hostSettings.FormationLeadTime(), settings, hostSettings.MinHostPayout)
 	if err != nil {
 		err := fmt.Errorf("contract rejected: validation failed: %w", err)
 		s.t.WriteResponseErr(err)
@@ -157,6 +204,7 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 	}
 
 	// calculate the host's collateral and add the inputs to the transaction
+	timer.Phase(metrics.PhaseFunding)
 	renterInputs, renterOutputs := len(formationTxn.SiacoinInputs), len(formationTxn.SiacoinOutputs)
 	toSign, discard, err := sh.wallet.FundTransaction(formationTxn, hostCollateral)
 	if err != nil {
@@ -169,10 +217,24 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 	}
 	defer discard()
 
+	// verify the host is not contributing more than its collateral
+	inputValues, err := sh.wallet.OutputValues(toSign)
+	if err != nil {
+		s.t.WriteResponseErr(ErrHostInternalError)
+		return contracts.Usage{}, fmt.Errorf("failed to get host input values: %w", err)
+	} else if err := rhp.ValidateFunding(formationTxnSet[:len(formationTxnSet)-1], *formationTxn, renterInputs, renterOutputs, settings.Address, inputValues, hostCollateral); err != nil {
+		err := fmt.Errorf("contract rejected: validation failed: %w", err)
+		s.t.WriteResponseErr(err)
+		return contracts.Usage{}, err
+	}
+
 	// create an initial revision for the contract
+	timer.Phase(metrics.PhaseSigning)
 	initialRevision := rhp.InitialRevision(formationTxn, hostPub.UnlockKey(), renterPub.UnlockKey())
+	log = rhp.ContractLogger(log, initialRevision.ParentID)
 	sigHash := rhp.HashRevision(initialRevision)
 	hostSig := sh.privateKey.SignHash(sigHash)
+	timer.Pause()
 
 	// send the host's transaction funding additions to the renter
 	hostAdditionsResp := &rhp2.RPCFormContractAdditions{
@@ -187,7 +249,9 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 	var renterSignaturesResp rhp2.RPCFormContractSignatures
 	if err := s.readResponse(&renterSignaturesResp, 10*minMessageSize, 30*time.Second); err != nil {
 		return contracts.Usage{}, fmt.Errorf("failed to read renter signatures: %w", err)
-	} else if err := validateRenterRevisionSignature(renterSignaturesResp.RevisionSignature, initialRevision.ParentID, sigHash, renterPub); err != nil {
+	}
+	timer.Phase(metrics.PhaseValidation)
+	if err := validateRenterRevisionSignature(renterSignaturesResp.RevisionSignature, initialRevision.ParentID, sigHash, renterPub); err != nil {
 		err := fmt.Errorf("contract rejected: validation failed: %w", err)
 		s.t.WriteResponseErr(err)
 		return contracts.Usage{}, err
@@ -197,13 +261,19 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 	formationTxn.Signatures = renterSignaturesResp.ContractSignatures
 
 	// sign and broadcast the formation transaction
+	timer.Phase(metrics.PhaseSigning)
 	if err = sh.wallet.SignTransaction(sh.cm.TipState(), formationTxn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to sign formation transaction: %w", err)
-	} else if err = sh.tpool.AcceptTransactionSet(formationTxnSet); err != nil {
+	}
+	timer.Phase(metrics.PhaseBroadcast)
+	if err = sh.tpool.AcceptTransactionSet(formationTxnSet); err != nil {
 		err = fmt.Errorf("failed to broadcast formation transaction: %w", err)
 		buf, _ := json.Marshal(formationTxnSet)
 		log.Error("failed to broadcast formation transaction", zap.Error(err), zap.String("txnset", string(buf)))
+		if premium {
+			err = fmt.Errorf("%w of %v: %v", ErrNewRenterPrice, settings.ContractPrice, err)
+		}
 		s.t.WriteResponseErr(err)
 		return contracts.Usage{}, err
 	}
@@ -220,6 +290,7 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to add contract to store: %w", err)
 	}
+	timer.Finish()
 
 	// send the host signatures to the renter
 	hostSignaturesResp := &rhp2.RPCFormContractSignatures{
@@ -236,8 +307,12 @@ func (sh *SessionHandler) rpcFormContract(s *session, log *zap.Logger) (contract
 // rpcRenewAndClearContract is an RPC that renews a contract and clears the
 // existing contract
 func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger) (contracts.Usage, error) {
+	if !sh.cm.Synced() {
+		s.t.WriteResponseErr(rhp.ErrNotSynced)
+		return contracts.Usage{}, rhp.ErrNotSynced
+	}
 	state := sh.cm.TipState()
-	settings, err := sh.Settings()
+	settings, err := sh.sessionSettings(s)
 	if err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to get host settings: %w", err)
@@ -259,6 +334,8 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 	if err := s.readRequest(&req, 10*minMessageSize, time.Minute); err != nil {
 		return contracts.Usage{}, fmt.Errorf("failed to read renew request: %w", err)
 	}
+	timer := sh.startContractTimer(metrics.OperationRenewal)
+	timer.Phase(metrics.PhaseValidation)
 
 	renterKey, err := convertToPublicKey(req.RenterKey)
 	if err != nil {
@@ -281,7 +358,7 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 	clearingRevision, err := rhp.ClearingRevision(existingRevision, req.FinalValidProofValues)
 	if err != nil {
 		err = fmt.Errorf("failed to create clearing revision: %w", err)
-		s.t.WriteResponseErr(err)
+		s.t.WriteResponseErr(rpcError(err))
 		return contracts.Usage{}, err
 	}
 	expectedExchange := settings.BaseRPCPrice
@@ -293,7 +370,7 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 	finalPayment, err := rhp.ValidateClearingRevision(existingRevision, clearingRevision, expectedExchange)
 	if err != nil {
 		err = fmt.Errorf("invalid clearing revision: %w", err)
-		s.t.WriteResponseErr(err)
+		s.t.WriteResponseErr(rpcError(err))
 		return contracts.Usage{}, err
 	}
 	clearingUsage := contracts.Usage{
@@ -307,8 +384,23 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 	var baseCollateral types.Currency
 	if renewedContract.WindowEnd > existingRevision.WindowEnd {
 		extension := uint64(renewedContract.WindowEnd - existingRevision.WindowEnd)
-		baseRevenue = baseRevenue.Add(settings.StoragePrice.Mul64(renewedContract.Filesize).Mul64(extension))
-		baseCollateral = settings.Collateral.Mul64(renewedContract.Filesize).Mul64(extension)
+		storageCost, err := rhp.StorageCost(settings.StoragePrice, renewedContract.Filesize, extension)
+		if err != nil {
+			s.t.WriteResponseErr(err)
+			return contracts.Usage{}, err
+		}
+		var overflow bool
+		baseRevenue, overflow = baseRevenue.AddWithOverflow(storageCost)
+		if overflow {
+			err := fmt.Errorf("renewal revenue: %w", rhp.ErrPriceOverflow)
+			s.t.WriteResponseErr(err)
+			return contracts.Usage{}, err
+		}
+		baseCollateral, err = rhp.StorageCost(settings.Collateral, renewedContract.Filesize, extension)
+		if err != nil {
+			s.t.WriteResponseErr(err)
+			return contracts.Usage{}, err
+		}
 	}
 
 	// validate the renewal
@@ -324,6 +416,7 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 		StorageRevenue:   baseRevenue.Sub(settings.ContractPrice),
 	}
 
+	timer.Phase(metrics.PhaseFunding)
 	renterInputs, renterOutputs := len(renewalTxn.SiacoinInputs), len(renewalTxn.SiacoinOutputs)
 	toSign, discard, err := sh.wallet.FundTransaction(&renewalTxn, lockedCollateral)
 	if err != nil {
@@ -336,6 +429,18 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 	}
 	defer discard()
 
+	// verify the host is not contributing more than its collateral
+	inputValues, err := sh.wallet.OutputValues(toSign)
+	if err != nil {
+		s.t.WriteResponseErr(ErrHostInternalError)
+		return contracts.Usage{}, fmt.Errorf("failed to get host input values: %w", err)
+	} else if err := rhp.ValidateFunding(renewalParents, renewalTxn, renterInputs, renterOutputs, settings.Address, inputValues, lockedCollateral); err != nil {
+		err := fmt.Errorf("invalid contract renewal: %w", err)
+		s.t.WriteResponseErr(err)
+		return contracts.Usage{}, err
+	}
+	timer.Pause()
+
 	// send the renter the host additions to the renewal txn
 	hostAdditionsResp := &rhp2.RPCFormContractAdditions{
 		Inputs:  renewalTxn.SiacoinInputs[renterInputs:],
@@ -356,6 +461,7 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 	// add the renter's signatures to the formation transaction
 	renewalTxn.Signatures = append(renewalTxn.Signatures, renterSigsResp.ContractSignatures...)
 	// sign the transaction
+	timer.Phase(metrics.PhaseSigning)
 	if err = sh.wallet.SignTransaction(state, &renewalTxn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to sign renewal transaction: %w", err)
@@ -365,6 +471,7 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 	initialRevision := rhp.InitialRevision(&renewalTxn, hostUnlockKey, req.RenterKey)
 
 	// verify the clearing revision signature
+	timer.Phase(metrics.PhaseValidation)
 	clearingRevSigHash := rhp.HashRevision(clearingRevision)
 	// important: verify using the existing contract's renter key
 	if !s.contract.RenterKey().VerifyHash(clearingRevSigHash, renterSigsResp.FinalRevisionSignature) {
@@ -382,6 +489,7 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 		return contracts.Usage{}, err
 	}
 
+	timer.Phase(metrics.PhaseSigning)
 	signedClearing := contracts.SignedRevision{
 		Revision:        clearingRevision,
 		RenterSignature: renterSigsResp.FinalRevisionSignature,
@@ -393,18 +501,18 @@ func (sh *SessionHandler) rpcRenewAndClearContract(s *session, log *zap.Logger)
 		HostSignature:   sh.privateKey.SignHash(renewalSigHash),
 	}
 
-	// broadcast the transaction
+	// update the existing contract, add the renewed contract to the store,
+	// and broadcast the transaction
+	timer.Phase(metrics.PhaseBroadcast)
 	renewalTxnSet = append(renewalParents, renewalTxn)
-	if err = sh.tpool.AcceptTransactionSet(renewalTxnSet); err != nil {
-		err = fmt.Errorf("failed to broadcast renewal transaction: %w", err)
+	if err := sh.contracts.RenewContract(signedRenewal, signedClearing, renewalTxnSet, lockedCollateral, clearingUsage, renewalUsage); errors.Is(err, contracts.ErrRenewalNotBroadcast) {
 		s.t.WriteResponseErr(err)
 		return contracts.Usage{}, err
-	}
-	// update the existing contract and add the renewed contract to the store
-	if err := sh.contracts.RenewContract(signedRenewal, signedClearing, renewalTxnSet, lockedCollateral, clearingUsage, renewalUsage); err != nil {
+	} else if err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to renew contract: %w", err)
 	}
+	timer.Finish()
 
 	// send the host signatures to the renter
 	hostSigsResp := &rhp2.RPCRenewAndClearContractSignatures{
@@ -440,7 +548,7 @@ func (sh *SessionHandler) rpcSectorRoots(s *session, log *zap.Logger) (contracts
 		return contracts.Usage{}, err
 	}
 
-	settings, err := sh.Settings()
+	settings, err := sh.sessionSettings(s)
 	if err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to get host settings: %w", err)
@@ -453,14 +561,14 @@ func (sh *SessionHandler) rpcSectorRoots(s *session, log *zap.Logger) (contracts
 	revision, err := rhp.Revise(s.contract.Revision, req.RevisionNumber, req.ValidProofValues, req.MissedProofValues)
 	if err != nil {
 		err := fmt.Errorf("failed to revise contract: %w", err)
-		s.t.WriteResponseErr(err)
+		s.t.WriteResponseErr(rpcError(err))
 		return contracts.Usage{}, err
 	}
 
 	payment, _, err := rhp.ValidateRevision(s.contract.Revision, revision, cost, types.ZeroCurrency)
 	if err != nil {
 		err := fmt.Errorf("failed to validate revision: %w", err)
-		s.t.WriteResponseErr(err)
+		s.t.WriteResponseErr(rpcError(err))
 		return contracts.Usage{}, err
 	}
 
@@ -533,7 +641,8 @@ func (sh *SessionHandler) rpcWrite(s *session, log *zap.Logger) (contracts.Usage
 		s.t.WriteResponseErr(err)
 		return contracts.Usage{}, err
 	}
-	settings, err := sh.Settings()
+	hs := sh.networkSettings(s.network)
+	settings, err := sh.SettingsFrom(hs)
 	if err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to get settings: %w", err)
@@ -545,9 +654,18 @@ func (sh *SessionHandler) rpcWrite(s *session, log *zap.Logger) (contracts.Usage
 	}
 
 	remainingDuration := uint64(s.contract.Revision.WindowEnd) - currentHeight
+	// uploaded data must cover the collateral risked for the rest of the
+	// contract
+	if writeActionSectors(req.Actions) > 0 {
+		if err := hs.CheckIngressPrice(settings.UploadBandwidthPrice, remainingDuration); err != nil {
+			s.t.WriteResponseErr(err)
+			return contracts.Usage{}, err
+		}
+	}
+
 	// validate the requested actions
 	oldSectors := s.contract.Revision.Filesize / rhp2.SectorSize
-	costs, err := settings.RPCWriteCost(req.Actions, oldSectors, remainingDuration, req.MerkleProof)
+	costs, err := rpcWriteCost(settings, req.Actions, oldSectors, remainingDuration, req.MerkleProof)
 	if err != nil {
 		err := fmt.Errorf("failed to validate write actions: %w", err)
 		s.t.WriteResponseErr(err)
@@ -559,13 +677,22 @@ func (sh *SessionHandler) rpcWrite(s *session, log *zap.Logger) (contracts.Usage
 	revision, err := rhp.Revise(s.contract.Revision, req.RevisionNumber, req.ValidProofValues, req.MissedProofValues)
 	if err != nil {
 		err := fmt.Errorf("failed to revise contract: %w", err)
-		s.t.WriteResponseErr(err)
+		s.t.WriteResponseErr(rpcError(err))
 		return contracts.Usage{}, err
 	}
 
 	payment, risked, err := rhp.ValidateRevision(s.contract.Revision, revision, cost, collateral)
 	if err != nil {
 		err := fmt.Errorf("failed to validate revision: %w", err)
+		s.t.WriteResponseErr(rpcError(err))
+		return contracts.Usage{}, err
+	}
+
+	// reserve space for the new sectors. The space is held until the
+	// contract is unlocked so a renter's upload cannot run out of space
+	// partway through.
+	if err := s.reserveSectors(sh.storage, writeActionSectors(req.Actions)); err != nil {
+		err := fmt.Errorf("failed to reserve space for sectors: %w", err)
 		s.t.WriteResponseErr(err)
 		return contracts.Usage{}, err
 	}
@@ -597,7 +724,7 @@ func (sh *SessionHandler) rpcWrite(s *session, log *zap.Logger) (contracts.Usage
 			}
 			sector := (*[rhp2.SectorSize]byte)(action.Data)
 			root := rhp2.SectorRoot(sector)
-			release, err := sh.storage.Write(root, sector)
+			release, err := s.reservation.Write(root, sector)
 			if err != nil {
 				err := fmt.Errorf("append action: failed to write sector: %w", err)
 				s.t.WriteResponseErr(err)
@@ -650,7 +777,7 @@ func (sh *SessionHandler) rpcWrite(s *session, log *zap.Logger) (contracts.Usage
 				s.t.WriteResponseErr(err)
 				return contracts.Usage{}, err
 			}
-			release, err := sh.storage.Write(root, sector)
+			release, err := s.reservation.Write(root, sector)
 			if err != nil {
 				err := fmt.Errorf("append action: failed to write sector: %w", err)
 				s.t.WriteResponseErr(err)
@@ -725,6 +852,31 @@ func (sh *SessionHandler) rpcWrite(s *session, log *zap.Logger) (contracts.Usage
 	return usage, s.writeResponse(hostSigResp, 30*time.Second)
 }
 
+// A sectorIndex finds the index of a root in a contract's sector roots. The
+// roots are only indexed once a lookup misses its hint.
+type sectorIndex struct {
+	updater *contracts.ContractUpdater
+	indices map[types.Hash256]uint64
+}
+
+// find returns the index of root in the contract. hint is checked first
+// since downloads usually read sectors in order.
+func (si *sectorIndex) find(root types.Hash256, hint uint64) (uint64, bool) {
+	if r, err := si.updater.SectorRoot(hint); err == nil && r == root {
+		return hint, true
+	}
+	if si.indices == nil {
+		roots := si.updater.SectorRoots()
+		si.indices = make(map[types.Hash256]uint64, len(roots))
+		// iterate in reverse so duplicate roots map to their first index
+		for i := len(roots) - 1; i >= 0; i-- {
+			si.indices[roots[i]] = uint64(i)
+		}
+	}
+	index, ok := si.indices[root]
+	return index, ok
+}
+
 func (sh *SessionHandler) rpcRead(s *session, log *zap.Logger) (contracts.Usage, error) {
 	currentHeight := sh.cm.TipState().Index.Height
 	// get the locked contract and check that it is revisable
@@ -735,7 +887,7 @@ func (sh *SessionHandler) rpcRead(s *session, log *zap.Logger) (contracts.Usage,
 	}
 
 	// get the host's current settings
-	settings, err := sh.Settings()
+	settings, err := sh.sessionSettings(s)
 	if err != nil {
 		s.t.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to get host settings: %w", err)
@@ -759,7 +911,7 @@ func (sh *SessionHandler) rpcRead(s *session, log *zap.Logger) (contracts.Usage,
 	revision, err := rhp.Revise(s.contract.Revision, req.RevisionNumber, req.ValidProofValues, req.MissedProofValues)
 	if err != nil {
 		err := fmt.Errorf("failed to revise contract: %w", err)
-		s.t.WriteResponseErr(err)
+		s.t.WriteResponseErr(rpcError(err))
 		return contracts.Usage{}, err
 	}
 
@@ -774,7 +926,7 @@ func (sh *SessionHandler) rpcRead(s *session, log *zap.Logger) (contracts.Usage,
 	payment, _, err := rhp.ValidateRevision(s.contract.Revision, revision, cost, types.ZeroCurrency)
 	if err != nil {
 		err := fmt.Errorf("failed to validate revision: %w", err)
-		s.t.WriteResponseErr(err)
+		s.t.WriteResponseErr(rpcError(err))
 		return contracts.Usage{}, err
 	}
 
@@ -823,6 +975,8 @@ func (sh *SessionHandler) rpcRead(s *session, log *zap.Logger) (contracts.Usage,
 	}()
 
 	// enter response loop
+	var next uint64
+	roots := sectorIndex{updater: updater}
 	for i, sec := range req.Sections {
 		sector, err := sh.storage.Read(sec.MerkleRoot)
 		if err != nil {
@@ -831,6 +985,13 @@ func (sh *SessionHandler) rpcRead(s *session, log *zap.Logger) (contracts.Usage,
 			return usage, err
 		}
 
+		if s.prefetcher.Enabled() {
+			if index, ok := roots.find(sec.MerkleRoot, next); ok {
+				s.prefetcher.Observe(revision.ParentID, index, updater.SectorRoot)
+				next = index + 1
+			}
+		}
+
 		resp := &rhp2.RPCReadResponse{
 			Data: sector[sec.Offset : sec.Offset+sec.Length],
 		}
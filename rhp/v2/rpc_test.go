@@ -4,14 +4,23 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"net"
+	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	rhp2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/persist/sqlite"
+	"go.sia.tech/hostd/rhp"
+	hostrhp2 "go.sia.tech/hostd/rhp/v2"
 	"go.sia.tech/renterd/wallet"
 	"go.uber.org/goleak"
 	"go.uber.org/zap/zaptest"
@@ -44,6 +53,262 @@ func TestSettings(t *testing.T) {
 	}
 }
 
+func TestNetworkPolicy(t *testing.T) {
+	const onionAddr = "hostd.onion:9982"
+
+	// tag every connection as Tor
+	tagger := func(net.Conn) rhp.NetworkType { return rhp.NetworkTor }
+	policy := settings.NetworkPolicy{
+		RejectContracts: true,
+		PriceMultiplier: 2,
+		NetAddress:      onionAddr,
+	}
+
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log, test.WithRHP2Options(hostrhp2.WithNetworkTagger(tagger), hostrhp2.WithNetworkPolicy(rhp.NetworkTor, policy)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	// the host's own settings are the clearnet settings
+	clearnet, err := host.RHP2Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tor, err := renter.Settings(context.Background(), host.RHP2Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case tor.AcceptingContracts:
+		t.Fatal("expected tor settings to reject contracts")
+	case tor.NetAddress != onionAddr:
+		t.Fatalf("expected net address %q, got %q", onionAddr, tor.NetAddress)
+	case !tor.ContractPrice.Equals(clearnet.ContractPrice.Mul64(2)):
+		t.Fatalf("expected contract price %v, got %v", clearnet.ContractPrice.Mul64(2), tor.ContractPrice)
+	case !tor.StoragePrice.Equals(clearnet.StoragePrice.Mul64(2)):
+		t.Fatalf("expected storage price %v, got %v", clearnet.StoragePrice.Mul64(2), tor.StoragePrice)
+	case !tor.DownloadBandwidthPrice.Equals(clearnet.DownloadBandwidthPrice.Mul64(2)):
+		t.Fatalf("expected download price %v, got %v", clearnet.DownloadBandwidthPrice.Mul64(2), tor.DownloadBandwidthPrice)
+	case !tor.Collateral.Equals(clearnet.Collateral):
+		// the collateral multiplier is reduced so the collateral is unchanged
+		t.Fatalf("expected collateral %v, got %v", clearnet.Collateral, tor.Collateral)
+	}
+
+	state := renter.TipState()
+	_, err = renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(10), types.Siacoins(20), state.Index.Height+200)
+	if err == nil || !strings.Contains(err.Error(), hostrhp2.ErrNotAcceptingContracts.Error()) {
+		t.Fatalf("expected %v, got %v", hostrhp2.ErrNotAcceptingContracts, err)
+	}
+}
+
+func TestFormationStorageHeadroom(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	// the testing host has a single 64 sector volume
+	renter, host, err := test.NewTestingPair(t.TempDir(), log, test.WithRHP2Options(hostrhp2.WithMinStorageHeadroom(60)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	checkAccepting := func(accepting bool) {
+		t.Helper()
+		settings, err := renter.Settings(context.Background(), host.RHP2Addr(), host.PublicKey())
+		if err != nil {
+			t.Fatal(err)
+		} else if settings.AcceptingContracts != accepting {
+			t.Fatalf("expected accepting contracts to be %v", accepting)
+		}
+	}
+
+	formContract := func() error {
+		state := renter.TipState()
+		_, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(10), types.Siacoins(20), state.Index.Height+200)
+		return err
+	}
+
+	checkAccepting(true)
+	if err := formContract(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reserve enough sectors to exhaust the headroom
+	reservation, err := host.Storage().ReserveSectors(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAccepting(false)
+	if err := formContract(); err == nil || !strings.Contains(err.Error(), hostrhp2.ErrNotAcceptingContracts.Error()) {
+		t.Fatalf("expected %v, got %v", hostrhp2.ErrNotAcceptingContracts, err)
+	}
+
+	// formation should resume once space is freed
+	reservation.Release()
+	checkAccepting(true)
+	if err := formContract(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFormationLatency(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	db, err := sqlite.OpenDatabase(filepath.Join(dir, "metrics.db"), log.Named("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mm, err := metrics.NewManager(db, log.Named("metrics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter, host, err := test.NewTestingPair(dir, log, test.WithRHP2Options(hostrhp2.WithContractLatencyRecorder(mm)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	state := renter.TipState()
+	if _, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(10), types.Siacoins(20), state.Index.Height+200); err != nil {
+		t.Fatal(err)
+	}
+
+	latency := mm.ContractLatency()
+	if latency.Formation.Count != 1 {
+		t.Fatalf("expected 1 formation, got %v", latency.Formation.Count)
+	} else if latency.Renewal.Count != 0 {
+		t.Fatalf("expected 0 renewals, got %v", latency.Renewal.Count)
+	}
+
+	var phaseTotal time.Duration
+	for _, phase := range []metrics.ContractPhase{metrics.PhaseValidation, metrics.PhaseFunding, metrics.PhaseSigning, metrics.PhaseBroadcast} {
+		h, ok := latency.Formation.Phases[phase]
+		if !ok {
+			t.Fatalf("expected %v phase to be recorded", phase)
+		} else if h.Count != 1 {
+			t.Fatalf("expected 1 %v observation, got %v", phase, h.Count)
+		} else if h.TotalDuration <= 0 {
+			t.Fatalf("expected %v duration to be positive", phase)
+		}
+		phaseTotal += h.TotalDuration
+	}
+	// the total includes time spent waiting on the renter
+	if latency.Formation.TotalDuration < phaseTotal {
+		t.Fatalf("expected total duration %v to be at least the sum of the phases %v", latency.Formation.TotalDuration, phaseTotal)
+	}
+	// the histogram is cumulative, so the largest bucket contains every
+	// observation that did not exceed it
+	buckets := latency.Formation.Durations
+	if last := buckets[len(buckets)-1]; last.Count != 1 {
+		t.Fatalf("expected the largest bucket to contain the formation, got %v", last.Count)
+	}
+
+	// the histograms should be loaded after a restart
+	mm, err = metrics.NewManager(db, log.Named("metrics"))
+	if err != nil {
+		t.Fatal(err)
+	} else if reloaded := mm.ContractLatency(); !reflect.DeepEqual(reloaded, latency) {
+		t.Fatalf("expected %v after reload, got %v", latency, reloaded)
+	}
+}
+
+func TestRenterPolicy(t *testing.T) {
+	policy := settings.RenterPolicy{
+		EstablishedContracts:     1,
+		NewRenterPriceMultiplier: 2,
+	}
+
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log, test.WithRHP2Options(hostrhp2.WithRenterPolicy(policy)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	hs, err := host.RHP2Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostCollateral := types.Siacoins(20)
+	formContract := func(contractPrice types.Currency) (contracts.Contract, error) {
+		state := renter.TipState()
+		revision, err := renter.FormContractWithPrice(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(10), hostCollateral, contractPrice, state.Index.Height+200)
+		if err != nil {
+			return contracts.Contract{}, err
+		}
+		return host.Contracts().Contract(revision.ID())
+	}
+
+	// a new renter must pay double the advertised contract price
+	if _, err := formContract(hs.ContractPrice); err == nil || !strings.Contains(err.Error(), hostrhp2.ErrNewRenterPrice.Error()) {
+		t.Fatalf("expected %v, got %v", hostrhp2.ErrNewRenterPrice, err)
+	}
+	contract, err := formContract(hs.ContractPrice.Mul64(2))
+	if err != nil {
+		t.Fatal(err)
+	} else if !contract.LockedCollateral.Equals(hostCollateral) {
+		t.Fatalf("expected collateral %v, got %v", hostCollateral, contract.LockedCollateral)
+	}
+
+	// once the renter is known, the host advertises the renter's price
+	checkPrice := func(id types.FileContractID, expected types.Currency) {
+		t.Helper()
+		session, err := renter.NewRHP2Session(context.Background(), host.RHP2Addr(), host.PublicKey(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer session.Close()
+		if price := session.Settings().ContractPrice; !price.Equals(expected) {
+			t.Fatalf("expected contract price %v, got %v", expected, price)
+		}
+	}
+	checkPrice(contract.Revision.ParentID, hs.ContractPrice.Mul64(2))
+
+	history, err := host.Contracts().RenterHistory(renter.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	} else if history.Formed != 1 || history.Successful != 0 {
+		t.Fatalf("unexpected renter history %+v", history)
+	}
+
+	// complete the contract to establish the renter
+	if err := host.Store().ExpireContract(contract.Revision.ParentID, contracts.ContractStatusSuccessful); err != nil {
+		t.Fatal(err)
+	}
+	history, err = host.Contracts().RenterHistory(renter.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	} else if history.Successful != 1 {
+		t.Fatalf("expected 1 successful contract, got %v", history.Successful)
+	}
+
+	// an established renter pays the advertised contract price
+	contract, err = formContract(hs.ContractPrice)
+	if err != nil {
+		t.Fatal(err)
+	} else if !contract.LockedCollateral.Equals(hostCollateral) {
+		t.Fatalf("expected collateral %v, got %v", hostCollateral, contract.LockedCollateral)
+	}
+	checkPrice(contract.Revision.ParentID, hs.ContractPrice)
+
+	// a different renter has no history
+	history, err = host.Contracts().RenterHistory(types.GeneratePrivateKey().PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	} else if history != (contracts.RenterHistory{}) {
+		t.Fatalf("expected empty history, got %+v", history)
+	}
+}
+
 func TestUploadDownload(t *testing.T) {
 	log := zaptest.NewLogger(t)
 	renter, host, err := test.NewTestingPair(t.TempDir(), log)
@@ -134,6 +399,30 @@ func TestUploadDownload(t *testing.T) {
 	if !bytes.Equal(buf.Bytes(), sector[:]) {
 		t.Fatal("sector mismatch")
 	}
+
+	// the space reserved for the upload is consumed by the write
+	if n := host.Storage().Reserved(); n != 0 {
+		t.Fatalf("expected no reserved sectors, got %v", n)
+	}
+
+	// uploading a stored sector does not consume the reserved space, which is
+	// held until the session ends
+	price, collateral, err = session.RPCAppendCost(remainingDuration)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := session.Append(context.Background(), &sector, price, collateral); err != nil {
+		t.Fatal(err)
+	} else if n := host.Storage().Reserved(); n != 1 {
+		t.Fatalf("expected 1 reserved sector, got %v", n)
+	}
+
+	session.Close()
+	for i := 0; host.Storage().Reserved() != 0; i++ {
+		if i >= 100 {
+			t.Fatal("expected the reservation to be released when the session ended")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
 }
 
 func TestRenew(t *testing.T) {
@@ -659,3 +948,52 @@ func TestSectorRoots(t *testing.T) {
 		}
 	}
 }
+
+func TestHandshakeTimeout(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewNode(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, err := test.NewHost(types.GeneratePrivateKey(), dir, node, log.Named("host"), test.WithRHP2Options(hostrhp2.WithHandshakeTimeout(250*time.Millisecond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+
+	// open a connection without sending the handshake
+	conn, err := net.Dial("tcp", host.RHP2Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the host should close the connection after the timeout
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("expected the host to close the connection, got %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond) // wait for the handler to return
+	if v2, _ := host.RejectedHandshakes(); v2 != 1 {
+		t.Fatalf("expected 1 rejected handshake, got %v", v2)
+	}
+
+	// a renter completing the handshake should not be affected
+	if err := os.MkdirAll(filepath.Join(dir, "renter"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	renter, err := test.NewRenter(types.GeneratePrivateKey(), filepath.Join(dir, "renter"), node, log.Named("renter"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+
+	if _, err := renter.Settings(context.Background(), host.RHP2Addr(), host.PublicKey()); err != nil {
+		t.Fatal(err)
+	} else if v2, _ := host.RejectedHandshakes(); v2 != 1 {
+		t.Fatalf("expected 1 rejected handshake, got %v", v2)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	rhp2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/rhp"
 )
 
@@ -19,8 +20,47 @@ type session struct {
 	id   rhp.UID
 	conn *rhp.Conn
 	t    *rhp2.Transport
+	// network is the network the renter connected over
+	network rhp.NetworkType
 
-	contract contracts.SignedRevision
+	contract   contracts.SignedRevision
+	prefetcher *storage.Prefetcher
+	// reservation holds space for the sectors written to the locked
+	// contract. It is released when the contract is unlocked or the
+	// session ends.
+	reservation *storage.Reservation
+}
+
+// writeActionSectors returns the number of sectors written by the actions.
+func writeActionSectors(actions []rhp2.RPCWriteAction) (n uint64) {
+	for _, action := range actions {
+		switch action.Type {
+		case rhp2.RPCWriteActionAppend, rhp2.RPCWriteActionUpdate:
+			n++
+		}
+	}
+	return
+}
+
+// reserveSectors adds space for n sectors to the session's reservation.
+func (s *session) reserveSectors(sm StorageManager, n uint64) (err error) {
+	switch {
+	case n == 0:
+		return nil
+	case s.reservation == nil:
+		s.reservation, err = sm.ReserveSectors(n)
+		return err
+	default:
+		return s.reservation.Reserve(n)
+	}
+}
+
+// releaseReservation releases any space reserved by the session.
+func (s *session) releaseReservation() {
+	if s.reservation != nil {
+		s.reservation.Release()
+		s.reservation = nil
+	}
 }
 
 func (s *session) readRequest(req rhp2.ProtocolObject, maxSize uint64, timeout time.Duration) error {
@@ -0,0 +1,31 @@
+package rhp
+
+import (
+	"fmt"
+
+	"go.sia.tech/hostd/host/accounts"
+)
+
+// estimateUsage returns the estimated account usage of executing every
+// instruction in the program. Instructions with invalid arguments are not
+// included since they will fail when executed.
+func (pe *programExecutor) estimateUsage() (usage accounts.Usage) {
+	for _, instr := range pe.instructions {
+		if _, instrUsage, err := pe.instructionCost(instr); err == nil {
+			usage = usage.Add(instrUsage)
+		}
+	}
+	return
+}
+
+// preauthorize checks that the program's budget can pay for the estimated
+// cost of the program before any instructions are executed. The payment is
+// withheld from the account when the budget is created. After execution, only
+// the actual cost is debited and the difference is returned to the account.
+func (pe *programExecutor) preauthorize() error {
+	estimated := pe.estimateUsage().Total()
+	if remaining := pe.budget.Remaining(); remaining.Cmp(estimated) < 0 {
+		return fmt.Errorf("%w: program costs an estimated %v, %v remaining", ErrInsufficientBudget, estimated, remaining)
+	}
+	return nil
+}
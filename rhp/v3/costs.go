@@ -0,0 +1,186 @@
+package rhp
+
+import (
+	rhp2 "go.sia.tech/core/rhp/v2"
+	rhp3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/rhp"
+)
+
+// blocksPerYear is the number of blocks in a year, matching the value used
+// by the price table to charge for registry entries.
+const blocksPerYear = 365 * 144
+
+// A costCalculator calculates instruction costs with overflow checks. The
+// core price table methods panic or wrap on overflow, and several of their
+// arguments are chosen by the renter. The first overflow is recorded and
+// every later operation returns zero.
+type costCalculator struct {
+	err error
+}
+
+// mul returns c multiplied by each of the factors.
+func (cc *costCalculator) mul(c types.Currency, factors ...uint64) types.Currency {
+	if cc.err != nil {
+		return types.ZeroCurrency
+	}
+	v, err := rhp.MulCurrency(c, factors...)
+	if err != nil {
+		cc.err = err
+	}
+	return v
+}
+
+// add returns a+b.
+func (cc *costCalculator) add(a, b types.Currency) types.Currency {
+	if cc.err != nil {
+		return types.ZeroCurrency
+	}
+	v, overflow := a.AddWithOverflow(b)
+	if overflow {
+		cc.err = rhp.ErrPriceOverflow
+		return types.ZeroCurrency
+	}
+	return v
+}
+
+// result returns the cost, or the first overflow. The budget adds up the
+// cost's resources without overflow checks, so their sum is checked too.
+func (cc *costCalculator) result(cost rhp3.ResourceCost) (rhp3.ResourceCost, error) {
+	cc.add(cc.add(cc.add(cost.Base, cost.Storage), cost.Ingress), cost.Egress)
+	if cc.err != nil {
+		return rhp3.ResourceCost{}, cc.err
+	}
+	return cost, nil
+}
+
+// writeBaseCost returns the cost of writing writeLength bytes, rounded up to
+// the nearest atomic write.
+func (cc *costCalculator) writeBaseCost(pt *rhp3.HostPriceTable, writeLength uint64) types.Currency {
+	const atomicWriteSize = 1 << 12
+	if mod := writeLength % atomicWriteSize; mod != 0 {
+		writeLength += (atomicWriteSize - mod)
+	}
+	return cc.add(cc.mul(pt.WriteLengthCost, writeLength), pt.WriteBaseCost)
+}
+
+// appendSectorCost returns the cost of executing the AppendSector
+// instruction.
+func appendSectorCost(pt *rhp3.HostPriceTable, duration uint64) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:       cc.writeBaseCost(pt, rhp2.SectorSize),
+		Storage:    cc.mul(pt.WriteStoreCost, rhp2.SectorSize, duration),
+		Collateral: cc.mul(pt.CollateralCost, rhp2.SectorSize, duration),
+		Ingress:    cc.mul(pt.UploadBandwidthCost, rhp2.SectorSize),
+	}
+	return cc.result(cost)
+}
+
+// appendSectorRootCost returns the cost of executing the AppendSectorRoot
+// instruction.
+func appendSectorRootCost(pt *rhp3.HostPriceTable, duration uint64) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:       pt.WriteBaseCost,
+		Storage:    cc.mul(pt.WriteStoreCost, rhp2.SectorSize, duration),
+		Collateral: cc.mul(pt.CollateralCost, rhp2.SectorSize, duration),
+		Ingress:    cc.mul(pt.UploadBandwidthCost, 32), // sector root
+	}
+	return cc.result(cost)
+}
+
+// dropSectorsCost returns the cost of executing the DropSectors instruction.
+func dropSectorsCost(pt *rhp3.HostPriceTable, n uint64) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:    cc.add(cc.mul(pt.DropSectorsUnitCost, n), pt.DropSectorsBaseCost),
+		Ingress: cc.mul(pt.UploadBandwidthCost, 8), // drop sector count
+	}
+	return cc.result(cost)
+}
+
+// hasSectorCost returns the cost of executing the HasSector instruction.
+func hasSectorCost(pt *rhp3.HostPriceTable) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:    pt.HasSectorBaseCost,
+		Ingress: cc.mul(pt.UploadBandwidthCost, 32), // sector root
+		Egress:  pt.DownloadBandwidthCost,           // boolean response
+	}
+	return cc.result(cost)
+}
+
+// readOffsetCost returns the cost of executing the ReadOffset instruction.
+func readOffsetCost(pt *rhp3.HostPriceTable, length uint64) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:    cc.add(cc.mul(pt.ReadLengthCost, length), pt.ReadBaseCost),
+		Ingress: cc.mul(pt.UploadBandwidthCost, 8),        // sector root index
+		Egress:  cc.mul(pt.DownloadBandwidthCost, length), // response data
+	}
+	return cc.result(cost)
+}
+
+// readSectorCost returns the cost of executing the ReadSector instruction.
+func readSectorCost(pt *rhp3.HostPriceTable, length uint64) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:    cc.add(cc.mul(pt.ReadLengthCost, length), pt.ReadBaseCost),
+		Ingress: cc.mul(pt.UploadBandwidthCost, 32),       // sector root
+		Egress:  cc.mul(pt.DownloadBandwidthCost, length), // response data
+	}
+	return cc.result(cost)
+}
+
+// swapSectorCost returns the cost of executing the SwapSector instruction.
+func swapSectorCost(pt *rhp3.HostPriceTable) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:    pt.SwapSectorBaseCost,
+		Ingress: cc.mul(pt.UploadBandwidthCost, 2*8), // 2 sector indices
+	}
+	return cc.result(cost)
+}
+
+// updateSectorCost returns the cost of executing the UpdateSector
+// instruction.
+func updateSectorCost(pt *rhp3.HostPriceTable, length uint64) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:    cc.writeBaseCost(pt, rhp2.SectorSize),
+		Ingress: cc.mul(pt.UploadBandwidthCost, length),
+	}
+	return cc.result(cost)
+}
+
+// storeSectorCost returns the cost of executing the StoreSector instruction.
+func storeSectorCost(pt *rhp3.HostPriceTable, duration uint64) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:    cc.writeBaseCost(pt, rhp2.SectorSize),
+		Storage: cc.mul(pt.WriteStoreCost, rhp2.SectorSize, duration),
+		Ingress: cc.mul(pt.UploadBandwidthCost, rhp2.SectorSize),
+	}
+	return cc.result(cost)
+}
+
+// revisionCost returns the cost of executing the Revision instruction.
+func revisionCost(pt *rhp3.HostPriceTable) (rhp3.ResourceCost, error) {
+	return rhp3.ResourceCost{
+		Base: pt.RevisionBaseCost,
+	}, nil
+}
+
+// readRegistryCost returns the cost of executing the ReadRegistry
+// instruction. The storage cost extends the entry by the equivalent of
+// storing 256 bytes for 10 years.
+func readRegistryCost(pt *rhp3.HostPriceTable) (rhp3.ResourceCost, error) {
+	var cc costCalculator
+	cost := rhp3.ResourceCost{
+		Base:    cc.writeBaseCost(pt, 256),
+		Storage: cc.mul(pt.WriteStoreCost, 256, 10, blocksPerYear),
+		Egress:  cc.mul(pt.DownloadBandwidthCost, 256),
+	}
+	return cc.result(cost)
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	rhp2 "go.sia.tech/core/rhp/v2"
@@ -15,6 +16,7 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/accounts"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/rhp"
 	"go.uber.org/zap"
@@ -26,9 +28,42 @@ const (
 	readRegistryType   = 2
 )
 
+// Program failure modes
+const (
+	// ProgramFailureRollback discards the changes of every instruction when a
+	// program fails. Sectors appended or stored by the program are not added
+	// to the contract or temporary storage and the contract is not revised.
+	// The renter is charged the program's init cost, the ingress of the
+	// completed instructions, and the effects that cannot be undone: the
+	// egress of outputs already sent and registry updates.
+	ProgramFailureRollback ProgramFailureMode = "rollback"
+	// ProgramFailureCommitCompleted keeps the changes of the instructions
+	// that completed before the failure. The renter pays for the completed
+	// instructions, but not the failed instruction. If the program requires
+	// finalization, the host waits for the renter's finalize request after
+	// sending the failed instruction's output and revises the contract with
+	// the state after the last completed instruction.
+	ProgramFailureCommitCompleted ProgramFailureMode = "commitCompleted"
+)
+
 type (
+	// A ProgramFailureMode determines how the host handles a program that
+	// fails before all of its instructions are executed.
+	ProgramFailureMode string
+
 	programData []byte
 
+	// An outputBuffer limits the total size of the instruction outputs that
+	// have been produced, but not yet sent to the renter.
+	outputBuffer struct {
+		max uint64
+
+		mu   sync.Mutex
+		used uint64
+		// freed is signaled when space is released
+		freed chan struct{}
+	}
+
 	programExecutor struct {
 		hostKey types.PrivateKey
 
@@ -42,17 +77,33 @@ type (
 
 		revision          *contracts.SignedRevision
 		remainingDuration uint64
-		updater           *contracts.ContractUpdater
-		tempSectors       []storage.TempSector
+		// minIngressPrice is the minimum ingress price for data stored for
+		// the remaining duration of the contract
+		minIngressPrice types.Currency
+		updater         *contracts.ContractUpdater
+		tempSectors     []storage.TempSector
 
 		finalize     bool
 		releaseFuncs []func() error
 
+		failureMode ProgramFailureMode
+		// completed is the number of instructions that were executed
+		// successfully
+		completed int
+		// failed is true if an instruction failed
+		failed bool
+
 		log       *zap.Logger
 		contracts ContractManager
 		storage   StorageManager
 		registry  RegistryManager
 
+		prefetcher *storage.Prefetcher
+		buffer     *outputBuffer
+		// reservation holds space for the sectors written by the program. It
+		// is nil if the program does not write any sectors.
+		reservation *storage.Reservation
+
 		committed bool
 	}
 )
@@ -61,8 +112,65 @@ var (
 	// ErrContractRequired is returned when a contract is required to execute a
 	// program but is not provided
 	ErrContractRequired = errors.New("contract required")
+	// ErrTooManySectors is returned when a program reads or writes more
+	// sectors than the host allows in a single RPC
+	ErrTooManySectors = errors.New("program exceeds max sectors per RPC")
+	// ErrInsufficientBudget is returned when the payment for a program cannot
+	// cover the program's estimated cost
+	ErrInsufficientBudget = errors.New("insufficient budget")
+	// ErrProgramBufferExceeded is returned when an instruction's output is
+	// larger than the host's program output buffer
+	ErrProgramBufferExceeded = errors.New("instruction output exceeds program buffer")
 )
 
+// bufferedSize returns the number of bytes of an instruction's output and
+// proof held in the program buffer.
+func bufferedSize(output []byte, proof []types.Hash256) uint64 {
+	return uint64(len(output)) + uint64(len(proof))*32
+}
+
+func newOutputBuffer(max uint64) *outputBuffer {
+	return &outputBuffer{
+		max:   max,
+		freed: make(chan struct{}, 1),
+	}
+}
+
+// acquire reserves n bytes of the buffer, waiting for space to be released
+// if necessary.
+func (ob *outputBuffer) acquire(ctx context.Context, n uint64) error {
+	if n > ob.max {
+		return fmt.Errorf("%w: output is %d bytes, limit is %d bytes", ErrProgramBufferExceeded, n, ob.max)
+	}
+	for {
+		ob.mu.Lock()
+		if ob.used+n <= ob.max {
+			ob.used += n
+			ob.mu.Unlock()
+			return nil
+		}
+		ob.mu.Unlock()
+
+		select {
+		case <-ob.freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release releases n bytes of the buffer.
+func (ob *outputBuffer) release(n uint64) {
+	ob.mu.Lock()
+	ob.used -= n
+	ob.mu.Unlock()
+
+	select {
+	case ob.freed <- struct{}{}:
+	default:
+	}
+}
+
 func (pe *programExecutor) instructionOutput(output []byte, proof []types.Hash256, err error) rhp3.RPCExecuteProgramResponse {
 	resp := rhp3.RPCExecuteProgramResponse{
 		AdditionalCollateral: pe.cost.Collateral,
@@ -80,6 +188,14 @@ func (pe *programExecutor) instructionOutput(output []byte, proof []types.Hash25
 	return resp
 }
 
+// refundInstruction refunds the spending of a failed instruction. cost and
+// usage are the program's cost and usage before the instruction was
+// executed.
+func (pe *programExecutor) refundInstruction(cost rhp3.ResourceCost, usage accounts.Usage) {
+	pe.budget.Refund(pe.usage.Sub(usage))
+	pe.cost, pe.usage = cost, usage
+}
+
 func (pe *programExecutor) payForExecution(cost rhp3.ResourceCost, usage accounts.Usage) error {
 	if err := pe.budget.Spend(usage); err != nil {
 		return err
@@ -89,6 +205,100 @@ func (pe *programExecutor) payForExecution(cost rhp3.ResourceCost, usage account
 	return nil
 }
 
+// instructionCost returns the cost of executing an instruction and the
+// resulting account usage. Arguments that affect the cost are read from the
+// program data.
+func (pe *programExecutor) instructionCost(instruction rhp3.Instruction) (rhp3.ResourceCost, accounts.Usage, error) {
+	var cost rhp3.ResourceCost
+	var err error
+	switch instr := instruction.(type) {
+	case *rhp3.InstrAppendSector:
+		cost, err = appendSectorCost(&pe.priceTable, pe.remainingDuration)
+	case *rhp3.InstrAppendSectorRoot:
+		cost, err = appendSectorRootCost(&pe.priceTable, pe.remainingDuration)
+	case *rhp3.InstrDropSectors:
+		var count uint64
+		count, err = pe.programData.Uint64(instr.SectorCountOffset)
+		if err != nil {
+			return rhp3.ResourceCost{}, accounts.Usage{}, fmt.Errorf("failed to read sector count: %w", err)
+		}
+		cost, err = dropSectorsCost(&pe.priceTable, count)
+	case *rhp3.InstrHasSector:
+		cost, err = hasSectorCost(&pe.priceTable)
+	case *rhp3.InstrReadOffset:
+		var length uint64
+		length, err = pe.programData.Uint64(instr.LengthOffset)
+		if err != nil {
+			return rhp3.ResourceCost{}, accounts.Usage{}, fmt.Errorf("failed to read length: %w", err)
+		}
+		cost, err = readOffsetCost(&pe.priceTable, length)
+	case *rhp3.InstrReadSector:
+		var length uint64
+		length, err = pe.programData.Uint64(instr.LengthOffset)
+		if err != nil {
+			return rhp3.ResourceCost{}, accounts.Usage{}, fmt.Errorf("failed to read length: %w", err)
+		}
+		cost, err = readSectorCost(&pe.priceTable, length)
+	case *rhp3.InstrSwapSector:
+		cost, err = swapSectorCost(&pe.priceTable)
+	case *rhp3.InstrUpdateSector:
+		cost, err = updateSectorCost(&pe.priceTable, instr.Length)
+	case *rhp3.InstrStoreSector:
+		cost, err = storeSectorCost(&pe.priceTable, instr.Duration)
+	case *rhp3.InstrRevision:
+		cost, err = revisionCost(&pe.priceTable)
+	case *rhp3.InstrReadRegistry, *rhp3.InstrReadRegistryNoVersion:
+		cost, err = readRegistryCost(&pe.priceTable)
+		if err != nil {
+			return rhp3.ResourceCost{}, accounts.Usage{}, err
+		}
+		return cost, accounts.Usage{
+			RPCRevenue:     cost.Base,
+			RegistryRead:   cost.Storage,
+			IngressRevenue: cost.Ingress,
+			EgressRevenue:  cost.Egress,
+		}, nil
+	case *rhp3.InstrUpdateRegistry, *rhp3.InstrUpdateRegistryNoType:
+		cost, err = readRegistryCost(&pe.priceTable)
+		if err != nil {
+			return rhp3.ResourceCost{}, accounts.Usage{}, err
+		}
+		return cost, accounts.Usage{
+			RPCRevenue:     cost.Base,
+			RegistryWrite:  cost.Storage,
+			IngressRevenue: cost.Ingress,
+			EgressRevenue:  cost.Egress,
+		}, nil
+	default:
+		return rhp3.ResourceCost{}, accounts.Usage{}, fmt.Errorf("unknown instruction: %T", instr)
+	}
+	if err != nil {
+		return rhp3.ResourceCost{}, accounts.Usage{}, err
+	}
+	return cost, costToAccountUsage(cost), nil
+}
+
+// payForInstruction pays for executing an instruction from the program's
+// budget.
+func (pe *programExecutor) payForInstruction(instr rhp3.Instruction) error {
+	cost, usage, err := pe.instructionCost(instr)
+	if err != nil {
+		return fmt.Errorf("failed to calculate instruction cost: %w", err)
+	}
+	if err := pe.payForExecution(cost, usage); err != nil {
+		return fmt.Errorf("failed to pay for instruction: %w", err)
+	}
+	return nil
+}
+
+// writeSector writes a sector using the program's reservation.
+func (pe *programExecutor) writeSector(root types.Hash256, sector *[rhp2.SectorSize]byte) (func() error, error) {
+	if pe.reservation == nil {
+		return pe.storage.Write(root, sector)
+	}
+	return pe.reservation.Write(root, sector)
+}
+
 func (pe *programExecutor) executeAppendSector(instr *rhp3.InstrAppendSector, log *zap.Logger) ([]byte, []types.Hash256, error) {
 	sector, err := pe.programData.Sector(instr.SectorDataOffset)
 	if err != nil {
@@ -97,13 +307,17 @@ func (pe *programExecutor) executeAppendSector(instr *rhp3.InstrAppendSector, lo
 	rootCalcStart := time.Now()
 	root := rhp2.SectorRoot(sector)
 	log.Debug("calculated sector root", zap.Duration("duration", time.Since(rootCalcStart)))
+	// uploaded data must cover the collateral risked for the rest of the
+	// contract
+	if pe.priceTable.UploadBandwidthCost.Cmp(pe.minIngressPrice) < 0 {
+		return nil, nil, fmt.Errorf("%w: ingress price %v is less than the minimum %v for %d blocks", settings.ErrIngressPriceTooLow, pe.priceTable.UploadBandwidthCost, pe.minIngressPrice, pe.remainingDuration)
+	}
 	// pay for execution
-	cost := pe.priceTable.AppendSectorCost(pe.remainingDuration)
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, nil, err
 	}
 
-	release, err := pe.storage.Write(root, sector)
+	release, err := pe.writeSector(root, sector)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to write sector: %w", err)
 	}
@@ -127,9 +341,8 @@ func (pe *programExecutor) executeAppendSectorRoot(instr *rhp3.InstrAppendSector
 		return nil, nil, fmt.Errorf("failed to read sector root: %w", err)
 	}
 	// pay for execution
-	cost := pe.priceTable.AppendSectorRootCost(pe.remainingDuration)
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, nil, err
 	}
 
 	// lock the sector to prevent it from being garbage collected
@@ -155,9 +368,8 @@ func (pe *programExecutor) executeDropSectors(instr *rhp3.InstrDropSectors, log
 		return nil, nil, fmt.Errorf("failed to read sector count: %w", err)
 	}
 	// pay for execution
-	cost := pe.priceTable.DropSectorsCost(count)
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, nil, err
 	}
 
 	// construct the proof before updating the roots
@@ -181,9 +393,8 @@ func (pe *programExecutor) executeHasSector(instr *rhp3.InstrHasSector) ([]byte,
 		return nil, nil, fmt.Errorf("failed to read sector root: %w", err)
 	}
 	// pay for execution
-	cost := pe.priceTable.HasSectorCost()
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, nil, err
 	}
 
 	var has bool
@@ -212,9 +423,8 @@ func (pe *programExecutor) executeReadOffset(instr *rhp3.InstrReadOffset, log *z
 		return nil, nil, fmt.Errorf("failed to read length: %w", err)
 	}
 	// pay for execution
-	cost := pe.priceTable.ReadOffsetCost(length)
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, nil, err
 	}
 
 	sectorIndex := offset / rhp2.SectorSize
@@ -229,6 +439,9 @@ func (pe *programExecutor) executeReadOffset(instr *rhp3.InstrReadOffset, log *z
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read sector: %w", err)
 	}
+	if pe.prefetcher != nil {
+		pe.prefetcher.Observe(pe.revision.Revision.ParentID, sectorIndex, pe.updater.SectorRoot)
+	}
 
 	// if no proof was requested, return the data
 	if !instr.ProofRequired {
@@ -268,9 +481,8 @@ func (pe *programExecutor) executeReadSector(instr *rhp3.InstrReadSector, log *z
 	}
 
 	// pay for execution
-	cost := pe.priceTable.ReadSectorCost(length)
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, nil, err
 	}
 
 	sector, err := pe.storage.Read(root)
@@ -305,9 +517,8 @@ func (pe *programExecutor) executeSwapSector(instr *rhp3.InstrSwapSector, log *z
 	}
 
 	// pay for execution
-	cost := pe.priceTable.SwapSectorCost()
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, nil, err
 	}
 
 	var output []byte
@@ -346,9 +557,8 @@ func (pe *programExecutor) executeUpdateSector(instr *rhp3.InstrUpdateSector, _
 	}
 
 	// pay for execution
-	cost := pe.priceTable.UpdateSectorCost(instr.Length)
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, nil, err
 	}
 
 	sectorIndex := offset / rhp2.SectorSize
@@ -372,7 +582,7 @@ func (pe *programExecutor) executeUpdateSector(instr *rhp3.InstrUpdateSector, _
 
 	// store the new sector
 	newRoot := rhp2.SectorRoot((*[rhp2.SectorSize]byte)(sector))
-	release, err := pe.storage.Write(newRoot, sector)
+	release, err := pe.writeSector(newRoot, sector)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to write sector: %w", err)
 	}
@@ -393,9 +603,8 @@ func (pe *programExecutor) executeStoreSector(instr *rhp3.InstrStoreSector, log
 	log.Debug("calculated sector root", zap.Duration("duration", time.Since(rootCalcStart)))
 
 	// pay for execution
-	cost := pe.priceTable.StoreSectorCost(instr.Duration)
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, err
 	}
 
 	if instr.Duration == 0 {
@@ -405,7 +614,7 @@ func (pe *programExecutor) executeStoreSector(instr *rhp3.InstrStoreSector, log
 	}
 
 	// store the sector
-	release, err := pe.storage.Write(root, sector)
+	release, err := pe.writeSector(root, sector)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write sector: %w", err)
 	}
@@ -419,11 +628,10 @@ func (pe *programExecutor) executeStoreSector(instr *rhp3.InstrStoreSector, log
 	return root[:], nil
 }
 
-func (pe *programExecutor) executeRevision(*rhp3.InstrRevision) ([]byte, error) {
+func (pe *programExecutor) executeRevision(instr *rhp3.InstrRevision) ([]byte, error) {
 	// pay for execution
-	cost := pe.priceTable.RevisionCost()
-	if err := pe.payForExecution(cost, costToAccountUsage(cost)); err != nil {
-		return nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, err
 	}
 
 	var buf bytes.Buffer
@@ -460,15 +668,8 @@ func (pe *programExecutor) executeReadRegistry(instr *rhp3.InstrReadRegistry) ([
 	}
 
 	// pay for execution
-	cost := pe.priceTable.ReadRegistryCost()
-	usage := accounts.Usage{
-		RPCRevenue:     cost.Base,
-		RegistryRead:   cost.Storage,
-		IngressRevenue: cost.Ingress,
-		EgressRevenue:  cost.Egress,
-	}
-	if err := pe.payForExecution(cost, usage); err != nil {
-		return nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, err
 	}
 
 	key := rhp3.RegistryKey{
@@ -524,15 +725,8 @@ func (pe *programExecutor) executeUpdateRegistry(instr *rhp3.InstrUpdateRegistry
 	}
 
 	// pay for execution
-	cost := pe.priceTable.ReadRegistryCost()
-	usage := accounts.Usage{
-		RPCRevenue:     cost.Base,
-		RegistryWrite:  cost.Storage,
-		IngressRevenue: cost.Ingress,
-		EgressRevenue:  cost.Egress,
-	}
-	if err := pe.payForExecution(cost, usage); err != nil {
-		return nil, fmt.Errorf("failed to pay for instruction: %w", err)
+	if err := pe.payForInstruction(instr); err != nil {
+		return nil, err
 	}
 
 	value := rhp3.RegistryEntry{
@@ -578,6 +772,7 @@ func (pe *programExecutor) executeProgram(ctx context.Context) <-chan rhp3.RPCEx
 			log := pe.log.Named(instrLabel(instruction)).With(zap.Int("instruction", i+1), zap.Int("total", len(pe.instructions)))
 
 			start := time.Now()
+			cost, usage := pe.cost, pe.usage
 			// execute the instruction
 			switch instr := instruction.(type) {
 			case *rhp3.InstrAppendSector:
@@ -615,10 +810,20 @@ func (pe *programExecutor) executeProgram(ctx context.Context) <-chan rhp3.RPCEx
 				return
 			}
 			if err != nil {
+				// the failed instruction is not charged
+				pe.refundInstruction(cost, usage)
 				outputs <- pe.instructionOutput(nil, nil, fmt.Errorf("failed to execute instruction %q: %w", instrLabel(instruction), err))
 				return
 			}
 			log.Debug("executed instruction", zap.Duration("elapsed", time.Since(start)))
+			// wait for the renter to receive earlier outputs to bound the
+			// program's memory usage
+			if err := pe.buffer.acquire(ctx, bufferedSize(output, proof)); err != nil {
+				pe.refundInstruction(cost, usage)
+				outputs <- pe.instructionOutput(nil, nil, fmt.Errorf("failed to buffer output of instruction %q: %w", instrLabel(instruction), err))
+				return
+			}
+			pe.completed++
 			outputs <- pe.instructionOutput(output, proof, err)
 		}
 	}()
@@ -654,6 +859,24 @@ func (pe *programExecutor) rollback() error {
 		pe.updater.Close()
 	}
 
+	if pe.failed && pe.failureMode == ProgramFailureRollback {
+		// the changes of every instruction are discarded. Refund everything
+		// except the data already transferred and the effects that cannot
+		// be undone.
+		kept := accounts.Usage{
+			IngressRevenue: pe.usage.IngressRevenue,
+			EgressRevenue:  pe.usage.EgressRevenue,
+			RegistryWrite:  pe.usage.RegistryWrite,
+		}
+		pe.budget.Refund(pe.usage.Sub(kept))
+		if err := pe.budget.Commit(); err != nil {
+			return fmt.Errorf("failed to commit budget: %w", err)
+		}
+		pe.usage = kept
+		pe.cost = rhp3.ResourceCost{Ingress: pe.cost.Ingress, Egress: pe.cost.Egress}
+		return nil
+	}
+
 	// refund only the storage spending
 	pe.budget.Refund(accounts.Usage{StorageRevenue: pe.usage.StorageRevenue})
 	if err := pe.budget.Commit(); err != nil {
@@ -704,14 +927,14 @@ func (pe *programExecutor) commit(s *rhp3.Stream) error {
 		revision, err := rhp.Revise(existing, req.RevisionNumber, req.ValidProofValues, req.MissedProofValues)
 		if err != nil {
 			err = fmt.Errorf("failed to revise contract: %w", err)
-			s.WriteResponseErr(err)
+			s.WriteResponseErr(rpcError(err))
 			return err
 		}
 
 		_, err = rhp.ValidateProgramRevision(existing, revision, pe.cost.Storage, pe.cost.Collateral)
 		if err != nil {
 			err = fmt.Errorf("failed to validate program revision: %w", err)
-			s.WriteResponseErr(err)
+			s.WriteResponseErr(rpcError(err))
 			return err
 		}
 
@@ -832,10 +1055,19 @@ func (pe *programExecutor) Execute(ctx context.Context, s *rhp3.Stream) error {
 	for output := range pe.executeProgram(ctx) {
 		start := time.Now()
 		err := s.WriteResponse(&output)
+		pe.buffer.release(bufferedSize(output.Output, output.Proof))
 		pe.log.Debug("wrote program output", zap.Int("outputLen", len(output.Output)), zap.Error(output.Error), zap.Duration("elapsed", time.Since(start)))
 		if err != nil {
 			return fmt.Errorf("failed to write program output: %w", err)
 		} else if output.Error != nil {
+			pe.failed = true
+			// the output of the failed instruction is the last output, so
+			// the program's state will not change
+			if pe.failureMode == ProgramFailureCommitCompleted && pe.completed > 0 {
+				if err := pe.commit(s); err != nil {
+					return fmt.Errorf("failed to commit completed instructions: %w", err)
+				}
+			}
 			return output.Error
 		}
 	}
@@ -857,7 +1089,7 @@ func (pe *programExecutor) Usage() (usage contracts.Usage) {
 	return usage
 }
 
-func (sh *SessionHandler) newExecutor(instructions []rhp3.Instruction, data []byte, pt rhp3.HostPriceTable, budget *accounts.Budget, revision *contracts.SignedRevision, finalize bool, log *zap.Logger) (*programExecutor, error) {
+func (sh *SessionHandler) newExecutor(instructions []rhp3.Instruction, data []byte, pt rhp3.HostPriceTable, budget *accounts.Budget, revision *contracts.SignedRevision, finalize bool, prefetcher *storage.Prefetcher, log *zap.Logger) (*programExecutor, error) {
 	ex := &programExecutor{
 		hostKey: sh.privateKey,
 
@@ -874,10 +1106,16 @@ func (sh *SessionHandler) newExecutor(instructions []rhp3.Instruction, data []by
 		contracts: sh.contracts,
 		storage:   sh.storage,
 		registry:  sh.registry,
+
+		prefetcher: prefetcher,
+		buffer:     newOutputBuffer(sh.maxProgramBuffer),
+
+		failureMode: sh.programFailureMode,
 	}
 
 	if revision != nil {
 		ex.remainingDuration = revision.Revision.WindowEnd - pt.HostBlockHeight
+		ex.minIngressPrice = sh.settings.AdvertisedSettings().MinIngressPrice(ex.remainingDuration)
 		updater, err := sh.contracts.ReviseContract(revision.Revision.ParentID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create contract updater: %w", err)
@@ -887,6 +1125,30 @@ func (sh *SessionHandler) newExecutor(instructions []rhp3.Instruction, data []by
 	return ex, nil
 }
 
+// programSectors returns the number of sectors a program reads or writes.
+// Instructions that only modify the contract's sector roots are not counted
+// since they do not load sector data into memory.
+func programSectors(instructions []rhp3.Instruction) (n uint64) {
+	for _, instr := range instructions {
+		switch instr.(type) {
+		case *rhp3.InstrAppendSector, *rhp3.InstrReadSector, *rhp3.InstrReadOffset, *rhp3.InstrUpdateSector, *rhp3.InstrStoreSector:
+			n++
+		}
+	}
+	return
+}
+
+// programWrites returns the number of sectors a program writes to disk.
+func programWrites(instructions []rhp3.Instruction) (n uint64) {
+	for _, instr := range instructions {
+		switch instr.(type) {
+		case *rhp3.InstrAppendSector, *rhp3.InstrUpdateSector, *rhp3.InstrStoreSector:
+			n++
+		}
+	}
+	return
+}
+
 func instrLabel(instr rhp3.Instruction) string {
 	switch instr.(type) {
 	case *rhp3.InstrAppendSector:
@@ -0,0 +1,100 @@
+package rhp
+
+import (
+	"errors"
+	"fmt"
+
+	rhp3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/hostd/host/settings"
+)
+
+type (
+	// ProgramLimits are the limits the host enforces on RHP3 programs. A
+	// limit of zero means the host does not enforce it.
+	ProgramLimits struct {
+		// MaxSectors is the maximum number of sectors a program can read or
+		// write.
+		MaxSectors uint64 `json:"maxsectors"`
+		// MaxReadSectors is the maximum number of sectors a program can read.
+		MaxReadSectors uint64 `json:"maxreadsectors"`
+		// MaxAppendSectors is the maximum number of sectors a program can
+		// append to a contract.
+		MaxAppendSectors uint64 `json:"maxappendsectors"`
+		// MaxInstructions is the maximum number of instructions in a program.
+		MaxInstructions uint64 `json:"maxprograminstructions"`
+	}
+
+	// A PriceTable is an RHP3 price table with the host's program limits.
+	// It is sent to renters in place of the plain price table so they can
+	// size their programs. Renters that are not aware of the limits ignore
+	// the additional fields.
+	PriceTable struct {
+		rhp3.HostPriceTable
+		ProgramLimits
+	}
+)
+
+var (
+	// ErrTooManyInstructions is returned when a program has more
+	// instructions than the host allows.
+	ErrTooManyInstructions = errors.New("program exceeds max instructions")
+	// ErrTooManyReads is returned when a program reads more sectors than
+	// the host allows.
+	ErrTooManyReads = errors.New("program exceeds max read sectors")
+	// ErrTooManyAppends is returned when a program appends more sectors than
+	// the host allows.
+	ErrTooManyAppends = errors.New("program exceeds max append sectors")
+)
+
+// minLimit returns the smaller of two limits, treating zero as unlimited.
+func minLimit(a, b uint64) uint64 {
+	switch {
+	case a == 0:
+		return b
+	case b == 0:
+		return a
+	case a < b:
+		return a
+	default:
+		return b
+	}
+}
+
+// ProgramLimitsFrom returns the program limits enforced with the given
+// settings. The same limits are used to validate programs and are
+// advertised in the price table so the two cannot drift apart.
+func ProgramLimitsFrom(s settings.Settings) ProgramLimits {
+	return ProgramLimits{
+		MaxSectors: s.MaxSectorsPerRPC,
+		// a program can never read or append more sectors than it can
+		// access in total.
+		MaxReadSectors:   minLimit(s.MaxReadSectorsPerProgram, s.MaxSectorsPerRPC),
+		MaxAppendSectors: minLimit(s.MaxAppendSectorsPerProgram, s.MaxSectorsPerRPC),
+		MaxInstructions:  s.MaxProgramInstructions,
+	}
+}
+
+// Check returns an error if the program exceeds any of the limits.
+func (pl ProgramLimits) Check(instructions []rhp3.Instruction) error {
+	var reads, appends uint64
+	for _, instr := range instructions {
+		switch instr.(type) {
+		case *rhp3.InstrReadSector, *rhp3.InstrReadOffset:
+			reads++
+		case *rhp3.InstrAppendSector:
+			appends++
+		}
+	}
+
+	switch {
+	case pl.MaxInstructions > 0 && uint64(len(instructions)) > pl.MaxInstructions:
+		return fmt.Errorf("%w: program has %d instructions, max %d", ErrTooManyInstructions, len(instructions), pl.MaxInstructions)
+	case pl.MaxSectors > 0 && programSectors(instructions) > pl.MaxSectors:
+		return fmt.Errorf("%w: program accesses %d sectors, max %d", ErrTooManySectors, programSectors(instructions), pl.MaxSectors)
+	case pl.MaxReadSectors > 0 && reads > pl.MaxReadSectors:
+		return fmt.Errorf("%w: program reads %d sectors, max %d", ErrTooManyReads, reads, pl.MaxReadSectors)
+	case pl.MaxAppendSectors > 0 && appends > pl.MaxAppendSectors:
+		return fmt.Errorf("%w: program appends %d sectors, max %d", ErrTooManyAppends, appends, pl.MaxAppendSectors)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package rhp
+
+import (
+	"time"
+
+	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/rhp"
+)
+
+// A SessionHandlerOption configures a SessionHandler.
+type SessionHandlerOption func(*SessionHandler)
+
+// WithHandshakeTimeout sets the time a peer has to complete the protocol
+// handshake before its connection is closed. A timeout of 0 disables the
+// limit. The default is 30 seconds.
+func WithHandshakeTimeout(d time.Duration) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.handshakeTimeout = d
+	}
+}
+
+// WithMaxProgramBuffer sets the maximum number of bytes of instruction output
+// and proofs a program can buffer in memory while waiting for them to be sent
+// to the renter. Execution pauses while the buffer is full and instructions
+// with larger outputs are rejected with ErrProgramBufferExceeded. The buffer
+// must hold at least a sector and its proof. Zero keeps the default of 64 MiB.
+func WithMaxProgramBuffer(n uint64) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		if n > 0 {
+			sh.maxProgramBuffer = n
+		}
+	}
+}
+
+// WithProgramFailureMode sets how the host handles programs that fail before
+// all of their instructions are executed. The default is
+// ProgramFailureRollback.
+func WithProgramFailureMode(mode ProgramFailureMode) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.programFailureMode = mode
+	}
+}
+
+// WithNetworkTagger sets the function used to identify the network each
+// connection was received on. By default, all connections are clearnet.
+// WebSocket connections are always clearnet.
+func WithNetworkTagger(tagger rhp.NetworkTagger) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.tagger = tagger
+	}
+}
+
+// WithNetworkPolicy overrides the host's contract acceptance and pricing for
+// renters connecting over the network.
+func WithNetworkPolicy(network rhp.NetworkType, policy settings.NetworkPolicy) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		if sh.policies == nil {
+			sh.policies = make(map[rhp.NetworkType]settings.NetworkPolicy)
+		}
+		sh.policies[network] = policy
+	}
+}
+
+// WithMinStorageHeadroom sets the number of free sectors the host must have
+// to renew contracts. While free storage is below the headroom, renewals are
+// rejected. The default of 0 disables the check.
+func WithMinStorageHeadroom(sectors uint64) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.minHeadroom = sectors
+	}
+}
+
+// WithContractLatencyRecorder records the time spent in each phase of
+// contract formation and renewal. By default, latency is not recorded.
+func WithContractLatencyRecorder(r ContractLatencyRecorder) SessionHandlerOption {
+	return func(sh *SessionHandler) {
+		sh.latency = r
+	}
+}
@@ -24,7 +24,10 @@ func (sh *SessionHandler) processContractPayment(s *rhp3.Stream, _ uint64) (rhp3
 	defer cancel()
 
 	contract, err := sh.contracts.Lock(ctx, req.ContractID)
-	if err != nil {
+	if errors.Is(err, contracts.ErrContractQuarantined) {
+		s.WriteResponseErr(err)
+		return rhp3.ZeroAccount, types.ZeroCurrency, err
+	} else if err != nil {
 		s.WriteResponseErr(ErrHostInternalError)
 		return rhp3.ZeroAccount, types.ZeroCurrency, fmt.Errorf("failed to lock contract %v: %w", req.ContractID, err)
 	}
@@ -34,7 +37,7 @@ func (sh *SessionHandler) processContractPayment(s *rhp3.Stream, _ uint64) (rhp3
 	revision, err := rhp.Revise(current, req.RevisionNumber, req.ValidProofValues, req.MissedProofValues)
 	if err != nil {
 		err = fmt.Errorf("failed to revise contract: %w", err)
-		s.WriteResponseErr(err)
+		s.WriteResponseErr(rpcError(err))
 		return rhp3.ZeroAccount, types.ZeroCurrency, err
 	}
 
@@ -49,7 +52,7 @@ func (sh *SessionHandler) processContractPayment(s *rhp3.Stream, _ uint64) (rhp3
 	// validate that new revision
 	if err := rhp.ValidatePaymentRevision(current, revision, fundAmount); err != nil {
 		err = fmt.Errorf("invalid payment revision: %w", err)
-		s.WriteResponseErr(err)
+		s.WriteResponseErr(rpcError(err))
 		return rhp3.ZeroAccount, types.ZeroCurrency, err
 	}
 
@@ -164,7 +167,10 @@ func (sh *SessionHandler) processFundAccountPayment(pt rhp3.HostPriceTable, s *r
 	defer cancel()
 
 	contract, err := sh.contracts.Lock(ctx, req.ContractID)
-	if err != nil {
+	if errors.Is(err, contracts.ErrContractQuarantined) {
+		s.WriteResponseErr(err)
+		return types.ZeroCurrency, types.ZeroCurrency, err
+	} else if err != nil {
 		s.WriteResponseErr(ErrHostInternalError)
 		return types.ZeroCurrency, types.ZeroCurrency, fmt.Errorf("failed to lock contract %v: %w", req.ContractID, err)
 	}
@@ -174,7 +180,7 @@ func (sh *SessionHandler) processFundAccountPayment(pt rhp3.HostPriceTable, s *r
 	revision, err := rhp.Revise(current, req.RevisionNumber, req.ValidProofValues, req.MissedProofValues)
 	if err != nil {
 		err := fmt.Errorf("failed to revise contract: %w", err)
-		s.WriteResponseErr(err)
+		s.WriteResponseErr(rpcError(err))
 		return types.ZeroCurrency, types.ZeroCurrency, err
 	}
 
@@ -189,7 +195,7 @@ func (sh *SessionHandler) processFundAccountPayment(pt rhp3.HostPriceTable, s *r
 	// validate that new revision
 	if err := rhp.ValidatePaymentRevision(current, revision, totalAmount); err != nil {
 		err = fmt.Errorf("invalid payment revision: %w", err)
-		s.WriteResponseErr(err)
+		s.WriteResponseErr(rpcError(err))
 		return types.ZeroCurrency, types.ZeroCurrency, err
 	}
 
@@ -216,9 +222,12 @@ func (sh *SessionHandler) processFundAccountPayment(pt rhp3.HostPriceTable, s *r
 	// credit the account with the deposit
 	balance, err = sh.accounts.Credit(fundReq, false)
 	if err != nil {
-		if errors.Is(err, accounts.ErrBalanceExceeded) {
+		switch {
+		case errors.Is(err, accounts.ErrBalanceExceeded):
 			s.WriteResponseErr(accounts.ErrBalanceExceeded)
-		} else {
+		case errors.Is(err, accounts.ErrDepositTooSmall):
+			s.WriteResponseErr(accounts.ErrDepositTooSmall)
+		default:
 			s.WriteResponseErr(ErrHostInternalError)
 		}
 		return types.ZeroCurrency, types.ZeroCurrency, fmt.Errorf("failed to credit account: %w", err)
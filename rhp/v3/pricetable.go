@@ -9,6 +9,8 @@ import (
 
 	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/settings"
+	"go.sia.tech/hostd/rhp"
 	"lukechampine.com/frand"
 )
 
@@ -104,14 +106,44 @@ func (pm *priceTableManager) Register(pt rhp3.HostPriceTable) {
 	}
 }
 
-// PriceTable returns the session handler's current price table.
+// PriceTable returns the session handler's current price table. It is
+// derived from the same snapshot as the RHP2 host settings.
 func (sh *SessionHandler) PriceTable() (rhp3.HostPriceTable, error) {
-	settings := sh.settings.Settings()
+	return sh.PriceTableFrom(sh.settings.AdvertisedSettings())
+}
+
+// networkSettings returns a snapshot of the host's configuration with the
+// policy of the network applied.
+func (sh *SessionHandler) networkSettings(network rhp.NetworkType) settings.Settings {
+	s := sh.settings.AdvertisedSettings()
+	if policy, ok := sh.policies[network]; ok {
+		s = policy.Apply(s)
+	}
+	return s
+}
+
+// PriceTableFrom returns a price table generated from a snapshot of the
+// host's configuration.
+func (sh *SessionHandler) PriceTableFrom(settings settings.Settings) (rhp3.HostPriceTable, error) {
 	count, limit, err := sh.registry.Entries()
 	if err != nil {
 		return rhp3.HostPriceTable{}, fmt.Errorf("failed to get registry entries: %w", err)
 	}
 
+	collateral, err := rhp.CollateralPrice(settings.StoragePrice, settings.CollateralMultiplier)
+	if err != nil {
+		return rhp3.HostPriceTable{}, fmt.Errorf("failed to calculate collateral: %w", err)
+	}
+	// the estimated bandwidth cost of downloading a file contract
+	revisionEgress, err := rhp.MulCurrency(settings.EgressPrice, 2048)
+	if err != nil {
+		return rhp3.HostPriceTable{}, fmt.Errorf("failed to calculate latest revision cost: %w", err)
+	}
+	latestRevisionCost, overflow := settings.BaseRPCPrice.AddWithOverflow(revisionEgress)
+	if overflow {
+		return rhp3.HostPriceTable{}, fmt.Errorf("failed to calculate latest revision cost: %w", rhp.ErrPriceOverflow)
+	}
+
 	fee := sh.tpool.RecommendedFee()
 	currentHeight := sh.chain.TipState().Index.Height
 	oneHasting := types.NewCurrency64(1)
@@ -147,11 +179,11 @@ func (sh *SessionHandler) PriceTable() (rhp3.HostPriceTable, error) {
 		// bandwidth cost of downloading a filecontract. This isn't perfect but
 		// at least scales a bit as the host updates their download bandwidth
 		// prices.
-		LatestRevisionCost: settings.BaseRPCPrice.Add(settings.EgressPrice.Mul64(2048)),
+		LatestRevisionCost: latestRevisionCost,
 
 		// Contract Formation/Renewal related fields
 		ContractPrice:     settings.ContractPrice,
-		CollateralCost:    settings.StoragePrice.Mul64(uint64(settings.CollateralMultiplier * 1000)).Div64(1000),
+		CollateralCost:    collateral,
 		MaxCollateral:     settings.MaxCollateral,
 		MaxDuration:       settings.MaxContractDuration,
 		WindowSize:        settings.WindowSize,
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"go.sia.tech/core/consensus"
@@ -13,6 +14,7 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/accounts"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/metrics"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/internal/threadgroup"
@@ -21,6 +23,19 @@ import (
 	"golang.org/x/time/rate"
 )
 
+const (
+	// defaultHandshakeTimeout is the time a peer has to complete the
+	// handshake before the connection is closed.
+	defaultHandshakeTimeout = 30 * time.Second
+	// defaultMaxProgramBuffer is the default number of bytes of instruction
+	// output a program can buffer in memory before it is sent to the renter.
+	defaultMaxProgramBuffer = 64 << 20 // 64 MiB
+	// minProgramBuffer is the smallest program buffer that can hold the
+	// output of any instruction: a full sector and a Merkle proof, which has
+	// at most two hashes for each level of the tree.
+	minProgramBuffer = rhp2.SectorSize + 2*64*32
+)
+
 type (
 	// An AccountManager manages deposits and withdrawals for accounts.
 	AccountManager interface {
@@ -71,6 +86,17 @@ type (
 		// as temporary sectors. Temporary sectors are short-lived sectors not
 		// associated with a contract.
 		AddTemporarySectors([]storage.TempSector) error
+
+		// ReserveSectors reserves space for n sectors that will be written
+		// by a pending upload. The reservation must be released when the
+		// upload ends.
+		ReserveSectors(n uint64) (*storage.Reservation, error)
+		// AvailableSectors returns the number of sectors that can be written
+		// without exceeding the host's storage.
+		AvailableSectors() (uint64, error)
+
+		// Prefetcher returns a new prefetcher for a renter session.
+		Prefetcher() *storage.Prefetcher
 	}
 
 	// A RegistryManager manages registry entries stored in a RegistryStore.
@@ -83,12 +109,14 @@ type (
 	// A ChainManager provides access to the current state of the blockchain.
 	ChainManager interface {
 		TipState() consensus.State
+		Synced() bool
 	}
 
 	// A Wallet manages funds and signs transactions
 	Wallet interface {
 		Address() types.Address
 		FundTransaction(txn *types.Transaction, amount types.Currency) ([]types.Hash256, func(), error)
+		OutputValues(ids []types.Hash256) (map[types.SiacoinOutputID]types.Currency, error)
 		SignTransaction(cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) error
 	}
 
@@ -101,9 +129,20 @@ type (
 	// A SettingsReporter reports the host's current configuration.
 	SettingsReporter interface {
 		Settings() settings.Settings
+		// AdvertisedSettings returns the settings advertised to renters,
+		// with the host's dynamic policies, such as collateral scaling,
+		// applied.
+		AdvertisedSettings() settings.Settings
+		ContractsPaused() bool
 		BandwidthLimiters() (ingress, egress *rate.Limiter)
 	}
 
+	// A ContractLatencyRecorder records the time spent in each phase of
+	// contract formation and renewal.
+	ContractLatencyRecorder interface {
+		StartContractTimer(metrics.ContractOperation) *metrics.ContractTimer
+	}
+
 	// SessionReporter reports session metrics
 	SessionReporter interface {
 		StartSession(conn *rhp.Conn, proto string, version int) (sessionID rhp.UID, end func())
@@ -113,11 +152,28 @@ type (
 	// A SessionHandler handles the host side of the renter-host protocol and
 	// manages renter sessions
 	SessionHandler struct {
+		rejectedHandshakes uint64 // ensure 64-bit alignment on 32-bit systems
+
 		privateKey types.PrivateKey
 
-		listener net.Listener
-		monitor  rhp.DataMonitor
-		tg       *threadgroup.ThreadGroup
+		handshakeTimeout time.Duration
+		maxProgramBuffer uint64
+		// programFailureMode determines how failed programs are handled
+		programFailureMode ProgramFailureMode
+		// minHeadroom is the number of free sectors below which renewals
+		// are rejected
+		minHeadroom uint64
+
+		// tagger identifies the network of each connection. policies
+		// override the host's settings for renters connecting over a
+		// network.
+		tagger   rhp.NetworkTagger
+		policies map[rhp.NetworkType]settings.NetworkPolicy
+
+		listeners []net.Listener
+		monitor   rhp.DataMonitor
+		latency   ContractLatencyRecorder
+		tg        *threadgroup.ThreadGroup
 
 		accounts  AccountManager
 		contracts ContractManager
@@ -168,7 +224,7 @@ var (
 )
 
 // handleHostStream handles streams routed to the "host" subscriber
-func (sh *SessionHandler) handleHostStream(s *rhp3.Stream, sessionID rhp.UID, log *zap.Logger) {
+func (sh *SessionHandler) handleHostStream(s *rhp3.Stream, sessionID rhp.UID, network rhp.NetworkType, prefetcher *storage.Prefetcher, log *zap.Logger) {
 	defer s.Close() // close the stream when the RPC has completed
 
 	done, err := sh.tg.Add() // add the RPC to the threadgroup
@@ -184,12 +240,18 @@ func (sh *SessionHandler) handleHostStream(s *rhp3.Stream, sessionID rhp.UID, lo
 		return
 	}
 	rpcs := map[types.Specifier]func(*rhp3.Stream, *zap.Logger) (contracts.Usage, error){
-		rhp3.RPCAccountBalanceID:   sh.handleRPCAccountBalance,
-		rhp3.RPCUpdatePriceTableID: sh.handleRPCPriceTable,
-		rhp3.RPCExecuteProgramID:   sh.handleRPCExecute,
-		rhp3.RPCFundAccountID:      sh.handleRPCFundAccount,
-		rhp3.RPCLatestRevisionID:   sh.handleRPCLatestRevision,
-		rhp3.RPCRenewContractID:    sh.handleRPCRenew,
+		rhp3.RPCAccountBalanceID: sh.handleRPCAccountBalance,
+		rhp3.RPCUpdatePriceTableID: func(s *rhp3.Stream, log *zap.Logger) (contracts.Usage, error) {
+			return sh.handleRPCPriceTable(s, network, log)
+		},
+		rhp3.RPCExecuteProgramID: func(s *rhp3.Stream, log *zap.Logger) (contracts.Usage, error) {
+			return sh.handleRPCExecute(s, prefetcher, log)
+		},
+		rhp3.RPCFundAccountID:    sh.handleRPCFundAccount,
+		rhp3.RPCLatestRevisionID: sh.handleRPCLatestRevision,
+		rhp3.RPCRenewContractID: func(s *rhp3.Stream, log *zap.Logger) (contracts.Usage, error) {
+			return sh.handleRPCRenew(s, network, log)
+		},
 	}
 	rpcFn, ok := rpcs[rpc]
 	if !ok {
@@ -219,13 +281,81 @@ func (sh *SessionHandler) HostKey() types.UnlockKey {
 // Close closes the session handler and stops accepting new connections.
 func (sh *SessionHandler) Close() error {
 	sh.tg.Stop()
-	return sh.listener.Close()
+	var errs []error
+	for _, l := range sh.listeners {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
-// Serve starts the host RPC server.
+// Serve starts the host RPC server on all of the handler's listeners. It
+// blocks until every listener is closed.
 func (sh *SessionHandler) Serve() error {
+	errCh := make(chan error, len(sh.listeners))
+	for _, l := range sh.listeners {
+		go func(l net.Listener) {
+			errCh <- sh.serve(l)
+		}(l)
+	}
+
+	var errs []error
+	for range sh.listeners {
+		if err := <-errCh; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// upgrade performs the RHP3 handshake. conn is closed if the handshake does not
+// complete before the handshake timeout.
+func (sh *SessionHandler) upgrade(conn net.Conn, rhpConn *rhp.Conn) (*rhp3.Transport, error) {
+	handshakeDone := rhp.GuardHandshake(conn, sh.handshakeTimeout)
+	t, err := rhp3.NewHostTransport(rhpConn, sh.privateKey)
+	if !handshakeDone() {
+		atomic.AddUint64(&sh.rejectedHandshakes, 1)
+		if err == nil {
+			t.Close()
+		}
+		return nil, rhp.ErrHandshakeTimeout
+	}
+	return t, err
+}
+
+// startContractTimer returns a timer for a contract operation or nil if
+// latency is not recorded.
+func (sh *SessionHandler) startContractTimer(op metrics.ContractOperation) *metrics.ContractTimer {
+	if sh.latency == nil {
+		return nil
+	}
+	return sh.latency.StartContractTimer(op)
+}
+
+// hasStorageHeadroom returns true if the host has more free storage than the
+// minimum headroom required to renew contracts.
+func (sh *SessionHandler) hasStorageHeadroom() (bool, error) {
+	if sh.minHeadroom == 0 {
+		return true, nil
+	}
+	available, err := sh.storage.AvailableSectors()
+	if err != nil {
+		return false, fmt.Errorf("failed to get available sectors: %w", err)
+	}
+	return available >= sh.minHeadroom, nil
+}
+
+// RejectedHandshakes returns the number of connections that were closed
+// because they did not complete the handshake before the timeout.
+func (sh *SessionHandler) RejectedHandshakes() uint64 {
+	return atomic.LoadUint64(&sh.rejectedHandshakes)
+}
+
+// serve accepts connections from a single listener until it is closed.
+func (sh *SessionHandler) serve(l net.Listener) error {
 	for {
-		conn, err := sh.listener.Accept()
+		conn, err := l.Accept()
 		if errors.Is(err, net.ErrClosed) {
 			return nil
 		} else if err != nil {
@@ -244,16 +374,18 @@ func (sh *SessionHandler) Serve() error {
 			sessionID, end := sh.sessions.StartSession(rhpConn, rhp.SessionProtocolTCP, 3)
 			defer end()
 
-			log := sh.log.With(zap.Stringer("sessionID", sessionID), zap.String("peerAddress", conn.RemoteAddr().String()))
+			network := sh.tagger(conn)
+			log := rhp.SessionLogger(sh.log, sessionID, conn.RemoteAddr().String()).With(zap.String("network", string(network)))
 
 			// upgrade the connection to RHP3
-			t, err := rhp3.NewHostTransport(rhpConn, sh.privateKey)
+			t, err := sh.upgrade(conn, rhpConn)
 			if err != nil {
 				log.Debug("failed to upgrade conn", zap.Error(err))
 				return
 			}
 			defer t.Close()
 
+			prefetcher := sh.storage.Prefetcher()
 			for {
 				stream, err := t.AcceptStream()
 				if err != nil {
@@ -263,25 +395,32 @@ func (sh *SessionHandler) Serve() error {
 					return
 				}
 
-				go sh.handleHostStream(stream, sessionID, log)
+				go sh.handleHostStream(stream, sessionID, network, prefetcher, log)
 			}
 		}()
 	}
 }
 
-// LocalAddr returns the address the host is listening on.
-func (sh *SessionHandler) LocalAddr() string {
-	return sh.listener.Addr().String()
+// LocalAddr returns the addresses the host is listening on.
+func (sh *SessionHandler) LocalAddr() []string {
+	addrs := make([]string, 0, len(sh.listeners))
+	for _, l := range sh.listeners {
+		addrs = append(addrs, l.Addr().String())
+	}
+	return addrs
 }
 
 // NewSessionHandler creates a new SessionHandler
-func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, chain ChainManager, tpool TransactionPool, wallet Wallet, accounts AccountManager, contracts ContractManager, registry RegistryManager, storage StorageManager, settings SettingsReporter, monitor rhp.DataMonitor, sessions SessionReporter, log *zap.Logger) (*SessionHandler, error) {
+func NewSessionHandler(listeners []net.Listener, hostKey types.PrivateKey, chain ChainManager, tpool TransactionPool, wallet Wallet, accounts AccountManager, contracts ContractManager, registry RegistryManager, storage StorageManager, settings SettingsReporter, monitor rhp.DataMonitor, sessions SessionReporter, log *zap.Logger, opts ...SessionHandlerOption) (*SessionHandler, error) {
+	if len(listeners) == 0 {
+		return nil, errors.New("at least one listener is required")
+	}
 	sh := &SessionHandler{
 		privateKey: hostKey,
 
-		listener: l,
-		monitor:  monitor,
-		tg:       threadgroup.New(),
+		listeners: listeners,
+		monitor:   monitor,
+		tg:        threadgroup.New(),
 
 		chain:  chain,
 		tpool:  tpool,
@@ -296,6 +435,18 @@ func NewSessionHandler(l net.Listener, hostKey types.PrivateKey, chain ChainMana
 		log:       log,
 
 		priceTables: newPriceTableManager(),
+
+		handshakeTimeout: defaultHandshakeTimeout,
+		tagger:           func(net.Conn) rhp.NetworkType { return rhp.NetworkClearnet },
+		maxProgramBuffer: defaultMaxProgramBuffer,
+
+		programFailureMode: ProgramFailureRollback,
+	}
+	for _, opt := range opts {
+		opt(sh)
+	}
+	if sh.maxProgramBuffer < minProgramBuffer {
+		return nil, fmt.Errorf("max program buffer %d is smaller than the minimum of %d bytes", sh.maxProgramBuffer, minProgramBuffer)
 	}
 	return sh, nil
 }
@@ -14,6 +14,8 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/hostd/host/accounts"
 	"go.sia.tech/hostd/host/contracts"
+	"go.sia.tech/hostd/host/metrics"
+	"go.sia.tech/hostd/host/storage"
 	"go.sia.tech/hostd/rhp"
 	"go.sia.tech/hostd/wallet"
 	"go.uber.org/zap"
@@ -42,14 +44,26 @@ var (
 	ErrNotAcceptingContracts = errors.New("host is not accepting contracts")
 )
 
+// rpcError converts err into the error sent to the renter. Validation errors
+// are sent with their code as the RPC error type so renters can identify the
+// failure without parsing its description.
+func rpcError(err error) error {
+	code, ok := rhp.ErrorCode(err)
+	if !ok {
+		return err
+	}
+	return &rhp3.RPCError{Type: code, Description: err.Error()}
+}
+
 // handleRPCPriceTable sends the host's price table to the renter.
-func (sh *SessionHandler) handleRPCPriceTable(s *rhp3.Stream, log *zap.Logger) (contracts.Usage, error) {
-	pt, err := sh.PriceTable()
+func (sh *SessionHandler) handleRPCPriceTable(s *rhp3.Stream, network rhp.NetworkType, log *zap.Logger) (contracts.Usage, error) {
+	settings := sh.networkSettings(network)
+	pt, err := sh.PriceTableFrom(settings)
 	if err != nil {
 		s.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to get price table: %w", err)
 	}
-	buf, err := json.Marshal(pt)
+	buf, err := json.Marshal(PriceTable{pt, ProgramLimitsFrom(settings)})
 	if err != nil {
 		s.WriteResponseErr(ErrHostInternalError)
 		return contracts.Usage{}, fmt.Errorf("failed to marshal price table: %w", err)
@@ -197,6 +211,10 @@ func (sh *SessionHandler) handleRPCLatestRevision(s *rhp3.Stream, log *zap.Logge
 		err := fmt.Errorf("failed to get contract %q: %w", req.ContractID, err)
 		s.WriteResponseErr(err)
 		return contracts.Usage{}, err
+	} else if contract.Quarantined {
+		err := fmt.Errorf("%w: %v", contracts.ErrContractQuarantined, req.ContractID)
+		s.WriteResponseErr(err)
+		return contracts.Usage{}, err
 	}
 
 	resp := &rhp3.RPCLatestRevisionResponse{
@@ -238,21 +256,31 @@ func (sh *SessionHandler) handleRPCLatestRevision(s *rhp3.Stream, log *zap.Logge
 	return usage, nil
 }
 
-func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contracts.Usage, error) {
+func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, network rhp.NetworkType, log *zap.Logger) (contracts.Usage, error) {
 	s.SetDeadline(time.Now().Add(2 * time.Minute))
-	if !sh.settings.Settings().AcceptingContracts {
+	if !sh.chain.Synced() {
+		s.WriteResponseErr(rhp.ErrNotSynced)
+		return contracts.Usage{}, rhp.ErrNotSynced
+	} else if !sh.networkSettings(network).AcceptingContracts || sh.settings.ContractsPaused() {
 		s.WriteResponseErr(ErrNotAcceptingContracts)
 		return contracts.Usage{}, ErrNotAcceptingContracts
+	} else if headroom, err := sh.hasStorageHeadroom(); err != nil {
+		s.WriteResponseErr(ErrHostInternalError)
+		return contracts.Usage{}, err
+	} else if !headroom {
+		s.WriteResponseErr(ErrNotAcceptingContracts)
+		return contracts.Usage{}, fmt.Errorf("%w: not enough free storage", ErrNotAcceptingContracts)
 	}
 	pt, err := sh.readPriceTable(s)
 	if errors.Is(err, ErrNoPriceTable) {
 		// no price table, send the renter a default one
-		pt, err = sh.PriceTable()
+		settings := sh.settings.AdvertisedSettings()
+		pt, err = sh.PriceTableFrom(settings)
 		if err != nil {
 			s.WriteResponseErr(ErrHostInternalError)
 			return contracts.Usage{}, fmt.Errorf("failed to get price table: %w", err)
 		}
-		buf, err := json.Marshal(pt)
+		buf, err := json.Marshal(PriceTable{pt, ProgramLimitsFrom(settings)})
 		if err != nil {
 			s.WriteResponseErr(ErrHostInternalError)
 			return contracts.Usage{}, fmt.Errorf("failed to marshal price table: %w", err)
@@ -270,7 +298,10 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	var req rhp3.RPCRenewContractRequest
 	if err := s.ReadRequest(&req, 10*maxRequestSize); err != nil {
 		return contracts.Usage{}, fmt.Errorf("failed to read renew contract request: %w", err)
-	} else if err := validRenewalTxnSet(req.TransactionSet); err != nil {
+	}
+	timer := sh.startContractTimer(metrics.OperationRenewal)
+	timer.Phase(metrics.PhaseValidation)
+	if err := validRenewalTxnSet(req.TransactionSet); err != nil {
 		err = fmt.Errorf("invalid renewal transaction set: %w", err)
 		s.WriteResponseErr(err)
 		return contracts.Usage{}, err
@@ -291,6 +322,7 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	timer.Pause()
 	existing, err := sh.contracts.Lock(ctx, clearingRevision.ParentID)
 	if err != nil {
 		err := fmt.Errorf("failed to lock contract %v: %w", clearingRevision.ParentID, err)
@@ -298,12 +330,13 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 		return contracts.Usage{}, err
 	}
 	defer sh.contracts.Unlock(clearingRevision.ParentID)
+	timer.Phase(metrics.PhaseValidation)
 
 	// validate the final revision and renter signature
 	finalPayment, err := rhp.ValidateClearingRevision(existing.Revision, clearingRevision, types.ZeroCurrency)
 	if err != nil {
 		err := fmt.Errorf("failed to validate clearing revision: %w", err)
-		s.WriteResponseErr(err)
+		s.WriteResponseErr(rpcError(err))
 		return contracts.Usage{}, err
 	}
 	finalRevisionSigHash := hashFinalRevision(clearingRevision, renewal)
@@ -326,8 +359,23 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	var baseCollateral types.Currency
 	if renewal.WindowEnd > existing.Revision.WindowEnd {
 		extension := uint64(renewal.WindowEnd - existing.Revision.WindowEnd)
-		baseRevenue = baseRevenue.Add(pt.WriteStoreCost.Mul64(renewal.Filesize).Mul64(extension))
-		baseCollateral = pt.CollateralCost.Mul64(renewal.Filesize).Mul64(extension)
+		storageCost, err := rhp.StorageCost(pt.WriteStoreCost, renewal.Filesize, extension)
+		if err != nil {
+			s.WriteResponseErr(err)
+			return contracts.Usage{}, err
+		}
+		var overflow bool
+		baseRevenue, overflow = baseRevenue.AddWithOverflow(storageCost)
+		if overflow {
+			err := fmt.Errorf("renewal revenue: %w", rhp.ErrPriceOverflow)
+			s.WriteResponseErr(err)
+			return contracts.Usage{}, err
+		}
+		baseCollateral, err = rhp.StorageCost(pt.CollateralCost, renewal.Filesize, extension)
+		if err != nil {
+			s.WriteResponseErr(err)
+			return contracts.Usage{}, err
+		}
 	}
 
 	riskedCollateral, lockedCollateral, err := validateContractRenewal(existing.Revision, renewal, hostUnlockKey, req.RenterKey, sh.wallet.Address(), baseRevenue, baseCollateral, pt)
@@ -336,6 +384,7 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 		s.WriteResponseErr(err)
 		return contracts.Usage{}, err
 	}
+	timer.Phase(metrics.PhaseFunding)
 	renterInputs, renterOutputs := len(renewalTxn.SiacoinInputs), len(renewalTxn.SiacoinOutputs)
 	toSign, release, err := sh.wallet.FundTransaction(&renewalTxn, lockedCollateral)
 	if err != nil {
@@ -348,6 +397,18 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	}
 	defer release()
 
+	// verify the host is not contributing more than its collateral
+	inputValues, err := sh.wallet.OutputValues(toSign)
+	if err != nil {
+		s.WriteResponseErr(ErrHostInternalError)
+		return contracts.Usage{}, fmt.Errorf("failed to get host input values: %w", err)
+	} else if err := rhp.ValidateFunding(parents, renewalTxn, renterInputs, renterOutputs, sh.wallet.Address(), inputValues, lockedCollateral); err != nil {
+		err := fmt.Errorf("failed to validate renewal: %w", err)
+		s.WriteResponseErr(err)
+		return contracts.Usage{}, err
+	}
+	timer.Pause()
+
 	hostAdditions := &rhp3.RPCRenewContractHostAdditions{
 		SiacoinInputs:          renewalTxn.SiacoinInputs[renterInputs:],
 		SiacoinOutputs:         renewalTxn.SiacoinOutputs[renterOutputs:],
@@ -363,6 +424,7 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	}
 
 	// create the initial revision and verify the renter's signature
+	timer.Phase(metrics.PhaseValidation)
 	renewalRevision := rhp.InitialRevision(&renewalTxn, hostUnlockKey, req.RenterKey)
 	renewalSigHash := rhp.HashRevision(renewalRevision)
 	if err := validateRenterRevisionSignature(renterSigsResp.RevisionSignature, renewalRevision.ParentID, renewalSigHash, renterKey); err != nil {
@@ -370,6 +432,7 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 		s.WriteResponseErr(err)
 		return contracts.Usage{}, err
 	}
+	timer.Phase(metrics.PhaseSigning)
 	signedRenewal := contracts.SignedRevision{
 		Revision:        renewalRevision,
 		HostSignature:   sh.privateKey.SignHash(renewalSigHash),
@@ -398,17 +461,12 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 	renewalTxn.Signatures = append(renewalTxn.Signatures, renterSigsResp.TransactionSignatures...)
 	renterSigs := len(renewalTxn.Signatures)
 
-	// sign and broadcast the transaction
+	// sign the transaction
 	if err := sh.wallet.SignTransaction(sh.chain.TipState(), &renewalTxn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
 		s.WriteResponseErr(fmt.Errorf("failed to sign renewal transaction: %w", ErrHostInternalError))
 		return contracts.Usage{}, fmt.Errorf("failed to sign renewal transaction: %w", err)
 	}
 	renewalTxnSet := append(parents, renewalTxn)
-	if err := sh.tpool.AcceptTransactionSet(renewalTxnSet); err != nil {
-		err = fmt.Errorf("failed to broadcast renewal transaction: %w", err)
-		s.WriteResponseErr(err)
-		return contracts.Usage{}, err
-	}
 
 	// calculate the usage
 	finalRevisionUsage := contracts.Usage{
@@ -419,12 +477,17 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 		StorageRevenue:   baseRevenue,
 		RiskedCollateral: riskedCollateral,
 	}
-	// renew the contract in the manager
+	// renew the contract in the manager and broadcast the transaction
+	timer.Phase(metrics.PhaseBroadcast)
 	err = sh.contracts.RenewContract(signedRenewal, signedClearingRevision, renewalTxnSet, lockedCollateral, finalRevisionUsage, renewalUsage)
-	if err != nil {
+	if errors.Is(err, contracts.ErrRenewalNotBroadcast) {
+		s.WriteResponseErr(err)
+		return contracts.Usage{}, err
+	} else if err != nil {
 		s.WriteResponseErr(fmt.Errorf("failed to renew contract: %w", ErrHostInternalError))
 		return contracts.Usage{}, fmt.Errorf("failed to renew contract: %w", err)
 	}
+	timer.Finish()
 
 	// send the signatures to the renter
 	hostSigs := &rhp3.RPCRenewSignatures{
@@ -442,7 +505,7 @@ func (sh *SessionHandler) handleRPCRenew(s *rhp3.Stream, log *zap.Logger) (contr
 }
 
 // handleRPCExecute handles an RPCExecuteProgram request.
-func (sh *SessionHandler) handleRPCExecute(s *rhp3.Stream, log *zap.Logger) (contracts.Usage, error) {
+func (sh *SessionHandler) handleRPCExecute(s *rhp3.Stream, prefetcher *storage.Prefetcher, log *zap.Logger) (contracts.Usage, error) {
 	s.SetDeadline(time.Now().Add(5 * time.Minute))
 	// read the price table
 	pt, err := sh.readPriceTable(s)
@@ -479,6 +542,33 @@ func (sh *SessionHandler) handleRPCExecute(s *rhp3.Stream, log *zap.Logger) (con
 		return contracts.Usage{}, err
 	}
 
+	// reject programs that exceed the host's limits before any instructions
+	// are executed. The renter is still charged the program's init cost.
+	if err := ProgramLimitsFrom(sh.settings.Settings()).Check(instructions); err != nil {
+		s.WriteResponseErr(err)
+		if err := budget.Commit(); err != nil {
+			return contracts.Usage{}, fmt.Errorf("failed to commit program init cost: %w", err)
+		}
+		return contracts.Usage{}, err
+	}
+
+	// reserve space for the program's writes so that concurrent uploads
+	// cannot fill the host after the program is accepted. The space is held
+	// until the program ends and its contract is unlocked.
+	var reservation *storage.Reservation
+	if n := programWrites(instructions); n > 0 {
+		reservation, err = sh.storage.ReserveSectors(n)
+		if err != nil {
+			err = fmt.Errorf("failed to reserve space for %d sectors: %w", n, err)
+			s.WriteResponseErr(err)
+			if err := budget.Commit(); err != nil {
+				return contracts.Usage{}, fmt.Errorf("failed to commit program init cost: %w", err)
+			}
+			return contracts.Usage{}, err
+		}
+		defer reservation.Release()
+	}
+
 	var requiresContract, requiresFinalization bool
 	for _, instr := range instructions {
 		requiresContract = requiresContract || instr.RequiresContract()
@@ -506,10 +596,29 @@ func (sh *SessionHandler) handleRPCExecute(s *rhp3.Stream, log *zap.Logger) (con
 		}
 		defer sh.contracts.Unlock(contract.Revision.ParentID)
 		revision = &contract
-		log = log.With(zap.String("contractID", contract.Revision.ParentID.String())) // attach the contract ID to the logger
+		log = rhp.ContractLogger(log, contract.Revision.ParentID)
 		log.Debug("locked contract", zap.Duration("elapsed", time.Since(contractLockStart)))
 	}
 
+	// create the program executor
+	// note: the budget is committed by the executor, no need to commit it in the handler.
+	executor, err := sh.newExecutor(instructions, executeReq.ProgramData, pt, budget, revision, requiresFinalization, prefetcher, log)
+	if err != nil {
+		s.WriteResponseErr(ErrHostInternalError)
+		return contracts.Usage{}, fmt.Errorf("failed to create program executor: %w", err)
+	}
+	executor.reservation = reservation
+
+	// fail fast if the payment cannot cover the program. The renter is still
+	// charged the program's init cost.
+	if err := executor.preauthorize(); err != nil {
+		s.WriteResponseErr(err)
+		if err := executor.rollback(); err != nil {
+			return contracts.Usage{}, fmt.Errorf("failed to commit program init cost: %w", err)
+		}
+		return contracts.Usage{}, err
+	}
+
 	// generate a cancellation token and write it to the stream. Currently just
 	// a placeholder.
 	cancelToken := types.Specifier(frand.Entropy128())
@@ -521,13 +630,6 @@ func (sh *SessionHandler) handleRPCExecute(s *rhp3.Stream, log *zap.Logger) (con
 	defer cancel()
 
 	log.Debug("executing program", zap.Int("instructions", len(instructions)), zap.String("budget", budget.Remaining().ExactString()), zap.Bool("requiresFinalization", requiresFinalization))
-	// create the program executor
-	// note: the budget is committed by the executor, no need to commit it in the handler.
-	executor, err := sh.newExecutor(instructions, executeReq.ProgramData, pt, budget, revision, requiresFinalization, log)
-	if err != nil {
-		s.WriteResponseErr(ErrHostInternalError)
-		return contracts.Usage{}, fmt.Errorf("failed to create program executor: %w", err)
-	}
 	err = executor.Execute(ctx, s)
 	usage := executor.Usage()
 	return usage, err
@@ -3,18 +3,31 @@ package rhp_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	rhp2 "go.sia.tech/core/rhp/v2"
 	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/host/accounts"
+	"go.sia.tech/hostd/host/contracts"
 	"go.sia.tech/hostd/host/settings"
 	"go.sia.tech/hostd/internal/test"
 	proto3 "go.sia.tech/hostd/internal/test/rhp/v3"
+	"go.sia.tech/hostd/rhp"
+	hostrhp3 "go.sia.tech/hostd/rhp/v3"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 	"lukechampine.com/frand"
 )
 
@@ -86,6 +99,149 @@ func TestPriceTable(t *testing.T) {
 	}
 }
 
+func TestPriceTableOverflow(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	// the latest revision cost is the base RPC price plus the egress of a
+	// revision, which overflows
+	s := host.Settings().Settings()
+	s.BaseRPCPrice = types.MaxCurrency.Sub(types.NewCurrency64(1))
+	s.EgressPrice = types.NewCurrency64(1)
+	if err := host.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	} else if _, err := host.RHP3PriceTable(); !errors.Is(err, rhp.ErrPriceOverflow) {
+		t.Fatalf("expected ErrPriceOverflow, got %v", err)
+	}
+}
+
+func TestConsistentPricing(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	// every update scales all prices by the same factor
+	pricesAt := func(i uint64) settings.Settings {
+		s := test.DefaultSettings
+		s.NetAddress = host.RHP2Addr()
+		s.StoragePrice = test.DefaultSettings.StoragePrice.Mul64(i)
+		s.EgressPrice = test.DefaultSettings.EgressPrice.Mul64(i)
+		s.IngressPrice = test.DefaultSettings.IngressPrice.Mul64(i)
+		s.ContractPrice = test.DefaultSettings.ContractPrice.Mul64(i)
+		return s
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := uint64(1); i <= 50; i++ {
+			if err := host.UpdateSettings(pricesAt(i)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// the settings and price table are read from the real
+				// handlers. Prices only increase, so if the advertised
+				// settings are unchanged after both reads, no update
+				// happened in between and they must match.
+				before := host.Settings().AdvertisedSettings()
+				hs, err := host.RHP2Settings()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				pt, err := host.RHP3PriceTable()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				// each artifact must be derived from a single update
+				scaleOf := func(price, base types.Currency) types.Currency { return price.Div(base) }
+				if scaleOf(hs.StoragePrice, test.DefaultSettings.StoragePrice) != scaleOf(hs.DownloadBandwidthPrice, test.DefaultSettings.EgressPrice) {
+					t.Errorf("settings mix updates: storage price %v, egress price %v", hs.StoragePrice, hs.DownloadBandwidthPrice)
+					return
+				} else if scaleOf(pt.WriteStoreCost, test.DefaultSettings.StoragePrice) != scaleOf(pt.DownloadBandwidthCost, test.DefaultSettings.EgressPrice) {
+					t.Errorf("price table mixes updates: storage cost %v, egress cost %v", pt.WriteStoreCost, pt.DownloadBandwidthCost)
+					return
+				} else if !reflect.DeepEqual(host.Settings().AdvertisedSettings(), before) {
+					// an update happened between the reads
+					continue
+				}
+
+				switch {
+				case !hs.StoragePrice.Equals(pt.WriteStoreCost):
+					t.Errorf("storage price mismatch: %v != %v", hs.StoragePrice, pt.WriteStoreCost)
+				case !hs.DownloadBandwidthPrice.Equals(pt.DownloadBandwidthCost):
+					t.Errorf("egress price mismatch: %v != %v", hs.DownloadBandwidthPrice, pt.DownloadBandwidthCost)
+				case !hs.UploadBandwidthPrice.Equals(pt.UploadBandwidthCost):
+					t.Errorf("ingress price mismatch: %v != %v", hs.UploadBandwidthPrice, pt.UploadBandwidthCost)
+				case !hs.ContractPrice.Equals(pt.ContractPrice):
+					t.Errorf("contract price mismatch: %v != %v", hs.ContractPrice, pt.ContractPrice)
+				case !hs.Collateral.Equals(pt.CollateralCost):
+					t.Errorf("collateral mismatch: %v != %v", hs.Collateral, pt.CollateralCost)
+				default:
+					continue
+				}
+				return
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMultipleListeners(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	addrs := host.RHP3Addrs()
+	if len(addrs) != 2 {
+		t.Fatalf("expected 2 listen addresses, got %v", addrs)
+	} else if addrs[0] == addrs[1] {
+		t.Fatalf("expected distinct listen addresses, got %v", addrs)
+	}
+
+	for _, addr := range addrs {
+		session, err := renter.NewRHP3Session(context.Background(), addr, host.PublicKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := session.ScanPriceTable(); err != nil {
+			t.Fatalf("failed to get price table from %v: %v", addr, err)
+		}
+		session.Close()
+	}
+}
+
 func TestAppendSector(t *testing.T) {
 	log := zaptest.NewLogger(t)
 	renter, host, err := test.NewTestingPair(t.TempDir(), log)
@@ -152,6 +308,72 @@ func TestAppendSector(t *testing.T) {
 	}
 }
 
+func TestAppendSectorIngressMinimum(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining := revision.Revision.WindowEnd - renter.TipState().Index.Height
+
+	// the ingress price exactly covers the collateral risked for the rest of
+	// the contract
+	s := host.Settings().Settings()
+	s.MaxContractDuration = remaining
+	s.MinIngressCollateralRatio = 0.5
+	s.IngressPrice = s.MinIngressPrice(remaining)
+	if err := host.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+	if _, err := session.RegisterPriceTable(payment); err != nil {
+		t.Fatal(err)
+	} else if _, err := session.FundAccount(account, payment, types.Siacoins(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	appendSector := func() error {
+		pt, err := session.RegisterPriceTable(payment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cost, _ := pt.BaseCost().Add(pt.AppendSectorCost(revision.Revision.WindowEnd - renter.TipState().Index.Height)).Total()
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		_, err = session.AppendSector(&sector, &revision, renter.PrivateKey(), payment, cost)
+		return err
+	}
+
+	if err := appendSector(); err != nil {
+		t.Fatal(err)
+	}
+
+	// doubling the advertised collateral of the empty host doubles the
+	// minimum for the rest of the contract
+	s.MinCollateralScale = 2
+	s.MaxCollateralScale = 2
+	if err := host.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	} else if err := appendSector(); err == nil || !strings.Contains(err.Error(), settings.ErrIngressPriceTooLow.Error()) {
+		t.Fatalf("expected ErrIngressPriceTooLow, got %v", err)
+	}
+}
+
 func TestStoreSector(t *testing.T) {
 	log := zaptest.NewLogger(t)
 	renter, host, err := test.NewTestingPair(t.TempDir(), log)
@@ -226,6 +448,317 @@ func TestStoreSector(t *testing.T) {
 	}
 }
 
+func TestMaxSectorsPerRPC(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+	pt, err := session.RegisterPriceTable(payment)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err = session.FundAccount(account, payment, types.Siacoins(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	// store a few sectors
+	payment = proto3.AccountPayment(account, renter.PrivateKey())
+	storeCost, _ := pt.StoreSectorCost(10).Total()
+	roots := make([]types.Hash256, 3)
+	for i := range roots {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		roots[i] = rhp2.SectorRoot(&sector)
+		if err := session.StoreSector(&sector, 10, payment, storeCost); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// limit programs to two sectors
+	s := test.DefaultSettings
+	s.NetAddress = host.RHP2Addr()
+	s.MaxSectorsPerRPC = 2
+	if err := host.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	readCost, _ := pt.ReadSectorCost(rhp2.SectorSize).Total()
+	budget := readCost.Mul64(uint64(len(roots)))
+	before, err := host.Accounts().Balance(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the over-limit program should be rejected before any sectors are read
+	if _, err := session.ReadSectors(roots, payment, budget); err == nil || !strings.Contains(err.Error(), hostrhp3.ErrTooManySectors.Error()) {
+		t.Fatalf("expected %q, got %v", hostrhp3.ErrTooManySectors, err)
+	}
+	// only the program's init cost should have been charged
+	after, err := host.Accounts().Balance(account)
+	if err != nil {
+		t.Fatal(err)
+	} else if spent := before.Sub(after); !spent.Equals(pt.InitBaseCost) {
+		t.Fatalf("expected %v to be charged, got %v", pt.InitBaseCost, spent)
+	}
+
+	sectors, err := session.ReadSectors(roots[:2], payment, budget)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(sectors) != 2 {
+		t.Fatalf("expected 2 sectors, got %d", len(sectors))
+	}
+}
+
+func TestAdvertisedProgramLimits(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	s := test.DefaultSettings
+	s.NetAddress = host.RHP2Addr()
+	s.MaxSectorsPerRPC = 10
+	s.MaxReadSectorsPerProgram = 2
+	s.MaxAppendSectorsPerProgram = 20
+	s.MaxProgramInstructions = 5
+	if err := host.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// fetch the raw price table to decode the additional fields
+	conn, err := net.Dial("tcp", host.RHP3Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, err := rhp3.NewRenterTransport(conn, host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	stream := transport.DialStream()
+	defer stream.Close()
+	if err := stream.WriteRequest(rhp3.RPCUpdatePriceTableID, nil); err != nil {
+		t.Fatal(err)
+	}
+	var resp rhp3.RPCUpdatePriceTableResponse
+	if err := stream.ReadResponse(&resp, 4096); err != nil {
+		t.Fatal(err)
+	}
+	var pt hostrhp3.PriceTable
+	if err := json.Unmarshal(resp.PriceTableJSON, &pt); err != nil {
+		t.Fatal(err)
+	}
+
+	// the append limit is capped by the total sector limit
+	expected := hostrhp3.ProgramLimits{
+		MaxSectors:       10,
+		MaxReadSectors:   2,
+		MaxAppendSectors: 10,
+		MaxInstructions:  5,
+	}
+	if pt.ProgramLimits != expected {
+		t.Fatalf("expected limits %+v, got %+v", expected, pt.ProgramLimits)
+	} else if enforced := hostrhp3.ProgramLimitsFrom(host.Settings().Settings()); pt.ProgramLimits != enforced {
+		t.Fatalf("advertised limits %+v do not match enforced limits %+v", pt.ProgramLimits, enforced)
+	}
+
+	// the advertised read limit should be enforced
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+	registered, err := session.RegisterPriceTable(payment)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err = session.FundAccount(account, payment, types.Siacoins(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	payment = proto3.AccountPayment(account, renter.PrivateKey())
+	storeCost, _ := registered.StoreSectorCost(10).Total()
+	roots := make([]types.Hash256, 3)
+	for i := range roots {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		roots[i] = rhp2.SectorRoot(&sector)
+		if err := session.StoreSector(&sector, 10, payment, storeCost); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readCost, _ := registered.ReadSectorCost(rhp2.SectorSize).Total()
+	budget := readCost.Mul64(uint64(len(roots)))
+	if _, err := session.ReadSectors(roots, payment, budget); err == nil || !strings.Contains(err.Error(), hostrhp3.ErrTooManyReads.Error()) {
+		t.Fatalf("expected %q, got %v", hostrhp3.ErrTooManyReads, err)
+	} else if _, err := session.ReadSectors(roots[:2], payment, budget); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuarantinedContract(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := host.Contracts().QuarantineContract(revision.ID(), "abuse"); err != nil {
+		t.Fatal(err)
+	}
+
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+	if _, err := session.RegisterPriceTable(payment); err == nil || !strings.Contains(err.Error(), contracts.ErrContractQuarantined.Error()) {
+		t.Fatalf("expected %q, got %v", contracts.ErrContractQuarantined, err)
+	} else if _, err := session.Revision(revision.ID()); err == nil || !strings.Contains(err.Error(), contracts.ErrContractQuarantined.Error()) {
+		t.Fatalf("expected %q, got %v", contracts.ErrContractQuarantined, err)
+	}
+
+	// the contract should still be tracked for its proof
+	contract, err := host.Contracts().Contract(revision.ID())
+	if err != nil {
+		t.Fatal(err)
+	} else if !contract.Quarantined {
+		t.Fatal("expected contract to be quarantined")
+	}
+}
+
+func TestInsufficientBudget(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := rhp3.Account(renter.PublicKey())
+	contractPayment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+	pt, err := session.RegisterPriceTable(contractPayment)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err = session.FundAccount(account, contractPayment, types.Siacoins(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	// store a few sectors
+	accountPayment := proto3.AccountPayment(account, renter.PrivateKey())
+	storeCost, _ := pt.StoreSectorCost(10).Total()
+	roots := make([]types.Hash256, 3)
+	for i := range roots {
+		var sector [rhp2.SectorSize]byte
+		frand.Read(sector[:256])
+		roots[i] = rhp2.SectorRoot(&sector)
+		if err := session.StoreSector(&sector, 10, accountPayment, storeCost); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the budget only covers reading one of the sectors
+	readCost, _ := pt.ReadSectorCost(rhp2.SectorSize).Total()
+	budget := readCost
+
+	tests := []struct {
+		name    string
+		payment proto3.PaymentMethod
+		// charged is the expected change in the account's balance
+		charged func(before, after types.Currency) types.Currency
+	}{
+		{
+			name:    "account",
+			payment: accountPayment,
+			charged: func(before, after types.Currency) types.Currency { return before.Sub(after) },
+		},
+		{
+			name:    "contract",
+			payment: contractPayment,
+			// the contract payment is credited to the refund account
+			charged: func(before, after types.Currency) types.Currency {
+				return pt.InitBaseCost.Add(budget).Sub(after.Sub(before))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := host.Accounts().Balance(account)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the program should be rejected before any sectors are read
+			if _, err := session.ReadSectors(roots, tt.payment, budget); err == nil || !strings.Contains(err.Error(), hostrhp3.ErrInsufficientBudget.Error()) {
+				t.Fatalf("expected %q, got %v", hostrhp3.ErrInsufficientBudget, err)
+			}
+
+			// only the program's init cost should have been charged
+			after, err := host.Accounts().Balance(account)
+			if err != nil {
+				t.Fatal(err)
+			} else if charged := tt.charged(before, after); !charged.Equals(pt.InitBaseCost) {
+				t.Fatalf("expected %v to be charged, got %v", pt.InitBaseCost, charged)
+			}
+
+			// a program within the budget should succeed
+			if sectors, err := session.ReadSectors(roots[:1], tt.payment, budget); err != nil {
+				t.Fatal(err)
+			} else if len(sectors) != 1 {
+				t.Fatalf("expected 1 sector, got %d", len(sectors))
+			}
+		})
+	}
+}
+
 func TestReadSectorOffset(t *testing.T) {
 	log := zaptest.NewLogger(t)
 	renter, host, err := test.NewTestingPair(t.TempDir(), log)
@@ -626,3 +1159,480 @@ func BenchmarkReadSector(b *testing.B) {
 		}
 	}
 }
+
+func TestRenewNotSynced(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	state := renter.TipState()
+	origin, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(10), types.Siacoins(20), state.Index.Height+200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate the host falling behind the network
+	host.SetSynced(false)
+
+	hostSettings, err := host.RHP2Settings()
+	if err != nil {
+		t.Fatal(err)
+	} else if hostSettings.AcceptingContracts {
+		t.Fatal("expected unsynced host to not accept contracts")
+	}
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	renewHeight := origin.Revision.WindowEnd + 10
+	_, _, err = session.RenewContract(&origin, hostSettings.Address, renter.PrivateKey(), types.Siacoins(10), types.Siacoins(20), renewHeight)
+	if err == nil || !strings.Contains(err.Error(), rhp.ErrNotSynced.Error()) {
+		t.Fatalf("expected %q, got %v", rhp.ErrNotSynced, err)
+	}
+
+	// the host should accept the renewal once it is synced
+	host.SetSynced(true)
+	if hostSettings, err = host.RHP2Settings(); err != nil {
+		t.Fatal(err)
+	} else if !hostSettings.AcceptingContracts {
+		t.Fatal("expected synced host to accept contracts")
+	}
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&origin, renter.PrivateKey(), account)
+	if _, err := session.RegisterPriceTable(payment); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = session.RenewContract(&origin, hostSettings.Address, renter.PrivateKey(), types.Siacoins(10), types.Siacoins(20), renewHeight); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenewStorageHeadroom(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	// the testing host has a single 64 sector volume
+	renter, host, err := test.NewTestingPair(t.TempDir(), log, test.WithRHP3Options(hostrhp3.WithMinStorageHeadroom(60)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	state := renter.TipState()
+	origin, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(10), types.Siacoins(20), state.Index.Height+200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostSettings, err := host.RHP2Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	// reserve enough sectors to exhaust the headroom
+	reservation, err := host.Storage().ReserveSectors(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewHeight := origin.Revision.WindowEnd + 10
+	_, _, err = session.RenewContract(&origin, hostSettings.Address, renter.PrivateKey(), types.Siacoins(10), types.Siacoins(20), renewHeight)
+	if err == nil || !strings.Contains(err.Error(), hostrhp3.ErrNotAcceptingContracts.Error()) {
+		t.Fatalf("expected %q, got %v", hostrhp3.ErrNotAcceptingContracts, err)
+	}
+
+	// the host should accept the renewal once space is freed
+	reservation.Release()
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&origin, renter.PrivateKey(), account)
+	if _, err := session.RegisterPriceTable(payment); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = session.RenewContract(&origin, hostSettings.Address, renter.PrivateKey(), types.Siacoins(10), types.Siacoins(20), renewHeight); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionLogFields(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(core)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+	pt, err := session.RegisterPriceTable(payment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cost, _ := pt.BaseCost().Add(pt.AppendSectorCost(revision.Revision.WindowEnd - renter.TipState().Index.Height)).Total()
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:256])
+	if _, err = session.AppendSector(&sector, &revision, renter.PrivateKey(), payment, cost); err != nil {
+		t.Fatal(err)
+	}
+
+	// the host logs the RPC result after responding to the renter
+	time.Sleep(100 * time.Millisecond)
+
+	rpcLogs := logs.FilterMessage("RPC success").All()
+	if len(rpcLogs) == 0 {
+		t.Fatal("expected RPC logs")
+	}
+	for _, entry := range rpcLogs {
+		fields := entry.ContextMap()
+		for _, key := range []string{"sessionID", "peerAddress", "rpcID"} {
+			if _, ok := fields[key]; !ok {
+				t.Fatalf("expected %q field on log %q from %q", key, entry.Message, entry.LoggerName)
+			}
+		}
+	}
+
+	programLogs := logs.FilterMessage("executing program").All()
+	if len(programLogs) == 0 {
+		t.Fatal("expected program logs")
+	}
+	for _, entry := range programLogs {
+		fields := entry.ContextMap()
+		if fields["contractID"] != revision.ID().String() {
+			t.Fatalf("expected contract ID %v, got %v", revision.ID(), fields["contractID"])
+		} else if _, ok := fields["sessionID"]; !ok {
+			t.Fatal("expected session ID on program log")
+		}
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	dir := t.TempDir()
+	node, err := test.NewNode(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, err := test.NewHost(types.GeneratePrivateKey(), dir, node, log.Named("host"), test.WithRHP3Options(hostrhp3.WithHandshakeTimeout(250*time.Millisecond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+
+	// open a connection without sending the handshake
+	conn, err := net.Dial("tcp", host.RHP3Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the host should close the connection after the timeout
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("expected the host to close the connection, got %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond) // wait for the handler to return
+	if _, v3 := host.RejectedHandshakes(); v3 != 1 {
+		t.Fatalf("expected 1 rejected handshake, got %v", v3)
+	}
+}
+
+func TestProgramBufferLimit(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	// a buffer smaller than a sector cannot hold a full read
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := hostrhp3.NewSessionHandler([]net.Listener{l}, types.GeneratePrivateKey(), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, log, hostrhp3.WithMaxProgramBuffer(1<<20)); err == nil || !strings.Contains(err.Error(), "max program buffer") {
+		t.Fatalf("expected program buffer to be rejected, got %v", err)
+	}
+
+	// limit program outputs to a single sector and its proof
+	renter, host, err := test.NewTestingPair(t.TempDir(), log, test.WithRHP3Options(hostrhp3.WithMaxProgramBuffer(rhp2.SectorSize+2*64*32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := rhp3.Account(renter.PublicKey())
+	payment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+	pt, err := session.RegisterPriceTable(payment)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err = session.FundAccount(account, payment, types.Siacoins(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	payment = proto3.AccountPayment(account, renter.PrivateKey())
+	storeCost, _ := pt.StoreSectorCost(10).Total()
+	var sector [rhp2.SectorSize]byte
+	frand.Read(sector[:256])
+	root := rhp2.SectorRoot(&sector)
+	if err := session.StoreSector(&sector, 10, payment, storeCost); err != nil {
+		t.Fatal(err)
+	}
+
+	// the minimum buffer should hold a full sector
+	readCost, _ := pt.ReadSectorCost(rhp2.SectorSize).Total()
+	buf, _, err := session.ReadSector(root, 0, rhp2.SectorSize, payment, readCost)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(buf, sector[:]) {
+		t.Fatal("downloaded sector doesn't match")
+	}
+
+	// partial reads include a proof
+	readCost, _ = pt.ReadSectorCost(256).Total()
+	buf, _, err = session.ReadSector(root, 0, 256, payment, readCost)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(buf, sector[:256]) {
+		t.Fatal("downloaded sector doesn't match")
+	}
+}
+
+func TestFundAccountLimits(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	renter, host, err := test.NewTestingPair(t.TempDir(), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renter.Close()
+	defer host.Close()
+
+	s := test.DefaultSettings
+	s.NetAddress = host.RHP2Addr()
+	s.MinAccountDeposit = types.Siacoins(1)
+	s.MaxAccountBalance = types.Siacoins(10)
+	if err := host.UpdateSettings(s); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	account := rhp3.Account(renter.PublicKey())
+	fund := func(amount types.Currency) error {
+		// a rejected deposit leaves the renter's revision out of sync, so
+		// each deposit uses a new contract
+		revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+		if _, err := session.RegisterPriceTable(payment); err != nil {
+			t.Fatal(err)
+		}
+		_, err = session.FundAccount(account, payment, amount)
+		return err
+	}
+
+	// a deposit below the minimum should be rejected
+	if err := fund(types.Siacoins(1).Div64(2)); err == nil || !strings.Contains(err.Error(), accounts.ErrDepositTooSmall.Error()) {
+		t.Fatalf("expected %v, got %v", accounts.ErrDepositTooSmall, err)
+	}
+
+	// a deposit within the limits should succeed
+	if err := fund(types.Siacoins(6)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a deposit that pushes the balance over the maximum should be rejected
+	if err := fund(types.Siacoins(6)); err == nil || !strings.Contains(err.Error(), accounts.ErrBalanceExceeded.Error()) {
+		t.Fatalf("expected %v, got %v", accounts.ErrBalanceExceeded, err)
+	}
+
+	// the balance should only include the accepted deposit
+	payment := proto3.AccountPayment(account, renter.PrivateKey())
+	if _, err := session.RegisterPriceTable(payment); err != nil {
+		t.Fatal(err)
+	}
+	pt, err := host.RHP3PriceTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, err := session.AccountBalance(account, payment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the price table and the balance RPC were paid from the account
+	expected := types.Siacoins(6).Sub(pt.UpdatePriceTableCost).Sub(pt.AccountBalanceCost)
+	if !balance.Equals(expected) {
+		t.Fatalf("expected balance %v, got %v", expected, balance)
+	}
+}
+
+func TestProgramFailureMode(t *testing.T) {
+	tests := []struct {
+		mode hostrhp3.ProgramFailureMode
+		// committed is true if the completed instructions should be
+		// committed
+		committed bool
+	}{
+		{hostrhp3.ProgramFailureRollback, false},
+		{hostrhp3.ProgramFailureCommitCompleted, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			log := zaptest.NewLogger(t)
+			renter, host, err := test.NewTestingPair(t.TempDir(), log, test.WithRHP3Options(hostrhp3.WithProgramFailureMode(tt.mode)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer renter.Close()
+			defer host.Close()
+
+			session, err := renter.NewRHP3Session(context.Background(), host.RHP3Addr(), host.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+
+			revision, err := renter.FormContract(context.Background(), host.RHP2Addr(), host.PublicKey(), types.Siacoins(50), types.Siacoins(100), 200)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			account := rhp3.Account(renter.PublicKey())
+			contractPayment := proto3.ContractPayment(&revision, renter.PrivateKey(), account)
+			pt, err := session.RegisterPriceTable(contractPayment)
+			if err != nil {
+				t.Fatal(err)
+			} else if _, err = session.FundAccount(account, contractPayment, types.Siacoins(10)); err != nil {
+				t.Fatal(err)
+			}
+			// the contract payments revised the contract
+			revision.Revision, err = session.Revision(revision.ID())
+			if err != nil {
+				t.Fatal(err)
+			}
+			initial := revision.Revision
+
+			// append two sectors, then append the root of a sector the host
+			// does not have
+			data := make([]byte, 2*rhp2.SectorSize+32)
+			var roots []types.Hash256
+			for i := 0; i < 2; i++ {
+				sector := (*[rhp2.SectorSize]byte)(data[i*rhp2.SectorSize:])
+				frand.Read(sector[:256])
+				roots = append(roots, rhp2.SectorRoot(sector))
+			}
+			frand.Read(data[2*rhp2.SectorSize:])
+			program := []rhp3.Instruction{
+				&rhp3.InstrAppendSector{SectorDataOffset: 0},
+				&rhp3.InstrAppendSector{SectorDataOffset: rhp2.SectorSize},
+				&rhp3.InstrAppendSectorRoot{MerkleRootOffset: 2 * rhp2.SectorSize},
+			}
+
+			duration := revision.Revision.WindowEnd - pt.HostBlockHeight
+			appendCost, _ := pt.AppendSectorCost(duration).Total()
+			appendRootCost, _ := pt.AppendSectorRootCost(duration).Total()
+			budget := appendCost.Mul64(2).Add(appendRootCost)
+
+			before, err := host.Accounts().Balance(account)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payment := proto3.AccountPayment(account, renter.PrivateKey())
+			outputs, err := session.ExecuteProgram(program, data, &revision, renter.PrivateKey(), payment, budget, tt.committed)
+			if err == nil {
+				t.Fatal("expected program to fail")
+			} else if len(outputs) != 3 {
+				t.Fatalf("expected 3 outputs, got %v", len(outputs))
+			} else if outputs[2].Error == nil {
+				t.Fatal("expected third instruction to fail")
+			}
+
+			after, err := host.Accounts().Balance(account)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contract, err := host.Contracts().Contract(revision.ID())
+			if err != nil {
+				t.Fatal(err)
+			}
+			hostRoots, err := host.Contracts().SectorRoots(revision.ID())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.committed {
+				// the completed instructions should be paid for and
+				// committed, the failed instruction should be refunded
+				expected := pt.InitBaseCost.Add(appendCost.Mul64(2))
+				if charged := before.Sub(after); !charged.Equals(expected) {
+					t.Fatalf("expected %v to be charged, got %v", expected, charged)
+				} else if !reflect.DeepEqual(hostRoots, roots) {
+					t.Fatalf("expected roots %v, got %v", roots, hostRoots)
+				} else if contract.Revision.RevisionNumber != initial.RevisionNumber+1 {
+					t.Fatalf("expected revision number %v, got %v", initial.RevisionNumber+1, contract.Revision.RevisionNumber)
+				} else if contract.Revision.Filesize != 2*rhp2.SectorSize {
+					t.Fatalf("expected filesize %v, got %v", 2*rhp2.SectorSize, contract.Revision.Filesize)
+				} else if contract.Revision.FileMerkleRoot != rhp2.MetaRoot(roots) {
+					t.Fatal("contract merkle root doesn't match")
+				} else if contract.Revision.FileMerkleRoot != revision.Revision.FileMerkleRoot {
+					t.Fatal("renter and host merkle roots don't match")
+				}
+				return
+			}
+
+			// only the program's init cost and the ingress of the completed
+			// instructions should be charged and the contract should not be
+			// changed
+			expected := pt.InitBaseCost.Add(pt.AppendSectorCost(duration).Ingress.Mul64(2))
+			if charged := before.Sub(after); !charged.Equals(expected) {
+				t.Fatalf("expected %v to be charged, got %v", expected, charged)
+			} else if len(hostRoots) != 0 {
+				t.Fatalf("expected no roots, got %v", len(hostRoots))
+			} else if contract.Revision.RevisionNumber != initial.RevisionNumber {
+				t.Fatalf("expected revision number %v, got %v", initial.RevisionNumber, contract.Revision.RevisionNumber)
+			} else if contract.Revision.Filesize != 0 {
+				t.Fatalf("expected filesize 0, got %v", contract.Revision.Filesize)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"net/http"
 
-	rhp3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/hostd/rhp"
 	"go.uber.org/zap"
 	"nhooyr.io/websocket"
@@ -12,12 +11,12 @@ import (
 
 // handleWebSockets handles websocket connections to the host.
 func (sh *SessionHandler) handleWebSockets(w http.ResponseWriter, r *http.Request) {
-	log := sh.log.Named("websockets").With(zap.String("peerAddr", r.RemoteAddr))
+	log := sh.log.Named("websockets")
 	wsConn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		OriginPatterns: []string{"*"},
 	})
 	if err != nil {
-		log.Warn("failed to accept websocket connection", zap.Error(err))
+		log.Warn("failed to accept websocket connection", zap.Error(err), zap.String("peerAddress", r.RemoteAddr))
 		return
 	}
 	defer wsConn.Close(websocket.StatusNormalClosure, "")
@@ -35,16 +34,17 @@ func (sh *SessionHandler) handleWebSockets(w http.ResponseWriter, r *http.Reques
 	sessionID, end := sh.sessions.StartSession(rhpConn, rhp.SessionProtocolWS, 3)
 	defer end()
 
-	log = log.With(zap.String("sessionID", sessionID.String()))
+	log = rhp.SessionLogger(log, sessionID, r.RemoteAddr)
 
 	// upgrade the connection
-	t, err := rhp3.NewHostTransport(rhpConn, sh.privateKey)
+	t, err := sh.upgrade(conn, rhpConn)
 	if err != nil {
-		sh.log.Debug("failed to upgrade conn", zap.Error(err), zap.String("remoteAddress", conn.RemoteAddr().String()))
+		log.Debug("failed to upgrade conn", zap.Error(err))
 		return
 	}
 	defer t.Close()
 
+	prefetcher := sh.storage.Prefetcher()
 	for {
 		stream, err := t.AcceptStream()
 		if err != nil {
@@ -52,7 +52,7 @@ func (sh *SessionHandler) handleWebSockets(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		go sh.handleHostStream(stream, sessionID, log)
+		go sh.handleHostStream(stream, sessionID, rhp.NetworkClearnet, prefetcher, log)
 	}
 }
 
@@ -0,0 +1,94 @@
+package rhp
+
+import (
+	"errors"
+	"fmt"
+
+	"go.sia.tech/core/types"
+)
+
+// Validation codes identify why a revision submitted by a renter was
+// rejected. They are sent to the renter as the type of the RPC error and must
+// not change.
+var (
+	// CodeStaleRevision is returned when the revision number is not greater
+	// than the host's latest revision number.
+	CodeStaleRevision = types.NewSpecifier("StaleRevision")
+	// CodeContractLocked is returned when the contract has been cleared and
+	// cannot be revised.
+	CodeContractLocked = types.NewSpecifier("ContractLocked")
+	// CodeImmutableField is returned when the revision changes a field that
+	// must not change, such as the proof window or an output address.
+	CodeImmutableField = types.NewSpecifier("ImmutableField")
+	// CodeInvalidOutputs is returned when the revision has the wrong number
+	// of outputs or changes the contract's total payout.
+	CodeInvalidOutputs = types.NewSpecifier("InvalidOutputs")
+	// CodeInvalidPayout is returned when an output's value changes in a
+	// direction not allowed by the RPC.
+	CodeInvalidPayout = types.NewSpecifier("InvalidPayout")
+	// CodeInvalidTransfer is returned when the value removed from the
+	// renter's outputs does not match the value added to the host's.
+	CodeInvalidTransfer = types.NewSpecifier("InvalidTransfer")
+	// CodeInsufficientFunds is returned when the renter's outputs cannot
+	// cover the payment.
+	CodeInsufficientFunds = types.NewSpecifier("LowFunds")
+	// CodeInsufficientCollateral is returned when the host's missed output
+	// cannot cover the collateral risked by the RPC.
+	CodeInsufficientCollateral = types.NewSpecifier("LowCollateral")
+	// CodeInsufficientPayment is returned when the renter pays less than the
+	// cost of the RPC.
+	CodeInsufficientPayment = types.NewSpecifier("LowPayment")
+	// CodeExcessiveBurn is returned when the revision burns more of the
+	// host's collateral than the RPC risks.
+	CodeExcessiveBurn = types.NewSpecifier("ExcessiveBurn")
+	// CodeInvalidRevisionNumber is returned when a clearing revision does
+	// not use the maximum revision number.
+	CodeInvalidRevisionNumber = types.NewSpecifier("InvalidRevNumber")
+	// CodeRevisionNumberExhausted is returned when a revision uses one of the
+	// reserved revision numbers near the maximum. The renter should renew
+	// the contract.
+	CodeRevisionNumberExhausted = types.NewSpecifier("RevNumExhausted")
+)
+
+// A ValidationError is returned when a revision submitted by a renter is
+// invalid. Code identifies the failure and is sent to the renter as the type
+// of the RPC error.
+type ValidationError struct {
+	Code types.Specifier
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalid returns a ValidationError with the given code and message.
+func invalid(code types.Specifier, msg string) error {
+	return &ValidationError{Code: code, Err: errors.New(msg)}
+}
+
+// invalidf returns a ValidationError with the given code and formatted
+// message.
+func invalidf(code types.Specifier, format string, args ...any) error {
+	return &ValidationError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// ErrorCode returns the validation code of err or any error it wraps. false is
+// returned if err is not a validation error.
+func ErrorCode(err error) (types.Specifier, bool) {
+	var staleErr *StaleRevisionError
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &staleErr):
+		return CodeStaleRevision, true
+	case errors.As(err, &validationErr):
+		return validationErr.Code, true
+	}
+	return types.Specifier{}, false
+}
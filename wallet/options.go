@@ -0,0 +1,57 @@
+package wallet
+
+import (
+	"time"
+
+	"go.sia.tech/core/types"
+)
+
+// An Option is a functional option that can be used to configure a wallet.
+type Option func(*SingleAddressWallet)
+
+// WithAlerts sets the alert manager used by the wallet to report
+// inconsistencies.
+func WithAlerts(a Alerts) Option {
+	return func(sw *SingleAddressWallet) {
+		sw.alerts = a
+	}
+}
+
+// WithTransactionRetention sets the number of blocks transactions are kept in
+// the wallet's history. Older transactions are periodically pruned, unless
+// they created an output that is still unspent. The default is 0, which keeps
+// all transactions.
+func WithTransactionRetention(blocks uint64) Option {
+	return func(sw *SingleAddressWallet) {
+		sw.txnRetention = blocks
+	}
+}
+
+// WithFeeReserve sets the balance kept in the wallet to pay for transaction
+// fees. Withdrawals never reduce the spendable balance below the reserve.
+func WithFeeReserve(reserve types.Currency) Option {
+	return func(sw *SingleAddressWallet) {
+		sw.feeReserve = reserve
+	}
+}
+
+// WithScheduledWithdrawal periodically withdraws the funds above the fee
+// reserve to the address.
+func WithScheduledWithdrawal(addr types.Address, interval time.Duration) Option {
+	return func(sw *SingleAddressWallet) {
+		sw.withdrawAddress = addr
+		sw.withdrawInterval = interval
+	}
+}
+
+// WithMinUTXOs sets the minimum number of spendable outputs the wallet
+// maintains. When the count drops below the minimum, the balance is split
+// into new outputs so that concurrent transactions, such as storage proofs
+// in the same block, can be funded without waiting for change outputs to
+// confirm. The minimum is capped below the threshold at which the wallet
+// starts consolidating small outputs. The default of 0 disables splitting.
+func WithMinUTXOs(n int) Option {
+	return func(sw *SingleAddressWallet) {
+		sw.minUTXOs = min(n, transactionDefragThreshold)
+	}
+}
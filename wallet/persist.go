@@ -8,6 +8,14 @@ import (
 )
 
 type (
+	// PrunedTransactions aggregates the transactions removed from the wallet's
+	// history by pruning.
+	PrunedTransactions struct {
+		Count   uint64         `json:"count"`
+		Inflow  types.Currency `json:"inflow"`
+		Outflow types.Currency `json:"outflow"`
+	}
+
 	// An UpdateTransaction atomically updates the wallet store
 	UpdateTransaction interface {
 		AddSiacoinElement(SiacoinElement) error
@@ -34,6 +42,13 @@ type (
 		// TransactionCount returns the total number of transactions in the
 		// wallet.
 		TransactionCount() (uint64, error)
+		// PruneTransactions removes transactions confirmed before height from
+		// the wallet's history and adds them to the pruned totals.
+		// Transactions that created a still unspent output are kept. The
+		// number of removed transactions is returned.
+		PruneTransactions(height uint64) (int, error)
+		// PrunedTransactions returns the totals of all pruned transactions.
+		PrunedTransactions() (PrunedTransactions, error)
 		UpdateWallet(ccID modules.ConsensusChangeID, height uint64, fn func(UpdateTransaction) error) error
 		// ResetWallet resets the wallet to its initial state. This is used when a
 		// consensus subscription error occurs.
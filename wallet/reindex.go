@@ -0,0 +1,80 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/siad/modules"
+	"go.uber.org/zap"
+)
+
+// A reindexSubscriber forwards replayed consensus changes to the wallet and
+// reports the height of each change.
+type reindexSubscriber struct {
+	sw       *SingleAddressWallet
+	progress func(height uint64)
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber.
+func (rs *reindexSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	rs.sw.ProcessConsensusChange(cc)
+	if rs.progress != nil {
+		rs.progress(uint64(cc.BlockHeight))
+	}
+}
+
+// Reindex rebuilds the wallet's outputs and transaction history by replaying
+// every consensus change from the beginning of the chain. The wallet's
+// balance is incomplete until the reindex completes. progress, if not nil, is
+// called with the height of each replayed change. If ctx is cancelled, the
+// remaining changes are processed in the background and ctx's error is
+// returned.
+func (sw *SingleAddressWallet) Reindex(ctx context.Context, progress func(height uint64)) error {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	log := sw.log.Named("reindex")
+	start := time.Now()
+
+	sw.subscribeMu.Lock()
+	// stop processing new changes while the wallet is rebuilt
+	sw.cm.Unsubscribe(sw)
+	err = func() error {
+		if err := sw.store.ResetWallet(types.HashBytes(sw.priv[:])); err != nil {
+			return fmt.Errorf("failed to reset wallet: %w", err)
+		}
+		atomic.StoreUint64(&sw.scanHeight, 0)
+
+		rs := &reindexSubscriber{sw: sw, progress: progress}
+		err := sw.cm.Subscribe(rs, modules.ConsensusChangeBeginning, ctx.Done())
+		// the replay subscriber is subscribed once it has caught up
+		sw.cm.Unsubscribe(rs)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			return fmt.Errorf("failed to replay consensus changes: %w", err)
+		}
+		return nil
+	}()
+	sw.subscribeMu.Unlock()
+
+	// resume from the last change processed by the replay
+	changeID, height, lastErr := sw.store.LastWalletChange()
+	if lastErr != nil {
+		log.Error("failed to get last wallet change, rescanning", zap.Error(lastErr))
+		changeID = modules.ConsensusChangeBeginning
+	}
+	if err != nil {
+		go sw.subscribe(changeID)
+		return err
+	}
+	sw.subscribe(changeID)
+	log.Info("wallet reindexed", zap.Uint64("height", height), zap.Duration("elapsed", time.Since(start)))
+	return nil
+}
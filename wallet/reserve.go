@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+// withdrawTxnSize is the estimated size of a withdrawal transaction used to
+// calculate its miner fee.
+const withdrawTxnSize = 1200 // bytes
+
+// FeeReserve returns the balance kept in the wallet to pay for transaction
+// fees.
+func (sw *SingleAddressWallet) FeeReserve() types.Currency {
+	return sw.feeReserve
+}
+
+// WithdrawableBalance returns the wallet's spendable balance above the fee
+// reserve.
+func (sw *SingleAddressWallet) WithdrawableBalance() (types.Currency, error) {
+	spendable, _, _, err := sw.Balance()
+	if err != nil {
+		return types.ZeroCurrency, err
+	}
+	withdrawable, underflow := spendable.SubWithUnderflow(sw.feeReserve)
+	if underflow {
+		return types.ZeroCurrency, nil
+	}
+	return withdrawable, nil
+}
+
+// withdrawalFee returns the miner fee of a withdrawal transaction.
+func (sw *SingleAddressWallet) withdrawalFee() types.Currency {
+	return sw.tp.RecommendedFee().Mul64(withdrawTxnSize)
+}
+
+// Withdraw sends amount to the address and broadcasts the transaction. The
+// amount plus the miner fee must not reduce the spendable balance below the
+// fee reserve.
+func (sw *SingleAddressWallet) Withdraw(addr types.Address, amount types.Currency) (types.Transaction, error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	defer done()
+
+	if addr == types.VoidAddress {
+		return types.Transaction{}, errors.New("cannot withdraw to void address")
+	}
+
+	sw.withdrawMu.Lock()
+	defer sw.withdrawMu.Unlock()
+
+	minerFee := sw.withdrawalFee()
+	total := amount.Add(minerFee)
+	withdrawable, err := sw.WithdrawableBalance()
+	if err != nil {
+		return types.Transaction{}, fmt.Errorf("failed to get withdrawable balance: %w", err)
+	} else if total.Cmp(withdrawable) > 0 {
+		return types.Transaction{}, fmt.Errorf("%w: withdrawal of %v (including %v fee) exceeds withdrawable balance %v", ErrBelowReserve, total, minerFee, withdrawable)
+	}
+
+	txn := types.Transaction{
+		MinerFees: []types.Currency{minerFee},
+		SiacoinOutputs: []types.SiacoinOutput{
+			{Address: addr, Value: amount},
+		},
+	}
+	toSign, release, err := sw.FundTransaction(&txn, total)
+	if err != nil {
+		return types.Transaction{}, fmt.Errorf("failed to fund transaction: %w", err)
+	} else if err := sw.SignTransaction(sw.cm.TipState(), &txn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
+		release()
+		return types.Transaction{}, fmt.Errorf("failed to sign transaction: %w", err)
+	} else if err := sw.tp.AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+		release()
+		return types.Transaction{}, fmt.Errorf("failed to broadcast transaction: %w", err)
+	}
+	return txn, nil
+}
+
+// runScheduledWithdrawals periodically withdraws the funds above the fee
+// reserve to the configured address.
+func (sw *SingleAddressWallet) runScheduledWithdrawals() {
+	log := sw.log.Named("withdraw").With(zap.Stringer("address", sw.withdrawAddress))
+	t := time.NewTicker(sw.withdrawInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-sw.tg.Done():
+			return
+		case <-t.C:
+		}
+
+		withdrawable, err := sw.WithdrawableBalance()
+		if err != nil {
+			log.Error("failed to get withdrawable balance", zap.Error(err))
+			continue
+		}
+		// the miner fee is paid from the withdrawable balance
+		amount, underflow := withdrawable.SubWithUnderflow(sw.withdrawalFee())
+		if underflow || amount.IsZero() {
+			continue
+		}
+		txn, err := sw.Withdraw(sw.withdrawAddress, amount)
+		if err != nil {
+			log.Error("failed to withdraw", zap.Stringer("amount", amount), zap.Error(err))
+			continue
+		}
+		log.Info("withdrew funds above fee reserve", zap.Stringer("amount", amount), zap.Stringer("transactionID", txn.ID()))
+	}
+}
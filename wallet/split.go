@@ -0,0 +1,125 @@
+package wallet
+
+import (
+	"fmt"
+
+	"go.sia.tech/core/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// splitInputTxnSize is the estimated size of a split transaction without
+	// its outputs.
+	splitInputTxnSize = 800 // bytes
+	// splitOutputSize is the estimated size of each output of a split
+	// transaction.
+	splitOutputSize = 100 // bytes
+)
+
+// minSplitOutputValue is the smallest output created when splitting the
+// wallet's outputs. Outputs smaller than this cannot pay for much more than
+// their own miner fee.
+var minSplitOutputValue = types.Siacoins(1)
+
+// UTXOCount returns the number of spendable outputs, including outputs
+// created by unconfirmed transactions, and the minimum number of outputs the
+// wallet maintains. A target of 0 means the count is not maintained.
+func (sw *SingleAddressWallet) UTXOCount() (count, target int, err error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer done()
+
+	utxos, err := sw.store.UnspentSiacoinElements()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get unspent outputs: %w", err)
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	for _, sce := range utxos {
+		if !sw.locked[sce.ID] && !sw.tpoolSpent[sce.ID] && !sw.consensusLocked[sce.ID] {
+			count++
+		}
+	}
+	// outputs of unconfirmed transactions, such as a pending split, will
+	// be spendable once they are confirmed
+	for id := range sw.tpoolUtxos {
+		if !sw.tpoolSpent[id] {
+			count++
+		}
+	}
+	return count, sw.minUTXOs, nil
+}
+
+// SplitUTXOs splits the wallet's spendable balance into additional outputs if
+// the number of spendable outputs is below the minimum. The outputs are
+// created by a single transaction that is broadcast to the transaction pool.
+// false is returned if no split was needed or the balance is too small to
+// split.
+func (sw *SingleAddressWallet) SplitUTXOs() (types.Transaction, bool, error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return types.Transaction{}, false, err
+	}
+	defer done()
+
+	// prevent concurrent splits from creating more outputs than needed
+	sw.splitMu.Lock()
+	defer sw.splitMu.Unlock()
+
+	count, target, err := sw.UTXOCount()
+	if err != nil {
+		return types.Transaction{}, false, fmt.Errorf("failed to count outputs: %w", err)
+	} else if count >= target {
+		return types.Transaction{}, false, nil
+	}
+	spendable, _, _, err := sw.Balance()
+	if err != nil {
+		return types.Transaction{}, false, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	// the spent inputs are replaced by the change output. Each new output
+	// receives an equal share of the balance so the change output is roughly
+	// the same size.
+	need := target - count
+	minerFee := sw.tp.RecommendedFee().Mul64(splitInputTxnSize + uint64(need)*splitOutputSize)
+	available, underflow := spendable.SubWithUnderflow(minerFee)
+	if underflow {
+		return types.Transaction{}, false, nil
+	}
+	value := available.Div64(uint64(target))
+	if value.Cmp(minSplitOutputValue) < 0 {
+		return types.Transaction{}, false, nil
+	}
+
+	txn := types.Transaction{
+		MinerFees: []types.Currency{minerFee},
+	}
+	for i := 0; i < need; i++ {
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{Address: sw.addr, Value: value})
+	}
+	toSign, release, err := sw.FundTransaction(&txn, value.Mul64(uint64(need)).Add(minerFee))
+	if err != nil {
+		return types.Transaction{}, false, fmt.Errorf("failed to fund transaction: %w", err)
+	} else if err := sw.SignTransaction(sw.cm.TipState(), &txn, toSign, types.CoveredFields{WholeTransaction: true}); err != nil {
+		release()
+		return types.Transaction{}, false, fmt.Errorf("failed to sign transaction: %w", err)
+	} else if err := sw.tp.AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+		release()
+		return types.Transaction{}, false, fmt.Errorf("failed to broadcast transaction: %w", err)
+	}
+	return txn, true, nil
+}
+
+// maintainUTXOs splits the wallet's outputs if there are fewer spendable
+// outputs than the minimum.
+func (sw *SingleAddressWallet) maintainUTXOs() {
+	txn, ok, err := sw.SplitUTXOs()
+	if err != nil {
+		sw.log.Warn("failed to split outputs", zap.Error(err))
+	} else if ok {
+		sw.log.Info("split wallet outputs", zap.Stringer("transactionID", txn.ID()), zap.Int("outputs", len(txn.SiacoinOutputs)), zap.Int("target", sw.minUTXOs))
+	}
+}
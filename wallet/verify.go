@@ -0,0 +1,213 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
+	"go.sia.tech/siad/modules"
+	"go.uber.org/zap"
+	"lukechampine.com/frand"
+)
+
+// significantDriftPercent is the percentage of the wallet's stored value that
+// must be stale before an alert is registered.
+const significantDriftPercent = 1
+
+// alertUTXODriftID is used to overwrite previous UTXO drift alerts instead of
+// registering new ones.
+var alertUTXODriftID = frand.Entropy256()
+
+const (
+	// verifySyncTimeout is the maximum time to wait for the wallet to
+	// process the consensus change a verification scanned to.
+	verifySyncTimeout = 30 * time.Second
+	// verifyAttempts is the number of times the consensus set is rescanned
+	// if the wallet moves past the scanned consensus change.
+	verifyAttempts = 3
+)
+
+// ErrNotSynced is returned when the wallet's state cannot be compared with
+// the consensus set because the wallet has not processed the latest
+// consensus change.
+var ErrNotSynced = errors.New("wallet is not synced with the consensus set")
+
+// A utxoScanner rebuilds the set of siacoin outputs belonging to an address
+// directly from the consensus set.
+type utxoScanner struct {
+	addr types.Address
+
+	mu       sync.Mutex
+	changeID modules.ConsensusChangeID
+	height   uint64
+	utxos    map[types.SiacoinOutputID]types.Currency
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber.
+func (us *utxoScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	for _, diff := range cc.SiacoinOutputDiffs {
+		if types.Address(diff.SiacoinOutput.UnlockHash) != us.addr {
+			continue
+		}
+		id := types.SiacoinOutputID(diff.ID)
+		if diff.Direction == modules.DiffApply {
+			var sco types.SiacoinOutput
+			convertToCore(diff.SiacoinOutput, (*types.V1SiacoinOutput)(&sco))
+			us.utxos[id] = sco.Value
+		} else {
+			delete(us.utxos, id)
+		}
+	}
+	us.changeID = cc.ID
+	us.height = uint64(cc.BlockHeight)
+}
+
+// waitForChange waits for the wallet to process the consensus change. If the
+// wallet processed the change, true is returned and the caller must release
+// syncMu. False is returned without holding syncMu if the wallet moved past
+// the scanned height or did not process the change before the timeout.
+func (sw *SingleAddressWallet) waitForChange(ctx context.Context, changeID modules.ConsensusChangeID, height uint64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, verifySyncTimeout)
+	defer cancel()
+
+	t := time.NewTicker(100 * time.Millisecond)
+	defer t.Stop()
+	for {
+		sw.syncMu.Lock()
+		lastChange, lastHeight, err := sw.store.LastWalletChange()
+		if err != nil {
+			sw.syncMu.Unlock()
+			return false, fmt.Errorf("failed to get last wallet change: %w", err)
+		} else if lastChange == changeID {
+			return true, nil
+		}
+		sw.syncMu.Unlock()
+
+		if lastHeight > height {
+			// the wallet is ahead of the scan
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-t.C:
+		}
+	}
+}
+
+// VerifyUTXOs checks each of the wallet's stored siacoin outputs against the
+// consensus set. Outputs that no longer exist or have already been spent are
+// removed from the store. The number of removed outputs is returned. If the
+// removed outputs account for a significant portion of the wallet's stored
+// value, an alert is registered.
+//
+// Verification rescans the blockchain and may take a long time.
+func (sw *SingleAddressWallet) VerifyUTXOs(ctx context.Context) (int, error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	log := sw.log.Named("verifyUTXOs")
+	start := time.Now()
+
+	// the wallet must have processed the same consensus change as the
+	// scanner. Otherwise, recently added outputs would be incorrectly
+	// removed. The wallet's subscriber may still be catching up, so wait
+	// for it and rescan if it moves past the scan.
+	var scanner *utxoScanner
+	for attempt := 1; ; attempt++ {
+		scanner = &utxoScanner{
+			addr:  sw.addr,
+			utxos: make(map[types.SiacoinOutputID]types.Currency),
+		}
+		err = sw.cm.Subscribe(scanner, modules.ConsensusChangeBeginning, ctx.Done())
+		sw.cm.Unsubscribe(scanner)
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to scan consensus set: %w", err)
+		}
+
+		scanner.mu.Lock()
+		changeID, height := scanner.changeID, scanner.height
+		scanner.mu.Unlock()
+
+		synced, err := sw.waitForChange(ctx, changeID, height)
+		if err != nil {
+			return 0, err
+		} else if synced {
+			break
+		} else if ctx.Err() != nil {
+			return 0, ctx.Err()
+		} else if attempt >= verifyAttempts {
+			return 0, ErrNotSynced
+		}
+		log.Debug("wallet moved past scan, rescanning", zap.Int("attempt", attempt))
+	}
+	// the subscriber cannot update the store until verification finishes
+	defer sw.syncMu.Unlock()
+
+	scanner.mu.Lock()
+	defer scanner.mu.Unlock()
+
+	stored, err := sw.store.UnspentSiacoinElements()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get unspent outputs: %w", err)
+	}
+
+	var storedValue, staleValue types.Currency
+	var stale []SiacoinElement
+	for _, sce := range stored {
+		storedValue = storedValue.Add(sce.Value)
+		if value, ok := scanner.utxos[sce.ID]; ok && value.Equals(sce.Value) {
+			continue
+		}
+		stale = append(stale, sce)
+		staleValue = staleValue.Add(sce.Value)
+	}
+	if len(stale) == 0 {
+		log.Debug("wallet utxos verified", zap.Int("utxos", len(stored)), zap.Duration("elapsed", time.Since(start)))
+		return 0, nil
+	}
+
+	err = sw.store.UpdateWallet(scanner.changeID, scanner.height, func(tx UpdateTransaction) error {
+		for _, sce := range stale {
+			if err := tx.RemoveSiacoinElement(sce.ID); err != nil {
+				return fmt.Errorf("failed to remove siacoin element %v: %w", sce.ID, err)
+			}
+		}
+		if err := tx.SubWalletDelta(staleValue, time.Now()); err != nil {
+			return fmt.Errorf("failed to update wallet balance: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove stale outputs: %w", err)
+	}
+	log.Warn("removed stale wallet utxos", zap.Int("removed", len(stale)), zap.Int("utxos", len(stored)), zap.String("value", staleValue.ExactString()), zap.Duration("elapsed", time.Since(start)))
+
+	if sw.alerts != nil && staleValue.Mul64(100).Cmp(storedValue.Mul64(significantDriftPercent)) > 0 {
+		sw.alerts.Register(alerts.Alert{
+			ID:       alertUTXODriftID,
+			Severity: alerts.SeverityWarning,
+			Message:  "Wallet contained stale outputs",
+			Data: map[string]any{
+				"removed":     len(stale),
+				"staleValue":  staleValue,
+				"storedValue": storedValue,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+	return len(stale), nil
+}
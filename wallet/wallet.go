@@ -13,6 +13,7 @@ import (
 	"gitlab.com/NebulousLabs/encoding"
 	"go.sia.tech/core/consensus"
 	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/alerts"
 	"go.sia.tech/hostd/internal/chain"
 	"go.sia.tech/hostd/internal/threadgroup"
 	"go.sia.tech/siad/modules"
@@ -30,6 +31,12 @@ const (
 	// maxDefragUTXOs is the maximum number of utxos that will be added to a
 	// transaction when defragging
 	maxDefragUTXOs = 10
+	// maxTransactionSize is the maximum encoded size of a transaction that
+	// will be accepted by the transaction pool.
+	maxTransactionSize uint64 = modules.TransactionSizeLimit
+	// transactionPruneInterval is the interval between attempts to prune
+	// transactions older than the retention period.
+	transactionPruneInterval = time.Hour
 )
 
 // transaction sources indicate the source of a transaction. Transactions can
@@ -47,6 +54,18 @@ var (
 	// ErrNotEnoughFunds is returned when there are not enough unspent outputs
 	// to fund a transaction.
 	ErrNotEnoughFunds = errors.New("not enough funds")
+	// ErrTransactionTooLarge is returned when funding a transaction would
+	// require so many inputs that the transaction would exceed the maximum
+	// size accepted by the transaction pool. The wallet's outputs should be
+	// consolidated by sending a portion of the balance back to the wallet's
+	// own address.
+	ErrTransactionTooLarge = errors.New("funded transaction would exceed the maximum transaction size")
+	// ErrReservationNotFound is returned when a reservation does not exist or
+	// has already been released.
+	ErrReservationNotFound = errors.New("reservation not found")
+	// ErrBelowReserve is returned when a withdrawal would reduce the
+	// wallet's spendable balance below the fee reserve.
+	ErrBelowReserve = errors.New("withdrawal would reduce the balance below the fee reserve")
 )
 
 type (
@@ -58,19 +77,41 @@ type (
 		TipState() consensus.State
 		BlockAtHeight(height uint64) (types.Block, bool)
 		Subscribe(subscriber modules.ConsensusSetSubscriber, ccID modules.ConsensusChangeID, cancel <-chan struct{}) error
+		Unsubscribe(subscriber modules.ConsensusSetSubscriber)
+	}
+
+	// Alerts registers global alerts.
+	Alerts interface {
+		Register(alerts.Alert)
 	}
 
 	// A TransactionPool manages unconfirmed transactions.
 	TransactionPool interface {
 		Subscribe(subscriber modules.TransactionPoolSubscriber)
+		RecommendedFee() types.Currency
+		AcceptTransactionSet([]types.Transaction) error
 	}
 
+	// A sizeWriter is an io.Writer that discards its input, counting the
+	// number of bytes written.
+	sizeWriter uint64
+
 	// A SiacoinElement is a SiacoinOutput along with its ID.
 	SiacoinElement struct {
 		types.SiacoinOutput
 		ID types.SiacoinOutputID
 	}
 
+	// A Reservation is a set of outputs locked by a call to FundTransaction
+	// that has not been released.
+	Reservation struct {
+		ID        uint64                  `json:"id"`
+		Outputs   []types.SiacoinOutputID `json:"outputs"`
+		Value     types.Currency          `json:"value"`
+		Timestamp time.Time               `json:"timestamp"`
+		Age       time.Duration           `json:"age"`
+	}
+
 	// A Transaction is an on-chain transaction relevant to a particular wallet,
 	// paired with useful metadata.
 	Transaction struct {
@@ -91,10 +132,43 @@ type (
 		priv types.PrivateKey
 		addr types.Address
 
-		cm    ChainManager
-		store SingleAddressStore
-		log   *zap.Logger
-		tg    *threadgroup.ThreadGroup
+		cm     ChainManager
+		tp     TransactionPool
+		store  SingleAddressStore
+		alerts Alerts
+		log    *zap.Logger
+		tg     *threadgroup.ThreadGroup
+
+		// subscribeMu is held while the wallet subscribes to or replays
+		// consensus changes so that it is never subscribed twice.
+		subscribeMu sync.Mutex
+
+		// txnRetention is the number of blocks transactions are kept in the
+		// wallet's history. 0 keeps all transactions.
+		txnRetention uint64
+
+		// feeReserve is the balance kept in the wallet to pay for
+		// transaction fees. Funds above the reserve can be withdrawn.
+		feeReserve types.Currency
+		// withdrawAddress and withdrawInterval configure scheduled
+		// withdrawals of funds above the reserve. Zero disables them.
+		withdrawAddress  types.Address
+		withdrawInterval time.Duration
+		// withdrawMu serializes withdrawals so that concurrent withdrawals
+		// cannot dip below the reserve.
+		withdrawMu sync.Mutex
+
+		// minUTXOs is the minimum number of spendable outputs maintained by
+		// splitting the balance. 0 disables splitting.
+		minUTXOs int
+		// splitMu serializes splits so that concurrent splits do not create
+		// more outputs than needed.
+		splitMu sync.Mutex
+
+		// syncMu is held while a consensus change is written to the store
+		// so the wallet's last change can be read without racing the
+		// subscriber.
+		syncMu sync.Mutex
 
 		mu sync.Mutex // protects the following fields
 		// tpoolTxns maps a transaction set ID to the transactions in that set
@@ -114,6 +188,10 @@ type (
 		// will be released either by calling Release for unused transactions or
 		// being confirmed in a block.
 		locked map[types.SiacoinOutputID]bool
+		// reservations tracks the outputs locked by each outstanding call to
+		// FundTransaction.
+		reservations      map[uint64]Reservation
+		nextReservationID uint64
 	}
 )
 
@@ -237,6 +315,38 @@ func (sw *SingleAddressWallet) Balance() (spendable, confirmed, unconfirmed type
 	return
 }
 
+// OutputValues returns the values of the wallet's unspent outputs with the
+// given IDs. An error is returned if any of the outputs are not unspent
+// outputs of the wallet.
+func (sw *SingleAddressWallet) OutputValues(ids []types.Hash256) (map[types.SiacoinOutputID]types.Currency, error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	outputs, err := sw.store.UnspentSiacoinElements()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unspent outputs: %w", err)
+	}
+	wanted := make(map[types.SiacoinOutputID]bool, len(ids))
+	for _, id := range ids {
+		wanted[types.SiacoinOutputID(id)] = true
+	}
+	values := make(map[types.SiacoinOutputID]types.Currency, len(ids))
+	for _, sce := range outputs {
+		if wanted[sce.ID] {
+			values[sce.ID] = sce.Value
+		}
+	}
+	for id := range wanted {
+		if _, ok := values[id]; !ok {
+			return nil, fmt.Errorf("output %v is not an unspent output of the wallet", id)
+		}
+	}
+	return values, nil
+}
+
 // Transactions returns a paginated list of transactions, ordered by block
 // height descending. If no more transactions are available, (nil, nil) is
 // returned.
@@ -259,6 +369,45 @@ func (sw *SingleAddressWallet) TransactionCount() (uint64, error) {
 	return sw.store.TransactionCount()
 }
 
+// TransactionRetention returns the number of blocks transactions are kept in
+// the wallet's history. 0 indicates that transactions are never pruned.
+func (sw *SingleAddressWallet) TransactionRetention() uint64 {
+	return sw.txnRetention
+}
+
+// PrunedTransactions returns the totals of the transactions that were pruned
+// from the wallet's history.
+func (sw *SingleAddressWallet) PrunedTransactions() (PrunedTransactions, error) {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return PrunedTransactions{}, err
+	}
+	defer done()
+	return sw.store.PrunedTransactions()
+}
+
+// pruneTransactions removes transactions older than the retention period from
+// the wallet's history.
+func (sw *SingleAddressWallet) pruneTransactions() error {
+	done, err := sw.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	height := sw.ScanHeight()
+	if sw.txnRetention == 0 || height <= sw.txnRetention {
+		return nil
+	}
+	pruned, err := sw.store.PruneTransactions(height - sw.txnRetention)
+	if err != nil {
+		return err
+	} else if pruned > 0 {
+		sw.log.Info("pruned wallet transactions", zap.Int("pruned", pruned), zap.Uint64("height", height-sw.txnRetention))
+	}
+	return nil
+}
+
 // FundTransaction adds siacoin inputs worth at least amount to the provided
 // transaction. If necessary, a change output will also be added. The inputs
 // will not be available to future calls to FundTransaction unless ReleaseInputs
@@ -313,6 +462,14 @@ func (sw *SingleAddressWallet) FundTransaction(txn *types.Transaction, amount ty
 		return nil, nil, ErrNotEnoughFunds
 	}
 
+	// check that the funded transaction will be accepted by the transaction
+	// pool. A wallet with many small outputs may need more inputs than fit
+	// in a single transaction.
+	baseSize, inputSize := sw.fundingSize(*txn)
+	if size := baseSize + uint64(len(selected))*inputSize; size > maxTransactionSize {
+		return nil, nil, fmt.Errorf("%w: %d inputs required, estimated size %d bytes exceeds limit of %d bytes; consolidate the wallet's outputs by sending a smaller amount to the wallet's own address", ErrTransactionTooLarge, len(selected), size, maxTransactionSize)
+	}
+
 	// check if remaining utxos should be defragged
 	txnInputs := len(txn.SiacoinInputs) + len(selected)
 	if len(usableUTXOs) > transactionDefragThreshold && txnInputs < maxInputsForDefrag {
@@ -324,6 +481,10 @@ func (sw *SingleAddressWallet) FundTransaction(txn *types.Transaction, amount ty
 		for i := len(defraggable) - 1; i >= 0; i-- {
 			if txnInputs >= maxInputsForDefrag {
 				break
+			} else if baseSize+uint64(len(selected)+1)*inputSize > maxTransactionSize {
+				// do not let defragging push the transaction over the
+				// size limit
+				break
 			}
 
 			sce := defraggable[i]
@@ -341,6 +502,13 @@ func (sw *SingleAddressWallet) FundTransaction(txn *types.Transaction, amount ty
 		})
 	}
 
+	sw.nextReservationID++
+	reservation := Reservation{
+		ID:        sw.nextReservationID,
+		Outputs:   make([]types.SiacoinOutputID, 0, len(selected)),
+		Value:     inputSum,
+		Timestamp: time.Now(),
+	}
 	toSign := make([]types.Hash256, len(selected))
 	for i, sce := range selected {
 		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
@@ -349,18 +517,86 @@ func (sw *SingleAddressWallet) FundTransaction(txn *types.Transaction, amount ty
 		})
 		toSign[i] = types.Hash256(sce.ID)
 		sw.locked[sce.ID] = true
+		reservation.Outputs = append(reservation.Outputs, sce.ID)
 	}
+	sw.reservations[reservation.ID] = reservation
 
 	release := func() {
 		sw.mu.Lock()
 		defer sw.mu.Unlock()
-		for _, id := range toSign {
-			delete(sw.locked, types.SiacoinOutputID(id))
-		}
+		// the reservation may have been force released and its outputs
+		// reserved again by another call
+		sw.releaseReservation(reservation.ID)
 	}
 	return toSign, release, nil
 }
 
+// releaseReservation unlocks the outputs of a reservation. It must be called
+// with the wallet's mutex held.
+func (sw *SingleAddressWallet) releaseReservation(id uint64) bool {
+	reservation, ok := sw.reservations[id]
+	if !ok {
+		return false
+	}
+	for _, outputID := range reservation.Outputs {
+		delete(sw.locked, outputID)
+	}
+	delete(sw.reservations, id)
+	return true
+}
+
+// Reservations returns the outputs currently locked by outstanding calls to
+// FundTransaction, oldest first.
+func (sw *SingleAddressWallet) Reservations() []Reservation {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	reservations := make([]Reservation, 0, len(sw.reservations))
+	for _, r := range sw.reservations {
+		r.Outputs = append([]types.SiacoinOutputID(nil), r.Outputs...)
+		r.Age = time.Since(r.Timestamp)
+		reservations = append(reservations, r)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].ID < reservations[j].ID
+	})
+	return reservations
+}
+
+// ReleaseReservation forcibly unlocks the outputs of a reservation so they can
+// be used to fund other transactions. It should only be used to recover from
+// leaked reservations; if the transaction that reserved the outputs is still
+// broadcast, the outputs may be double spent.
+func (sw *SingleAddressWallet) ReleaseReservation(id uint64) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	reservation, ok := sw.reservations[id]
+	if !ok {
+		return ErrReservationNotFound
+	}
+	sw.releaseReservation(id)
+	sw.log.Warn("force released wallet reservation; its outputs may be double spent if the reserving transaction is broadcast", zap.Uint64("id", id), zap.Int("outputs", len(reservation.Outputs)), zap.Stringer("value", reservation.Value), zap.Duration("age", time.Since(reservation.Timestamp)))
+	return nil
+}
+
+// fundingSize estimates the encoded size of txn after it has been funded. The
+// base size includes a change output; each input added by the wallet adds
+// inputSize bytes, including its signature.
+func (sw *SingleAddressWallet) fundingSize(txn types.Transaction) (baseSize, inputSize uint64) {
+	// copy the outputs to avoid modifying the caller's transaction
+	outputs := make([]types.SiacoinOutput, 0, len(txn.SiacoinOutputs)+1)
+	outputs = append(outputs, txn.SiacoinOutputs...)
+	txn.SiacoinOutputs = append(outputs, types.SiacoinOutput{Address: sw.addr, Value: types.MaxCurrency})
+
+	input := types.SiacoinInput{UnlockConditions: types.StandardUnlockConditions(sw.priv.PublicKey())}
+	sig := types.TransactionSignature{
+		CoveredFields: types.CoveredFields{WholeTransaction: true},
+		Signature:     make([]byte, 64),
+	}
+	return encodedSize(txn), encodedSize(input) + encodedSize(sig)
+}
+
 // SignTransaction adds a signature to each of the specified inputs.
 func (sw *SingleAddressWallet) SignTransaction(cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) error {
 	done, err := sw.tg.Add()
@@ -599,6 +835,7 @@ func (sw *SingleAddressWallet) ProcessConsensusChange(cc modules.ConsensusChange
 	sw.mu.Unlock()
 
 	// begin a database transaction to update the wallet state
+	sw.syncMu.Lock()
 	err = sw.store.UpdateWallet(cc.ID, uint64(cc.BlockHeight), func(tx UpdateTransaction) error {
 		// add new siacoin outputs and remove spent or reverted siacoin outputs
 		for _, diff := range cc.SiacoinOutputDiffs {
@@ -748,6 +985,8 @@ func (sw *SingleAddressWallet) ProcessConsensusChange(cc modules.ConsensusChange
 	if err != nil {
 		sw.log.Panic("failed to update wallet", zap.Error(err), zap.String("changeID", cc.ID.String()), zap.Uint64("height", uint64(cc.BlockHeight)))
 	}
+	atomic.StoreUint64(&sw.scanHeight, uint64(cc.BlockHeight))
+	sw.syncMu.Unlock()
 
 	sw.mu.Lock()
 	for _, id := range locked {
@@ -755,7 +994,9 @@ func (sw *SingleAddressWallet) ProcessConsensusChange(cc modules.ConsensusChange
 	}
 	sw.mu.Unlock()
 
-	atomic.StoreUint64(&sw.scanHeight, uint64(cc.BlockHeight))
+	if sw.minUTXOs > 0 && cc.Synced {
+		go sw.maintainUTXOs()
+	}
 	sw.log.Debug("applied consensus change", zap.String("changeID", cc.ID.String()), zap.Int("applied", len(cc.AppliedBlocks)), zap.Int("reverted", len(cc.RevertedBlocks)), zap.Uint64("height", uint64(cc.BlockHeight)), zap.Duration("elapsed", time.Since(start)), zap.String("address", sw.addr.String()))
 }
 
@@ -774,6 +1015,21 @@ func payoutTransaction(output SiacoinElement, index types.ChainIndex, source Tra
 	}
 }
 
+// Write implements io.Writer by counting the bytes written.
+func (w *sizeWriter) Write(p []byte) (int, error) {
+	*w += sizeWriter(len(p))
+	return len(p), nil
+}
+
+// encodedSize returns the number of bytes v occupies when encoded.
+func encodedSize(v types.EncoderTo) uint64 {
+	var w sizeWriter
+	e := types.NewEncoder(&w)
+	v.EncodeTo(e)
+	e.Flush()
+	return uint64(w)
+}
+
 // convertToCore converts a siad type to an equivalent core type.
 func convertToCore(siad encoding.SiaMarshaler, core types.DecoderFrom) {
 	var buf bytes.Buffer
@@ -785,8 +1041,28 @@ func convertToCore(siad encoding.SiaMarshaler, core types.DecoderFrom) {
 	}
 }
 
+// subscribe subscribes the wallet to consensus changes after changeID. If the
+// change ID is unknown, the wallet is reset and rescans the blockchain.
+func (sw *SingleAddressWallet) subscribe(changeID modules.ConsensusChangeID) {
+	sw.subscribeMu.Lock()
+	defer sw.subscribeMu.Unlock()
+
+	err := sw.cm.Subscribe(sw, changeID, sw.tg.Done())
+	if errors.Is(err, chain.ErrInvalidChangeID) {
+		sw.log.Warn("rescanning blockchain due to unknown consensus change ID")
+		// reset change ID and subscribe again
+		if err := sw.store.ResetWallet(types.HashBytes(sw.priv[:])); err != nil {
+			sw.log.Fatal("failed to reset wallet", zap.Error(err))
+		} else if err = sw.cm.Subscribe(sw, modules.ConsensusChangeBeginning, sw.tg.Done()); err != nil {
+			sw.log.Fatal("failed to reset consensus change subscription", zap.Error(err))
+		}
+	} else if err != nil && !strings.Contains(err.Error(), "ThreadGroup already stopped") {
+		sw.log.Fatal("failed to subscribe to consensus set", zap.Error(err))
+	}
+}
+
 // NewSingleAddressWallet returns a new SingleAddressWallet using the provided private key and store.
-func NewSingleAddressWallet(priv types.PrivateKey, cm ChainManager, tp TransactionPool, store SingleAddressStore, log *zap.Logger) (*SingleAddressWallet, error) {
+func NewSingleAddressWallet(priv types.PrivateKey, cm ChainManager, tp TransactionPool, store SingleAddressStore, log *zap.Logger, opts ...Option) (*SingleAddressWallet, error) {
 	changeID, scanHeight, err := store.LastWalletChange()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get last wallet change: %w", err)
@@ -810,34 +1086,48 @@ func NewSingleAddressWallet(priv types.PrivateKey, cm ChainManager, tp Transacti
 
 		store: store,
 		cm:    cm,
+		tp:    tp,
 		log:   log,
 		tg:    threadgroup.New(),
 
 		addr: types.StandardUnlockHash(priv.PublicKey()),
 
 		locked:          make(map[types.SiacoinOutputID]bool),
+		reservations:    make(map[uint64]Reservation),
 		consensusLocked: make(map[types.SiacoinOutputID]bool),
 		tpoolSpent:      make(map[types.SiacoinOutputID]bool),
 
 		tpoolUtxos: make(map[types.SiacoinOutputID]SiacoinElement),
 		tpoolTxns:  make(map[modules.TransactionSetID][]Transaction),
 	}
+	for _, opt := range opts {
+		opt(sw)
+	}
 
-	go func() {
-		// note: start in goroutine to avoid blocking startup
-		err := cm.Subscribe(sw, changeID, sw.tg.Done())
-		if errors.Is(err, chain.ErrInvalidChangeID) {
-			sw.log.Warn("rescanning blockchain due to unknown consensus change ID")
-			// reset change ID and subscribe again
-			if err := store.ResetWallet(seedHash); err != nil {
-				sw.log.Fatal("failed to reset wallet", zap.Error(err))
-			} else if err = cm.Subscribe(sw, modules.ConsensusChangeBeginning, sw.tg.Done()); err != nil {
-				sw.log.Fatal("failed to reset consensus change subscription", zap.Error(err))
-			}
-		} else if err != nil && !strings.Contains(err.Error(), "ThreadGroup already stopped") {
-			sw.log.Fatal("failed to subscribe to consensus set", zap.Error(err))
-		}
-	}()
+	// note: start in goroutine to avoid blocking startup
+	go sw.subscribe(changeID)
 	tp.Subscribe(sw)
+
+	if sw.txnRetention > 0 {
+		go func() {
+			t := time.NewTicker(transactionPruneInterval)
+			defer t.Stop()
+
+			for {
+				select {
+				case <-sw.tg.Done():
+					return
+				case <-t.C:
+					if err := sw.pruneTransactions(); err != nil {
+						sw.log.Error("failed to prune transactions", zap.Error(err))
+					}
+				}
+			}
+		}()
+	}
+
+	if sw.withdrawInterval > 0 && sw.withdrawAddress != types.VoidAddress {
+		go sw.runScheduledWithdrawals()
+	}
 	return sw, nil
 }
@@ -1,7 +1,11 @@
 package wallet_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"reflect"
 	"sort"
 	"testing"
 	"time"
@@ -11,6 +15,7 @@ import (
 	"go.sia.tech/hostd/wallet"
 	stypes "go.sia.tech/siad/types"
 	"go.uber.org/zap/zaptest"
+	"lukechampine.com/frand"
 )
 
 func TestWallet(t *testing.T) {
@@ -405,3 +410,461 @@ func TestWalletUTXOSelection(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestWalletFragmentedFunding(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// mine a single output to the wallet and wait for it to mature
+	if err := w.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sleep for consensus sync
+
+	_, balance, _, err := w.Balance()
+	if err != nil {
+		t.Fatal(err)
+	} else if balance.IsZero() {
+		t.Fatal("expected non-zero balance")
+	}
+
+	// fragment the wallet's balance into many small outputs
+	const fragments = 200
+	splitValue := types.Siacoins(1)
+	splitOutputs := make([]types.SiacoinOutput, fragments)
+	for i := range splitOutputs {
+		splitOutputs[i] = types.SiacoinOutput{
+			Value:   splitValue,
+			Address: w.Address(),
+		}
+	}
+	if _, err := w.SendSiacoins(splitOutputs); err != nil {
+		t.Fatal(err)
+	}
+	// mine a block to confirm the split
+	if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sleep for consensus sync
+
+	// burn the large change output so only the fragmented outputs remain
+	utxos, err := w.Store().UnspentSiacoinElements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var change types.Currency
+	for _, utxo := range utxos {
+		if !utxo.Value.Equals(splitValue) {
+			change = change.Add(utxo.Value)
+		}
+	}
+	if _, err := w.SendSiacoins([]types.SiacoinOutput{{Address: types.VoidAddress, Value: change}}); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sleep for consensus sync
+
+	// funding a transaction that requires most of the fragments should fail
+	// instead of creating a transaction the tpool will reject
+	txn := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{
+			{Address: types.VoidAddress, Value: splitValue.Mul64(fragments - 10)},
+		},
+	}
+	if _, _, err := w.FundTransaction(&txn, txn.SiacoinOutputs[0].Value); !errors.Is(err, wallet.ErrTransactionTooLarge) {
+		t.Fatalf("expected ErrTransactionTooLarge, got %v", err)
+	} else if len(txn.SiacoinInputs) != 0 || len(txn.SiacoinOutputs) != 1 {
+		t.Fatal("expected transaction to be unmodified")
+	}
+
+	// a smaller transaction should still be funded and accepted. Defrag
+	// inputs must not push the transaction over the size limit.
+	if _, err := w.SendSiacoins([]types.SiacoinOutput{{Address: types.VoidAddress, Value: splitValue.Mul64(50)}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalletVerifyUTXOs(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// mine until the wallet has funds
+	if err := w.MineBlocks(w.Address(), 5+int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sleep for consensus sync
+
+	// a consistent wallet should not be modified
+	if removed, err := w.VerifyUTXOs(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if removed != 0 {
+		t.Fatalf("expected no stale utxos, got %v", removed)
+	}
+
+	utxos, err := w.Store().UnspentSiacoinElements()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(utxos) == 0 {
+		t.Fatal("expected utxos")
+	}
+
+	// seed a stale utxo that does not exist in the consensus set
+	stale := wallet.SiacoinElement{
+		ID: frand.Entropy256(),
+		SiacoinOutput: types.SiacoinOutput{
+			Address: w.Address(),
+			Value:   types.Siacoins(1000),
+		},
+	}
+	changeID, height, err := w.Store().LastWalletChange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Store().UpdateWallet(changeID, height, func(tx wallet.UpdateTransaction) error {
+		return tx.AddSiacoinElement(stale)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := w.VerifyUTXOs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if removed != 1 {
+		t.Fatalf("expected 1 stale utxo, got %v", removed)
+	}
+
+	// check that only the stale utxo was removed
+	remaining, err := w.Store().UnspentSiacoinElements()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(remaining) != len(utxos) {
+		t.Fatalf("expected %v utxos, got %v", len(utxos), len(remaining))
+	}
+	for _, utxo := range remaining {
+		if utxo.ID == stale.ID {
+			t.Fatal("stale utxo was not removed")
+		}
+	}
+
+	// verifying immediately after new blocks must wait for the wallet to
+	// catch up instead of failing
+	for i := 0; i < 5; i++ {
+		if err := w.MineBlocks(w.Address(), 1); err != nil {
+			t.Fatal(err)
+		} else if removed, err := w.VerifyUTXOs(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if removed != 0 {
+			t.Fatalf("expected no stale utxos, got %v", removed)
+		}
+	}
+}
+
+func TestWalletReservations(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// mine until the first output has matured
+	if err := w.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	if reservations := w.Reservations(); len(reservations) != 0 {
+		t.Fatalf("expected no reservations, got %v", reservations)
+	}
+
+	// reserve the wallet's only output
+	var txn types.Transaction
+	toSign, release, err := w.FundTransaction(&txn, types.Siacoins(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reservations := w.Reservations()
+	if len(reservations) != 1 {
+		t.Fatalf("expected 1 reservation, got %v", reservations)
+	} else if len(reservations[0].Outputs) != len(toSign) || types.Hash256(reservations[0].Outputs[0]) != toSign[0] {
+		t.Fatalf("expected reserved outputs %v, got %v", toSign, reservations[0].Outputs)
+	}
+
+	// the output should not be available to other transactions
+	if _, _, err := w.FundTransaction(&types.Transaction{}, types.Siacoins(1)); !errors.Is(err, wallet.ErrNotEnoughFunds) {
+		t.Fatalf("expected ErrNotEnoughFunds, got %v", err)
+	}
+
+	// force release the reservation
+	if err := w.ReleaseReservation(reservations[0].ID); err != nil {
+		t.Fatal(err)
+	} else if err := w.ReleaseReservation(reservations[0].ID); !errors.Is(err, wallet.ErrReservationNotFound) {
+		t.Fatalf("expected ErrReservationNotFound, got %v", err)
+	} else if reservations := w.Reservations(); len(reservations) != 0 {
+		t.Fatalf("expected no reservations, got %v", reservations)
+	}
+
+	// the output should be available again
+	_, release2, err := w.FundTransaction(&types.Transaction{}, types.Siacoins(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release2()
+
+	// calling the stale release function should not unlock the output
+	// reserved by the new call
+	release()
+	if reservations := w.Reservations(); len(reservations) != 1 {
+		t.Fatalf("expected 1 reservation, got %v", reservations)
+	} else if _, _, err := w.FundTransaction(&types.Transaction{}, types.Siacoins(1)); !errors.Is(err, wallet.ErrNotEnoughFunds) {
+		t.Fatalf("expected ErrNotEnoughFunds, got %v", err)
+	}
+}
+
+func TestWalletFeeReserve(t *testing.T) {
+	log := zaptest.NewLogger(t)
+
+	reserve := types.Siacoins(100000)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"), wallet.WithFeeReserve(reserve))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// nothing is withdrawable until the balance exceeds the reserve
+	if withdrawable, err := w.WithdrawableBalance(); err != nil {
+		t.Fatal(err)
+	} else if !withdrawable.IsZero() {
+		t.Fatalf("expected zero withdrawable balance, got %v", withdrawable)
+	}
+
+	// fund the wallet
+	reward := w.TipState().BlockReward()
+	if err := w.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	withdrawable, err := w.WithdrawableBalance()
+	if err != nil {
+		t.Fatal(err)
+	} else if !withdrawable.Equals(reward.Sub(reserve)) {
+		t.Fatalf("expected %v withdrawable, got %v", reward.Sub(reserve), withdrawable)
+	}
+
+	// withdrawing the entire withdrawable balance should fail since the
+	// miner fee would be paid from the reserve
+	addr := types.StandardUnlockHash(types.GeneratePrivateKey().PublicKey())
+	if _, err := w.Withdraw(addr, withdrawable); !errors.Is(err, wallet.ErrBelowReserve) {
+		t.Fatalf("expected ErrBelowReserve, got %v", err)
+	}
+
+	// withdraw half of the withdrawable balance
+	amount := withdrawable.Div64(2)
+	if _, err := w.Withdraw(addr, amount); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	// the remaining balance should still cover the reserve
+	spendable, _, _, err := w.Balance()
+	if err != nil {
+		t.Fatal(err)
+	} else if spendable.Cmp(reserve) < 0 {
+		t.Fatalf("expected spendable balance %v to be at least the reserve %v", spendable, reserve)
+	} else if spendable.Cmp(reward.Sub(amount)) >= 0 {
+		t.Fatalf("expected spendable balance %v to be less than %v", spendable, reward.Sub(amount))
+	}
+
+	// the reserve cannot be withdrawn
+	if _, err := w.Withdraw(addr, spendable.Sub(reserve).Add(types.Siacoins(1))); !errors.Is(err, wallet.ErrBelowReserve) {
+		t.Fatalf("expected ErrBelowReserve, got %v", err)
+	}
+}
+
+func TestWalletMinUTXOs(t *testing.T) {
+	const minUTXOs = 4
+	log := zaptest.NewLogger(t)
+
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"), wallet.WithMinUTXOs(minUTXOs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// fund the wallet with a single output
+	if err := w.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	utxos, err := w.Store().UnspentSiacoinElements()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(utxos) != 1 {
+		t.Fatalf("expected 1 utxo, got %v", len(utxos))
+	}
+
+	// the matured output should be split once the next block is processed
+	if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for the split to be broadcast
+
+	if count, target, err := w.UTXOCount(); err != nil {
+		t.Fatal(err)
+	} else if target != minUTXOs {
+		t.Fatalf("expected target %v, got %v", minUTXOs, target)
+	} else if count != minUTXOs {
+		t.Fatalf("expected %v utxos including the pending split, got %v", minUTXOs, count)
+	}
+
+	// confirm the split
+	if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
+
+	utxos, err = w.Store().UnspentSiacoinElements()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(utxos) != minUTXOs {
+		t.Fatalf("expected %v utxos, got %v", minUTXOs, len(utxos))
+	} else if count, _, err := w.UTXOCount(); err != nil {
+		t.Fatal(err)
+	} else if count != minUTXOs {
+		t.Fatalf("expected %v utxos, got %v", minUTXOs, count)
+	}
+
+	// the outputs should not be split again
+	if _, ok, err := w.SplitUTXOs(); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected no split")
+	}
+}
+
+func TestWalletReindex(t *testing.T) {
+	log := zaptest.NewLogger(t)
+	w, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir(), log.Named("wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// mine until the wallet has funds
+	if err := w.MineBlocks(w.Address(), 5+int(stypes.MaturityDelay)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sleep for consensus sync
+
+	// send siacoins so the history includes a wallet transaction
+	if _, err := w.SendSiacoins([]types.SiacoinOutput{{Address: types.VoidAddress, Value: types.Siacoins(100)}}); err != nil {
+		t.Fatal(err)
+	} else if err := w.MineBlocks(types.VoidAddress, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sleep for consensus sync
+
+	type walletState struct {
+		balance types.Currency
+		utxos   []wallet.SiacoinElement
+		txnIDs  []types.TransactionID
+	}
+	currentState := func() (state walletState) {
+		t.Helper()
+		_, state.balance, _, err = w.Balance()
+		if err != nil {
+			t.Fatal(err)
+		}
+		state.utxos, err = w.Store().UnspentSiacoinElements()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(state.utxos, func(i, j int) bool {
+			return bytes.Compare(state.utxos[i].ID[:], state.utxos[j].ID[:]) < 0
+		})
+		txns, err := w.Transactions(1000, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, txn := range txns {
+			state.txnIDs = append(state.txnIDs, txn.ID)
+		}
+		return
+	}
+
+	before := currentState()
+	if len(before.utxos) == 0 || len(before.txnIDs) < 2 {
+		t.Fatal("expected wallet to have utxos and transactions")
+	}
+
+	var lastHeight uint64
+	err = w.Reindex(context.Background(), func(height uint64) {
+		lastHeight = height
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if lastHeight != w.TipState().Index.Height {
+		t.Fatalf("expected progress to reach height %v, got %v", w.TipState().Index.Height, lastHeight)
+	} else if w.ScanHeight() != lastHeight {
+		t.Fatalf("expected scan height %v, got %v", lastHeight, w.ScanHeight())
+	}
+
+	after := currentState()
+	if !after.balance.Equals(before.balance) {
+		t.Fatalf("expected balance %v, got %v", before.balance, after.balance)
+	} else if !reflect.DeepEqual(after.utxos, before.utxos) {
+		t.Fatalf("expected utxos %v, got %v", before.utxos, after.utxos)
+	} else if !reflect.DeepEqual(after.txnIDs, before.txnIDs) {
+		t.Fatalf("expected transactions %v, got %v", before.txnIDs, after.txnIDs)
+	}
+
+	// the wallet should continue processing new blocks
+	if err := w.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // sleep for consensus sync
+	if w.ScanHeight() != w.TipState().Index.Height {
+		t.Fatalf("expected scan height %v, got %v", w.TipState().Index.Height, w.ScanHeight())
+	}
+
+	// a cancelled reindex should resume in the background
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.Reindex(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for i := 0; i < 100 && w.ScanHeight() != w.TipState().Index.Height; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if w.ScanHeight() != w.TipState().Index.Height {
+		t.Fatalf("expected scan height %v, got %v", w.TipState().Index.Height, w.ScanHeight())
+	} else if _, balance, _, err := w.Balance(); err != nil {
+		t.Fatal(err)
+	} else if balance.IsZero() {
+		t.Fatal("expected non-zero balance")
+	}
+}